@@ -0,0 +1,388 @@
+// Package anomaly watches how clients behave and raises an alert when one
+// deviates sharply from the rest. Activity is counted per subject, an IP, an
+// authenticated pubkey or a connection fingerprint, over fixed windows. At the
+// end of each window every active subject contributes one sample per feature
+// to an exponentially weighted baseline shared by all subjects, and a sample
+// more than Threshold standard deviations above it raises an alert.
+//
+// Features:
+//
+//	req_rate            REQs per window
+//	filter_breadth      REQ filters without ids, authors or tag conditions
+//	subscription_churn  subscriptions closed or replaced
+//	rejection_ratio     share of published events answered OK false
+package anomaly
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Features an alert can be raised on.
+const (
+	FeatureREQRate        = "req_rate"
+	FeatureFilterBreadth  = "filter_breadth"
+	FeatureChurn          = "subscription_churn"
+	FeatureRejectionRatio = "rejection_ratio"
+)
+
+// Features lists every feature, in the order they are checked.
+var Features = []string{FeatureREQRate, FeatureFilterBreadth, FeatureChurn, FeatureRejectionRatio}
+
+const (
+	defaultWindow      = time.Minute
+	defaultThreshold   = 4
+	defaultWarmup      = 50
+	defaultCooldown    = 15 * time.Minute
+	defaultMaxSubjects = 10000
+	defaultMaxAlerts   = 100
+
+	// baselineWeight is the weight of each new sample in the baseline.
+	baselineWeight = 0.01
+	// minPublished is the events a subject must publish in a window before
+	// its rejection ratio is sampled.
+	minPublished = 10
+	// webhookTimeout bounds one alert delivery.
+	webhookTimeout = 10 * time.Second
+)
+
+// minValues are the values below which a feature never alerts, however
+// quiet the baseline is.
+var minValues = map[string]float64{
+	FeatureREQRate:        30,
+	FeatureFilterBreadth:  10,
+	FeatureChurn:          30,
+	FeatureRejectionRatio: 0.5,
+}
+
+// Alert is a subject whose feature deviated from the baseline.
+type Alert struct {
+	Subject   string    `json:"subject"` // "ip:<addr>", "pubkey:<hex>" or "fingerprint:<hex>"
+	Feature   string    `json:"feature"`
+	Value     float64   `json:"value"`
+	Baseline  float64   `json:"baseline"`  // mean of all subjects
+	Deviation float64   `json:"deviation"` // standard deviations above the mean
+	At        time.Time `json:"at"`
+}
+
+// Baseline summarizes one feature over all subjects.
+type Baseline struct {
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"stddev"`
+	Samples int64   `json:"samples"`
+}
+
+// baseline is an exponentially weighted mean and variance.
+type baseline struct {
+	mean, variance float64
+	samples        int64
+}
+
+// add folds a sample into the baseline.
+func (b *baseline) add(x float64) {
+	if b.samples == 0 {
+		b.mean = x
+	} else {
+		d := x - b.mean
+		b.mean += baselineWeight * d
+		b.variance = (1 - baselineWeight) * (b.variance + baselineWeight*d*d)
+	}
+	b.samples++
+}
+
+// counters is the activity of one subject in the current window.
+type counters struct {
+	reqs, broad, churn, published, rejected int64
+}
+
+// values returns the features sampled from c; rejection_ratio only once
+// enough events were published.
+func (c *counters) values() map[string]float64 {
+	v := map[string]float64{
+		FeatureREQRate:       float64(c.reqs),
+		FeatureFilterBreadth: float64(c.broad),
+		FeatureChurn:         float64(c.churn),
+	}
+	if c.published >= minPublished {
+		v[FeatureRejectionRatio] = float64(c.rejected) / float64(c.published)
+	}
+	return v
+}
+
+// Detector counts client activity and compares it to the baseline.
+type Detector struct {
+	cfg    config.AnomalyConfig
+	client *http.Client
+	log    *zap.Logger
+
+	mu         sync.Mutex
+	current    map[string]*counters // subject -> activity this window
+	baselines  map[string]*baseline // feature -> baseline
+	lastAlerts map[string]time.Time // subject + feature -> last alert
+	alerts     []Alert              // most recent last
+	onAlert    func(Alert)
+}
+
+// New creates a detector, filling in defaults for unset limits.
+func New(cfg config.AnomalyConfig) *Detector {
+	if cfg.Window <= 0 {
+		cfg.Window = defaultWindow
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultThreshold
+	}
+	if cfg.Warmup <= 0 {
+		cfg.Warmup = defaultWarmup
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultCooldown
+	}
+	if cfg.MaxSubjects <= 0 {
+		cfg.MaxSubjects = defaultMaxSubjects
+	}
+	if cfg.MaxAlerts <= 0 {
+		cfg.MaxAlerts = defaultMaxAlerts
+	}
+
+	d := &Detector{
+		cfg:        cfg,
+		client:     &http.Client{Timeout: webhookTimeout},
+		log:        logger.New("anomaly"),
+		current:    make(map[string]*counters),
+		baselines:  make(map[string]*baseline, len(Features)),
+		lastAlerts: make(map[string]time.Time),
+	}
+	for _, f := range Features {
+		d.baselines[f] = &baseline{}
+	}
+	return d
+}
+
+// SetAlertHook sets a function called for every alert raised.
+func (d *Detector) SetAlertHook(fn func(Alert)) {
+	d.mu.Lock()
+	d.onAlert = fn
+	d.mu.Unlock()
+}
+
+// Fingerprint identifies the client software and setup behind a connection
+// from headers that stay the same across its IPs.
+func Fingerprint(h http.Header) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		h.Get("User-Agent"),
+		h.Get("Origin"),
+		h.Get("Accept-Language"),
+		h.Get("Sec-WebSocket-Extensions"),
+	}, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// IsBroadFilter reports whether f selects events by kind or time alone.
+func IsBroadFilter(f nostr.Filter) bool {
+	return len(f.IDs) == 0 && len(f.Authors) == 0 && len(f.Tags) == 0
+}
+
+// Request counts a REQ with broad broad filters for each subject.
+func (d *Detector) Request(subjects []string, broad int) {
+	d.count(subjects, func(c *counters) {
+		c.reqs++
+		c.broad += int64(broad)
+	})
+}
+
+// Churn counts a subscription closed or replaced by each subject.
+func (d *Detector) Churn(subjects []string) {
+	d.count(subjects, func(c *counters) { c.churn++ })
+}
+
+// Published counts an event published by each subject and whether it was
+// accepted.
+func (d *Detector) Published(subjects []string, accepted bool) {
+	d.count(subjects, func(c *counters) {
+		c.published++
+		if !accepted {
+			c.rejected++
+		}
+	})
+}
+
+// count applies fn to the counters of each subject. Subjects beyond
+// MaxSubjects in a window are not counted.
+func (d *Detector) count(subjects []string, fn func(*counters)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range subjects {
+		c := d.current[s]
+		if c == nil {
+			if len(d.current) >= d.cfg.MaxSubjects {
+				continue
+			}
+			c = &counters{}
+			d.current[s] = c
+		}
+		fn(c)
+	}
+}
+
+// Start closes a window every Window until ctx is canceled.
+func (d *Detector) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.cfg.Window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				d.closeWindow(now)
+			}
+		}
+	}()
+	d.log.Info("Anomaly detection started",
+		zap.Duration("window", d.cfg.Window),
+		zap.Float64("threshold", d.cfg.Threshold))
+}
+
+// closeWindow checks the samples of the window that ended at now against
+// the baselines, raises the alerts and starts a new window.
+func (d *Detector) closeWindow(now time.Time) {
+	d.mu.Lock()
+	window := d.current
+	d.current = make(map[string]*counters, len(window))
+
+	// Subjects are checked in a fixed order so alerts are reproducible
+	subjects := make([]string, 0, len(window))
+	for s := range window {
+		subjects = append(subjects, s)
+	}
+	sort.Strings(subjects)
+
+	var raised []Alert
+	for _, s := range subjects {
+		values := window[s].values()
+		for _, f := range Features {
+			v, ok := values[f]
+			if !ok {
+				continue
+			}
+			b := d.baselines[f]
+			if alert, ok := d.check(s, f, v, b, now); ok {
+				raised = append(raised, alert)
+				continue // outliers are kept out of the baseline
+			}
+			b.add(v)
+		}
+	}
+
+	for key, at := range d.lastAlerts {
+		if now.Sub(at) > d.cfg.Cooldown {
+			delete(d.lastAlerts, key)
+		}
+	}
+	d.alerts = append(d.alerts, raised...)
+	if excess := len(d.alerts) - d.cfg.MaxAlerts; excess > 0 {
+		d.alerts = append([]Alert(nil), d.alerts[excess:]...)
+	}
+	onAlert := d.onAlert
+	d.mu.Unlock()
+
+	for _, alert := range raised {
+		if onAlert != nil {
+			onAlert(alert)
+		}
+		if d.cfg.WebhookURL != "" {
+			go d.notify(alert)
+		}
+	}
+}
+
+// check returns the alert raised by value v of feature f for subject s, if
+// the baseline is warm, v exceeds it by Threshold standard deviations and the
+// subject is not cooling down. Must be called with mu held.
+func (d *Detector) check(s, f string, v float64, b *baseline, now time.Time) (Alert, bool) {
+	if b.samples < int64(d.cfg.Warmup) || v < minValues[f] {
+		return Alert{}, false
+	}
+	// A floor on the spread keeps a perfectly uniform baseline from
+	// flagging every small step above it
+	stddev := math.Max(math.Sqrt(b.variance), math.Max(b.mean*0.1, 1e-3))
+	deviation := (v - b.mean) / stddev
+	if deviation < d.cfg.Threshold {
+		return Alert{}, false
+	}
+	key := s + "\n" + f
+	if at, ok := d.lastAlerts[key]; ok && now.Sub(at) < d.cfg.Cooldown {
+		return Alert{}, false
+	}
+	d.lastAlerts[key] = now
+	return Alert{Subject: s, Feature: f, Value: v, Baseline: b.mean, Deviation: deviation, At: now}, true
+}
+
+// notify POSTs an alert to the webhook.
+func (d *Detector) notify(alert Alert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		d.log.Warn("Failed to build anomaly alert request", zap.Error(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("webhook answered %s", resp.Status)
+		}
+	}
+	if err != nil {
+		d.log.Warn("Failed to deliver anomaly alert",
+			zap.String("subject", alert.Subject),
+			zap.String("feature", alert.Feature),
+			zap.Error(err))
+	}
+}
+
+// Alerts returns up to limit alerts raised since since, most recent first.
+func (d *Detector) Alerts(limit int, since time.Time) []Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var alerts []Alert
+	for i := len(d.alerts) - 1; i >= 0 && len(alerts) < limit; i-- {
+		if d.alerts[i].At.Before(since) {
+			break
+		}
+		alerts = append(alerts, d.alerts[i])
+	}
+	return alerts
+}
+
+// Baselines returns the current baseline of every feature.
+func (d *Detector) Baselines() map[string]Baseline {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[string]Baseline, len(d.baselines))
+	for f, b := range d.baselines {
+		out[f] = Baseline{Mean: b.mean, StdDev: math.Sqrt(b.variance), Samples: b.samples}
+	}
+	return out
+}
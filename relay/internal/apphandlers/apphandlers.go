@@ -0,0 +1,311 @@
+// Package apphandlers indexes the NIP-89 application handlers stored on the
+// relay: the latest handler information (kind 31990) an application
+// publishes for the kinds it supports, and the latest recommendations (kind
+// 31989) users publish for a kind. A kind's handlers are ranked by how many
+// distinct users recommend them, so a client that meets an event of a kind
+// it does not know can pick an application to open it with.
+package apphandlers
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/index"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-89 event kinds.
+const (
+	KindRecommendation = 31989
+	KindHandler        = 31990
+)
+
+const defaultMaxEntries = 100000
+
+// bech32Placeholder marks where a link template takes the NIP-19 entity.
+const bech32Placeholder = "<bech32>"
+
+// Link is a platform tag of a handler: a URL template in which <bech32> is
+// replaced by the NIP-19 entity of the event to open, optionally for one
+// entity type only.
+type Link struct {
+	Platform string `json:"platform"` // web, ios, android, ...
+	URL      string `json:"url"`
+	Entity   string `json:"entity,omitempty"` // nevent, naddr, nprofile, ...
+}
+
+// Handler is the latest handler information of an application.
+type Handler struct {
+	Address    string `json:"address"` // "31990:<pubkey>:<d>"
+	Pubkey     string `json:"pubkey"`
+	Identifier string `json:"identifier"`
+	Kinds      []int  `json:"kinds"`
+	Name       string `json:"name,omitempty"`
+	Picture    string `json:"picture,omitempty"`
+	About      string `json:"about,omitempty"`
+	Website    string `json:"website,omitempty"`
+	Links      []Link `json:"links"`
+	// Recommenders counts the distinct users recommending the handler for
+	// the queried kind.
+	Recommenders int          `json:"recommenders"`
+	Event        *nostr.Event `json:"event"`
+}
+
+// handlerMetadata is the optional kind 0 style content of a handler.
+type handlerMetadata struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Picture     string `json:"picture"`
+	About       string `json:"about"`
+	Website     string `json:"website"`
+}
+
+// ParseHandler reads a handler information event. Kinds come from its k
+// tags; the name, picture, about and website from its content, if any.
+func ParseHandler(evt *nostr.Event) *Handler {
+	h := &Handler{
+		Address:    strconv.Itoa(KindHandler) + ":" + evt.PubKey + ":" + evt.Tags.GetD(),
+		Pubkey:     evt.PubKey,
+		Identifier: evt.Tags.GetD(),
+		Kinds:      []int{},
+		Links:      []Link{},
+		Event:      evt,
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "k":
+			if kind, err := strconv.Atoi(tag[1]); err == nil && kind >= 0 && !slices.Contains(h.Kinds, kind) {
+				h.Kinds = append(h.Kinds, kind)
+			}
+		default:
+			// Any other tag carrying a URL template is a platform link
+			if !strings.Contains(tag[1], bech32Placeholder) {
+				continue
+			}
+			link := Link{Platform: tag[0], URL: tag[1]}
+			if len(tag) >= 3 {
+				link.Entity = tag[2]
+			}
+			h.Links = append(h.Links, link)
+		}
+	}
+	var meta handlerMetadata
+	if evt.Content != "" && json.Unmarshal([]byte(evt.Content), &meta) == nil {
+		h.Name = meta.Name
+		if h.Name == "" {
+			h.Name = meta.DisplayName
+		}
+		h.Picture = meta.Picture
+		h.About = meta.About
+		h.Website = meta.Website
+	}
+	return h
+}
+
+// recommendation is the latest recommendation of a user for a kind.
+type recommendation struct {
+	author   string
+	kind     int
+	handlers []string // handler addresses
+	event    *nostr.Event
+}
+
+// parseRecommendation reads a recommendation event; its d tag is the kind
+// and its a tags point at the recommended handlers. It returns nil when the
+// d tag is not a kind.
+func parseRecommendation(evt *nostr.Event) *recommendation {
+	kind, err := strconv.Atoi(evt.Tags.GetD())
+	if err != nil || kind < 0 {
+		return nil
+	}
+	rec := &recommendation{author: evt.PubKey, kind: kind, event: evt}
+	prefix := strconv.Itoa(KindHandler) + ":"
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "a" && strings.HasPrefix(tag[1], prefix) && !slices.Contains(rec.handlers, tag[1]) {
+			rec.handlers = append(rec.handlers, tag[1])
+		}
+	}
+	return rec
+}
+
+// Index holds the latest handlers and recommendations, by address.
+type Index struct {
+	cfg config.AppHandlersConfig
+
+	mu              sync.RWMutex
+	handlers        map[string]*Handler        // address -> handler
+	recommendations map[string]*recommendation // "<author>:<kind>" -> recommendation
+}
+
+// New creates an empty index.
+func New(cfg config.AppHandlersConfig) *Index {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultMaxEntries
+	}
+	return &Index{
+		cfg:             cfg,
+		handlers:        make(map[string]*Handler),
+		recommendations: make(map[string]*recommendation),
+	}
+}
+
+// Add indexes a handler or recommendation unless a newer version of it is
+// indexed already. It reports false when the event is not indexed: it is
+// older, malformed, or the index is full.
+func (ix *Index) Add(evt *nostr.Event) bool {
+	switch evt.Kind {
+	case KindHandler:
+		handler := ParseHandler(evt)
+
+		ix.mu.Lock()
+		defer ix.mu.Unlock()
+		current, exists := ix.handlers[handler.Address]
+		if exists && !index.Newer(evt, current.Event) {
+			return false
+		}
+		if !exists && ix.full() {
+			return false
+		}
+		ix.handlers[handler.Address] = handler
+		return true
+	case KindRecommendation:
+		rec := parseRecommendation(evt)
+		if rec == nil {
+			return false
+		}
+		key := evt.PubKey + ":" + strconv.Itoa(rec.kind)
+
+		ix.mu.Lock()
+		defer ix.mu.Unlock()
+		current, exists := ix.recommendations[key]
+		if exists && !index.Newer(evt, current.event) {
+			return false
+		}
+		if !exists && ix.full() {
+			return false
+		}
+		ix.recommendations[key] = rec
+		return true
+	}
+	return false
+}
+
+// full reports whether the index holds MaxEntries entries. Callers hold mu.
+func (ix *Index) full() bool {
+	return len(ix.handlers)+len(ix.recommendations) >= ix.cfg.MaxEntries
+}
+
+// Remove drops the author's handlers and recommendations deleted by a
+// NIP-09 deletion: those whose event ID is in ids, and those whose address
+// is in addrs with a version no newer than the deletion.
+func (ix *Index) Remove(deletion *nostr.Event, ids, addrs []string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for address, h := range ix.handlers {
+		if index.Deletes(deletion, ids, addrs, h.Event, address) {
+			delete(ix.handlers, address)
+		}
+	}
+	for key, rec := range ix.recommendations {
+		if index.Deletes(deletion, ids, addrs, rec.event, strconv.Itoa(KindRecommendation)+":"+key) {
+			delete(ix.recommendations, key)
+		}
+	}
+}
+
+// RemoveAuthor drops every handler and recommendation of a pubkey, after a
+// NIP-62 vanish request.
+func (ix *Index) RemoveAuthor(author string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for address, h := range ix.handlers {
+		if h.Pubkey == author {
+			delete(ix.handlers, address)
+		}
+	}
+	for key, rec := range ix.recommendations {
+		if rec.author == author {
+			delete(ix.recommendations, key)
+		}
+	}
+}
+
+// Observe keeps the index in step with a newly stored event.
+func (ix *Index) Observe(evt *nostr.Event) {
+	index.Observe(ix, evt, KindHandler, KindRecommendation)
+}
+
+// Query selects the handlers of a kind. Platform, when set, keeps only the
+// handlers with a link for that platform.
+type Query struct {
+	Kind     int
+	Platform string
+	Limit    int
+}
+
+// ForKind returns up to q.Limit handlers that support q.Kind, with the
+// number of distinct users recommending each for it, the most recommended
+// first. Recommendations of handlers that do not list the kind in their k
+// tags are not counted.
+func (ix *Index) ForKind(q Query) []Handler {
+	ix.mu.RLock()
+	recommenders := make(map[string]int)
+	for _, rec := range ix.recommendations {
+		if rec.kind != q.Kind {
+			continue
+		}
+		for _, address := range rec.handlers {
+			recommenders[address]++
+		}
+	}
+	var found []Handler
+	for address, h := range ix.handlers {
+		if !slices.Contains(h.Kinds, q.Kind) || (q.Platform != "" && !h.hasPlatform(q.Platform)) {
+			continue
+		}
+		handler := *h
+		handler.Recommenders = recommenders[address]
+		found = append(found, handler)
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.Recommenders != b.Recommenders {
+			return a.Recommenders > b.Recommenders
+		}
+		if a.Event.CreatedAt != b.Event.CreatedAt {
+			return a.Event.CreatedAt > b.Event.CreatedAt
+		}
+		return a.Address < b.Address
+	})
+	if len(found) > q.Limit {
+		found = found[:q.Limit]
+	}
+	return found
+}
+
+// hasPlatform reports whether the handler has a link for platform.
+func (h *Handler) hasPlatform(platform string) bool {
+	for _, link := range h.Links {
+		if link.Platform == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of indexed handlers and recommendations.
+func (ix *Index) Len() (handlers, recommendations int) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.handlers), len(ix.recommendations)
+}
@@ -10,10 +10,14 @@ import (
 	"github.com/Shugur-Network/relay/internal/config"
 	"github.com/Shugur-Network/relay/internal/constants"
 	"github.com/Shugur-Network/relay/internal/domain"
+	"github.com/Shugur-Network/relay/internal/grpcapi"
+	"github.com/Shugur-Network/relay/internal/identity"
 	"github.com/Shugur-Network/relay/internal/limiter"
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
 	"github.com/Shugur-Network/relay/internal/relay"
 	"github.com/Shugur-Network/relay/internal/storage"
+	"github.com/Shugur-Network/relay/internal/tracing"
 	"github.com/Shugur-Network/relay/internal/workers"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
@@ -27,6 +31,7 @@ type Node struct {
 	db              *storage.DB
 	config          *config.Config
 	WorkerPool      *workers.WorkerPool
+	SigVerifier     *workers.SignatureVerifier
 	EventProcessor  *storage.EventProcessor
 	EventDispatcher *storage.EventDispatcher
 	Validator       domain.EventValidator
@@ -87,9 +92,34 @@ func (n *Node) Start(ctx context.Context) error {
 		return err
 	}
 
+	// Serve Prometheus metrics on their own listener, labeled with this node's ID
+	nodeID := ""
+	if id, err := identity.GetOrCreateRelayIdentityWithConfig(n.config.Relay.PublicKey); err == nil {
+		nodeID = id.RelayID
+	}
+	if err := metrics.StartServer(n.ctx, n.config.Metrics, nodeID); err != nil {
+		logger.Error("Failed to start metrics listener", zap.Error(err))
+		return err
+	}
+
+	// Serve the internal gRPC API for sidecar services on its own listener
+	if err := grpcapi.StartServer(n.ctx, n.config, n); err != nil {
+		logger.Error("Failed to start gRPC listener", zap.Error(err))
+		return err
+	}
+
+	// Export spans for client commands to the configured OTLP collector
+	if err := tracing.Init(n.ctx, n.config.Tracing); err != nil {
+		logger.Error("Failed to start tracing", zap.Error(err))
+		return err
+	}
+
 	// Start the relay server (now includes web dashboard)
 	go func() {
 		addr := n.config.Relay.WSAddr
+		if n.config.TLS.Enabled {
+			addr = n.config.TLS.Addr
+		}
 		server := relay.NewServer(n.config.Relay, n, n.config)
 		if err := server.ListenAndServe(n.ctx, addr); err != nil {
 			// Don't log "Server closed" as an error - it's expected during graceful shutdown
@@ -133,6 +163,11 @@ func (n *Node) Shutdown() {
 		logger.Debug("✅ Event processor stopped")
 	}
 
+	// Stop the signature verifiers; no connection is left to submit events
+	if n.SigVerifier != nil {
+		n.SigVerifier.Stop()
+	}
+
 	// Step 4: Wait for all WorkerPool tasks to finish with timeout
 	logger.Debug("Waiting for worker pool to finish...")
 	done := make(chan struct{})
@@ -149,6 +184,9 @@ func (n *Node) Shutdown() {
 		logger.Warn("Worker pool shutdown timed out", zap.Duration("timeout", shutdownTimeout))
 	}
 
+	// Flush spans recorded while draining
+	tracing.Shutdown(shutdownCtx)
+
 	// Step 5: Cancel the node context
 	if n.cancel != nil {
 		logger.Debug("Canceling node context...")
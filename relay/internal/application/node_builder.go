@@ -33,6 +33,7 @@ type NodeBuilder struct {
 	database        *storage.DB
 	eventDispatcher *storage.EventDispatcher
 	workerPool      *workers.WorkerPool
+	sigVerifier     *workers.SignatureVerifier
 	validator       domain.EventValidator
 	eventVal        *relay.EventValidator
 	eventProc       *storage.EventProcessor
@@ -208,8 +209,21 @@ func replaceDBNameInURL(connURL string, newDB string) string {
 	return connURL[:schemeEnd+3+slashIdx+1] + newDB + afterSlash[qIdx:]
 }
 
-// BuildDB initializes the database connection with support for standalone, distributed, and cloud modes.
+// BuildDB initializes the database connection with support for standalone, distributed, and cloud modes,
+// or opens the embedded SQLite database when DATABASE.DRIVER is "sqlite".
 func (b *NodeBuilder) BuildDB() error {
+	if b.config.Database.Driver == storage.DriverSQLite {
+		logger.Info("Building database connection (embedded SQLite)",
+			zap.String("path", b.config.Database.SQLitePath))
+		dbConn, err := storage.InitSQLite(b.ctx, b.config.Database.SQLitePath)
+		if err != nil {
+			b.cancel()
+			return fmt.Errorf("failed to open SQLite database: %w", err)
+		}
+		b.database = dbConn
+		return b.finishDB()
+	}
+
 	const (
 		caPath    = "./certs/ca.crt"
 		relayCert = "./certs/client.relay.crt"
@@ -282,7 +296,7 @@ func (b *NodeBuilder) BuildDB() error {
 	// Optionally connect to default DB to create the target DB (only when defaultDbURI is set).
 	if defaultDbURI != "" {
 		logger.Info("Connecting to default database to check/create target database...")
-		defaultConn, err := storage.InitDB(b.ctx, defaultDbURI, b.config.Relay.ThrottlingConfig.MaxConnections)
+		defaultConn, err := storage.InitDB(b.ctx, defaultDbURI, b.config.Relay.ThrottlingConfig.MaxConnections, b.config.Database.Pool)
 		if err != nil {
 			logger.Warn("Root connection to default database failed; skipping create step (assuming provisioned).", zap.Error(err))
 		} else {
@@ -298,7 +312,7 @@ func (b *NodeBuilder) BuildDB() error {
 	// Connect to the target database
 	logger.Info("Connecting to target database...",
 		zap.String("db", dbName))
-	dbConn, err := storage.InitDB(b.ctx, targetDbURI, b.config.Relay.ThrottlingConfig.MaxConnections)
+	dbConn, err := storage.InitDB(b.ctx, targetDbURI, b.config.Relay.ThrottlingConfig.MaxConnections, b.config.Database.Pool)
 	if err != nil {
 		b.cancel()
 		return fmt.Errorf("failed to initialize database connection to %s: %w", dbName, err)
@@ -317,6 +331,28 @@ func (b *NodeBuilder) BuildDB() error {
 		return fmt.Errorf("database schema verification failed: %w", err)
 	}
 
+	// Route REQ and COUNT queries to a read replica or follower reads
+	if rr := b.config.Database.ReadReplica; rr.URL != "" || rr.FollowerReads {
+		readDbURI := targetDbURI
+		if rr.URL != "" {
+			readDbURI = replaceDBNameInURL(rr.URL, dbName)
+		}
+		if err := dbConn.OpenReadPool(b.ctx, readDbURI, b.config.Relay.ThrottlingConfig.MaxConnections, b.config.Database.Pool, rr.FollowerReads); err != nil {
+			// Queries keep running on the primary
+			logger.Warn("Read replica disabled", zap.Error(err))
+		}
+	}
+
+	return b.finishDB()
+}
+
+// finishDB sets up the driver-independent storage layers once the database is open.
+func (b *NodeBuilder) finishDB() error {
+	dbConn := b.database
+
+	// Back off and trip the circuit breaker on failing storage operations
+	dbConn.SetRetryPolicy(b.config.Database.Retry)
+
 	// Initialize EventsStored metric with current count
 	if count, err := dbConn.GetTotalEventCount(b.ctx); err != nil {
 		logger.Warn("Failed to get initial event count for metrics", zap.Error(err))
@@ -325,12 +361,61 @@ func (b *NodeBuilder) BuildDB() error {
 		logger.Info("Initialized EventsStored metric", zap.Int64("count", count))
 	}
 
-	if err := b.database.RebuildBloomFilter(b.ctx); err != nil {
-		logger.Warn("Failed to rebuild bloom filter", zap.Error(err))
+	// Restore the Bloom filter from disk, or rebuild it in the background
+	bloomCfg := b.config.Database.Bloom
+	singleWriter := bloomCfg.SingleWriter && !b.config.Database.ClusterDispatch.Enabled
+	b.database.LoadOrRebuildBloom(b.ctx, bloomCfg.Path, bloomCfg.Shards, singleWriter)
+	b.database.StartBloomPersistence(b.ctx, bloomCfg.Path, bloomCfg.SaveInterval)
+
+	// Enable the COUNT/REQ result cache
+	if qc := b.config.Database.QueryCache; qc.Enabled {
+		b.database.SetQueryCache(storage.NewQueryCache(qc.TTL, qc.MaxEntries, qc.MaxLimit))
+		logger.Info("Query result cache enabled",
+			zap.Duration("ttl", qc.TTL),
+			zap.Int("max_entries", qc.MaxEntries),
+			zap.Int("max_limit", qc.MaxLimit))
+	}
+
+	// Shed REQ and COUNT load while the pool is saturated
+	if ac := b.config.Database.Admission; ac.MaxWait > 0 {
+		b.database.StartAdmissionControl(b.ctx, ac.MaxWait, ac.Interval)
+	}
+
+	// Track NIP-90 DVM jobs
+	if dc := b.config.DVM; dc.Enabled {
+		b.database.SetDVMTracker(storage.NewDVMTracker(dc.MaxJobs, dc.Retention))
+	}
+
+	// Aggregate NIP-56 reports into the moderation queue
+	if rc := b.config.Reports; rc.Enabled {
+		rt := storage.NewReportTracker(rc)
+		b.database.SetReportTracker(rt)
+		go rt.Preload(b.ctx, b.database)
+	}
+
+	// Open the recent-events hot store and fill it from SQL in the background
+	if hc := b.config.Database.HotStore; hc.Enabled {
+		hs, err := storage.OpenHotStore(hc.Path, hc.Window)
+		if err != nil {
+			logger.Warn("Hot store disabled", zap.Error(err))
+		} else {
+			b.database.SetHotStore(hs)
+			go hs.Backfill(b.ctx, b.database)
+			hs.StartGC(b.ctx, hc.GCInterval)
+			logger.Info("Hot store enabled",
+				zap.String("path", hc.Path),
+				zap.Duration("window", hc.Window))
+		}
 	}
 
 	// Initialize event dispatcher for real-time notifications
 	b.eventDispatcher = storage.NewEventDispatcher(b.database)
+	if cd := b.config.Database.ClusterDispatch; cd.Enabled {
+		if err := b.eventDispatcher.EnableClusterDispatch(b.ctx, cd); err != nil {
+			return fmt.Errorf("failed to enable cluster dispatch: %w", err)
+		}
+		logger.Info("Cluster event dispatch enabled", zap.String("channel", cd.Channel))
+	}
 
 	// Set the event dispatcher reference in the database for immediate local broadcasting
 	b.database.SetEventDispatcher(b.eventDispatcher)
@@ -346,16 +431,24 @@ func (b *NodeBuilder) BuildWorkers() {
 	b.workerPool = workers.NewWorkerPool(numCPU*2, numCPU*300)
 }
 
-// BuildValidators configures the validation logic.
+// BuildValidators configures the validation logic. Both validators check
+// signatures on one shared verification pool.
 func (b *NodeBuilder) BuildValidators() {
-	b.validator = relay.NewPluginValidator(b.config, b.database)
-	b.eventVal = relay.NewEventValidator(b.config, b.database)
+	sv := b.config.Relay.SigVerify
+	b.sigVerifier = workers.NewSignatureVerifier(sv.Workers, sv.QueueSize)
+	b.validator = relay.NewPluginValidator(b.config, b.database, b.sigVerifier)
+	b.eventVal = relay.NewEventValidator(b.config, b.database, b.sigVerifier)
 }
 
 // BuildProcessor sets up the event processor.
 func (b *NodeBuilder) BuildProcessor() {
 	// 100000 is the buffer size from your original code
 	b.eventProc = storage.NewEventProcessor(b.ctx, b.database, 100000)
+
+	// Group concurrent inserts into shared transactions
+	if ib := b.config.Database.InsertBatch; ib.Enabled {
+		b.eventProc.EnableInsertBatching(ib.MaxSize, ib.MaxDelay)
+	}
 }
 
 // BuildRateLimiter sets up the rate limiter.
@@ -418,6 +511,7 @@ func (b *NodeBuilder) Build() (*Node, error) {
 		Validator:       b.validator,
 		EventValidator:  b.eventVal,
 		WorkerPool:      b.workerPool,
+		SigVerifier:     b.sigVerifier,
 		wsConns:         make(map[domain.WebSocketConnection]bool),
 		rateLimiter:     b.rateLimiter,
 
@@ -428,5 +522,6 @@ func (b *NodeBuilder) Build() (*Node, error) {
 
 	logger.Debug("Node initialized successfully via builder")
 	b.database.StartExpiredEventsCleaner(b.ctx, time.Hour)
+	b.database.StartRetentionPruner(b.ctx, b.config.RelayPolicy.Retention.MaxAge, time.Hour)
 	return node, nil
 }
@@ -0,0 +1,154 @@
+// Package audit keeps an append-only log of administrative and moderation
+// actions. Entries are written as JSON lines and never rewritten, so the file
+// can be shipped to external storage or inspected with standard tools.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outcomes recorded for an action.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// Actor used for actions the relay takes on its own (automatic bans).
+const ActorSystem = "system"
+
+// maxLineSize bounds a single entry when reading the log back.
+const maxLineSize = 1 << 20
+
+// Entry is one audited action.
+type Entry struct {
+	ID       int64     `json:"id"`
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor"`            // pubkey, or "system"
+	Source   string    `json:"source"`           // nip86, nip29, ratelimit, ...
+	Action   string    `json:"action"`           // method name or event kind
+	Params   []string  `json:"params,omitempty"` // method parameters or event tags
+	Outcome  string    `json:"outcome"`
+	Message  string    `json:"message,omitempty"` // error or rejection reason
+	ClientIP string    `json:"client_ip,omitempty"`
+}
+
+// Log appends entries to a JSON lines file.
+type Log struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	lastID int64
+}
+
+// Open opens (or creates) the audit log at path and resumes its ID sequence.
+func Open(path string) (*Log, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+		}
+	}
+
+	l := &Log{path: path}
+	if err := l.scan(func(e Entry) { l.lastID = e.ID }); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = f
+	return l, nil
+}
+
+// Record appends an entry, assigning its ID and timestamp.
+func (l *Log) Record(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastID++
+	e.ID = l.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// List returns up to limit entries with an ID below before (0 = newest),
+// newest first, and the cursor for the next page (0 when there are no more).
+func (l *Log) List(limit int, before int64) ([]Entry, int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Keep the newest `limit` matching entries in a ring while scanning
+	ring := make([]Entry, 0, limit)
+	next := 0
+	older := false
+	err := l.scan(func(e Entry) {
+		if before > 0 && e.ID >= before {
+			return
+		}
+		if len(ring) < limit {
+			ring = append(ring, e)
+			return
+		}
+		older = true
+		ring[next] = e
+		next = (next + 1) % limit
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]Entry, 0, len(ring))
+	for i := len(ring) - 1; i >= 0; i-- {
+		entries = append(entries, ring[(next+i)%len(ring)])
+	}
+	var cursor int64
+	if older && len(entries) > 0 {
+		cursor = entries[len(entries)-1].ID
+	}
+	return entries, cursor, nil
+}
+
+// Close closes the underlying file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// scan calls fn for every readable entry in file order. Corrupt lines (e.g. a
+// partial write before a crash) are skipped rather than failing the read.
+func (l *Log) scan(fn func(Entry)) error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			fn(e)
+		}
+	}
+	return scanner.Err()
+}
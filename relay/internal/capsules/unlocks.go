@@ -0,0 +1,244 @@
+// Package capsules schedules the unlocks of NIP-XX time capsules (kind
+// 1041). A capsule is time-lock encrypted to a drand round; it becomes
+// decryptable once the drand network publishes the randomness of that round.
+// The schedule converts the round of each stored capsule to a wall-clock
+// time from the known drand chains, hands out the capsules as they become
+// due and lists the upcoming unlocks addressed to a pubkey.
+package capsules
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Chain is the timing of a drand network: round 1 is published at Genesis
+// and every Period after that.
+type Chain struct {
+	Name    string
+	Genesis time.Time
+	Period  time.Duration
+}
+
+// Chains are the drand networks whose round times are known, by chain hash.
+var Chains = map[string]Chain{
+	// League of Entropy quicknet, the chain tlock encrypts to by default
+	"52db9ba70e0cc0f6eaf7803dd07447a1f5477735fd3f661792ba94600c84e971": {
+		Name: "quicknet", Genesis: time.Unix(1692803367, 0), Period: 3 * time.Second,
+	},
+	// League of Entropy mainnet (default)
+	"8990e7a9aaed2ffed73dbd7092123d6f289930540d7651336225dc172e51b2ce": {
+		Name: "mainnet", Genesis: time.Unix(1595431050, 0), Period: 30 * time.Second,
+	},
+}
+
+// RoundTime returns when round of the chain is published, or false when the
+// chain is unknown.
+func RoundTime(chainHash string, round int64) (time.Time, bool) {
+	chain, ok := Chains[chainHash]
+	if !ok || round < 1 {
+		return time.Time{}, false
+	}
+	// Rounds far in the future would overflow a Duration
+	if round-1 > int64(1<<62)/int64(chain.Period) {
+		return time.Time{}, false
+	}
+	return chain.Genesis.Add(time.Duration(round-1) * chain.Period), true
+}
+
+// Unlock is a scheduled capsule unlock.
+type Unlock struct {
+	EventID    string    `json:"event_id"`
+	Author     string    `json:"author"`
+	Recipients []string  `json:"recipients"`
+	Chain      string    `json:"drand_chain"`
+	Round      int64     `json:"drand_round"`
+	At         time.Time `json:"unlock_at"`
+
+	index int // position in the heap
+}
+
+// Schedule holds the pending unlocks, soonest first.
+type Schedule struct {
+	max int
+
+	mu    sync.Mutex
+	queue unlockQueue
+	byID  map[string]*Unlock
+}
+
+// New creates an empty schedule holding at most max unlocks (0 = no cap).
+func New(max int) *Schedule {
+	return &Schedule{max: max, byID: make(map[string]*Unlock)}
+}
+
+// Add schedules the unlock of a capsule. It reports false, scheduling
+// nothing, when the event is not a capsule, its chain is unknown, it is
+// already unlocked at now or already scheduled, or the schedule is full.
+func (s *Schedule) Add(evt *nostr.Event, now time.Time) bool {
+	if evt.Kind != constants.KindTimeCapsule {
+		return false
+	}
+	chainHash, round, err := nips.ExtractDrandParameters(evt)
+	if err != nil {
+		return false
+	}
+	at, ok := RoundTime(chainHash, round)
+	if !ok || !at.After(now) {
+		return false
+	}
+	var recipients []string
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == constants.TagP && nostr.IsValidPublicKey(tag[1]) {
+			recipients = append(recipients, tag[1])
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, scheduled := s.byID[evt.ID]; scheduled {
+		return false
+	}
+	if s.max > 0 && len(s.queue) >= s.max {
+		return false
+	}
+	u := &Unlock{
+		EventID:    evt.ID,
+		Author:     evt.PubKey,
+		Recipients: recipients,
+		Chain:      chainHash,
+		Round:      round,
+		At:         at,
+	}
+	heap.Push(&s.queue, u)
+	s.byID[u.EventID] = u
+	return true
+}
+
+// Remove unschedules the author's capsules with the given IDs, after a
+// NIP-09 deletion.
+func (s *Schedule) Remove(author string, ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if u, ok := s.byID[id]; ok && u.Author == author {
+			s.removeLocked(u)
+		}
+	}
+}
+
+// RemoveAuthor unschedules every capsule of a pubkey, after a NIP-62 vanish request.
+func (s *Schedule) RemoveAuthor(author string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.byID {
+		if u.Author == author {
+			s.removeLocked(u)
+		}
+	}
+}
+
+// Observe keeps the schedule in step with a newly stored event.
+func (s *Schedule) Observe(evt *nostr.Event) {
+	switch evt.Kind {
+	case constants.KindTimeCapsule:
+		s.Add(evt, time.Now())
+	case 5:
+		var ids []string
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "e" {
+				ids = append(ids, tag[1])
+			}
+		}
+		if len(ids) > 0 {
+			s.Remove(evt.PubKey, ids)
+		}
+	case 62:
+		s.RemoveAuthor(evt.PubKey)
+	}
+}
+
+// Due unschedules and returns the unlocks due at now, soonest first.
+func (s *Schedule) Due(now time.Time) []Unlock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []Unlock
+	for len(s.queue) > 0 && !s.queue[0].At.After(now) {
+		u := heap.Pop(&s.queue).(*Unlock)
+		delete(s.byID, u.EventID)
+		due = append(due, *u)
+	}
+	return due
+}
+
+// Upcoming returns up to limit pending unlocks of capsules addressed to
+// pubkey, soonest first.
+func (s *Schedule) Upcoming(pubkey string, limit int) []Unlock {
+	s.mu.Lock()
+	var found []Unlock
+	for _, u := range s.queue {
+		for _, recipient := range u.Recipients {
+			if recipient == pubkey {
+				found = append(found, *u)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].At.Equal(found[j].At) {
+			return found[i].At.Before(found[j].At)
+		}
+		return found[i].EventID < found[j].EventID
+	})
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
+
+// Len returns the number of pending unlocks.
+func (s *Schedule) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// removeLocked unschedules an unlock. Must be called with mu held.
+func (s *Schedule) removeLocked(u *Unlock) {
+	heap.Remove(&s.queue, u.index)
+	delete(s.byID, u.EventID)
+}
+
+// unlockQueue is a min-heap of unlocks by time.
+type unlockQueue []*Unlock
+
+func (q unlockQueue) Len() int { return len(q) }
+
+func (q unlockQueue) Less(i, j int) bool { return q[i].At.Before(q[j].At) }
+
+func (q unlockQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *unlockQueue) Push(x interface{}) {
+	u := x.(*Unlock)
+	u.index = len(*q)
+	*q = append(*q, u)
+}
+
+func (q *unlockQueue) Pop() interface{} {
+	old := *q
+	u := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return u
+}
@@ -0,0 +1,334 @@
+// Package classifieds indexes the NIP-99 classified listings (kind 30402)
+// stored on the relay by location. Only the latest version of each listing
+// is kept, with its free-text location tag and its geohash (g) tags, so
+// listings can be searched within a geohash cell or a radius of a point.
+// Listings past their maximum age, or sold for longer than the retention,
+// expire; the index returns them so the caller can delete them from storage.
+package classifieds
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/index"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// KindListing is the NIP-99 classified listing event kind.
+const KindListing = 30402
+
+// StatusSold is the status tag value of a listing that is no longer available.
+const StatusSold = "sold"
+
+const defaultMaxEntries = 100000
+
+// Price is the price tag of a listing: ["price", amount, currency, frequency].
+type Price struct {
+	Amount    string `json:"amount"`
+	Currency  string `json:"currency,omitempty"`
+	Frequency string `json:"frequency,omitempty"`
+}
+
+// Listing is the latest version of a classified listing.
+type Listing struct {
+	Author      string       `json:"author"`
+	ID          string       `json:"id"`
+	Title       string       `json:"title,omitempty"`
+	Summary     string       `json:"summary,omitempty"`
+	Price       *Price       `json:"price,omitempty"`
+	Location    string       `json:"location,omitempty"`
+	Geohash     string       `json:"geohash,omitempty"` // the most precise g tag
+	Status      string       `json:"status"`
+	PublishedAt int64        `json:"published_at"`
+	Categories  []string     `json:"categories"`
+	DistanceKm  *float64     `json:"distance_km,omitempty"` // set by radius searches
+	Event       *nostr.Event `json:"event"`
+
+	geohashes []string
+	lat, lon  float64
+	located   bool
+}
+
+// ParseListing reads a listing event. Listings without a status are active;
+// published_at defaults to created_at.
+func ParseListing(evt *nostr.Event) *Listing {
+	l := &Listing{
+		Author:      evt.PubKey,
+		ID:          evt.Tags.GetD(),
+		Status:      "active",
+		PublishedAt: int64(evt.CreatedAt),
+		Categories:  []string{},
+		Event:       evt,
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "title":
+			l.Title = tag[1]
+		case "summary":
+			l.Summary = tag[1]
+		case "location":
+			l.Location = tag[1]
+		case "status":
+			l.Status = strings.ToLower(tag[1])
+		case "published_at":
+			if ts, err := strconv.ParseInt(tag[1], 10, 64); err == nil && ts > 0 {
+				l.PublishedAt = ts
+			}
+		case "t":
+			l.Categories = append(l.Categories, strings.ToLower(tag[1]))
+		case "price":
+			l.Price = &Price{Amount: tag[1]}
+			if len(tag) >= 3 {
+				l.Price.Currency = strings.ToUpper(tag[2])
+			}
+			if len(tag) >= 4 {
+				l.Price.Frequency = tag[3]
+			}
+		case "g":
+			g := strings.ToLower(tag[1])
+			if !ValidGeohash(g) {
+				continue
+			}
+			l.geohashes = append(l.geohashes, g)
+			if len(g) > len(l.Geohash) {
+				l.Geohash = g
+			}
+		}
+	}
+	if l.Geohash != "" {
+		l.lat, l.lon, l.located = DecodeGeohash(l.Geohash)
+	}
+	return l
+}
+
+// inCell reports whether the listing has a geohash within the cell prefix.
+func (l *Listing) inCell(prefix string) bool {
+	for _, g := range l.geohashes {
+		if strings.HasPrefix(g, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Near is a circle to search in.
+type Near struct {
+	Lat, Lon float64
+	RadiusKm float64
+}
+
+// Query selects listings. Empty fields match every listing.
+type Query struct {
+	Geohash     string // geohash cell prefix
+	Near        *Near
+	Location    string // case-insensitive substring of the location tag
+	Category    string
+	IncludeSold bool
+	Limit       int
+}
+
+// geo reports whether the query selects by geohash or radius.
+func (q Query) geo() bool {
+	return q.Geohash != "" || q.Near != nil
+}
+
+// Index holds the latest listings, by address.
+type Index struct {
+	cfg config.ClassifiedsConfig
+
+	mu       sync.RWMutex
+	listings map[string]*Listing // "<author>:<d>" -> listing
+}
+
+// New creates an empty index.
+func New(cfg config.ClassifiedsConfig) *Index {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultMaxEntries
+	}
+	return &Index{cfg: cfg, listings: make(map[string]*Listing)}
+}
+
+// Add indexes a listing unless a newer version of it is indexed already.
+// It reports false when the listing is not indexed: it is older, or the
+// index is full.
+func (ix *Index) Add(evt *nostr.Event) bool {
+	if evt.Kind != KindListing {
+		return false
+	}
+	listing := ParseListing(evt)
+	key := evt.PubKey + ":" + listing.ID
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	current, exists := ix.listings[key]
+	if exists && !index.Newer(evt, current.Event) {
+		return false
+	}
+	if !exists && len(ix.listings) >= ix.cfg.MaxEntries {
+		return false
+	}
+	ix.listings[key] = listing
+	return true
+}
+
+// Remove drops the author's listings deleted by a NIP-09 deletion: those
+// whose event ID is in ids, and those whose address is in addrs
+// ("30402:<pubkey>:<d>") with a version no newer than the deletion.
+func (ix *Index) Remove(deletion *nostr.Event, ids, addrs []string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for key, l := range ix.listings {
+		if index.Deletes(deletion, ids, addrs, l.Event, strconv.Itoa(KindListing)+":"+key) {
+			delete(ix.listings, key)
+		}
+	}
+}
+
+// RemoveAuthor drops every listing of a pubkey, after a NIP-62 vanish request.
+func (ix *Index) RemoveAuthor(author string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for key, l := range ix.listings {
+		if l.Author == author {
+			delete(ix.listings, key)
+		}
+	}
+}
+
+// Observe keeps the index in step with a newly stored event.
+func (ix *Index) Observe(evt *nostr.Event) {
+	index.Observe(ix, evt, KindListing)
+}
+
+// Search returns up to q.Limit listings matching q: nearest first for a
+// radius search, newest first otherwise. Results of a radius search carry
+// their distance.
+func (ix *Index) Search(q Query) []*Listing {
+	location := strings.ToLower(q.Location)
+	category := strings.ToLower(q.Category)
+	now := time.Now()
+
+	ix.mu.RLock()
+	var found []*Listing
+	for _, l := range ix.listings {
+		switch {
+		case !q.IncludeSold && l.Status == StatusSold,
+			q.Geohash != "" && !l.inCell(q.Geohash),
+			q.Near != nil && !l.located,
+			location != "" && !strings.Contains(strings.ToLower(l.Location), location),
+			category != "" && !slices.Contains(l.Categories, category),
+			ix.expired(l, now):
+			continue
+		}
+		if q.Near != nil {
+			d := DistanceKm(q.Near.Lat, q.Near.Lon, l.lat, l.lon)
+			if d > q.Near.RadiusKm {
+				continue
+			}
+			match := *l
+			d = math.Round(d*1000) / 1000
+			match.DistanceKm = &d
+			found = append(found, &match)
+			continue
+		}
+		found = append(found, l)
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.DistanceKm != nil && *a.DistanceKm != *b.DistanceKm {
+			return *a.DistanceKm < *b.DistanceKm
+		}
+		if a.Event.CreatedAt != b.Event.CreatedAt {
+			return a.Event.CreatedAt > b.Event.CreatedAt
+		}
+		return a.Event.ID < b.Event.ID
+	})
+	if len(found) > q.Limit {
+		found = found[:q.Limit]
+	}
+	return found
+}
+
+// Geohashes returns up to q.Limit geohashes that select the listings
+// matching the geohash or radius of q with an exact #g tag filter: the most
+// precise geohash of each, plus the searched cell itself, so the result is
+// never empty.
+func (ix *Index) Geohashes(q Query) []string {
+	cell := q.Geohash
+	if q.Near != nil {
+		cell = EncodeGeohash(q.Near.Lat, q.Near.Lon, precisionFor(q.Near.RadiusKm))
+	}
+	values := []string{cell}
+	if !q.geo() {
+		return values
+	}
+	seen := map[string]bool{cell: true}
+	for _, l := range ix.Search(q) {
+		if g := l.Geohash; !seen[g] && len(values) < q.Limit {
+			seen[g] = true
+			values = append(values, g)
+		}
+	}
+	return values
+}
+
+// Expire drops and returns the listings published longer than MaxAge ago
+// (aged) and those sold longer than SoldRetention ago (sold). Listings past
+// their NIP-40 expiration are dropped too; the expired-events cleaner
+// deletes them from storage.
+func (ix *Index) Expire(now time.Time) (aged, sold []*nostr.Event) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for key, l := range ix.listings {
+		switch {
+		case ix.cfg.MaxAge > 0 && now.Sub(time.Unix(l.PublishedAt, 0)) > ix.cfg.MaxAge:
+			aged = append(aged, l.Event)
+		case ix.cfg.SoldRetention > 0 && l.Status == StatusSold && now.Sub(l.Event.CreatedAt.Time()) > ix.cfg.SoldRetention:
+			sold = append(sold, l.Event)
+		case nips.IsExpired(*l.Event):
+		default:
+			continue
+		}
+		delete(ix.listings, key)
+	}
+	return aged, sold
+}
+
+// Len returns the number of indexed listings.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.listings)
+}
+
+// expired reports whether a listing is past its age, retention or NIP-40
+// expiration but not yet dropped by Expire.
+func (ix *Index) expired(l *Listing, now time.Time) bool {
+	return (ix.cfg.MaxAge > 0 && now.Sub(time.Unix(l.PublishedAt, 0)) > ix.cfg.MaxAge) ||
+		nips.IsExpired(*l.Event)
+}
+
+// precisionFor returns the geohash precision whose cells are about the size
+// of a search radius.
+func precisionFor(radiusKm float64) int {
+	// Approximate cell widths of precisions 1 to 8
+	widths := []float64{5000, 1250, 156, 39, 4.9, 1.2, 0.15, 0.038}
+	for i, w := range widths {
+		if w <= radiusKm {
+			return i + 1
+		}
+	}
+	return len(widths)
+}
@@ -0,0 +1,104 @@
+package classifieds
+
+import (
+	"math"
+	"strings"
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+// ValidGeohash reports whether s is a geohash of 1 to 12 characters.
+func ValidGeohash(s string) bool {
+	if s == "" || len(s) > 12 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune(geohashAlphabet, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// DecodeGeohash returns the centre of a geohash cell. It reports false when
+// s is not a valid geohash.
+func DecodeGeohash(s string) (lat, lon float64, ok bool) {
+	s = strings.ToLower(s)
+	if !ValidGeohash(s) {
+		return 0, 0, false
+	}
+	latLo, latHi := -90.0, 90.0
+	lonLo, lonHi := -180.0, 180.0
+	even := true // bits alternate longitude, latitude
+	for _, c := range s {
+		bits := strings.IndexRune(geohashAlphabet, c)
+		for mask := 16; mask > 0; mask >>= 1 {
+			if even {
+				mid := (lonLo + lonHi) / 2
+				if bits&mask != 0 {
+					lonLo = mid
+				} else {
+					lonHi = mid
+				}
+			} else {
+				mid := (latLo + latHi) / 2
+				if bits&mask != 0 {
+					latLo = mid
+				} else {
+					latHi = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return (latLo + latHi) / 2, (lonLo + lonHi) / 2, true
+}
+
+// EncodeGeohash returns the geohash of a point at the given precision.
+func EncodeGeohash(lat, lon float64, precision int) string {
+	latLo, latHi := -90.0, 90.0
+	lonLo, lonHi := -180.0, 180.0
+	var b strings.Builder
+	even := true
+	bits, bit := 0, 0
+	for b.Len() < precision {
+		if even {
+			mid := (lonLo + lonHi) / 2
+			if lon >= mid {
+				bits = bits<<1 | 1
+				lonLo = mid
+			} else {
+				bits <<= 1
+				lonHi = mid
+			}
+		} else {
+			mid := (latLo + latHi) / 2
+			if lat >= mid {
+				bits = bits<<1 | 1
+				latLo = mid
+			} else {
+				bits <<= 1
+				latHi = mid
+			}
+		}
+		even = !even
+		if bit++; bit == 5 {
+			b.WriteByte(geohashAlphabet[bits])
+			bits, bit = 0, 0
+		}
+	}
+	return b.String()
+}
+
+// DistanceKm returns the great-circle distance between two points.
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,141 @@
+// Package clientip works out the address and scheme of the client behind a
+// request. Proxy headers (X-Real-IP, X-Forwarded-For, X-Forwarded-Proto) are
+// only honoured when the connection comes from a trusted proxy, so clients
+// connecting directly cannot spoof their address to get around bans and
+// per-IP limits, or the scheme NIP-98 auth events are checked against.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	trustedMu sync.RWMutex
+	trusted   []*net.IPNet
+)
+
+// SetTrustedProxies replaces the trusted proxy ranges. Entries are CIDR
+// ranges or single addresses; invalid entries are returned as an error after
+// the valid ones are applied.
+func SetTrustedProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	var invalid []string
+	for _, p := range proxies {
+		n, err := ParseCIDR(p)
+		if err != nil {
+			invalid = append(invalid, p)
+			continue
+		}
+		nets = append(nets, n)
+	}
+
+	trustedMu.Lock()
+	trusted = nets
+	trustedMu.Unlock()
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid trusted proxies: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// ParseCIDR parses a CIDR range, or a single address as its /32 or /128.
+func ParseCIDR(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid CIDR range %q", s)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR range %q", s)
+	}
+	return n, nil
+}
+
+// IsTrusted reports whether ip belongs to a trusted proxy.
+func IsTrusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	trustedMu.RLock()
+	defer trustedMu.RUnlock()
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromRequest returns the client IP of r. The peer address is used unless it
+// is a trusted proxy, in which case X-Real-IP is taken, or else the right-most
+// X-Forwarded-For entry that is not itself a trusted proxy.
+func FromRequest(r *http.Request) string {
+	peer := Normalize(r.RemoteAddr)
+	if !IsTrusted(net.ParseIP(peer)) {
+		return peer
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		if ip := net.ParseIP(realIP); ip != nil {
+			return Normalize(realIP)
+		}
+	}
+
+	// Walk the chain from the nearest hop; earlier entries are client supplied
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		if i == 0 || !IsTrusted(ip) {
+			return Normalize(ip.String())
+		}
+	}
+	return peer
+}
+
+// Scheme returns the scheme the client used: the first X-Forwarded-Proto
+// entry when the peer is a trusted proxy, and otherwise https over TLS.
+func Scheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" && IsTrusted(net.ParseIP(Normalize(r.RemoteAddr))) {
+		return strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Normalize converts a network address to a plain IP string, dropping the
+// port and unwrapping IPv4-mapped IPv6 addresses.
+func Normalize(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		// Not host:port, assume addr is already an IP
+		host = addr
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ipv4 := ip.To4(); ipv4 != nil {
+			return ipv4.String()
+		}
+		return ip.String()
+	}
+	return host
+}
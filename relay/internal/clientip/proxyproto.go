@@ -0,0 +1,184 @@
+package clientip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HAProxy PROXY protocol (v1 text and v2 binary) on a listener, for load
+// balancers that forward TCP rather than HTTP. Only connections from trusted
+// proxies are expected to start with a header; their remote address becomes
+// the client address the header carries.
+
+// v2Signature starts every PROXY protocol v2 header.
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// maxV1HeaderLen is the longest v1 header line, CRLF included.
+const maxV1HeaderLen = 107
+
+// proxyListener reads PROXY headers on connections from trusted proxies.
+type proxyListener struct {
+	net.Listener
+	timeout time.Duration
+}
+
+// NewProxyListener wraps l so connections from trusted proxies report the
+// client address of their PROXY protocol header. A header that does not
+// arrive within timeout fails the connection.
+func NewProxyListener(l net.Listener, timeout time.Duration) net.Listener {
+	return &proxyListener{Listener: l, timeout: timeout}
+}
+
+// Accept waits for the next connection. The header itself is read by the
+// connection's goroutine on first use, so a slow proxy cannot stall Accept.
+func (l *proxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !IsTrusted(net.ParseIP(Normalize(c.RemoteAddr().String()))) {
+		return c, nil
+	}
+	return &proxyConn{Conn: c, r: bufio.NewReader(c), timeout: l.timeout}, nil
+}
+
+// proxyConn is a connection whose first bytes are a PROXY header.
+type proxyConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	remote net.Addr // nil for LOCAL and UNKNOWN headers
+	err    error
+}
+
+func (c *proxyConn) readHeader() {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+		}
+		c.remote, c.err = readProxyHeader(c.r)
+		if c.err != nil {
+			c.err = fmt.Errorf("PROXY protocol from %s: %w", c.Conn.RemoteAddr(), c.err)
+		}
+	})
+}
+
+// Read reads past the PROXY header.
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr returns the client address of the PROXY header.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads a v1 or v2 header, returning the source address or
+// nil when the header carries none.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(v2Signature))
+	if err == nil && bytes.Equal(sig, v2Signature) {
+		return readV2Header(r)
+	}
+	prefix, err := r.Peek(6)
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if string(prefix) == "PROXY " {
+		return readV1Header(r)
+	}
+	return nil, errors.New("missing header")
+}
+
+// readV1Header reads "PROXY TCP4|TCP6|UNKNOWN src dst sport dport\r\n".
+func readV1Header(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < maxV1HeaderLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("reading v1 header: %w", err)
+		}
+		line = append(line, b)
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("v1 header too long")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errors.New("malformed v1 header")
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, errors.New("malformed v1 header address")
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2Header reads a binary v2 header.
+func readV2Header(r *bufio.Reader) (net.Addr, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("reading v2 header: %w", err)
+	}
+	if hdr[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported version %d", hdr[12]>>4)
+	}
+	command := hdr[12] & 0x0f
+	family := hdr[13]
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("reading v2 addresses: %w", err)
+	}
+
+	switch command {
+	case 0x0: // LOCAL: health checks from the proxy itself
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("unsupported command %d", command)
+	}
+
+	switch family {
+	case 0x11: // TCP over IPv4
+		if len(payload) < 12 {
+			return nil, errors.New("short v2 IPv4 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(payload) < 36 {
+			return nil, errors.New("short v2 IPv6 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		// UNSPEC and non-TCP families carry no usable client address
+		return nil, nil
+	}
+}
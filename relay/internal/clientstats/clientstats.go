@@ -0,0 +1,323 @@
+// Package clientstats counts connections, commands and published kinds per
+// client software, so operators can see which clients use the relay and which
+// NIPs are actually exercised. Clients are named after the first product token
+// of their User-Agent; browsers, which all send a generic Mozilla agent, are
+// named after the Origin of the web app instead. Counts are kept in memory and
+// periodically written to a JSON snapshot that is reloaded on start.
+package clientstats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	"go.uber.org/zap"
+)
+
+const (
+	// Unknown names connections without a User-Agent or Origin.
+	Unknown = "unknown"
+	// Other collects clients seen after MaxClients distinct ones.
+	Other = "other"
+
+	defaultMaxClients    = 200
+	defaultFlushInterval = 5 * time.Minute
+	// maxNameLength bounds client names taken from request headers.
+	maxNameLength = 64
+)
+
+// commandNIPs maps client commands to the NIP defining them.
+var commandNIPs = map[string]string{
+	"EVENT":     "01",
+	"REQ":       "01",
+	"CLOSE":     "01",
+	"AUTH":      "42",
+	"COUNT":     "45",
+	"NEG-OPEN":  "77",
+	"NEG-MSG":   "77",
+	"NEG-CLOSE": "77",
+}
+
+// Client holds the counts of one client software.
+type Client struct {
+	Name        string           `json:"name"`
+	Connections int64            `json:"connections"`
+	Commands    map[string]int64 `json:"commands"` // command -> times issued
+	Kinds       map[int]int64    `json:"kinds"`    // kind -> events published
+	FirstSeen   time.Time        `json:"first_seen"`
+	LastSeen    time.Time        `json:"last_seen"`
+}
+
+// Summary is a point-in-time view of the counts.
+type Summary struct {
+	Since       time.Time        `json:"since"` // when counting started
+	Connections int64            `json:"connections"`
+	Clients     []Client         `json:"clients"` // most connections first
+	NIPs        map[string]int64 `json:"nips"`    // NIP -> commands and events using it
+}
+
+// snapshot is the file format of the flushed counts.
+type snapshot struct {
+	Since   time.Time `json:"since"`
+	Clients []Client  `json:"clients"`
+}
+
+// Tracker counts client activity.
+type Tracker struct {
+	path          string
+	flushInterval time.Duration
+	maxClients    int
+
+	mu      sync.Mutex
+	since   time.Time
+	clients map[string]*Client
+	dirty   bool
+}
+
+// New creates a tracker from cfg and restores the counts of its snapshot
+// file, if any.
+func New(cfg config.ClientStatsConfig) *Tracker {
+	t := &Tracker{
+		path:          cfg.Path,
+		flushInterval: cfg.FlushInterval,
+		maxClients:    cfg.MaxClients,
+		since:         time.Now(),
+		clients:       make(map[string]*Client),
+	}
+	if t.flushInterval <= 0 {
+		t.flushInterval = defaultFlushInterval
+	}
+	if t.maxClients <= 0 {
+		t.maxClients = defaultMaxClients
+	}
+	if t.path != "" {
+		if err := t.load(); err != nil && !os.IsNotExist(err) {
+			logger.Warn("Failed to load client stats, starting empty",
+				zap.String("path", t.path), zap.Error(err))
+		}
+	}
+	return t
+}
+
+// ClientName derives the client name of a connection from its User-Agent and
+// Origin headers.
+func ClientName(userAgent, origin string) string {
+	userAgent = strings.TrimSpace(userAgent)
+	if userAgent == "" || strings.HasPrefix(userAgent, "Mozilla/") {
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			return truncate("web:" + strings.ToLower(u.Hostname()))
+		}
+		if userAgent == "" {
+			return Unknown
+		}
+		return "browser"
+	}
+	// First product token, without its version: "nostr-tools/2.1 (...)" -> "nostr-tools"
+	product := strings.Fields(userAgent)[0]
+	if i := strings.IndexByte(product, '/'); i > 0 {
+		product = product[:i]
+	}
+	return truncate(strings.ToLower(product))
+}
+
+// truncate bounds a client name taken from request headers.
+func truncate(name string) string {
+	if len(name) > maxNameLength {
+		return name[:maxNameLength]
+	}
+	return name
+}
+
+// Connect counts a new connection of the named client and returns the name
+// its activity is counted under.
+func (t *Tracker) Connect(name string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.client(name)
+	c.Connections++
+	return c.Name
+}
+
+// Command counts a command issued by the named client.
+func (t *Tracker) Command(name, command string) {
+	if _, ok := commandNIPs[command]; !ok {
+		return // unknown commands would let clients grow the map
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.client(name).Commands[command]++
+}
+
+// Published counts an event of the given kind published by the named client.
+func (t *Tracker) Published(name string, kind int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.client(name).Kinds[kind]++
+}
+
+// client returns the counts of name, creating them while fewer than
+// maxClients clients are tracked and falling back to Other afterwards.
+// Must be called with mu held.
+func (t *Tracker) client(name string) *Client {
+	now := time.Now()
+	t.dirty = true
+	c, ok := t.clients[name]
+	if !ok {
+		if len(t.clients) >= t.maxClients {
+			name = Other
+			c = t.clients[Other]
+		}
+		if c == nil {
+			c = &Client{
+				Name:      name,
+				Commands:  make(map[string]int64),
+				Kinds:     make(map[int]int64),
+				FirstSeen: now,
+			}
+			t.clients[name] = c
+		}
+	}
+	c.LastSeen = now
+	return c
+}
+
+// Summary returns the counts of every client, most connections first, and
+// the NIPs their commands and published kinds use.
+func (t *Tracker) Summary() Summary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := Summary{
+		Since:   t.since,
+		Clients: make([]Client, 0, len(t.clients)),
+		NIPs:    make(map[string]int64),
+	}
+	for _, c := range t.clients {
+		cp := *c
+		cp.Commands = make(map[string]int64, len(c.Commands))
+		for cmd, n := range c.Commands {
+			cp.Commands[cmd] = n
+			s.NIPs[commandNIPs[cmd]] += n
+		}
+		cp.Kinds = make(map[int]int64, len(c.Kinds))
+		for kind, n := range c.Kinds {
+			cp.Kinds[kind] = n
+			// NIP-01 kinds are already counted through the EVENT command
+			if nip := registry.ForKind(kind); nip != "" && nip != "01" {
+				s.NIPs[nip] += n
+			}
+		}
+		s.Connections += c.Connections
+		s.Clients = append(s.Clients, cp)
+	}
+	sort.Slice(s.Clients, func(i, j int) bool {
+		if s.Clients[i].Connections != s.Clients[j].Connections {
+			return s.Clients[i].Connections > s.Clients[j].Connections
+		}
+		return s.Clients[i].Name < s.Clients[j].Name
+	})
+	return s
+}
+
+// Start writes the snapshot every flush interval and once more when ctx is
+// canceled. It does nothing without a snapshot path.
+func (t *Tracker) Start(ctx context.Context) {
+	if t.path == "" {
+		return
+	}
+	ticker := time.NewTicker(t.flushInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				t.flush()
+				return
+			case <-ticker.C:
+				t.flush()
+			}
+		}
+	}()
+}
+
+// flush writes the snapshot when counts changed since the last write.
+func (t *Tracker) flush() {
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return
+	}
+	t.dirty = false
+	t.mu.Unlock()
+
+	s := t.Summary()
+	if err := t.save(snapshot{Since: s.Since, Clients: s.Clients}); err != nil {
+		logger.Warn("Failed to save client stats", zap.String("path", t.path), zap.Error(err))
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+	}
+}
+
+// save writes snap to the snapshot path atomically (temp file + rename).
+func (t *Tracker) save(snap snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create client stats directory: %w", err)
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write client stats file: %w", err)
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// load restores the counts of the snapshot file.
+func (t *Tracker) load() error {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("invalid client stats file: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !snap.Since.IsZero() {
+		t.since = snap.Since
+	}
+	for i := range snap.Clients {
+		c := snap.Clients[i]
+		if c.Name == "" {
+			continue
+		}
+		if c.Commands == nil {
+			c.Commands = make(map[string]int64)
+		}
+		if c.Kinds == nil {
+			c.Kinds = make(map[int]int64)
+		}
+		t.clients[c.Name] = &c
+	}
+	logger.Info("Client stats restored",
+		zap.String("path", t.path),
+		zap.Int("clients", len(t.clients)),
+		zap.String("since", t.since.Format(time.RFC3339)))
+	return nil
+}
@@ -0,0 +1,460 @@
+// Package communities indexes the NIP-72 moderated communities stored on
+// the relay: the latest definition (kind 34550) of each community with its
+// owner and moderators, the top-level posts made to it (kind 1111) and the
+// approvals (kind 4550) of those posts. A post is approved while an approval
+// of it comes from the community's owner or a current moderator, or while
+// its author is on the community's auto-approve allowlist; every other post
+// waits in the community's moderation queue. Posts and approvals older than
+// the maximum age are pruned.
+package communities
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/index"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-72 event kinds.
+const (
+	KindCommunity = 34550
+	KindApproval  = 4550
+	KindPost      = 1111
+)
+
+const (
+	defaultMaxPosts = 100000
+	defaultMaxAge   = 30 * 24 * time.Hour
+)
+
+// communityPrefix starts the address of every community.
+var communityPrefix = strconv.Itoa(KindCommunity) + ":"
+
+// Community is the latest definition of a community.
+type Community struct {
+	Address    string       `json:"address"` // "34550:<owner>:<d>"
+	Owner      string       `json:"owner"`
+	Name       string       `json:"name,omitempty"`
+	Moderators []string     `json:"moderators"`
+	Event      *nostr.Event `json:"event"`
+}
+
+// ParseCommunity reads a community definition. Its moderators are the p
+// tags with the moderator role.
+func ParseCommunity(evt *nostr.Event) *Community {
+	c := &Community{
+		Address:    communityPrefix + evt.PubKey + ":" + evt.Tags.GetD(),
+		Owner:      evt.PubKey,
+		Moderators: []string{},
+		Event:      evt,
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "name":
+			c.Name = tag[1]
+		case "p":
+			if len(tag) >= 4 && strings.EqualFold(tag[3], "moderator") && !slices.Contains(c.Moderators, tag[1]) {
+				c.Moderators = append(c.Moderators, tag[1])
+			}
+		}
+	}
+	return c
+}
+
+// moderates reports whether pubkey may approve posts to the community.
+func (c *Community) moderates(pubkey string) bool {
+	return pubkey == c.Owner || slices.Contains(c.Moderators, pubkey)
+}
+
+// Post is a top-level post to a community with its approval state.
+type Post struct {
+	ID        string `json:"id"`
+	Community string `json:"community"`
+	Author    string `json:"author"`
+	// ApprovedBy lists the owner and current moderators whose approvals of
+	// the post stand.
+	ApprovedBy   []string     `json:"approved_by"`
+	AutoApproved bool         `json:"auto_approved,omitempty"`
+	Event        *nostr.Event `json:"event"`
+}
+
+// Approved reports whether the post is approved.
+func (p *Post) Approved() bool {
+	return p.AutoApproved || len(p.ApprovedBy) > 0
+}
+
+// PostCommunity returns the address of the community a top-level post is
+// made to, or "" when evt is not one: a top-level post has the community as
+// both its root (A) and its parent (a).
+func PostCommunity(evt *nostr.Event) string {
+	if evt.Kind != KindPost {
+		return ""
+	}
+	var root, parent string
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || !strings.HasPrefix(tag[1], communityPrefix) {
+			continue
+		}
+		switch tag[0] {
+		case "A":
+			root = tag[1]
+		case "a":
+			parent = tag[1]
+		}
+	}
+	if root != "" && root == parent {
+		return root
+	}
+	return ""
+}
+
+// approval is a moderator's approval of a post to a community.
+type approval struct {
+	community string
+	moderator string
+	event     *nostr.Event
+}
+
+// parseApproval reads an approval event and the ID of the post it approves.
+// The approval is nil when the event does not name a community and a post.
+func parseApproval(evt *nostr.Event) (postID string, a *approval) {
+	a = &approval{moderator: evt.PubKey, event: evt}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch {
+		case tag[0] == "a" && strings.HasPrefix(tag[1], communityPrefix):
+			a.community = tag[1]
+		case tag[0] == "e" && postID == "":
+			postID = tag[1]
+		}
+	}
+	if a.community == "" || postID == "" {
+		return "", nil
+	}
+	return postID, a
+}
+
+// Index holds the latest community definitions, their posts and approvals.
+type Index struct {
+	cfg        config.CommunitiesConfig
+	allowlists map[string]map[string]bool // community -> authors approved automatically
+
+	mu          sync.RWMutex
+	communities map[string]*Community      // address -> community
+	posts       map[string]*nostr.Event    // post ID -> post
+	byCommunity map[string]map[string]bool // community -> post IDs
+	approvals   map[string][]*approval     // post ID -> approvals, the post may not be indexed yet
+	entries     int                        // posts plus approvals
+}
+
+// New creates an empty index.
+func New(cfg config.CommunitiesConfig) *Index {
+	if cfg.MaxPosts <= 0 {
+		cfg.MaxPosts = defaultMaxPosts
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = defaultMaxAge
+	}
+	allowlists := make(map[string]map[string]bool)
+	for _, list := range cfg.AutoApprove {
+		if allowlists[list.Community] == nil {
+			allowlists[list.Community] = make(map[string]bool)
+		}
+		for _, author := range list.Authors {
+			allowlists[list.Community][strings.ToLower(author)] = true
+		}
+	}
+	return &Index{
+		cfg:         cfg,
+		allowlists:  allowlists,
+		communities: make(map[string]*Community),
+		posts:       make(map[string]*nostr.Event),
+		byCommunity: make(map[string]map[string]bool),
+		approvals:   make(map[string][]*approval),
+	}
+}
+
+// Add indexes a community definition, post or approval. It reports false
+// when the event is not indexed: it is an older definition, not a post or
+// approval to a community, a duplicate, or the index is full.
+func (ix *Index) Add(evt *nostr.Event) bool {
+	switch evt.Kind {
+	case KindCommunity:
+		community := ParseCommunity(evt)
+
+		ix.mu.Lock()
+		defer ix.mu.Unlock()
+		if current, exists := ix.communities[community.Address]; exists && !index.Newer(evt, current.Event) {
+			return false
+		}
+		ix.communities[community.Address] = community
+		return true
+	case KindPost:
+		address := PostCommunity(evt)
+		if address == "" {
+			return false
+		}
+
+		ix.mu.Lock()
+		defer ix.mu.Unlock()
+		if _, exists := ix.posts[evt.ID]; exists || ix.entries >= ix.cfg.MaxPosts {
+			return false
+		}
+		ix.posts[evt.ID] = evt
+		if ix.byCommunity[address] == nil {
+			ix.byCommunity[address] = make(map[string]bool)
+		}
+		ix.byCommunity[address][evt.ID] = true
+		ix.entries++
+		return true
+	case KindApproval:
+		postID, a := parseApproval(evt)
+		if a == nil {
+			return false
+		}
+
+		ix.mu.Lock()
+		defer ix.mu.Unlock()
+		for _, existing := range ix.approvals[postID] {
+			if existing.event.ID == evt.ID {
+				return false
+			}
+		}
+		if ix.entries >= ix.cfg.MaxPosts {
+			return false
+		}
+		ix.approvals[postID] = append(ix.approvals[postID], a)
+		ix.entries++
+		return true
+	}
+	return false
+}
+
+// Remove drops what the author's NIP-09 deletion covers: posts and
+// approvals whose event ID is in ids, and communities whose address is in
+// addrs with a definition no newer than the deletion.
+func (ix *Index) Remove(deletion *nostr.Event, ids, addrs []string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for _, address := range addrs {
+		if c, ok := ix.communities[address]; ok && c.Owner == deletion.PubKey && c.Event.CreatedAt <= deletion.CreatedAt {
+			delete(ix.communities, address)
+		}
+	}
+	for _, id := range ids {
+		if post, ok := ix.posts[id]; ok && post.PubKey == deletion.PubKey {
+			ix.removePost(post)
+		}
+	}
+	if len(ids) > 0 {
+		ix.removeApprovals(func(a *approval) bool {
+			return a.moderator == deletion.PubKey && slices.Contains(ids, a.event.ID)
+		})
+	}
+}
+
+// RemoveAuthor drops every community, post and approval of a pubkey, after
+// a NIP-62 vanish request.
+func (ix *Index) RemoveAuthor(author string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for address, c := range ix.communities {
+		if c.Owner == author {
+			delete(ix.communities, address)
+		}
+	}
+	for _, post := range ix.posts {
+		if post.PubKey == author {
+			ix.removePost(post)
+		}
+	}
+	ix.removeApprovals(func(a *approval) bool { return a.moderator == author })
+}
+
+// removePost drops a post. Callers hold mu.
+func (ix *Index) removePost(post *nostr.Event) {
+	delete(ix.posts, post.ID)
+	address := PostCommunity(post)
+	delete(ix.byCommunity[address], post.ID)
+	if len(ix.byCommunity[address]) == 0 {
+		delete(ix.byCommunity, address)
+	}
+	ix.entries--
+}
+
+// removeApprovals drops the approvals drop selects. Callers hold mu.
+func (ix *Index) removeApprovals(drop func(a *approval) bool) {
+	for postID, approvals := range ix.approvals {
+		kept := approvals[:0]
+		for _, a := range approvals {
+			if drop(a) {
+				ix.entries--
+				continue
+			}
+			kept = append(kept, a)
+		}
+		if len(kept) == 0 {
+			delete(ix.approvals, postID)
+		} else {
+			ix.approvals[postID] = kept
+		}
+	}
+}
+
+// Observe keeps the index in step with a newly stored event.
+func (ix *Index) Observe(evt *nostr.Event) {
+	index.Observe(ix, evt, KindCommunity, KindPost, KindApproval)
+}
+
+// Community returns the definition of a community, or nil when it is not
+// indexed.
+func (ix *Index) Community(address string) *Community {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.communities[address]
+}
+
+// IsModerator reports whether pubkey owns or moderates the community.
+func (ix *Index) IsModerator(address, pubkey string) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	c := ix.communities[address]
+	return c != nil && c.moderates(pubkey)
+}
+
+// Post returns a post with its approval state, or nil when it is not
+// indexed.
+func (ix *Index) Post(id string) *Post {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	evt, ok := ix.posts[id]
+	if !ok {
+		return nil
+	}
+	return ix.post(evt)
+}
+
+// post builds the approval state of a post. Callers hold mu.
+func (ix *Index) post(evt *nostr.Event) *Post {
+	address := PostCommunity(evt)
+	p := &Post{
+		ID:           evt.ID,
+		Community:    address,
+		Author:       evt.PubKey,
+		ApprovedBy:   []string{},
+		AutoApproved: ix.allowlists[address][evt.PubKey],
+		Event:        evt,
+	}
+	if c := ix.communities[address]; c != nil {
+		for _, a := range ix.approvals[evt.ID] {
+			if a.community == address && c.moderates(a.moderator) && !slices.Contains(p.ApprovedBy, a.moderator) {
+				p.ApprovedBy = append(p.ApprovedBy, a.moderator)
+			}
+		}
+	}
+	return p
+}
+
+// PostQuery selects a page of a community's posts.
+type PostQuery struct {
+	Community string
+	// Approved selects the approved posts, newest first; otherwise the
+	// posts awaiting approval are selected, oldest first.
+	Approved bool
+	Limit    int
+	// After resumes a listing after the post with this event, by
+	// created_at and ID in listing order.
+	AfterCreatedAt int64
+	AfterID        string
+}
+
+// Posts returns up to q.Limit posts of the community: the moderation queue,
+// or the approved posts.
+func (ix *Index) Posts(q PostQuery) []*Post {
+	ix.mu.RLock()
+	var found []*Post
+	for id := range ix.byCommunity[q.Community] {
+		p := ix.post(ix.posts[id])
+		if p.Approved() != q.Approved {
+			continue
+		}
+		if q.AfterID != "" && !listedAfter(p.Event, q.AfterCreatedAt, q.AfterID, q.Approved) {
+			continue
+		}
+		found = append(found, p)
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i].Event, found[j].Event
+		return listedAfter(b, int64(a.CreatedAt), a.ID, q.Approved)
+	})
+	if len(found) > q.Limit {
+		found = found[:q.Limit]
+	}
+	return found
+}
+
+// Counts returns the number of posts of the community awaiting approval
+// and approved.
+func (ix *Index) Counts(address string) (pending, approved int) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	for id := range ix.byCommunity[address] {
+		if ix.post(ix.posts[id]).Approved() {
+			approved++
+		} else {
+			pending++
+		}
+	}
+	return pending, approved
+}
+
+// PruneAt drops the posts and approvals older than the maximum age at now
+// and returns how many it dropped.
+func (ix *Index) PruneAt(now time.Time) int {
+	cutoff := nostr.Timestamp(now.Add(-ix.cfg.MaxAge).Unix())
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	before := ix.entries
+	for _, post := range ix.posts {
+		if post.CreatedAt < cutoff {
+			ix.removePost(post)
+		}
+	}
+	ix.removeApprovals(func(a *approval) bool { return a.event.CreatedAt < cutoff })
+	return before - ix.entries
+}
+
+// MaxAge returns how long posts and approvals are kept.
+func (ix *Index) MaxAge() time.Duration {
+	return ix.cfg.MaxAge
+}
+
+// Len returns the number of indexed communities and of posts and approvals.
+func (ix *Index) Len() (communities, entries int) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.communities), ix.entries
+}
+
+// listedAfter reports whether evt comes after the post (createdAt, id) in a
+// listing: newest first when newestFirst, oldest first otherwise, with ties
+// in ID order.
+func listedAfter(evt *nostr.Event, createdAt int64, id string, newestFirst bool) bool {
+	if int64(evt.CreatedAt) != createdAt {
+		return (int64(evt.CreatedAt) < createdAt) == newestFirst
+	}
+	return evt.ID > id
+}
@@ -0,0 +1,18 @@
+package config
+
+import "time"
+
+// AnomalyConfig holds settings for client anomaly detection: per IP, pubkey
+// and connection fingerprint, the relay counts REQs, broad filters,
+// subscription churn and rejected events every Window, and alerts when a
+// client exceeds the baseline of all clients by Threshold standard deviations.
+type AnomalyConfig struct {
+	Enabled     bool          `mapstructure:"ENABLED"      json:"enabled"`
+	Window      time.Duration `mapstructure:"WINDOW"       json:"window"       validate:"omitempty,min=10s,max=1h"`
+	Threshold   float64       `mapstructure:"THRESHOLD"    json:"threshold"    validate:"omitempty,min=1,max=100"`     // Standard deviations above the baseline
+	Warmup      int           `mapstructure:"WARMUP"       json:"warmup"       validate:"omitempty,min=1,max=1000000"` // Samples of a feature before it can alert
+	Cooldown    time.Duration `mapstructure:"COOLDOWN"     json:"cooldown"     validate:"omitempty,min=1m,max=168h"`   // Between two alerts for one client and feature
+	MaxSubjects int           `mapstructure:"MAX_SUBJECTS" json:"max_subjects" validate:"omitempty,min=1,max=1000000"` // Clients tracked per window
+	MaxAlerts   int           `mapstructure:"MAX_ALERTS"   json:"max_alerts"   validate:"omitempty,min=1,max=10000"`   // Recent alerts kept for the dashboard
+	WebhookURL  string        `mapstructure:"WEBHOOK_URL"  json:"webhook_url"  validate:"omitempty,url"`               // Alerts are POSTed here as JSON (empty = off)
+}
@@ -0,0 +1,10 @@
+package config
+
+// AppHandlersConfig holds settings for the NIP-89 application handler index
+// behind /api/handlers: the latest handler information (kind 31990) of every
+// application and the latest recommendations (kind 31989) of every user are
+// kept in memory so clients can find an application for an unknown kind.
+type AppHandlersConfig struct {
+	Enabled    bool `mapstructure:"ENABLED"     json:"enabled"`
+	MaxEntries int  `mapstructure:"MAX_ENTRIES" json:"max_entries" validate:"omitempty,min=1"` // handlers and recommendations together
+}
@@ -0,0 +1,8 @@
+package config
+
+// AuditConfig controls the append-only audit log of administrative and
+// moderation actions (NIP-86 calls, NIP-29 moderation, bans).
+type AuditConfig struct {
+	Enabled bool   `mapstructure:"ENABLED" json:"enabled"`
+	Path    string `mapstructure:"PATH"    json:"path"` // JSON lines file, created if missing
+}
@@ -2,6 +2,8 @@ package config
 
 // CapsulesConfig holds time capsules feature settings
 type CapsulesConfig struct {
-	Enabled      bool `mapstructure:"ENABLED"       json:"enabled"`
-	MaxWitnesses int  `mapstructure:"MAX_WITNESSES" json:"max_witnesses" validate:"required,min=1,max=20"`
+	Enabled       bool `mapstructure:"ENABLED"        json:"enabled"`
+	MaxWitnesses  int  `mapstructure:"MAX_WITNESSES"  json:"max_witnesses" validate:"required,min=1,max=20"`
+	NotifyUnlocks bool `mapstructure:"NOTIFY_UNLOCKS" json:"notify_unlocks"`
+	MaxScheduled  int  `mapstructure:"MAX_SCHEDULED"  json:"max_scheduled" validate:"min=0"`
 }
@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+// ClassifiedsConfig holds settings for the NIP-99 classified listing index
+// behind /api/classifieds and the "geo" REQ filter extension: the latest
+// version of every stored listing (kind 30402) is kept with its location and
+// geohashes, and listings published longer than MaxAge ago, or sold longer
+// than SoldRetention ago, are deleted.
+type ClassifiedsConfig struct {
+	Enabled       bool          `mapstructure:"ENABLED"        json:"enabled"`
+	MaxEntries    int           `mapstructure:"MAX_ENTRIES"    json:"max_entries"    validate:"omitempty,min=1"`
+	MaxAge        time.Duration `mapstructure:"MAX_AGE"        json:"max_age"        validate:"omitempty,min=1h"` // 0 keeps listings
+	SoldRetention time.Duration `mapstructure:"SOLD_RETENTION" json:"sold_retention" validate:"omitempty,min=1h"` // 0 keeps sold listings
+}
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// ClientStatsConfig holds settings for client telemetry: connections,
+// commands and published kinds counted per client software. Counts are kept
+// in memory and written to Path every FlushInterval, so they survive restarts.
+type ClientStatsConfig struct {
+	Enabled       bool          `mapstructure:"ENABLED"        json:"enabled"`
+	Path          string        `mapstructure:"PATH"           json:"path"` // Snapshot file (empty = memory only)
+	FlushInterval time.Duration `mapstructure:"FLUSH_INTERVAL" json:"flush_interval" validate:"omitempty,min=10s,max=24h"`
+	MaxClients    int           `mapstructure:"MAX_CLIENTS"    json:"max_clients"    validate:"omitempty,min=1,max=10000"` // Further clients are counted as "other"
+}
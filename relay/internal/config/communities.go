@@ -0,0 +1,24 @@
+package config
+
+import "time"
+
+// CommunitiesConfig holds settings for the NIP-72 community index behind
+// /api/communities: the latest definition (kind 34550) of every community,
+// the top-level posts made to it and the approvals (kind 4550) of its
+// moderators are kept in memory for MaxAge, so moderators can work through the posts
+// still awaiting approval. Posts from the authors AutoApprove lists for a
+// community count as approved; when the relay's own key moderates that
+// community, the relay also publishes the approval.
+type CommunitiesConfig struct {
+	Enabled     bool                       `mapstructure:"ENABLED"      json:"enabled"`
+	MaxPosts    int                        `mapstructure:"MAX_POSTS"    json:"max_posts"    validate:"omitempty,min=1"` // posts and approvals together
+	MaxAge      time.Duration              `mapstructure:"MAX_AGE"      json:"max_age"      validate:"omitempty,min=1h"`
+	AutoApprove []CommunityAllowlistConfig `mapstructure:"AUTO_APPROVE" json:"auto_approve" validate:"omitempty,dive"`
+}
+
+// CommunityAllowlistConfig lists the authors whose posts to a community are
+// approved without waiting for a moderator.
+type CommunityAllowlistConfig struct {
+	Community string   `mapstructure:"COMMUNITY" json:"community" validate:"required,startswith=34550:"` // "34550:<owner pubkey>:<d>"
+	Authors   []string `mapstructure:"AUTHORS"   json:"authors"   validate:"required,min=1,dive,pubkey"`
+}
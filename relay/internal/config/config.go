@@ -26,13 +26,47 @@ var validate = validator.New()
 
 // Config holds every sub‑config.
 type Config struct {
-	General     GeneralConfig     `mapstructure:"general"      validate:"required"`
-	Metrics     MetricsConfig     `mapstructure:"metrics"      validate:"required"`
-	Logging     LoggingConfig     `mapstructure:"logging"      validate:"required"`
-	Relay       RelayConfig       `mapstructure:"relay"        validate:"required"`
-	RelayPolicy RelayPolicyConfig `mapstructure:"relay_policy" validate:"required"`
-	Database    DatabaseConfig    `mapstructure:"database"     validate:"required"`
-	Capsules    CapsulesConfig    `mapstructure:"capsules"     validate:"required"`
+	Profile        string               `mapstructure:"profile"      validate:"omitempty,oneof=public community paid private"`
+	General        GeneralConfig        `mapstructure:"general"      validate:"required"`
+	Metrics        MetricsConfig        `mapstructure:"metrics"      validate:"required"`
+	Tracing        TracingConfig        `mapstructure:"tracing"`
+	GRPC           GRPCConfig           `mapstructure:"grpc"`
+	TLS            TLSConfig            `mapstructure:"tls"`
+	Logging        LoggingConfig        `mapstructure:"logging"      validate:"required"`
+	Relay          RelayConfig          `mapstructure:"relay"        validate:"required"`
+	RelayPolicy    RelayPolicyConfig    `mapstructure:"relay_policy" validate:"required"`
+	Database       DatabaseConfig       `mapstructure:"database"     validate:"required"`
+	Capsules       CapsulesConfig       `mapstructure:"capsules"     validate:"required"`
+	Outbox         OutboxConfig         `mapstructure:"outbox"`
+	DVM            DVMConfig            `mapstructure:"dvm"`
+	Reports        ReportsConfig        `mapstructure:"reports"`
+	Scoring        ScoringConfig        `mapstructure:"scoring"`
+	Reputation     ReputationConfig     `mapstructure:"reputation"`
+	SpamClusters   SpamClustersConfig   `mapstructure:"spam_clusters"`
+	ClientStats    ClientStatsConfig    `mapstructure:"client_stats"`
+	Anomaly        AnomalyConfig        `mapstructure:"anomaly"`
+	Metadata       MetadataConfig       `mapstructure:"metadata"`
+	NIP05          NIP05Config          `mapstructure:"nip05"`
+	OpenTimestamps OpenTimestampsConfig `mapstructure:"opentimestamps"`
+	Zaps           ZapsConfig           `mapstructure:"zaps"`
+	LNURL          LNURLConfig          `mapstructure:"lnurl"`
+	KeyPackages    KeyPackagesConfig    `mapstructure:"key_packages"`
+	Marketplace    MarketplaceConfig    `mapstructure:"marketplace"`
+	Classifieds    ClassifiedsConfig    `mapstructure:"classifieds"`
+	Live           LiveConfig           `mapstructure:"live"`
+	AppHandlers    AppHandlersConfig    `mapstructure:"app_handlers"`
+	Communities    CommunitiesConfig    `mapstructure:"communities"`
+	Labels         LabelsConfig         `mapstructure:"labels"`
+	Wiki           WikiConfig           `mapstructure:"wiki"`
+	NIPs           NIPsConfig           `mapstructure:"nips"`
+	Web            WebConfig            `mapstructure:"web"`
+	Audit          AuditConfig          `mapstructure:"audit"`
+	Sink           SinkConfig           `mapstructure:"sink"`
+	Webhooks       WebhooksConfig       `mapstructure:"webhooks"`
+	Tenants        []TenantConfig       `mapstructure:"tenants"      validate:"omitempty,dive"`
+
+	// File is the config file the settings were read from ("" when none was).
+	File string `mapstructure:"-"`
 }
 
 // Register custom validation rules
@@ -135,6 +169,18 @@ func registerCustomValidators() {
 		logger.Error("Failed to register pubkey validator", zap.Error(err))
 	}
 	
+	// Validate kind number, range or keyword in the kinds policy
+	if err := validate.RegisterValidation("kind_spec", func(fl validator.FieldLevel) bool {
+		spec := strings.TrimSpace(fl.Field().String())
+		if spec == KindSpecDefault {
+			return true
+		}
+		_, _, err := ParseKindSpec(spec)
+		return err == nil
+	}); err != nil {
+		logger.Error("Failed to register kind_spec validator", zap.Error(err))
+	}
+
 	// Validate duration is reasonable (not too short or too long)
 	if err := validate.RegisterValidation("reasonable_duration", func(fl validator.FieldLevel) bool {
 		duration := fl.Field().Interface().(time.Duration)
@@ -222,15 +268,43 @@ func performCrossFieldValidation(sl validator.StructLevel, cfg Config) {
 	}
 	
 	// Validate that database port is not the same as metrics port (only when not using URL)
-	if cfg.Database.URL == "" && cfg.Database.Port == cfg.Metrics.Port {
+	usesServer := cfg.Database.Driver != "sqlite"
+	if usesServer && cfg.Database.URL == "" && cfg.Database.Port == cfg.Metrics.Port {
 		sl.ReportError(cfg.Database.Port, "Port", "Port", "port_conflict", "")
 	}
 
 	// Validate that either URL or Server+Port is configured
-	if cfg.Database.URL == "" && cfg.Database.Server == "" {
+	if usesServer && cfg.Database.URL == "" && cfg.Database.Server == "" {
 		sl.ReportError(cfg.Database.Server, "Server", "Server", "db_connection_required", "")
 	}
+
+	// The embedded driver needs a database file
+	if !usesServer && cfg.Database.SQLitePath == "" {
+		sl.ReportError(cfg.Database.SQLitePath, "SQLitePath", "SQLitePath", "required", "")
+	}
 	
+	// Context takeover cannot be negotiated; gorilla/websocket only implements no_context_takeover
+	if cfg.Relay.Compression.Enabled && cfg.Relay.Compression.ContextTakeover {
+		sl.ReportError(cfg.Relay.Compression.ContextTakeover, "ContextTakeover", "ContextTakeover", "context_takeover_unsupported", "")
+	}
+
+	// Tenant names and hosts must be unique so every event and request has one owner
+	tenantNames := make(map[string]bool, len(cfg.Tenants))
+	tenantHosts := make(map[string]bool)
+	for _, t := range cfg.Tenants {
+		if tenantNames[t.Name] {
+			sl.ReportError(t.Name, "Tenants", "Tenants", "duplicate_tenant_name", "")
+		}
+		tenantNames[t.Name] = true
+		for _, host := range t.Hosts {
+			host = strings.ToLower(host)
+			if tenantHosts[host] {
+				sl.ReportError(host, "Tenants", "Tenants", "duplicate_tenant_host", "")
+			}
+			tenantHosts[host] = true
+		}
+	}
+
 	// Validate that public URL scheme matches WebSocket address
 	if cfg.Relay.PublicURL != "" {
 		if parsedURL, err := url.Parse(cfg.Relay.PublicURL); err == nil {
@@ -250,8 +324,82 @@ func SetVersion(v string) {
 	Version = v
 }
 
-// Load merges defaults → file (optional) → env vars, validates, and returns cfg.
+// Load merges defaults → profile (optional) → file (optional) → env vars,
+// validates, and returns cfg.
 func Load(path string, log *zap.Logger) (*Config, error) {
+	cfg, err := load(path, log)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := initializeLogger(cfg.Logging); err != nil {
+		return nil, fmt.Errorf("initialize logger: %w", err)
+	} else {
+		if log != nil {
+			log.Info("logger initialized",
+				zap.String("level", cfg.Logging.Level),
+				zap.String("format", cfg.Logging.Format),
+				zap.String("file", cfg.Logging.FilePath),
+			)
+		}
+	}
+	return cfg, nil
+}
+
+// Reload reads the configuration again from the file at path, for settings
+// applied while the relay runs. Unlike Load it leaves the logger alone.
+func Reload(path string) (*Config, error) {
+	return load(path, nil)
+}
+
+// load reads and validates the configuration.
+func load(path string, log *zap.Logger) (*Config, error) {
+	v, err := readConfig(path, nil, log)
+	if err != nil {
+		return nil, err
+	}
+
+	// A policy profile sits between the defaults and the operator's settings,
+	// so the file only needs the fields that differ from the profile.
+	if profile := v.GetString("PROFILE"); profile != "" {
+		data, err := profileYAML(profile)
+		if err != nil {
+			return nil, err
+		}
+		if v, err = readConfig(path, data, nil); err != nil {
+			return nil, err
+		}
+		if log != nil {
+			log.Info("Applied policy profile", zap.String("profile", profile))
+		}
+	}
+
+	// 4. env already merged by AutomaticEnv()
+
+	var cfg Config
+	if err := v.UnmarshalExact(&cfg); err != nil { // ← use Exact
+		return nil, fmt.Errorf("unmarshal config: %w", err)
+	}
+	if err := validate.Struct(cfg); err != nil {
+		return nil, formatValidationError(err)
+	}
+	// if err := crossValidate(&cfg); err != nil {
+	// 	return nil, err
+	// }
+
+	cfg.File = v.ConfigFileUsed()
+
+	if log != nil {
+		log.Info("configuration loaded",
+			zap.String("version", Version),
+		)
+	}
+	return &cfg, nil
+}
+
+// readConfig layers the embedded defaults, the profile settings (if any) and
+// the config file at path, or config.yaml in the working directory.
+func readConfig(path string, profile []byte, log *zap.Logger) (*viper.Viper, error) {
 	v := viper.New()
 	v.SetConfigType("yaml")
 	v.SetEnvPrefix("SHUGUR") // SHUGUR_GENERAL_LISTENING_PORT
@@ -263,7 +411,14 @@ func Load(path string, log *zap.Logger) (*Config, error) {
 		return nil, fmt.Errorf("read defaults: %w", err)
 	}
 
-	// 2. optional user file
+	// 2. policy profile (embedded)
+	if profile != nil {
+		if err := v.MergeConfig(bytes.NewReader(profile)); err != nil {
+			return nil, fmt.Errorf("read profile: %w", err)
+		}
+	}
+
+	// 3. optional user file
 	if path != "" {
 		v.SetConfigFile(path)
 		if err := v.MergeInConfig(); err != nil {
@@ -285,37 +440,7 @@ func Load(path string, log *zap.Logger) (*Config, error) {
 			}
 		}
 	}
-
-	// 3. env already merged by AutomaticEnv()
-
-	var cfg Config
-	if err := v.UnmarshalExact(&cfg); err != nil { // ← use Exact
-		return nil, fmt.Errorf("unmarshal config: %w", err)
-	}
-	if err := validate.Struct(cfg); err != nil {
-		return nil, formatValidationError(err)
-	}
-	// if err := crossValidate(&cfg); err != nil {
-	// 	return nil, err
-	// }
-
-	if log != nil {
-		log.Info("configuration loaded",
-			zap.String("version", Version),
-		)
-	}
-	if err := initializeLogger(cfg.Logging); err != nil {
-		return nil, fmt.Errorf("initialize logger: %w", err)
-	} else {
-		if log != nil {
-			log.Info("logger initialized",
-				zap.String("level", cfg.Logging.Level),
-				zap.String("format", cfg.Logging.Format),
-				zap.String("file", cfg.Logging.FilePath),
-			)
-		}
-	}
-	return &cfg, nil
+	return v, nil
 }
 
 // MustLoad loads configuration and returns error instead of panicking (production-safe)
@@ -397,6 +522,10 @@ func getFieldErrorMessage(fe validator.FieldError) string {
 		return fmt.Sprintf("%s should be longer than write timeout to allow proper connection closure", field)
 	case "port_conflict":
 		return "database port conflicts with metrics port, they must be different"
+	case "context_takeover_unsupported":
+		return fmt.Sprintf("%s is not supported: the websocket library always negotiates permessage-deflate with no_context_takeover", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s (got: %v)", field, param, value)
 	case "invalid_websocket_scheme":
 		return fmt.Sprintf("%s must use 'ws://' or 'wss://' scheme for WebSocket connections", field)
 	default:
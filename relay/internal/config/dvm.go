@@ -0,0 +1,10 @@
+package config
+
+import "time"
+
+// DVMConfig holds NIP-90 Data Vending Machine job tracking settings.
+type DVMConfig struct {
+	Enabled   bool          `mapstructure:"ENABLED"   json:"enabled"`
+	MaxJobs   int           `mapstructure:"MAX_JOBS"  json:"max_jobs"  validate:"omitempty,min=1,max=1000000"`
+	Retention time.Duration `mapstructure:"RETENTION" json:"retention"`
+}
@@ -0,0 +1,13 @@
+package config
+
+// GRPCConfig holds settings for the internal gRPC API used by sidecar
+// services (indexers, analytics, bridges). It listens on its own port, which
+// should stay private.
+type GRPCConfig struct {
+	Enabled bool   `mapstructure:"ENABLED" json:"enabled"`
+	Host    string `mapstructure:"HOST"    json:"host"` // listen address (empty = all interfaces)
+	Port    int    `mapstructure:"PORT"    json:"port"    validate:"omitempty,min=1024,max=65535"`
+
+	// Token, when set, must be sent as "authorization: Bearer <token>" metadata.
+	Token string `mapstructure:"TOKEN" json:"-"`
+}
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// KeyPackagesConfig holds settings for the NIP-EE KeyPackage index behind
+// /api/keypackages/<pubkey>: stored kind 443 events are indexed by pubkey and
+// ciphersuite, republished copies are refused, and packages beyond
+// MaxPerPubkey or older than MaxAge are deleted.
+type KeyPackagesConfig struct {
+	Enabled      bool          `mapstructure:"ENABLED"        json:"enabled"`
+	MaxPerPubkey int           `mapstructure:"MAX_PER_PUBKEY" json:"max_per_pubkey" validate:"omitempty,min=1,max=1000"`
+	MaxAge       time.Duration `mapstructure:"MAX_AGE"        json:"max_age"        validate:"omitempty,min=1h"` // 0 keeps packages until superseded or deleted
+}
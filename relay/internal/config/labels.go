@@ -0,0 +1,14 @@
+package config
+
+// LabelsConfig holds settings for the NIP-32 label index behind /api/labels
+// and the "label" REQ filter extension. Queries that name no labeler count
+// the labels of TrustedLabelers, or of everyone when it is empty. Events
+// labeled with one of the Withhold labels ("<namespace>/<label>") by a
+// trusted labeler, and the events of pubkeys so labeled, are withheld from
+// results like events hidden by reports.
+type LabelsConfig struct {
+	Enabled         bool     `mapstructure:"ENABLED"          json:"enabled"`
+	FilterExtension bool     `mapstructure:"FILTER_EXTENSION" json:"filter_extension"`
+	TrustedLabelers []string `mapstructure:"TRUSTED_LABELERS" json:"trusted_labelers" validate:"omitempty,dive,pubkey"`
+	Withhold        []string `mapstructure:"WITHHOLD"         json:"withhold"         validate:"omitempty,dive,contains=/"` // needs TRUSTED_LABELERS
+}
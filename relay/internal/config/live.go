@@ -0,0 +1,18 @@
+package config
+
+import "time"
+
+// LiveConfig holds settings for the NIP-53 live activity tracker behind
+// /api/live: live streams (kind 30311) that are live and meeting spaces
+// (kind 30312) that are open are kept with a participant count from the
+// room presence events (kind 10312) younger than PresenceTTL. Live streams
+// whose ends time has passed, or that their host has not updated for
+// StaleAfter, are treated as ended and, with HideStaleInREQ, left out of
+// REQ results too.
+type LiveConfig struct {
+	Enabled        bool          `mapstructure:"ENABLED"           json:"enabled"`
+	PresenceTTL    time.Duration `mapstructure:"PRESENCE_TTL"      json:"presence_ttl"      validate:"omitempty,min=30s"`
+	MaxActivities  int           `mapstructure:"MAX_ACTIVITIES"    json:"max_activities"    validate:"omitempty,min=1"`
+	StaleAfter     time.Duration `mapstructure:"STALE_AFTER"       json:"stale_after"       validate:"omitempty,min=1m"`
+	HideStaleInREQ bool          `mapstructure:"HIDE_STALE_IN_REQ" json:"hide_stale_in_req"`
+}
@@ -0,0 +1,38 @@
+package config
+
+import "time"
+
+// Lightning backends.
+const (
+	LightningLNbits = "lnbits"
+	LightningLND    = "lnd"
+)
+
+// LNURLConfig holds settings for the relay's lightning address: the relay
+// serves LNURL-pay (LUD-06/LUD-16) at /.well-known/lnurlp/<name> with
+// invoices from Backend, so Name@<relay host> can be zapped. Once an invoice
+// for a NIP-57 zap request is paid the relay publishes the zap receipt,
+// signed with its key. With Users, the names hosted through NIP05.HOSTING
+// are lightning addresses too, paid into the same wallet.
+type LNURLConfig struct {
+	Enabled       bool            `mapstructure:"ENABLED"        json:"enabled"`
+	Name          string          `mapstructure:"NAME"           json:"name"           validate:"omitempty,max=64"`
+	Users         bool            `mapstructure:"USERS"          json:"users"`
+	Description   string          `mapstructure:"DESCRIPTION"    json:"description"    validate:"omitempty,max=200"`
+	MinSendable   int64           `mapstructure:"MIN_SENDABLE"   json:"min_sendable"   validate:"omitempty,min=1"` // msat
+	MaxSendable   int64           `mapstructure:"MAX_SENDABLE"   json:"max_sendable"   validate:"omitempty,min=1"` // msat
+	InvoiceExpiry time.Duration   `mapstructure:"INVOICE_EXPIRY" json:"invoice_expiry" validate:"omitempty,min=1m,max=24h"`
+	PollInterval  time.Duration   `mapstructure:"POLL_INTERVAL"  json:"poll_interval"  validate:"omitempty,min=1s,max=5m"` // How often unpaid zap invoices are checked
+	MaxPending    int             `mapstructure:"MAX_PENDING"    json:"max_pending"    validate:"omitempty,min=1,max=1000000"`
+	Backend       LightningConfig `mapstructure:"BACKEND"        json:"backend"`
+}
+
+// LightningConfig names the lightning node invoices are created on.
+type LightningConfig struct {
+	Type     string        `mapstructure:"TYPE"     json:"type"     validate:"omitempty,oneof=lnbits lnd"`
+	URL      string        `mapstructure:"URL"      json:"url"      validate:"omitempty,url"`
+	APIKey   string        `mapstructure:"API_KEY"  json:"-"`        // LNbits wallet invoice key
+	Macaroon string        `mapstructure:"MACAROON" json:"-"`        // LND invoice macaroon, hex
+	TLSCert  string        `mapstructure:"TLS_CERT" json:"tls_cert"` // LND certificate file, for self-signed nodes
+	Timeout  time.Duration `mapstructure:"TIMEOUT"  json:"timeout"  validate:"omitempty,min=1s,max=1m"`
+}
@@ -0,0 +1,10 @@
+package config
+
+// MarketplaceConfig holds settings for the NIP-15 marketplace index behind
+// /api/marketplace: the latest version of every stored stall (kind 30017)
+// and product (kind 30018) is kept parsed in memory so clients can search
+// products and browse stalls without a REQ per tag.
+type MarketplaceConfig struct {
+	Enabled    bool `mapstructure:"ENABLED"     json:"enabled"`
+	MaxEntries int  `mapstructure:"MAX_ENTRIES" json:"max_entries" validate:"omitempty,min=1"` // stalls and products together
+}
@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// MetadataConfig holds settings for the kind 0 metadata cache behind
+// /api/profile/<pubkey>: the latest profile of up to MaxEntries pubkeys is
+// kept in memory and read from storage again after TTL.
+type MetadataConfig struct {
+	Enabled    bool          `mapstructure:"ENABLED"     json:"enabled"`
+	MaxEntries int           `mapstructure:"MAX_ENTRIES" json:"max_entries" validate:"omitempty,min=1,max=1000000"`
+	TTL        time.Duration `mapstructure:"TTL"         json:"ttl"         validate:"omitempty,min=1s,max=168h"` // How long a profile is served before storage is read again
+}
@@ -2,6 +2,18 @@ package config
 
 // MetricsConfig holds metrics configuration settings.
 type MetricsConfig struct {
-	Enabled bool `mapstructure:"ENABLED" json:"enabled" validate:"required"`
-	Port    int  `mapstructure:"PORT"    json:"port"    validate:"required,min=1024,max=65535"`
+	Enabled bool   `mapstructure:"ENABLED" json:"enabled" validate:"required"`
+	Host    string `mapstructure:"HOST"    json:"host"` // listen address for the metrics listener (empty = all interfaces)
+	Port    int    `mapstructure:"PORT"    json:"port"    validate:"required,min=1024,max=65535"`
+	Path    string `mapstructure:"PATH"    json:"path"    validate:"omitempty,startswith=/"`
+
+	// AllowedIPs restricts scrapes to these IPs or CIDR ranges (empty = any).
+	AllowedIPs []string         `mapstructure:"ALLOWED_IPS" json:"allowed_ips" validate:"omitempty,dive,cidr|ip"`
+	BasicAuth  MetricsBasicAuth `mapstructure:"BASIC_AUTH"  json:"basic_auth"`
+}
+
+// MetricsBasicAuth protects the metrics listener with HTTP basic auth when both fields are set.
+type MetricsBasicAuth struct {
+	Username string `mapstructure:"USERNAME" json:"username"`
+	Password string `mapstructure:"PASSWORD" json:"-"`
 }
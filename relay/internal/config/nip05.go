@@ -0,0 +1,37 @@
+package config
+
+import "time"
+
+// NIP05Config holds settings for NIP-05 verification: name@domain identifiers
+// are resolved through the domain's /.well-known/nostr.json and the mapping
+// cached for TTL, a failed lookup for NegativeTTL. With RequireForWrite only
+// authors whose profile claims an identifier resolving to their pubkey may
+// publish, apart from profiles and deletions.
+type NIP05Config struct {
+	Enabled         bool          `mapstructure:"ENABLED"           json:"enabled"`
+	TTL             time.Duration `mapstructure:"TTL"               json:"ttl"               validate:"omitempty,min=1m,max=168h"`
+	NegativeTTL     time.Duration `mapstructure:"NEGATIVE_TTL"      json:"negative_ttl"      validate:"omitempty,min=10s,max=24h"`
+	DNSTimeout      time.Duration `mapstructure:"DNS_TIMEOUT"       json:"dns_timeout"       validate:"omitempty,min=100ms,max=30s"`
+	Timeout         time.Duration `mapstructure:"TIMEOUT"           json:"timeout"           validate:"omitempty,min=1s,max=1m"` // Bounds one lookup, DNS included
+	MaxEntries      int           `mapstructure:"MAX_ENTRIES"       json:"max_entries"       validate:"omitempty,min=1,max=1000000"`
+	RequireForWrite bool          `mapstructure:"REQUIRE_FOR_WRITE" json:"require_for_write"`
+
+	Hosting NIP05HostingConfig `mapstructure:"HOSTING" json:"hosting"`
+}
+
+// NIP05HostingConfig makes the relay a NIP-05 provider: it serves
+// /.well-known/nostr.json for the local names listed here and those set
+// through NIP-86. A name's relay hints default to Relays, or to the relay's
+// PUBLIC_URL when Relays is empty.
+type NIP05HostingConfig struct {
+	Enabled bool              `mapstructure:"ENABLED" json:"enabled"`
+	Names   []NIP05NameConfig `mapstructure:"NAMES"   json:"names"   validate:"omitempty,dive"`
+	Relays  []string          `mapstructure:"RELAYS"  json:"relays"  validate:"omitempty,dive,url"`
+}
+
+// NIP05NameConfig maps a local name (the part before @) to a pubkey.
+type NIP05NameConfig struct {
+	Name   string   `mapstructure:"NAME"   json:"name"   validate:"required,max=64"`
+	Pubkey string   `mapstructure:"PUBKEY" json:"pubkey" validate:"required,pubkey"`
+	Relays []string `mapstructure:"RELAYS" json:"relays" validate:"omitempty,dive,url"`
+}
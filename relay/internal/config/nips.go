@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// NIPsConfig switches individual NIPs off. A disabled NIP's kinds are
+// refused, its validators are skipped and it is dropped from the NIP-11
+// supported_nips. The list is re-read from the config file while the relay
+// runs, so NIPs can be switched without a restart.
+type NIPsConfig struct {
+	Disabled       []string      `mapstructure:"DISABLED"        json:"disabled"        validate:"omitempty,dive,alphanum,max=4"` // NIP IDs such as "54", "15" or "EE"
+	ReloadInterval time.Duration `mapstructure:"RELOAD_INTERVAL" json:"reload_interval" validate:"omitempty,min=1s,max=1h"`
+}
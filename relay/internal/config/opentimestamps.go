@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// OpenTimestampsConfig holds settings for verifying NIP-03 attestations
+// (kind 1040): the OTS proof is parsed, checked to commit to the referenced
+// event ID, and its Bitcoin attestations are checked against block headers.
+// Proofs still waiting on a calendar are upgraded from the allowed calendars.
+type OpenTimestampsConfig struct {
+	Enabled bool `mapstructure:"ENABLED" json:"enabled"`
+
+	// Esplora-compatible API serving Bitcoin block headers by height.
+	BitcoinAPI string `mapstructure:"BITCOIN_API" json:"bitcoin_api" validate:"omitempty,url"`
+
+	// Calendar servers asked to upgrade pending attestations; pending
+	// attestations naming other calendars are never fetched.
+	Calendars       []string      `mapstructure:"CALENDARS"        json:"calendars"        validate:"omitempty,dive,url"`
+	UpgradeInterval time.Duration `mapstructure:"UPGRADE_INTERVAL" json:"upgrade_interval" validate:"omitempty,min=1m,max=168h"`
+	Timeout         time.Duration `mapstructure:"TIMEOUT"          json:"timeout"`
+	Workers         int           `mapstructure:"WORKERS"          json:"workers"          validate:"omitempty,min=1,max=64"`
+	QueueSize       int           `mapstructure:"QUEUE_SIZE"       json:"queue_size"       validate:"omitempty,min=1,max=1000000"`
+	MaxAttestations int           `mapstructure:"MAX_ATTESTATIONS" json:"max_attestations" validate:"omitempty,min=1,max=10000000"`
+}
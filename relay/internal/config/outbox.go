@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// OutboxConfig holds settings for publishing relay-signed events to external relays.
+type OutboxConfig struct {
+	Enabled    bool          `mapstructure:"ENABLED"     json:"enabled"`
+	Relays     []string      `mapstructure:"RELAYS"      json:"relays"      validate:"dive,url"`
+	MaxRetries int           `mapstructure:"MAX_RETRIES" json:"max_retries" validate:"min=0,max=20"`
+	RetryDelay time.Duration `mapstructure:"RETRY_DELAY" json:"retry_delay"`
+	QueueSize  int           `mapstructure:"QUEUE_SIZE"  json:"queue_size"  validate:"min=1,max=100000"`
+
+	// NIP-65: also deliver events addressed to a pubkey to that pubkey's read relays
+	UseRelayHints bool `mapstructure:"USE_RELAY_HINTS" json:"use_relay_hints"`
+	MaxHintRelays int  `mapstructure:"MAX_HINT_RELAYS" json:"max_hint_relays" validate:"min=0,max=1000"`
+}
@@ -1,5 +1,12 @@
 package config
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // RelayPolicyConfig holds policy settings.
 type RelayPolicyConfig struct {
 	Blacklist struct {
@@ -8,4 +15,60 @@ type RelayPolicyConfig struct {
 	Whitelist struct {
 		PubKeys []string `mapstructure:"PUBKEYS" json:"pubkeys" validate:"omitempty,dive,pubkey"`
 	} `mapstructure:"WHITELIST"`
+	Kinds     KindsPolicyConfig `mapstructure:"KINDS"     json:"kinds"`
+	Retention struct {
+		// Regular events older than MaxAge are removed; replaceable and
+		// addressable events are kept (0 = keep forever).
+		MaxAge time.Duration `mapstructure:"MAX_AGE" json:"max_age"`
+	} `mapstructure:"RETENTION" json:"retention"`
+	StorageQuota struct {
+		// Stored bytes allowed per pubkey; relay admins are exempt (0 = unlimited).
+		MaxBytesPerPubkey int64 `mapstructure:"MAX_BYTES_PER_PUBKEY" json:"max_bytes_per_pubkey" validate:"min=0"`
+	} `mapstructure:"STORAGE_QUOTA" json:"storage_quota"`
+}
+
+// KindsPolicyConfig declares the event kinds the relay accepts. Entries are
+// kind numbers, ranges ("5000-5999"), "*" for every kind or "default" for the
+// built-in profile. An empty allow list means the built-in profile.
+type KindsPolicyConfig struct {
+	Allow []string `mapstructure:"ALLOW" json:"allow" validate:"omitempty,dive,kind_spec"`
+	Deny  []string `mapstructure:"DENY"  json:"deny"  validate:"omitempty,dive,kind_spec"`
+}
+
+// Kind spec keywords.
+const (
+	KindSpecAll     = "*"
+	KindSpecDefault = "default"
+	MaxEventKind    = 65535
+)
+
+// ParseKindSpec parses a kind number or range into its inclusive bounds.
+// "*" covers every kind; "default" is not a range and is rejected here.
+func ParseKindSpec(spec string) (lo, hi int, err error) {
+	spec = strings.TrimSpace(spec)
+	if spec == KindSpecAll {
+		return 0, MaxEventKind, nil
+	}
+	from, to, isRange := strings.Cut(spec, "-")
+	if lo, err = parseKind(from); err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return lo, lo, nil
+	}
+	if hi, err = parseKind(to); err != nil {
+		return 0, 0, err
+	}
+	if hi < lo {
+		return 0, 0, fmt.Errorf("invalid kind range %q", spec)
+	}
+	return lo, hi, nil
+}
+
+func parseKind(s string) (int, error) {
+	k, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || k < 0 || k > MaxEventKind {
+		return 0, fmt.Errorf("invalid kind %q", s)
+	}
+	return k, nil
 }
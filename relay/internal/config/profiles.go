@@ -0,0 +1,26 @@
+package config
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+)
+
+// Policy profiles bundle coherent settings for common kinds of relays. The
+// selected profile is layered over the defaults and under the config file,
+// so operators only override the fields where they differ.
+//
+//go:embed profiles/*.yaml
+var profileFS embed.FS
+
+// Profiles lists the policy profiles selectable with PROFILE.
+var Profiles = []string{"public", "community", "paid", "private"}
+
+// profileYAML returns the settings of the named profile.
+func profileYAML(name string) ([]byte, error) {
+	data, err := profileFS.ReadFile("profiles/" + name + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(Profiles, ", "))
+	}
+	return data, nil
+}
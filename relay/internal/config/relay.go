@@ -9,6 +9,7 @@ type RelayConfig struct {
 	Contact          string           `mapstructure:"CONTACT"           json:"contact"           validate:"omitempty,email"`
 	PublicKey        string           `mapstructure:"PUBLIC_KEY"        json:"public_key"        validate:"omitempty,pubkey"`
 	PrivateKey       string           `mapstructure:"PRIVATE_KEY"       json:"-"`
+	Signer           SignerConfig     `mapstructure:"SIGNER"            json:"signer"`
 	AdminPubkeys     []string         `mapstructure:"ADMIN_PUBKEYS"     json:"admin_pubkeys"`
 	Icon             string           `mapstructure:"ICON"              json:"icon"              validate:"omitempty,url"`
 	Banner           string           `mapstructure:"BANNER"            json:"banner"            validate:"omitempty,url"`
@@ -16,12 +17,104 @@ type RelayConfig struct {
 	RelayCountries   []string         `mapstructure:"RELAY_COUNTRIES"   json:"relay_countries"`
 	WSAddr           string           `mapstructure:"WS_ADDR"           json:"ws_addr"           validate:"required,wsaddr"`
 	PublicURL        string           `mapstructure:"PUBLIC_URL"        json:"public_url"        validate:"omitempty,url"`
+	TrustedProxies   []string         `mapstructure:"TRUSTED_PROXIES"   json:"trusted_proxies"   validate:"omitempty,dive,cidr|ip"` // Peers allowed to set client IP and scheme headers
+	ProxyProtocol    bool             `mapstructure:"PROXY_PROTOCOL"    json:"proxy_protocol"`
 	IdleTimeout      time.Duration    `mapstructure:"IDLE_TIMEOUT"      json:"idle_timeout"      validate:"required,reasonable_duration"`
 	WriteTimeout     time.Duration    `mapstructure:"WRITE_TIMEOUT"     json:"write_timeout"     validate:"required,timeout_duration"`
 	SendBufferSize   int              `mapstructure:"SEND_BUFFER_SIZE"  json:"send_buffer_size"  validate:"required,buffer_size"`
 	EventCacheSize   int              `mapstructure:"EVENT_CACHE_SIZE"   json:"event_cache_size"  validate:"required,min=100,max=1000000"`
 	MinPowDifficulty int              `mapstructure:"MIN_POW_DIFFICULTY" json:"min_pow_difficulty" validate:"min=0,max=64"`
+	MinFilterPrefix  int              `mapstructure:"MIN_FILTER_PREFIX"  json:"min_filter_prefix"  validate:"omitempty,min=4,max=64"`
 	ThrottlingConfig ThrottlingConfig `mapstructure:"THROTTLING"        json:"throttling"        validate:"required"`
+	Compression      DeflateConfig    `mapstructure:"COMPRESSION"       json:"compression"`
+	AcceptBinary     bool             `mapstructure:"ACCEPT_BINARY_FRAMES" json:"accept_binary_frames"`
+	AccessMode       string           `mapstructure:"ACCESS_MODE"       json:"access_mode"       validate:"omitempty,oneof=public members private"`
+	AuthRequired     bool             `mapstructure:"AUTH_REQUIRED"     json:"auth_required"`
+	PaymentRequired  bool             `mapstructure:"PAYMENT_REQUIRED"  json:"payment_required"`
+	PaymentsURL      string           `mapstructure:"PAYMENTS_URL"      json:"payments_url"      validate:"omitempty,url"`
+	InviteTTL        time.Duration    `mapstructure:"INVITE_TTL"        json:"invite_ttl"`
+	SigVerify        SigVerifyConfig  `mapstructure:"SIGNATURE_VERIFICATION" json:"signature_verification"`
+	NWC              NWCConfig        `mapstructure:"NWC"               json:"nwc"`
+	DMInbox          DMInboxConfig    `mapstructure:"DM_INBOX"          json:"dm_inbox"`
+	Highlights       HighlightsConfig `mapstructure:"HIGHLIGHTS"        json:"highlights"`
+	Badges           BadgesConfig     `mapstructure:"BADGES"            json:"badges"`
+	Ack              AckConfig        `mapstructure:"ACK"               json:"ack"`
+	Coalesce         CoalesceConfig   `mapstructure:"COALESCE"          json:"coalesce"`
+}
+
+// CoalesceConfig holds live delivery settings for replaceable and addressable
+// events: versions published within Window of each other reach a
+// subscription as the latest one only.
+type CoalesceConfig struct {
+	Enabled bool          `mapstructure:"ENABLED" json:"enabled"`
+	Window  time.Duration `mapstructure:"WINDOW"  json:"window" validate:"omitempty,min=10ms,max=1m"`
+}
+
+// AckConfig selects when EVENT commands are answered with OK true.
+type AckConfig struct {
+	// Mode "queued" answers once the event is queued for storage, "durable"
+	// once it is written (falling back to accepted-pending after Timeout) and
+	// "pending" at once with an accepted-pending reason.
+	Mode    string        `mapstructure:"MODE"    json:"mode"    validate:"omitempty,oneof=queued durable pending"`
+	Timeout time.Duration `mapstructure:"TIMEOUT" json:"timeout" validate:"omitempty,min=10ms,max=30s"`
+}
+
+// SignerConfig selects where the key for relay-signed events comes from.
+type SignerConfig struct {
+	Source        string        `mapstructure:"SOURCE"         json:"source"         validate:"omitempty,oneof=config env keystore bunker"`
+	Env           string        `mapstructure:"ENV"            json:"env"`          // Variable holding a hex or nsec key (source env)
+	Keystore      string        `mapstructure:"KEYSTORE"       json:"keystore"`     // File holding a NIP-49 ncryptsec (source keystore)
+	PasswordEnv   string        `mapstructure:"PASSWORD_ENV"   json:"password_env"` // Variable holding the keystore password
+	BunkerURL     string        `mapstructure:"BUNKER_URL"     json:"-"`            // bunker:// URL, its secret included (source bunker)
+	Timeout       time.Duration `mapstructure:"TIMEOUT"        json:"timeout"        validate:"omitempty,min=1s,max=5m"`
+	RotationGrace time.Duration `mapstructure:"ROTATION_GRACE" json:"rotation_grace" validate:"omitempty,max=8760h"` // How long the previous key stays valid after rotaterelaykey
+}
+
+// DMInboxConfig holds settings for NIP-17 DM inbox mode.
+type DMInboxConfig struct {
+	Enabled            bool          `mapstructure:"ENABLED"             json:"enabled"`
+	Users              []string      `mapstructure:"USERS"               json:"users"`
+	DeliveredRetention time.Duration `mapstructure:"DELIVERED_RETENTION" json:"delivered_retention" validate:"omitempty,min=1m,max=720h"`
+}
+
+// HighlightsConfig holds settings for NIP-84 highlights.
+type HighlightsConfig struct {
+	MaxLength int `mapstructure:"MAX_LENGTH" json:"max_length" validate:"min=0,max=65536"` // 0 = only the general content limit
+}
+
+// BadgesConfig holds settings for NIP-58 badges.
+type BadgesConfig struct {
+	// StrictAwards rejects badge awards whose definition the relay does not
+	// store; otherwise only stored definitions are checked against the award.
+	StrictAwards bool `mapstructure:"STRICT_AWARDS" json:"strict_awards"`
+}
+
+// NWCConfig holds settings for NIP-47 Wallet Connect traffic.
+type NWCConfig struct {
+	Enabled         bool          `mapstructure:"ENABLED"           json:"enabled"`
+	Retention       time.Duration `mapstructure:"RETENTION"         json:"retention"         validate:"omitempty,min=1s,max=1h"`
+	EventsPerMinute int           `mapstructure:"EVENTS_PER_MINUTE" json:"events_per_minute" validate:"min=0,max=10000"`
+	Burst           int           `mapstructure:"BURST"             json:"burst"             validate:"min=0,max=1000"`
+}
+
+// SigVerifyConfig holds settings for the signature verification worker pool.
+type SigVerifyConfig struct {
+	Workers   int `mapstructure:"WORKERS"    json:"workers"    validate:"min=0,max=1024"`
+	QueueSize int `mapstructure:"QUEUE_SIZE" json:"queue_size" validate:"omitempty,min=1,max=1000000"`
+}
+
+// DeflateConfig holds WebSocket permessage-deflate settings.
+//
+// ContextTakeover asks for the compression context to be kept across
+// messages, which compresses small, similar Nostr messages much better at the
+// cost of a 32 KiB window per connection. gorilla/websocket implements only
+// the no_context_takeover mode of RFC 7692 and always negotiates it, so the
+// setting must stay false: setting it fails validation rather than silently
+// negotiating something else.
+type DeflateConfig struct {
+	Enabled         bool `mapstructure:"ENABLED"          json:"enabled"`
+	Level           int  `mapstructure:"LEVEL"            json:"level"            validate:"min=-2,max=9"`
+	ContextTakeover bool `mapstructure:"CONTEXT_TAKEOVER" json:"context_takeover"`
 }
 
 // ThrottlingConfig holds rate limiting settings.
@@ -31,6 +124,27 @@ type ThrottlingConfig struct {
 	MaxConnections int             `mapstructure:"MAX_CONNECTIONS"    json:"max_connections"    validate:"required,min=1,max=100000"`
 	BanThreshold   int             `mapstructure:"BAN_THRESHOLD"      json:"ban_threshold"      validate:"required,min=1,max=1000"`
 	BanDuration    int             `mapstructure:"BAN_DURATION"       json:"ban_duration"       validate:"required,min=1,max=86400"`
+
+	// Websocket connections and SSE streams one IP may hold at once (0 = unlimited).
+	MaxConnectionsPerIP int `mapstructure:"MAX_CONNECTIONS_PER_IP" json:"max_connections_per_ip" validate:"min=0,max=100000"`
+
+	// Query limits; zero uses the built-in default.
+	MaxLimit             int `mapstructure:"MAX_LIMIT"              json:"max_limit"              validate:"omitempty,min=1,max=5000"`
+	MaxEventsPerReq      int `mapstructure:"MAX_EVENTS_PER_REQ"     json:"max_events_per_req"     validate:"omitempty,min=1,max=100000"`
+	MaxConcurrentQueries int `mapstructure:"MAX_CONCURRENT_QUERIES" json:"max_concurrent_queries" validate:"omitempty,min=1,max=1000"`
+
+	// History REQ and COUNT filters may reach back, per connection class.
+	QueryWindow QueryWindowConfig `mapstructure:"QUERY_WINDOW" json:"query_window"`
+
+	// Address range, country and ASN blocks and repeat offender escalation.
+	IPBlocking IPBlockingConfig `mapstructure:"IP_BLOCKING" json:"ip_blocking"`
+}
+
+// QueryWindowConfig holds the maximum history window of a query: filters
+// reaching further back are clamped to it (0 = unlimited).
+type QueryWindowConfig struct {
+	Anonymous     time.Duration `mapstructure:"ANONYMOUS"     json:"anonymous"     validate:"min=0"`
+	Authenticated time.Duration `mapstructure:"AUTHENTICATED" json:"authenticated" validate:"min=0"`
 }
 
 // RateLimitConfig holds rate limiting settings.
@@ -44,3 +158,24 @@ type RateLimitConfig struct {
 	BanDuration          time.Duration `mapstructure:"BAN_DURATION"          json:"ban_duration"            validate:"reasonable_duration"`
 	MaxBanDuration       time.Duration `mapstructure:"MAX_BAN_DURATION"      json:"max_ban_duration"        validate:"reasonable_duration"`
 }
+
+// IPBlockingConfig holds the client IPs refused before a connection is
+// accepted. Countries and ASNs need the matching GeoIP database.
+type IPBlockingConfig struct {
+	CIDRs           []string `mapstructure:"CIDRS"            json:"cidrs"            validate:"omitempty,dive,cidr"`
+	Countries       []string `mapstructure:"COUNTRIES"        json:"countries"        validate:"omitempty,dive,len=2"` // ISO 3166-1 alpha-2 codes
+	ASNs            []uint   `mapstructure:"ASNS"             json:"asns"`
+	CountryDatabase string   `mapstructure:"COUNTRY_DATABASE" json:"country_database"` // GeoLite2-Country or GeoLite2-City .mmdb
+	ASNDatabase     string   `mapstructure:"ASN_DATABASE"     json:"asn_database"`     // GeoLite2-ASN .mmdb
+
+	Escalation EscalationConfig `mapstructure:"ESCALATION" json:"escalation"`
+}
+
+// EscalationConfig turns repeated rate limit bans of one IP into a long,
+// persisted IP block.
+type EscalationConfig struct {
+	Enabled   bool          `mapstructure:"ENABLED"   json:"enabled"`
+	Window    time.Duration `mapstructure:"WINDOW"    json:"window"`                              // How long a ban counts as an offense
+	Threshold int           `mapstructure:"THRESHOLD" json:"threshold" validate:"min=0,max=1000"` // Offenses within the window that escalate
+	LongBan   time.Duration `mapstructure:"LONG_BAN"  json:"long_ban"`                            // 0 = permanent
+}
@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// ReportsConfig holds settings for the NIP-56 report pipeline. Reports (kind
+// 1984) are aggregated per reported event and pubkey; crossing a threshold of
+// unique reporters within Window hides the event or flags the pubkey for review.
+type ReportsConfig struct {
+	Enabled bool          `mapstructure:"ENABLED" json:"enabled"`
+	Window  time.Duration `mapstructure:"WINDOW"  json:"window"`
+
+	HideThreshold int      `mapstructure:"HIDE_THRESHOLD" json:"hide_threshold" validate:"min=0,max=100000"` // 0 = never auto-hide
+	FlagThreshold int      `mapstructure:"FLAG_THRESHOLD" json:"flag_threshold" validate:"min=0,max=100000"` // 0 = never auto-flag
+	Types         []string `mapstructure:"TYPES"          json:"types"          validate:"omitempty,dive,oneof=nudity malware profanity illegal spam impersonation other"`
+	MaxTargets    int      `mapstructure:"MAX_TARGETS"    json:"max_targets"    validate:"omitempty,min=1,max=1000000"`
+}
@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+// ReputationConfig holds web-of-trust settings. Trust is derived from the
+// follow lists (kind 3) of the anchor pubkeys and of the pubkeys they follow.
+type ReputationConfig struct {
+	Enabled         bool          `mapstructure:"ENABLED"          json:"enabled"`
+	Anchors         []string      `mapstructure:"ANCHORS"          json:"anchors"          validate:"omitempty,dive,len=64,hexadecimal"`
+	MaxDepth        int           `mapstructure:"MAX_DEPTH"        json:"max_depth"        validate:"omitempty,min=1,max=4"`
+	RefreshInterval time.Duration `mapstructure:"REFRESH_INTERVAL" json:"refresh_interval"`
+
+	// Pubkeys scoring at or above TrustThreshold skip PoW and connection rate limits.
+	TrustThreshold float64 `mapstructure:"TRUST_THRESHOLD" json:"trust_threshold" validate:"min=0,max=1"`
+
+	Unknown UnknownPubkeyPolicy `mapstructure:"UNKNOWN" json:"unknown"`
+}
+
+// UnknownPubkeyPolicy holds the stricter limits applied to pubkeys outside the trust graph.
+type UnknownPubkeyPolicy struct {
+	MinPowDifficulty int `mapstructure:"MIN_POW_DIFFICULTY" json:"min_pow_difficulty" validate:"min=0,max=64"`
+	EventsPerMinute  int `mapstructure:"EVENTS_PER_MINUTE"  json:"events_per_minute"  validate:"min=0,max=100000"` // 0 = unlimited
+	Burst            int `mapstructure:"BURST"              json:"burst"              validate:"min=0,max=10000"`
+	// ShadowReject answers events over the limit with OK true and drops them
+	// instead of refusing them, so spammers cannot tell they are limited.
+	ShadowReject bool `mapstructure:"SHADOW_REJECT" json:"shadow_reject"`
+}
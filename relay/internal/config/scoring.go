@@ -0,0 +1,40 @@
+package config
+
+import "time"
+
+// ScoringConfig holds settings for the asynchronous content scoring pipeline.
+type ScoringConfig struct {
+	Enabled   bool  `mapstructure:"ENABLED"    json:"enabled"`
+	Workers   int   `mapstructure:"WORKERS"    json:"workers"    validate:"omitempty,min=1,max=64"`
+	QueueSize int   `mapstructure:"QUEUE_SIZE" json:"queue_size" validate:"omitempty,min=1,max=1000000"`
+	Kinds     []int `mapstructure:"KINDS"      json:"kinds"` // empty = every stored kind with content
+
+	// Events scoring at or above DeleteThreshold are removed from storage;
+	// a pubkey with ShadowBanAfter removed events is shadow-banned (0 = never).
+	DeleteThreshold float64 `mapstructure:"DELETE_THRESHOLD" json:"delete_threshold" validate:"min=0,max=1"`
+	ShadowBanAfter  int     `mapstructure:"SHADOW_BAN_AFTER" json:"shadow_ban_after" validate:"min=0,max=10000"`
+
+	// Events the local scorers (regex, Bayes) rate at or above
+	// ShadowRejectThreshold are answered OK but never stored (0 = never).
+	ShadowRejectThreshold float64 `mapstructure:"SHADOW_REJECT_THRESHOLD" json:"shadow_reject_threshold" validate:"min=0,max=1"`
+
+	Regex RegexScorerConfig `mapstructure:"REGEX" json:"regex"`
+	Bayes BayesScorerConfig `mapstructure:"BAYES" json:"bayes"`
+	HTTP  HTTPScorerConfig  `mapstructure:"HTTP"  json:"http"`
+}
+
+// RegexScorerConfig lists patterns that mark content as spam.
+type RegexScorerConfig struct {
+	Patterns []string `mapstructure:"PATTERNS" json:"patterns"`
+}
+
+// BayesScorerConfig points at a naive Bayes token model.
+type BayesScorerConfig struct {
+	ModelPath string `mapstructure:"MODEL_PATH" json:"model_path"`
+}
+
+// HTTPScorerConfig configures an external HTTP classifier.
+type HTTPScorerConfig struct {
+	URL     string        `mapstructure:"URL"     json:"url"     validate:"omitempty,url"`
+	Timeout time.Duration `mapstructure:"TIMEOUT" json:"timeout"`
+}
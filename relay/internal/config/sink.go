@@ -0,0 +1,41 @@
+package config
+
+import "time"
+
+// SinkConfig holds settings for streaming accepted events to an MQTT broker or Kafka.
+type SinkConfig struct {
+	Enabled          bool          `mapstructure:"ENABLED"           json:"enabled"`
+	Driver           string        `mapstructure:"DRIVER"            json:"driver"            validate:"omitempty,oneof=mqtt kafka"`
+	Kinds            []int         `mapstructure:"KINDS"             json:"kinds"` // empty = every kind
+	IncludeEphemeral bool          `mapstructure:"INCLUDE_EPHEMERAL" json:"include_ephemeral"`
+	QueueSize        int           `mapstructure:"QUEUE_SIZE"        json:"queue_size"        validate:"omitempty,min=1,max=1000000"`
+	BatchSize        int           `mapstructure:"BATCH_SIZE"        json:"batch_size"        validate:"omitempty,min=1,max=10000"`
+	FlushInterval    time.Duration `mapstructure:"FLUSH_INTERVAL"    json:"flush_interval"`
+	RetryDelay       time.Duration `mapstructure:"RETRY_DELAY"       json:"retry_delay"`
+	MaxRetryDelay    time.Duration `mapstructure:"MAX_RETRY_DELAY"   json:"max_retry_delay"`
+
+	MQTT  SinkMQTTConfig  `mapstructure:"MQTT"  json:"mqtt"`
+	Kafka SinkKafkaConfig `mapstructure:"KAFKA" json:"kafka"`
+}
+
+// SinkMQTTConfig configures the MQTT 3.1.1 broker events are published to.
+// Topic may contain {kind}, replaced by the event kind.
+type SinkMQTTConfig struct {
+	Broker    string        `mapstructure:"BROKER"     json:"broker"     validate:"omitempty,url"` // tcp://host:1883 or ssl://host:8883
+	ClientID  string        `mapstructure:"CLIENT_ID"  json:"client_id"`
+	Username  string        `mapstructure:"USERNAME"   json:"username"`
+	Password  string        `mapstructure:"PASSWORD"   json:"-"`
+	Topic     string        `mapstructure:"TOPIC"      json:"topic"`
+	QoS       int           `mapstructure:"QOS"        json:"qos"        validate:"min=0,max=1"`
+	KeepAlive time.Duration `mapstructure:"KEEP_ALIVE" json:"keep_alive"`
+}
+
+// SinkKafkaConfig configures the Kafka REST Proxy (v2 API) events are produced
+// through. Topic may contain {kind}, replaced by the event kind.
+type SinkKafkaConfig struct {
+	RestURL  string        `mapstructure:"REST_URL" json:"rest_url" validate:"omitempty,url"`
+	Topic    string        `mapstructure:"TOPIC"    json:"topic"`
+	Username string        `mapstructure:"USERNAME" json:"username"`
+	Password string        `mapstructure:"PASSWORD" json:"-"`
+	Timeout  time.Duration `mapstructure:"TIMEOUT"  json:"timeout"`
+}
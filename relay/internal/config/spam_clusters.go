@@ -0,0 +1,22 @@
+package config
+
+import "time"
+
+// SpamClustersConfig holds settings for near-duplicate spam detection: notes
+// whose normalized content is nearly identical are grouped into clusters, and
+// a cluster posted by many pubkeys within the window is acted on.
+type SpamClustersConfig struct {
+	Enabled    bool          `mapstructure:"ENABLED"     json:"enabled"`
+	Kinds      []int         `mapstructure:"KINDS"       json:"kinds"`                                  // empty = every kind
+	MinLength  int           `mapstructure:"MIN_LENGTH"  json:"min_length"  validate:"min=0,max=10000"` // Shorter normalized content is never clustered
+	Distance   int           `mapstructure:"DISTANCE"    json:"distance"    validate:"min=0,max=3"`     // Differing SimHash bits still counted as the same content
+	Window     time.Duration `mapstructure:"WINDOW"      json:"window"      validate:"omitempty,min=1m,max=168h"`
+	MinPubkeys int           `mapstructure:"MIN_PUBKEYS" json:"min_pubkeys" validate:"omitempty,min=2,max=100000"`
+
+	// Action taken on events of a flagged cluster: "throttle" lets
+	// ThrottlePerMinute events of the cluster through per minute,
+	// "shadow_reject" acknowledges and drops them all.
+	Action            string `mapstructure:"ACTION"              json:"action"              validate:"omitempty,oneof=throttle shadow_reject"`
+	ThrottlePerMinute int    `mapstructure:"THROTTLE_PER_MINUTE" json:"throttle_per_minute" validate:"min=0,max=10000"`
+	MaxClusters       int    `mapstructure:"MAX_CLUSTERS"        json:"max_clusters"        validate:"omitempty,min=1,max=1000000"`
+}
@@ -0,0 +1,21 @@
+package config
+
+// TenantConfig describes a virtual relay served from the same process. A
+// connection or NIP-11 request is routed to the tenant whose Hosts include the
+// request's Host header; requests for any other host go to the main relay.
+// Events published through a tenant are stored with its Name and only served
+// back through that tenant.
+type TenantConfig struct {
+	Name        string   `mapstructure:"NAME"        json:"name"        validate:"required,min=1,max=64,alphanum"`
+	Hosts       []string `mapstructure:"HOSTS"       json:"hosts"       validate:"required,min=1,dive,hostname_port|hostname_rfc1123"`
+	RelayName   string   `mapstructure:"RELAY_NAME"  json:"relay_name"  validate:"omitempty,max=30"`
+	Description string   `mapstructure:"DESCRIPTION" json:"description" validate:"omitempty,max=200"`
+	Contact     string   `mapstructure:"CONTACT"     json:"contact"     validate:"omitempty,email"`
+	Icon        string   `mapstructure:"ICON"        json:"icon"        validate:"omitempty,url"`
+	Banner      string   `mapstructure:"BANNER"      json:"banner"      validate:"omitempty,url"`
+
+	// Policies applied on top of the relay-wide ones
+	AllowedKinds []int    `mapstructure:"ALLOWED_KINDS" json:"allowed_kinds" validate:"omitempty,dive,min=0,max=65535"` // empty = all kinds
+	Blacklist    []string `mapstructure:"BLACKLIST"     json:"blacklist"     validate:"omitempty,dive,pubkey"`
+	Whitelist    []string `mapstructure:"WHITELIST"     json:"whitelist"     validate:"omitempty,dive,pubkey"` // non-empty = only these pubkeys may publish
+}
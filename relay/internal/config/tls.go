@@ -0,0 +1,33 @@
+package config
+
+import "time"
+
+// TLSConfig holds settings for serving HTTPS/WSS from the relay itself, for
+// deployments without a reverse proxy in front.
+type TLSConfig struct {
+	Enabled  bool   `mapstructure:"ENABLED"   json:"enabled"`
+	Addr     string `mapstructure:"ADDR"      json:"addr"`      // HTTPS listener, used instead of RELAY.WS_ADDR
+	HTTPAddr string `mapstructure:"HTTP_ADDR" json:"http_addr"` // redirects to HTTPS and answers HTTP-01 challenges (empty = off)
+
+	// Certificate files, reloaded when they change (ignored with ACME).
+	CertFile       string        `mapstructure:"CERT_FILE"       json:"cert_file"`
+	KeyFile        string        `mapstructure:"KEY_FILE"        json:"key_file"`
+	ReloadInterval time.Duration `mapstructure:"RELOAD_INTERVAL" json:"reload_interval"`
+
+	ACME ACMEConfig `mapstructure:"ACME" json:"acme"`
+}
+
+// ACMEConfig holds settings for obtaining certificates automatically.
+type ACMEConfig struct {
+	Enabled   bool     `mapstructure:"ENABLED"   json:"enabled"`
+	Domains   []string `mapstructure:"DOMAINS"   json:"domains"` // "*.example.com" needs dns-01
+	Email     string   `mapstructure:"EMAIL"     json:"email"     validate:"omitempty,email"`
+	Directory string   `mapstructure:"DIRECTORY" json:"directory" validate:"omitempty,url"`
+	CacheDir  string   `mapstructure:"CACHE_DIR" json:"cache_dir"`
+	Challenge string   `mapstructure:"CHALLENGE" json:"challenge" validate:"omitempty,oneof=http-01 tls-alpn-01 dns-01"`
+
+	// DNS-01: executable run as "<hook> present|cleanup <domain> <record> <value>"
+	// to create and remove the TXT record with the DNS provider.
+	DNSHook        string        `mapstructure:"DNS_HOOK"        json:"dns_hook"`
+	DNSPropagation time.Duration `mapstructure:"DNS_PROPAGATION" json:"dns_propagation"`
+}
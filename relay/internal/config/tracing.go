@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+// TracingConfig holds OpenTelemetry tracing settings. Spans are exported to an
+// OTLP/HTTP collector (JSON encoding) such as the OpenTelemetry Collector,
+// Jaeger or Tempo.
+type TracingConfig struct {
+	Enabled bool `mapstructure:"ENABLED" json:"enabled"`
+
+	// Endpoint is the collector base URL; /v1/traces is appended unless a path is given.
+	Endpoint    string            `mapstructure:"ENDPOINT"     json:"endpoint"     validate:"omitempty,url"`
+	Headers     map[string]string `mapstructure:"HEADERS"      json:"-"`
+	ServiceName string            `mapstructure:"SERVICE_NAME" json:"service_name"`
+
+	// SampleRatio is the fraction of commands traced (0-1).
+	SampleRatio    float64       `mapstructure:"SAMPLE_RATIO"    json:"sample_ratio"    validate:"min=0,max=1"`
+	BatchSize      int           `mapstructure:"BATCH_SIZE"      json:"batch_size"      validate:"omitempty,min=1,max=10000"`
+	ExportInterval time.Duration `mapstructure:"EXPORT_INTERVAL" json:"export_interval"`
+}
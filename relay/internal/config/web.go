@@ -0,0 +1,71 @@
+package config
+
+import "time"
+
+// WebConfig holds settings for the dashboard and /api/* endpoints.
+type WebConfig struct {
+	// AssetsDir overrides the embedded dashboard assets with a directory
+	// holding templates/ and static/ (empty = use the embedded copy).
+	AssetsDir string `mapstructure:"ASSETS_DIR" json:"assets_dir"`
+
+	Auth WebAuthConfig `mapstructure:"AUTH" json:"auth"`
+
+	EventsAPI WebEventsAPIConfig `mapstructure:"EVENTS_API" json:"events_api"`
+	Stream    WebStreamConfig    `mapstructure:"STREAM"     json:"stream"`
+	Articles  WebArticlesConfig  `mapstructure:"ARTICLES"   json:"articles"`
+}
+
+// WebEventsAPIConfig controls the read-only HTTP query API (/api/events and
+// /api/event/<id>), which serves stored events without a websocket.
+type WebEventsAPIConfig struct {
+	Enabled bool `mapstructure:"ENABLED" json:"enabled"`
+
+	// Queries running at once across all HTTP clients.
+	MaxConcurrentQueries int `mapstructure:"MAX_CONCURRENT_QUERIES" json:"max_concurrent_queries" validate:"omitempty,min=1,max=1000"`
+
+	// Add the relay's first-seen time as a received_at field next to each
+	// event and accept received_since/received_until filters.
+	ReceivedAt bool `mapstructure:"RECEIVED_AT" json:"received_at"`
+}
+
+// WebStreamConfig controls the Server-Sent Events endpoint (/api/stream),
+// which follows live events over plain HTTP. Streams count against
+// THROTTLING.MAX_CONNECTIONS and MAX_CONNECTIONS_PER_IP like websockets.
+type WebStreamConfig struct {
+	Enabled bool `mapstructure:"ENABLED" json:"enabled"`
+
+	// Interval of the keepalive comments holding idle streams open through proxies.
+	Keepalive time.Duration `mapstructure:"KEEPALIVE" json:"keepalive" validate:"omitempty,min=1s,max=10m"`
+}
+
+// WebArticlesConfig controls the NIP-23 article pages (/a/<naddr> and
+// /article/<kind>:<pubkey>:<d>), which render stored long-form posts as HTML
+// with Open Graph tags so links to them unfurl and read without a client.
+type WebArticlesConfig struct {
+	Enabled bool `mapstructure:"ENABLED" json:"enabled"`
+}
+
+// WebAuthConfig controls dashboard authentication. Users log in with a NIP-98
+// signed request and receive a session cookie; scripts can use a static
+// bearer token instead. Relay admins always get the admin role.
+type WebAuthConfig struct {
+	Enabled bool `mapstructure:"ENABLED" json:"enabled"`
+
+	// PublicRead lets unauthenticated visitors use read-only endpoints (rate limited).
+	PublicRead bool `mapstructure:"PUBLIC_READ" json:"public_read"`
+
+	AdminPubkeys  []string      `mapstructure:"ADMIN_PUBKEYS"  json:"admin_pubkeys"  validate:"omitempty,dive,len=64,hexadecimal"`
+	ViewerPubkeys []string      `mapstructure:"VIEWER_PUBKEYS" json:"viewer_pubkeys" validate:"omitempty,dive,len=64,hexadecimal"`
+	Tokens        []WebAPIToken `mapstructure:"TOKENS"         json:"-"              validate:"omitempty,dive"`
+	SessionTTL    time.Duration `mapstructure:"SESSION_TTL"    json:"session_ttl"`
+
+	// Requests per minute allowed from one IP without a session or token (0 = unlimited).
+	UnauthRequestsPerMinute int `mapstructure:"UNAUTH_REQUESTS_PER_MINUTE" json:"unauth_requests_per_minute" validate:"min=0,max=100000"`
+	UnauthBurst             int `mapstructure:"UNAUTH_BURST"               json:"unauth_burst"               validate:"min=0,max=10000"`
+}
+
+// WebAPIToken is a static bearer token granting a dashboard role.
+type WebAPIToken struct {
+	Token string `mapstructure:"TOKEN" json:"-"    validate:"min=16"`
+	Role  string `mapstructure:"ROLE"  json:"role" validate:"oneof=viewer admin"`
+}
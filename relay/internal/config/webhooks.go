@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+// WebhooksConfig holds settings for POSTing matching events to HTTP endpoints.
+type WebhooksConfig struct {
+	Enabled    bool            `mapstructure:"ENABLED"     json:"enabled"`
+	Workers    int             `mapstructure:"WORKERS"     json:"workers"     validate:"omitempty,min=1,max=64"`
+	QueueSize  int             `mapstructure:"QUEUE_SIZE"  json:"queue_size"  validate:"omitempty,min=1,max=1000000"`
+	MaxRetries int             `mapstructure:"MAX_RETRIES" json:"max_retries" validate:"min=0,max=20"`
+	RetryDelay time.Duration   `mapstructure:"RETRY_DELAY" json:"retry_delay"`
+	Timeout    time.Duration   `mapstructure:"TIMEOUT"     json:"timeout"`
+	Endpoints  []WebhookConfig `mapstructure:"ENDPOINTS"   json:"endpoints"   validate:"omitempty,dive"`
+}
+
+// WebhookConfig is one endpoint and the events it receives.
+type WebhookConfig struct {
+	URL    string `mapstructure:"URL"    json:"url"    validate:"required,url"`
+	Secret string `mapstructure:"SECRET" json:"-"`      // HMAC-SHA256 key for the signature header
+	Filter string `mapstructure:"FILTER" json:"filter"` // NIP-01 filter as JSON, empty = every event
+}
@@ -0,0 +1,15 @@
+package config
+
+// WikiConfig holds settings for the NIP-54 wiki page API at /api/wiki, which
+// resolves a topic to its articles (kind 30818), their pending merge
+// requests (kind 818) and the redirects (kind 30819) leading to it. Articles
+// are ranked by their author's web-of-trust score, raised for every trusted
+// labeler (LABELS.TRUSTED_LABELERS) that gave the article or its author one
+// of PreferredLabels and lowered for every one that gave it one of
+// DemotedLabels ("<namespace>/<label>").
+type WikiConfig struct {
+	Enabled         bool     `mapstructure:"ENABLED"          json:"enabled"`
+	MaxArticles     int      `mapstructure:"MAX_ARTICLES"     json:"max_articles"     validate:"omitempty,min=1,max=500"`
+	PreferredLabels []string `mapstructure:"PREFERRED_LABELS" json:"preferred_labels" validate:"omitempty,dive,contains=/"`
+	DemotedLabels   []string `mapstructure:"DEMOTED_LABELS"   json:"demoted_labels"   validate:"omitempty,dive,contains=/"`
+}
@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+// ZapsConfig holds settings for deep verification of NIP-57 zap receipts
+// (kind 9735). Beyond the structural checks every receipt gets, a verified
+// receipt's invoice must commit to the embedded zap request and match its
+// amount, and optionally the receipt must be signed by the zap provider the
+// recipient's kind 0 lud16/lud06 address names.
+type ZapsConfig struct {
+	VerifyReceipts bool          `mapstructure:"VERIFY_RECEIPTS" json:"verify_receipts"`
+	VerifyProvider bool          `mapstructure:"VERIFY_PROVIDER" json:"verify_provider"` // Fetches the recipient's LNURL-pay endpoint
+	Timeout        time.Duration `mapstructure:"TIMEOUT"         json:"timeout"         validate:"omitempty,min=1s,max=1m"`
+	CacheTTL       time.Duration `mapstructure:"CACHE_TTL"       json:"cache_ttl"       validate:"omitempty,min=1m,max=168h"` // How long a provider pubkey is reused
+}
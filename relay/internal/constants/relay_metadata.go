@@ -5,6 +5,7 @@ import (
 	
 	"github.com/Shugur-Network/relay/internal/config"
 	"github.com/Shugur-Network/relay/internal/identity"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nip11 "github.com/nbd-wtf/go-nostr/nip11"
 )
 
@@ -22,77 +23,6 @@ const (
 	DefaultRelayIcon        = "https://avatars.githubusercontent.com/u/198367099?s=400&u=2bc76d4fe6f57a1c39ef00fd784dd0bf85d79bda&v=4"
 )
 
-// DefaultSupportedNIPs lists the NIPs supported by the relay
-var DefaultSupportedNIPs = []interface{}{
-	1,  // NIP-01: Basic protocol flow description
-	2,  // NIP-02: Follow List
-	3,  // NIP-03: OpenTimestamps Attestations for Events
-	9,  // NIP-09: Event Deletion Request
-	11, // NIP-11: Relay Information Document
-	13, // NIP-13: Proof of Work
-	15, // NIP-15: Nostr Marketplace (for resilient marketplaces)
-	17, // NIP-17: Private Direct Messages
-	18, // NIP-18: Reposts
-	22, // NIP-22: Comment
-	23, // NIP-23: Long-form Content
-	24, // NIP-24: Extra metadata fields and tags
-	25, // NIP-25: Reactions
-	28, // NIP-28: Public Chat
-	29, // NIP-29: Relay-based Groups
-	30, // NIP-30: Custom Emoji
-	32, // NIP-32: Labeling
-	34, // NIP-34: Git Stuff
-	35, // NIP-35: Torrents
-	37, // NIP-37: Draft Wraps
-	38, // NIP-38: User Statuses
-	39, // NIP-39: External Identities in Profiles
-	40, // NIP-40: Expiration Timestamp
-	42, // NIP-42: Authentication of clients to relays
-	43, // NIP-43: Relay Access Metadata and Requests
-	44, // NIP-44: Encrypted Payloads (Versioned)
-	45, // NIP-45: Counting Events
-	47, // NIP-47: Nostr Wallet Connect (NWC)
-	50, // NIP-50: Search Capability
-	51, // NIP-51: Lists
-	52, // NIP-52: Calendar Events
-	53, // NIP-53: Live Activities
-	54, // NIP-54: Wiki
-	56, // NIP-56: Reporting
-	57, // NIP-57: Lightning Zaps
-	58, // NIP-58: Badges
-	59, // NIP-59: Gift Wrap
-	60, // NIP-60: Cashu Wallets
-	61, // NIP-61: Nutzaps
-	62, // NIP-62: Request to Vanish
-	64, // NIP-64: Chess (PGN)
-	65, // NIP-65: Relay List Metadata
-	66, // NIP-66: Relay Discovery and Liveness Monitoring
-	69, // NIP-69: Peer-to-peer Order Events
-	70, // NIP-70: Protected Events
-	71, // NIP-71: Video Events
-	72, // NIP-72: Moderated Communities
-	75, // NIP-75: Zap Goals
-	77, // NIP-77: Negentropy Syncing
-	78, // NIP-78: Application-specific data
-	84, // NIP-84: Highlights
-	85, // NIP-85: Trusted Assertions
-	86, // NIP-86: Relay Management API
-	87, // NIP-87: Ecash Mint Discoverability
-	88, // NIP-88: Polls
-	89, // NIP-89: Recommended Application Handlers
-	90, // NIP-90: Data Vending Machine
-	94, // NIP-94: File Metadata
-	99, // NIP-99: Classified Listings
-	"7D", // NIP-7D: Threads
-	"A0", // NIP-A0: Voice Messages
-	"A4", // NIP-A4: Public Messages
-	"B0", // NIP-B0: Web Bookmarking
-	"C0", // NIP-C0: Code Snippets
-	"C7", // NIP-C7: Chats
-	"B7", // NIP-B7: Blossom Server List
-	"EE", // NIP-EE: MLS E2EE Messaging
-}
-
 // CustomNIP represents a custom NIP implementation
 type CustomNIP struct {
 	ID          string `json:"id"`
@@ -102,19 +32,12 @@ type CustomNIP struct {
 }
 
 // DefaultCustomNIPs lists custom NIPs implemented by this relay
-var DefaultCustomNIPs = []CustomNIP{
-	{
-		ID:          "XX",
-		Name:        "Time Capsules",
-		Description: "Time-locked message delivery with threshold witness mode and VDF support",
-		Link:        "https://github.com/Shugur-Network/NIP-XX_Time-Capsules",
-	},
-	{
-		ID:          "YY",
-		Name:        "Nostr Web Pages",
-		Description: "Censorship-resistant static websites on Nostr",
-		Link:        "https://github.com/Shugur-Network/nw-nips",
-	},
+func DefaultCustomNIPs() []CustomNIP {
+	custom := make([]CustomNIP, 0)
+	for _, nip := range registry.Custom() {
+		custom = append(custom, CustomNIP{ID: nip.ID, Name: nip.Name, Description: nip.Description, Link: nip.Link})
+	}
+	return custom
 }
 
 // Relay limitations and settings
@@ -122,7 +45,7 @@ const (
 	MaxMessageLength = 2048
 	MaxSubscriptions = 100
 	MaxFilters       = 100
-	MaxLimit         = 100
+	MaxLimit         = 500
 	MaxSubIDLength   = 100
 	MaxEventTags     = 100
 	MaxContentLength = 2048
@@ -213,30 +136,35 @@ func DefaultRelayMetadata(cfg *config.Config) nip11.RelayInformationDocument {
 	if maxContentLength == 0 {
 		maxContentLength = MaxContentLength // fallback to default constant
 	}
+	maxLimit := cfg.Relay.ThrottlingConfig.MaxLimit
+	if maxLimit == 0 {
+		maxLimit = MaxLimit
+	}
 
 	return nip11.RelayInformationDocument{
 		Name:          relayName,
 		Description:   relayDescription,
 		Contact:       relayContact,
 		PubKey:        relayIdentity.PublicKey,
-		SupportedNIPs: DefaultSupportedNIPs,
+		SupportedNIPs: registry.Supported(),
 		Software:      DefaultRelaySoftware,
 		Version:       config.Version,
 		Icon:          relayIcon,
 		Banner:         relayBanner,
 		PostingPolicy:  relayPostingPolicy,
 		RelayCountries: relayCountries,
+		PaymentsURL:    cfg.Relay.PaymentsURL,
 		Limitation: &nip11.RelayLimitationDocument{
 			MaxMessageLength: maxContentLength, // Use actual configured content length
 			MaxSubscriptions: MaxSubscriptions, // Use constant (configurable via config if needed)
-			MaxLimit:         MaxLimit,         // Use constant (configurable via config if needed)
+			MaxLimit:         maxLimit,         // Use configured filter limit cap
 			MaxSubidLength:   MaxSubIDLength,   // Use constant (configurable via config if needed)
 			MaxEventTags:     MaxEventTags,     // Use constant (configurable via config if needed)
 			MaxContentLength: maxContentLength, // Use actual configured content length
 			MinPowDifficulty: cfg.Relay.MinPowDifficulty, // Use configured PoW difficulty (NIP-13)
-			AuthRequired:     AuthRequired,     // Use constant (configurable via config if needed)
-			PaymentRequired:  PaymentRequired,  // Use constant (configurable via config if needed)
-			RestrictedWrites: RestrictedWrites, // Use constant (configurable via config if needed)
+			AuthRequired:     AuthRequired || cfg.Relay.AuthRequired || cfg.Relay.AccessMode == "private" || cfg.Relay.DMInbox.Enabled, // NIP-17 DM inboxes and private relays require AUTH to read
+			PaymentRequired:  PaymentRequired || cfg.Relay.PaymentRequired,
+			RestrictedWrites: RestrictedWrites || cfg.Relay.AccessMode == "members" || cfg.Relay.AccessMode == "private" || cfg.Relay.DMInbox.Enabled || (cfg.NIP05.Enabled && cfg.NIP05.RequireForWrite), // NIP-43 members-only relays, DM inboxes and required NIP-05 restrict writes
 		},
 	}
 }
@@ -0,0 +1,384 @@
+// Package geoip reads MaxMind DB (.mmdb) files such as GeoLite2-Country and
+// GeoLite2-ASN, enough to look up the country and autonomous system of a
+// client IP. The whole file is read into memory on Open.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata section at the end of the file.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the size of the zero block between the search tree
+// and the data section.
+const dataSectionSeparator = 16
+
+// Reader looks up records in a MaxMind DB.
+type Reader struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint // node of ::/96 in IPv6 trees
+	dbType     string
+}
+
+// Open reads the database at path.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes parses a database held in memory.
+func FromBytes(buf []byte) (*Reader, error) {
+	start := bytes.LastIndex(buf, metadataMarker)
+	if start < 0 {
+		return nil, fmt.Errorf("not a MaxMind DB: metadata not found")
+	}
+	meta, _, err := decoder{buf: buf[start+len(metadataMarker):]}.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid metadata: not a map")
+	}
+
+	r := &Reader{
+		nodeCount:  uintField(m, "node_count"),
+		recordSize: uintField(m, "record_size"),
+		ipVersion:  uintField(m, "ip_version"),
+	}
+	r.dbType, _ = m["database_type"].(string)
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", r.ipVersion)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(start) {
+		return nil, fmt.Errorf("search tree larger than the file")
+	}
+	r.tree = buf[:treeSize]
+	r.data = buf[treeSize+dataSectionSeparator : start]
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// DatabaseType returns the database_type of the metadata, such as
+// "GeoLite2-Country".
+func (r *Reader) DatabaseType() string {
+	return r.dbType
+}
+
+// Lookup returns the record for ip, or nil when the database has none.
+func (r *Reader) Lookup(ip net.IP) (map[string]interface{}, error) {
+	addr := ip.To4()
+	node := uint(0)
+	if addr != nil && r.ipVersion == 6 {
+		node = r.ipv4Start
+	} else if addr == nil {
+		if r.ipVersion == 4 {
+			return nil, nil
+		}
+		addr = ip.To16()
+		if addr == nil {
+			return nil, fmt.Errorf("invalid IP address")
+		}
+	}
+
+	for i := 0; i < len(addr)*8 && node < r.nodeCount; i++ {
+		bit := uint(addr[i/8]>>(7-uint(i%8))) & 1
+		node = r.record(node, bit)
+	}
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, fmt.Errorf("invalid search tree")
+	}
+
+	offset := node - r.nodeCount - dataSectionSeparator
+	if offset >= uint(len(r.data)) {
+		return nil, fmt.Errorf("record outside the data section")
+	}
+	v, _, err := decoder{buf: r.data}.decode(offset)
+	if err != nil {
+		return nil, err
+	}
+	rec, _ := v.(map[string]interface{})
+	return rec, nil
+}
+
+// Country returns the ISO 3166-1 code of the country ip is located in,
+// falling back to the country it is registered in, or "" when unknown.
+func (r *Reader) Country(ip net.IP) (string, error) {
+	rec, err := r.Lookup(ip)
+	if err != nil || rec == nil {
+		return "", err
+	}
+	for _, key := range []string{"country", "registered_country"} {
+		if c, ok := rec[key].(map[string]interface{}); ok {
+			if code, ok := c["iso_code"].(string); ok && code != "" {
+				return code, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// ASN returns the autonomous system number ip belongs to, or 0 when unknown.
+func (r *Reader) ASN(ip net.IP) (uint, error) {
+	rec, err := r.Lookup(ip)
+	if err != nil || rec == nil {
+		return 0, err
+	}
+	return uintField(rec, "autonomous_system_number"), nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (r *Reader) record(node, bit uint) uint {
+	size := r.recordSize / 4 // bytes per node
+	b := r.tree[node*size : (node+1)*size]
+	switch r.recordSize {
+	case 24:
+		if bit == 0 {
+			return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3])<<16 | uint(b[4])<<8 | uint(b[5])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		if bit == 0 {
+			return uint(binary.BigEndian.Uint32(b[0:4]))
+		}
+		return uint(binary.BigEndian.Uint32(b[4:8]))
+	}
+}
+
+// MaxMind DB data types.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// decoder decodes values of a data section; pointers are offsets into buf.
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at offset and the offset following it.
+func (d decoder) decode(offset uint) (interface{}, uint, error) {
+	return d.decodeDepth(offset, 0)
+}
+
+func (d decoder) decodeDepth(offset uint, depth int) (interface{}, uint, error) {
+	if depth > 64 {
+		return nil, 0, fmt.Errorf("data nested too deeply")
+	}
+	ctrl, err := d.byteAt(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		target, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decodeDepth(target, depth+1)
+		return v, next, err
+	}
+	if typ == typeExtended {
+		ext, err := d.byteAt(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		typ = 7 + uint(ext)
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		b, err := d.slice(offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch n {
+		case 1:
+			size = 29 + uint(b[0])
+		case 2:
+			size = 285 + (uint(b[0])<<8 | uint(b[1]))
+		case 3:
+			size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+		}
+		offset += n
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decodeDepth(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key is not a string")
+			}
+			v, next, err := d.decodeDepth(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decodeDepth(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+
+	b, err := d.slice(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += size
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeUint16, typeUint32, typeUint64, typeUint128:
+		if size > 8 {
+			// Only IPv6 network numbers need 128 bits; they are not read here
+			return append([]byte(nil), b...), offset, nil
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case typeInt32:
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int64(int32(n)), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", typ)
+}
+
+// pointer decodes the pointer whose control byte is ctrl and whose payload
+// starts at offset, returning its target and the offset following it.
+func (d decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3)&0x3 + 1
+	b, err := d.slice(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	v := uint(ctrl & 0x7)
+	var target uint
+	switch n {
+	case 1:
+		target = v<<8 | uint(b[0])
+	case 2:
+		target = (v<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 3:
+		target = (v<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	case 4:
+		target = uint(binary.BigEndian.Uint32(b))
+	}
+	return target, offset + n, nil
+}
+
+func (d decoder) byteAt(offset uint) (byte, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, fmt.Errorf("unexpected end of data")
+	}
+	return d.buf[offset], nil
+}
+
+func (d decoder) slice(offset, n uint) ([]byte, error) {
+	if offset+n > uint(len(d.buf)) || offset+n < offset {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	return d.buf[offset : offset+n], nil
+}
+
+// uintField returns the unsigned integer m[key], or 0.
+func uintField(m map[string]interface{}, key string) uint {
+	switch v := m[key].(type) {
+	case uint64:
+		return uint(v)
+	case int64:
+		if v > 0 {
+			return uint(v)
+		}
+	}
+	return 0
+}
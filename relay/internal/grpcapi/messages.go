@@ -0,0 +1,218 @@
+package grpcapi
+
+import (
+	"fmt"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Protobuf encoding of the relay.proto messages, written against protowire
+// so the relay needs no generated code.
+
+// appendEvent appends evt encoded as an Event message.
+func appendEvent(b []byte, evt *nostr.Event) []byte {
+	b = appendString(b, 1, evt.ID)
+	b = appendString(b, 2, evt.PubKey)
+	b = appendVarint(b, 3, uint64(evt.CreatedAt))
+	b = appendVarint(b, 4, uint64(int32(evt.Kind)))
+	for _, tag := range evt.Tags {
+		var t []byte
+		for _, v := range tag {
+			t = protowire.AppendTag(t, 1, protowire.BytesType)
+			t = protowire.AppendString(t, v)
+		}
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, t)
+	}
+	b = appendString(b, 6, evt.Content)
+	b = appendString(b, 7, evt.Sig)
+	return b
+}
+
+// appendPublishResult appends a PublishResult message.
+func appendPublishResult(b []byte, accepted bool, message string) []byte {
+	if accepted {
+		b = appendVarint(b, 1, 1)
+	}
+	return appendString(b, 2, message)
+}
+
+// parseEvent decodes an Event message.
+func parseEvent(b []byte) (nostr.Event, error) {
+	var evt nostr.Event
+	err := parseFields(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			evt.ID = string(v)
+		case num == 2 && typ == protowire.BytesType:
+			evt.PubKey = string(v)
+		case num == 3 && typ == protowire.VarintType:
+			evt.CreatedAt = nostr.Timestamp(int64(n))
+		case num == 4 && typ == protowire.VarintType:
+			evt.Kind = int(int32(n))
+		case num == 5 && typ == protowire.BytesType:
+			tag, err := parseStrings(v, 1)
+			if err != nil {
+				return err
+			}
+			evt.Tags = append(evt.Tags, nostr.Tag(tag))
+		case num == 6 && typ == protowire.BytesType:
+			evt.Content = string(v)
+		case num == 7 && typ == protowire.BytesType:
+			evt.Sig = string(v)
+		}
+		return nil
+	})
+	if evt.Tags == nil {
+		evt.Tags = nostr.Tags{}
+	}
+	return evt, err
+}
+
+// parseQueryRequest decodes a QueryRequest message into its filters.
+func parseQueryRequest(b []byte) ([]nostr.Filter, error) {
+	var filters []nostr.Filter
+	err := parseFields(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		f, err := parseFilter(v)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, f)
+		return nil
+	})
+	return filters, err
+}
+
+// parseFilter decodes a Filter message.
+func parseFilter(b []byte) (nostr.Filter, error) {
+	var f nostr.Filter
+	err := parseFields(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			f.IDs = append(f.IDs, string(v))
+		case num == 2 && typ == protowire.BytesType:
+			f.Authors = append(f.Authors, string(v))
+		case num == 3 && typ == protowire.VarintType:
+			f.Kinds = append(f.Kinds, int(int32(n)))
+		case num == 3 && typ == protowire.BytesType:
+			// Packed repeated kinds
+			for len(v) > 0 {
+				k, m := protowire.ConsumeVarint(v)
+				if m < 0 {
+					return protowire.ParseError(m)
+				}
+				f.Kinds = append(f.Kinds, int(int32(k)))
+				v = v[m:]
+			}
+		case num == 4 && typ == protowire.VarintType && n != 0:
+			since := nostr.Timestamp(int64(n))
+			f.Since = &since
+		case num == 5 && typ == protowire.VarintType && n != 0:
+			until := nostr.Timestamp(int64(n))
+			f.Until = &until
+		case num == 6 && typ == protowire.VarintType:
+			f.Limit = int(int32(n))
+		case num == 7 && typ == protowire.BytesType:
+			name, values, err := parseTagFilter(v)
+			if err != nil {
+				return err
+			}
+			if f.Tags == nil {
+				f.Tags = make(nostr.TagMap)
+			}
+			f.Tags[name] = append(f.Tags[name], values...)
+		case num == 8 && typ == protowire.BytesType:
+			f.Search = string(v)
+		}
+		return nil
+	})
+	return f, err
+}
+
+// parseTagFilter decodes a TagFilter message.
+func parseTagFilter(b []byte) (string, []string, error) {
+	var name string
+	var values []string
+	err := parseFields(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			name = string(v)
+		case 2:
+			values = append(values, string(v))
+		}
+		return nil
+	})
+	if err == nil && name == "" {
+		err = fmt.Errorf("tag filter without a name")
+	}
+	return name, values, err
+}
+
+// parseStrings decodes the repeated string field num of a message.
+func parseStrings(b []byte, num protowire.Number) ([]string, error) {
+	values := []string{}
+	err := parseFields(b, func(n protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if n == num && typ == protowire.BytesType {
+			values = append(values, string(v))
+		}
+		return nil
+	})
+	return values, err
+}
+
+// parseFields calls fn for every field of a message with its length-delimited
+// bytes or varint value. Other wire types are skipped.
+func parseFields(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, m := protowire.ConsumeTag(b)
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		b = b[m:]
+
+		var v []byte
+		var n uint64
+		switch typ {
+		case protowire.BytesType:
+			v, m = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			n, m = protowire.ConsumeVarint(b)
+		default:
+			m = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		b = b[m:]
+
+		if typ == protowire.BytesType || typ == protowire.VarintType {
+			if err := fn(num, typ, v, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
@@ -0,0 +1,324 @@
+// Package grpcapi serves the relay's internal gRPC API (relay.proto) so
+// sidecar services can publish, query and follow events without the public
+// websocket and its JSON encoding.
+//
+// The service runs on net/http over plaintext HTTP/2 and speaks the gRPC wire
+// protocol directly: standard gRPC clients generated from relay.proto work
+// unchanged. Callers are trusted services; publishing goes through event
+// validation but not the per-connection policies of the websocket.
+package grpcapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/domain"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// servicePath prefixes the method paths of the Relay service.
+const servicePath = "/shugur.relay.v1.Relay/"
+
+// maxMessageSize bounds a request message, like gRPC's default receive limit.
+const maxMessageSize = 4 << 20
+
+// gRPC status codes used by the service.
+const (
+	codeOK                = 0
+	codeInvalidArgument   = 3
+	codeResourceExhausted = 8
+	codeUnimplemented     = 12
+	codeInternal          = 13
+	codeUnavailable       = 14
+	codeUnauthenticated   = 16
+)
+
+// statusError is a failed call with its gRPC status.
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string { return e.message }
+
+func newStatus(code int, format string, args ...interface{}) *statusError {
+	return &statusError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// service implements the Relay service on a node.
+type service struct {
+	node     domain.NodeInterface
+	token    string
+	maxLimit int
+}
+
+// StartServer serves the gRPC API on its own listener until ctx is canceled.
+func StartServer(ctx context.Context, cfg *config.Config, node domain.NodeInterface) error {
+	gc := cfg.GRPC
+	if !gc.Enabled {
+		return nil
+	}
+
+	svc := &service{node: node, token: gc.Token, maxLimit: cfg.Relay.ThrottlingConfig.MaxLimit}
+	if svc.maxLimit <= 0 {
+		svc.maxLimit = constants.MaxLimit
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(servicePath+"Publish", svc.handle(svc.publish))
+	mux.HandleFunc(servicePath+"Query", svc.handle(svc.query))
+	mux.HandleFunc(servicePath+"Subscribe", svc.handle(func(r *http.Request, req []byte, send func([]byte) error) error {
+		return svc.subscribe(ctx, r, req, send)
+	}))
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	addr := net.JoinHostPort(gc.Host, strconv.Itoa(gc.Port))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		Protocols:         &protocols,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		logger.Info("gRPC listener started",
+			zap.String("address", addr),
+			zap.Bool("token", gc.Token != ""))
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("gRPC listener failed", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// handle adapts a method to HTTP: it checks the call, reads the request
+// message and writes the response messages and status.
+func (svc *service) handle(method func(r *http.Request, req []byte, send func([]byte) error) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+
+		wrote := false
+		finish := func(err error) {
+			code, message := codeOK, ""
+			if err != nil {
+				code, message = codeInternal, err.Error()
+				if se, ok := err.(*statusError); ok {
+					code = se.code
+				}
+			}
+			// Calls that failed before any message answer with trailers only
+			prefix := http.TrailerPrefix
+			if !wrote {
+				prefix = ""
+			}
+			w.Header().Set(prefix+"Grpc-Status", strconv.Itoa(code))
+			if message != "" {
+				w.Header().Set(prefix+"Grpc-Message", encodeGRPCMessage(message))
+			}
+			if !wrote {
+				w.WriteHeader(http.StatusOK)
+			}
+		}
+
+		if svc.token != "" {
+			auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(auth), []byte(svc.token)) != 1 {
+				finish(newStatus(codeUnauthenticated, "invalid or missing token"))
+				return
+			}
+		}
+		req, err := readMessage(r.Body)
+		if err != nil {
+			finish(err)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		send := func(msg []byte) error {
+			if !wrote {
+				w.WriteHeader(http.StatusOK)
+				wrote = true
+			}
+			var header [5]byte
+			binary.BigEndian.PutUint32(header[1:], uint32(len(msg)))
+			if _, err := w.Write(header[:]); err != nil {
+				return err
+			}
+			if _, err := w.Write(msg); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		finish(method(r, req, send))
+	}
+}
+
+// readMessage reads the single length-prefixed request message of a call.
+func readMessage(body io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(body, header[:]); err != nil {
+		return nil, newStatus(codeInvalidArgument, "missing request message")
+	}
+	if header[0] != 0 {
+		return nil, newStatus(codeUnimplemented, "compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxMessageSize {
+		return nil, newStatus(codeResourceExhausted, "request message larger than %d bytes", maxMessageSize)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, newStatus(codeInvalidArgument, "truncated request message")
+	}
+	return msg, nil
+}
+
+// publish implements Relay.Publish.
+func (svc *service) publish(r *http.Request, req []byte, send func([]byte) error) error {
+	evt, err := parseEvent(req)
+	if err != nil {
+		return newStatus(codeInvalidArgument, "invalid event: %v", err)
+	}
+
+	valid, msg, err := svc.node.GetValidator().ValidateAndProcessEvent(r.Context(), evt)
+	switch {
+	case err != nil:
+		valid, msg = false, "error: "+err.Error()
+	case valid && msg != relay.MsgAlreadyDeleted:
+		if !svc.node.GetEventProcessor().QueueEventContext(r.Context(), evt) {
+			return newStatus(codeUnavailable, "server busy, try again")
+		}
+	}
+	return send(appendPublishResult(nil, valid, msg))
+}
+
+// query implements Relay.Query.
+func (svc *service) query(r *http.Request, req []byte, send func([]byte) error) error {
+	filters, err := svc.filters(req)
+	if err != nil {
+		return err
+	}
+	for _, f := range filters {
+		if f.Limit <= 0 {
+			f.Limit = svc.maxLimit
+		}
+		events, err := svc.node.DB().GetEvents(storage.WithReplicaRead(r.Context()), f)
+		if err != nil {
+			logger.Warn("gRPC query failed", zap.Error(err))
+			return newStatus(codeInternal, "query failed")
+		}
+		for i := range events {
+			if err := send(appendEvent(nil, &events[i])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// subscribe implements Relay.Subscribe; it ends with the call or with ctx,
+// the server's lifetime.
+func (svc *service) subscribe(ctx context.Context, r *http.Request, req []byte, send func([]byte) error) error {
+	filters, err := svc.filters(req)
+	if err != nil {
+		return err
+	}
+	dispatcher := svc.node.GetEventDispatcher()
+	if dispatcher == nil {
+		return newStatus(codeUnavailable, "event dispatcher not available")
+	}
+	clientID := "grpc-" + randomID()
+	live := dispatcher.AddClient(clientID)
+	defer dispatcher.RemoveClient(clientID)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return newStatus(codeUnavailable, "relay shutting down")
+		case <-r.Context().Done():
+			return nil
+		case evt := <-live:
+			if evt == nil {
+				return newStatus(codeUnavailable, "event dispatcher stopped")
+			}
+			for _, f := range filters {
+				if storage.MatchesFilter(f, evt) {
+					if err := send(appendEvent(nil, evt)); err != nil {
+						return err
+					}
+					break
+				}
+			}
+		}
+	}
+}
+
+// filters decodes and validates the filters of a QueryRequest.
+func (svc *service) filters(req []byte) ([]nostr.Filter, error) {
+	filters, err := parseQueryRequest(req)
+	if err != nil {
+		return nil, newStatus(codeInvalidArgument, "invalid query request: %v", err)
+	}
+	if len(filters) == 0 || len(filters) > constants.MaxFilters {
+		return nil, newStatus(codeInvalidArgument, "between 1 and %d filters are allowed", constants.MaxFilters)
+	}
+	for _, f := range filters {
+		if f.Limit > svc.maxLimit {
+			return nil, newStatus(codeInvalidArgument, "limit %d exceeds the maximum of %d", f.Limit, svc.maxLimit)
+		}
+		if err := svc.node.GetValidator().ValidateFilter(f); err != nil {
+			return nil, newStatus(codeInvalidArgument, "invalid filter: %v", err)
+		}
+	}
+	return filters, nil
+}
+
+// encodeGRPCMessage percent-encodes a grpc-message value.
+func encodeGRPCMessage(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// randomID returns a random dispatcher client ID suffix.
+func randomID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
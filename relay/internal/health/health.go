@@ -68,6 +68,7 @@ type DatabaseStats struct {
 type HealthChecker struct {
 	db       DatabaseInterface
 	node     NodeInterface
+	pipeline PipelineInterface // optional, enables queue and dispatcher probes
 	cfg      *config.Config
 	logger   *zap.Logger
 	startTime time.Time
@@ -76,10 +77,11 @@ type HealthChecker struct {
 }
 
 // NewHealthChecker creates a new health checker
-func NewHealthChecker(db DatabaseInterface, node NodeInterface, cfg *config.Config, logger *zap.Logger, version string) *HealthChecker {
+func NewHealthChecker(db DatabaseInterface, node NodeInterface, pipeline PipelineInterface, cfg *config.Config, logger *zap.Logger, version string) *HealthChecker {
 	return &HealthChecker{
 		db:        db,
 		node:      node,
+		pipeline:  pipeline,
 		cfg:       cfg,
 		logger:    logger.Named("health"),
 		startTime: time.Now(),
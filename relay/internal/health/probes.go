@@ -0,0 +1,234 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Shugur-Network/relay/internal/constants"
+)
+
+// Probe thresholds
+const (
+	queueDegradedPercent  = 75 // queue fill level reported as degraded
+	queueSaturatedPercent = 95 // queue fill level that takes the node out of rotation
+	dispatcherStallAfter  = 30 * time.Second
+)
+
+// QueueStats is the fill level of one event queue
+type QueueStats struct {
+	Name     string
+	Length   int
+	Capacity int
+}
+
+// PipelineInterface defines the event pipeline operations needed for probes
+type PipelineInterface interface {
+	EventQueues() []QueueStats
+	DispatcherRunning() bool
+	DispatcherHeartbeat() time.Time
+	DispatcherBuffer() QueueStats
+}
+
+// ProbeResponse is the body returned by /healthz and /readyz
+type ProbeResponse struct {
+	Status    HealthStatus       `json:"status"`
+	Timestamp time.Time          `json:"timestamp"`
+	Version   string             `json:"version"`
+	Uptime    string             `json:"uptime"`
+	Checks    []*ComponentStatus `json:"checks"`
+}
+
+// CheckLiveness reports whether the process is able to make progress. It does
+// not touch the database so a slow dependency never gets the relay restarted.
+func (h *HealthChecker) CheckLiveness() *ProbeResponse {
+	checks := []*ComponentStatus{
+		{
+			Name:    "process",
+			Status:  StatusHealthy,
+			Message: "Process is running",
+			Details: map[string]interface{}{"goroutines": runtime.NumGoroutine()},
+		},
+	}
+	if h.pipeline != nil {
+		// Only a stalled loop fails liveness; a stopped dispatcher is a readiness concern
+		dispatcher := h.checkDispatcher()
+		if dispatcher.Status == StatusUnhealthy && h.pipeline.DispatcherRunning() {
+			checks = append(checks, dispatcher)
+		}
+	}
+	return h.probeResponse(checks)
+}
+
+// CheckReadiness reports whether the relay should receive traffic: the
+// database answers, event queues have room and the dispatcher is broadcasting.
+func (h *HealthChecker) CheckReadiness(ctx context.Context) *ProbeResponse {
+	checks := []*ComponentStatus{h.checkDatabaseReachable(ctx)}
+	if h.pipeline != nil {
+		checks = append(checks, h.checkQueues(), h.checkDispatcher())
+	}
+	return h.probeResponse(checks)
+}
+
+// checkDatabaseReachable pings the database, bounded by the probe context
+func (h *HealthChecker) checkDatabaseReachable(ctx context.Context) *ComponentStatus {
+	status := &ComponentStatus{Name: "database", Details: make(map[string]interface{})}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- h.db.Ping() }()
+
+	select {
+	case err := <-done:
+		status.Details["latency_ms"] = time.Since(start).Milliseconds()
+		if err != nil {
+			status.Status = StatusUnhealthy
+			status.Message = "Database connection failed"
+			status.Details["error"] = err.Error()
+			return status
+		}
+	case <-ctx.Done():
+		status.Status = StatusUnhealthy
+		status.Message = "Database ping timed out"
+		return status
+	}
+
+	status.Status = StatusHealthy
+	status.Message = "Database is reachable"
+	return status
+}
+
+// checkQueues reports the fill level of the event processing queues
+func (h *HealthChecker) checkQueues() *ComponentStatus {
+	status := &ComponentStatus{Name: "event_queues", Status: StatusHealthy, Details: make(map[string]interface{})}
+
+	worst := 0.0
+	worstName := ""
+	for _, q := range h.pipeline.EventQueues() {
+		percent := fillPercent(q)
+		status.Details[q.Name] = map[string]interface{}{
+			"length":          q.Length,
+			"capacity":        q.Capacity,
+			"utilization_pct": percent,
+		}
+		if percent > worst {
+			worst, worstName = percent, q.Name
+		}
+	}
+
+	switch {
+	case worst >= queueSaturatedPercent:
+		status.Status = StatusUnhealthy
+		status.Message = fmt.Sprintf("Event queue %s saturated (%.1f%%)", worstName, worst)
+	case worst >= queueDegradedPercent:
+		status.Status = StatusDegraded
+		status.Message = fmt.Sprintf("Event queue %s filling up (%.1f%%)", worstName, worst)
+	default:
+		status.Message = "Event queues have capacity"
+	}
+	return status
+}
+
+// checkDispatcher reports whether the real-time dispatcher is broadcasting
+func (h *HealthChecker) checkDispatcher() *ComponentStatus {
+	status := &ComponentStatus{Name: "dispatcher", Details: make(map[string]interface{})}
+
+	buffer := h.pipeline.DispatcherBuffer()
+	status.Details["buffered"] = buffer.Length
+	status.Details["capacity"] = buffer.Capacity
+
+	if !h.pipeline.DispatcherRunning() {
+		status.Status = StatusUnhealthy
+		status.Message = "Event dispatcher is not running"
+		return status
+	}
+
+	since := time.Since(h.pipeline.DispatcherHeartbeat())
+	status.Details["last_heartbeat_ms"] = since.Milliseconds()
+
+	switch {
+	case since > dispatcherStallAfter:
+		status.Status = StatusUnhealthy
+		status.Message = fmt.Sprintf("Event dispatcher stalled for %s", since.Round(time.Second))
+	case fillPercent(buffer) >= queueSaturatedPercent:
+		status.Status = StatusDegraded
+		status.Message = "Event dispatcher buffer is full"
+	default:
+		status.Status = StatusHealthy
+		status.Message = "Event dispatcher is running"
+	}
+	return status
+}
+
+// probeResponse wraps probe checks with the overall status
+func (h *HealthChecker) probeResponse(checks []*ComponentStatus) *ProbeResponse {
+	return &ProbeResponse{
+		Status:    h.determineOverallStatus(checks),
+		Timestamp: time.Now(),
+		Version:   h.version,
+		Uptime:    h.formatUptime(time.Since(h.startTime)),
+		Checks:    checks,
+	}
+}
+
+// HandleLiveness is the HTTP handler for /healthz
+func (h *HealthChecker) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.writeProbe(w, r, h.CheckLiveness())
+}
+
+// HandleReadiness is the HTTP handler for /readyz
+func (h *HealthChecker) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), constants.HealthCheckTimeout*time.Second)
+	defer cancel()
+
+	h.writeProbe(w, r, h.CheckReadiness(ctx))
+}
+
+// writeProbe writes a probe response; degraded still counts as passing
+func (h *HealthChecker) writeProbe(w http.ResponseWriter, r *http.Request, resp *ProbeResponse) {
+	statusCode := http.StatusOK
+	if resp.Status == StatusUnhealthy {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(statusCode)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.Error("Failed to encode probe response", zap.Error(err))
+		return
+	}
+
+	if statusCode != http.StatusOK {
+		h.logger.Warn("Probe failed",
+			zap.String("path", r.URL.Path),
+			zap.String("status", string(resp.Status)),
+			zap.String("client_ip", r.RemoteAddr))
+	}
+}
+
+// fillPercent returns how full a queue is
+func fillPercent(q QueueStats) float64 {
+	if q.Capacity == 0 {
+		return 0
+	}
+	return float64(q.Length) / float64(q.Capacity) * 100
+}
@@ -0,0 +1,74 @@
+// Package index holds what the in-memory indexes of stored events share:
+// the NIP-01 order in which a replaceable event replaces another, and
+// keeping an index in step with NIP-09 deletions and NIP-62 vanish requests.
+package index
+
+import (
+	"slices"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Observer is an index kept in step with stored events by Observe.
+type Observer interface {
+	// Add indexes an event of one of the observed kinds.
+	Add(evt *nostr.Event) bool
+	// Remove drops what a NIP-09 deletion covers: the events whose ID is in
+	// ids (its e tags) and the addresses in addrs (its a tags).
+	Remove(deletion *nostr.Event, ids, addrs []string)
+	// RemoveAuthor drops everything of a pubkey, after a NIP-62 vanish request.
+	RemoveAuthor(pubkey string)
+}
+
+// Observe keeps o in step with a newly stored event: events of kinds are
+// added, deletions remove what they cover and vanish requests everything of
+// their author.
+func Observe(o Observer, evt *nostr.Event, kinds ...int) {
+	switch {
+	case slices.Contains(kinds, evt.Kind):
+		o.Add(evt)
+	case evt.Kind == 5:
+		if ids, addrs := DeletionTargets(evt); len(ids) > 0 || len(addrs) > 0 {
+			o.Remove(evt, ids, addrs)
+		}
+	case evt.Kind == 62:
+		o.RemoveAuthor(evt.PubKey)
+	}
+}
+
+// DeletionTargets returns the event IDs (e tags) and addresses (a tags) a
+// NIP-09 deletion names.
+func DeletionTargets(deletion *nostr.Event) (ids, addrs []string) {
+	for _, tag := range deletion.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "e":
+			ids = append(ids, tag[1])
+		case "a":
+			addrs = append(addrs, tag[1])
+		}
+	}
+	return ids, addrs
+}
+
+// Deletes reports whether a deletion naming ids and addrs covers evt,
+// indexed under address ("<kind>:<pubkey>:<d>"): the deletion is by the
+// same author and names the event's ID, or its address with a version no
+// newer than the deletion.
+func Deletes(deletion *nostr.Event, ids, addrs []string, evt *nostr.Event, address string) bool {
+	if evt.PubKey != deletion.PubKey {
+		return false
+	}
+	return slices.Contains(ids, evt.ID) || (slices.Contains(addrs, address) && evt.CreatedAt <= deletion.CreatedAt)
+}
+
+// Newer reports whether evt replaces current: it is more recent or, at the
+// same second, has the lower ID (NIP-01).
+func Newer(evt, current *nostr.Event) bool {
+	if evt.CreatedAt != current.CreatedAt {
+		return evt.CreatedAt > current.CreatedAt
+	}
+	return evt.ID < current.ID
+}
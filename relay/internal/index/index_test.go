@@ -0,0 +1,87 @@
+package index
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// recorder is an Observer recording its calls.
+type recorder struct {
+	added   []string
+	removed [][]string
+	authors []string
+}
+
+func (r *recorder) Add(evt *nostr.Event) bool {
+	r.added = append(r.added, evt.ID)
+	return true
+}
+
+func (r *recorder) Remove(_ *nostr.Event, ids, addrs []string) {
+	r.removed = append(r.removed, append(ids, addrs...))
+}
+
+func (r *recorder) RemoveAuthor(pubkey string) {
+	r.authors = append(r.authors, pubkey)
+}
+
+func TestObserve(t *testing.T) {
+	r := &recorder{}
+	Observe(r, &nostr.Event{ID: "listing", Kind: 30402}, 30402)
+	Observe(r, &nostr.Event{ID: "note", Kind: 1}, 30402)
+	Observe(r, &nostr.Event{Kind: 5, Tags: nostr.Tags{{"e", "x"}, {"a", "30402:pk:d"}, {"e"}}}, 30402)
+	Observe(r, &nostr.Event{Kind: 5, Tags: nostr.Tags{{"k", "30402"}}}, 30402)
+	Observe(r, &nostr.Event{Kind: 62, PubKey: "pk"}, 30402)
+
+	if !slices.Equal(r.added, []string{"listing"}) {
+		t.Errorf("added = %v, want [listing]", r.added)
+	}
+	if len(r.removed) != 1 || !slices.Equal(r.removed[0], []string{"x", "30402:pk:d"}) {
+		t.Errorf("removed = %v, want one deletion of x and 30402:pk:d", r.removed)
+	}
+	if !slices.Equal(r.authors, []string{"pk"}) {
+		t.Errorf("vanished authors = %v, want [pk]", r.authors)
+	}
+}
+
+func TestDeletes(t *testing.T) {
+	author := strings.Repeat("aa", 32)
+	evt := &nostr.Event{ID: "v1", PubKey: author, Kind: 30402, CreatedAt: 100}
+	address := "30402:" + author + ":d"
+
+	for _, tc := range []struct {
+		name     string
+		deletion *nostr.Event
+		ids      []string
+		addrs    []string
+		want     bool
+	}{
+		{"by id", &nostr.Event{PubKey: author, CreatedAt: 50}, []string{"v1"}, nil, true},
+		{"by address", &nostr.Event{PubKey: author, CreatedAt: 100}, nil, []string{address}, true},
+		{"address before the version", &nostr.Event{PubKey: author, CreatedAt: 99}, nil, []string{address}, false},
+		{"other author", &nostr.Event{PubKey: strings.Repeat("bb", 32), CreatedAt: 200}, []string{"v1"}, []string{address}, false},
+		{"other event", &nostr.Event{PubKey: author, CreatedAt: 200}, []string{"v2"}, []string{"30402:" + author + ":other"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Deletes(tc.deletion, tc.ids, tc.addrs, evt, address); got != tc.want {
+				t.Errorf("Deletes = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewer(t *testing.T) {
+	old := &nostr.Event{ID: "b", CreatedAt: 100}
+	if !Newer(&nostr.Event{ID: "c", CreatedAt: 101}, old) {
+		t.Error("a later event does not replace an earlier one")
+	}
+	if !Newer(&nostr.Event{ID: "a", CreatedAt: 100}, old) || Newer(&nostr.Event{ID: "c", CreatedAt: 100}, old) {
+		t.Error("ties do not go to the lower ID")
+	}
+	if Newer(old, old) {
+		t.Error("an event replaces itself")
+	}
+}
@@ -0,0 +1,213 @@
+// Package keypackages indexes the NIP-EE MLS KeyPackages (kind 443) stored
+// on the relay by pubkey and ciphersuite, so clients inviting a user to an
+// MLS group can fetch a fresh one without scanning events. Identical
+// republished packages are recognised, a pubkey keeps at most a configured
+// number of packages and packages past their maximum age expire; the index
+// returns the packages it drops so the caller can delete them from storage.
+package keypackages
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// KindKeyPackage is the NIP-EE KeyPackage event kind.
+const KindKeyPackage = 443
+
+const defaultMaxPerPubkey = 10
+
+// Index holds the KeyPackages of every pubkey, newest first.
+type Index struct {
+	cfg config.KeyPackagesConfig
+
+	mu       sync.RWMutex
+	packages map[string][]*nostr.Event // pubkey -> packages, newest first
+}
+
+// New creates an empty index.
+func New(cfg config.KeyPackagesConfig) *Index {
+	if cfg.MaxPerPubkey <= 0 {
+		cfg.MaxPerPubkey = defaultMaxPerPubkey
+	}
+	return &Index{cfg: cfg, packages: make(map[string][]*nostr.Event)}
+}
+
+// Ciphersuite returns the mls_ciphersuite of a KeyPackage event.
+func Ciphersuite(evt *nostr.Event) string {
+	if tag := evt.Tags.GetFirst([]string{"mls_ciphersuite", ""}); tag != nil && len(*tag) >= 2 {
+		return (*tag)[1]
+	}
+	return ""
+}
+
+// Duplicate reports whether the author already has a KeyPackage with the
+// same content under another event ID. A KeyPackage is meant to be used
+// once, so serving copies of it would hand the same key to several inviters.
+func (ix *Index) Duplicate(evt *nostr.Event) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	for _, p := range ix.packages[evt.PubKey] {
+		if p.ID != evt.ID && p.Content == evt.Content {
+			return true
+		}
+	}
+	return false
+}
+
+// Add indexes a KeyPackage and returns the packages it supersedes: the
+// oldest of the author's beyond MaxPerPubkey.
+func (ix *Index) Add(evt *nostr.Event) []*nostr.Event {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	list := ix.packages[evt.PubKey]
+	for _, p := range list {
+		if p.ID == evt.ID {
+			return nil
+		}
+	}
+	list = append(list, evt)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt > list[j].CreatedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+
+	var superseded []*nostr.Event
+	if len(list) > ix.cfg.MaxPerPubkey {
+		superseded = append(superseded, list[ix.cfg.MaxPerPubkey:]...)
+		list = list[:ix.cfg.MaxPerPubkey:ix.cfg.MaxPerPubkey]
+	}
+	ix.packages[evt.PubKey] = list
+	return superseded
+}
+
+// Remove drops the author's packages with the given IDs, after a NIP-09
+// deletion; clients delete a KeyPackage once it has been used.
+func (ix *Index) Remove(pubkey string, ids []string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	list := ix.packages[pubkey]
+	kept := list[:0]
+	for _, p := range list {
+		if !containsID(ids, p.ID) {
+			kept = append(kept, p)
+		}
+	}
+	ix.setLocked(pubkey, kept)
+}
+
+// RemovePubkey drops every package of a pubkey, after a NIP-62 vanish request.
+func (ix *Index) RemovePubkey(pubkey string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	delete(ix.packages, pubkey)
+}
+
+// Observe keeps the index in step with a newly stored event and returns the
+// packages it supersedes.
+func (ix *Index) Observe(evt *nostr.Event) []*nostr.Event {
+	switch evt.Kind {
+	case KindKeyPackage:
+		return ix.Add(evt)
+	case 5:
+		var ids []string
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "e" {
+				ids = append(ids, tag[1])
+			}
+		}
+		if len(ids) > 0 {
+			ix.Remove(evt.PubKey, ids)
+		}
+	case 62:
+		ix.RemovePubkey(evt.PubKey)
+	}
+	return nil
+}
+
+// Lookup returns up to limit packages of pubkey, newest first, only those
+// of ciphersuite when it is not empty.
+func (ix *Index) Lookup(pubkey, ciphersuite string, limit int) []*nostr.Event {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	var found []*nostr.Event
+	for _, p := range ix.packages[pubkey] {
+		if ciphersuite != "" && Ciphersuite(p) != ciphersuite {
+			continue
+		}
+		if ix.expired(p, time.Now()) {
+			continue
+		}
+		found = append(found, p)
+		if len(found) == limit {
+			break
+		}
+	}
+	return found
+}
+
+// Expire drops and returns the packages older than MaxAge.
+func (ix *Index) Expire(now time.Time) []*nostr.Event {
+	if ix.cfg.MaxAge <= 0 {
+		return nil
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	var expired []*nostr.Event
+	for pubkey, list := range ix.packages {
+		kept := list[:0]
+		for _, p := range list {
+			if ix.expired(p, now) {
+				expired = append(expired, p)
+			} else {
+				kept = append(kept, p)
+			}
+		}
+		ix.setLocked(pubkey, kept)
+	}
+	return expired
+}
+
+// Len returns the number of indexed packages.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	n := 0
+	for _, list := range ix.packages {
+		n += len(list)
+	}
+	return n
+}
+
+// expired reports whether a package is older than MaxAge.
+func (ix *Index) expired(p *nostr.Event, now time.Time) bool {
+	return ix.cfg.MaxAge > 0 && now.Sub(p.CreatedAt.Time()) > ix.cfg.MaxAge
+}
+
+// setLocked stores the packages of pubkey, forgetting pubkeys left without
+// any. Must be called with mu held.
+func (ix *Index) setLocked(pubkey string, list []*nostr.Event) {
+	if len(list) == 0 {
+		delete(ix.packages, pubkey)
+		return
+	}
+	ix.packages[pubkey] = list
+}
+
+func containsID(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
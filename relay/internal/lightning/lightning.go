@@ -0,0 +1,84 @@
+// Package lightning creates and watches invoices on the lightning node that
+// receives payments for the relay: an LNbits wallet or an LND node through
+// its REST interface. Only invoices are created; the relay never pays.
+package lightning
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// maxResponse bounds the node responses read.
+const maxResponse = 64 * 1024
+
+// Invoice is a created invoice.
+type Invoice struct {
+	PaymentHash string // lowercase hex
+	Bolt11      string
+}
+
+// Status is the payment state of an invoice.
+type Status struct {
+	Paid     bool
+	Preimage string // lowercase hex, "" when the node does not tell
+}
+
+// Backend creates invoices and reports whether they were paid.
+type Backend interface {
+	// CreateInvoice creates an invoice of amountMsat committing to
+	// descriptionHash, valid for expiry.
+	CreateInvoice(ctx context.Context, amountMsat int64, descriptionHash []byte, expiry time.Duration) (Invoice, error)
+	// InvoiceStatus returns the state of the invoice with paymentHash.
+	InvoiceStatus(ctx context.Context, paymentHash string) (Status, error)
+}
+
+// New creates the backend cfg names.
+func New(cfg config.LightningConfig) (Backend, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("lightning backend URL is not set")
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+	baseURL := strings.TrimRight(cfg.URL, "/")
+
+	switch cfg.Type {
+	case config.LightningLNbits:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("LNbits needs the wallet's invoice key in API_KEY")
+		}
+		return &lnbits{url: baseURL, apiKey: cfg.APIKey, client: client}, nil
+	case config.LightningLND:
+		if cfg.Macaroon == "" {
+			return nil, fmt.Errorf("LND needs an invoice macaroon in MACAROON")
+		}
+		if cfg.TLSCert != "" {
+			pem, err := os.ReadFile(cfg.TLSCert)
+			if err != nil {
+				return nil, fmt.Errorf("reading LND TLS certificate: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in %s", cfg.TLSCert)
+			}
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+			client.Transport = transport
+		}
+		return &lnd{url: baseURL, macaroon: cfg.Macaroon, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown lightning backend %q", cfg.Type)
+	}
+}
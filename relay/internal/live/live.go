@@ -0,0 +1,353 @@
+// Package live tracks the NIP-53 live activities stored on the relay: live
+// streams (kind 30311) whose status is live and meeting spaces (kind 30312)
+// that are open, keeping only the latest version of each. Room presence
+// events (kind 10312) are counted towards the room they point at while they
+// are younger than the presence TTL, so a room's participant count decays as
+// listeners stop refreshing their presence. A live stream whose ends time has
+// passed, or whose host has not updated it for the stale-after duration, is
+// stale: it still says it is live but has ended in all but name (NIP-53
+// suggests an hour without an update), so it is no longer tracked.
+package live
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/index"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-53 event kinds.
+const (
+	KindLiveStream   = 30311
+	KindMeetingSpace = 30312
+	KindRoomPresence = 10312
+)
+
+const (
+	defaultPresenceTTL   = 5 * time.Minute
+	defaultMaxActivities = 10000
+	defaultStaleAfter    = time.Hour
+)
+
+// Reasons a live stream is stale.
+const (
+	StaleEnded    = "ended"    // its ends time has passed
+	StaleInactive = "inactive" // its host stopped updating it
+)
+
+// Activity is the latest version of a live stream or meeting space.
+type Activity struct {
+	Address   string   `json:"address"` // "<kind>:<pubkey>:<d>"
+	Kind      int      `json:"kind"`
+	Pubkey    string   `json:"pubkey"`
+	Title     string   `json:"title,omitempty"`
+	Summary   string   `json:"summary,omitempty"`
+	Image     string   `json:"image,omitempty"`
+	Streaming string   `json:"streaming,omitempty"` // live streams
+	Room      string   `json:"room,omitempty"`      // meeting spaces
+	Status    string   `json:"status"`
+	Starts    int64    `json:"starts,omitempty"`
+	Ends      int64    `json:"ends,omitempty"`
+	Hashtags  []string `json:"hashtags"`
+	// Participants and HandsRaised count the fresh room presences.
+	Participants int `json:"participants"`
+	HandsRaised  int `json:"hands_raised"`
+	// ReportedParticipants is the host's current_participants tag.
+	ReportedParticipants *int         `json:"reported_participants,omitempty"`
+	Event                *nostr.Event `json:"event"`
+}
+
+// ParseActivity reads a live stream or meeting space event.
+func ParseActivity(evt *nostr.Event) *Activity {
+	a := &Activity{
+		Address:  strconv.Itoa(evt.Kind) + ":" + evt.PubKey + ":" + evt.Tags.GetD(),
+		Kind:     evt.Kind,
+		Pubkey:   evt.PubKey,
+		Hashtags: []string{},
+		Event:    evt,
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "title":
+			a.Title = tag[1]
+		case "summary":
+			a.Summary = tag[1]
+		case "image":
+			a.Image = tag[1]
+		case "streaming":
+			a.Streaming = tag[1]
+		case "room":
+			a.Room = tag[1]
+		case "status":
+			a.Status = tag[1]
+		case "starts":
+			a.Starts, _ = strconv.ParseInt(tag[1], 10, 64)
+		case "ends":
+			a.Ends, _ = strconv.ParseInt(tag[1], 10, 64)
+		case "t":
+			a.Hashtags = append(a.Hashtags, strings.ToLower(tag[1]))
+		case "current_participants":
+			if n, err := strconv.Atoi(tag[1]); err == nil && n >= 0 {
+				a.ReportedParticipants = &n
+			}
+		}
+	}
+	return a
+}
+
+// Active reports whether the activity is going on: a live stream that is
+// live or a meeting space that is open or private.
+func (a *Activity) Active() bool {
+	switch a.Kind {
+	case KindLiveStream:
+		return a.Status == "live"
+	case KindMeetingSpace:
+		return a.Status == "open" || a.Status == "private"
+	}
+	return false
+}
+
+// StaleAt returns why a live stream that is live is stale at now, or "" if
+// it is not: its ends time has passed, or it was last updated longer than
+// staleAfter ago. Meeting spaces and ended streams are never stale.
+func (a *Activity) StaleAt(now time.Time, staleAfter time.Duration) string {
+	if a.Kind != KindLiveStream || a.Status != "live" {
+		return ""
+	}
+	if a.Ends > 0 && now.Unix() > a.Ends {
+		return StaleEnded
+	}
+	if now.Sub(a.Event.CreatedAt.Time()) > staleAfter {
+		return StaleInactive
+	}
+	return ""
+}
+
+// presence is the latest room presence of a pubkey.
+type presence struct {
+	room string // address of the activity
+	at   time.Time
+	hand bool
+}
+
+// Tracker holds the active live activities and the room presences.
+type Tracker struct {
+	cfg config.LiveConfig
+
+	mu         sync.RWMutex
+	activities map[string]*Activity // address -> activity
+	presences  map[string]presence  // pubkey -> presence
+}
+
+// New creates an empty tracker.
+func New(cfg config.LiveConfig) *Tracker {
+	if cfg.PresenceTTL <= 0 {
+		cfg.PresenceTTL = defaultPresenceTTL
+	}
+	if cfg.MaxActivities <= 0 {
+		cfg.MaxActivities = defaultMaxActivities
+	}
+	if cfg.StaleAfter <= 0 {
+		cfg.StaleAfter = defaultStaleAfter
+	}
+	return &Tracker{
+		cfg:        cfg,
+		activities: make(map[string]*Activity),
+		presences:  make(map[string]presence),
+	}
+}
+
+// Add tracks a live stream, meeting space or room presence unless a newer
+// version of it is tracked already. An activity that is no longer active, or
+// is stale, is forgotten. It reports whether the event changed what is tracked.
+func (t *Tracker) Add(evt *nostr.Event) bool {
+	switch evt.Kind {
+	case KindLiveStream, KindMeetingSpace:
+		activity := ParseActivity(evt)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		current, exists := t.activities[activity.Address]
+		if exists && !index.Newer(evt, current.Event) {
+			return false
+		}
+		if !activity.Active() || activity.StaleAt(time.Now(), t.cfg.StaleAfter) != "" {
+			delete(t.activities, activity.Address)
+			return exists
+		}
+		if !exists && len(t.activities) >= t.cfg.MaxActivities {
+			return false
+		}
+		t.activities[activity.Address] = activity
+		return true
+	case KindRoomPresence:
+		tag := evt.Tags.GetFirst([]string{"a", ""})
+		if tag == nil || len(*tag) < 2 {
+			return false
+		}
+		at := evt.CreatedAt.Time()
+		if now := time.Now(); at.After(now) {
+			at = now
+		}
+		hand := false
+		if h := evt.Tags.GetFirst([]string{"hand", ""}); h != nil && len(*h) >= 2 {
+			hand = (*h)[1] == "1"
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if current, ok := t.presences[evt.PubKey]; ok && !at.After(current.at) {
+			return false
+		}
+		t.presences[evt.PubKey] = presence{room: (*tag)[1], at: at, hand: hand}
+		return true
+	}
+	return false
+}
+
+// Remove forgets the author's activities deleted by a NIP-09 deletion: those
+// whose event ID is in ids, and those whose address is in addrs with a
+// version no newer than the deletion.
+func (t *Tracker) Remove(deletion *nostr.Event, ids, addrs []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for address, a := range t.activities {
+		if index.Deletes(deletion, ids, addrs, a.Event, address) {
+			delete(t.activities, address)
+		}
+	}
+}
+
+// RemoveAuthor forgets the activities and presence of a pubkey, after a
+// NIP-62 vanish request.
+func (t *Tracker) RemoveAuthor(pubkey string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for address, a := range t.activities {
+		if a.Pubkey == pubkey {
+			delete(t.activities, address)
+		}
+	}
+	delete(t.presences, pubkey)
+}
+
+// Observe keeps the tracker in step with a newly stored event.
+func (t *Tracker) Observe(evt *nostr.Event) {
+	index.Observe(t, evt, KindLiveStream, KindMeetingSpace, KindRoomPresence)
+}
+
+// Query selects activities. Empty fields match every activity.
+type Query struct {
+	Kind    int
+	Hashtag string
+	Limit   int
+}
+
+// Active returns up to q.Limit active activities that are not stale, with
+// their participant counts, the most attended first.
+func (t *Tracker) Active(q Query) []Activity {
+	hashtag := strings.ToLower(q.Hashtag)
+	now := time.Now()
+	cutoff := now.Add(-t.cfg.PresenceTTL)
+
+	t.mu.RLock()
+	participants := make(map[string]int)
+	hands := make(map[string]int)
+	for _, p := range t.presences {
+		if p.at.Before(cutoff) {
+			continue
+		}
+		participants[p.room]++
+		if p.hand {
+			hands[p.room]++
+		}
+	}
+	var found []Activity
+	for address, a := range t.activities {
+		if (q.Kind != 0 && a.Kind != q.Kind) || (hashtag != "" && !slices.Contains(a.Hashtags, hashtag)) ||
+			a.StaleAt(now, t.cfg.StaleAfter) != "" {
+			continue
+		}
+		activity := *a
+		activity.Participants = participants[address]
+		activity.HandsRaised = hands[address]
+		found = append(found, activity)
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.Participants != b.Participants {
+			return a.Participants > b.Participants
+		}
+		if a.Event.CreatedAt != b.Event.CreatedAt {
+			return a.Event.CreatedAt > b.Event.CreatedAt
+		}
+		return a.Address < b.Address
+	})
+	if len(found) > q.Limit {
+		found = found[:q.Limit]
+	}
+	return found
+}
+
+// PruneAt forgets the presences older than the presence TTL at now and
+// returns how many it dropped.
+func (t *Tracker) PruneAt(now time.Time) int {
+	cutoff := now.Add(-t.cfg.PresenceTTL)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dropped := 0
+	for pubkey, p := range t.presences {
+		if p.at.Before(cutoff) {
+			delete(t.presences, pubkey)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// ExpireStale forgets the live streams that are stale at now and returns
+// them by reason (StaleEnded or StaleInactive).
+func (t *Tracker) ExpireStale(now time.Time) map[string][]*Activity {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stale := make(map[string][]*Activity)
+	for address, a := range t.activities {
+		if reason := a.StaleAt(now, t.cfg.StaleAfter); reason != "" {
+			stale[reason] = append(stale[reason], a)
+			delete(t.activities, address)
+		}
+	}
+	return stale
+}
+
+// HiddenFromREQ reports whether evt is a stale live stream that REQ results
+// leave out, when the tracker is configured to hide them.
+func (t *Tracker) HiddenFromREQ(evt *nostr.Event, now time.Time) bool {
+	if !t.cfg.HideStaleInREQ || evt.Kind != KindLiveStream {
+		return false
+	}
+	return ParseActivity(evt).StaleAt(now, t.cfg.StaleAfter) != ""
+}
+
+// PresenceTTL returns how long a room presence counts.
+func (t *Tracker) PresenceTTL() time.Duration {
+	return t.cfg.PresenceTTL
+}
+
+// Len returns the number of active activities and presences tracked.
+func (t *Tracker) Len() (activities, presences int) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.activities), len(t.presences)
+}
@@ -0,0 +1,434 @@
+// Package marketplace indexes the NIP-15 stalls (kind 30017) and products
+// (kind 30018) stored on the relay. Only the latest version of each
+// addressable listing is kept, parsed, so products can be searched by
+// category, price, currency and stall, and stalls summarised with their
+// products, without a tag-filter REQ per query.
+package marketplace
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/index"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Marketplace event kinds.
+const (
+	KindStall   = 30017
+	KindProduct = 30018
+)
+
+// Stock statuses of a product.
+const (
+	StockInStock    = "in_stock"
+	StockOutOfStock = "out_of_stock"
+	StockUnlimited  = "unlimited" // no quantity given
+)
+
+const defaultMaxEntries = 100000
+
+// Stall is the latest version of a stall.
+type Stall struct {
+	Merchant    string          `json:"merchant"`
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Currency    string          `json:"currency"`
+	Shipping    json.RawMessage `json:"shipping,omitempty"`
+	Event       *nostr.Event    `json:"event"`
+}
+
+// Product is the latest version of a product.
+type Product struct {
+	Merchant    string       `json:"merchant"`
+	ID          string       `json:"id"`
+	StallID     string       `json:"stall_id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Images      []string     `json:"images,omitempty"`
+	Currency    string       `json:"currency"`
+	Price       float64      `json:"price"`
+	Quantity    *int         `json:"quantity"`
+	Stock       string       `json:"stock"`
+	Categories  []string     `json:"categories"`
+	Event       *nostr.Event `json:"event"`
+}
+
+// available reports whether the product can be ordered.
+func (p *Product) available() bool {
+	return p.Stock != StockOutOfStock
+}
+
+// ParseStall reads a stall event.
+func ParseStall(evt *nostr.Event) (*Stall, error) {
+	var content struct {
+		ID          string          `json:"id"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Currency    string          `json:"currency"`
+		Shipping    json.RawMessage `json:"shipping"`
+	}
+	if err := json.Unmarshal([]byte(evt.Content), &content); err != nil {
+		return nil, fmt.Errorf("invalid stall JSON: %w", err)
+	}
+	id := evt.Tags.GetD()
+	if id == "" {
+		return nil, fmt.Errorf("stall has no d tag")
+	}
+	return &Stall{
+		Merchant:    evt.PubKey,
+		ID:          id,
+		Name:        content.Name,
+		Description: content.Description,
+		Currency:    strings.ToUpper(content.Currency),
+		Shipping:    content.Shipping,
+		Event:       evt,
+	}, nil
+}
+
+// ParseProduct reads a product event. Its categories are its t tags.
+func ParseProduct(evt *nostr.Event) (*Product, error) {
+	var content struct {
+		StallID     string   `json:"stall_id"`
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Images      []string `json:"images"`
+		Currency    string   `json:"currency"`
+		Price       float64  `json:"price"`
+		Quantity    *int     `json:"quantity"`
+	}
+	if err := json.Unmarshal([]byte(evt.Content), &content); err != nil {
+		return nil, fmt.Errorf("invalid product JSON: %w", err)
+	}
+	id := evt.Tags.GetD()
+	if id == "" {
+		return nil, fmt.Errorf("product has no d tag")
+	}
+	p := &Product{
+		Merchant:    evt.PubKey,
+		ID:          id,
+		StallID:     content.StallID,
+		Name:        content.Name,
+		Description: content.Description,
+		Images:      content.Images,
+		Currency:    strings.ToUpper(content.Currency),
+		Price:       content.Price,
+		Quantity:    content.Quantity,
+		Stock:       StockUnlimited,
+		Categories:  []string{},
+		Event:       evt,
+	}
+	if p.Quantity != nil {
+		p.Stock = StockInStock
+		if *p.Quantity <= 0 {
+			p.Stock = StockOutOfStock
+		}
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "t" && tag[1] != "" {
+			p.Categories = append(p.Categories, strings.ToLower(tag[1]))
+		}
+	}
+	return p, nil
+}
+
+// Index holds the latest stalls and products, by address.
+type Index struct {
+	max int
+
+	mu       sync.RWMutex
+	stalls   map[string]*Stall              // "<merchant>:<stall id>" -> stall
+	products map[string]*Product            // "<merchant>:<product id>" -> product
+	byStall  map[string]map[string]*Product // "<merchant>:<stall id>" -> products by address
+}
+
+// New creates an empty index.
+func New(cfg config.MarketplaceConfig) *Index {
+	max := cfg.MaxEntries
+	if max <= 0 {
+		max = defaultMaxEntries
+	}
+	return &Index{
+		max:      max,
+		stalls:   make(map[string]*Stall),
+		products: make(map[string]*Product),
+		byStall:  make(map[string]map[string]*Product),
+	}
+}
+
+// Add indexes a stall or product unless a newer version of it is indexed
+// already. It reports false when the event is not indexed: it is older, it
+// does not parse, or the index is full.
+func (ix *Index) Add(evt *nostr.Event) bool {
+	switch evt.Kind {
+	case KindStall:
+		stall, err := ParseStall(evt)
+		if err != nil {
+			return false
+		}
+		ix.mu.Lock()
+		defer ix.mu.Unlock()
+		key := address(stall.Merchant, stall.ID)
+		current, exists := ix.stalls[key]
+		if exists && !index.Newer(evt, current.Event) {
+			return false
+		}
+		if !exists && ix.fullLocked() {
+			return false
+		}
+		ix.stalls[key] = stall
+		return true
+	case KindProduct:
+		product, err := ParseProduct(evt)
+		if err != nil {
+			return false
+		}
+		ix.mu.Lock()
+		defer ix.mu.Unlock()
+		key := address(product.Merchant, product.ID)
+		current, exists := ix.products[key]
+		if exists && !index.Newer(evt, current.Event) {
+			return false
+		}
+		if !exists && ix.fullLocked() {
+			return false
+		}
+		if exists {
+			ix.unlinkLocked(key, current)
+		}
+		ix.products[key] = product
+		stallKey := address(product.Merchant, product.StallID)
+		if ix.byStall[stallKey] == nil {
+			ix.byStall[stallKey] = make(map[string]*Product)
+		}
+		ix.byStall[stallKey][key] = product
+		return true
+	}
+	return false
+}
+
+// Remove drops the merchant's listings deleted by a NIP-09 deletion: those
+// whose event ID is in ids, and those whose address is in addrs
+// ("<kind>:<pubkey>:<d>") with a version no newer than the deletion.
+func (ix *Index) Remove(deletion *nostr.Event, ids, addrs []string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	merchant := deletion.PubKey
+	for key, stall := range ix.stalls {
+		if stall.Merchant == merchant && deletes(stall.Event, deletion, ids, addrs) {
+			delete(ix.stalls, key)
+		}
+	}
+	for key, product := range ix.products {
+		if product.Merchant == merchant && deletes(product.Event, deletion, ids, addrs) {
+			ix.unlinkLocked(key, product)
+			delete(ix.products, key)
+		}
+	}
+}
+
+// RemoveAuthor drops every listing of a merchant, after a NIP-62 vanish request.
+func (ix *Index) RemoveAuthor(merchant string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for key, stall := range ix.stalls {
+		if stall.Merchant == merchant {
+			delete(ix.stalls, key)
+		}
+	}
+	for key, product := range ix.products {
+		if product.Merchant == merchant {
+			ix.unlinkLocked(key, product)
+			delete(ix.products, key)
+		}
+	}
+}
+
+// Observe keeps the index in step with a newly stored event.
+func (ix *Index) Observe(evt *nostr.Event) {
+	index.Observe(ix, evt, KindStall, KindProduct)
+}
+
+// ProductQuery selects products. Empty fields match every product; prices
+// are compared in the product's own currency.
+type ProductQuery struct {
+	Category string
+	Currency string
+	Merchant string
+	StallID  string // with Merchant, the products of one stall
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  bool // only products that are in stock or unlimited
+	Limit    int
+	// After resumes a listing after the product with this event, by
+	// created_at and ID; zero starts from the newest.
+	AfterCreatedAt int64
+	AfterID        string
+}
+
+// Products returns up to q.Limit products matching q, newest first.
+func (ix *Index) Products(q ProductQuery) []*Product {
+	category := strings.ToLower(q.Category)
+	currency := strings.ToUpper(q.Currency)
+
+	ix.mu.RLock()
+	candidates := ix.products
+	if q.Merchant != "" && q.StallID != "" {
+		candidates = ix.byStall[address(q.Merchant, q.StallID)]
+	}
+	var found []*Product
+	for _, p := range candidates {
+		switch {
+		case q.Merchant != "" && p.Merchant != q.Merchant,
+			currency != "" && p.Currency != currency,
+			category != "" && !slices.Contains(p.Categories, category),
+			q.MinPrice != nil && p.Price < *q.MinPrice,
+			q.MaxPrice != nil && p.Price > *q.MaxPrice,
+			q.InStock && !p.available(),
+			q.AfterID != "" && !olderThan(p.Event, q.AfterCreatedAt, q.AfterID):
+			continue
+		}
+		found = append(found, p)
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		return olderThan(found[j].Event, int64(found[i].Event.CreatedAt), found[i].Event.ID)
+	})
+	if len(found) > q.Limit {
+		found = found[:q.Limit]
+	}
+	return found
+}
+
+// PriceRange is the lowest and highest price of a stall's products.
+type PriceRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// CategoryCount is how many of a stall's products are in a category.
+type CategoryCount struct {
+	Category string `json:"category"`
+	Products int    `json:"products"`
+}
+
+// StallSummary is a stall with aggregates over its products.
+type StallSummary struct {
+	*Stall
+	Products   int             `json:"products"`
+	InStock    int             `json:"in_stock"`
+	Categories []CategoryCount `json:"categories"`
+	// Prices ranges over the products priced in the stall's currency.
+	Prices *PriceRange `json:"prices,omitempty"`
+}
+
+// Stalls returns up to limit stalls with their product aggregates, newest
+// first, only those of merchant and in currency when they are not empty.
+func (ix *Index) Stalls(merchant, currency string, limit int) []StallSummary {
+	currency = strings.ToUpper(currency)
+
+	ix.mu.RLock()
+	var found []StallSummary
+	for key, stall := range ix.stalls {
+		if (merchant != "" && stall.Merchant != merchant) || (currency != "" && stall.Currency != currency) {
+			continue
+		}
+		found = append(found, summarise(stall, ix.byStall[key]))
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		return olderThan(found[j].Event, int64(found[i].Event.CreatedAt), found[i].Event.ID)
+	})
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
+
+// Len returns the number of indexed stalls and products.
+func (ix *Index) Len() (stalls, products int) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.stalls), len(ix.products)
+}
+
+// summarise aggregates the products of a stall.
+func summarise(stall *Stall, products map[string]*Product) StallSummary {
+	summary := StallSummary{Stall: stall, Products: len(products), Categories: []CategoryCount{}}
+	counts := make(map[string]int)
+	for _, p := range products {
+		if p.available() {
+			summary.InStock++
+		}
+		for _, category := range p.Categories {
+			counts[category]++
+		}
+		if p.Currency != stall.Currency {
+			continue
+		}
+		if summary.Prices == nil {
+			summary.Prices = &PriceRange{Min: p.Price, Max: p.Price}
+		} else if p.Price < summary.Prices.Min {
+			summary.Prices.Min = p.Price
+		} else if p.Price > summary.Prices.Max {
+			summary.Prices.Max = p.Price
+		}
+	}
+	for category, n := range counts {
+		summary.Categories = append(summary.Categories, CategoryCount{Category: category, Products: n})
+	}
+	sort.Slice(summary.Categories, func(i, j int) bool {
+		a, b := summary.Categories[i], summary.Categories[j]
+		if a.Products != b.Products {
+			return a.Products > b.Products
+		}
+		return a.Category < b.Category
+	})
+	return summary
+}
+
+// fullLocked reports whether the index holds MaxEntries listings. Must be
+// called with mu held.
+func (ix *Index) fullLocked() bool {
+	return len(ix.stalls)+len(ix.products) >= ix.max
+}
+
+// unlinkLocked drops a product from its stall's products. Must be called
+// with mu held.
+func (ix *Index) unlinkLocked(key string, p *Product) {
+	stallKey := address(p.Merchant, p.StallID)
+	delete(ix.byStall[stallKey], key)
+	if len(ix.byStall[stallKey]) == 0 {
+		delete(ix.byStall, stallKey)
+	}
+}
+
+// address keys a listing by merchant and d tag.
+func address(merchant, id string) string {
+	return merchant + ":" + id
+}
+
+// olderThan reports whether evt sorts after (createdAt, id) in a newest
+// first listing.
+func olderThan(evt *nostr.Event, createdAt int64, id string) bool {
+	if int64(evt.CreatedAt) != createdAt {
+		return int64(evt.CreatedAt) < createdAt
+	}
+	return evt.ID > id
+}
+
+// deletes reports whether a deletion covers the listing event.
+func deletes(evt, deletion *nostr.Event, ids, addrs []string) bool {
+	addr := strconv.Itoa(evt.Kind) + ":" + evt.PubKey + ":" + evt.Tags.GetD()
+	return index.Deletes(deletion, ids, addrs, evt, addr)
+}
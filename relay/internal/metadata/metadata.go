@@ -0,0 +1,202 @@
+// Package metadata caches the latest kind 0 profile of each pubkey, parsed.
+// Profiles are loaded from storage on a miss and refreshed after TTL; newly
+// stored profiles replace cached ones at once, and deletions drop them.
+package metadata
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/index"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	defaultMaxEntries = 10000
+	defaultTTL        = 10 * time.Minute
+)
+
+// Metadata is the parsed content of a kind 0 event. Fields that are missing
+// or not strings are left empty.
+type Metadata struct {
+	Name        string `json:"name,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	About       string `json:"about,omitempty"`
+	Picture     string `json:"picture,omitempty"`
+	Banner      string `json:"banner,omitempty"`
+	Website     string `json:"website,omitempty"`
+	NIP05       string `json:"nip05,omitempty"`
+	LUD06       string `json:"lud06,omitempty"`
+	LUD16       string `json:"lud16,omitempty"`
+	Bot         bool   `json:"bot,omitempty"`
+}
+
+// Parse reads the metadata of a kind 0 event, tolerating fields of the wrong
+// type; it fails only when the content is not a JSON object.
+func Parse(evt *nostr.Event) (Metadata, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(evt.Content), &raw); err != nil {
+		return Metadata{}, err
+	}
+	str := func(key string) string {
+		s, _ := raw[key].(string)
+		return strings.TrimSpace(s)
+	}
+	m := Metadata{
+		Name:        str("name"),
+		DisplayName: str("display_name"),
+		About:       str("about"),
+		Picture:     str("picture"),
+		Banner:      str("banner"),
+		Website:     str("website"),
+		NIP05:       str("nip05"),
+		LUD06:       str("lud06"),
+		LUD16:       str("lud16"),
+	}
+	if m.DisplayName == "" {
+		m.DisplayName = str("displayName") // deprecated spelling
+	}
+	m.Bot, _ = raw["bot"].(bool)
+	return m, nil
+}
+
+// Profile is the latest kind 0 of a pubkey.
+type Profile struct {
+	Event    *nostr.Event
+	Metadata Metadata
+	Valid    bool // the content parsed as a JSON object
+}
+
+// entry is a cached profile lookup.
+type entry struct {
+	pubkey  string
+	profile *Profile // nil when the pubkey has no stored profile
+	loaded  time.Time
+}
+
+// Loader returns the latest stored kind 0 of pubkey, or nil when there is none.
+type Loader func(ctx context.Context, pubkey string) (*nostr.Event, error)
+
+// Cache holds the latest profiles of the most recently used pubkeys.
+type Cache struct {
+	cfg  config.MetadataConfig
+	load Loader
+
+	mu      sync.Mutex
+	order   *list.List               // least recently used at the front
+	entries map[string]*list.Element // pubkey -> *entry
+}
+
+// New creates a cache reading profiles with load, filling in defaults for
+// unset limits.
+func New(cfg config.MetadataConfig, load Loader) *Cache {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultMaxEntries
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	return &Cache{
+		cfg:     cfg,
+		load:    load,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the latest profile of pubkey, or nil when it has none.
+func (c *Cache) Get(ctx context.Context, pubkey string) (*Profile, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if elem, ok := c.entries[pubkey]; ok {
+		e := elem.Value.(*entry)
+		if now.Sub(e.loaded) < c.cfg.TTL {
+			c.order.MoveToBack(elem)
+			c.mu.Unlock()
+			metrics.MetadataCacheLookups.WithLabelValues("hit").Inc()
+			return e.profile, nil
+		}
+	}
+	c.mu.Unlock()
+	metrics.MetadataCacheLookups.WithLabelValues("miss").Inc()
+
+	evt, err := c.load(ctx, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	var profile *Profile
+	if evt != nil {
+		profile = newProfile(evt)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A profile stored while storage was read is newer than what was read
+	if elem, ok := c.entries[pubkey]; ok {
+		if cur := elem.Value.(*entry).profile; cur != nil && (profile == nil || index.Newer(cur.Event, profile.Event)) {
+			profile = cur
+		}
+	}
+	c.putLocked(pubkey, profile, now)
+	return profile, nil
+}
+
+// Observe updates the cache with a newly stored event: a kind 0 replaces an
+// older cached profile, and a deletion or request to vanish by its author
+// drops it.
+func (c *Cache) Observe(evt *nostr.Event) {
+	switch evt.Kind {
+	case 0:
+		profile := newProfile(evt)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if elem, ok := c.entries[evt.PubKey]; ok {
+			if cur := elem.Value.(*entry).profile; cur != nil && !index.Newer(evt, cur.Event) {
+				return
+			}
+		}
+		c.putLocked(evt.PubKey, profile, time.Now())
+	case 5, 62:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if elem, ok := c.entries[evt.PubKey]; ok {
+			c.order.Remove(elem)
+			delete(c.entries, evt.PubKey)
+		}
+	}
+}
+
+// Len returns the number of cached pubkeys.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// putLocked caches profile for pubkey, evicting the least recently used
+// entry when full. Must be called with mu held.
+func (c *Cache) putLocked(pubkey string, profile *Profile, loaded time.Time) {
+	if elem, ok := c.entries[pubkey]; ok {
+		e := elem.Value.(*entry)
+		e.profile, e.loaded = profile, loaded
+		c.order.MoveToBack(elem)
+		return
+	}
+	c.entries[pubkey] = c.order.PushBack(&entry{pubkey: pubkey, profile: profile, loaded: loaded})
+	if c.order.Len() > c.cfg.MaxEntries {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).pubkey)
+	}
+}
+
+func newProfile(evt *nostr.Event) *Profile {
+	m, err := Parse(evt)
+	return &Profile{Event: evt, Metadata: m, Valid: err == nil}
+}
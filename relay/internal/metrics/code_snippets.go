@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Code snippet languages: accepted NIP-C0 snippets counted by the language in
+// their "l" tag, for the dashboard's language distribution. Languages are
+// publisher-chosen, so only the first maxCodeLanguages distinct names get
+// their own count; later ones are counted as "other".
+
+// maxCodeLanguages bounds the distinct languages counted.
+const maxCodeLanguages = 100
+
+// CodeSnippets counts accepted code snippets by language.
+var CodeSnippets = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: Namespace,
+	Name:      "code_snippets_total",
+	Help:      "Accepted NIP-C0 code snippets by language",
+}, []string{"language"}) // "unknown" when untagged, "other" past the language cap
+
+var (
+	codeLanguagesMu sync.Mutex
+	codeLanguages   = make(map[string]int64)
+)
+
+// RecordCodeSnippet counts an accepted code snippet written in language.
+func RecordCodeSnippet(language string) {
+	if language == "" {
+		language = "unknown"
+	}
+	codeLanguagesMu.Lock()
+	if _, ok := codeLanguages[language]; !ok && len(codeLanguages) >= maxCodeLanguages {
+		language = "other"
+	}
+	codeLanguages[language]++
+	codeLanguagesMu.Unlock()
+	CodeSnippets.WithLabelValues(language).Inc()
+}
+
+// GetCodeSnippetLanguages returns accepted code snippets per language since start.
+func GetCodeSnippetLanguages() map[string]int64 {
+	codeLanguagesMu.Lock()
+	defer codeLanguagesMu.Unlock()
+	out := make(map[string]int64, len(codeLanguages))
+	for language, n := range codeLanguages {
+		out[language] = n
+	}
+	return out
+}
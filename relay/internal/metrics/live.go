@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Live ingestion tracking for the dashboard's /api/live stream. Accepted
+// events, rejections and publishers are counted in one-second buckets over
+// the last liveWindow seconds; Prometheus counters cannot be read back per
+// second, and top talkers would be far too high-cardinality as labels.
+
+const (
+	liveWindow = 60 // seconds kept
+	// maxLiveTalkers bounds the distinct pubkeys counted in one bucket.
+	maxLiveTalkers = 10000
+	// liveTopTalkers is the number of publishers reported in a snapshot.
+	liveTopTalkers = 10
+)
+
+type liveBucket struct {
+	second   int64
+	kinds    map[int]int
+	rejected map[string]int
+	talkers  map[string]int
+}
+
+var (
+	liveMu      sync.Mutex
+	liveBuckets [liveWindow]liveBucket
+
+	queueDepthMu sync.RWMutex
+	queueDepths  = make(map[string]int)
+)
+
+// LiveTalker is a publisher's accepted event count over the live window.
+type LiveTalker struct {
+	Pubkey string `json:"pubkey"`
+	Events int    `json:"events"`
+}
+
+// LiveSnapshot is one frame of the live ingestion stream.
+type LiveSnapshot struct {
+	Timestamp     int64          `json:"timestamp"`
+	KindRates     map[string]int `json:"kind_rates"`   // accepted events per kind in the last full second
+	KindTotals    map[string]int `json:"kind_totals"`  // accepted events per kind over the window
+	Rejections    map[string]int `json:"rejections"`   // rejections per reason prefix over the window
+	TopTalkers    []LiveTalker   `json:"top_talkers"`  // busiest publishers over the window
+	QueueDepths   map[string]int `json:"queue_depths"` // events waiting per priority class
+	WindowSeconds int            `json:"window_seconds"`
+}
+
+// bucketLocked returns the bucket for the given second, resetting it if stale. Caller must hold liveMu.
+func bucketLocked(second int64) *liveBucket {
+	b := &liveBuckets[second%liveWindow]
+	if b.second != second {
+		*b = liveBucket{
+			second:   second,
+			kinds:    make(map[int]int),
+			rejected: make(map[string]int),
+			talkers:  make(map[string]int),
+		}
+	}
+	return b
+}
+
+// RecordLiveAccepted counts an accepted event for the live stream.
+func RecordLiveAccepted(kind int, pubkey string) {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	b := bucketLocked(time.Now().Unix())
+	b.kinds[kind]++
+	if _, ok := b.talkers[pubkey]; ok || len(b.talkers) < maxLiveTalkers {
+		b.talkers[pubkey]++
+	}
+}
+
+// RecordLiveRejected counts a rejected event by the machine-readable prefix of its reason.
+func RecordLiveRejected(reason string) {
+	prefix := "other"
+	if i := strings.Index(reason, ":"); i > 0 && i <= 20 {
+		prefix = reason[:i]
+	}
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	bucketLocked(time.Now().Unix()).rejected[prefix]++
+}
+
+// SetEventQueueDepth publishes the length of a priority queue to Prometheus and the live stream.
+func SetEventQueueDepth(class string, depth int) {
+	EventQueueDepth.WithLabelValues(class).Set(float64(depth))
+	queueDepthMu.Lock()
+	queueDepths[class] = depth
+	queueDepthMu.Unlock()
+}
+
+// GetLiveSnapshot aggregates the live window.
+func GetLiveSnapshot() LiveSnapshot {
+	now := time.Now().Unix()
+	snap := LiveSnapshot{
+		Timestamp:     now,
+		KindRates:     make(map[string]int),
+		KindTotals:    make(map[string]int),
+		Rejections:    make(map[string]int),
+		QueueDepths:   make(map[string]int),
+		WindowSeconds: liveWindow,
+	}
+
+	talkers := make(map[string]int)
+	liveMu.Lock()
+	for i := range liveBuckets {
+		b := &liveBuckets[i]
+		if b.second <= now-liveWindow || b.second > now {
+			continue
+		}
+		for kind, n := range b.kinds {
+			key := strconv.Itoa(kind)
+			snap.KindTotals[key] += n
+			if b.second == now-1 {
+				snap.KindRates[key] = n
+			}
+		}
+		for reason, n := range b.rejected {
+			snap.Rejections[reason] += n
+		}
+		for pubkey, n := range b.talkers {
+			talkers[pubkey] += n
+		}
+	}
+	liveMu.Unlock()
+
+	snap.TopTalkers = make([]LiveTalker, 0, min(len(talkers), liveTopTalkers))
+	for pubkey, n := range talkers {
+		snap.TopTalkers = append(snap.TopTalkers, LiveTalker{Pubkey: pubkey, Events: n})
+	}
+	sort.Slice(snap.TopTalkers, func(i, j int) bool {
+		if snap.TopTalkers[i].Events != snap.TopTalkers[j].Events {
+			return snap.TopTalkers[i].Events > snap.TopTalkers[j].Events
+		}
+		return snap.TopTalkers[i].Pubkey < snap.TopTalkers[j].Pubkey
+	})
+	if len(snap.TopTalkers) > liveTopTalkers {
+		snap.TopTalkers = snap.TopTalkers[:liveTopTalkers]
+	}
+
+	queueDepthMu.RLock()
+	for class, depth := range queueDepths {
+		snap.QueueDepths[class] = depth
+	}
+	queueDepthMu.RUnlock()
+	return snap
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Subscription query performance: the time from a REQ to its EOSE and the
+// number of stored events it returned, labeled by filter shape (which of
+// ids, authors, kinds, tags and search the filters use). Prometheus gets
+// histograms; the dashboard gets percentiles over the latest
+// maxLatencySamples requests of each shape.
+
+// maxLatencySamples bounds the requests kept per shape for percentiles.
+const maxLatencySamples = 1024
+
+var (
+	SubscriptionEOSELatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "subscription_eose_latency_seconds",
+		Help:      "Time from a REQ to its EOSE by filter shape",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2.5, 12), // 1ms ... ~24s
+	}, []string{"shape"})
+
+	SubscriptionResultSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "subscription_result_events",
+		Help:      "Stored events sent before EOSE by filter shape",
+		Buckets:   []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000, 5000},
+	}, []string{"shape"})
+)
+
+// QueryLatency summarizes recent requests of one filter shape.
+type QueryLatency struct {
+	Requests  int64   `json:"requests"` // since start
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+	P50Events int     `json:"p50_events"`
+	P95Events int     `json:"p95_events"`
+	P99Events int     `json:"p99_events"`
+}
+
+// latencySamples is a ring of the latest requests of one shape.
+type latencySamples struct {
+	requests int64
+	latency  []time.Duration
+	events   []int
+	next     int
+}
+
+var (
+	latencyMu      sync.Mutex
+	latencyByShape = make(map[string]*latencySamples)
+)
+
+// RecordEOSE records a subscription of the given filter shape that reached
+// EOSE after latency with events stored events sent.
+func RecordEOSE(shape string, latency time.Duration, events int) {
+	SubscriptionEOSELatency.WithLabelValues(shape).Observe(latency.Seconds())
+	SubscriptionResultSize.WithLabelValues(shape).Observe(float64(events))
+
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	s := latencyByShape[shape]
+	if s == nil {
+		s = &latencySamples{}
+		latencyByShape[shape] = s
+	}
+	s.requests++
+	if len(s.latency) < maxLatencySamples {
+		s.latency = append(s.latency, latency)
+		s.events = append(s.events, events)
+		return
+	}
+	s.latency[s.next] = latency
+	s.events[s.next] = events
+	s.next = (s.next + 1) % maxLatencySamples
+}
+
+// GetQueryLatency returns EOSE latency and result size percentiles per filter shape.
+func GetQueryLatency() map[string]QueryLatency {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	out := make(map[string]QueryLatency, len(latencyByShape))
+	for shape, s := range latencyByShape {
+		latency := slices.Clone(s.latency)
+		events := slices.Clone(s.events)
+		slices.Sort(latency)
+		slices.Sort(events)
+		out[shape] = QueryLatency{
+			Requests:  s.requests,
+			P50Ms:     durationMs(percentile(latency, 50)),
+			P95Ms:     durationMs(percentile(latency, 95)),
+			P99Ms:     durationMs(percentile(latency, 99)),
+			P50Events: percentile(events, 50),
+			P95Events: percentile(events, 95),
+			P99Events: percentile(events, 99),
+		}
+	}
+	return out
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted values.
+func percentile[T time.Duration | int](sorted []T, p int) T {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p+99)/100 - 1
+	return sorted[max(i, 0)]
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
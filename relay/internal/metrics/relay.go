@@ -228,102 +228,378 @@ func SyncActiveConnectionsCount(actualCount int64) {
 		ActiveConnections.Set(float64(actualCount))
 	}
 } // Metrics for tracking relay performance and usage
+
+// Namespace prefixes every relay metric name.
+const Namespace = "shugur_relay"
+
 var (
 	// Connection metrics
 	ActiveConnections = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "nostr_relay_active_connections",
-		Help: "The number of active WebSocket connections",
+		Namespace: Namespace,
+		Name:      "active_connections",
+		Help:      "The number of active WebSocket connections",
 	})
 
 	ActiveSubscriptions = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "nostr_relay_active_subscriptions",
-		Help: "The number of active subscriptions",
+		Namespace: Namespace,
+		Name:      "active_subscriptions",
+		Help:      "The number of active subscriptions",
 	})
 
+	ConnectionCompression = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "connections_compression_total",
+		Help:      "WebSocket connections by permessage-deflate negotiation outcome",
+	}, []string{"status"}) // "negotiated", "not_offered", "disabled"
+
 	// Message metrics
 	MessagesReceived = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "nostr_relay_messages_received_total",
-		Help: "The total number of messages received",
+		Namespace: Namespace,
+		Name:      "messages_received_total",
+		Help:      "The total number of messages received",
 	})
 
 	MessagesSent = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "nostr_relay_messages_sent_total",
-		Help: "The total number of messages sent",
+		Namespace: Namespace,
+		Name:      "messages_sent_total",
+		Help:      "The total number of messages sent",
 	})
 
 	MessageSizeBytes = promauto.NewHistogram(prometheus.HistogramOpts{
-		Name:    "nostr_relay_message_size_bytes",
-		Help:    "Size of received messages in bytes",
-		Buckets: prometheus.ExponentialBuckets(10, 10, 6), // 10, 100, 1000, ..., 1000000
+		Namespace: Namespace,
+		Name:      "message_size_bytes",
+		Help:      "Size of received messages in bytes",
+		Buckets:   prometheus.ExponentialBuckets(10, 10, 6), // 10, 100, 1000, ..., 1000000
+	})
+
+	BinaryFramesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "binary_frames_received_total",
+		Help:      "The total number of binary WebSocket frames received",
 	})
 
 	MessageSizeBytesSent = promauto.NewHistogram(prometheus.HistogramOpts{
-		Name:    "nostr_relay_message_size_bytes_sent",
-		Help:    "Size of sent messages in bytes",
-		Buckets: prometheus.ExponentialBuckets(10, 10, 6), // 10, 100, 1000, ..., 1000000
+		Namespace: Namespace,
+		Name:      "message_size_bytes_sent",
+		Help:      "Size of sent messages in bytes",
+		Buckets:   prometheus.ExponentialBuckets(10, 10, 6), // 10, 100, 1000, ..., 1000000
 	})
 
 	// Command metrics
 	CommandsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
-		Name: "nostr_relay_commands_received_total",
-		Help: "The total number of commands received by type",
+		Namespace: Namespace,
+		Name:      "commands_received_total",
+		Help:      "The total number of commands received by type",
 	}, []string{"type"}) // "EVENT", "REQ", "CLOSE", etc.
 
 	CommandProcessingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "nostr_relay_command_processing_duration_seconds",
-		Help:    "Time to process different command types",
-		Buckets: prometheus.ExponentialBuckets(0.001, 10, 5), // 0.001, 0.01, 0.1, 1, 10
+		Namespace: Namespace,
+		Name:      "command_processing_duration_seconds",
+		Help:      "Time to process different command types",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 10, 5), // 0.001, 0.01, 0.1, 1, 10
 	}, []string{"type"})
 
 	// Event metrics
 	EventsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
-		Name: "nostr_relay_events_processed_total",
-		Help: "The total number of events processed by kind",
+		Namespace: Namespace,
+		Name:      "events_processed_total",
+		Help:      "The total number of events processed by kind",
 	}, []string{"kind"})
 
 	EventsStored = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "nostr_relay_events_stored",
-		Help: "The total number of events currently stored in the database",
+		Namespace: Namespace,
+		Name:      "events_stored",
+		Help:      "The total number of events currently stored in the database",
 	})
 
 	DuplicateEvents = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "nostr_relay_duplicate_events_total",
-		Help: "The total number of duplicate events received",
+		Namespace: Namespace,
+		Name:      "duplicate_events_total",
+		Help:      "The total number of duplicate events received",
 	})
 
 	// HTTP metrics
 	HTTPRequests = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "nostr_relay_http_requests_total",
-		Help: "The total number of HTTP requests",
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "The total number of HTTP requests",
 	})
 
 	HTTPRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
-		Name:    "nostr_relay_http_request_duration_seconds",
-		Help:    "HTTP request duration in seconds",
-		Buckets: prometheus.ExponentialBuckets(0.01, 10, 5), // 0.01, 0.1, 1, 10, 100
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request duration in seconds",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 10, 5), // 0.01, 0.1, 1, 10, 100
 	})
 
 	// Error metrics
 	ErrorsCount = promauto.NewCounterVec(prometheus.CounterOpts{
-		Name: "nostr_relay_errors_total",
-		Help: "The total number of errors by type",
+		Namespace: Namespace,
+		Name:      "errors_total",
+		Help:      "The total number of errors by type",
 	}, []string{"type"}) // "validation", "database", "websocket", etc.
 
+	// Outbox metrics
+	OutboxEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "outbox_events_total",
+		Help:      "Relay-signed events published to external relays by outcome",
+	}, []string{"status"}) // "published", "failed", "dropped", "duplicate"
+
+	// Event sink metrics
+	SinkEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "sink_events_total",
+		Help:      "Accepted events streamed to the MQTT or Kafka sink by outcome",
+	}, []string{"status"}) // "published", "retried", "dropped"
+
+	// Webhook metrics
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook deliveries of matching events by outcome",
+	}, []string{"status"}) // "delivered", "retried", "dead_letter", "dropped"
+
+	// Wallet Connect metrics
+	NWCMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "nwc_messages_total",
+		Help:      "NIP-47 Wallet Connect messages by outcome",
+	}, []string{"status"}) // "accepted", "rate_limited", "replayed"
+
+	// NIP-17 DM inbox metrics
+	DMInboxEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "dm_inbox_events_total",
+		Help:      "NIP-17 DM inbox gift wraps and relay lists by outcome",
+	}, []string{"status"}) // "accepted", "rejected", "delivered", "purged"
+
+	// NIP-90 DVM metrics
+	DVMJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "dvm_jobs_total",
+		Help:      "NIP-90 DVM jobs by request kind and lifecycle stage",
+	}, []string{"kind", "stage"}) // stage: "requested", "completed", "error"
+
+	DVMJobLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "dvm_job_latency_seconds",
+		Help:      "Time from a NIP-90 job request to its first result",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 4, 8), // 0.1s ... ~27m
+	}, []string{"kind"})
+
+	// Content scoring metrics
+	ContentScoring = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "content_scoring_total",
+		Help:      "Content scoring pipeline outcomes",
+	}, []string{"result"}) // "scored", "deleted", "shadow_banned", "dropped", "error"
+
+	// Shadow rejection metrics
+	ShadowRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "shadow_rejected_events_total",
+		Help:      "Events acknowledged with OK true but neither stored nor dispatched",
+	}, []string{"reason"}) // "shadow_banned", "low_reputation", "spam", "spam_cluster"
+
+	// Near-duplicate spam cluster metrics
+	SpamClusters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "spam_cluster_actions_total",
+		Help:      "Near-duplicate spam clusters flagged and events acted on",
+	}, []string{"action"}) // "flagged", "throttle", "shadow_reject"
+
+	// Client anomaly detection metrics
+	AnomalyAlerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "anomaly_alerts_total",
+		Help:      "Clients found deviating from the baseline, by feature",
+	}, []string{"feature"}) // "req_rate", "filter_breadth", "subscription_churn", "rejection_ratio"
+
+	// NIP-03 OpenTimestamps verification metrics
+	OpenTimestamps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "opentimestamps_attestations_total",
+		Help:      "NIP-03 attestations verified, by outcome",
+	}, []string{"status"}) // "verified", "pending", "invalid", "dropped"
+
+	// Web-of-trust metrics
+	TrustGraphPubkeys = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "trust_graph_pubkeys",
+		Help:      "Pubkeys with a trust score in the web-of-trust graph",
+	})
+
+	ReputationDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "reputation_decisions_total",
+		Help:      "Policy decisions made from web-of-trust scores",
+	}, []string{"decision"}) // "trusted_bypass", "unknown_rate_limited"
+
+	// NIP-56 report pipeline metrics
+	ReportActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "report_actions_total",
+		Help:      "NIP-56 reports received and moderation actions taken from them",
+	}, []string{"action"}) // "received", "hidden", "flagged", "dismissed"
+
+	// Subscription replay dedup metrics
+	ReplayDuplicatesSuppressed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "replay_duplicates_suppressed_total",
+		Help:      "Events not sent because the subscription already received them before EOSE",
+	}, []string{"source"}) // "stored", "live"
+
+	SupersededVersionsSuppressed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "superseded_versions_suppressed_total",
+		Help:      "Replaceable event versions not sent to a subscription because a newer version replaced them",
+	}, []string{"reason"}) // "coalesced", "stale"
+
+	QueryCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "query_cache_lookups_total",
+		Help:      "Query result cache lookups by result",
+	}, []string{"result"}) // "hit", "miss"
+
+	MetadataCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "metadata_cache_lookups_total",
+		Help:      "Kind 0 profile cache lookups by result",
+	}, []string{"result"}) // "hit", "miss"
+
+	NIP05Lookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "nip05_lookups_total",
+		Help:      "NIP-05 identifier lookups by result",
+	}, []string{"result"}) // "resolved", "failed", "cached"
+
+	LNURLInvoices = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "lnurl_invoices_total",
+		Help:      "Invoices of the relay's lightning address by outcome",
+	}, []string{"outcome"}) // "created", "failed", "paid", "expired"
+
+	KeyPackagesDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "key_packages_dropped_total",
+		Help:      "NIP-EE KeyPackages refused or deleted by reason",
+	}, []string{"reason"}) // "duplicate", "superseded", "expired"
+
+	CapsuleUnlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "capsule_unlocks_total",
+		Help:      "Time capsule unlocks reached by notification outcome",
+	}, []string{"outcome"}) // "notified", "withdrawn", "failed"
+
+	ClassifiedsExpired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "classifieds_expired_total",
+		Help:      "NIP-99 classified listings deleted by reason",
+	}, []string{"reason"}) // "aged", "sold"
+
+	LiveActivitiesStale = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "live_activities_stale_total",
+		Help:      "NIP-53 live streams still marked live that were treated as ended, by reason",
+	}, []string{"reason"}) // "ended", "inactive"
+
+	CommunityAutoApprovals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "community_auto_approvals_total",
+		Help:      "NIP-72 approvals the relay published for allowlisted authors by outcome",
+	}, []string{"outcome"}) // "published", "dropped", "failed"
+
+	ThreadEventsDispatched = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "thread_events_dispatched_total",
+		Help:      "Thread replies and chat messages (kinds 9, 11, 1111) handed to the dispatcher by kind",
+	}, []string{"kind"})
+
+	ThreadDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "thread_deliveries_total",
+		Help:      "Thread events for thread-scoped connections, routed to a subscriber of their root or skipped",
+	}, []string{"route"}) // "routed", "skipped"
+
+	ThreadSubscribedRoots = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "thread_subscribed_roots",
+		Help:      "Root events with thread-scoped subscribers in the dispatcher",
+	})
+
+	HotStoreLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "hot_store_lookups_total",
+		Help:      "Hot store lookups by result",
+	}, []string{"result"}) // "hit", "miss"
+
+	ClusterDispatchEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "cluster_dispatch_events_total",
+		Help:      "Events exchanged with other relay nodes for real-time dispatch",
+	}, []string{"direction"}) // "sent", "received", "dropped"
+
+	EventQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "event_queue_depth",
+		Help:      "Events waiting in the processing queue by priority class",
+	}, []string{"class"}) // "high", "normal"
+
+	EventQueueDrops = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "event_queue_drops_total",
+		Help:      "Events dropped because their processing queue was full, by priority class",
+	}, []string{"class"})
+
 	// Database metrics
 	DBConnections = promauto.NewCounterVec(prometheus.CounterOpts{
-		Name: "nostr_relay_db_connections_total",
-		Help: "Total number of database connections by status",
+		Namespace: Namespace,
+		Name:      "db_connections_total",
+		Help:      "Total number of database connections by status",
 	}, []string{"status"}) // "success", "failure", "closed"
 
 	DBErrors = promauto.NewCounterVec(prometheus.CounterOpts{
-		Name: "nostr_relay_db_errors_total",
-		Help: "Total number of database errors by type",
+		Namespace: Namespace,
+		Name:      "db_errors_total",
+		Help:      "Total number of database errors by type",
 	}, []string{"error_type"})
 
 	DBOperations = promauto.NewCounterVec(prometheus.CounterOpts{
-		Name: "nostr_relay_db_operations_total",
-		Help: "Total number of database operations by type",
+		Namespace: Namespace,
+		Name:      "db_operations_total",
+		Help:      "Total number of database operations by type",
 	}, []string{"operation"})
+
+	DuplicateChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "duplicate_checks_total",
+		Help:      "Duplicate checks of incoming events by what answered them; bloom and recent saved a query",
+	}, []string{"source"}) // "bloom", "recent", "storage"
+
+	StorageRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "storage_retries_total",
+		Help:      "Storage operation retries and the operations that gave up",
+	}, []string{"outcome"}) // "retried", "exhausted", "circuit_open"
+
+	DBCircuitOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "db_circuit_open",
+		Help:      "1 while the storage circuit breaker refuses work",
+	})
+
+	DBPoolWait = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "db_pool_wait_seconds",
+		Help:      "Average time queries waited for a pool connection in the last admission sample",
+	})
+
+	QueriesShed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "queries_shed_total",
+		Help:      "Client queries refused because the database pool was saturated",
+	}, []string{"command"}) // "REQ", "COUNT"
 )
 
 // RegisterMetrics ensures all metrics are registered with Prometheus
@@ -341,6 +617,137 @@ func RegisterMetrics() {
 		EventsProcessed.WithLabelValues(kind)
 	}
 
+	// Pre-register compression negotiation outcomes
+	for _, status := range []string{"negotiated", "not_offered", "disabled"} {
+		ConnectionCompression.WithLabelValues(status)
+	}
+
+	// Pre-register outbox outcomes
+	for _, status := range []string{"published", "failed", "dropped", "duplicate"} {
+		OutboxEvents.WithLabelValues(status)
+	}
+
+	// Pre-register event sink outcomes
+	for _, status := range []string{"published", "retried", "dropped"} {
+		SinkEvents.WithLabelValues(status)
+	}
+
+	// Pre-register webhook outcomes
+	for _, status := range []string{"delivered", "retried", "dead_letter", "dropped"} {
+		WebhookDeliveries.WithLabelValues(status)
+	}
+
+	// Pre-register Wallet Connect outcomes
+	for _, status := range []string{"accepted", "rate_limited", "replayed"} {
+		NWCMessages.WithLabelValues(status)
+	}
+
+	// Pre-register DM inbox outcomes
+	for _, status := range []string{"accepted", "rejected", "delivered", "purged"} {
+		DMInboxEvents.WithLabelValues(status)
+	}
+
+	// Pre-register content scoring outcomes
+	for _, result := range []string{"scored", "deleted", "shadow_banned", "dropped", "error"} {
+		ContentScoring.WithLabelValues(result)
+	}
+
+	// Pre-register shadow rejection reasons
+	for _, reason := range []string{"shadow_banned", "low_reputation", "spam", "spam_cluster"} {
+		ShadowRejected.WithLabelValues(reason)
+	}
+
+	// Pre-register spam cluster actions
+	for _, action := range []string{"flagged", "throttle", "shadow_reject"} {
+		SpamClusters.WithLabelValues(action)
+	}
+
+	// Pre-register anomaly alert features
+	for _, feature := range []string{"req_rate", "filter_breadth", "subscription_churn", "rejection_ratio"} {
+		AnomalyAlerts.WithLabelValues(feature)
+	}
+
+	// Pre-register OpenTimestamps outcomes
+	for _, status := range []string{"verified", "pending", "invalid", "dropped"} {
+		OpenTimestamps.WithLabelValues(status)
+	}
+
+	// Pre-register web-of-trust decisions
+	for _, decision := range []string{"trusted_bypass", "unknown_rate_limited"} {
+		ReputationDecisions.WithLabelValues(decision)
+	}
+
+	// Pre-register report pipeline actions
+	for _, action := range []string{"received", "hidden", "flagged", "dismissed"} {
+		ReportActions.WithLabelValues(action)
+	}
+
+	// Pre-register replay dedup sources
+	for _, source := range []string{"stored", "live"} {
+		ReplayDuplicatesSuppressed.WithLabelValues(source)
+	}
+	for _, reason := range []string{"coalesced", "stale"} {
+		SupersededVersionsSuppressed.WithLabelValues(reason)
+	}
+
+	// Pre-register query cache and hot store results
+	for _, result := range []string{"hit", "miss"} {
+		QueryCacheLookups.WithLabelValues(result)
+		HotStoreLookups.WithLabelValues(result)
+		MetadataCacheLookups.WithLabelValues(result)
+	}
+	for _, result := range []string{"resolved", "failed", "cached"} {
+		NIP05Lookups.WithLabelValues(result)
+	}
+	for _, outcome := range []string{"created", "failed", "paid", "expired"} {
+		LNURLInvoices.WithLabelValues(outcome)
+	}
+	for _, reason := range []string{"duplicate", "superseded", "expired"} {
+		KeyPackagesDropped.WithLabelValues(reason)
+	}
+	for _, outcome := range []string{"notified", "withdrawn", "failed"} {
+		CapsuleUnlocks.WithLabelValues(outcome)
+	}
+	for _, reason := range []string{"aged", "sold"} {
+		ClassifiedsExpired.WithLabelValues(reason)
+	}
+	for _, reason := range []string{"ended", "inactive"} {
+		LiveActivitiesStale.WithLabelValues(reason)
+	}
+	for _, outcome := range []string{"published", "dropped", "failed"} {
+		CommunityAutoApprovals.WithLabelValues(outcome)
+	}
+
+	for _, route := range []string{"routed", "skipped"} {
+		ThreadDeliveries.WithLabelValues(route)
+	}
+
+	// Pre-register cluster dispatch directions
+	for _, direction := range []string{"sent", "received", "dropped"} {
+		ClusterDispatchEvents.WithLabelValues(direction)
+	}
+
+	// Pre-register duplicate check sources
+	for _, source := range []string{"bloom", "recent", "storage"} {
+		DuplicateChecks.WithLabelValues(source)
+	}
+
+	// Pre-register storage retry outcomes
+	for _, outcome := range []string{"retried", "exhausted", "circuit_open"} {
+		StorageRetries.WithLabelValues(outcome)
+	}
+
+	// Pre-register shed query commands
+	for _, command := range []string{"REQ", "COUNT"} {
+		QueriesShed.WithLabelValues(command)
+	}
+
+	// Pre-register event queue priority classes
+	for _, class := range []string{"high", "normal"} {
+		EventQueueDepth.WithLabelValues(class)
+		EventQueueDrops.WithLabelValues(class)
+	}
+
 	// Pre-register error types
 	errorTypes := []string{
 		"validation", "database", "websocket", "rate_limit",
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// nodeGatherer adds a node_id label to every gathered metric so scrapes of
+// several relay nodes aggregate without relabeling.
+type nodeGatherer struct {
+	prometheus.Gatherer
+	label *dto.LabelPair
+}
+
+// Gather implements prometheus.Gatherer.
+func (g nodeGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	for _, family := range families {
+		for _, m := range family.Metric {
+			m.Label = append(m.Label, g.label)
+		}
+	}
+	return families, err
+}
+
+// StartServer serves Prometheus metrics on their own listener until ctx is
+// canceled, restricted by the configured IP allowlist and basic auth.
+func StartServer(ctx context.Context, cfg config.MetricsConfig, nodeID string) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	allowed, err := parseAllowedIPs(cfg.AllowedIPs)
+	if err != nil {
+		return err
+	}
+
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if nodeID != "" {
+		gatherer = nodeGatherer{
+			Gatherer: prometheus.DefaultGatherer,
+			label:    &dto.LabelPair{Name: proto.String("node_id"), Value: proto.String(nodeID)},
+		}
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, protectMetrics(cfg, allowed, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})))
+
+	addr := net.JoinHostPort(cfg.Host, fmt.Sprintf("%d", cfg.Port))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		logger.Info("Metrics listener started",
+			zap.String("address", addr),
+			zap.String("path", path),
+			zap.Int("allowed_networks", len(allowed)),
+			zap.Bool("basic_auth", cfg.BasicAuth.Username != "" && cfg.BasicAuth.Password != ""))
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("Metrics listener failed", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// protectMetrics applies the IP allowlist and basic auth to the metrics handler.
+func protectMetrics(cfg config.MetricsConfig, allowed []*net.IPNet, next http.Handler) http.Handler {
+	user, pass := cfg.BasicAuth.Username, cfg.BasicAuth.Password
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(allowed) > 0 && !ipAllowed(r.RemoteAddr, allowed) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if user != "" && pass != "" {
+			u, p, ok := r.BasicAuth()
+			if !ok ||
+				subtle.ConstantTimeCompare([]byte(u), []byte(user)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(p), []byte(pass)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseAllowedIPs turns IPs and CIDRs into networks.
+func parseAllowedIPs(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metrics allowlist entry %q: %w", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ipAllowed checks the connecting address (not proxy headers) against the allowlist.
+func ipAllowed(remoteAddr string, allowed []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range allowed {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
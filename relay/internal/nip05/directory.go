@@ -0,0 +1,115 @@
+package nip05
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Sources of hosted names.
+const (
+	SourceConfig  = "config" // listed in the config file
+	SourceManaged = "nip86"  // set through the management API
+)
+
+// Name is a local name the relay hosts.
+type Name struct {
+	Name   string   `json:"name"`
+	Pubkey string   `json:"pubkey"`
+	Relays []string `json:"relays,omitempty"` // empty = the directory's defaults
+	Source string   `json:"source"`
+}
+
+// Document is a /.well-known/nostr.json document.
+type Document struct {
+	Names  map[string]string   `json:"names"`
+	Relays map[string][]string `json:"relays,omitempty"`
+}
+
+// Directory holds the local names the relay serves from its own nostr.json.
+type Directory struct {
+	relays []string // relay hints of names without their own
+
+	mu    sync.RWMutex
+	names map[string]Name
+}
+
+// NewDirectory creates an empty directory giving names without relay hints
+// of their own the defaultRelays.
+func NewDirectory(defaultRelays []string) *Directory {
+	return &Directory{relays: defaultRelays, names: make(map[string]Name)}
+}
+
+// ValidName reports whether name may be the local part of an identifier:
+// lowercase letters, digits, '-', '_' and '.'.
+func ValidName(name string) bool {
+	return name != "" && len(name) <= 64 && strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-_.") == ""
+}
+
+// Set adds or replaces a name.
+func (d *Directory) Set(n Name) {
+	d.mu.Lock()
+	d.names[n.Name] = n
+	d.mu.Unlock()
+}
+
+// Remove drops a name, returning it.
+func (d *Directory) Remove(name string) (Name, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n, ok := d.names[name]
+	delete(d.names, name)
+	return n, ok
+}
+
+// Get returns a name.
+func (d *Directory) Get(name string) (Name, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	n, ok := d.names[name]
+	return n, ok
+}
+
+// List returns every name, sorted.
+func (d *Directory) List() []Name {
+	d.mu.RLock()
+	out := make([]Name, 0, len(d.names))
+	for _, n := range d.names {
+		out = append(out, n)
+	}
+	d.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Document returns the nostr.json answering for name, or listing every name
+// when name is "". The relay hints of each pubkey are those of its names.
+func (d *Directory) Document(name string) Document {
+	doc := Document{Names: make(map[string]string), Relays: make(map[string][]string)}
+	add := func(n Name) {
+		doc.Names[n.Name] = n.Pubkey
+		relays := n.Relays
+		if len(relays) == 0 {
+			relays = d.relays
+		}
+		for _, relay := range relays {
+			if !slices.Contains(doc.Relays[n.Pubkey], relay) {
+				doc.Relays[n.Pubkey] = append(doc.Relays[n.Pubkey], relay)
+			}
+		}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if name != "" {
+		if n, ok := d.names[strings.ToLower(name)]; ok {
+			add(n)
+		}
+		return doc
+	}
+	for _, n := range d.names {
+		add(n)
+	}
+	return doc
+}
@@ -0,0 +1,290 @@
+// Package nip05 resolves NIP-05 identifiers (name@domain) to the pubkey the
+// domain lists for them in its /.well-known/nostr.json. Mappings are cached
+// for TTL and failed lookups for NegativeTTL, so a domain is asked at most
+// once per identifier and period. Lookups follow no redirects, as NIP-05
+// requires, and dial only public addresses, so a profile cannot point the
+// relay at its own network. A Directory holds the local names the relay
+// itself hosts when it acts as a NIP-05 provider.
+package nip05
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	defaultTTL         = time.Hour
+	defaultNegativeTTL = 5 * time.Minute
+	defaultDNSTimeout  = 2 * time.Second
+	defaultTimeout     = 5 * time.Second
+	defaultMaxEntries  = 10000
+
+	// maxResponse bounds the nostr.json document read.
+	maxResponse = 64 * 1024
+)
+
+// Lookup is the cached result of resolving an identifier.
+type Lookup struct {
+	Identifier string    `json:"identifier"`
+	Pubkey     string    `json:"pubkey,omitempty"` // "" when the lookup failed
+	Relays     []string  `json:"relays,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+	expires    time.Time
+}
+
+// Verification is whether an identifier resolves to a given pubkey.
+type Verification struct {
+	Identifier string    `json:"identifier"`
+	Verified   bool      `json:"verified"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Stats summarizes the cached lookups.
+type Stats struct {
+	Cached   int `json:"cached"`
+	Resolved int `json:"resolved"`
+	Failed   int `json:"failed"`
+}
+
+// Resolver resolves and caches NIP-05 identifiers.
+type Resolver struct {
+	cfg    config.NIP05Config
+	client *http.Client
+	dialer *net.Dialer
+
+	mu      sync.Mutex
+	lookups map[string]*Lookup // identifier -> last lookup
+}
+
+// New creates a resolver, filling in defaults for unset limits.
+func New(cfg config.NIP05Config) *Resolver {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.NegativeTTL <= 0 {
+		cfg.NegativeTTL = defaultNegativeTTL
+	}
+	if cfg.DNSTimeout <= 0 {
+		cfg.DNSTimeout = defaultDNSTimeout
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultMaxEntries
+	}
+
+	r := &Resolver{
+		cfg:     cfg,
+		dialer:  &net.Dialer{Timeout: cfg.Timeout},
+		lookups: make(map[string]*Lookup),
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = r.dial
+	r.client = &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return r
+}
+
+// RequireForWrite reports whether publishing requires a verified identifier.
+func (r *Resolver) RequireForWrite() bool {
+	return r.cfg.RequireForWrite
+}
+
+// ParseIdentifier splits a NIP-05 identifier into its lowercased local part
+// and domain; a bare domain stands for _@domain.
+func ParseIdentifier(identifier string) (name, domain string, err error) {
+	identifier = strings.ToLower(strings.TrimSpace(identifier))
+	name, domain, ok := strings.Cut(identifier, "@")
+	if !ok {
+		name, domain = "_", identifier
+	}
+	if !ValidName(name) {
+		return "", "", fmt.Errorf("invalid NIP-05 name %q", name)
+	}
+	if domain == "" || strings.ContainsAny(domain, "/@?#:% ") {
+		return "", "", fmt.Errorf("invalid NIP-05 domain %q", domain)
+	}
+	return name, domain, nil
+}
+
+// Resolve returns the lookup of identifier, from the cache when it has not
+// expired.
+func (r *Resolver) Resolve(ctx context.Context, identifier string) Lookup {
+	identifier = strings.ToLower(strings.TrimSpace(identifier))
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.lookups[identifier]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		metrics.NIP05Lookups.WithLabelValues("cached").Inc()
+		return *cached
+	}
+
+	l := &Lookup{Identifier: identifier, CheckedAt: now}
+	pubkey, relays, err := r.fetch(ctx, identifier)
+	if err != nil {
+		l.Error = err.Error()
+		l.expires = now.Add(r.cfg.NegativeTTL)
+		metrics.NIP05Lookups.WithLabelValues("failed").Inc()
+	} else {
+		l.Pubkey, l.Relays = pubkey, relays
+		l.expires = now.Add(r.cfg.TTL)
+		metrics.NIP05Lookups.WithLabelValues("resolved").Inc()
+	}
+
+	r.mu.Lock()
+	if len(r.lookups) >= r.cfg.MaxEntries {
+		for id, cur := range r.lookups {
+			if now.After(cur.expires) || len(r.lookups) >= r.cfg.MaxEntries {
+				delete(r.lookups, id)
+			}
+		}
+	}
+	r.lookups[identifier] = l
+	r.mu.Unlock()
+	return *l
+}
+
+// Verify reports whether identifier resolves to pubkey.
+func (r *Resolver) Verify(ctx context.Context, identifier, pubkey string) Verification {
+	l := r.Resolve(ctx, identifier)
+	v := Verification{Identifier: l.Identifier, CheckedAt: l.CheckedAt, Error: l.Error}
+	switch {
+	case l.Error != "":
+	case l.Pubkey != strings.ToLower(pubkey):
+		v.Error = l.Identifier + " names a different pubkey"
+	default:
+		v.Verified = true
+	}
+	return v
+}
+
+// Stats counts the cached lookups that have not expired.
+func (r *Resolver) Stats() Stats {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var s Stats
+	for _, l := range r.lookups {
+		if now.After(l.expires) {
+			continue
+		}
+		s.Cached++
+		if l.Error == "" {
+			s.Resolved++
+		} else {
+			s.Failed++
+		}
+	}
+	return s
+}
+
+// Recent returns up to limit cached lookups, most recently checked first.
+func (r *Resolver) Recent(limit int) []Lookup {
+	r.mu.Lock()
+	out := make([]Lookup, 0, len(r.lookups))
+	for _, l := range r.lookups {
+		out = append(out, *l)
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CheckedAt.After(out[j].CheckedAt) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// fetch asks identifier's domain for the pubkey and relays it lists.
+func (r *Resolver) fetch(ctx context.Context, identifier string) (string, []string, error) {
+	name, domain, err := ParseIdentifier(identifier)
+	if err != nil {
+		return "", nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	endpoint := "https://" + domain + "/.well-known/nostr.json?name=" + url.QueryEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid NIP-05 domain %q", domain)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err // the URL is implied by the identifier
+		}
+		return "", nil, fmt.Errorf("%s unreachable: %w", domain, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("%s answered %s", domain, resp.Status)
+	}
+
+	var doc struct {
+		Names  map[string]string   `json:"names"`
+		Relays map[string][]string `json:"relays"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponse)).Decode(&doc); err != nil {
+		return "", nil, fmt.Errorf("invalid nostr.json from %s", domain)
+	}
+	for n, pubkey := range doc.Names {
+		if strings.ToLower(n) != name {
+			continue
+		}
+		if pubkey = strings.ToLower(pubkey); !nostr.IsValid32ByteHex(pubkey) {
+			return "", nil, fmt.Errorf("%s lists an invalid pubkey for %s", domain, name)
+		}
+		return pubkey, doc.Relays[pubkey], nil
+	}
+	return "", nil, fmt.Errorf("%s is not listed by %s", name, domain)
+}
+
+// dial resolves the host within DNSTimeout and connects to its first public
+// address; private, loopback and other local addresses are refused.
+func (r *Resolver) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, r.cfg.DNSTimeout)
+	addrs, err := net.DefaultResolver.LookupNetIP(lookupCtx, "ip", host)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if addr = addr.Unmap(); isPublic(addr) {
+			return r.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		}
+	}
+	return nil, fmt.Errorf("%s has no public address", host)
+}
+
+// isPublic reports whether addr is a globally routable unicast address.
+func isPublic(addr netip.Addr) bool {
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
@@ -0,0 +1,106 @@
+package opentimestamps
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxCachedHeaders bounds the block headers remembered by EsploraSource.
+const maxCachedHeaders = 10000
+
+// Header is the part of a Bitcoin block header attestations are checked against.
+type Header struct {
+	MerkleRoot []byte // in header byte order, as OTS commitments are
+	Time       int64
+}
+
+// HeaderSource returns Bitcoin block headers by height.
+type HeaderSource interface {
+	BlockHeader(ctx context.Context, height uint64) (Header, error)
+}
+
+// EsploraSource fetches headers from an Esplora-compatible HTTP API, such as
+// blockstream.info or a self-hosted electrs.
+type EsploraSource struct {
+	base   string
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[uint64]Header
+}
+
+// NewEsploraSource creates a header source for the API at base.
+func NewEsploraSource(base string, client *http.Client) *EsploraSource {
+	return &EsploraSource{
+		base:   strings.TrimRight(base, "/"),
+		client: client,
+		cache:  make(map[uint64]Header),
+	}
+}
+
+// BlockHeader returns the header of the block at height.
+func (s *EsploraSource) BlockHeader(ctx context.Context, height uint64) (Header, error) {
+	s.mu.Lock()
+	h, ok := s.cache[height]
+	s.mu.Unlock()
+	if ok {
+		return h, nil
+	}
+
+	hash, err := s.get(ctx, "/block-height/"+strconv.FormatUint(height, 10))
+	if err != nil {
+		return Header{}, err
+	}
+	raw, err := s.get(ctx, "/block/"+strings.TrimSpace(string(hash)))
+	if err != nil {
+		return Header{}, err
+	}
+	var block struct {
+		MerkleRoot string `json:"merkle_root"`
+		Timestamp  int64  `json:"timestamp"`
+	}
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return Header{}, fmt.Errorf("decoding block: %w", err)
+	}
+	root, err := hex.DecodeString(block.MerkleRoot)
+	if err != nil || len(root) != 32 {
+		return Header{}, fmt.Errorf("invalid merkle root %q", block.MerkleRoot)
+	}
+	// The API shows the root byte-reversed, like block hashes
+	for i, j := 0, len(root)-1; i < j; i, j = i+1, j-1 {
+		root[i], root[j] = root[j], root[i]
+	}
+	h = Header{MerkleRoot: root, Time: block.Timestamp}
+
+	s.mu.Lock()
+	if len(s.cache) >= maxCachedHeaders {
+		s.cache = make(map[uint64]Header)
+	}
+	s.cache[height] = h
+	s.mu.Unlock()
+	return h, nil
+}
+
+// get fetches path from the API.
+func (s *EsploraSource) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bitcoin API %s: HTTP %d", path, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+}
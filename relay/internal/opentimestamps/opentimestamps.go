@@ -0,0 +1,418 @@
+// Package opentimestamps verifies NIP-03 attestations (kind 1040). The OTS
+// proof in an attestation is parsed and checked to commit to the event it
+// references; its Bitcoin attestations are checked against block headers
+// from a header source, and attestations still waiting on a calendar are
+// upgraded from the allowed calendars until one reaches a block. Outcomes
+// are kept in an in-memory index queryable by attested event and status.
+package opentimestamps
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// KindAttestation is the NIP-03 OpenTimestamps attestation kind.
+const KindAttestation = 1040
+
+// Verification statuses.
+const (
+	StatusVerified = "verified" // a Bitcoin attestation matches its block
+	StatusPending  = "pending"  // waiting on a calendar or the header source
+	StatusInvalid  = "invalid"  // malformed, not committing to the event, or not in its block
+)
+
+// maxCalendarResponse bounds a timestamp served by a calendar.
+const maxCalendarResponse = 10000
+
+// Attestation is the verification state of one kind 1040 event.
+type Attestation struct {
+	ID          string   `json:"id"`       // the attestation event
+	EventID     string   `json:"event_id"` // the attested event
+	Pubkey      string   `json:"pubkey"`
+	Status      string   `json:"status"`
+	BlockHeight uint64   `json:"block_height,omitempty"`
+	BlockTime   int64    `json:"block_time,omitempty"`
+	Calendars   []string `json:"calendars,omitempty"` // calendars of pending attestations
+	Error       string   `json:"error,omitempty"`
+	CheckedAt   int64    `json:"checked_at"`
+
+	// unresolved are the attestations rechecked by Upgrade: pending ones and
+	// Bitcoin ones whose header could not be fetched
+	unresolved []nips.OTSAttestation
+}
+
+// Filter selects attestations for List. Zero values match everything.
+type Filter struct {
+	EventID string
+	Status  string
+	Limit   int
+}
+
+// Verifier checks attestations in the background and indexes the outcome.
+type Verifier struct {
+	cfg       config.OpenTimestampsConfig
+	headers   HeaderSource
+	client    *http.Client
+	calendars map[string]bool
+	queue     chan nostr.Event
+
+	mu      sync.Mutex
+	entries map[string]*Attestation
+	order   []string // attestation IDs, oldest first
+}
+
+// New creates a verifier, filling in defaults for unset limits.
+func New(cfg config.OpenTimestampsConfig) *Verifier {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.UpgradeInterval <= 0 {
+		cfg.UpgradeInterval = time.Hour
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.MaxAttestations <= 0 {
+		cfg.MaxAttestations = 100000
+	}
+
+	client := &http.Client{Timeout: cfg.Timeout}
+	v := &Verifier{
+		cfg:       cfg,
+		client:    client,
+		calendars: make(map[string]bool, len(cfg.Calendars)),
+		queue:     make(chan nostr.Event, cfg.QueueSize),
+		entries:   make(map[string]*Attestation),
+	}
+	if cfg.BitcoinAPI != "" {
+		v.headers = NewEsploraSource(cfg.BitcoinAPI, client)
+	}
+	for _, c := range cfg.Calendars {
+		v.calendars[normalizeCalendar(c)] = true
+	}
+	return v
+}
+
+// SetHeaderSource replaces the source of Bitcoin block headers.
+func (v *Verifier) SetHeaderSource(src HeaderSource) {
+	v.headers = src
+}
+
+// Start launches the verification workers and the periodic upgrade of
+// pending attestations.
+func (v *Verifier) Start(ctx context.Context) {
+	for i := 0; i < v.cfg.Workers; i++ {
+		go v.worker(ctx)
+	}
+	go v.upgradeLoop(ctx)
+	logger.New("opentimestamps").Info("OpenTimestamps verification started",
+		zap.Int("workers", v.cfg.Workers),
+		zap.String("bitcoin_api", v.cfg.BitcoinAPI),
+		zap.Int("calendars", len(v.calendars)))
+}
+
+// Submit queues a stored attestation for verification without blocking.
+func (v *Verifier) Submit(evt *nostr.Event) {
+	if evt.Kind != KindAttestation {
+		return
+	}
+	select {
+	case v.queue <- *evt:
+	default:
+		metrics.OpenTimestamps.WithLabelValues("dropped").Inc()
+	}
+}
+
+func (v *Verifier) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-v.queue:
+			v.Verify(ctx, &evt)
+		}
+	}
+}
+
+func (v *Verifier) upgradeLoop(ctx context.Context) {
+	ticker := time.NewTicker(v.cfg.UpgradeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			v.Upgrade(ctx)
+		}
+	}
+}
+
+// Verify checks an attestation event and indexes the outcome.
+func (v *Verifier) Verify(ctx context.Context, evt *nostr.Event) Attestation {
+	a := &Attestation{ID: evt.ID, Pubkey: evt.PubKey}
+	if tag := evt.Tags.GetFirst([]string{"e", ""}); tag != nil && len(*tag) >= 2 {
+		a.EventID = strings.ToLower((*tag)[1])
+	}
+
+	if proof, err := v.parse(evt, a.EventID); err != nil {
+		a.Status, a.Error = StatusInvalid, err.Error()
+	} else {
+		v.resolve(ctx, a, proof.Attestations, true)
+	}
+	a.CheckedAt = time.Now().Unix()
+	metrics.OpenTimestamps.WithLabelValues(a.Status).Inc()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.putLocked(a)
+	return a.public()
+}
+
+// parse decodes the proof of evt and checks that it commits to eventID.
+func (v *Verifier) parse(evt *nostr.Event, eventID string) (*nips.OTSProof, error) {
+	if err := nips.ValidateOpenTimestampsAttestation(evt); err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(evt.Content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 content: %w", err)
+	}
+	proof, err := nips.ParseOTSProof(data)
+	if err != nil {
+		return nil, err
+	}
+	if digest := hex.EncodeToString(proof.Digest); digest != eventID {
+		return nil, fmt.Errorf("proof commits to %s, not the referenced event", digest)
+	}
+	return proof, nil
+}
+
+// resolve checks atts and sets the status of a. With fetchCalendars, pending
+// attestations are upgraded from the allowed calendars.
+func (v *Verifier) resolve(ctx context.Context, a *Attestation, atts []nips.OTSAttestation, fetchCalendars bool) {
+	a.unresolved, a.Calendars, a.Error = nil, nil, ""
+	var mismatched []string
+	for _, att := range atts {
+		switch att.Type {
+		case nips.OTSAttestationBitcoin:
+			ok, blockTime, err := v.checkBlock(ctx, att)
+			switch {
+			case err != nil:
+				a.unresolved = append(a.unresolved, att)
+				a.Error = err.Error()
+			case ok:
+				a.Status, a.BlockHeight, a.BlockTime = StatusVerified, att.Height, blockTime
+				a.unresolved, a.Calendars, a.Error = nil, nil, ""
+				return
+			default:
+				mismatched = append(mismatched, fmt.Sprint(att.Height))
+			}
+
+		case nips.OTSAttestationPending:
+			if fetchCalendars {
+				upgraded, err := v.upgradeFromCalendar(ctx, att)
+				if err != nil {
+					a.Error = err.Error()
+				}
+				for _, up := range upgraded {
+					if up.Type != nips.OTSAttestationBitcoin {
+						continue
+					}
+					ok, blockTime, err := v.checkBlock(ctx, up)
+					if err == nil && ok {
+						a.Status, a.BlockHeight, a.BlockTime = StatusVerified, up.Height, blockTime
+						a.unresolved, a.Calendars, a.Error = nil, nil, ""
+						return
+					}
+				}
+			}
+			a.unresolved = append(a.unresolved, att)
+			a.Calendars = append(a.Calendars, att.URI)
+		}
+	}
+
+	switch {
+	case len(a.unresolved) > 0:
+		a.Status = StatusPending
+	case len(mismatched) > 0:
+		a.Status = StatusInvalid
+		a.Error = "commitment not in the merkle root of block " + strings.Join(mismatched, ", ")
+	default:
+		a.Status = StatusInvalid
+		a.Error = "no Bitcoin or pending attestation"
+	}
+}
+
+// checkBlock reports whether the commitment of a Bitcoin attestation is the
+// merkle root of its block, returning the block time.
+func (v *Verifier) checkBlock(ctx context.Context, att nips.OTSAttestation) (bool, int64, error) {
+	if v.headers == nil {
+		return false, 0, fmt.Errorf("no Bitcoin header source configured")
+	}
+	header, err := v.headers.BlockHeader(ctx, att.Height)
+	if err != nil {
+		return false, 0, fmt.Errorf("block %d: %w", att.Height, err)
+	}
+	return bytes.Equal(att.Commitment, header.MerkleRoot), header.Time, nil
+}
+
+// upgradeFromCalendar asks the calendar of a pending attestation for the
+// timestamp of its commitment. Calendars that are not allowed are never
+// contacted; a commitment the calendar has not anchored yet yields nothing.
+func (v *Verifier) upgradeFromCalendar(ctx context.Context, att nips.OTSAttestation) ([]nips.OTSAttestation, error) {
+	calendar := normalizeCalendar(att.URI)
+	if !v.calendars[calendar] {
+		return nil, nil
+	}
+
+	url := calendar + "/timestamp/" + hex.EncodeToString(att.Commitment)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.opentimestamps.v1")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calendar %s: %w", calendar, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar %s: HTTP %d", calendar, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCalendarResponse))
+	if err != nil {
+		return nil, fmt.Errorf("calendar %s: %w", calendar, err)
+	}
+	atts, err := nips.ParseOTSTimestamp(body, att.Commitment)
+	if err != nil {
+		return nil, fmt.Errorf("calendar %s: %w", calendar, err)
+	}
+	return atts, nil
+}
+
+// Upgrade rechecks pending attestations: calendars are asked again and
+// headers that could not be fetched are looked up again.
+func (v *Verifier) Upgrade(ctx context.Context) {
+	v.mu.Lock()
+	pending := make([]Attestation, 0)
+	for _, a := range v.entries {
+		if a.Status == StatusPending {
+			pending = append(pending, *a)
+		}
+	}
+	v.mu.Unlock()
+
+	verified := 0
+	for i := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+		a := &pending[i]
+		v.resolve(ctx, a, a.unresolved, true)
+		a.CheckedAt = time.Now().Unix()
+		if a.Status != StatusPending {
+			metrics.OpenTimestamps.WithLabelValues(a.Status).Inc()
+			if a.Status == StatusVerified {
+				verified++
+			}
+		}
+
+		v.mu.Lock()
+		if _, ok := v.entries[a.ID]; ok {
+			v.entries[a.ID] = a
+		}
+		v.mu.Unlock()
+	}
+	if len(pending) > 0 {
+		logger.New("opentimestamps").Debug("Rechecked pending attestations",
+			zap.Int("pending", len(pending)),
+			zap.Int("verified", verified))
+	}
+}
+
+// putLocked indexes a, dropping the oldest attestations beyond the limit.
+func (v *Verifier) putLocked(a *Attestation) {
+	if _, exists := v.entries[a.ID]; !exists {
+		v.order = append(v.order, a.ID)
+	}
+	v.entries[a.ID] = a
+	for len(v.order) > v.cfg.MaxAttestations {
+		delete(v.entries, v.order[0])
+		v.order = v.order[1:]
+	}
+}
+
+// Get returns the indexed state of an attestation event.
+func (v *Verifier) Get(id string) (Attestation, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	a, ok := v.entries[strings.ToLower(id)]
+	if !ok {
+		return Attestation{}, false
+	}
+	return a.public(), true
+}
+
+// List returns the number of indexed attestations by status and up to
+// f.Limit attestations matching f, most recently checked first.
+func (v *Verifier) List(f Filter) (map[string]int, []Attestation) {
+	eventID := strings.ToLower(f.EventID)
+	byStatus := map[string]int{StatusVerified: 0, StatusPending: 0, StatusInvalid: 0}
+
+	v.mu.Lock()
+	list := make([]Attestation, 0)
+	for _, a := range v.entries {
+		byStatus[a.Status]++
+		if (eventID != "" && a.EventID != eventID) || (f.Status != "" && a.Status != f.Status) {
+			continue
+		}
+		list = append(list, a.public())
+	}
+	v.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CheckedAt != list[j].CheckedAt {
+			return list[i].CheckedAt > list[j].CheckedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+	if f.Limit > 0 && len(list) > f.Limit {
+		list = list[:f.Limit]
+	}
+	return byStatus, list
+}
+
+// public returns a copy of a without its internal state.
+func (a *Attestation) public() Attestation {
+	out := *a
+	out.unresolved = nil
+	out.Calendars = append([]string(nil), a.Calendars...)
+	return out
+}
+
+// normalizeCalendar returns a calendar URL without trailing slashes.
+func normalizeCalendar(url string) string {
+	return strings.TrimRight(strings.TrimSpace(url), "/")
+}
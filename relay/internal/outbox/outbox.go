@@ -0,0 +1,423 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+const (
+	// publishTimeout bounds a single connect or publish attempt.
+	publishTimeout = 10 * time.Second
+	// seenTTL is how long published event IDs are remembered for dedupe.
+	seenTTL = time.Hour
+	// maxSeen triggers pruning of the dedupe set.
+	maxSeen = 10000
+	// hintLookupTimeout bounds a NIP-65 read-relay lookup.
+	hintLookupTimeout = 3 * time.Second
+	// hintIdleTTL is how long a hint relay stays connected without events.
+	hintIdleTTL = 30 * time.Minute
+)
+
+// IsBroadcastKind reports whether a relay-signed event kind is published to external relays.
+func IsBroadcastKind(kind int) bool {
+	switch {
+	case kind >= 39000 && kind <= 39003: // NIP-29 group metadata
+		return true
+	case kind == 13534 || kind == 8000 || kind == 8001: // NIP-43 membership
+		return true
+	case kind == 30166 || kind == 10166: // NIP-66 discovery and monitor announcements
+		return true
+	case kind == 1776: // relay key transition
+		return true
+	case kind == 9735: // NIP-57 zap receipts of the relay's lightning address
+		return true
+	}
+	return false
+}
+
+// isAddressedKind reports whether a broadcast kind is addressed to the pubkeys
+// in its "p" tags, and so should reach their NIP-65 read relays.
+func isAddressedKind(kind int) bool {
+	return kind == 8000 || kind == 8001 // NIP-43 member added / removed
+}
+
+// listedRelays returns the relays an event names for its own delivery: a
+// zap receipt goes to the relays tag of the zap request it embeds (NIP-57).
+func listedRelays(evt *nostr.Event) []string {
+	if evt.Kind != 9735 {
+		return nil
+	}
+	description := evt.Tags.GetFirst([]string{"description", ""})
+	if description == nil {
+		return nil
+	}
+	var request nostr.Event
+	if err := json.Unmarshal([]byte((*description)[1]), &request); err != nil {
+		return nil
+	}
+	if relays := request.Tags.GetFirst([]string{"relays"}); relays != nil {
+		return (*relays)[1:]
+	}
+	return nil
+}
+
+// HintResolver returns the NIP-65 read relays of a pubkey.
+type HintResolver func(ctx context.Context, pubkey string) []string
+
+// Outbox publishes relay-signed events to a configured set of external relays,
+// retrying with exponential backoff and skipping events it has already sent.
+type Outbox struct {
+	cfg         config.OutboxConfig
+	relayPubkey string
+	targets     []*target
+
+	mu   sync.Mutex           // guards seen and relayPubkey
+	seen map[string]time.Time // event ID -> first publish time
+
+	// NIP-65 delivery to the read relays of addressed pubkeys
+	hints    HintResolver
+	hinted   map[string]*target // url -> lazily started target
+	hintedMu sync.Mutex         // guards hinted and the lastUsed of its targets
+	ctx      context.Context
+}
+
+// target is a single external relay with its own queue and connection.
+type target struct {
+	url   string
+	queue chan nostr.Event
+	conn  *nostr.Relay
+
+	// Hint relays only: they come from user-published relay lists, so their
+	// address is checked before dialling, and idle ones are stopped.
+	hinted   bool
+	lastUsed time.Time
+	cancel   context.CancelFunc
+}
+
+// New creates an outbox for events signed by relayPubkey.
+func New(cfg config.OutboxConfig, relayPubkey string) *Outbox {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1000
+	}
+	o := &Outbox{
+		cfg:         cfg,
+		relayPubkey: relayPubkey,
+		seen:        make(map[string]time.Time),
+		hinted:      make(map[string]*target),
+	}
+	for _, url := range cfg.Relays {
+		o.targets = append(o.targets, &target{
+			url:   nostr.NormalizeURL(url),
+			queue: make(chan nostr.Event, queueSize),
+		})
+	}
+	return o
+}
+
+// SetRelayPubkey switches the author of published events after a relay key
+// rotation.
+func (o *Outbox) SetRelayPubkey(pubkey string) {
+	o.mu.Lock()
+	o.relayPubkey = pubkey
+	o.mu.Unlock()
+}
+
+// SetHintResolver enables delivery of addressed events to NIP-65 read relays.
+// Must be called before Start.
+func (o *Outbox) SetHintResolver(resolver HintResolver) {
+	if o.cfg.UseRelayHints {
+		o.hints = resolver
+	}
+}
+
+// Start launches one publishing goroutine per external relay. They exit when ctx is canceled.
+func (o *Outbox) Start(ctx context.Context) {
+	o.ctx = ctx
+	for _, t := range o.targets {
+		go o.run(ctx, t)
+	}
+	logger.New("outbox").Info("Outbox started",
+		zap.Int("relays", len(o.targets)))
+}
+
+// Publish enqueues a relay-signed event for every external relay without blocking.
+// Events of other authors or kinds, and events already published, are ignored.
+func (o *Outbox) Publish(evt *nostr.Event) bool {
+	if evt == nil {
+		return false
+	}
+	listed := listedRelays(evt)
+	if len(o.targets) == 0 && o.hints == nil && len(listed) == 0 {
+		return false
+	}
+	o.mu.Lock()
+	relayPubkey := o.relayPubkey
+	o.mu.Unlock()
+	if evt.PubKey != relayPubkey || !IsBroadcastKind(evt.Kind) {
+		return false
+	}
+	if !o.markSeen(evt.ID) {
+		metrics.OutboxEvents.WithLabelValues("duplicate").Inc()
+		return false
+	}
+
+	for _, t := range o.targets {
+		o.enqueue(t, evt)
+	}
+	addressed := o.hints != nil && isAddressedKind(evt.Kind)
+	if o.ctx != nil && (addressed || len(listed) > 0) {
+		go o.publishToHints(*evt, listed, addressed)
+	}
+	return true
+}
+
+// enqueue adds an event to a target's queue without blocking.
+func (o *Outbox) enqueue(t *target, evt *nostr.Event) {
+	select {
+	case t.queue <- *evt:
+	default:
+		metrics.OutboxEvents.WithLabelValues("dropped").Inc()
+		logger.New("outbox").Warn("Outbox queue full, dropping event",
+			zap.String("relay", t.url),
+			zap.String("event_id", evt.ID))
+	}
+}
+
+// publishToHints delivers an event to the relays it lists and, when
+// addressed, to the read relays of every pubkey in its "p" tags, skipping
+// relays already configured as targets and relays on non-public addresses.
+// The address is checked again on every dial, since the name may resolve
+// differently by then.
+func (o *Outbox) publishToHints(evt nostr.Event, listed []string, addressed bool) {
+	skip := make(map[string]bool, len(o.targets))
+	for _, t := range o.targets {
+		skip[t.url] = true
+	}
+	deliver := func(ctx context.Context, urls []string) {
+		for _, url := range urls {
+			url = nostr.NormalizeURL(url)
+			if skip[url] {
+				continue
+			}
+			skip[url] = true
+			if err := checkPublicRelay(ctx, url); err != nil {
+				logger.New("outbox").Debug("Skipping hint relay", zap.String("relay", url), zap.Error(err))
+				continue
+			}
+			if t := o.hintedTarget(url); t != nil {
+				o.enqueue(t, &evt)
+			}
+		}
+	}
+
+	if len(listed) > 0 {
+		ctx, cancel := context.WithTimeout(o.ctx, hintLookupTimeout)
+		deliver(ctx, listed)
+		cancel()
+	}
+	if !addressed {
+		return
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "p" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(o.ctx, hintLookupTimeout)
+		deliver(ctx, o.hints(ctx, tag[1]))
+		cancel()
+	}
+}
+
+// hintedTarget returns the target for a hint relay, starting it on first use.
+// Targets idle for hintIdleTTL are stopped, and once MAX_HINT_RELAYS distinct
+// relays are in use the least recently used one makes room.
+func (o *Outbox) hintedTarget(url string) *target {
+	o.hintedMu.Lock()
+	defer o.hintedMu.Unlock()
+
+	now := time.Now()
+	if t, ok := o.hinted[url]; ok {
+		t.lastUsed = now
+		return t
+	}
+	if o.cfg.MaxHintRelays <= 0 {
+		return nil
+	}
+
+	var oldest *target
+	for _, t := range o.hinted {
+		if now.Sub(t.lastUsed) > hintIdleTTL {
+			o.stopHintedLocked(t)
+		} else if oldest == nil || t.lastUsed.Before(oldest.lastUsed) {
+			oldest = t
+		}
+	}
+	if len(o.hinted) >= o.cfg.MaxHintRelays && oldest != nil {
+		logger.New("outbox").Debug("Hint relay limit reached, evicting least recently used relay",
+			zap.String("relay", oldest.url))
+		o.stopHintedLocked(oldest)
+	}
+
+	queueSize := o.cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1000
+	}
+	ctx, cancel := context.WithCancel(o.ctx)
+	t := &target{url: url, queue: make(chan nostr.Event, queueSize), hinted: true, lastUsed: now, cancel: cancel}
+	o.hinted[url] = t
+	go o.run(ctx, t)
+	return t
+}
+
+// stopHintedLocked forgets a hint relay and stops its goroutine, dropping
+// the events still queued for it. Callers hold o.hintedMu.
+func (o *Outbox) stopHintedLocked(t *target) {
+	delete(o.hinted, t.url)
+	t.cancel()
+}
+
+// checkPublicRelay returns an error unless every address the relay URL
+// resolves to is public, so relay lists cannot point the outbox at loopback,
+// private or link-local services.
+func checkPublicRelay(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid relay URL: %w", err)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("relay URL %q has no host", rawURL)
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return fmt.Errorf("failed to resolve relay host: %w", err)
+		}
+	}
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+			return fmt.Errorf("relay host %s resolves to non-public address %s", host, addr)
+		}
+	}
+	return nil
+}
+
+// markSeen records an event ID, returning false if it was already recorded.
+func (o *Outbox) markSeen(id string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.seen[id]; ok {
+		return false
+	}
+	now := time.Now()
+	if len(o.seen) >= maxSeen {
+		for seenID, at := range o.seen {
+			if now.Sub(at) > seenTTL {
+				delete(o.seen, seenID)
+			}
+		}
+	}
+	o.seen[id] = now
+	return true
+}
+
+// run drains a target's queue until ctx is canceled.
+func (o *Outbox) run(ctx context.Context, t *target) {
+	defer func() {
+		if t.conn != nil {
+			_ = t.conn.Close()
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-t.queue:
+			o.deliver(ctx, t, evt)
+		}
+	}
+}
+
+// deliver publishes an event to a target, reconnecting and retrying on failure.
+func (o *Outbox) deliver(ctx context.Context, t *target, evt nostr.Event) {
+	log := logger.New("outbox")
+	delay := o.cfg.RetryDelay
+	if delay <= 0 {
+		delay = 5 * time.Second
+	}
+
+	var err error
+	for attempt := 0; attempt <= o.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		if err = o.publishOnce(ctx, t, evt); err == nil {
+			metrics.OutboxEvents.WithLabelValues("published").Inc()
+			log.Debug("Published relay-signed event",
+				zap.String("relay", t.url),
+				zap.String("event_id", evt.ID),
+				zap.Int("kind", evt.Kind))
+			return
+		}
+
+		// Drop the connection so the next attempt reconnects
+		if t.conn != nil {
+			_ = t.conn.Close()
+			t.conn = nil
+		}
+	}
+
+	metrics.OutboxEvents.WithLabelValues("failed").Inc()
+	log.Warn("Failed to publish relay-signed event",
+		zap.String("relay", t.url),
+		zap.String("event_id", evt.ID),
+		zap.Int("attempts", o.cfg.MaxRetries+1),
+		zap.Error(err))
+}
+
+// publishOnce makes a single connect-and-publish attempt.
+func (o *Outbox) publishOnce(ctx context.Context, t *target, evt nostr.Event) error {
+	if t.conn == nil || !t.conn.IsConnected() {
+		connectCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		defer cancel()
+		if t.hinted {
+			if err := checkPublicRelay(connectCtx, t.url); err != nil {
+				return err
+			}
+		}
+		conn, err := nostr.RelayConnect(connectCtx, t.url)
+		if err != nil {
+			return err
+		}
+		t.conn = conn
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+	return t.conn.Publish(publishCtx, evt)
+}
@@ -0,0 +1,51 @@
+package relay
+
+import (
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+)
+
+// EVENT acknowledgement modes, see config.AckConfig.
+const (
+	ackQueued  = "queued"
+	ackDurable = "durable"
+	ackPending = "pending"
+)
+
+// defaultAckTimeout is the durable-mode latency budget when ACK.TIMEOUT is unset.
+const defaultAckTimeout = 2 * time.Second
+
+// ackPendingMessage tells a client its event is accepted but not yet written.
+const ackPendingMessage = "accepted-pending: event queued for storage"
+
+// ackMode returns the configured acknowledgement mode.
+func ackMode(cfg config.AckConfig) string {
+	switch cfg.Mode {
+	case ackDurable, ackPending:
+		return cfg.Mode
+	}
+	return ackQueued
+}
+
+// sendDurableOK answers OK once the event is written, with OK false when
+// storage gave up on it, or as accepted-pending when the write outlasts the
+// latency budget.
+func (c *WsConnection) sendDurableOK(eventID string, done <-chan error, budget time.Duration) {
+	if budget <= 0 {
+		budget = defaultAckTimeout
+	}
+	timer := time.NewTimer(budget)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			c.sendOK(eventID, false, "error: failed to store event")
+			return
+		}
+		c.sendOK(eventID, true, "")
+	case <-timer.C:
+		c.sendOK(eventID, true, ackPendingMessage)
+	}
+}
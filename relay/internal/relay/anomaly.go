@@ -0,0 +1,111 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Shugur-Network/relay/internal/anomaly"
+	"github.com/Shugur-Network/relay/internal/audit"
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// anomalyInstance is the package-level client anomaly detector (nil when disabled).
+var anomalyInstance *anomaly.Detector
+
+// GetAnomalyDetector returns the client anomaly detector, or nil when it is disabled.
+func GetAnomalyDetector() *anomaly.Detector {
+	return anomalyInstance
+}
+
+// InitAnomalyDetector creates the client anomaly detector and logs, counts
+// and audits the alerts it raises. Called from NewServer.
+func InitAnomalyDetector(cfg *config.Config) *anomaly.Detector {
+	if !cfg.Anomaly.Enabled {
+		anomalyInstance = nil
+		return nil
+	}
+
+	d := anomaly.New(cfg.Anomaly)
+	d.SetAlertHook(func(a anomaly.Alert) {
+		metrics.AnomalyAlerts.WithLabelValues(a.Feature).Inc()
+		logger.New("anomaly").Warn("Client deviates from baseline",
+			zap.String("subject", a.Subject),
+			zap.String("feature", a.Feature),
+			zap.Float64("value", a.Value),
+			zap.Float64("baseline", a.Baseline),
+			zap.Float64("deviation", a.Deviation))
+		recordAudit(audit.Entry{
+			Actor:   audit.ActorSystem,
+			Source:  "anomaly",
+			Action:  "anomalyalert",
+			Params:  []string{a.Subject, a.Feature},
+			Outcome: audit.OutcomeSuccess,
+			Message: fmt.Sprintf("%s %.2f is %.1f standard deviations above the baseline %.2f", a.Feature, a.Value, a.Deviation, a.Baseline),
+		})
+	})
+	anomalyInstance = d
+	return d
+}
+
+// startAnomalyDetector closes detection windows until ctx is canceled.
+func startAnomalyDetector(ctx context.Context) {
+	if d := GetAnomalyDetector(); d != nil {
+		d.Start(ctx)
+	}
+}
+
+// connectionFingerprint returns the fingerprint of a new connection, "" when
+// anomaly detection is disabled.
+func connectionFingerprint(r *http.Request) string {
+	if GetAnomalyDetector() == nil {
+		return ""
+	}
+	return anomaly.Fingerprint(r.Header)
+}
+
+// anomalySubjects returns the subjects the activity of c is counted under:
+// its IP, its fingerprint and its authenticated pubkey.
+func (c *WsConnection) anomalySubjects() []string {
+	subjects := []string{"ip:" + c.realClientIP}
+	if c.fingerprint != "" {
+		subjects = append(subjects, "fingerprint:"+c.fingerprint)
+	}
+	if pubkey := c.getAuthenticatedPubkey(); pubkey != "" {
+		subjects = append(subjects, "pubkey:"+pubkey)
+	}
+	return subjects
+}
+
+// countAnomalyRequest counts a REQ with filters issued on c.
+func (c *WsConnection) countAnomalyRequest(filters []nostr.Filter) {
+	d := GetAnomalyDetector()
+	if d == nil {
+		return
+	}
+	broad := 0
+	for _, f := range filters {
+		if anomaly.IsBroadFilter(f) {
+			broad++
+		}
+	}
+	d.Request(c.anomalySubjects(), broad)
+}
+
+// countAnomalyChurn counts a subscription closed or replaced on c.
+func (c *WsConnection) countAnomalyChurn() {
+	if d := GetAnomalyDetector(); d != nil {
+		d.Churn(c.anomalySubjects())
+	}
+}
+
+// countAnomalyPublished counts an event answered on c with OK accepted.
+func (c *WsConnection) countAnomalyPublished(accepted bool) {
+	if d := GetAnomalyDetector(); d != nil {
+		d.Published(c.anomalySubjects(), accepted)
+	}
+}
@@ -0,0 +1,136 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/apphandlers"
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// App handlers API: the NIP-89 applications that handle a kind, from the
+// application handler index.
+//
+//	GET /api/handlers?kind=<n>[&platform=web&limit=20]
+//
+// Handlers are those whose handler information (kind 31990) lists the kind,
+// with their name and links, ranked by the number of distinct users whose
+// recommendation (kind 31989) for the kind points at them.
+
+const (
+	// appHandlersLoadPageSize is how many events are read at a time on startup.
+	appHandlersLoadPageSize = 500
+	appHandlersDefaultLimit = 20
+	appHandlersMaxLimit     = 100
+)
+
+// appHandlersInstance is the package-level application handler index (nil when disabled).
+var appHandlersInstance *apphandlers.Index
+
+// GetAppHandlers returns the application handler index, or nil when it is disabled.
+func GetAppHandlers() *apphandlers.Index {
+	return appHandlersInstance
+}
+
+// InitAppHandlers creates the application handler index and keeps it in
+// step with newly stored events. The stored handlers and recommendations
+// are loaded by startAppHandlers. Called from NewServer.
+func InitAppHandlers(cfg *config.Config, db *storage.DB) *apphandlers.Index {
+	if !cfg.AppHandlers.Enabled || db == nil {
+		appHandlersInstance = nil
+		return nil
+	}
+	index := apphandlers.New(cfg.AppHandlers)
+	db.SetAppHandlersObserver(index.Observe)
+	appHandlersInstance = index
+	return index
+}
+
+// startAppHandlers loads the stored handlers and recommendations into the
+// index. Called from ListenAndServe.
+func startAppHandlers(ctx context.Context, db *storage.DB) {
+	index := GetAppHandlers()
+	if index == nil {
+		return
+	}
+	go loadAppHandlers(ctx, db, index)
+}
+
+// loadAppHandlers indexes the stored handlers and recommendations, oldest
+// first, so later versions replace earlier ones.
+func loadAppHandlers(ctx context.Context, db *storage.DB, index *apphandlers.Index) {
+	log := logger.New("apphandlers")
+
+	// A since-only filter pages oldest first
+	since := nostr.Timestamp(0)
+	f := nostr.Filter{
+		Kinds: []int{apphandlers.KindRecommendation, apphandlers.KindHandler},
+		Since: &since,
+		Limit: appHandlersLoadPageSize,
+	}
+	var cursor storage.PageCursor
+	for {
+		events, err := db.GetEvents(storage.WithPageCursor(ctx, cursor), f)
+		if err != nil {
+			log.Warn("Failed to load app handlers", zap.Error(err))
+			return
+		}
+		for i := range events {
+			evt := events[i]
+			index.Add(&evt)
+		}
+		if len(events) < appHandlersLoadPageSize {
+			break
+		}
+		cursor = storage.CursorAfter(events[len(events)-1])
+	}
+	handlers, recommendations := index.Len()
+	log.Info("Indexed NIP-89 app handlers",
+		zap.Int("handlers", handlers),
+		zap.Int("recommendations", recommendations))
+}
+
+// appHandlersAPIResponse is the body of /api/handlers.
+type appHandlersAPIResponse struct {
+	Kind     int                   `json:"kind"`
+	Handlers []apphandlers.Handler `json:"handlers"`
+	Count    int                   `json:"count"`
+}
+
+// handleAppHandlersAPI serves GET /api/handlers.
+func (s *Server) handleAppHandlersAPI(w http.ResponseWriter, r *http.Request) {
+	index := GetAppHandlers()
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && index != nil) {
+		return
+	}
+	query := r.URL.Query()
+	kind, err := strconv.Atoi(query.Get("kind"))
+	if err != nil || kind < 0 || kind > 65535 {
+		writeEventsAPIError(w, r, "INVALID_KIND", "kind must be an event kind between 0 and 65535")
+		return
+	}
+	q := apphandlers.Query{Kind: kind, Platform: query.Get("platform"), Limit: appHandlersDefaultLimit}
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > appHandlersMaxLimit {
+			writeEventsAPIError(w, r, "INVALID_LIMIT", "limit must be between 1 and "+strconv.Itoa(appHandlersMaxLimit))
+			return
+		}
+		q.Limit = n
+	}
+
+	db := s.node.DB()
+	response := appHandlersAPIResponse{Kind: kind, Handlers: []apphandlers.Handler{}}
+	for _, h := range index.ForKind(q) {
+		if visibleAnonymously(db, h.Event) {
+			response.Handlers = append(response.Handlers, h)
+		}
+	}
+	response.Count = len(response.Handlers)
+	writeEventsAPIJSON(w, response)
+}
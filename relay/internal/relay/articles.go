@@ -0,0 +1,202 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/clientip"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"github.com/Shugur-Network/relay/internal/web"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// NIP-23 article pages: stored long-form posts rendered as HTML with Open
+// Graph tags, so links to articles hosted here unfurl in chat apps and read
+// in a browser.
+//
+//	GET /a/<naddr>
+//	GET /article/30023:<pubkey>:<d>
+//
+// Articles follow the visibility rules of the HTTP query API: nothing is
+// served when reading requires NIP-42 AUTH, and withheld or group-restricted
+// articles are not found.
+
+// KindLongForm is the NIP-23 long-form article kind.
+const KindLongForm = 30023
+
+// articleLookupTimeout bounds the storage queries of one article page.
+const articleLookupTimeout = 5 * time.Second
+
+// handleArticle serves GET /a/<naddr> and /article/<kind>:<pubkey>:<d>.
+func (s *Server) handleArticle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		errors.HandleHTTPError(w, r, errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed."))
+		return
+	}
+	if !s.fullCfg.Web.Articles.Enabled {
+		errors.HandleHTTPError(w, r, errors.NotFoundError(r.URL.Path).
+			WithUserMessage("Article pages are disabled on this relay."))
+		return
+	}
+	if cfg := s.fullCfg; cfg.Relay.AuthRequired || cfg.Relay.AccessMode == "private" {
+		errors.HandleHTTPError(w, r, errors.AuthenticationError("this relay requires NIP-42 authentication").
+			WithUserMessage("This relay requires authentication, connect with a websocket client."))
+		return
+	}
+
+	addr, err := parseArticleAddress(r.URL.Path)
+	if err != nil {
+		writeEventsAPIError(w, r, "INVALID_ADDRESS", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), articleLookupTimeout)
+	defer cancel()
+	if tenants := GetTenants(); tenants != nil {
+		ctx = storage.WithTenant(ctx, tenants.ForHost(r.Host).ID())
+	}
+
+	db := s.node.DB()
+	events, err := db.GetEvents(ctx, nostr.Filter{
+		Kinds:   []int{addr.Kind},
+		Authors: []string{addr.PublicKey},
+		Tags:    nostr.TagMap{"d": []string{addr.Identifier}},
+		Limit:   1,
+	})
+	if err != nil {
+		logger.Warn("Article lookup failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("article query", err))
+		return
+	}
+	if len(events) == 0 || !visibleAnonymously(db, &events[0]) {
+		errors.HandleHTTPError(w, r, errors.NotFoundError("article"))
+		return
+	}
+	evt := &events[0]
+
+	base := s.publicBaseURL(r)
+	naddr, _ := nips.EncodeNaddr(evt.Kind, evt.PubKey, addr.Identifier)
+	hinted := naddr
+	if relayURL := s.cfg.PublicURL; relayURL != "" {
+		hinted, _ = nips.EncodeNaddr(evt.Kind, evt.PubKey, addr.Identifier, relayURL)
+	}
+
+	article := web.Article{
+		Title:        articleTag(evt, "title"),
+		Summary:      articleTag(evt, "summary"),
+		Image:        articleTag(evt, "image"),
+		Content:      evt.Content,
+		PublishedAt:  evt.CreatedAt.Time(),
+		UpdatedAt:    evt.CreatedAt.Time(),
+		AuthorName:   s.articleAuthorName(ctx, evt.PubKey),
+		AuthorNpub:   npub(evt.PubKey),
+		CanonicalURL: base + "/a/" + naddr,
+		Naddr:        hinted,
+	}
+	if ts, err := strconv.ParseInt(articleTag(evt, "published_at"), 10, 64); err == nil && ts > 0 {
+		article.PublishedAt = time.Unix(ts, 0)
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "t" && tag[1] != "" {
+			article.Hashtags = append(article.Hashtags, tag[1])
+		}
+	}
+
+	s.webHandler.RenderArticle(w, article)
+}
+
+// parseArticleAddress reads the article address from an /a/<naddr> or
+// /article/<kind>:<pubkey>:<d> path. Only NIP-23 articles are served.
+func parseArticleAddress(path string) (nostr.EntityPointer, error) {
+	var addr nostr.EntityPointer
+	if entity, ok := strings.CutPrefix(path, "/a/"); ok {
+		prefix, value, err := nips.DecodeNIP19(entity)
+		if err != nil {
+			return addr, fmt.Errorf("invalid naddr")
+		}
+		if prefix != "naddr" {
+			return addr, fmt.Errorf("address must be an naddr")
+		}
+		addr = value.(nostr.EntityPointer)
+	} else {
+		parts := strings.SplitN(strings.TrimPrefix(path, "/article/"), ":", 3)
+		if len(parts) != 3 {
+			return addr, fmt.Errorf("address must be <kind>:<pubkey>:<d>")
+		}
+		kind, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return addr, fmt.Errorf("invalid kind in address")
+		}
+		addr = nostr.EntityPointer{Kind: kind, PublicKey: strings.ToLower(parts[1]), Identifier: parts[2]}
+	}
+
+	if addr.Kind != KindLongForm {
+		return addr, fmt.Errorf("only kind 30023 articles are rendered")
+	}
+	if !nostr.IsValid32ByteHex(addr.PublicKey) {
+		return addr, fmt.Errorf("address pubkey must be 64 hex characters")
+	}
+	return addr, nil
+}
+
+// publicBaseURL returns the http(s) origin links to the relay are built on:
+// the relay's public URL with its websocket scheme swapped, or the request host.
+func (s *Server) publicBaseURL(r *http.Request) string {
+	if u, err := url.Parse(s.cfg.PublicURL); err == nil && u.Host != "" {
+		switch u.Scheme {
+		case "wss", "https":
+			return "https://" + u.Host
+		case "ws", "http":
+			return "http://" + u.Host
+		}
+	}
+	scheme := "http"
+	if clientip.Scheme(r) == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// articleAuthorName returns the display name of pubkey from its stored
+// profile, or "" when there is none.
+func (s *Server) articleAuthorName(ctx context.Context, pubkey string) string {
+	events, err := s.node.DB().GetEvents(ctx, nostr.Filter{
+		Kinds:   []int{0},
+		Authors: []string{pubkey},
+		Limit:   1,
+	})
+	if err != nil || len(events) == 0 {
+		return ""
+	}
+	var profile struct {
+		DisplayName string `json:"display_name"`
+		Name        string `json:"name"`
+	}
+	if json.Unmarshal([]byte(events[0].Content), &profile) != nil {
+		return ""
+	}
+	if name := strings.TrimSpace(profile.DisplayName); name != "" {
+		return name
+	}
+	return strings.TrimSpace(profile.Name)
+}
+
+// articleTag returns the value of the first name tag of evt.
+func articleTag(evt *nostr.Event, name string) string {
+	if tag := evt.Tags.GetFirst([]string{name, ""}); tag != nil && len(*tag) >= 2 {
+		return strings.TrimSpace((*tag)[1])
+	}
+	return ""
+}
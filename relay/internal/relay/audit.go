@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/audit"
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// auditInstance is the package-level audit log (nil when auditing is disabled).
+var auditInstance *audit.Log
+
+// GetAuditLog returns the audit log, or nil when auditing is disabled.
+func GetAuditLog() *audit.Log {
+	return auditInstance
+}
+
+// InitAuditLog opens the audit log. Called from NewServer; a log that cannot
+// be opened disables auditing rather than keeping the relay down.
+func InitAuditLog(cfg *config.Config) *audit.Log {
+	if auditInstance != nil {
+		_ = auditInstance.Close()
+		auditInstance = nil
+	}
+	if !cfg.Audit.Enabled || cfg.Audit.Path == "" {
+		return nil
+	}
+
+	l, err := audit.Open(cfg.Audit.Path)
+	if err != nil {
+		logger.Error("Failed to open audit log, auditing disabled",
+			zap.String("path", cfg.Audit.Path),
+			zap.Error(err))
+		return nil
+	}
+	auditInstance = l
+	return l
+}
+
+// recordAudit appends an entry to the audit log when auditing is enabled.
+func recordAudit(e audit.Entry) {
+	l := GetAuditLog()
+	if l == nil {
+		return
+	}
+	if err := l.Record(e); err != nil {
+		logger.Error("Failed to write audit entry",
+			zap.String("source", e.Source),
+			zap.String("action", e.Action),
+			zap.Error(err))
+	}
+}
+
+// auditGroupModeration records a NIP-29 moderation event (kinds 9000-9009)
+// with its tags as parameters.
+func auditGroupModeration(evt *nostr.Event, accepted bool, reason, clientIP string) {
+	if evt.Kind < 9000 || evt.Kind > 9009 {
+		return
+	}
+
+	params := []string{"event:" + evt.ID}
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 {
+			params = append(params, tag[0]+":"+tag[1])
+		}
+	}
+	outcome := audit.OutcomeSuccess
+	if !accepted {
+		outcome = audit.OutcomeDenied
+	}
+	recordAudit(audit.Entry{
+		Actor:    evt.PubKey,
+		Source:   "nip29",
+		Action:   groupModerationAction(evt.Kind),
+		Params:   params,
+		Outcome:  outcome,
+		Message:  reason,
+		ClientIP: clientIP,
+	})
+}
+
+// groupModerationAction names a NIP-29 moderation kind.
+func groupModerationAction(kind int) string {
+	switch kind {
+	case 9000:
+		return "put-user"
+	case 9001:
+		return "remove-user"
+	case 9002:
+		return "edit-metadata"
+	case 9005:
+		return "delete-event"
+	case kindMuteUser:
+		return "mute-user"
+	case 9007:
+		return "create-group"
+	case 9008:
+		return "delete-group"
+	case 9009:
+		return "create-invite"
+	default:
+		return "kind-" + strconv.Itoa(kind)
+	}
+}
+
+// --- Audit Log ---
+
+func (s *Server) mgmtListAuditLog(params []string) (interface{}, string) {
+	l := GetAuditLog()
+	if l == nil {
+		return nil, "audit log is disabled"
+	}
+
+	limit, before := 50, int64(0)
+	if len(params) > 0 && params[0] != "" {
+		n, err := strconv.Atoi(params[0])
+		if err != nil || n < 1 || n > 500 {
+			return nil, "invalid limit: must be between 1 and 500"
+		}
+		limit = n
+	}
+	if len(params) > 1 && params[1] != "" {
+		n, err := strconv.ParseInt(params[1], 10, 64)
+		if err != nil || n < 0 {
+			return nil, "invalid cursor: must be a non-negative entry id"
+		}
+		before = n
+	}
+
+	entries, next, err := l.List(limit, before)
+	if err != nil {
+		return nil, "failed to read audit log: " + err.Error()
+	}
+	return map[string]interface{}{
+		"entries":     entries,
+		"next_cursor": next,
+	}, ""
+}
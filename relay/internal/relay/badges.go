@@ -0,0 +1,54 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// kindBadgeDefinition is the NIP-58 badge definition kind.
+const kindBadgeDefinition = 30009
+
+// checkBadgeAward checks a badge award against the definition it references.
+// Only the author of a badge may award it, so an award whose issuer is not
+// the definition's author is a forgery. In strict mode the definition must
+// also be stored on the relay; in lenient mode awards of badges defined
+// elsewhere are accepted as they are.
+func (pv *PluginValidator) checkBadgeAward(event *nostr.Event) error {
+	author, d := nips.BadgeAwardDefinition(event)
+	if author != event.PubKey {
+		return fmt.Errorf("badge award must be issued by the badge definition's author")
+	}
+	strict := pv.config.Relay.Badges.StrictAwards
+	if pv.db == nil {
+		if strict {
+			return fmt.Errorf("badge definition could not be verified")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defs, err := pv.db.GetEvents(ctx, nostr.Filter{
+		Kinds:   []int{kindBadgeDefinition},
+		Authors: []string{author},
+		Tags:    nostr.TagMap{"d": []string{d}},
+		Limit:   1,
+	})
+	if err != nil {
+		logger.Warn("Badge definition lookup failed", zap.String("event_id", event.ID), zap.Error(err))
+		if strict {
+			return fmt.Errorf("badge definition could not be verified")
+		}
+		return nil
+	}
+	if len(defs) == 0 && strict {
+		return fmt.Errorf("badge definition %d:%s:%s is not stored on this relay", kindBadgeDefinition, author, d)
+	}
+	return nil
+}
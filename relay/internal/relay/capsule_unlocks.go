@@ -0,0 +1,256 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/capsules"
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/domain"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/signer"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Time capsule unlocks: the relay tracks when each stored NIP-XX time
+// capsule (kind 1041) reaches its drand round and, at that moment, publishes
+// a relay-signed ephemeral notification to the capsule's recipients:
+//
+//	{"kind": 21041, "tags": [["p", "<recipient>"]..., ["e", "<capsule>"],
+//	  ["k", "1041"], ["tlock", "<drand_chain>", "<drand_round>"], ["alt", ...]]}
+//
+// Clients subscribe with {"kinds": [21041], "#p": ["<pubkey>"]}. The
+// upcoming unlocks addressed to a pubkey are listed at
+//
+//	GET /api/capsules/<pubkey>/unlocks[?limit=<n>]
+//
+// Only capsules on a known drand chain (quicknet, mainnet) are scheduled.
+
+const (
+	// kindCapsuleUnlocked is the ephemeral notification of a capsule unlock.
+	kindCapsuleUnlocked = 21041
+	// capsuleUnlockInterval is how often due unlocks are checked for.
+	capsuleUnlockInterval = time.Second
+	// capsuleLoadPageSize is how many capsules are read at a time on startup.
+	capsuleLoadPageSize = 500
+	// defaultCapsuleUnlocksLimit and maxCapsuleUnlocksLimit bound one listing.
+	defaultCapsuleUnlocksLimit = 50
+	maxCapsuleUnlocksLimit     = 500
+
+	capsuleUnlocksPrefix = "/api/capsules/"
+	capsuleUnlocksSuffix = "/unlocks"
+)
+
+// capsuleUnlocksInstance is the package-level unlock schedule (nil when disabled).
+var capsuleUnlocksInstance *capsules.Schedule
+
+// capsuleNotify reports whether unlock notifications are published.
+var capsuleNotify bool
+
+// GetCapsuleUnlocks returns the capsule unlock schedule, or nil when it is disabled.
+func GetCapsuleUnlocks() *capsules.Schedule {
+	return capsuleUnlocksInstance
+}
+
+// InitCapsuleUnlocks creates the capsule unlock schedule and keeps it in step
+// with newly stored events. The stored capsules are loaded by
+// startCapsuleUnlocks. Called from NewServer.
+func InitCapsuleUnlocks(cfg *config.Config, db *storage.DB) *capsules.Schedule {
+	if !cfg.Capsules.Enabled || db == nil {
+		capsuleUnlocksInstance = nil
+		return nil
+	}
+	schedule := capsules.New(cfg.Capsules.MaxScheduled)
+	db.SetCapsuleObserver(schedule.Observe)
+	capsuleUnlocksInstance = schedule
+	capsuleNotify = cfg.Capsules.NotifyUnlocks
+	return schedule
+}
+
+// startCapsuleUnlocks schedules the stored capsules that are still locked,
+// then notifies recipients as capsules unlock. Called from ListenAndServe.
+func startCapsuleUnlocks(ctx context.Context, node domain.NodeInterface) {
+	schedule := GetCapsuleUnlocks()
+	if schedule == nil {
+		return
+	}
+	go func() {
+		loadCapsuleUnlocks(ctx, node.DB(), schedule)
+
+		ticker := time.NewTicker(capsuleUnlockInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, u := range schedule.Due(now) {
+					notifyCapsuleUnlock(ctx, node, u)
+				}
+			}
+		}
+	}()
+}
+
+// loadCapsuleUnlocks schedules the stored capsules whose round is still to come.
+func loadCapsuleUnlocks(ctx context.Context, db *storage.DB, schedule *capsules.Schedule) {
+	log := logger.New("capsules")
+
+	// A since-only filter pages oldest first
+	since := nostr.Timestamp(0)
+	f := nostr.Filter{Kinds: []int{constants.KindTimeCapsule}, Since: &since, Limit: capsuleLoadPageSize}
+	var cursor storage.PageCursor
+	now := time.Now()
+	for {
+		events, err := db.GetEvents(storage.WithPageCursor(ctx, cursor), f)
+		if err != nil {
+			log.Warn("Failed to load time capsules", zap.Error(err))
+			return
+		}
+		for i := range events {
+			schedule.Add(&events[i], now)
+		}
+		if len(events) < capsuleLoadPageSize {
+			break
+		}
+		cursor = storage.CursorAfter(events[len(events)-1])
+	}
+	log.Info("Scheduled time capsule unlocks", zap.Int("pending", schedule.Len()))
+}
+
+// notifyCapsuleUnlock publishes the unlock notification of a capsule to its
+// recipients, unless the capsule has since been deleted or withheld.
+func notifyCapsuleUnlock(ctx context.Context, node domain.NodeInterface, u capsules.Unlock) {
+	if !capsuleNotify || len(u.Recipients) == 0 {
+		return
+	}
+	log := logger.New("capsules")
+
+	db := node.DB()
+	events, err := db.GetEvents(ctx, nostr.Filter{IDs: []string{u.EventID}, Limit: 1})
+	if err != nil {
+		log.Warn("Failed to look up unlocked capsule", zap.String("event_id", u.EventID), zap.Error(err))
+		metrics.CapsuleUnlocks.WithLabelValues("failed").Inc()
+		return
+	}
+	if len(events) == 0 || !visibleAnonymously(db, &events[0]) {
+		metrics.CapsuleUnlocks.WithLabelValues("withdrawn").Inc()
+		return
+	}
+
+	var relayKey signer.Signer
+	if gs := GetGroupStore(); gs != nil {
+		relayKey = gs.relaySigner()
+	}
+	if relayKey == nil {
+		metrics.CapsuleUnlocks.WithLabelValues("failed").Inc()
+		return
+	}
+
+	tags := make(nostr.Tags, 0, len(u.Recipients)+4)
+	for _, recipient := range u.Recipients {
+		tags = append(tags, nostr.Tag{"p", recipient})
+	}
+	tags = append(tags,
+		nostr.Tag{"e", u.EventID},
+		nostr.Tag{"k", strconv.Itoa(constants.KindTimeCapsule)},
+		nostr.Tag{constants.TagTlock, u.Chain, strconv.FormatInt(u.Round, 10)},
+		nostr.Tag{constants.TagAlt, "A time capsule addressed to you can now be opened"},
+	)
+	evt := &nostr.Event{
+		Kind:      kindCapsuleUnlocked,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Tags:      tags,
+	}
+	if err := relayKey.Sign(ctx, evt); err != nil {
+		log.Error("Failed to sign capsule unlock notification", zap.Error(err))
+		metrics.CapsuleUnlocks.WithLabelValues("failed").Inc()
+		return
+	}
+	if !queueRelayEvent(node, evt) {
+		metrics.CapsuleUnlocks.WithLabelValues("failed").Inc()
+		return
+	}
+	metrics.CapsuleUnlocks.WithLabelValues("notified").Inc()
+	log.Debug("Time capsule unlocked",
+		zap.String("event_id", u.EventID),
+		zap.Int("recipients", len(u.Recipients)))
+}
+
+// isCapsuleUnlocksPath reports whether path lists the unlocks of a pubkey.
+func isCapsuleUnlocksPath(path string) bool {
+	return strings.HasPrefix(path, capsuleUnlocksPrefix) && strings.HasSuffix(path, capsuleUnlocksSuffix)
+}
+
+// capsuleUnlocksAPIResponse is the body of /api/capsules/<pubkey>/unlocks.
+type capsuleUnlocksAPIResponse struct {
+	Pubkey  string            `json:"pubkey"`
+	Unlocks []capsules.Unlock `json:"unlocks"`
+	Count   int               `json:"count"`
+}
+
+// handleCapsuleUnlocksAPI serves GET /api/capsules/<pubkey>/unlocks.
+func (s *Server) handleCapsuleUnlocksAPI(w http.ResponseWriter, r *http.Request) {
+	schedule := GetCapsuleUnlocks()
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && schedule != nil) {
+		return
+	}
+	param := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, capsuleUnlocksPrefix), capsuleUnlocksSuffix)
+	pubkey, errMsg := parsePubkeyParam(param)
+	if errMsg != "" {
+		writeEventsAPIError(w, r, "INVALID_PUBKEY", errMsg)
+		return
+	}
+	limit := defaultCapsuleUnlocksLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxCapsuleUnlocksLimit {
+			writeEventsAPIError(w, r, "INVALID_LIMIT", "limit must be between 1 and "+strconv.Itoa(maxCapsuleUnlocksLimit))
+			return
+		}
+		limit = n
+	}
+
+	upcoming := schedule.Upcoming(pubkey, limit)
+	unlocks := make([]capsules.Unlock, 0, len(upcoming))
+	if len(upcoming) > 0 {
+		release, ok := s.acquireEventsAPISlot(w, r)
+		if !ok {
+			return
+		}
+		defer release()
+
+		// Leave out capsules withheld from anonymous readers
+		ids := make([]string, len(upcoming))
+		for i, u := range upcoming {
+			ids[i] = u.EventID
+		}
+		db := s.node.DB()
+		ctx, cancel := context.WithTimeout(eventsAPIContext(r), eventsAPIQueryTimeout)
+		defer cancel()
+		events, err := db.GetEvents(ctx, nostr.Filter{IDs: ids, Limit: len(ids)})
+		if err != nil {
+			logger.Warn("Capsule unlocks query failed", zap.Error(err))
+			errors.HandleHTTPError(w, r, errors.HandleDatabaseError("capsule unlocks query", err))
+			return
+		}
+		visible := make(map[string]bool, len(events))
+		for i := range events {
+			visible[events[i].ID] = visibleAnonymously(db, &events[i])
+		}
+		for _, u := range upcoming {
+			if visible[u.EventID] {
+				unlocks = append(unlocks, u)
+			}
+		}
+	}
+	writeEventsAPIJSON(w, capsuleUnlocksAPIResponse{Pubkey: pubkey, Unlocks: unlocks, Count: len(unlocks)})
+}
@@ -0,0 +1,275 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/classifieds"
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Classifieds API: NIP-99 listings (kind 30402) near a place, from the
+// classified listing index.
+//
+//	GET /api/classifieds?geohash=<prefix>&location=&t=&status=all&limit=50
+//	GET /api/classifieds?lat=<deg>&lon=<deg>&radius_km=<km>&location=&t=&status=all&limit=50
+//
+// A geohash search lists the listings with a g tag in the cell, newest
+// first; a radius search lists those whose most precise g tag lies within
+// radius_km, nearest first, with their distance. Sold listings are left out
+// unless status=all.
+//
+// The same searches are a REQ filter extension:
+//
+//	["REQ", "<sub>", {"kinds": [30402], "geo": {"geohash": "u33d"}}]
+//	["REQ", "<sub>", {"kinds": [30402], "geo": {"lat": 52.52, "lon": 13.40, "radius_km": 10}}]
+//
+// The relay turns "geo" into a #g filter listing the geohashes of the indexed
+// listings in range, so the results and live events are cell-accurate rather
+// than exact.
+
+const (
+	// classifiedsExpiryInterval is how often aged and sold listings are deleted.
+	classifiedsExpiryInterval = time.Hour
+	// classifiedsLoadPageSize is how many listings are read at a time on startup.
+	classifiedsLoadPageSize = 500
+	classifiedsDefaultLimit = 50
+	classifiedsMaxLimit     = 500
+	// classifiedsMaxRadiusKm bounds the radius of one search.
+	classifiedsMaxRadiusKm = 500
+	// maxGeoFilterValues bounds the #g values a geo filter expands to.
+	maxGeoFilterValues = 200
+)
+
+// classifiedsInstance is the package-level listing index (nil when disabled).
+var classifiedsInstance *classifieds.Index
+
+// GetClassifieds returns the classified listing index, or nil when it is disabled.
+func GetClassifieds() *classifieds.Index {
+	return classifiedsInstance
+}
+
+// InitClassifieds creates the classified listing index and keeps it in step
+// with newly stored events. The stored listings are loaded by
+// startClassifieds. Called from NewServer.
+func InitClassifieds(cfg *config.Config, db *storage.DB) *classifieds.Index {
+	if !cfg.Classifieds.Enabled || db == nil {
+		classifiedsInstance = nil
+		return nil
+	}
+	index := classifieds.New(cfg.Classifieds)
+	db.SetClassifiedsObserver(index.Observe)
+	classifiedsInstance = index
+	return index
+}
+
+// startClassifieds loads the stored listings into the index, then
+// periodically deletes the aged and sold ones. Called from ListenAndServe.
+func startClassifieds(ctx context.Context, db *storage.DB) {
+	index := GetClassifieds()
+	if index == nil {
+		return
+	}
+	go func() {
+		loadClassifieds(ctx, db, index)
+		expireClassifieds(ctx, db, index, time.Now())
+
+		ticker := time.NewTicker(classifiedsExpiryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				expireClassifieds(ctx, db, index, now)
+			}
+		}
+	}()
+}
+
+// loadClassifieds indexes the stored listings, oldest first, so later
+// versions replace earlier ones.
+func loadClassifieds(ctx context.Context, db *storage.DB, index *classifieds.Index) {
+	log := logger.New("classifieds")
+
+	// A since-only filter pages oldest first
+	since := nostr.Timestamp(0)
+	f := nostr.Filter{Kinds: []int{classifieds.KindListing}, Since: &since, Limit: classifiedsLoadPageSize}
+	var cursor storage.PageCursor
+	for {
+		events, err := db.GetEvents(storage.WithPageCursor(ctx, cursor), f)
+		if err != nil {
+			log.Warn("Failed to load classified listings", zap.Error(err))
+			return
+		}
+		for i := range events {
+			evt := events[i]
+			index.Add(&evt)
+		}
+		if len(events) < classifiedsLoadPageSize {
+			break
+		}
+		cursor = storage.CursorAfter(events[len(events)-1])
+	}
+	log.Info("Indexed NIP-99 classified listings", zap.Int("listings", index.Len()))
+}
+
+// expireClassifieds deletes the listings the index finds aged or sold.
+func expireClassifieds(ctx context.Context, db *storage.DB, index *classifieds.Index, now time.Time) {
+	aged, sold := index.Expire(now)
+	for reason, events := range map[string][]*nostr.Event{"aged": aged, "sold": sold} {
+		for _, evt := range events {
+			if err := db.RemoveEvent(ctx, evt); err != nil {
+				logger.New("classifieds").Warn("Failed to delete classified listing",
+					zap.String("event_id", evt.ID),
+					zap.String("reason", reason),
+					zap.Error(err))
+				continue
+			}
+			metrics.ClassifiedsExpired.WithLabelValues(reason).Inc()
+		}
+	}
+}
+
+// geoFilter is the "geo" REQ filter extension.
+type geoFilter struct {
+	Geohash  string   `json:"geohash"`
+	Lat      *float64 `json:"lat"`
+	Lon      *float64 `json:"lon"`
+	RadiusKm float64  `json:"radius_km"`
+}
+
+// applyGeoFilter turns the "geo" extension of a filter into a #g tag filter
+// over the listings in range, restricting the filter to listings when it
+// names no kinds.
+func applyGeoFilter(f *nostr.Filter, raw json.RawMessage) error {
+	index := GetClassifieds()
+	if index == nil {
+		return fmt.Errorf("geo filters are not supported by this relay")
+	}
+	var geo geoFilter
+	if err := json.Unmarshal(raw, &geo); err != nil {
+		return fmt.Errorf("invalid geo filter: %w", err)
+	}
+	if len(f.Tags["g"]) > 0 {
+		return fmt.Errorf("geo cannot be combined with #g")
+	}
+	q, err := geoQuery(strings.ToLower(geo.Geohash), geo.Lat, geo.Lon, geo.RadiusKm)
+	if err != nil {
+		return err
+	}
+	if q.Geohash == "" && q.Near == nil {
+		return fmt.Errorf("geo needs a geohash, or lat, lon and radius_km")
+	}
+	q.IncludeSold = true
+	q.Limit = maxGeoFilterValues
+	f.Tags["g"] = index.Geohashes(q)
+	if len(f.Kinds) == 0 {
+		f.Kinds = []int{classifieds.KindListing}
+	}
+	return nil
+}
+
+// geoQuery builds the location part of a listing search from a geohash
+// prefix or a point and radius; either may be left out.
+func geoQuery(geohash string, lat, lon *float64, radiusKm float64) (classifieds.Query, error) {
+	var q classifieds.Query
+	if geohash != "" {
+		if !classifieds.ValidGeohash(geohash) {
+			return q, fmt.Errorf("geohash must be 1 to 12 geohash characters")
+		}
+		q.Geohash = geohash
+	}
+	if lat == nil && lon == nil {
+		return q, nil
+	}
+	switch {
+	case q.Geohash != "":
+		return q, fmt.Errorf("search by geohash or by lat and lon, not both")
+	case lat == nil || lon == nil || math.IsNaN(*lat) || math.IsNaN(*lon) ||
+		*lat < -90 || *lat > 90 || *lon < -180 || *lon > 180:
+		return q, fmt.Errorf("lat and lon must be given together, within -90..90 and -180..180")
+	case !(radiusKm > 0 && radiusKm <= classifiedsMaxRadiusKm):
+		return q, fmt.Errorf("radius_km must be between 0 and %d", classifiedsMaxRadiusKm)
+	}
+	q.Near = &classifieds.Near{Lat: *lat, Lon: *lon, RadiusKm: radiusKm}
+	return q, nil
+}
+
+// classifiedsAPIResponse is the body of /api/classifieds.
+type classifiedsAPIResponse struct {
+	Listings []*classifieds.Listing `json:"listings"`
+	Count    int                    `json:"count"`
+}
+
+// handleClassifiedsAPI serves GET /api/classifieds.
+func (s *Server) handleClassifiedsAPI(w http.ResponseWriter, r *http.Request) {
+	index := GetClassifieds()
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && index != nil) {
+		return
+	}
+	query := r.URL.Query()
+
+	lat, latOK := parseFloatParam(query.Get("lat"))
+	lon, lonOK := parseFloatParam(query.Get("lon"))
+	radius, radiusOK := parseFloatParam(query.Get("radius_km"))
+	if !latOK || !lonOK || !radiusOK {
+		writeEventsAPIError(w, r, "INVALID_LOCATION", "lat, lon and radius_km must be numbers")
+		return
+	}
+	var radiusKm float64
+	if radius != nil {
+		radiusKm = *radius
+	}
+	q, err := geoQuery(strings.ToLower(query.Get("geohash")), lat, lon, radiusKm)
+	if err != nil {
+		writeEventsAPIError(w, r, "INVALID_LOCATION", err.Error())
+		return
+	}
+	q.Location = query.Get("location")
+	q.Category = query.Get("t")
+	q.IncludeSold = query.Get("status") == "all"
+	q.Limit = classifiedsDefaultLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > classifiedsMaxLimit {
+			writeEventsAPIError(w, r, "INVALID_LIMIT", "limit must be between 1 and "+strconv.Itoa(classifiedsMaxLimit))
+			return
+		}
+		q.Limit = n
+	}
+
+	db := s.node.DB()
+	response := classifiedsAPIResponse{Listings: []*classifieds.Listing{}}
+	for _, l := range index.Search(q) {
+		if visibleAnonymously(db, l.Event) {
+			response.Listings = append(response.Listings, l)
+		}
+	}
+	response.Count = len(response.Listings)
+	writeEventsAPIJSON(w, response)
+}
+
+// parseFloatParam reads an optional number, nil when raw is empty. It
+// reports false when raw is not a number.
+func parseFloatParam(raw string) (*float64, bool) {
+	if raw == "" {
+		return nil, true
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &v, true
+}
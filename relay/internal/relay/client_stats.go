@@ -0,0 +1,59 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Shugur-Network/relay/internal/clientstats"
+	"github.com/Shugur-Network/relay/internal/config"
+)
+
+// clientStatsInstance is the package-level client telemetry tracker (nil when disabled).
+var clientStatsInstance *clientstats.Tracker
+
+// GetClientStats returns the client telemetry tracker, or nil when it is disabled.
+func GetClientStats() *clientstats.Tracker {
+	return clientStatsInstance
+}
+
+// InitClientStats creates the client telemetry tracker and restores its last
+// snapshot. Called from NewServer.
+func InitClientStats(cfg *config.Config) *clientstats.Tracker {
+	if !cfg.ClientStats.Enabled {
+		clientStatsInstance = nil
+		return nil
+	}
+	clientStatsInstance = clientstats.New(cfg.ClientStats)
+	return clientStatsInstance
+}
+
+// startClientStats flushes the client counts to their snapshot file until ctx is canceled.
+func startClientStats(ctx context.Context) {
+	if t := GetClientStats(); t != nil {
+		t.Start(ctx)
+	}
+}
+
+// countClientConnection counts a new connection under the client software
+// named by its headers and returns that name, "" when telemetry is disabled.
+func countClientConnection(r *http.Request) string {
+	t := GetClientStats()
+	if t == nil {
+		return ""
+	}
+	return t.Connect(clientstats.ClientName(r.Header.Get("User-Agent"), r.Header.Get("Origin")))
+}
+
+// countClientCommand counts a command issued on c.
+func (c *WsConnection) countClientCommand(command string) {
+	if t := GetClientStats(); t != nil && c.clientName != "" {
+		t.Command(c.clientName, command)
+	}
+}
+
+// countClientPublished counts an event of kind published on c.
+func (c *WsConnection) countClientPublished(kind int) {
+	if t := GetClientStats(); t != nil && c.clientName != "" {
+		t.Published(c.clientName, kind)
+	}
+}
@@ -0,0 +1,153 @@
+package relay
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// maxCoalescedAddresses bounds the delivered versions remembered per
+// connection. Forgetting them only risks sending a stale version.
+const maxCoalescedAddresses = 4096
+
+// coalesceKey is one replaceable address as seen by one subscription.
+type coalesceKey struct {
+	subID   string
+	address string // kind:pubkey:d
+}
+
+// version orders the versions of one address: the newest wins, ties going to
+// the lowest id as in storage.
+type version struct {
+	createdAt nostr.Timestamp
+	id        string
+}
+
+func (v version) newerThan(o version) bool {
+	return v.createdAt > o.createdAt || (v.createdAt == o.createdAt && v.id < o.id)
+}
+
+// pendingVersion is a version held back until its window ends.
+type pendingVersion struct {
+	event *nostr.Event
+}
+
+// coalescer holds live replaceable and addressable events back for a short
+// window so a subscription receives only the latest of versions published in
+// quick succession, and never a version older than one it already received.
+type coalescer struct {
+	window time.Duration
+	send   func(subID string, evt *nostr.Event)
+
+	mu        sync.Mutex
+	pending   map[coalesceKey]*pendingVersion
+	delivered map[coalesceKey]version
+}
+
+// newCoalescer returns a coalescer sending through send, or nil when
+// coalescing is disabled.
+func newCoalescer(cfg config.CoalesceConfig, send func(subID string, evt *nostr.Event)) *coalescer {
+	if !cfg.Enabled {
+		return nil
+	}
+	window := cfg.Window
+	if window <= 0 {
+		window = 500 * time.Millisecond
+	}
+	return &coalescer{
+		window:    window,
+		send:      send,
+		pending:   make(map[coalesceKey]*pendingVersion),
+		delivered: make(map[coalesceKey]version),
+	}
+}
+
+// replaceableAddress returns the address whose versions evt replaces, or ""
+// for events that are not replaceable.
+func replaceableAddress(evt *nostr.Event) string {
+	switch {
+	case nips.IsReplaceable(evt.Kind):
+		return strconv.Itoa(evt.Kind) + ":" + evt.PubKey + ":"
+	case nips.IsParameterizedReplaceableKind(evt.Kind):
+		return strconv.Itoa(evt.Kind) + ":" + evt.PubKey + ":" + nips.GetDTagValue(evt)
+	}
+	return ""
+}
+
+// offer takes evt for delivery to subID and reports whether it did; events
+// that are not replaceable are left to the caller.
+func (co *coalescer) offer(subID string, evt *nostr.Event) bool {
+	address := replaceableAddress(evt)
+	if address == "" {
+		return false
+	}
+	key := coalesceKey{subID: subID, address: address}
+	v := version{createdAt: evt.CreatedAt, id: evt.ID}
+
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	if last, ok := co.delivered[key]; ok && !v.newerThan(last) {
+		metrics.SupersededVersionsSuppressed.WithLabelValues("stale").Inc()
+		return true
+	}
+	if p := co.pending[key]; p != nil {
+		if v.newerThan(version{createdAt: p.event.CreatedAt, id: p.event.ID}) {
+			p.event = evt
+		}
+		metrics.SupersededVersionsSuppressed.WithLabelValues("coalesced").Inc()
+		return true
+	}
+	p := &pendingVersion{event: evt}
+	co.pending[key] = p
+	time.AfterFunc(co.window, func() { co.flush(key, p) })
+	return true
+}
+
+// flush sends the version held for key once its window ends, unless the
+// subscription was closed or replaced meanwhile.
+func (co *coalescer) flush(key coalesceKey, p *pendingVersion) {
+	co.mu.Lock()
+	if co.pending[key] != p {
+		co.mu.Unlock()
+		return
+	}
+	delete(co.pending, key)
+	if len(co.delivered) >= maxCoalescedAddresses {
+		co.delivered = make(map[coalesceKey]version)
+	}
+	evt := p.event
+	co.delivered[key] = version{createdAt: evt.CreatedAt, id: evt.ID}
+	co.mu.Unlock()
+
+	co.send(key.subID, evt)
+}
+
+// forget drops what is held and remembered for subID.
+func (co *coalescer) forget(subID string) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	for key := range co.pending {
+		if key.subID == subID {
+			delete(co.pending, key)
+		}
+	}
+	for key := range co.delivered {
+		if key.subID == subID {
+			delete(co.delivered, key)
+		}
+	}
+}
+
+// reset drops everything held, when the connection closes.
+func (co *coalescer) reset() {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	co.pending = make(map[coalesceKey]*pendingVersion)
+	co.delivered = make(map[coalesceKey]version)
+}
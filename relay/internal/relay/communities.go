@@ -0,0 +1,342 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/communities"
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/domain"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Communities API: the posts of a NIP-72 community, from the community
+// index.
+//
+//	GET /api/communities/queue?community=34550:<owner>:<d>[&limit=50&cursor=]
+//	GET /api/communities/approved?community=34550:<owner>:<d>[&limit=50&cursor=]
+//
+// The queue lists the top-level posts (kind 1111) still awaiting approval,
+// oldest first. It is authenticated with a NIP-98 Authorization header
+// signed by the community's owner, one of its moderators or a relay admin.
+// The approved posts are public and listed newest first. Both come with the
+// community's pending and approved counts; when a page is full, "next" holds
+// the URL of the following page.
+//
+// Posts from authors on a community's AUTO_APPROVE allowlist skip the queue.
+// When the relay's own key moderates that community, the relay publishes an
+// approval (kind 4550) of each such post so clients show it too.
+
+const (
+	communityQueuePath = "/api/communities/queue"
+	// communitiesPruneInterval is how often aged posts and approvals are dropped.
+	communitiesPruneInterval = time.Hour
+	// communitiesLoadPageSize is how many events are read at a time on startup.
+	communitiesLoadPageSize = 500
+	communitiesDefaultLimit = 50
+	communitiesMaxLimit     = 500
+	// communityApprovalBacklog bounds the allowlisted posts awaiting a
+	// relay-signed approval.
+	communityApprovalBacklog = 256
+)
+
+// communitiesInstance is the package-level community index (nil when disabled).
+var communitiesInstance *communities.Index
+
+// communityApprovals carries the allowlisted posts the relay approves.
+var communityApprovals chan *communities.Post
+
+// GetCommunities returns the community index, or nil when it is disabled.
+func GetCommunities() *communities.Index {
+	return communitiesInstance
+}
+
+// InitCommunities creates the community index and keeps it in step with
+// newly stored events, queueing allowlisted posts for a relay-signed
+// approval. The stored events are loaded by startCommunities. Called from
+// NewServer.
+func InitCommunities(cfg *config.Config, db *storage.DB) *communities.Index {
+	if !cfg.Communities.Enabled || db == nil {
+		communitiesInstance = nil
+		return nil
+	}
+	index := communities.New(cfg.Communities)
+	approvals := make(chan *communities.Post, communityApprovalBacklog)
+	db.SetCommunitiesObserver(func(evt *nostr.Event) {
+		index.Observe(evt)
+		if post := relayApprovable(index, evt); post != nil {
+			select {
+			case approvals <- post:
+			default:
+				metrics.CommunityAutoApprovals.WithLabelValues("dropped").Inc()
+			}
+		}
+	})
+	communitiesInstance = index
+	communityApprovals = approvals
+	return index
+}
+
+// relayApprovable returns the post evt when it is an allowlisted post to a
+// community the relay's key moderates and has not approved yet.
+func relayApprovable(index *communities.Index, evt *nostr.Event) *communities.Post {
+	if evt.Kind != communities.KindPost {
+		return nil
+	}
+	post := index.Post(evt.ID)
+	if post == nil || !post.AutoApproved {
+		return nil
+	}
+	gs := GetGroupStore()
+	if gs == nil || gs.relaySigner() == nil {
+		return nil
+	}
+	relayPubkey := gs.GetRelayPubkey()
+	if !index.IsModerator(post.Community, relayPubkey) {
+		return nil
+	}
+	for _, moderator := range post.ApprovedBy {
+		if moderator == relayPubkey {
+			return nil
+		}
+	}
+	return post
+}
+
+// startCommunities loads the stored communities, posts and approvals, then
+// publishes the relay's approvals of allowlisted posts and periodically
+// drops aged posts. Called from ListenAndServe.
+func startCommunities(ctx context.Context, node domain.NodeInterface) {
+	index := GetCommunities()
+	if index == nil {
+		return
+	}
+	approvals := communityApprovals
+	go func() {
+		loadCommunities(ctx, node.DB(), index)
+
+		ticker := time.NewTicker(communitiesPruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case post := <-approvals:
+				approveCommunityPost(ctx, node, post)
+			case now := <-ticker.C:
+				index.PruneAt(now)
+			}
+		}
+	}()
+}
+
+// loadCommunities indexes the stored community definitions, then the posts
+// and approvals younger than the maximum age, oldest first.
+func loadCommunities(ctx context.Context, db *storage.DB, index *communities.Index) {
+	log := logger.New("communities")
+
+	// A since-only filter pages oldest first
+	since := nostr.Timestamp(0)
+	recent := nostr.Timestamp(time.Now().Add(-index.MaxAge()).Unix())
+	for _, f := range []nostr.Filter{
+		{Kinds: []int{communities.KindCommunity}, Since: &since, Limit: communitiesLoadPageSize},
+		{
+			Kinds: []int{communities.KindPost},
+			Tags:  nostr.TagMap{"k": []string{strconv.Itoa(communities.KindCommunity)}},
+			Since: &recent,
+			Limit: communitiesLoadPageSize,
+		},
+		{Kinds: []int{communities.KindApproval}, Since: &recent, Limit: communitiesLoadPageSize},
+	} {
+		var cursor storage.PageCursor
+		for {
+			events, err := db.GetEvents(storage.WithPageCursor(ctx, cursor), f)
+			if err != nil {
+				log.Warn("Failed to load communities", zap.Error(err))
+				return
+			}
+			for i := range events {
+				evt := events[i]
+				index.Add(&evt)
+			}
+			if len(events) < communitiesLoadPageSize {
+				break
+			}
+			cursor = storage.CursorAfter(events[len(events)-1])
+		}
+	}
+	count, entries := index.Len()
+	log.Info("Indexed NIP-72 communities",
+		zap.Int("communities", count),
+		zap.Int("posts_and_approvals", entries))
+}
+
+// approveCommunityPost publishes the relay's approval of an allowlisted post.
+func approveCommunityPost(ctx context.Context, node domain.NodeInterface, post *communities.Post) {
+	log := logger.New("communities")
+
+	gs := GetGroupStore()
+	if gs == nil || gs.relaySigner() == nil {
+		metrics.CommunityAutoApprovals.WithLabelValues("failed").Inc()
+		return
+	}
+	content, err := json.Marshal(post.Event)
+	if err != nil {
+		metrics.CommunityAutoApprovals.WithLabelValues("failed").Inc()
+		return
+	}
+	evt := &nostr.Event{
+		Kind:      communities.KindApproval,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Tags: nostr.Tags{
+			{"a", post.Community},
+			{"e", post.ID},
+			{"p", post.Author},
+			{"k", strconv.Itoa(post.Event.Kind)},
+			{constants.TagAlt, "Post approved for the community"},
+		},
+		Content: string(content),
+	}
+	if err := gs.relaySigner().Sign(ctx, evt); err != nil {
+		log.Error("Failed to sign community approval", zap.Error(err))
+		metrics.CommunityAutoApprovals.WithLabelValues("failed").Inc()
+		return
+	}
+	if !queueRelayEvent(node, evt) {
+		metrics.CommunityAutoApprovals.WithLabelValues("failed").Inc()
+		return
+	}
+	metrics.CommunityAutoApprovals.WithLabelValues("published").Inc()
+	log.Debug("Allowlisted community post approved",
+		zap.String("community", post.Community),
+		zap.String("event_id", post.ID))
+}
+
+// communityPostsResponse is the body of /api/communities/queue and
+// /api/communities/approved.
+type communityPostsResponse struct {
+	Community string              `json:"community"`
+	Pending   int                 `json:"pending"`
+	Approved  int                 `json:"approved"`
+	Posts     []*communities.Post `json:"posts"`
+	Count     int                 `json:"count"`
+	Next      string              `json:"next,omitempty"`
+}
+
+// handleCommunityQueueAPI serves GET /api/communities/queue.
+func (s *Server) handleCommunityQueueAPI(w http.ResponseWriter, r *http.Request) {
+	index := GetCommunities()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodGet {
+		errors.HandleHTTPError(w, r, errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed."))
+		return
+	}
+	if index == nil {
+		errors.HandleHTTPError(w, r, errors.NotFoundError(r.URL.Path).
+			WithUserMessage("This endpoint is disabled on this relay."))
+		return
+	}
+
+	q, ok := parseCommunityPostQuery(w, r, index)
+	if !ok {
+		return
+	}
+	pubkey, err := nips.ValidateHTTPAuth(r.Header.Get("Authorization"), s.publicBaseURL(r)+r.URL.RequestURI(), http.MethodGet, nil)
+	if err != nil {
+		errors.HandleHTTPError(w, r, errors.AuthenticationError(err.Error()).
+			WithUserMessage("Sign the request with NIP-98 as a community moderator."))
+		return
+	}
+	if !index.IsModerator(q.Community, pubkey) && !s.isAdmin(pubkey) {
+		errors.HandleHTTPError(w, r, errors.AuthorizationError("community queue",
+			"only community moderators may read the moderation queue").
+			WithUserMessage("Only community moderators may read the moderation queue."))
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	s.writeCommunityPosts(w, r, index, q)
+}
+
+// handleCommunityApprovedAPI serves GET /api/communities/approved.
+func (s *Server) handleCommunityApprovedAPI(w http.ResponseWriter, r *http.Request) {
+	index := GetCommunities()
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && index != nil) {
+		return
+	}
+	q, ok := parseCommunityPostQuery(w, r, index)
+	if !ok {
+		return
+	}
+	q.Approved = true
+	s.writeCommunityPosts(w, r, index, q)
+}
+
+// parseCommunityPostQuery reads the community, limit and cursor of a
+// listing of community posts, answering the request when they are invalid.
+func parseCommunityPostQuery(w http.ResponseWriter, r *http.Request, index *communities.Index) (communities.PostQuery, bool) {
+	query := r.URL.Query()
+	q := communities.PostQuery{Community: query.Get("community"), Limit: communitiesDefaultLimit}
+	if index.Community(q.Community) == nil {
+		errors.HandleHTTPError(w, r, errors.NotFoundError(r.URL.Path).
+			WithUserMessage("Community not found."))
+		return q, false
+	}
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > communitiesMaxLimit {
+			writeEventsAPIError(w, r, "INVALID_LIMIT", "limit must be between 1 and "+strconv.Itoa(communitiesMaxLimit))
+			return q, false
+		}
+		q.Limit = n
+	}
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := storage.ParsePageCursor(raw)
+		if err != nil {
+			writeEventsAPIError(w, r, "INVALID_CURSOR", err.Error())
+			return q, false
+		}
+		q.AfterCreatedAt, q.AfterID = cursor.CreatedAt, cursor.ID
+	}
+	return q, true
+}
+
+// writeCommunityPosts answers with a page of the community's posts.
+func (s *Server) writeCommunityPosts(w http.ResponseWriter, r *http.Request, index *communities.Index, q communities.PostQuery) {
+	posts := index.Posts(q)
+	response := communityPostsResponse{Community: q.Community, Posts: make([]*communities.Post, 0, len(posts))}
+	response.Pending, response.Approved = index.Counts(q.Community)
+	db := s.node.DB()
+	for _, p := range posts {
+		if visibleAnonymously(db, p.Event) {
+			response.Posts = append(response.Posts, p)
+		}
+	}
+	response.Count = len(response.Posts)
+	if len(posts) == q.Limit {
+		// The next page starts after the last post of this one
+		query := r.URL.Query()
+		query.Set("cursor", storage.CursorAfter(*posts[len(posts)-1].Event).String())
+		response.Next = r.URL.Path + "?" + query.Encode()
+	}
+	writeEventsAPIJSON(w, response)
+}
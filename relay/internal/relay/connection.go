@@ -6,19 +6,24 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"net"
 	"net/http"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"github.com/Shugur-Network/relay/internal/audit"
+	"github.com/Shugur-Network/relay/internal/clientip"
 	"github.com/Shugur-Network/relay/internal/config"
 	"github.com/Shugur-Network/relay/internal/domain"
 	"github.com/Shugur-Network/relay/internal/errors"
 	"github.com/Shugur-Network/relay/internal/logger"
 	"github.com/Shugur-Network/relay/internal/metrics"
 	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/spamcluster"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"github.com/Shugur-Network/relay/internal/tracing"
 	"github.com/gorilla/websocket"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
@@ -32,64 +37,10 @@ var (
 	clientExceededCount = make(map[string]int)
 )
 
-// extractRealClientIP extracts the real client IP from request headers when behind a proxy
+// extractRealClientIP returns the client IP of a request, honouring proxy
+// headers only from RELAY.TRUSTED_PROXIES
 func extractRealClientIP(r *http.Request) string {
-	var extractedIP string
-	var source string
-
-	// Try X-Real-IP first (set by Caddy)
-	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-		extractedIP = strings.TrimSpace(realIP)
-		return extractedIP
-	}
-
-	// Try X-Forwarded-For (contains comma-separated list of IPs)
-	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
-		// Take the first IP in the chain (the original client)
-		parts := strings.Split(forwardedFor, ",")
-		if len(parts) > 0 {
-			extractedIP = strings.TrimSpace(parts[0])
-			source = "X-Forwarded-For"
-			logger.Debug("Client IP extracted from X-Forwarded-For header",
-				zap.String("forwarded_ip", extractedIP),
-				zap.String("source", source),
-				zap.String("full_header", forwardedFor),
-				zap.String("raw_remote_addr", r.RemoteAddr))
-			return extractedIP
-		}
-	}
-
-	// Fallback to RemoteAddr (direct connection)
-	extractedIP = normalizeIP(r.RemoteAddr)
-	source = "RemoteAddr"
-	logger.Debug("No proxy headers found, using RemoteAddr",
-		zap.String("client_ip", extractedIP),
-		zap.String("source", source),
-		zap.String("x_real_ip", r.Header.Get("X-Real-IP")),
-		zap.String("x_forwarded_for", r.Header.Get("X-Forwarded-For")))
-
-	return extractedIP
-}
-
-// normalizeIP converts a network address to a normalized IP string
-func normalizeIP(addr string) string {
-	// Extract the IP portion (remove port)
-	host, _, err := net.SplitHostPort(addr)
-	if err != nil {
-		// If splitting fails, assume addr is already an IP
-		host = addr
-	}
-
-	// Normalize IPv4-mapped IPv6 addresses
-	ip := net.ParseIP(host)
-	if ip != nil {
-		if ipv4 := ip.To4(); ipv4 != nil {
-			return ipv4.String()
-		}
-		return ip.String()
-	}
-
-	return host
+	return clientip.FromRequest(r)
 }
 
 // generateClientID generates a unique client ID for event dispatcher
@@ -149,18 +100,23 @@ func handleWebSocketConnection(ctx context.Context, w http.ResponseWriter, r *ht
 		zap.String("origin", r.Header.Get("Origin")))
 
 	// Check if client is banned
-	banListMutex.Lock()
-	banExpiry, banned := clientBanList[clientIP]
-	banListMutex.Unlock()
-
-	if banned && time.Now().Before(banExpiry) {
+	if remaining := clientBanRemaining(clientIP); remaining > 0 {
 		// Use new error handling system
-		banErr := errors.ClientBannedError("excessive messages", time.Until(banExpiry).String()).
+		banErr := errors.ClientBannedError("excessive messages", remaining.String()).
 			WithSeverity(errors.SeverityMedium)
 		errors.HandleHTTPError(w, r, banErr)
 		return
 	}
 
+	// Check address range, country and ASN blocks
+	if reason := ipBlocks.blockedReason(clientIP); reason != "" {
+		logger.Debug("Blocked client attempted to connect",
+			zap.String("client_ip", clientIP),
+			zap.String("reason", reason))
+		errors.HandleHTTPError(w, r, errors.AuthorizationError("connection", "blocked "+reason))
+		return
+	}
+
 	// Reset exceeded count on new allowed connection
 	banListMutex.Lock()
 	delete(clientExceededCount, clientIP)
@@ -176,16 +132,24 @@ func handleWebSocketConnection(ctx context.Context, w http.ResponseWriter, r *ht
 		errors.HandleHTTPError(w, r, limitErr)
 		return
 	}
+
+	// Per-IP connection limit, shared with SSE streams
+	if !acquireIPConnection(clientIP, relayConfig.ThrottlingConfig.MaxConnectionsPerIP) {
+		errors.HandleHTTPError(w, r, errors.RateLimitError("connections from this IP"))
+		return
+	}
+
 	// Ensure we decrement on error
 	connectionSuccess := false
 	defer func() {
 		if !connectionSuccess {
 			metrics.DecrementActiveConnections()
+			releaseIPConnection(clientIP)
 		}
 	}()
 
 	// Upgrade the connection
-	wsConn, err := upgrader.Upgrade(w, r, nil)
+	wsConn, compressed, err := upgradeWebSocket(&upgrader, w, r)
 	if err != nil {
 		// Use new error handling system
 		upgradeErr := errors.WebSocketError("connection upgrade", err).
@@ -194,16 +158,23 @@ func handleWebSocketConnection(ctx context.Context, w http.ResponseWriter, r *ht
 		return
 	}
 
-	// Enable compression
-	wsConn.EnableWriteCompression(true)
-	_ = wsConn.SetCompressionLevel(2) // nolint:errcheck // compression level is non-critical
+	// Record whether permessage-deflate was negotiated for this connection
+	if compressed {
+		metrics.ConnectionCompression.WithLabelValues("negotiated").Inc()
+	} else if upgrader.EnableCompression {
+		metrics.ConnectionCompression.WithLabelValues("not_offered").Inc()
+	} else {
+		metrics.ConnectionCompression.WithLabelValues("disabled").Inc()
+	}
 
 	// Update metrics
 	metrics.IncrementActiveConnections()
 	connectionSuccess = true
 
 	// Create new connection and register it
-	conn := NewWsConnection(ctx, wsConn, node, relayConfig, clientIP)
+	conn := NewWsConnection(ctx, wsConn, node, relayConfig, clientIP, compressed, GetTenants().ForHost(r.Host))
+	conn.clientName = countClientConnection(r)
+	conn.fingerprint = connectionFingerprint(r)
 	node.RegisterConn(conn)
 
 	logger.Debug("WebSocket connection established successfully",
@@ -219,6 +190,8 @@ type WsConnection struct {
 	ws           *websocket.Conn
 	node         domain.NodeInterface
 	realClientIP string // Real client IP (extracted from proxy headers)
+	compressed   bool   // permessage-deflate negotiated during the handshake
+	acceptBinary bool   // accept binary frames carrying UTF-8 JSON
 	lastActivity time.Time
 	idleTimeout  time.Duration
 	maxLifetime  time.Duration // Maximum lifetime of a connection
@@ -228,6 +201,7 @@ type WsConnection struct {
 
 	subMu         sync.RWMutex
 	subscriptions map[string][]nostr.Filter
+	replays       map[string]*replaySet // subscriptions still replaying stored events
 
 	writeMu            sync.Mutex
 	closeMu            sync.Once
@@ -239,6 +213,10 @@ type WsConnection struct {
 	exceededLimitCount int
 	backpressureChan   chan struct{} // Channel for backpressure handling
 
+	// Query limits (THROTTLING)
+	queryLimits   queryLimits
+	activeQueries atomic.Int32 // REQ and COUNT queries in flight
+
 	// Event dispatcher integration
 	clientID    string
 	eventChan   chan *nostr.Event
@@ -253,6 +231,18 @@ type WsConnection struct {
 
 	// NIP-77 Negentropy Syncing
 	negSessions *negSessions
+
+	// Virtual relay this connection was opened on (nil = main relay)
+	tenant *Tenant
+
+	// Client software its activity is counted under ("" = telemetry disabled)
+	clientName string
+
+	// Fingerprint of its headers for anomaly detection ("" = detection disabled)
+	fingerprint string
+
+	// Holds back superseded replaceable versions (nil = coalescing disabled)
+	coalesce *coalescer
 }
 
 // Ensure WsConnection implements domain.WebSocketConnection
@@ -265,6 +255,8 @@ func NewWsConnection(
 	node domain.NodeInterface,
 	cfg config.RelayConfig,
 	realClientIP string,
+	compressed bool,
+	tenant *Tenant,
 ) *WsConnection {
 	// Basic rate limiter
 	limiter := rate.NewLimiter(
@@ -279,14 +271,18 @@ func NewWsConnection(
 		ws:               ws,
 		node:             node,
 		realClientIP:     realClientIP,
+		compressed:       compressed,
+		acceptBinary:     cfg.AcceptBinary,
 		idleTimeout:      cfg.IdleTimeout,
 		maxLifetime:      24 * time.Hour, // Maximum connection lifetime
 		startTime:        time.Now(),
 		lastActivity:     time.Now(),
 		subscriptions:    make(map[string][]nostr.Filter),
+		replays:          make(map[string]*replaySet),
 		pingTicker:       time.NewTicker(15 * time.Second),
 		limiter:          limiter,
 		backpressureChan: make(chan struct{}, 100), // Buffer for backpressure
+		queryLimits:      newQueryLimits(cfg.ThrottlingConfig),
 		// Event dispatcher integration
 		clientID:    generateClientID(),
 		eventCtx:    eventCtx,
@@ -295,7 +291,12 @@ func NewWsConnection(
 		authedPubkeys: make(map[string]bool),
 		negSessions:   newNegSessions(),
 		relayURL:      cfg.PublicURL,
+		tenant:        tenant,
+	}
+	if tenant != nil {
+		conn.relayURL = tenant.relayURL()
 	}
+	conn.coalesce = newCoalescer(cfg.Coalesce, conn.sendCoalesced)
 
 	// Generate NIP-42 auth challenge
 	challenge, err := nips.GenerateAuthChallenge()
@@ -312,9 +313,15 @@ func NewWsConnection(
 		go conn.processDispatcherEvents()
 	}
 
-	// WebSocket compression
-	ws.EnableWriteCompression(true)
-	_ = ws.SetCompressionLevel(2) // nolint:errcheck // compression level is non-critical
+	// WebSocket compression (only effective when permessage-deflate was negotiated)
+	ws.EnableWriteCompression(compressed)
+	if compressed {
+		if err := ws.SetCompressionLevel(cfg.Compression.Level); err != nil {
+			logger.Warn("Invalid compression level, using library default",
+				zap.Int("level", cfg.Compression.Level),
+				zap.Error(err))
+		}
+	}
 
 	// Deadlines + read limit
 	_ = ws.SetReadDeadline(time.Now().Add(60 * time.Second)) // nolint:errcheck // deadline is non-critical
@@ -438,6 +445,10 @@ func (c *WsConnection) sendClosed(subID, reason string) {
 
 // sendOK sends an OK response for an event with status and message
 func (c *WsConnection) sendOK(eventID string, accepted bool, message string) {
+	if !accepted {
+		metrics.RecordLiveRejected(message)
+	}
+	c.countAnomalyPublished(accepted)
 	msg := []interface{}{"OK", eventID, accepted, message}
 	data, _ := json.Marshal(msg)
 	c.SendMessage(data)
@@ -532,7 +543,7 @@ func (c *WsConnection) HandleMessages(ctx context.Context, cfg config.RelayConfi
 		}
 
 		// Read message
-		_, rawMsg, err := c.ws.ReadMessage()
+		frameType, rawMsg, err := c.ws.ReadMessage()
 		if err != nil {
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 				c.closeReason = "client closed connection"
@@ -555,6 +566,19 @@ func (c *WsConnection) HandleMessages(ctx context.Context, cfg config.RelayConfi
 		_ = c.ws.SetReadDeadline(time.Time{}) // nolint:errcheck // deadline reset is non-critical
 		c.lastActivity = time.Now()
 
+		// Binary frames are accepted only when enabled and carrying UTF-8 JSON
+		if frameType == websocket.BinaryMessage {
+			metrics.BinaryFramesReceived.Inc()
+			if !c.acceptBinary {
+				c.sendNotice("invalid: binary frames are not accepted, send text frames")
+				continue
+			}
+			if !utf8.Valid(rawMsg) {
+				c.sendNotice("invalid: binary frame is not valid UTF-8 JSON")
+				continue
+			}
+		}
+
 		var arr []interface{}
 		if err := json.Unmarshal(rawMsg, &arr); err != nil {
 			c.sendNotice("invalid: malformed JSON from client")
@@ -572,7 +596,8 @@ func (c *WsConnection) HandleMessages(ctx context.Context, cfg config.RelayConfi
 		}
 
 		if cmdType == "EVENT" {
-			if !c.limiter.Allow() {
+			// Connections authenticated as a trusted pubkey skip the connection rate limit
+			if !c.hasTrustedAuth() && !c.limiter.Allow() {
 				// Track repeated violations
 				banListMutex.Lock()
 				clientExceededCount[clientIP]++
@@ -601,6 +626,16 @@ func (c *WsConnection) HandleMessages(ctx context.Context, cfg config.RelayConfi
 					clientBanList[clientIP] = time.Now().Add(banDuration)
 					delete(clientExceededCount, clientIP)
 					banListMutex.Unlock()
+					recordAudit(audit.Entry{
+						Actor:    audit.ActorSystem,
+						Source:   "ratelimit",
+						Action:   "banip",
+						Params:   []string{clientIP, banDuration.String()},
+						Outcome:  audit.OutcomeSuccess,
+						Message:  fmt.Sprintf("%d rate limit violations", count),
+						ClientIP: clientIP,
+					})
+					c.escalateBan(clientIP)
 
 					c.sendNotice("You have been temporarily banned.")
 					c.Close()
@@ -614,16 +649,23 @@ func (c *WsConnection) HandleMessages(ctx context.Context, cfg config.RelayConfi
 
 		// Update command metrics
 		metrics.CommandsReceived.WithLabelValues(cmdType).Inc()
+		c.countClientCommand(cmdType)
 
 		// Process the command
 		start := time.Now()
+		cmdCtx, span := ctx, (*tracing.Span)(nil)
+		if tracedCommands[cmdType] {
+			cmdCtx, span = tracing.Start(ctx, "nostr."+cmdType, tracing.SpanKindServer,
+				tracing.String("nostr.command", cmdType),
+				tracing.String("client.address", clientIP))
+		}
 		switch cmdType {
 		case "EVENT":
-			c.handleEvent(ctx, arr)
+			c.handleEvent(cmdCtx, arr)
 		case "REQ":
-			c.handleRequest(ctx, arr)
+			c.handleRequest(cmdCtx, arr)
 		case "COUNT":
-			c.handleCountRequest(ctx, arr)
+			c.handleCountRequest(cmdCtx, arr)
 		case "CLOSE":
 			c.handleClose(arr)
 		case "AUTH":
@@ -637,10 +679,14 @@ func (c *WsConnection) HandleMessages(ctx context.Context, cfg config.RelayConfi
 		default:
 			c.sendNotice("invalid: unknown command '" + cmdType + "'")
 		}
+		span.End()
 		metrics.CommandProcessingDuration.WithLabelValues(cmdType).Observe(time.Since(start).Seconds())
 	}
 }
 
+// tracedCommands are the client commands that start a trace.
+var tracedCommands = map[string]bool{"EVENT": true, "REQ": true, "COUNT": true, "CLOSE": true}
+
 // processDispatcherEvents handles real-time events from the event dispatcher
 func (c *WsConnection) processDispatcherEvents() {
 	if c.eventChan == nil {
@@ -661,13 +707,61 @@ func (c *WsConnection) processDispatcherEvents() {
 				return
 			}
 
+			// NIP-38: a withdrawn status's deletion goes wherever the status went
+			match := event
+			if status := GetStatusExpiry().withdrawnStatus(event); status != nil {
+				match = status
+			}
+
+			// NIP-29: Never dispatch private/hidden group events to non-members
+			if !c.canReadGroupEvent(match) {
+				continue
+			}
+
+			// Banned events and events hidden by reports are never dispatched
+			if c.isWithheld(match) {
+				continue
+			}
+
+			// Multi-tenant mode: only events of this connection's relay
+			if !c.inTenant(match) {
+				continue
+			}
+
+			// NIP-47: Wallet Connect messages only go to their addressed parties
+			nwcOnly := GetNWCStore() != nil && nips.IsWalletConnectMessage(event.Kind)
+			inbox := GetDMInbox()
+			authedPK := ""
+			if nwcOnly || inbox != nil {
+				authedPK = c.getAuthenticatedPubkey()
+			}
+
+			// NIP-17 DM inbox: gift wraps only go to their recipient
+			if inbox != nil && !inbox.CanDeliver(event, authedPK) {
+				continue
+			}
+
 			// Check if any subscription matches this event
 			c.subMu.RLock()
 			for subID, filters := range c.subscriptions {
 				for _, filter := range filters {
-					if c.eventMatchesFilter(event, filter) {
+					if nwcOnly && !canDeliverNWC(event, filter, authedPK) {
+						continue
+					}
+					if c.eventMatchesFilter(match, filter) {
+						// Skip events the stored-events replay already sent
+						if !c.shouldSendLive(subID, event.ID) {
+							break
+						}
+						// Replaceable versions are sent once their window ends
+						if c.coalesce != nil && c.coalesce.offer(subID, event) {
+							break
+						}
 						// Send event to client
 						c.sendMessage("EVENT", subID, event)
+						if inbox != nil {
+							inbox.MarkDelivered(event)
+						}
 						logger.Debug("Sent real-time event to client",
 							zap.String("sub_id", subID),
 							zap.String("event_id", event.ID),
@@ -681,13 +775,21 @@ func (c *WsConnection) processDispatcherEvents() {
 	}
 }
 
+// sendCoalesced sends a replaceable version whose coalescing window ended.
+func (c *WsConnection) sendCoalesced(subID string, evt *nostr.Event) {
+	if c.isClosed.Load() || !c.HasSubscription(subID) {
+		return
+	}
+	c.sendMessage("EVENT", subID, evt)
+}
+
 // eventMatchesFilter checks if an event matches a subscription filter
 func (c *WsConnection) eventMatchesFilter(event *nostr.Event, filter nostr.Filter) bool {
-	// Check IDs
+	// Check IDs (exact or NIP-01 prefix)
 	if len(filter.IDs) > 0 {
 		found := false
 		for _, id := range filter.IDs {
-			if event.ID == id {
+			if strings.HasPrefix(event.ID, id) {
 				found = true
 				break
 			}
@@ -697,11 +799,11 @@ func (c *WsConnection) eventMatchesFilter(event *nostr.Event, filter nostr.Filte
 		}
 	}
 
-	// Check authors
+	// Check authors (exact or NIP-01 prefix)
 	if len(filter.Authors) > 0 {
 		found := false
 		for _, author := range filter.Authors {
-			if event.PubKey == author {
+			if strings.HasPrefix(event.PubKey, author) {
 				found = true
 				break
 			}
@@ -788,7 +890,11 @@ func (c *WsConnection) Close() {
 		c.subMu.Lock()
 		oldSubs := len(c.subscriptions)
 		c.subscriptions = make(map[string][]nostr.Filter)
+		c.replays = make(map[string]*replaySet)
 		c.subMu.Unlock()
+		if c.coalesce != nil {
+			c.coalesce.reset()
+		}
 
 		// Clean up NIP-77 negentropy sessions
 		if c.negSessions != nil {
@@ -799,6 +905,7 @@ func (c *WsConnection) Close() {
 		if !c.metricsDecremented.Swap(true) {
 			metrics.ActiveSubscriptions.Sub(float64(oldSubs))
 			metrics.DecrementActiveConnections()
+			releaseIPConnection(c.realClientIP)
 		}
 
 		if c.pingTicker != nil {
@@ -914,6 +1021,7 @@ func (c *WsConnection) AddSubscription(subID string, filters []nostr.Filter) {
 	c.subMu.Lock()
 	defer c.subMu.Unlock()
 	c.subscriptions[subID] = filters
+	c.routeThreads()
 	metrics.IncrementActiveSubscriptions()
 }
 
@@ -921,8 +1029,10 @@ func (c *WsConnection) AddSubscription(subID string, filters []nostr.Filter) {
 func (c *WsConnection) RemoveSubscription(subID string) {
 	c.subMu.Lock()
 	defer c.subMu.Unlock()
+	delete(c.replays, subID)
 	if _, exists := c.subscriptions[subID]; exists {
 		delete(c.subscriptions, subID)
+		c.routeThreads()
 		metrics.DecrementActiveSubscriptions()
 	}
 }
@@ -946,6 +1056,16 @@ func (c *WsConnection) handleEvent(ctx context.Context, arr []interface{}) {
 		c.sendNotice("Invalid event: " + err.Error())
 		return
 	}
+	tracing.SpanFromContext(ctx).SetAttributes(
+		tracing.String("nostr.event_id", evt.ID),
+		tracing.Int("nostr.kind", evt.Kind))
+	c.countClientPublished(evt.Kind)
+
+	// NIP-42: AUTH_REQUIRED relays only accept events on authenticated connections
+	if c.node.Config().Relay.AuthRequired && !c.hasAuthentication() {
+		c.sendOK(evt.ID, false, "auth-required: this relay requires authentication")
+		return
+	}
 
 	// Use ValidateAndProcessEvent for comprehensive validation
 	valid, msg, err := c.node.GetValidator().ValidateAndProcessEvent(ctx, evt)
@@ -957,6 +1077,41 @@ func (c *WsConnection) handleEvent(ctx context.Context, arr []interface{}) {
 		c.sendOK(evt.ID, false, msg)
 		return
 	}
+	if msg == MsgAlreadyDeleted {
+		// NIP-09: nothing left to delete, the request itself is not stored
+		c.sendOK(evt.ID, true, msg)
+		return
+	}
+
+	// NIP-43: Members-only relays accept writes from members and join requests only
+	if cfg := c.node.Config(); membersOnly(cfg) {
+		if !GetMembershipStore().CanWrite(&evt, cfg) {
+			c.sendOK(evt.ID, false, "restricted: this relay is members-only, send a join request with an invite code")
+			return
+		}
+	}
+
+	// NIP-05: authors may be required to hold a verified identifier
+	if reason := c.checkNIP05Write(ctx, &evt); reason != "" {
+		c.sendOK(evt.ID, false, reason)
+		return
+	}
+
+	// Multi-tenant mode: the virtual relay's own publish policies
+	if c.tenant != nil {
+		if ok, reason := c.tenant.CheckEvent(&evt); !ok {
+			c.sendOK(evt.ID, false, reason)
+			return
+		}
+	}
+
+	// NIP-17 DM inbox: only gift wraps for local users and their DM relay lists
+	if inbox := GetDMInbox(); inbox != nil {
+		if ok, reason := inbox.CheckEvent(&evt); !ok {
+			c.sendOK(evt.ID, false, reason)
+			return
+		}
+	}
 
 	// NIP-70: Reject protected events unless the author is authenticated on this connection
 	if nips.IsProtectedEvent(&evt) {
@@ -966,13 +1121,75 @@ func (c *WsConnection) handleEvent(ctx context.Context, arr []interface{}) {
 		}
 	}
 
+	// Namespace policies: community-scoped kind rules and rate limits
+	if nps := GetNamespacePolicyStore(); nps != nil {
+		if evt.Kind == KindNamespacePolicy {
+			if ok, reason := nps.HandlePolicyEvent(&evt); !ok {
+				c.sendOK(evt.ID, false, reason)
+				return
+			}
+		} else if ok, reason := nps.CheckEvent(&evt); !ok {
+			c.sendOK(evt.ID, false, reason)
+			return
+		}
+	}
+
+	// NIP-47: Wallet Connect traffic has its own per-pubkey rate limit
+	nwc := GetNWCStore()
+	if nwc != nil && nips.IsWalletConnectMessage(evt.Kind) {
+		if ok, reason := nwc.CheckEvent(&evt); !ok {
+			c.sendOK(evt.ID, false, reason)
+			return
+		}
+	}
+
+	// Content scoring: events of shadow-banned authors and suspected spam are
+	// acknowledged as accepted, but discarded
+	if reason := shadowRejectReason(ctx, &evt); reason != "" {
+		c.shadowReject(&evt, reason)
+		return
+	}
+
+	// Near-duplicate notes flooded by many pubkeys are throttled or dropped
+	switch checkSpamCluster(&evt) {
+	case spamcluster.ActionShadowReject:
+		c.shadowReject(&evt, ShadowRejectCluster)
+		return
+	case spamcluster.ActionThrottle:
+		c.sendOK(evt.ID, false, "rate-limited: too many copies of this note")
+		return
+	}
+
+	// Web of trust: pubkeys outside the trust graph have a stricter rate limit
+	if rep := GetReputation(); rep != nil {
+		if ok, reason := rep.CheckEvent(&evt); !ok {
+			if rep.ShadowRejects() {
+				c.shadowReject(&evt, ShadowRejectLowReputation)
+				return
+			}
+			c.sendOK(evt.ID, false, reason)
+			return
+		}
+	}
+
+	// Storage quota: authors over their stored bytes may only delete
+	if msg := c.checkStorageQuota(ctx, &evt); msg != "" {
+		c.sendOK(evt.ID, false, msg)
+		return
+	}
+
 	// NIP-29: Validate and process group events
 	if IsGroupEvent(&evt) {
 		gs := GetGroupStore()
 		if gs != nil {
 			ok, reason := gs.ValidateGroupEvent(&evt)
+			auditGroupModeration(&evt, ok, reason, c.realClientIP)
 			if !ok {
-				c.sendOK(evt.ID, false, "blocked: "+reason)
+				// Reasons without a machine-readable prefix are group policy
+				if code, _, _ := strings.Cut(reason, ":"); !nips.IsStandardErrorCode(code) {
+					reason = nips.FormatErrorMessage(nips.ErrorCodeBlacklisted, reason)
+				}
+				c.sendOK(evt.ID, false, reason)
 				return
 			}
 			// Process group state changes and get relay-generated events
@@ -980,7 +1197,7 @@ func (c *WsConnection) handleEvent(ctx context.Context, arr []interface{}) {
 			for _, relayEvt := range relayEvents {
 				// Store relay-generated metadata events
 				if relayEvt != nil {
-					c.node.GetEventProcessor().QueueEvent(*relayEvt)
+					queueRelayEvent(c.node, relayEvt)
 				}
 			}
 		}
@@ -997,7 +1214,7 @@ func (c *WsConnection) handleEvent(ctx context.Context, arr []interface{}) {
 		// Store relay-generated events (membership list updates, add/remove)
 		for _, relayEvt := range relayEvents {
 			if relayEvt != nil {
-				c.node.GetEventProcessor().QueueEvent(*relayEvt)
+				queueRelayEvent(c.node, relayEvt)
 			}
 		}
 		if msg != "" {
@@ -1010,24 +1227,63 @@ func (c *WsConnection) handleEvent(ctx context.Context, arr []interface{}) {
 		}
 	}
 
-	// Queue the event for processing
-	if ok := c.node.GetEventProcessor().QueueEvent(evt); !ok {
+	// Multi-tenant mode: record which virtual relay the event was published through
+	if GetTenants() != nil {
+		if err := c.node.DB().TagEventTenant(ctx, &evt, c.tenant.ID()); err != nil {
+			logger.Error("Failed to record event tenant",
+				zap.String("event_id", evt.ID),
+				zap.String("tenant", c.tenant.ID()),
+				zap.Error(err))
+			c.sendOK(evt.ID, false, "error: failed to store event")
+			return
+		}
+	}
+
+	// Queue the event for processing, keeping hold of the write in durable mode
+	ack := c.node.Config().Relay.Ack
+	mode := ackMode(ack)
+	var done <-chan error
+	var ok bool
+	if mode == ackDurable {
+		done, ok = c.node.GetEventProcessor().QueueEventDurable(ctx, evt)
+	} else {
+		ok = c.node.GetEventProcessor().QueueEventContext(ctx, evt)
+	}
+	if !ok {
 		c.sendOK(evt.ID, false, "server busy, try again")
 		return
 	}
 
+	// NIP-47: Keep Wallet Connect messages for a reconnecting wallet or client
+	if nwc != nil && nips.IsWalletConnectMessage(evt.Kind) {
+		nwc.Retain(&evt)
+	}
+
 	// Update metrics for successful event
 	metrics.EventsProcessed.WithLabelValues(fmt.Sprintf("%d", evt.Kind)).Inc()
+	metrics.RecordLiveAccepted(evt.Kind, evt.PubKey)
+	if evt.Kind == nips.KindCodeSnippet {
+		metrics.RecordCodeSnippet(nips.CodeSnippetLanguage(&evt))
+	}
 
 	// Send successful response
-	c.sendOK(evt.ID, true, "")
+	switch mode {
+	case ackDurable:
+		// Wait off the read loop so the connection keeps serving commands
+		go c.sendDurableOK(evt.ID, done, ack.Timeout)
+	case ackPending:
+		c.sendOK(evt.ID, true, ackPendingMessage)
+	default:
+		c.sendOK(evt.ID, true, "")
+	}
 }
 
 // QueryEvents reads events from storage that match a given Nostr filter.
 func (c *WsConnection) QueryEvents(ctx context.Context, f nostr.Filter) ([]nostr.Event, error) {
 	logger.Debug("QueryEvents called with filter", zap.Any("filter", f))
 
-	results, err := c.node.DB().GetEvents(ctx, f)
+	// Client queries tolerate replica lag, live events arrive by dispatch
+	results, err := c.node.DB().GetEvents(storage.WithReplicaRead(c.tenantScope(ctx)), f)
 	if err != nil {
 		logger.Error("Error retrieving events from storage", zap.Error(err))
 		return nil, err
@@ -0,0 +1,226 @@
+package relay
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/domain"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// DM inbox mode: the relay serves as a NIP-17 inbox for a fixed set of local users.
+//
+// In DM inbox mode the relay:
+//   - accepts only gift wraps (kind 1059) addressed to a local user and DM
+//     relay lists (kind 10050) published by a local user
+//   - answers REQs only on NIP-42 authenticated connections and only for those
+//     two kinds; gift wraps go to their recipient alone, stored or live
+//   - deletes a gift wrap DELIVERED_RETENTION after it was first sent to its
+//     recipient, so delivered messages do not accumulate on the relay
+
+const (
+	kindGiftWrap    = 1059
+	kindDMRelayList = 10050
+)
+
+// maxDMDelivered bounds the delivered gift wraps awaiting deletion. Wraps
+// delivered beyond it are simply kept until the next delivery.
+const maxDMDelivered = 100000
+
+// dmPurgeInterval is how often delivered gift wraps are checked for deletion.
+const dmPurgeInterval = time.Minute
+
+// deliveredWrap is a gift wrap its recipient has received.
+type deliveredWrap struct {
+	evt         *nostr.Event
+	deliveredAt time.Time
+}
+
+// DMInbox enforces DM inbox mode and tracks delivered gift wraps.
+type DMInbox struct {
+	users     map[string]bool
+	retention time.Duration
+
+	mu        sync.Mutex
+	delivered map[string]deliveredWrap
+}
+
+// dmInboxInstance is the package-level DM inbox singleton (nil when DM inbox mode is off).
+var dmInboxInstance *DMInbox
+
+// GetDMInbox returns the package-level DM inbox, or nil when DM inbox mode is disabled.
+func GetDMInbox() *DMInbox {
+	return dmInboxInstance
+}
+
+// InitDMInbox initializes the package-level DM inbox. Called from NewServer.
+func InitDMInbox(cfg *config.Config) *DMInbox {
+	dc := cfg.Relay.DMInbox
+	if !dc.Enabled {
+		dmInboxInstance = nil
+		return nil
+	}
+
+	log := logger.New("dm_inbox")
+	users := make(map[string]bool, len(dc.Users))
+	for _, pk := range dc.Users {
+		pk = strings.ToLower(strings.TrimSpace(pk))
+		if !nostr.IsValid32ByteHex(pk) {
+			log.Warn("Ignoring invalid DM inbox user", zap.String("pubkey", pk))
+			continue
+		}
+		users[pk] = true
+	}
+	if len(users) == 0 {
+		log.Warn("DM inbox mode is enabled without local users, every event will be rejected")
+	}
+
+	retention := dc.DeliveredRetention
+	if retention <= 0 {
+		retention = 24 * time.Hour
+	}
+
+	dmInboxInstance = &DMInbox{
+		users:     users,
+		retention: retention,
+		delivered: make(map[string]deliveredWrap),
+	}
+	log.Info("DM inbox mode enabled",
+		zap.Int("users", len(users)),
+		zap.Duration("delivered_retention", retention))
+	return dmInboxInstance
+}
+
+// IsLocalUser reports whether pubkey is served by this inbox.
+func (d *DMInbox) IsLocalUser(pubkey string) bool {
+	return d.users[strings.ToLower(pubkey)]
+}
+
+// CheckEvent decides whether an event may be published to the inbox.
+// Returns (allowed, reason).
+func (d *DMInbox) CheckEvent(evt *nostr.Event) (bool, string) {
+	switch evt.Kind {
+	case kindGiftWrap:
+		if recipient := giftWrapRecipient(evt); recipient == "" || !d.IsLocalUser(recipient) {
+			metrics.DMInboxEvents.WithLabelValues("rejected").Inc()
+			return false, "restricted: gift wrap recipient is not a user of this inbox"
+		}
+	case kindDMRelayList:
+		if !d.IsLocalUser(evt.PubKey) {
+			metrics.DMInboxEvents.WithLabelValues("rejected").Inc()
+			return false, "restricted: only users of this inbox may publish DM relay lists"
+		}
+	default:
+		metrics.DMInboxEvents.WithLabelValues("rejected").Inc()
+		return false, "blocked: this relay is a DM inbox and only accepts gift wraps (kind 1059) and DM relay lists (kind 10050)"
+	}
+	metrics.DMInboxEvents.WithLabelValues("accepted").Inc()
+	return true, ""
+}
+
+// CheckFilter returns the CLOSED reason for a REQ filter the inbox refuses.
+func (d *DMInbox) CheckFilter(f nostr.Filter, authedPK string) string {
+	if authedPK == "" {
+		return "auth-required: this relay is a DM inbox, authenticate to read your messages"
+	}
+	if len(f.Kinds) == 0 {
+		return "restricted: DM inbox queries must ask for kind 1059 or 10050"
+	}
+	for _, k := range f.Kinds {
+		if k != kindGiftWrap && k != kindDMRelayList {
+			return "restricted: DM inbox queries must ask for kind 1059 or 10050"
+		}
+	}
+	return ""
+}
+
+// CanDeliver reports whether an event may be sent to a connection
+// authenticated as authedPK. Gift wraps only ever go to their recipient.
+func (d *DMInbox) CanDeliver(evt *nostr.Event, authedPK string) bool {
+	if evt.Kind != kindGiftWrap {
+		return true
+	}
+	return authedPK != "" && giftWrapRecipient(evt) == authedPK
+}
+
+// MarkDelivered starts the retention clock of a gift wrap sent to its recipient.
+func (d *DMInbox) MarkDelivered(evt *nostr.Event) {
+	if evt.Kind != kindGiftWrap {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.delivered[evt.ID]; ok || len(d.delivered) >= maxDMDelivered {
+		return
+	}
+	d.delivered[evt.ID] = deliveredWrap{evt: evt, deliveredAt: time.Now()}
+	metrics.DMInboxEvents.WithLabelValues("delivered").Inc()
+}
+
+// PurgeDelivered deletes gift wraps whose retention after delivery has passed
+// and returns how many were removed.
+func (d *DMInbox) PurgeDelivered(ctx context.Context, node domain.NodeInterface) int {
+	cutoff := time.Now().Add(-d.retention)
+
+	d.mu.Lock()
+	var due []*nostr.Event
+	for id, w := range d.delivered {
+		if w.deliveredAt.Before(cutoff) {
+			due = append(due, w.evt)
+			delete(d.delivered, id)
+		}
+	}
+	d.mu.Unlock()
+
+	removed := 0
+	for _, evt := range due {
+		if err := node.DB().RemoveEvent(ctx, evt); err != nil {
+			logger.New("dm_inbox").Warn("Failed to delete delivered gift wrap",
+				zap.String("event_id", evt.ID),
+				zap.Error(err))
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		metrics.DMInboxEvents.WithLabelValues("purged").Add(float64(removed))
+	}
+	return removed
+}
+
+// giftWrapRecipient returns the pubkey a gift wrap is addressed to.
+func giftWrapRecipient(evt *nostr.Event) string {
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			return strings.ToLower(tag[1])
+		}
+	}
+	return ""
+}
+
+// purgeDeliveredDMs periodically deletes gift wraps delivered long enough ago.
+func purgeDeliveredDMs(ctx context.Context, node domain.NodeInterface) {
+	d := GetDMInbox()
+	if d == nil {
+		return
+	}
+	ticker := time.NewTicker(dmPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := d.PurgeDelivered(ctx, node); n > 0 {
+				logger.New("dm_inbox").Debug("Deleted delivered gift wraps", zap.Int("count", n))
+			}
+		}
+	}
+}
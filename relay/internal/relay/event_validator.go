@@ -6,6 +6,7 @@ import (
 
 	"github.com/Shugur-Network/relay/internal/config"
 	"github.com/Shugur-Network/relay/internal/storage"
+	"github.com/Shugur-Network/relay/internal/workers"
 )
 
 // EventValidator provides validation services for Nostr events
@@ -32,7 +33,7 @@ type EventCounter struct {
 }
 
 // NewEventValidator creates a new event validator instance
-func NewEventValidator(cfg *config.Config, db *storage.DB) *EventValidator {
+func NewEventValidator(cfg *config.Config, db *storage.DB, sigVerifier *workers.SignatureVerifier) *EventValidator {
 	// Create rate limiter with general limits
 	limiter := &RateLimiter{
 		limitPerMin:    cfg.Relay.ThrottlingConfig.RateLimit.MaxEventsPerSecond * 60,
@@ -47,7 +48,7 @@ func NewEventValidator(cfg *config.Config, db *storage.DB) *EventValidator {
 	go limiter.cleanupInactiveCounters()
 
 	validator := &EventValidator{
-		validator:   NewPluginValidator(cfg, db),
+		validator:   NewPluginValidator(cfg, db, sigVerifier),
 		db:          db,
 		rateLimiter: limiter,
 	}
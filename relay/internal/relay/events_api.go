@@ -0,0 +1,442 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// HTTP query API: read-only access to stored events for integrations without
+// a websocket library. Filters go through the same validation, visibility
+// rules and storage queries as a REQ from an unauthenticated client.
+//
+//	GET /api/events?kinds=1,7&authors=<hex>&#t=nostr&since=<ts>&until=<ts>&limit=50
+//	GET /api/event/<id>
+//
+// List values may be comma separated or repeated. Results are newest first,
+// or oldest first when only since is given; when a page is full, "next" holds
+// the URL of the following page, which resumes after its last event with a
+// cursor=<created_at>:<id> parameter.
+//
+// With WEB.EVENTS_API.RECEIVED_AT, each event carries the time this relay
+// first received it as a received_at extension field, and received_since and
+// received_until filter on it, for operators debugging propagation delays.
+
+// Default for WEB.EVENTS_API.MAX_CONCURRENT_QUERIES when left unset.
+const defaultEventsAPIConcurrency = 16
+
+// eventsAPIQueryTimeout bounds one HTTP query against storage.
+const eventsAPIQueryTimeout = 10 * time.Second
+
+// eventsAPIResponse is the body of /api/events.
+type eventsAPIResponse struct {
+	Events []apiEvent `json:"events"`
+	Count  int        `json:"count"`
+	Next   string     `json:"next,omitempty"`
+}
+
+// apiEvent is an event as served over HTTP, with the relay's first-seen time
+// when it is exposed and known.
+type apiEvent struct {
+	nostr.Event
+	ReceivedAt int64
+}
+
+// MarshalJSON writes the event object, adding received_at when set. The
+// embedded event has its own marshaller, which would drop the field.
+func (e apiEvent) MarshalJSON() ([]byte, error) {
+	raw, err := json.Marshal(e.Event)
+	if err != nil || e.ReceivedAt == 0 {
+		return raw, err
+	}
+	raw = raw[:len(raw)-1] // closing brace
+	return append(raw, fmt.Sprintf(`,"received_at":%d}`, e.ReceivedAt)...), nil
+}
+
+// eventsAPI holds the HTTP query API state of a Server.
+type eventsAPI struct {
+	enabled    bool
+	receivedAt bool
+	limits     queryLimits
+	slots      chan struct{}
+}
+
+// newEventsAPI builds the HTTP query API from cfg.
+func newEventsAPI(cfg *config.Config) *eventsAPI {
+	n := cfg.Web.EventsAPI.MaxConcurrentQueries
+	if n <= 0 {
+		n = defaultEventsAPIConcurrency
+	}
+	return &eventsAPI{
+		enabled:    cfg.Web.EventsAPI.Enabled,
+		receivedAt: cfg.Web.EventsAPI.ReceivedAt,
+		limits:     newQueryLimits(cfg.Relay.ThrottlingConfig),
+		slots:      make(chan struct{}, n),
+	}
+}
+
+// handleEventsAPI serves GET /api/events.
+func (s *Server) handleEventsAPI(w http.ResponseWriter, r *http.Request) {
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled) {
+		return
+	}
+	query := r.URL.Query()
+	received, err := s.eventsAPIReceivedRange(query)
+	if err != nil {
+		writeEventsAPIError(w, r, "INVALID_FILTER", err.Error())
+		return
+	}
+	var cursor storage.PageCursor
+	if query.Has("cursor") {
+		if cursor, err = storage.ParsePageCursor(query.Get("cursor")); err != nil {
+			writeEventsAPIError(w, r, "INVALID_FILTER", err.Error())
+			return
+		}
+		query.Del("cursor")
+	}
+	f, err := s.eventsAPIFilter(query)
+	if err != nil {
+		writeEventsAPIError(w, r, "INVALID_FILTER", err.Error())
+		return
+	}
+	if received != (storage.ReceivedRange{}) {
+		r = r.WithContext(storage.WithReceivedRange(r.Context(), received))
+	}
+	events, next, ok := s.queryEventsAPI(w, r.WithContext(storage.WithPageCursor(r.Context(), cursor)), f)
+	if !ok {
+		return
+	}
+
+	response := eventsAPIResponse{Events: s.apiEvents(r.Context(), events), Count: len(events)}
+	if next != nil {
+		// The next page resumes after the last event of this one
+		query := r.URL.Query()
+		query.Set("cursor", next.String())
+		response.Next = r.URL.Path + "?" + query.Encode()
+	}
+	writeEventsAPIJSON(w, response)
+}
+
+// handleEventAPI serves GET /api/event/<id>.
+func (s *Server) handleEventAPI(w http.ResponseWriter, r *http.Request) {
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled) {
+		return
+	}
+	id := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/api/event/"))
+	if !nostr.IsValid32ByteHex(id) {
+		writeEventsAPIError(w, r, "INVALID_EVENT_ID", "event id must be 64 hex characters")
+		return
+	}
+	events, _, ok := s.queryEventsAPI(w, r, nostr.Filter{IDs: []string{id}, Limit: 1})
+	if !ok {
+		return
+	}
+	if len(events) == 0 {
+		errors.HandleHTTPError(w, r, errors.NotFoundError("event"))
+		return
+	}
+	writeEventsAPIJSON(w, s.apiEvents(r.Context(), events)[0])
+}
+
+// apiEvents wraps events for an HTTP response, looking up when the relay
+// received them if WEB.EVENTS_API.RECEIVED_AT is set.
+func (s *Server) apiEvents(ctx context.Context, events []nostr.Event) []apiEvent {
+	out := make([]apiEvent, len(events))
+	for i := range events {
+		out[i].Event = events[i]
+	}
+	if !s.eventsAPI.receivedAt || len(events) == 0 {
+		return out
+	}
+
+	ids := make([]string, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+	ctx, cancel := context.WithTimeout(ctx, eventsAPIQueryTimeout)
+	defer cancel()
+	received, err := s.node.DB().GetReceivedAt(ctx, ids)
+	if err != nil {
+		// The events are still worth serving without it
+		logger.Warn("Events API received_at lookup failed", zap.Error(err))
+		return out
+	}
+	for i := range out {
+		out[i].ReceivedAt = received[out[i].ID]
+	}
+	return out
+}
+
+// eventsAPIReceivedRange takes the received_since and received_until
+// parameters out of query, which only exist with WEB.EVENTS_API.RECEIVED_AT.
+func (s *Server) eventsAPIReceivedRange(query url.Values) (storage.ReceivedRange, error) {
+	var rng storage.ReceivedRange
+	if !s.eventsAPI.receivedAt {
+		return rng, nil
+	}
+	for _, param := range []string{"received_since", "received_until"} {
+		if !query.Has(param) {
+			continue
+		}
+		ts, err := strconv.ParseInt(query.Get(param), 10, 64)
+		if err != nil || ts <= 0 {
+			return rng, fmt.Errorf("%s must be a unix timestamp", param)
+		}
+		if param == "received_since" {
+			rng.Since = ts
+		} else {
+			rng.Until = ts
+		}
+		query.Del(param)
+	}
+	return rng, nil
+}
+
+// prepareEventsAPI writes the common headers and refuses requests the HTTP
+// read endpoints do not serve, returning false when the response is complete.
+func (s *Server) prepareEventsAPI(w http.ResponseWriter, r *http.Request, enabled bool) bool {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return false
+	}
+	if r.Method != http.MethodGet {
+		errors.HandleHTTPError(w, r, errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed."))
+		return false
+	}
+	if !enabled {
+		errors.HandleHTTPError(w, r, errors.NotFoundError(r.URL.Path).
+			WithUserMessage("This endpoint is disabled on this relay."))
+		return false
+	}
+
+	// Readers here are never authenticated, so relays that require NIP-42
+	// AUTH for reading are only served over websockets
+	if cfg := s.fullCfg; cfg.Relay.AuthRequired || cfg.Relay.AccessMode == "private" {
+		errors.HandleHTTPError(w, r, errors.AuthenticationError("this relay requires NIP-42 authentication").
+			WithUserMessage("This relay requires authentication, connect with a websocket client."))
+		return false
+	}
+	return true
+}
+
+// eventsAPIFilter builds and validates the filter of an /api/events request.
+func (s *Server) eventsAPIFilter(query url.Values) (nostr.Filter, error) {
+	var f nostr.Filter
+	for param, values := range query {
+		var list []string
+		for _, v := range values {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+		}
+
+		switch {
+		case param == "ids":
+			f.IDs = lowerAll(list)
+		case param == "authors":
+			f.Authors = lowerAll(list)
+		case param == "kinds":
+			for _, item := range list {
+				k, err := strconv.Atoi(item)
+				if err != nil {
+					return f, fmt.Errorf("invalid kind %q", item)
+				}
+				f.Kinds = append(f.Kinds, k)
+			}
+		case param == "since", param == "until":
+			ts, err := strconv.ParseInt(query.Get(param), 10, 64)
+			if err != nil || ts < 0 {
+				return f, fmt.Errorf("%s must be a unix timestamp", param)
+			}
+			t := nostr.Timestamp(ts)
+			if param == "since" {
+				f.Since = &t
+			} else {
+				f.Until = &t
+			}
+		case param == "limit":
+			n, err := strconv.Atoi(query.Get(param))
+			if err != nil || n < 1 {
+				return f, fmt.Errorf("limit must be a positive number")
+			}
+			f.Limit = n
+		case param == "search":
+			f.Search = query.Get(param)
+		case len(param) == 2 && param[0] == '#' && isTagLetter(param[1]):
+			if f.Tags == nil {
+				f.Tags = make(nostr.TagMap)
+			}
+			f.Tags[param[1:]] = list
+		default:
+			return f, fmt.Errorf("unknown parameter %q", param)
+		}
+	}
+
+	if f.Limit > s.eventsAPI.limits.maxLimit {
+		return f, fmt.Errorf("limit %d exceeds the maximum of %d", f.Limit, s.eventsAPI.limits.maxLimit)
+	}
+	if f.Limit == 0 {
+		f.Limit = s.eventsAPI.limits.maxLimit
+	}
+	if n := filterComplexity([]nostr.Filter{f}); n > maxFilterComplexity {
+		return f, fmt.Errorf("filter too complex (%d values, max %d)", n, maxFilterComplexity)
+	}
+	return f, s.checkAnonymousFilter(f)
+}
+
+// checkAnonymousFilter applies the REQ filter checks for a reader that has
+// not authenticated.
+func (s *Server) checkAnonymousFilter(f nostr.Filter) error {
+	if err := s.node.GetValidator().ValidateFilter(f); err != nil {
+		return err
+	}
+	if containsKind(f.Kinds, nips.KindRelayList) {
+		if err := nips.ValidateRelayListFilter(f); err != nil {
+			return err
+		}
+	}
+	if f.Search != "" {
+		if err := nips.ValidateSearchFilter(f, nips.DefaultSearchOptions()); err != nil {
+			return err
+		}
+	}
+	for _, k := range f.Kinds {
+		if k == 4 || k == 14 || k == 15 || k == 1059 {
+			return fmt.Errorf("direct messages require NIP-42 authentication")
+		}
+	}
+	if inbox := GetDMInbox(); inbox != nil {
+		if reason := inbox.CheckFilter(f, ""); reason != "" {
+			return fmt.Errorf("%s", reason)
+		}
+	}
+	if gs := GetGroupStore(); gs != nil {
+		if reason := gs.CheckFilterAccess(f, ""); reason != "" {
+			return fmt.Errorf("%s", reason)
+		}
+	}
+	return nil
+}
+
+// queryEventsAPI runs f for the Host tenant and drops the events an
+// unauthenticated reader may not see. When storage filled the limit of a
+// paged query, next resumes after its last event, in the order storage
+// applied the limit. It writes the error response and returns false when the
+// query fails.
+func (s *Server) queryEventsAPI(w http.ResponseWriter, r *http.Request, f nostr.Filter) (visible []nostr.Event, next *storage.PageCursor, ok bool) {
+	release, ok := s.acquireEventsAPISlot(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(eventsAPIContext(r), eventsAPIQueryTimeout)
+	defer cancel()
+
+	events, err := s.node.DB().GetEvents(ctx, f)
+	if err != nil {
+		logger.Warn("Events API query failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("events query", err))
+		return nil, nil, false
+	}
+	if _, paged := storage.PageCursorFromContext(r.Context()); paged && len(events) >= f.Limit && len(events) > 0 {
+		cursor := storage.CursorAfter(events[len(events)-1])
+		next = &cursor
+	}
+
+	db := s.node.DB()
+	visible = make([]nostr.Event, 0, len(events))
+	for _, evt := range events {
+		if !visibleAnonymously(db, &evt) {
+			continue
+		}
+		if len(f.Kinds) == 1 && f.Kinds[0] == nips.KindRelayList {
+			if err := nips.ValidateKind10002(evt); err != nil {
+				continue
+			}
+		}
+		visible = append(visible, evt)
+	}
+	return visible, next, true
+}
+
+// acquireEventsAPISlot takes one of the concurrent query slots, answering
+// 429 and returning false when all are busy.
+func (s *Server) acquireEventsAPISlot(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	select {
+	case s.eventsAPI.slots <- struct{}{}:
+		return func() { <-s.eventsAPI.slots }, true
+	default:
+		w.Header().Set("Retry-After", "1")
+		errors.HandleHTTPError(w, r, errors.RateLimitError("events API queries"))
+		return nil, false
+	}
+}
+
+// eventsAPIContext returns the request context scoped to the Host tenant.
+func eventsAPIContext(r *http.Request) context.Context {
+	ctx := storage.WithReplicaRead(r.Context())
+	if tenants := GetTenants(); tenants != nil {
+		ctx = storage.WithTenant(ctx, tenants.ForHost(r.Host).ID())
+	}
+	return ctx
+}
+
+// visibleAnonymously reports whether evt may be served to a reader that has
+// not authenticated.
+func visibleAnonymously(db *storage.DB, evt *nostr.Event) bool {
+	switch evt.Kind {
+	case 4, 14, 15, 1059:
+		return false
+	}
+	if GetNWCStore() != nil && nips.IsWalletConnectMessage(evt.Kind) {
+		return false
+	}
+	if isWithheldEvent(db, evt) {
+		return false
+	}
+	if gs := GetGroupStore(); gs != nil && !gs.CanReadEvent(evt, "") {
+		return false
+	}
+	return true
+}
+
+// writeEventsAPIError answers a malformed request with 400 and its reason.
+func writeEventsAPIError(w http.ResponseWriter, r *http.Request, code, reason string) {
+	errors.HandleHTTPError(w, r, errors.ValidationError(code, reason).WithUserMessage(reason))
+}
+
+// writeEventsAPIJSON writes v as the 200 response.
+func writeEventsAPIJSON(w http.ResponseWriter, v interface{}) {
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("Failed to encode events API response", zap.Error(err))
+	}
+}
+
+// isTagLetter reports whether c names a single-letter tag (NIP-01).
+func isTagLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
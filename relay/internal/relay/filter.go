@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	nostr "github.com/nbd-wtf/go-nostr"
@@ -48,7 +49,21 @@ func parseFilterFromRaw(raw interface{}) (nostr.Filter, error) {
 		}
 	}
 
-	// Step 5: Apply filter normalization
+	// Step 5: Expand the NIP-99 "geo" extension into a #g filter
+	if geo, ok := partial["geo"]; ok {
+		if err = applyGeoFilter(&f, geo); err != nil {
+			return f, err
+		}
+	}
+
+	// Step 6: Expand the NIP-32 "label" extension into ids or authors
+	if label, ok := partial["label"]; ok {
+		if err = applyLabelFilter(&f, label); err != nil {
+			return f, err
+		}
+	}
+
+	// Step 7: Apply filter normalization
 	normalizeFilter(&f)
 
 	return f, nil
@@ -56,17 +71,12 @@ func parseFilterFromRaw(raw interface{}) (nostr.Filter, error) {
 
 // normalizeFilter applies normalization rules to ensure filter consistency
 func normalizeFilter(f *nostr.Filter) {
-	// Cap result limit to reasonable values
-	if f.Limit <= 0 || f.Limit > 500 {
-		f.Limit = 500
-	}
-
-	// Normalize IDs and Authors to lowercase if needed
+	// Lowercase IDs and authors; values shorter than 64 characters are NIP-01 prefixes
 	for i, id := range f.IDs {
-		if len(id) < 64 {
-			// Pad shorter IDs with prefix matching
-			f.IDs[i] = id + strings.Repeat("0", 64-len(id))
-		}
+		f.IDs[i] = strings.ToLower(id)
+	}
+	for i, author := range f.Authors {
+		f.Authors[i] = strings.ToLower(author)
 	}
 
 	// Ensure search terms are properly formatted
@@ -89,16 +99,16 @@ func ValidateFilter(f nostr.Filter) error {
 		return fmt.Errorf("filter must have at least one condition")
 	}
 
-	// Validate IDs format
+	// Validate IDs format (full IDs or NIP-01 prefixes)
 	for _, id := range f.IDs {
-		if len(id) > 0 && len(id) <= 64 && !isHexString(id) {
+		if len(id) > 64 || !isHexPrefix(id) {
 			return fmt.Errorf("invalid ID format: %s", id)
 		}
 	}
 
-	// Validate Authors format
+	// Validate Authors format (full pubkeys or NIP-01 prefixes)
 	for _, author := range f.Authors {
-		if len(author) > 0 && !nostr.IsValid32ByteHex(author) {
+		if len(author) > 64 || !isHexPrefix(author) {
 			return fmt.Errorf("invalid author pubkey: %s", author)
 		}
 	}
@@ -145,3 +155,151 @@ func isHexString(s string) bool {
 	_, err := hex.DecodeString(s)
 	return err == nil
 }
+
+// defaultMinFilterPrefix is the shortest id/author prefix accepted when
+// RELAY.MIN_FILTER_PREFIX is unset.
+const defaultMinFilterPrefix = 8
+
+// isHexPrefix reports whether s is a non-empty run of lowercase hex digits.
+// Unlike isHexString it accepts odd lengths.
+func isHexPrefix(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHexPrefix validates a filter id or author: 64 hex characters, or a
+// prefix of at least minLen.
+func checkHexPrefix(field, value string, minLen int) error {
+	if len(value) > 64 || !isHexPrefix(value) {
+		return fmt.Errorf("invalid %s: %s", field, value)
+	}
+	if len(value) < 64 && len(value) < minLen {
+		return fmt.Errorf("%s prefix too short (min %d hex characters): %s", field, minLen, value)
+	}
+	return nil
+}
+
+// maxFilterComplexity caps the combined number of IDs, authors, kinds and tag
+// values across the filters of one REQ after merging.
+const maxFilterComplexity = 2000
+
+// canonicalizeFilter sorts and deduplicates the filter's value lists so that
+// equivalent filters compare equal regardless of the order clients sent them in.
+func canonicalizeFilter(f *nostr.Filter) {
+	f.IDs = sortedUnique(lowerAll(f.IDs))
+	f.Authors = sortedUnique(lowerAll(f.Authors))
+	if f.Kinds != nil {
+		slices.Sort(f.Kinds)
+		f.Kinds = slices.Compact(f.Kinds)
+	}
+	for name, values := range f.Tags {
+		f.Tags[name] = sortedUnique(values)
+	}
+}
+
+// sortedUnique sorts values and drops repeats, keeping nil and empty distinct
+// since an empty list matches nothing while a nil one matches everything.
+func sortedUnique(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	slices.Sort(values)
+	return slices.Compact(values)
+}
+
+// lowerAll lowercases hex values in place; tag values stay case-sensitive.
+func lowerAll(values []string) []string {
+	for i, v := range values {
+		values[i] = strings.ToLower(v)
+	}
+	return values
+}
+
+// mergeFilters canonicalizes the filters of a REQ, drops exact duplicates and
+// folds filters that differ only in their authors into one, so storage runs
+// one query where the client asked for several. A limit applies to its own
+// filter alone, so only filters left at the default limit, maxLimit, are
+// folded; with an explicit limit the authors of one filter could take the
+// results of another.
+func mergeFilters(filters []nostr.Filter, maxLimit int) []nostr.Filter {
+	merged := make([]nostr.Filter, 0, len(filters))
+	exact := make(map[string]bool, len(filters))
+	byShape := make(map[string]int, len(filters))
+
+	for _, f := range filters {
+		canonicalizeFilter(&f)
+
+		key := filterKey(f, true)
+		if exact[key] {
+			continue
+		}
+		exact[key] = true
+
+		if len(f.Authors) == 0 || f.Limit != maxLimit {
+			merged = append(merged, f)
+			continue
+		}
+		shape := filterKey(f, false)
+		i, ok := byShape[shape]
+		if !ok {
+			byShape[shape] = len(merged)
+			merged = append(merged, f)
+			continue
+		}
+
+		// Same shape: query the union of authors
+		m := &merged[i]
+		m.Authors = sortedUnique(append(m.Authors, f.Authors...))
+	}
+	return merged
+}
+
+// filterKey serializes a canonical filter deterministically. Authors are left
+// out when withAuthors is false, which groups filters by their shape.
+func filterKey(f nostr.Filter, withAuthors bool) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("ids=%v|%t", f.IDs, f.IDs != nil))
+	if withAuthors {
+		b.WriteString("|authors=" + strings.Join(f.Authors, ","))
+		b.WriteString(fmt.Sprintf("|limit=%d", f.Limit))
+	}
+	b.WriteString(fmt.Sprintf("|kinds=%v|%t", f.Kinds, f.Kinds != nil))
+
+	names := make([]string, 0, len(f.Tags))
+	for name := range f.Tags {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		b.WriteString("|#" + name + "=" + strings.Join(f.Tags[name], ","))
+	}
+
+	if f.Since != nil {
+		b.WriteString(fmt.Sprintf("|since=%d", *f.Since))
+	}
+	if f.Until != nil {
+		b.WriteString(fmt.Sprintf("|until=%d", *f.Until))
+	}
+	b.WriteString("|search=" + f.Search)
+	return b.String()
+}
+
+// filterComplexity counts the values a set of filters asks storage to match.
+func filterComplexity(filters []nostr.Filter) int {
+	n := 0
+	for _, f := range filters {
+		n += len(f.IDs) + len(f.Authors) + len(f.Kinds)
+		for _, values := range f.Tags {
+			n += len(values)
+		}
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package relay
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// queryNewest returns the newest events matching f, at most f.Limit, the
+// way storage answers one filter.
+func queryNewest(events []nostr.Event, f nostr.Filter) []nostr.Event {
+	var matched []nostr.Event
+	for _, evt := range events {
+		if f.Matches(&evt) {
+			matched = append(matched, evt)
+		}
+	}
+	slices.SortFunc(matched, func(a, b nostr.Event) int { return int(b.CreatedAt - a.CreatedAt) })
+	return matched[:min(len(matched), f.Limit)]
+}
+
+// TestMergeFiltersKeepsLimitsPerFilter checks that filters with their own
+// limits are not folded, so a busy author cannot take the results of a
+// quiet one.
+func TestMergeFiltersKeepsLimitsPerFilter(t *testing.T) {
+	const maxLimit = 500
+	busy := strings.Repeat("aa", 32)
+	quiet := strings.Repeat("bb", 32)
+
+	// The busy author posted 30 events, all newer than the quiet author's 10
+	var events []nostr.Event
+	for i := 0; i < 30; i++ {
+		events = append(events, nostr.Event{PubKey: busy, Kind: 1, CreatedAt: nostr.Timestamp(1000 + i)})
+	}
+	for i := 0; i < 10; i++ {
+		events = append(events, nostr.Event{PubKey: quiet, Kind: 1, CreatedAt: nostr.Timestamp(100 + i)})
+	}
+
+	merged := mergeFilters([]nostr.Filter{
+		{Authors: []string{busy}, Kinds: []int{1}, Limit: 10},
+		{Authors: []string{quiet}, Kinds: []int{1}, Limit: 10},
+	}, maxLimit)
+	if len(merged) != 2 {
+		t.Fatalf("merged into %d filters, want 2", len(merged))
+	}
+
+	got := map[string]int{}
+	for _, f := range merged {
+		for _, evt := range queryNewest(events, f) {
+			got[evt.PubKey]++
+		}
+	}
+	if got[busy] != 10 || got[quiet] != 10 {
+		t.Errorf("results per author = busy %d, quiet %d, want 10 each", got[busy], got[quiet])
+	}
+}
+
+func TestMergeFiltersFoldsDefaultLimits(t *testing.T) {
+	const maxLimit = 500
+	a := strings.Repeat("aa", 32)
+	b := strings.Repeat("bb", 32)
+
+	merged := mergeFilters([]nostr.Filter{
+		{Authors: []string{b}, Kinds: []int{1}, Limit: maxLimit},
+		{Authors: []string{a}, Kinds: []int{1}, Limit: maxLimit},
+		{Authors: []string{a}, Kinds: []int{1}, Limit: maxLimit},
+		{Authors: []string{a}, Kinds: []int{7}, Limit: 20},
+	}, maxLimit)
+	if len(merged) != 2 {
+		t.Fatalf("merged into %d filters, want 2: %v", len(merged), merged)
+	}
+	if !slices.Equal(merged[0].Authors, []string{a, b}) || merged[0].Limit != maxLimit {
+		t.Errorf("folded filter = %v, want authors [a b] with limit %d", merged[0], maxLimit)
+	}
+	if merged[1].Limit != 20 {
+		t.Errorf("explicit limit = %d, want 20", merged[1].Limit)
+	}
+}
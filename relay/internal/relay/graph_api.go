@@ -0,0 +1,107 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Follow graph API: followers and follows of a pubkey from the stored follow
+// lists (kind 3), served with the HTTP query API.
+//
+//	GET /api/graph/followers?pubkey=<hex|npub>&limit=100&after=<pubkey>
+//	GET /api/graph/following?pubkey=<hex|npub>&limit=100&after=<pubkey>
+//
+// Pubkeys are listed in hex order; when a page is full, "next" holds the URL
+// of the following page.
+
+const (
+	graphDefaultLimit = 100
+	graphMaxLimit     = 1000
+)
+
+// graphAPIResponse is the body of /api/graph/followers and /api/graph/following.
+type graphAPIResponse struct {
+	Pubkey    string   `json:"pubkey"`
+	Followers int      `json:"followers"`
+	Following int      `json:"following"`
+	Pubkeys   []string `json:"pubkeys"`
+	Count     int      `json:"count"`
+	Next      string   `json:"next,omitempty"`
+}
+
+// handleGraphAPI serves GET /api/graph/followers and /api/graph/following.
+func (s *Server) handleGraphAPI(w http.ResponseWriter, r *http.Request) {
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled) {
+		return
+	}
+	query := r.URL.Query()
+	pubkey, errMsg := parsePubkeyParam(query.Get("pubkey"))
+	if errMsg != "" {
+		writeEventsAPIError(w, r, "INVALID_PUBKEY", errMsg)
+		return
+	}
+	limit := graphDefaultLimit
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > graphMaxLimit {
+			writeEventsAPIError(w, r, "INVALID_LIMIT", "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+	after := strings.ToLower(query.Get("after"))
+	if after != "" && !nostr.IsValid32ByteHex(after) {
+		writeEventsAPIError(w, r, "INVALID_CURSOR", "after must be a 64 character hex pubkey")
+		return
+	}
+
+	release, ok := s.acquireEventsAPISlot(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(eventsAPIContext(r), eventsAPIQueryTimeout)
+	defer cancel()
+
+	db := s.node.DB()
+	list := db.Followers
+	if strings.HasSuffix(r.URL.Path, "/following") {
+		list = db.Following
+	}
+	pubkeys, err := list(ctx, pubkey, after, limit)
+	if err != nil {
+		logger.Warn("Follow graph query failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("follow graph query", err))
+		return
+	}
+	followers, following, err := db.FollowCounts(ctx, pubkey)
+	if err != nil {
+		logger.Warn("Follow graph count failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("follow graph query", err))
+		return
+	}
+
+	response := graphAPIResponse{
+		Pubkey:    pubkey,
+		Followers: followers,
+		Following: following,
+		Pubkeys:   pubkeys,
+		Count:     len(pubkeys),
+	}
+	if response.Pubkeys == nil {
+		response.Pubkeys = []string{}
+	}
+	if len(pubkeys) == limit {
+		// The next page starts after the last pubkey of this one
+		query.Set("after", pubkeys[len(pubkeys)-1])
+		response.Next = r.URL.Path + "?" + query.Encode()
+	}
+	writeEventsAPIJSON(w, response)
+}
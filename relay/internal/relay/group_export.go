@@ -0,0 +1,207 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// NIP-29 group export: group admins download the whole history of a group,
+// for backups and for moving the group to another relay.
+//
+//	GET /api/groups/<group>/export[?cursor=<created_at>:<id>]
+//
+// The request is authenticated with a NIP-98 Authorization header signed by
+// a group admin or a relay admin. The response is JSON Lines: the group's
+// relay-signed metadata (kinds 39000-39003), then every event tagged with the
+// group, oldest first, then a groupExportEnd line. It is streamed from
+// storage page by page; an export that ends without a complete end line was
+// cut short and resumes with its next_cursor, or the cursor of the last
+// event received.
+
+const (
+	groupExportPrefix = "/api/groups/"
+	groupExportSuffix = "/export"
+	// groupExportPageSize is how many events are read from storage at a time.
+	groupExportPageSize = 500
+)
+
+// groupExportEnd is the last line of an export.
+type groupExportEnd struct {
+	Export     string `json:"export"` // "complete" or "incomplete"
+	Events     int    `json:"events"`
+	NextCursor string `json:"next_cursor,omitempty"` // where an incomplete export resumes
+	Error      string `json:"error,omitempty"`
+}
+
+// isGroupExportPath reports whether path is a group export. Exports carry
+// their own NIP-98 authentication, so they skip the dashboard login.
+func isGroupExportPath(path string) bool {
+	return strings.HasPrefix(path, groupExportPrefix) && strings.HasSuffix(path, groupExportSuffix)
+}
+
+// handleGroupExport serves GET /api/groups/<group>/export.
+func (s *Server) handleGroupExport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodGet {
+		errors.HandleHTTPError(w, r, errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed."))
+		return
+	}
+
+	groupID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, groupExportPrefix), groupExportSuffix)
+	gs := GetGroupStore()
+	if gs == nil || !isValidGroupID(groupID) || gs.GetGroup(groupID) == nil {
+		errors.HandleHTTPError(w, r, errors.NotFoundError(r.URL.Path).
+			WithUserMessage("Group not found."))
+		return
+	}
+
+	pubkey, err := nips.ValidateHTTPAuth(r.Header.Get("Authorization"), s.publicBaseURL(r)+r.URL.RequestURI(), http.MethodGet, nil)
+	if err != nil {
+		errors.HandleHTTPError(w, r, errors.AuthenticationError(err.Error()).
+			WithUserMessage("Sign the request with NIP-98 as a group admin."))
+		return
+	}
+	if !gs.IsAdmin(groupID, pubkey) && !s.isAdmin(pubkey) {
+		errors.HandleHTTPError(w, r, errors.AuthorizationError("group export",
+			"only group admins may export a group").
+			WithUserMessage("Only group admins may export a group."))
+		return
+	}
+
+	var cursor storage.PageCursor
+	if raw := r.URL.Query().Get("cursor"); raw != "" {
+		if cursor, err = storage.ParsePageCursor(raw); err != nil {
+			writeEventsAPIError(w, r, "INVALID_CURSOR", err.Error())
+			return
+		}
+	}
+
+	release, ok := s.acquireEventsAPISlot(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Large histories outlive the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Debug("Could not clear export write deadline", zap.Error(err))
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+groupID+`.jsonl"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	log := logger.New("nip29")
+	n, next, err := s.streamGroupExport(eventsAPIContext(r), w, gs, groupID, pubkey, cursor)
+	if err != nil {
+		// The status is already sent, so the end line tells the client where to resume
+		end := groupExportEnd{Export: "incomplete", Events: n, Error: "export interrupted"}
+		if next != (storage.PageCursor{}) {
+			end.NextCursor = next.String()
+		}
+		_ = json.NewEncoder(w).Encode(end)
+		log.Warn("Group export interrupted",
+			zap.String("group", groupID),
+			zap.Int("events", n),
+			zap.Error(err))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(groupExportEnd{Export: "complete", Events: n}); err != nil {
+		log.Warn("Group export interrupted",
+			zap.String("group", groupID),
+			zap.Int("events", n),
+			zap.Error(err))
+		return
+	}
+	log.Info("Group exported",
+		zap.String("group", groupID),
+		zap.String("admin", pubkey),
+		zap.Int("events", n))
+}
+
+// streamGroupExport writes the group's metadata, unless resuming after
+// cursor, then its events from cursor on, one JSON object per line. It
+// returns the number of events written and the cursor the export resumes
+// from should it fail.
+func (s *Server) streamGroupExport(ctx context.Context, w http.ResponseWriter, gs *GroupStore, groupID, pubkey string, cursor storage.PageCursor) (int, storage.PageCursor, error) {
+	db := s.node.DB()
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	written := 0
+
+	write := func(events []nostr.Event) error {
+		for i := range events {
+			if !gs.CanReadEvent(&events[i], pubkey) {
+				continue
+			}
+			if err := enc.Encode(events[i]); err != nil {
+				return err
+			}
+			written++
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	if cursor == (storage.PageCursor{}) {
+		metadata, err := db.GetEvents(ctx, nostr.Filter{
+			Kinds:   []int{39000, 39001, 39002, 39003},
+			Authors: []string{gs.GetRelayPubkey()},
+			Tags:    nostr.TagMap{"d": []string{groupID}},
+		})
+		if err != nil {
+			return written, cursor, err
+		}
+		if err := write(metadata); err != nil {
+			return written, cursor, err
+		}
+	}
+
+	// A since-only filter pages oldest first
+	since := nostr.Timestamp(0)
+	f := nostr.Filter{
+		Tags:  nostr.TagMap{"h": []string{groupID}},
+		Since: &since,
+		Limit: groupExportPageSize,
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, cursor, err
+		}
+		queryCtx, cancel := context.WithTimeout(storage.WithPageCursor(ctx, cursor), eventsAPIQueryTimeout)
+		events, err := db.GetEvents(queryCtx, f)
+		cancel()
+		if err != nil {
+			return written, cursor, err
+		}
+		if err := write(events); err != nil {
+			return written, cursor, err
+		}
+		if len(events) < groupExportPageSize {
+			return written, cursor, nil
+		}
+		cursor = storage.CursorAfter(events[len(events)-1])
+	}
+}
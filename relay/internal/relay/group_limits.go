@@ -0,0 +1,204 @@
+package relay
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-29 group limits: per-group capacity and posting policy, set by group
+// admins with kind 9007 (create-group) or 9002 (edit-metadata) tags and
+// published in the group's relay-signed kind 39000 metadata:
+//
+//	["max_members", "<n>"]     members beyond which joins are refused
+//	["rate_limit", "<n>"]      events per minute accepted across the group
+//	["max_length", "<n>"]      characters of content per event
+//	["slow_mode", "<seconds>"] minimum time between two posts of a member
+//
+// A value of 0 lifts the limit. Group admins and the relay are not held to
+// the posting limits.
+
+const (
+	tagMaxMembers = "max_members"
+	tagRateLimit  = "rate_limit"
+	tagMaxLength  = "max_length"
+	tagSlowMode   = "slow_mode"
+)
+
+// GroupLimits is the capacity and posting policy of a group. Zero values are unlimited.
+type GroupLimits struct {
+	MaxMembers      int
+	EventsPerMinute int
+	MaxLength       int           // characters of content
+	SlowMode        time.Duration // per member
+}
+
+// groupPosts is the posting activity a group's rate limit and slow mode are checked against.
+type groupPosts struct {
+	windowStart time.Time
+	count       int                  // posts since windowStart
+	last        map[string]time.Time // pubkey -> last post
+}
+
+// isGroupLimitTag reports whether name is one of the group limit tags.
+func isGroupLimitTag(name string) bool {
+	switch name {
+	case tagMaxMembers, tagRateLimit, tagMaxLength, tagSlowMode:
+		return true
+	}
+	return false
+}
+
+// applyGroupLimitTag sets the limit a group limit tag names. It fails when
+// the value is not a non-negative integer, leaving limits unchanged.
+func applyGroupLimitTag(limits *GroupLimits, tag nostr.Tag) error {
+	if len(tag) < 2 {
+		return fmt.Errorf("missing %s value", tag[0])
+	}
+	n, err := strconv.Atoi(tag[1])
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid %s value (must be a non-negative integer)", tag[0])
+	}
+	switch tag[0] {
+	case tagMaxMembers:
+		limits.MaxMembers = n
+	case tagRateLimit:
+		limits.EventsPerMinute = n
+	case tagMaxLength:
+		limits.MaxLength = n
+	case tagSlowMode:
+		limits.SlowMode = time.Duration(n) * time.Second
+	}
+	return nil
+}
+
+// tags returns the kind 39000 tags of the limits that are set.
+func (l GroupLimits) tags() nostr.Tags {
+	var tags nostr.Tags
+	if l.MaxMembers > 0 {
+		tags = append(tags, nostr.Tag{tagMaxMembers, strconv.Itoa(l.MaxMembers)})
+	}
+	if l.EventsPerMinute > 0 {
+		tags = append(tags, nostr.Tag{tagRateLimit, strconv.Itoa(l.EventsPerMinute)})
+	}
+	if l.MaxLength > 0 {
+		tags = append(tags, nostr.Tag{tagMaxLength, strconv.Itoa(l.MaxLength)})
+	}
+	if l.SlowMode > 0 {
+		tags = append(tags, nostr.Tag{tagSlowMode, strconv.Itoa(int(l.SlowMode / time.Second))})
+	}
+	return tags
+}
+
+// isGroupPost reports whether an event is a post to a group rather than a
+// moderation event, a join or leave request or relay metadata.
+func isGroupPost(evt *nostr.Event) bool {
+	switch {
+	case evt.Kind >= 9000 && evt.Kind <= 9009,
+		evt.Kind == 9021, evt.Kind == 9022,
+		evt.Kind >= 39000 && evt.Kind <= 39003:
+		return false
+	}
+	return true
+}
+
+// validateLimits checks an event against the group limits: limit tags must
+// be well formed, joins and added members must fit in the group, and posts
+// must respect the length, rate and slow mode limits. group may be nil.
+func (gs *GroupStore) validateLimits(evt *nostr.Event, group *Group) (bool, string) {
+	if evt.Kind == 9007 || evt.Kind == 9002 {
+		var limits GroupLimits
+		for _, tag := range evt.Tags {
+			if len(tag) >= 1 && isGroupLimitTag(tag[0]) {
+				if err := applyGroupLimitTag(&limits, tag); err != nil {
+					return false, err.Error()
+				}
+			}
+		}
+		return true, ""
+	}
+	if group == nil {
+		return true, ""
+	}
+
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	limits := group.Limits
+	switch {
+	case evt.Kind == 9000 || evt.Kind == 9021:
+		if limits.MaxMembers == 0 {
+			return true, ""
+		}
+		joining := 0
+		if evt.Kind == 9021 {
+			if !group.Members[evt.PubKey] {
+				joining = 1
+			}
+		} else {
+			for _, tag := range evt.Tags {
+				if len(tag) >= 2 && tag[0] == "p" && !group.Members[tag[1]] {
+					joining++
+				}
+			}
+		}
+		if joining > 0 && len(group.Members)+joining > limits.MaxMembers {
+			return false, fmt.Sprintf("group is full (max %d members)", limits.MaxMembers)
+		}
+		return true, ""
+	case !isGroupPost(evt):
+		return true, ""
+	}
+
+	if _, isAdmin := group.Admins[evt.PubKey]; isAdmin || gs.isRelayKeyLocked(evt.PubKey) {
+		return true, ""
+	}
+	if limits.MaxLength > 0 && utf8.RuneCountInString(evt.Content) > limits.MaxLength {
+		return false, fmt.Sprintf("message is too long (max %d characters)", limits.MaxLength)
+	}
+	now := time.Now()
+	if limits.EventsPerMinute > 0 && now.Sub(group.posts.windowStart) < time.Minute &&
+		group.posts.count >= limits.EventsPerMinute {
+		return false, fmt.Sprintf("rate-limited: this group accepts %d events per minute", limits.EventsPerMinute)
+	}
+	if limits.SlowMode > 0 {
+		if last, ok := group.posts.last[evt.PubKey]; ok && now.Sub(last) < limits.SlowMode {
+			wait := (limits.SlowMode - now.Sub(last) + time.Second - 1) / time.Second
+			return false, fmt.Sprintf("rate-limited: slow mode is on, wait %ds before posting again", wait)
+		}
+	}
+	return true, ""
+}
+
+// recordPost counts an accepted post against its group's rate limit and slow mode.
+func (gs *GroupStore) recordPost(evt *nostr.Event, groupID string) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	group := gs.groups[groupID]
+	if group == nil || (group.Limits.EventsPerMinute == 0 && group.Limits.SlowMode == 0) {
+		return
+	}
+	now := time.Now()
+	posts := &group.posts
+	if now.Sub(posts.windowStart) >= time.Minute {
+		posts.windowStart = now
+		posts.count = 0
+		// Forget posters whose slow mode wait is over
+		for pubkey, last := range posts.last {
+			if now.Sub(last) >= group.Limits.SlowMode {
+				delete(posts.last, pubkey)
+			}
+		}
+	}
+	posts.count++
+	if group.Limits.SlowMode > 0 {
+		if posts.last == nil {
+			posts.last = make(map[string]time.Time)
+		}
+		posts.last[evt.PubKey] = now
+	}
+}
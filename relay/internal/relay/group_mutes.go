@@ -0,0 +1,178 @@
+package relay
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/domain"
+	"github.com/Shugur-Network/relay/internal/logger"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// NIP-29 group mutes: group admins put a member in a timeout with a kind
+// 9006 (mute-user) moderation event, which keeps the membership but refuses
+// the member's posts until the mute expires:
+//
+//	["h", "<group>"], ["p", "<pubkey>"]..., ["duration", "<seconds>"]
+//
+// A duration of 0 lifts the mute early. Muted pubkeys are listed with the
+// "muted" role in the group's kind 39001 admins event while the mute lasts.
+
+const (
+	// kindMuteUser is the moderation event kind that mutes group members.
+	kindMuteUser = 9006
+	// roleMuted is the role muted pubkeys carry in the group metadata.
+	roleMuted            = "muted"
+	roleMutedDescription = "Cannot post until the mute expires"
+	// muteExpiryInterval is how often lapsed mutes are removed from the metadata.
+	muteExpiryInterval = time.Minute
+)
+
+// validateMute checks a mute-user event: it needs a duration and at least
+// one pubkey, none of them a group admin.
+func validateMute(evt *nostr.Event, group *Group) (bool, string) {
+	tag := evt.Tags.GetFirst([]string{"duration", ""})
+	if tag == nil || len(*tag) < 2 {
+		return false, "mute-user requires a 'duration' tag"
+	}
+	if secs, err := strconv.Atoi((*tag)[1]); err != nil || secs < 0 {
+		return false, "invalid duration value (must be a non-negative integer)"
+	}
+	targets := 0
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "p" {
+			continue
+		}
+		if !nostr.IsValidPublicKey(tag[1]) {
+			return false, "invalid pubkey in 'p' tag"
+		}
+		if _, isAdmin := group.Admins[tag[1]]; isAdmin {
+			return false, "cannot mute a group admin"
+		}
+		targets++
+	}
+	if targets == 0 {
+		return false, "mute-user requires a 'p' tag"
+	}
+	return true, ""
+}
+
+// mutedUntil returns when the pubkey's mute in the group ends, or false
+// when it is not muted. Must be called with gs.mu held.
+func (group *Group) mutedUntil(pubkey string) (time.Time, bool) {
+	until, ok := group.Muted[pubkey]
+	if !ok || !time.Now().Before(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// checkMuted refuses posts from members muted in the group.
+func (gs *GroupStore) checkMuted(evt *nostr.Event, group *Group) (bool, string) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	if until, muted := group.mutedUntil(evt.PubKey); muted {
+		return false, "restricted: you are muted in this group until " + until.UTC().Format(time.RFC3339)
+	}
+	return true, ""
+}
+
+func (gs *GroupStore) handleMuteUser(evt *nostr.Event, groupID string, log *zap.Logger) []*nostr.Event {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	group := gs.groups[groupID]
+	if group == nil {
+		return nil
+	}
+
+	var duration time.Duration
+	if tag := evt.Tags.GetFirst([]string{"duration", ""}); tag != nil && len(*tag) >= 2 {
+		secs, _ := strconv.Atoi((*tag)[1])
+		duration = time.Duration(secs) * time.Second
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "p" {
+			continue
+		}
+		target := tag[1]
+		if duration == 0 {
+			delete(group.Muted, target)
+			log.Info("User unmuted in group",
+				zap.String("group", groupID),
+				zap.String("user", target[:16]+"..."))
+			continue
+		}
+		if group.Muted == nil {
+			group.Muted = make(map[string]time.Time)
+		}
+		group.Muted[target] = time.Now().Add(duration)
+		log.Info("User muted in group",
+			zap.String("group", groupID),
+			zap.String("user", target[:16]+"..."),
+			zap.Duration("duration", duration))
+	}
+
+	return gs.generateGroupMetadataLocked(group)
+}
+
+// expireMutesLocked drops the mutes that have lapsed and returns the
+// refreshed metadata of the groups they were in. Must be called with gs.mu held.
+func (gs *GroupStore) expireMutesLocked() []*nostr.Event {
+	now := time.Now()
+	var events []*nostr.Event
+	for _, group := range gs.groups {
+		lapsed := false
+		for pubkey, until := range group.Muted {
+			if !now.Before(until) {
+				delete(group.Muted, pubkey)
+				lapsed = true
+			}
+		}
+		if lapsed {
+			events = append(events, gs.generateGroupMetadataLocked(group)...)
+		}
+	}
+	return events
+}
+
+// expireGroupMutes periodically lifts lapsed mutes and publishes the groups'
+// updated metadata.
+func expireGroupMutes(ctx context.Context, node domain.NodeInterface) {
+	ticker := time.NewTicker(muteExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			gs := GetGroupStore()
+			if gs == nil {
+				continue
+			}
+			gs.mu.Lock()
+			events := gs.expireMutesLocked()
+			gs.mu.Unlock()
+			for _, evt := range events {
+				queueRelayEvent(node, evt)
+			}
+			if len(events) > 0 {
+				logger.New("nip29").Debug("Published metadata for lapsed group mutes",
+					zap.Int("events", len(events)))
+			}
+		}
+	}
+}
+
+// metadataRoles returns the roles listed for a pubkey in the 39001 admins
+// event: its admin roles, plus "muted" while it is muted.
+func metadataRoles(group *Group, pubkey string) []string {
+	roles := group.Admins[pubkey]
+	if _, muted := group.mutedUntil(pubkey); muted {
+		roles = append(append([]string(nil), roles...), roleMuted)
+	}
+	return roles
+}
@@ -0,0 +1,257 @@
+package relay
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/audit"
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/geoip"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"go.uber.org/zap"
+)
+
+// IP blocking beyond single addresses: CIDR ranges from the config and from
+// NIP-86 blockcidr, countries and autonomous systems looked up in optional
+// GeoIP databases, and escalation of IPs that keep getting rate limit bans
+// into a long, persisted block. Checked with the ban list on admission of
+// websocket connections and SSE streams.
+
+// ipBlocker holds the range, country and ASN blocks and offense history.
+type ipBlocker struct {
+	mu         sync.RWMutex
+	static     []*net.IPNet          // THROTTLING.IP_BLOCKING.CIDRS
+	ranges     map[string]*net.IPNet // NIP-86 blockcidr, by canonical CIDR
+	countries  map[string]bool
+	asns       map[uint]bool
+	countryDB  *geoip.Reader
+	asnDB      *geoip.Reader
+	escalation config.EscalationConfig
+	offenses   map[string][]time.Time // IP -> rate limit bans within the window
+}
+
+var ipBlocks = &ipBlocker{
+	ranges:   make(map[string]*net.IPNet),
+	offenses: make(map[string][]time.Time),
+}
+
+// InitIPBlocking loads the configured ranges, countries, ASNs and GeoIP
+// databases. Called from NewServer before LoadManagementState restores the
+// NIP-86 range blocks.
+func InitIPBlocking(cfg *config.Config) {
+	bc := cfg.Relay.ThrottlingConfig.IPBlocking
+	log := logger.New("ipblock")
+
+	b := &ipBlocker{
+		ranges:     make(map[string]*net.IPNet),
+		countries:  make(map[string]bool),
+		asns:       make(map[uint]bool),
+		escalation: bc.Escalation,
+		offenses:   make(map[string][]time.Time),
+	}
+	for _, cidr := range bc.CIDRs {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			log.Warn("Ignoring invalid blocked CIDR", zap.String("cidr", cidr), zap.Error(err))
+			continue
+		}
+		b.static = append(b.static, n)
+	}
+	for _, c := range bc.Countries {
+		b.countries[strings.ToUpper(strings.TrimSpace(c))] = true
+	}
+	for _, asn := range bc.ASNs {
+		b.asns[asn] = true
+	}
+
+	b.countryDB = openGeoIP(log, bc.CountryDatabase, "country")
+	if len(b.countries) > 0 && b.countryDB == nil {
+		log.Warn("Country blocks configured without a country database, ignoring them",
+			zap.Int("countries", len(b.countries)))
+	}
+	b.asnDB = openGeoIP(log, bc.ASNDatabase, "ASN")
+	if len(b.asns) > 0 && b.asnDB == nil {
+		log.Warn("ASN blocks configured without an ASN database, ignoring them",
+			zap.Int("asns", len(b.asns)))
+	}
+
+	if len(b.static) > 0 || len(b.countries) > 0 || len(b.asns) > 0 {
+		log.Info("IP blocking enabled",
+			zap.Int("cidrs", len(b.static)),
+			zap.Int("countries", len(b.countries)),
+			zap.Int("asns", len(b.asns)))
+	}
+	ipBlocks = b
+}
+
+// openGeoIP opens the GeoIP database at path, or returns nil when path is
+// empty or the file cannot be read.
+func openGeoIP(log *zap.Logger, path, kind string) *geoip.Reader {
+	if path == "" {
+		return nil
+	}
+	r, err := geoip.Open(path)
+	if err != nil {
+		log.Error("Failed to open GeoIP database",
+			zap.String("kind", kind),
+			zap.String("path", path),
+			zap.Error(err))
+		return nil
+	}
+	log.Info("Loaded GeoIP database",
+		zap.String("kind", kind),
+		zap.String("type", r.DatabaseType()))
+	return r
+}
+
+// addRange blocks a canonical CIDR range made through NIP-86.
+func (b *ipBlocker) addRange(n *net.IPNet) {
+	b.mu.Lock()
+	b.ranges[n.String()] = n
+	b.mu.Unlock()
+}
+
+// removeRange lifts a NIP-86 range block.
+func (b *ipBlocker) removeRange(cidr string) {
+	b.mu.Lock()
+	delete(b.ranges, cidr)
+	b.mu.Unlock()
+}
+
+// blockedReason returns why ip is refused by a range, country or ASN block,
+// or "" when it is not.
+func (b *ipBlocker) blockedReason(ipStr string) string {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return ""
+	}
+
+	b.mu.RLock()
+	for _, n := range b.static {
+		if n.Contains(ip) {
+			b.mu.RUnlock()
+			return "address range " + n.String()
+		}
+	}
+	var matched []string
+	for cidr, n := range b.ranges {
+		if n.Contains(ip) {
+			matched = append(matched, cidr)
+		}
+	}
+	b.mu.RUnlock()
+	if len(matched) > 0 {
+		sort.Strings(matched)
+		return "address range " + matched[0]
+	}
+
+	if b.countryDB != nil && len(b.countries) > 0 {
+		country, err := b.countryDB.Country(ip)
+		if err != nil {
+			logger.Debug("GeoIP country lookup failed", zap.String("client_ip", ipStr), zap.Error(err))
+		} else if b.countries[country] {
+			return "country " + country
+		}
+	}
+	if b.asnDB != nil && len(b.asns) > 0 {
+		asn, err := b.asnDB.ASN(ip)
+		if err != nil {
+			logger.Debug("GeoIP ASN lookup failed", zap.String("client_ip", ipStr), zap.Error(err))
+		} else if b.asns[asn] {
+			return fmt.Sprintf("AS%d", asn)
+		}
+	}
+	return ""
+}
+
+// recordOffense counts a rate limit ban of ip and reports whether it reached
+// the escalation threshold, returning the offenses within the window. The
+// history of an escalated IP starts over.
+func (b *ipBlocker) recordOffense(ip string, now time.Time) (bool, int) {
+	esc := b.escalation
+	if !esc.Enabled || esc.Threshold <= 0 {
+		return false, 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	recent := b.offenses[ip][:0]
+	for _, t := range b.offenses[ip] {
+		if esc.Window <= 0 || now.Sub(t) < esc.Window {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	count := len(recent)
+	if count >= esc.Threshold {
+		delete(b.offenses, ip)
+		return true, count
+	}
+	b.offenses[ip] = recent
+	// Forget IPs whose offenses all left the window
+	if len(b.offenses) > 10000 {
+		for other, times := range b.offenses {
+			if esc.Window > 0 && now.Sub(times[len(times)-1]) >= esc.Window {
+				delete(b.offenses, other)
+			}
+		}
+	}
+	return false, count
+}
+
+// escalateBan counts a rate limit ban of ip and, once it reaches the
+// escalation threshold, replaces it with a long IP block persisted like a
+// NIP-86 blockip so it survives restarts and can be lifted with unblockip.
+func (c *WsConnection) escalateBan(ip string) {
+	now := time.Now()
+	escalate, offenses := ipBlocks.recordOffense(ip, now)
+	if !escalate {
+		return
+	}
+
+	esc := ipBlocks.escalation
+	var expiresAt int64
+	if esc.LongBan > 0 {
+		expiresAt = now.Add(esc.LongBan).Unix()
+	}
+	reason := fmt.Sprintf("%d rate limit bans within %s", offenses, esc.Window)
+	pv, _ := c.node.GetValidator().(*PluginValidator)
+	errMsg := persistDecision(c.node.DB(), pv, storage.ModerationDecision{
+		Type:      storage.ModerationBlockIP,
+		Target:    ip,
+		Reason:    reason,
+		Actor:     audit.ActorSystem,
+		CreatedAt: now.Unix(),
+		ExpiresAt: expiresAt,
+	})
+
+	entry := audit.Entry{
+		Actor:    audit.ActorSystem,
+		Source:   "ratelimit",
+		Action:   "blockip",
+		Params:   []string{ip, esc.LongBan.String()},
+		Outcome:  audit.OutcomeSuccess,
+		Message:  reason,
+		ClientIP: ip,
+	}
+	if errMsg != "" {
+		entry.Outcome, entry.Message = audit.OutcomeFailure, errMsg
+	}
+	recordAudit(entry)
+
+	logger.Warn("Escalated repeat offender to a long IP block",
+		zap.String("client_ip", ip),
+		zap.Int("offenses", offenses),
+		zap.Duration("duration", esc.LongBan))
+}
+
+// cidrEntry is a blocked address range in a management listing.
+type cidrEntry struct {
+	CIDR string `json:"cidr"`
+	decisionInfo
+}
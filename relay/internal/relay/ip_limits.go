@@ -0,0 +1,53 @@
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+// Per-IP connection accounting shared by websocket connections and SSE
+// streams, so a client cannot get around THROTTLING.MAX_CONNECTIONS_PER_IP by
+// switching transports. Both also honour the websocket rate limit ban list.
+
+var (
+	ipConnections   = make(map[string]int)
+	ipConnectionsMu sync.Mutex
+)
+
+// acquireIPConnection counts a new connection from ip, returning false when ip
+// already holds max connections (max <= 0 = unlimited).
+func acquireIPConnection(ip string, max int) bool {
+	ipConnectionsMu.Lock()
+	defer ipConnectionsMu.Unlock()
+	if max > 0 && ipConnections[ip] >= max {
+		return false
+	}
+	ipConnections[ip]++
+	return true
+}
+
+// releaseIPConnection forgets a connection counted by acquireIPConnection.
+func releaseIPConnection(ip string) {
+	ipConnectionsMu.Lock()
+	defer ipConnectionsMu.Unlock()
+	if ipConnections[ip] <= 1 {
+		delete(ipConnections, ip)
+		return
+	}
+	ipConnections[ip]--
+}
+
+// clientBanRemaining returns how long ip stays banned for rate limit
+// violations, or zero when it is not banned.
+func clientBanRemaining(ip string) time.Duration {
+	banListMutex.Lock()
+	expiry, banned := clientBanList[ip]
+	banListMutex.Unlock()
+	if !banned {
+		return 0
+	}
+	if remaining := time.Until(expiry); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
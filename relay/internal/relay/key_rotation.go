@@ -0,0 +1,258 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/signer"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Relay key rotation: rotaterelaykey replaces the relay's signing key with a
+// freshly generated one persisted by the signer, announces the transition
+// under both keys, re-signs the group and membership metadata, and keeps
+// honouring events signed by the previous key for a grace window.
+
+// relayKeyTransitionKind announces a relay key rotation. No NIP covers it
+// yet: the previous key's event names its successor and the new key's event
+// names its predecessor, both with the end of the grace window.
+const relayKeyTransitionKind = 1776
+
+// defaultRotationGrace applies when RELAY.SIGNER.ROTATION_GRACE is unset.
+const defaultRotationGrace = 30 * 24 * time.Hour
+
+// maxKeyTransitions bounds how far back LoadKeyTransitions follows the chain
+// of previous keys.
+const maxKeyTransitions = 16
+
+// Markers of the "p" tag of a transition event.
+const (
+	transitionSuccessor   = "successor"
+	transitionPredecessor = "predecessor"
+)
+
+// keyRotation is the outcome of GroupStore.RotateKey.
+type keyRotation struct {
+	Previous   string
+	Pubkey     string
+	GraceUntil time.Time
+	Farewell   *nostr.Event   // signed by the previous key
+	Greeting   *nostr.Event   // signed by the new key
+	Metadata   []*nostr.Event // group metadata re-signed by the new key
+}
+
+// IsRelayKey reports whether pubkey is the relay's key, or a previous one
+// still within its grace window.
+func (gs *GroupStore) IsRelayKey(pubkey string) bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.isRelayKeyLocked(pubkey)
+}
+
+// isRelayKeyLocked is IsRelayKey with gs.mu held.
+func (gs *GroupStore) isRelayKeyLocked(pubkey string) bool {
+	pubkey = strings.ToLower(pubkey)
+	if pubkey != "" && pubkey == strings.ToLower(gs.relayPubkey) {
+		return true
+	}
+	until, ok := gs.retiredKeys[pubkey]
+	return ok && time.Now().Before(until)
+}
+
+// isRetiredKey reports whether pubkey was the relay's key before a rotation,
+// whether or not its grace window is over.
+func (gs *GroupStore) isRetiredKey(pubkey string) bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	_, ok := gs.retiredKeys[strings.ToLower(pubkey)]
+	return ok
+}
+
+// RotateKey replaces the relay key with a new one persisted by the signer
+// and signs the transition and the current group metadata. Events signed by
+// the previous key stay valid for grace.
+func (gs *GroupStore) RotateKey(grace time.Duration) (*keyRotation, error) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	current, ok := gs.signer.(signer.Rotator)
+	if !ok {
+		return nil, fmt.Errorf("relay key rotation needs RELAY.SIGNER.SOURCE keystore")
+	}
+	next, err := current.Rotate()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	rot := &keyRotation{
+		Previous:   current.PublicKey(),
+		Pubkey:     next.PublicKey(),
+		GraceUntil: now.Add(grace),
+	}
+	graceTag := nostr.Tag{"grace_until", strconv.FormatInt(rot.GraceUntil.Unix(), 10)}
+	log := logger.New("nip29")
+
+	// The keystore already holds the new key, so a failed announcement
+	// cannot stop the switch
+	rot.Farewell = &nostr.Event{
+		Kind:      relayKeyTransitionKind,
+		CreatedAt: nostr.Timestamp(now.Unix()),
+		Tags:      nostr.Tags{{"p", rot.Pubkey, "", transitionSuccessor}, graceTag},
+		Content:   "relay key rotated to " + rot.Pubkey,
+	}
+	if err := current.Sign(context.Background(), rot.Farewell); err != nil {
+		log.Error("Failed to sign key transition with the previous key", zap.Error(err))
+		rot.Farewell = nil
+	}
+
+	gs.signer = next
+	gs.relayPubkey = rot.Pubkey
+	gs.retiredKeys[rot.Previous] = rot.GraceUntil
+
+	greetingTags := nostr.Tags{{"p", rot.Previous, "", transitionPredecessor}, graceTag}
+	if rot.Farewell != nil {
+		greetingTags = append(greetingTags, nostr.Tag{"e", rot.Farewell.ID})
+	}
+	rot.Greeting = &nostr.Event{
+		Kind:      relayKeyTransitionKind,
+		CreatedAt: nostr.Timestamp(now.Unix()),
+		Tags:      greetingTags,
+		Content:   "relay key rotated from " + rot.Previous,
+	}
+	if err := next.Sign(context.Background(), rot.Greeting); err != nil {
+		log.Error("Failed to sign key transition with the new key", zap.Error(err))
+		rot.Greeting = nil
+	}
+
+	ids := make([]string, 0, len(gs.groups))
+	for id := range gs.groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		rot.Metadata = append(rot.Metadata, gs.generateGroupMetadataLocked(gs.groups[id])...)
+	}
+
+	log.Warn("Rotated relay key",
+		zap.String("previous_pubkey", rot.Previous),
+		zap.String("relay_pubkey", rot.Pubkey),
+		zap.Time("grace_until", rot.GraceUntil))
+	return rot, nil
+}
+
+// LoadKeyTransitions restores the previous relay keys from the transition
+// events signed by the current key and, in turn, by each previous key.
+// Called from NewServer.
+func (gs *GroupStore) LoadKeyTransitions(ctx context.Context, db *storage.DB) {
+	author := gs.GetRelayPubkey()
+	if db == nil || author == "" {
+		return
+	}
+
+	for i := 0; i < maxKeyTransitions; i++ {
+		// A key signs at most one greeting and one farewell
+		events, err := db.GetEvents(ctx, nostr.Filter{
+			Authors: []string{author},
+			Kinds:   []int{relayKeyTransitionKind},
+			Limit:   2,
+		})
+		if err != nil {
+			logger.New("nip29").Warn("Failed to load relay key transitions", zap.Error(err))
+			return
+		}
+		previous, until := "", time.Time{}
+		for j := range events {
+			if p, u, ok := parseKeyTransition(&events[j], transitionPredecessor); ok {
+				previous, until = p, u
+				break
+			}
+		}
+		if previous == "" || gs.isRetiredKey(previous) {
+			return
+		}
+
+		gs.mu.Lock()
+		gs.retiredKeys[previous] = until
+		gs.mu.Unlock()
+		logger.New("nip29").Info("Restored previous relay key",
+			zap.String("pubkey", previous),
+			zap.Time("grace_until", until))
+		author = previous
+	}
+}
+
+// parseKeyTransition returns the pubkey of the "p" tag with marker and the
+// end of the grace window of a transition event.
+func parseKeyTransition(evt *nostr.Event, marker string) (string, time.Time, bool) {
+	var pubkey string
+	var until time.Time
+	for _, tag := range evt.Tags {
+		switch {
+		case len(tag) >= 4 && tag[0] == "p" && tag[3] == marker && nostr.IsValidPublicKey(tag[1]):
+			pubkey = strings.ToLower(tag[1])
+		case len(tag) >= 2 && tag[0] == "grace_until":
+			if ts, err := strconv.ParseInt(tag[1], 10, 64); err == nil {
+				until = time.Unix(ts, 0)
+			}
+		}
+	}
+	return pubkey, until, pubkey != ""
+}
+
+// mgmtRotateRelayKey replaces the relay key and publishes the transition and
+// the re-signed metadata.
+// Params: [grace_seconds (optional, default RELAY.SIGNER.ROTATION_GRACE)]
+func (s *Server) mgmtRotateRelayKey(params []string) (interface{}, string) {
+	grace := s.fullCfg.Relay.Signer.RotationGrace
+	if grace <= 0 {
+		grace = defaultRotationGrace
+	}
+	if len(params) >= 1 && params[0] != "" {
+		secs, err := strconv.Atoi(params[0])
+		if err != nil || secs < 0 {
+			return nil, "invalid grace: must be a non-negative number of seconds"
+		}
+		grace = time.Duration(secs) * time.Second
+	}
+
+	gs := GetGroupStore()
+	if gs == nil {
+		return nil, "internal error: group store not initialized"
+	}
+	rot, err := gs.RotateKey(grace)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	var eventIDs []string
+	publish := func(evt *nostr.Event) {
+		if evt != nil && queueRelayEvent(s.node, evt) {
+			eventIDs = append(eventIDs, evt.ID)
+		}
+	}
+	// The outbox still publishes as the previous key for its farewell
+	publish(rot.Farewell)
+	if outboxInstance != nil {
+		outboxInstance.SetRelayPubkey(rot.Pubkey)
+	}
+	publish(rot.Greeting)
+	for _, evt := range rot.Metadata {
+		publish(evt)
+	}
+	publish(GetMembershipStore().createMembershipListEvent(gs))
+
+	return map[string]interface{}{
+		"pubkey":          rot.Pubkey,
+		"previous_pubkey": rot.Previous,
+		"grace_until":     rot.GraceUntil.Unix(),
+		"event_ids":       eventIDs,
+	}, ""
+}
@@ -0,0 +1,174 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/keypackages"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// KeyPackage API: GET /api/keypackages/<pubkey>[?ciphersuite=<id>&limit=<n>]
+// serves the freshest NIP-EE KeyPackages (kind 443) of a pubkey (hex, npub or
+// nprofile) from the KeyPackage index, newest first, one unless limit asks
+// for more. The index refuses republished copies of a stored package and
+// deletes the oldest packages beyond KEY_PACKAGES.MAX_PER_PUBKEY and those
+// older than KEY_PACKAGES.MAX_AGE.
+
+const (
+	// keyPackageExpiryInterval is how often expired KeyPackages are deleted.
+	keyPackageExpiryInterval = time.Hour
+	// keyPackageLoadPageSize is how many KeyPackages are read at a time on startup.
+	keyPackageLoadPageSize = 500
+	// maxKeyPackageLookup bounds the limit of one lookup.
+	maxKeyPackageLookup = 20
+)
+
+// keyPackagesInstance is the package-level KeyPackage index (nil when disabled).
+var keyPackagesInstance *keypackages.Index
+
+// GetKeyPackages returns the KeyPackage index, or nil when it is disabled.
+func GetKeyPackages() *keypackages.Index {
+	return keyPackagesInstance
+}
+
+// InitKeyPackages creates the KeyPackage index and keeps it in step with
+// newly stored events. The stored packages are loaded by startKeyPackages.
+// Called from NewServer.
+func InitKeyPackages(cfg *config.Config, db *storage.DB) *keypackages.Index {
+	if !cfg.KeyPackages.Enabled || db == nil {
+		keyPackagesInstance = nil
+		return nil
+	}
+	index := keypackages.New(cfg.KeyPackages)
+	db.SetKeyPackageObserver(func(evt *nostr.Event) {
+		if superseded := index.Observe(evt); len(superseded) > 0 {
+			go removeKeyPackages(context.Background(), db, superseded, "superseded")
+		}
+	})
+	keyPackagesInstance = index
+	return index
+}
+
+// startKeyPackages loads the stored KeyPackages into the index, then
+// periodically deletes the expired ones. Called from ListenAndServe.
+func startKeyPackages(ctx context.Context, db *storage.DB) {
+	index := GetKeyPackages()
+	if index == nil {
+		return
+	}
+	go func() {
+		loadKeyPackages(ctx, db, index)
+
+		ticker := time.NewTicker(keyPackageExpiryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if expired := index.Expire(now); len(expired) > 0 {
+					removeKeyPackages(ctx, db, expired, "expired")
+				}
+			}
+		}
+	}()
+}
+
+// loadKeyPackages indexes the stored KeyPackages, oldest first, deleting
+// those the index supersedes or finds expired.
+func loadKeyPackages(ctx context.Context, db *storage.DB, index *keypackages.Index) {
+	log := logger.New("keypackages")
+
+	// A since-only filter pages oldest first
+	since := nostr.Timestamp(0)
+	f := nostr.Filter{Kinds: []int{keypackages.KindKeyPackage}, Since: &since, Limit: keyPackageLoadPageSize}
+	var cursor storage.PageCursor
+	for {
+		events, err := db.GetEvents(storage.WithPageCursor(ctx, cursor), f)
+		if err != nil {
+			log.Warn("Failed to load KeyPackages", zap.Error(err))
+			return
+		}
+		for i := range events {
+			evt := events[i]
+			if superseded := index.Add(&evt); len(superseded) > 0 {
+				removeKeyPackages(ctx, db, superseded, "superseded")
+			}
+		}
+		if len(events) < keyPackageLoadPageSize {
+			break
+		}
+		cursor = storage.CursorAfter(events[len(events)-1])
+	}
+	if expired := index.Expire(time.Now()); len(expired) > 0 {
+		removeKeyPackages(ctx, db, expired, "expired")
+	}
+	log.Info("Indexed NIP-EE KeyPackages", zap.Int("packages", index.Len()))
+}
+
+// removeKeyPackages deletes KeyPackages the index dropped from storage.
+func removeKeyPackages(ctx context.Context, db *storage.DB, events []*nostr.Event, reason string) {
+	for _, evt := range events {
+		if err := db.RemoveEvent(ctx, evt); err != nil {
+			logger.New("keypackages").Warn("Failed to delete KeyPackage",
+				zap.String("event_id", evt.ID),
+				zap.String("reason", reason),
+				zap.Error(err))
+			continue
+		}
+		metrics.KeyPackagesDropped.WithLabelValues(reason).Inc()
+	}
+}
+
+// keyPackagesAPIResponse is the body of /api/keypackages/<pubkey>.
+type keyPackagesAPIResponse struct {
+	Pubkey      string         `json:"pubkey"`
+	KeyPackages []*nostr.Event `json:"key_packages"`
+	Count       int            `json:"count"`
+}
+
+// handleKeyPackagesAPI serves GET /api/keypackages/<pubkey>.
+func (s *Server) handleKeyPackagesAPI(w http.ResponseWriter, r *http.Request) {
+	index := GetKeyPackages()
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && index != nil) {
+		return
+	}
+	pubkey, errMsg := parsePubkeyParam(strings.TrimPrefix(r.URL.Path, "/api/keypackages/"))
+	if errMsg != "" {
+		writeEventsAPIError(w, r, "INVALID_PUBKEY", errMsg)
+		return
+	}
+	query := r.URL.Query()
+	limit := 1
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxKeyPackageLookup {
+			writeEventsAPIError(w, r, "INVALID_LIMIT", "limit must be between 1 and "+strconv.Itoa(maxKeyPackageLookup))
+			return
+		}
+		limit = n
+	}
+
+	db := s.node.DB()
+	var packages []*nostr.Event
+	for _, evt := range index.Lookup(pubkey, query.Get("ciphersuite"), limit) {
+		if visibleAnonymously(db, evt) {
+			packages = append(packages, evt)
+		}
+	}
+	if len(packages) == 0 {
+		errors.HandleHTTPError(w, r, errors.NotFoundError("key package"))
+		return
+	}
+	writeEventsAPIJSON(w, keyPackagesAPIResponse{Pubkey: pubkey, KeyPackages: packages, Count: len(packages)})
+}
@@ -0,0 +1,142 @@
+package relay
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	"go.uber.org/zap"
+)
+
+// KindRange is an inclusive range of event kinds.
+type KindRange struct {
+	From, To int
+}
+
+func (r KindRange) contains(kind int) bool {
+	return kind >= r.From && kind <= r.To
+}
+
+// KindPolicy decides which event kinds the relay accepts. Exact kinds take
+// precedence over ranges and exclusions over allowances at the same level,
+// so ALLOW ["1000-1999"] with DENY ["1500"] refuses only kind 1500, while
+// ALLOW ["7"] with DENY ["0-99"] still accepts kind 7. It is not safe for
+// concurrent use; PluginValidator guards it with its mutex.
+type KindPolicy struct {
+	allowed       map[int]bool
+	allowedRanges []KindRange
+	denied        map[int]bool
+	deniedRanges  []KindRange
+}
+
+// NewKindPolicy builds the policy from configuration. An empty allow list
+// selects the built-in profile, the kinds of every registered NIP.
+func NewKindPolicy(cfg config.KindsPolicyConfig) *KindPolicy {
+	p := &KindPolicy{allowed: make(map[int]bool), denied: make(map[int]bool)}
+	allow := cfg.Allow
+	if len(allow) == 0 {
+		allow = []string{config.KindSpecDefault}
+	}
+	addKindSpecs(allow, p.allowed, &p.allowedRanges)
+	addKindSpecs(cfg.Deny, p.denied, &p.deniedRanges)
+	return p
+}
+
+// addKindSpecs adds configured kind specs to an exact set and a range list.
+func addKindSpecs(specs []string, kinds map[int]bool, ranges *[]KindRange) {
+	for _, spec := range specs {
+		if strings.TrimSpace(spec) == config.KindSpecDefault {
+			defaultKinds, defaultRanges := registry.DefaultKinds()
+			for k := range defaultKinds {
+				kinds[k] = true
+			}
+			for _, r := range defaultRanges {
+				*ranges = append(*ranges, KindRange{From: r.From, To: r.To})
+			}
+			continue
+		}
+		from, to, err := config.ParseKindSpec(spec)
+		if err != nil {
+			logger.Warn("Ignoring invalid kind in kinds policy", zap.String("kind", spec), zap.Error(err))
+			continue
+		}
+		if from == to {
+			kinds[from] = true
+		} else {
+			*ranges = append(*ranges, KindRange{From: from, To: to})
+		}
+	}
+}
+
+// Allows reports whether events of kind are accepted. Kinds of a NIP
+// switched off in NIPS.DISABLED are refused whatever the lists say.
+func (p *KindPolicy) Allows(kind int) bool {
+	if p.denied[kind] || registry.DisabledBy(kind) != "" {
+		return false
+	}
+	if p.allowed[kind] {
+		return true
+	}
+	return !inKindRanges(p.deniedRanges, kind) && inKindRanges(p.allowedRanges, kind)
+}
+
+// Allow accepts kind, overriding any exclusion.
+func (p *KindPolicy) Allow(kind int) {
+	delete(p.denied, kind)
+	p.allowed[kind] = true
+}
+
+// Disallow refuses kind, even inside an allowed range.
+func (p *KindPolicy) Disallow(kind int) {
+	delete(p.allowed, kind)
+	if p.Allows(kind) {
+		p.denied[kind] = true
+	}
+}
+
+// Kinds returns every accepted kind in ascending order.
+func (p *KindPolicy) Kinds() []int {
+	kinds := make([]int, 0, len(p.allowed))
+	for k := 0; k <= config.MaxEventKind; k++ {
+		if p.Allows(k) {
+			kinds = append(kinds, k)
+		}
+	}
+	return kinds
+}
+
+// Ranges returns the accepted kinds in NIP-11 notation: single kinds as
+// numbers and runs of consecutive kinds as [from, to] pairs.
+func (p *KindPolicy) Ranges() []interface{} {
+	return kindRuns(p.Kinds())
+}
+
+// kindRuns compresses sorted kinds into NIP-11 notation.
+func kindRuns(kinds []int) []interface{} {
+	sort.Ints(kinds)
+	runs := make([]interface{}, 0)
+	for i := 0; i < len(kinds); {
+		j := i
+		for j+1 < len(kinds) && kinds[j+1] == kinds[j]+1 {
+			j++
+		}
+		if j == i {
+			runs = append(runs, kinds[i])
+		} else {
+			runs = append(runs, [2]int{kinds[i], kinds[j]})
+		}
+		i = j + 1
+	}
+	return runs
+}
+
+func inKindRanges(ranges []KindRange, kind int) bool {
+	for _, r := range ranges {
+		if r.contains(kind) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,390 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Labels API: NIP-32 labels (kind 1985) from the label index, with the
+// labeled events.
+//
+//	GET /api/labels?namespace=ugc&label=spam[&labeler=<pubkey>,...&target_type=e&target=&until=&limit=100]
+//
+// Labels are listed newest first, one entry per label and target; entries
+// labeling an event carry it. Without labeler, only the labels of the
+// trusted labelers (LABELS.TRUSTED_LABELERS) count, or everyone's when none
+// are configured. until resumes a listing at the created_at of its last entry.
+//
+// With LABELS.FILTER_EXTENSION, the same lookup is a REQ filter extension:
+//
+//	["REQ", "<sub>", {"label": {"namespace": "ugc", "label": "spam", "labelers": ["<pubkey>"]}}]
+//	["REQ", "<sub>", {"label": {"label": "spam", "target": "p"}, "kinds": [1]}]
+//
+// The relay turns "label" into the ids of the labeled events, or with
+// "target": "p" the authors of the labeled pubkeys, as they are when the
+// filter arrives.
+
+const (
+	labelsDefaultLimit = 100
+	labelsMaxLimit     = 1000
+	// maxLabelFilterValues bounds the ids or authors a label filter expands to.
+	maxLabelFilterValues = 500
+	// labelFilterTimeout bounds the label lookup of a REQ filter.
+	labelFilterTimeout = 3 * time.Second
+	// labelWithholdRefreshInterval is how often the withheld events and
+	// pubkeys are reread, dropping those whose labels were deleted.
+	labelWithholdRefreshInterval = 5 * time.Minute
+	// maxWithheldPerLabel bounds the targets read per withheld label.
+	maxWithheldPerLabel = 100000
+)
+
+// noMatchID is an event ID no event has, selecting nothing when a label
+// filter matches no event.
+var noMatchID = strings.Repeat("0", 64)
+
+// labels holds the label settings and the events and pubkeys withheld by
+// label.
+type labels struct {
+	cfg      config.LabelsConfig
+	db       *storage.DB
+	withhold map[string]bool // "<namespace>/<label>"
+
+	mu      sync.RWMutex
+	events  map[string]bool
+	pubkeys map[string]bool
+}
+
+// labelsInstance is the package-level label state (nil when disabled).
+var labelsInstance *labels
+
+// InitLabels enables the labels API and filter extension and, when labels
+// are withheld, keeps the withheld events and pubkeys in step with newly
+// stored label events. They are loaded by startLabels. Called from NewServer.
+func InitLabels(cfg *config.Config, db *storage.DB) {
+	if !cfg.Labels.Enabled || db == nil {
+		labelsInstance = nil
+		return
+	}
+	l := &labels{
+		cfg:      cfg.Labels,
+		db:       db,
+		withhold: make(map[string]bool),
+		events:   make(map[string]bool),
+		pubkeys:  make(map[string]bool),
+	}
+	l.cfg.TrustedLabelers = make([]string, len(cfg.Labels.TrustedLabelers))
+	for i, labeler := range cfg.Labels.TrustedLabelers {
+		l.cfg.TrustedLabelers[i] = strings.ToLower(labeler)
+	}
+	if len(cfg.Labels.Withhold) > 0 && len(cfg.Labels.TrustedLabelers) == 0 {
+		logger.New("labels").Warn("LABELS.WITHHOLD needs LABELS.TRUSTED_LABELERS, no events are withheld by label")
+	} else {
+		for _, label := range cfg.Labels.Withhold {
+			l.withhold[label] = true
+		}
+	}
+	if len(l.withhold) > 0 {
+		db.SetLabelObserver(l.observe)
+	}
+	labelsInstance = l
+}
+
+// startLabels loads the withheld events and pubkeys, then rereads them
+// periodically. Called from ListenAndServe.
+func startLabels(ctx context.Context) {
+	l := labelsInstance
+	if l == nil || len(l.withhold) == 0 {
+		return
+	}
+	go func() {
+		l.refresh(ctx)
+
+		ticker := time.NewTicker(labelWithholdRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				l.refresh(ctx)
+			}
+		}
+	}()
+}
+
+// refresh rereads the events and pubkeys the trusted labelers labeled with
+// a withheld label.
+func (l *labels) refresh(ctx context.Context) {
+	events := make(map[string]bool)
+	pubkeys := make(map[string]bool)
+	for label := range l.withhold {
+		namespace, value, _ := strings.Cut(label, "/")
+		for _, targetType := range []string{"e", "p"} {
+			records, err := l.db.Labels(ctx, storage.LabelQuery{
+				Namespace:  namespace,
+				Label:      value,
+				Labelers:   l.cfg.TrustedLabelers,
+				TargetType: targetType,
+				Limit:      maxWithheldPerLabel,
+			})
+			if err != nil {
+				// Keep withholding what was withheld
+				logger.New("labels").Warn("Failed to load withheld labels", zap.String("label", label), zap.Error(err))
+				return
+			}
+			for _, r := range records {
+				if r.TargetType == "e" {
+					events[r.Target] = true
+				} else {
+					pubkeys[r.Target] = true
+				}
+			}
+		}
+	}
+	l.mu.Lock()
+	l.events, l.pubkeys = events, pubkeys
+	l.mu.Unlock()
+}
+
+// observe withholds the targets of a newly stored label event of a trusted
+// labeler applying a withheld label.
+func (l *labels) observe(evt *nostr.Event) {
+	if evt.Kind != nips.KindLabel || !slices.Contains(l.cfg.TrustedLabelers, evt.PubKey) {
+		return
+	}
+	applied, targets := nips.LabelsOf(evt)
+	for _, label := range applied {
+		if !l.withhold[label.Namespace+"/"+label.Value] {
+			continue
+		}
+		l.mu.Lock()
+		for _, t := range targets {
+			switch t.Type {
+			case "e":
+				l.events[t.Value] = true
+			case "p":
+				l.pubkeys[t.Value] = true
+			}
+		}
+		l.mu.Unlock()
+		return
+	}
+}
+
+// isLabelWithheld reports whether evt, or its author, carries a withheld
+// label from a trusted labeler.
+func isLabelWithheld(evt *nostr.Event) bool {
+	l := labelsInstance
+	if l == nil || len(l.withhold) == 0 {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.events[evt.ID] || l.pubkeys[evt.PubKey]
+}
+
+// labelers returns the labelers a query counts: those requested, or else the
+// trusted ones.
+func (l *labels) labelers(requested []string) []string {
+	if len(requested) > 0 {
+		return requested
+	}
+	return l.cfg.TrustedLabelers
+}
+
+// labelFilter is the "label" REQ filter extension.
+type labelFilter struct {
+	Namespace string   `json:"namespace"`
+	Label     string   `json:"label"`
+	Labelers  []string `json:"labelers"`
+	Target    string   `json:"target"` // "e" (default) or "p"
+}
+
+// applyLabelFilter turns the "label" extension of a filter into the ids of
+// the labeled events, or the authors of the labeled pubkeys.
+func applyLabelFilter(f *nostr.Filter, raw json.RawMessage) error {
+	l := labelsInstance
+	if l == nil || !l.cfg.FilterExtension {
+		return fmt.Errorf("label filters are not supported by this relay")
+	}
+	var lf labelFilter
+	if err := json.Unmarshal(raw, &lf); err != nil {
+		return fmt.Errorf("invalid label filter: %w", err)
+	}
+	if lf.Label == "" {
+		return fmt.Errorf("label filter needs a label")
+	}
+	for i, labeler := range lf.Labelers {
+		if !nostr.IsValid32ByteHex(labeler) {
+			return fmt.Errorf("label filter labelers must be 64 character hex pubkeys")
+		}
+		lf.Labelers[i] = strings.ToLower(labeler)
+	}
+	switch lf.Target {
+	case "", "e":
+		if len(f.IDs) > 0 {
+			return fmt.Errorf("label cannot be combined with ids")
+		}
+		lf.Target = "e"
+	case "p":
+		if len(f.Authors) > 0 {
+			return fmt.Errorf("label with target p cannot be combined with authors")
+		}
+	default:
+		return fmt.Errorf("label filter target must be e or p")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), labelFilterTimeout)
+	defer cancel()
+	records, err := l.db.Labels(ctx, storage.LabelQuery{
+		Namespace:  lf.Namespace,
+		Label:      lf.Label,
+		Labelers:   l.labelers(lf.Labelers),
+		TargetType: lf.Target,
+		Limit:      maxLabelFilterValues,
+	})
+	if err != nil {
+		return fmt.Errorf("label lookup failed")
+	}
+	var values []string
+	for _, r := range records {
+		if !slices.Contains(values, r.Target) {
+			values = append(values, r.Target)
+		}
+	}
+	if len(values) == 0 {
+		values = []string{noMatchID}
+	}
+	if lf.Target == "p" {
+		f.Authors = values
+	} else {
+		f.IDs = values
+	}
+	return nil
+}
+
+// labelAPIEntry is a label of /api/labels with the event it labels.
+type labelAPIEntry struct {
+	storage.LabelRecord
+	Event *nostr.Event `json:"event,omitempty"`
+}
+
+// labelsAPIResponse is the body of /api/labels.
+type labelsAPIResponse struct {
+	Labels []labelAPIEntry `json:"labels"`
+	Count  int             `json:"count"`
+}
+
+// handleLabelsAPI serves GET /api/labels.
+func (s *Server) handleLabelsAPI(w http.ResponseWriter, r *http.Request) {
+	l := labelsInstance
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && l != nil) {
+		return
+	}
+	query := r.URL.Query()
+	q := storage.LabelQuery{
+		Namespace: query.Get("namespace"),
+		Label:     query.Get("label"),
+		Target:    query.Get("target"),
+		Limit:     labelsDefaultLimit,
+	}
+	if raw := query.Get("labeler"); raw != "" {
+		for _, param := range strings.Split(raw, ",") {
+			pubkey, errMsg := parsePubkeyParam(param)
+			if errMsg != "" {
+				writeEventsAPIError(w, r, "INVALID_PUBKEY", errMsg)
+				return
+			}
+			q.Labelers = append(q.Labelers, pubkey)
+		}
+	}
+	q.Labelers = l.labelers(q.Labelers)
+	if q.TargetType = query.Get("target_type"); q.TargetType != "" && !slices.Contains(nips.LabelTargetTypes, q.TargetType) {
+		writeEventsAPIError(w, r, "INVALID_TARGET", "target_type must be one of e, p, a, r or t")
+		return
+	}
+	if q.Target != "" && q.TargetType == "" {
+		writeEventsAPIError(w, r, "INVALID_TARGET", "target needs target_type")
+		return
+	}
+	if raw := query.Get("until"); raw != "" {
+		until, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || until < 0 {
+			writeEventsAPIError(w, r, "INVALID_UNTIL", "until must be a unix timestamp")
+			return
+		}
+		q.Until = until
+	}
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > labelsMaxLimit {
+			writeEventsAPIError(w, r, "INVALID_LIMIT", "limit must be between 1 and "+strconv.Itoa(labelsMaxLimit))
+			return
+		}
+		q.Limit = n
+	}
+
+	release, ok := s.acquireEventsAPISlot(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(eventsAPIContext(r), eventsAPIQueryTimeout)
+	defer cancel()
+
+	db := s.node.DB()
+	records, err := db.Labels(ctx, q)
+	if err != nil {
+		logger.Warn("Label query failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("label query", err))
+		return
+	}
+
+	// Attach the labeled events
+	var ids []string
+	for _, rec := range records {
+		if rec.TargetType == "e" && nostr.IsValid32ByteHex(rec.Target) && !slices.Contains(ids, rec.Target) {
+			ids = append(ids, rec.Target)
+		}
+	}
+	labeled := make(map[string]*nostr.Event, len(ids))
+	if len(ids) > 0 {
+		events, err := db.GetEvents(ctx, nostr.Filter{IDs: ids, Limit: len(ids)})
+		if err != nil {
+			logger.Warn("Labeled events query failed", zap.Error(err))
+			errors.HandleHTTPError(w, r, errors.HandleDatabaseError("label query", err))
+			return
+		}
+		for i := range events {
+			if visibleAnonymously(db, &events[i]) {
+				labeled[events[i].ID] = &events[i]
+			}
+		}
+	}
+
+	response := labelsAPIResponse{Labels: make([]labelAPIEntry, 0, len(records))}
+	for _, rec := range records {
+		entry := labelAPIEntry{LabelRecord: rec}
+		if rec.TargetType == "e" {
+			entry.Event = labeled[rec.Target]
+		}
+		response.Labels = append(response.Labels, entry)
+	}
+	response.Count = len(response.Labels)
+	writeEventsAPIJSON(w, response)
+}
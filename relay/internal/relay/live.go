@@ -0,0 +1,182 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/live"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Live activities API: the NIP-53 live streams (kind 30311) that are live
+// and meeting spaces (kind 30312) that are open, with the number of
+// listeners whose room presence (kind 10312) is fresh, most attended first.
+//
+//	GET /api/live[?kind=30311|30312&t=<hashtag>&limit=50]
+//
+// WebSocket upgrades of /api/live are the dashboard's live traffic stream.
+//
+// Live streams still marked live whose ends time has passed, or whose host
+// stopped updating them, are stale and left out; with LIVE.HIDE_STALE_IN_REQ
+// they are left out of REQ results as well.
+
+const (
+	// livePruneInterval is how often stale room presences and live streams
+	// are forgotten.
+	livePruneInterval = time.Minute
+	// liveLoadPageSize is how many events are read at a time on startup.
+	liveLoadPageSize = 500
+	liveDefaultLimit = 50
+	liveMaxLimit     = 500
+)
+
+// liveTrackerInstance is the package-level live activity tracker (nil when disabled).
+var liveTrackerInstance *live.Tracker
+
+// GetLiveTracker returns the live activity tracker, or nil when it is disabled.
+func GetLiveTracker() *live.Tracker {
+	return liveTrackerInstance
+}
+
+// InitLiveTracker creates the live activity tracker and keeps it in step with
+// newly stored events. The stored activities are loaded by startLiveTracker.
+// Called from NewServer.
+func InitLiveTracker(cfg *config.Config, db *storage.DB) *live.Tracker {
+	if !cfg.Live.Enabled || db == nil {
+		liveTrackerInstance = nil
+		return nil
+	}
+	tracker := live.New(cfg.Live)
+	db.SetLiveObserver(tracker.Observe)
+	liveTrackerInstance = tracker
+	return tracker
+}
+
+// startLiveTracker loads the stored activities and recent room presences,
+// then periodically forgets stale presences and live streams. Called from
+// ListenAndServe.
+func startLiveTracker(ctx context.Context, db *storage.DB) {
+	tracker := GetLiveTracker()
+	if tracker == nil {
+		return
+	}
+	go func() {
+		loadLiveTracker(ctx, db, tracker)
+
+		ticker := time.NewTicker(livePruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				tracker.PruneAt(now)
+				expireStaleLive(tracker, now)
+			}
+		}
+	}()
+}
+
+// loadLiveTracker feeds the stored activities and the room presences
+// younger than the presence TTL to the tracker, oldest first.
+func loadLiveTracker(ctx context.Context, db *storage.DB, tracker *live.Tracker) {
+	log := logger.New("live")
+
+	// A since-only filter pages oldest first
+	since := nostr.Timestamp(0)
+	recent := nostr.Timestamp(time.Now().Add(-tracker.PresenceTTL()).Unix())
+	for _, f := range []nostr.Filter{
+		{Kinds: []int{live.KindLiveStream, live.KindMeetingSpace}, Since: &since, Limit: liveLoadPageSize},
+		{Kinds: []int{live.KindRoomPresence}, Since: &recent, Limit: liveLoadPageSize},
+	} {
+		var cursor storage.PageCursor
+		for {
+			events, err := db.GetEvents(storage.WithPageCursor(ctx, cursor), f)
+			if err != nil {
+				log.Warn("Failed to load live activities", zap.Error(err))
+				return
+			}
+			for i := range events {
+				evt := events[i]
+				tracker.Add(&evt)
+			}
+			if len(events) < liveLoadPageSize {
+				break
+			}
+			cursor = storage.CursorAfter(events[len(events)-1])
+		}
+	}
+	activities, presences := tracker.Len()
+	log.Info("Tracked NIP-53 live activities",
+		zap.Int("activities", activities),
+		zap.Int("presences", presences))
+}
+
+// expireStaleLive forgets the live streams that ended in all but status and
+// counts them.
+func expireStaleLive(tracker *live.Tracker, now time.Time) {
+	for reason, activities := range tracker.ExpireStale(now) {
+		for _, a := range activities {
+			logger.New("live").Debug("Live stream treated as ended",
+				zap.String("address", a.Address),
+				zap.String("reason", reason))
+		}
+		metrics.LiveActivitiesStale.WithLabelValues(reason).Add(float64(len(activities)))
+	}
+}
+
+// hiddenStaleLive reports whether REQ results leave evt out as a stale live
+// stream.
+func hiddenStaleLive(evt *nostr.Event) bool {
+	tracker := GetLiveTracker()
+	return tracker != nil && tracker.HiddenFromREQ(evt, time.Now())
+}
+
+// liveAPIResponse is the body of /api/live.
+type liveAPIResponse struct {
+	Activities []live.Activity `json:"activities"`
+	Count      int             `json:"count"`
+}
+
+// handleLiveActivitiesAPI serves GET /api/live.
+func (s *Server) handleLiveActivitiesAPI(w http.ResponseWriter, r *http.Request) {
+	tracker := GetLiveTracker()
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && tracker != nil) {
+		return
+	}
+	query := r.URL.Query()
+	q := live.Query{Hashtag: query.Get("t"), Limit: liveDefaultLimit}
+	if raw := query.Get("kind"); raw != "" {
+		kind, err := strconv.Atoi(raw)
+		if err != nil || (kind != live.KindLiveStream && kind != live.KindMeetingSpace) {
+			writeEventsAPIError(w, r, "INVALID_KIND", "kind must be 30311 or 30312")
+			return
+		}
+		q.Kind = kind
+	}
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > liveMaxLimit {
+			writeEventsAPIError(w, r, "INVALID_LIMIT", "limit must be between 1 and "+strconv.Itoa(liveMaxLimit))
+			return
+		}
+		q.Limit = n
+	}
+
+	db := s.node.DB()
+	response := liveAPIResponse{Activities: []live.Activity{}}
+	for _, a := range tracker.Active(q) {
+		if visibleAnonymously(db, a.Event) {
+			response.Activities = append(response.Activities, a)
+		}
+	}
+	response.Count = len(response.Activities)
+	writeEventsAPIJSON(w, response)
+}
@@ -0,0 +1,468 @@
+package relay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/domain"
+	"github.com/Shugur-Network/relay/internal/lightning"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/nip05"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/signer"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// LNURL-pay: with LNURL enabled the relay has a lightning address,
+// LNURL.NAME@<relay host>, served at /.well-known/lnurlp/<name> (LUD-06,
+// LUD-16) with invoices from the configured LNbits wallet or LND node. The
+// address accepts NIP-57 zaps: the invoice commits to the zap request passed
+// to the callback, and once it is paid the relay publishes the zap receipt,
+// signed with its key. With LNURL.USERS the names hosted through
+// NIP05.HOSTING are addresses too, paid into the same wallet. Zap receipts
+// signed with the relay key are only accepted for invoices the relay issued
+// and saw paid (see ZapVerifier.Verify).
+
+const (
+	defaultLNURLName          = "relay"
+	defaultLNURLMinSendable   = 1000
+	defaultLNURLMaxSendable   = 100_000_000
+	defaultLNURLInvoiceExpiry = 10 * time.Minute
+	defaultLNURLPollInterval  = 5 * time.Second
+	defaultMaxPendingZaps     = 10000
+
+	// paidZapRetention is how long a paid zap invoice is remembered, so that
+	// its receipt can still be verified when it comes back from other relays.
+	paidZapRetention = 24 * time.Hour
+)
+
+// pendingZap is a zap invoice the relay issued.
+type pendingZap struct {
+	request     *nostr.Event
+	description string // the zap request JSON the invoice commits to
+	recipient   string
+	bolt11      string
+	expires     time.Time
+	paidAt      time.Time // zero until paid
+}
+
+// LNURLPay serves the relay's lightning addresses and publishes the receipts
+// of the zaps paid through them.
+type LNURLPay struct {
+	cfg     config.LNURLConfig
+	node    domain.NodeInterface
+	backend lightning.Backend
+	origin  string // http(s) origin of RELAY.PUBLIC_URL, "" when unset
+
+	mu   sync.Mutex
+	zaps map[string]*pendingZap // payment hash -> zap
+}
+
+// lnurlInstance is the package-level lightning address (nil when disabled).
+var lnurlInstance *LNURLPay
+
+// GetLNURLPay returns the relay's lightning address, or nil when it is disabled.
+func GetLNURLPay() *LNURLPay {
+	return lnurlInstance
+}
+
+// InitLNURLPay creates the relay's lightning address. Called from NewServer
+// after the group store, whose key signs the zap receipts.
+func InitLNURLPay(cfg *config.Config, node domain.NodeInterface) *LNURLPay {
+	lnurlInstance = nil
+	if !cfg.LNURL.Enabled {
+		return nil
+	}
+	log := logger.New("lnurl")
+	backend, err := lightning.New(cfg.LNURL.Backend)
+	if err != nil {
+		log.Error("Lightning backend unavailable — lightning address disabled", zap.Error(err))
+		return nil
+	}
+
+	c := cfg.LNURL
+	c.Name = strings.ToLower(c.Name)
+	if c.Name == "" {
+		c.Name = defaultLNURLName
+	}
+	if c.MinSendable <= 0 {
+		c.MinSendable = defaultLNURLMinSendable
+	}
+	if c.MaxSendable <= 0 {
+		c.MaxSendable = defaultLNURLMaxSendable
+	}
+	if c.InvoiceExpiry <= 0 {
+		c.InvoiceExpiry = defaultLNURLInvoiceExpiry
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultLNURLPollInterval
+	}
+	if c.MaxPending <= 0 {
+		c.MaxPending = defaultMaxPendingZaps
+	}
+	if !nip05.ValidName(c.Name) || c.MinSendable > c.MaxSendable {
+		log.Error("Invalid LNURL settings — lightning address disabled",
+			zap.String("name", c.Name),
+			zap.Int64("min_sendable", c.MinSendable),
+			zap.Int64("max_sendable", c.MaxSendable))
+		return nil
+	}
+
+	l := &LNURLPay{cfg: c, node: node, backend: backend, zaps: make(map[string]*pendingZap)}
+	if u, err := url.Parse(cfg.Relay.PublicURL); err == nil && u.Host != "" {
+		scheme := "https"
+		if u.Scheme == "ws" || u.Scheme == "http" {
+			scheme = "http"
+		}
+		l.origin = scheme + "://" + u.Host
+	}
+	log.Info("Lightning address enabled",
+		zap.String("name", c.Name),
+		zap.String("backend", c.Backend.Type),
+		zap.Bool("users", c.Users))
+
+	lnurlInstance = l
+	return l
+}
+
+// startLNURLPay checks unpaid zap invoices until ctx is canceled.
+func startLNURLPay(ctx context.Context) {
+	if l := GetLNURLPay(); l != nil {
+		go l.run(ctx)
+	}
+}
+
+// recipient returns the pubkey zaps to name go to, "" when name is not one
+// of the relay's lightning addresses.
+func (l *LNURLPay) recipient(name string) string {
+	if name == l.cfg.Name {
+		if gs := GetGroupStore(); gs != nil {
+			return gs.GetRelayPubkey()
+		}
+		return ""
+	}
+	if l.cfg.Users {
+		if dir := GetNIP05Directory(); dir != nil {
+			if n, ok := dir.Get(name); ok {
+				return n.Pubkey
+			}
+		}
+	}
+	return ""
+}
+
+// Serves reports whether an LNURL-pay endpoint is one of the relay's own.
+func (l *LNURLPay) Serves(endpoint string) bool {
+	return l.origin != "" && strings.HasPrefix(endpoint, l.origin+"/.well-known/lnurlp/")
+}
+
+// receiptSigner returns the key zap receipts are signed with, nil when the
+// relay cannot sign.
+func (l *LNURLPay) receiptSigner() signer.Signer {
+	if gs := GetGroupStore(); gs != nil {
+		return gs.relaySigner()
+	}
+	return nil
+}
+
+// Signs reports whether pubkey is the key the relay signs zap receipts with.
+func (l *LNURLPay) Signs(pubkey string) bool {
+	s := l.receiptSigner()
+	return s != nil && s.PublicKey() == pubkey
+}
+
+// VerifyReceipt checks a zap receipt signed with the relay key: its invoice
+// must be one the relay issued for the embedded zap request and saw paid.
+func (l *LNURLPay) VerifyReceipt(event *nostr.Event) error {
+	invoice, err := nips.DecodeBolt11(nips.GetTagValue(*event, "bolt11"))
+	if err != nil {
+		return fmt.Errorf("invalid bolt11 invoice: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	z, ok := l.zaps[invoice.PaymentHash]
+	switch {
+	case !ok || z.bolt11 != nips.GetTagValue(*event, "bolt11"):
+		return fmt.Errorf("zap receipt names an invoice this relay did not issue")
+	case z.paidAt.IsZero():
+		return fmt.Errorf("zap receipt names an unpaid invoice")
+	case z.description != nips.GetTagValue(*event, "description"):
+		return fmt.Errorf("zap receipt does not carry the zap request the invoice was issued for")
+	}
+	return nil
+}
+
+// lnurlPayParams is the LUD-06 payRequest, with the NIP-57 zap fields.
+type lnurlPayParams struct {
+	Tag         string `json:"tag"`
+	Callback    string `json:"callback"`
+	MinSendable int64  `json:"minSendable"`
+	MaxSendable int64  `json:"maxSendable"`
+	Metadata    string `json:"metadata"`
+	AllowsNostr bool   `json:"allowsNostr,omitempty"`
+	NostrPubkey string `json:"nostrPubkey,omitempty"`
+}
+
+// handleLNURLPay serves GET /.well-known/lnurlp/<name> and its callback,
+// /.well-known/lnurlp/<name>/callback?amount=<msat>[&nostr=<zap request>].
+func (s *Server) handleLNURLPay(w http.ResponseWriter, r *http.Request) {
+	// Wallets may run in the browser
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeLNURLError(w, http.StatusMethodNotAllowed, "only GET requests are allowed")
+		return
+	}
+	l := GetLNURLPay()
+	if l == nil {
+		writeLNURLError(w, http.StatusNotFound, "this relay has no lightning address")
+		return
+	}
+
+	name, callback := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/.well-known/lnurlp/"), "/callback")
+	name = strings.ToLower(name)
+	recipient := l.recipient(name)
+	if recipient == "" {
+		writeLNURLError(w, http.StatusNotFound, "unknown lightning address")
+		return
+	}
+	base := s.publicBaseURL(r)
+	metadata := l.metadata(name, strings.TrimPrefix(strings.TrimPrefix(base, "https://"), "http://"))
+
+	if !callback {
+		params := lnurlPayParams{
+			Tag:         "payRequest",
+			Callback:    base + "/.well-known/lnurlp/" + name + "/callback",
+			MinSendable: l.cfg.MinSendable,
+			MaxSendable: l.cfg.MaxSendable,
+			Metadata:    metadata,
+		}
+		if sig := l.receiptSigner(); sig != nil {
+			params.AllowsNostr, params.NostrPubkey = true, sig.PublicKey()
+		}
+		writeEventsAPIJSON(w, params)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), eventsAPIQueryTimeout)
+	defer cancel()
+	bolt11, errMsg := l.invoice(ctx, recipient, metadata, r.URL.Query())
+	if errMsg != "" {
+		writeLNURLError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	writeEventsAPIJSON(w, map[string]interface{}{"pr": bolt11, "routes": []string{}})
+}
+
+// metadata returns the LUD-06 metadata of an address, which non-zap
+// invoices commit to.
+func (l *LNURLPay) metadata(name, host string) string {
+	identifier := name + "@" + host
+	description := l.cfg.Description
+	if description == "" {
+		description = "Zap " + identifier
+	}
+	b, _ := json.Marshal([][]string{{"text/plain", description}, {"text/identifier", identifier}})
+	return string(b)
+}
+
+// invoice creates the invoice for a callback, returning the reason when the
+// request is refused.
+func (l *LNURLPay) invoice(ctx context.Context, recipient, metadata string, query url.Values) (string, string) {
+	amount, err := strconv.ParseInt(query.Get("amount"), 10, 64)
+	if err != nil || amount < l.cfg.MinSendable || amount > l.cfg.MaxSendable {
+		return "", fmt.Sprintf("amount must be between %d and %d msat", l.cfg.MinSendable, l.cfg.MaxSendable)
+	}
+
+	descriptionHash := sha256.Sum256([]byte(metadata))
+	var z *pendingZap
+	if raw := query.Get("nostr"); raw != "" {
+		if l.receiptSigner() == nil {
+			return "", "this address does not accept zaps"
+		}
+		request, errMsg := zapRequestFor(raw, recipient, amount)
+		if errMsg != "" {
+			return "", errMsg
+		}
+		l.mu.Lock()
+		full := len(l.zaps) >= l.cfg.MaxPending
+		l.mu.Unlock()
+		if full {
+			return "", "too many pending zaps, try again later"
+		}
+		descriptionHash = sha256.Sum256([]byte(raw))
+		z = &pendingZap{request: request, description: raw, recipient: recipient}
+	}
+
+	inv, err := l.backend.CreateInvoice(ctx, amount, descriptionHash[:], l.cfg.InvoiceExpiry)
+	if err != nil {
+		metrics.LNURLInvoices.WithLabelValues("failed").Inc()
+		logger.New("lnurl").Warn("Failed to create invoice", zap.Error(err))
+		return "", "failed to create invoice"
+	}
+	metrics.LNURLInvoices.WithLabelValues("created").Inc()
+	if z != nil {
+		z.bolt11, z.expires = inv.Bolt11, time.Now().Add(l.cfg.InvoiceExpiry)
+		l.mu.Lock()
+		l.zaps[inv.PaymentHash] = z
+		l.mu.Unlock()
+	}
+	return inv.Bolt11, ""
+}
+
+// zapRequestFor parses the zap request of a callback and checks that it
+// zaps recipient for amount.
+func zapRequestFor(raw, recipient string, amount int64) (*nostr.Event, string) {
+	var request nostr.Event
+	if err := json.Unmarshal([]byte(raw), &request); err != nil {
+		return nil, "invalid zap request"
+	}
+	if err := nips.ValidateZapRequest(&request); err != nil {
+		return nil, "invalid zap request: " + err.Error()
+	}
+	if ok, err := request.CheckSignature(); err != nil || !ok {
+		return nil, "zap request signature is invalid"
+	}
+	var recipients []string
+	for _, tag := range request.Tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			recipients = append(recipients, tag[1])
+		}
+	}
+	if len(recipients) != 1 || recipients[0] != recipient {
+		return nil, "zap request must name this address's pubkey in a single p tag"
+	}
+	if a := nips.GetTagValue(request, "amount"); a != "" && a != strconv.FormatInt(amount, 10) {
+		return nil, "amount does not match the zap request"
+	}
+	return &request, ""
+}
+
+// run checks the unpaid zap invoices every POLL_INTERVAL until ctx is canceled.
+func (l *LNURLPay) run(ctx context.Context) {
+	ticker := time.NewTicker(l.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.poll(ctx)
+		}
+	}
+}
+
+// poll publishes the receipts of newly paid zaps and forgets expired
+// invoices. An expired invoice is checked once more before it is dropped, in
+// case it was paid just before expiring.
+func (l *LNURLPay) poll(ctx context.Context) {
+	now := time.Now()
+	var unpaid []string
+	l.mu.Lock()
+	for hash, z := range l.zaps {
+		if z.paidAt.IsZero() {
+			unpaid = append(unpaid, hash)
+		} else if now.Sub(z.paidAt) > paidZapRetention {
+			delete(l.zaps, hash)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, hash := range unpaid {
+		status, err := l.backend.InvoiceStatus(ctx, hash)
+		if err != nil {
+			logger.New("lnurl").Debug("Invoice status check failed",
+				zap.String("payment_hash", hash),
+				zap.Error(err))
+			continue
+		}
+		if status.Paid {
+			l.settle(ctx, hash, status.Preimage)
+			continue
+		}
+		l.mu.Lock()
+		if z, ok := l.zaps[hash]; ok && z.paidAt.IsZero() && now.After(z.expires) {
+			delete(l.zaps, hash)
+			metrics.LNURLInvoices.WithLabelValues("expired").Inc()
+		}
+		l.mu.Unlock()
+	}
+}
+
+// settle marks a zap paid and publishes its receipt.
+func (l *LNURLPay) settle(ctx context.Context, hash, preimage string) {
+	l.mu.Lock()
+	z, ok := l.zaps[hash]
+	if !ok || !z.paidAt.IsZero() {
+		l.mu.Unlock()
+		return
+	}
+	z.paidAt = time.Now()
+	l.mu.Unlock()
+	metrics.LNURLInvoices.WithLabelValues("paid").Inc()
+
+	log := logger.New("lnurl")
+	sig := l.receiptSigner()
+	if sig == nil {
+		log.Warn("Zap paid but the relay cannot sign its receipt", zap.String("payment_hash", hash))
+		return
+	}
+	receipt := zapReceipt(z, preimage)
+	if err := sig.Sign(ctx, receipt); err != nil {
+		log.Error("Failed to sign zap receipt", zap.Error(err))
+		return
+	}
+	if !queueRelayEvent(l.node, receipt) {
+		log.Warn("Failed to queue zap receipt", zap.String("event_id", receipt.ID))
+		return
+	}
+	log.Info("Zap received",
+		zap.String("recipient", z.recipient),
+		zap.String("receipt", receipt.ID))
+}
+
+// zapReceipt builds the kind 9735 receipt of a paid zap (NIP-57).
+func zapReceipt(z *pendingZap, preimage string) *nostr.Event {
+	tags := nostr.Tags{{"p", z.recipient}}
+	for _, name := range []string{"e", "a", "k"} {
+		if v := nips.GetTagValue(*z.request, name); v != "" {
+			tags = append(tags, nostr.Tag{name, v})
+		}
+	}
+	tags = append(tags,
+		nostr.Tag{"P", z.request.PubKey},
+		nostr.Tag{"bolt11", z.bolt11},
+		nostr.Tag{"description", z.description})
+	if preimage != "" {
+		tags = append(tags, nostr.Tag{"preimage", preimage})
+	}
+	return &nostr.Event{
+		Kind:      9735,
+		CreatedAt: nostr.Timestamp(z.paidAt.Unix()),
+		Tags:      tags,
+	}
+}
+
+// writeLNURLError writes a LUD-06 error response.
+func writeLNURLError(w http.ResponseWriter, status int, reason string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ERROR", "reason": reason})
+}
@@ -0,0 +1,240 @@
+package relay
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/marketplace"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Marketplace API: NIP-15 product search and stall browsing from the
+// marketplace index, which keeps the latest version of every stall (kind
+// 30017) and product (kind 30018).
+//
+//	GET /api/marketplace/products?category=&currency=&merchant=&stall=&min_price=&max_price=&in_stock=true&limit=50&cursor=
+//	GET /api/marketplace/stalls?merchant=&currency=&limit=50
+//
+// Products are listed newest first with their stock status ("in_stock",
+// "out_of_stock" or "unlimited" when they give no quantity); stall filters
+// by stall id and needs merchant. Prices are compared in the product's own
+// currency, so a price range is best combined with currency. When a page of
+// products is full, "next" holds the URL of the following page. Stalls come
+// with their product count, in-stock count, categories and price range.
+
+const (
+	// marketplaceLoadPageSize is how many listings are read at a time on startup.
+	marketplaceLoadPageSize = 500
+	marketplaceDefaultLimit = 50
+	marketplaceMaxLimit     = 500
+)
+
+// marketplaceInstance is the package-level marketplace index (nil when disabled).
+var marketplaceInstance *marketplace.Index
+
+// GetMarketplace returns the marketplace index, or nil when it is disabled.
+func GetMarketplace() *marketplace.Index {
+	return marketplaceInstance
+}
+
+// InitMarketplace creates the marketplace index and keeps it in step with
+// newly stored events. The stored listings are loaded by startMarketplace.
+// Called from NewServer.
+func InitMarketplace(cfg *config.Config, db *storage.DB) *marketplace.Index {
+	if !cfg.Marketplace.Enabled || db == nil {
+		marketplaceInstance = nil
+		return nil
+	}
+	index := marketplace.New(cfg.Marketplace)
+	db.SetMarketplaceObserver(index.Observe)
+	marketplaceInstance = index
+	return index
+}
+
+// startMarketplace loads the stored stalls and products into the index.
+// Called from ListenAndServe.
+func startMarketplace(ctx context.Context, db *storage.DB) {
+	index := GetMarketplace()
+	if index == nil {
+		return
+	}
+	go loadMarketplace(ctx, db, index)
+}
+
+// loadMarketplace indexes the stored stalls and products, oldest first, so
+// later versions replace earlier ones.
+func loadMarketplace(ctx context.Context, db *storage.DB, index *marketplace.Index) {
+	log := logger.New("marketplace")
+
+	// A since-only filter pages oldest first
+	since := nostr.Timestamp(0)
+	f := nostr.Filter{
+		Kinds: []int{marketplace.KindStall, marketplace.KindProduct},
+		Since: &since,
+		Limit: marketplaceLoadPageSize,
+	}
+	var cursor storage.PageCursor
+	for {
+		events, err := db.GetEvents(storage.WithPageCursor(ctx, cursor), f)
+		if err != nil {
+			log.Warn("Failed to load marketplace listings", zap.Error(err))
+			return
+		}
+		for i := range events {
+			evt := events[i]
+			index.Add(&evt)
+		}
+		if len(events) < marketplaceLoadPageSize {
+			break
+		}
+		cursor = storage.CursorAfter(events[len(events)-1])
+	}
+	stalls, products := index.Len()
+	log.Info("Indexed NIP-15 marketplace",
+		zap.Int("stalls", stalls),
+		zap.Int("products", products))
+}
+
+// marketplaceProductsResponse is the body of /api/marketplace/products.
+type marketplaceProductsResponse struct {
+	Products []*marketplace.Product `json:"products"`
+	Count    int                    `json:"count"`
+	Next     string                 `json:"next,omitempty"`
+}
+
+// marketplaceStallsResponse is the body of /api/marketplace/stalls.
+type marketplaceStallsResponse struct {
+	Stalls []marketplace.StallSummary `json:"stalls"`
+	Count  int                        `json:"count"`
+}
+
+// handleMarketplaceProductsAPI serves GET /api/marketplace/products.
+func (s *Server) handleMarketplaceProductsAPI(w http.ResponseWriter, r *http.Request) {
+	index := GetMarketplace()
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && index != nil) {
+		return
+	}
+	query := r.URL.Query()
+	q := marketplace.ProductQuery{
+		Category: query.Get("category"),
+		Currency: query.Get("currency"),
+		StallID:  query.Get("stall"),
+		InStock:  query.Get("in_stock") == "true",
+	}
+	if raw := query.Get("merchant"); raw != "" {
+		pubkey, errMsg := parsePubkeyParam(raw)
+		if errMsg != "" {
+			writeEventsAPIError(w, r, "INVALID_PUBKEY", errMsg)
+			return
+		}
+		q.Merchant = pubkey
+	}
+	if q.StallID != "" && q.Merchant == "" {
+		writeEventsAPIError(w, r, "INVALID_STALL", "stall requires merchant, stall ids are per merchant")
+		return
+	}
+	var errMsg string
+	if q.MinPrice, errMsg = parseMarketplacePrice(query, "min_price"); errMsg == "" {
+		q.MaxPrice, errMsg = parseMarketplacePrice(query, "max_price")
+	}
+	if errMsg != "" {
+		writeEventsAPIError(w, r, "INVALID_PRICE", errMsg)
+		return
+	}
+	limit, ok := parseMarketplaceLimit(w, r)
+	if !ok {
+		return
+	}
+	q.Limit = limit
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := storage.ParsePageCursor(raw)
+		if err != nil {
+			writeEventsAPIError(w, r, "INVALID_CURSOR", err.Error())
+			return
+		}
+		q.AfterCreatedAt, q.AfterID = cursor.CreatedAt, cursor.ID
+	}
+
+	products := index.Products(q)
+	response := marketplaceProductsResponse{Products: make([]*marketplace.Product, 0, len(products))}
+	db := s.node.DB()
+	for _, p := range products {
+		if visibleAnonymously(db, p.Event) {
+			response.Products = append(response.Products, p)
+		}
+	}
+	response.Count = len(response.Products)
+	if len(products) == limit {
+		// The next page starts after the last product of this one
+		query.Set("cursor", storage.CursorAfter(*products[len(products)-1].Event).String())
+		response.Next = r.URL.Path + "?" + query.Encode()
+	}
+	writeEventsAPIJSON(w, response)
+}
+
+// handleMarketplaceStallsAPI serves GET /api/marketplace/stalls.
+func (s *Server) handleMarketplaceStallsAPI(w http.ResponseWriter, r *http.Request) {
+	index := GetMarketplace()
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && index != nil) {
+		return
+	}
+	query := r.URL.Query()
+	var merchant string
+	if raw := query.Get("merchant"); raw != "" {
+		pubkey, errMsg := parsePubkeyParam(raw)
+		if errMsg != "" {
+			writeEventsAPIError(w, r, "INVALID_PUBKEY", errMsg)
+			return
+		}
+		merchant = pubkey
+	}
+	limit, ok := parseMarketplaceLimit(w, r)
+	if !ok {
+		return
+	}
+
+	db := s.node.DB()
+	response := marketplaceStallsResponse{Stalls: []marketplace.StallSummary{}}
+	for _, stall := range index.Stalls(merchant, query.Get("currency"), limit) {
+		if visibleAnonymously(db, stall.Event) {
+			response.Stalls = append(response.Stalls, stall)
+		}
+	}
+	response.Count = len(response.Stalls)
+	writeEventsAPIJSON(w, response)
+}
+
+// parseMarketplaceLimit reads the limit of a marketplace listing, answering
+// the request when it is invalid.
+func parseMarketplaceLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return marketplaceDefaultLimit, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > marketplaceMaxLimit {
+		writeEventsAPIError(w, r, "INVALID_LIMIT", "limit must be between 1 and "+strconv.Itoa(marketplaceMaxLimit))
+		return 0, false
+	}
+	return n, true
+}
+
+// parseMarketplacePrice reads a price bound, nil when it is not given.
+func parseMarketplacePrice(query url.Values, param string) (*float64, string) {
+	raw := query.Get(param)
+	if raw == "" {
+		return nil, ""
+	}
+	price, err := strconv.ParseFloat(raw, 64)
+	if err != nil || price < 0 || math.IsInf(price, 0) {
+		return nil, param + " must be a non-negative number"
+	}
+	return &price, ""
+}
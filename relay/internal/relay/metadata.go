@@ -0,0 +1,100 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metadata"
+	"github.com/Shugur-Network/relay/internal/nip05"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Profile API: GET /api/profile/<pubkey> serves the latest kind 0 of a pubkey
+// (hex, npub or nprofile) from the metadata cache, its parsed metadata and,
+// when it claims a NIP-05 identifier and verification is enabled, whether the
+// identifier's domain lists the pubkey under it.
+
+// metadataInstance is the package-level kind 0 metadata cache (nil when disabled).
+var metadataInstance *metadata.Cache
+
+// GetMetadataCache returns the kind 0 metadata cache, or nil when it is disabled.
+func GetMetadataCache() *metadata.Cache {
+	return metadataInstance
+}
+
+// InitMetadataCache creates the metadata cache over stored profiles and keeps
+// it in step with newly stored events. Called from NewServer.
+func InitMetadataCache(cfg *config.Config, db *storage.DB) *metadata.Cache {
+	if !cfg.Metadata.Enabled || db == nil {
+		metadataInstance = nil
+		return nil
+	}
+	metadataInstance = metadata.New(cfg.Metadata, func(ctx context.Context, pubkey string) (*nostr.Event, error) {
+		events, err := db.GetEvents(ctx, nostr.Filter{Kinds: []int{0}, Authors: []string{pubkey}, Limit: 1})
+		if err != nil || len(events) == 0 {
+			return nil, err
+		}
+		return &events[0], nil
+	})
+	db.SetMetadataObserver(metadataInstance.Observe)
+	return metadataInstance
+}
+
+// profileAPIResponse is the body of /api/profile/<pubkey>.
+type profileAPIResponse struct {
+	Pubkey   string              `json:"pubkey"`
+	Event    *nostr.Event        `json:"event"`
+	Metadata metadata.Metadata   `json:"metadata"`
+	Valid    bool                `json:"valid"` // the content is a JSON object
+	NIP05    *nip05.Verification `json:"nip05,omitempty"`
+}
+
+// handleProfileAPI serves GET /api/profile/<pubkey>.
+func (s *Server) handleProfileAPI(w http.ResponseWriter, r *http.Request) {
+	cache := GetMetadataCache()
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && cache != nil) {
+		return
+	}
+	pubkey, errMsg := parsePubkeyParam(strings.TrimPrefix(r.URL.Path, "/api/profile/"))
+	if errMsg != "" {
+		writeEventsAPIError(w, r, "INVALID_PUBKEY", errMsg)
+		return
+	}
+
+	release, ok := s.acquireEventsAPISlot(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(eventsAPIContext(r), eventsAPIQueryTimeout)
+	defer cancel()
+
+	profile, err := cache.Get(ctx, pubkey)
+	if err != nil {
+		logger.Warn("Profile query failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("profile query", err))
+		return
+	}
+	if profile == nil || !visibleAnonymously(s.node.DB(), profile.Event) {
+		errors.HandleHTTPError(w, r, errors.NotFoundError("profile"))
+		return
+	}
+
+	response := profileAPIResponse{
+		Pubkey:   pubkey,
+		Event:    profile.Event,
+		Metadata: profile.Metadata,
+		Valid:    profile.Valid,
+	}
+	if id, resolver := profile.Metadata.NIP05, GetNIP05Resolver(); id != "" && resolver != nil {
+		v := resolver.Verify(ctx, id, pubkey)
+		response.NIP05 = &v
+	}
+	writeEventsAPIJSON(w, response)
+}
@@ -0,0 +1,315 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+func init() {
+	registry.Register(registry.NIP{Name: "Namespace Policies", Kinds: []int{39150}, Hidden: true})
+}
+
+// Namespace policies: community-scoped kind rules and rate limits.
+//
+// Event kind:
+//   39150 — Namespace policy (addressable, "d" tag = namespace)
+//
+// A namespace is the tag that scopes an event to a community:
+//   h:<group-id>                    — NIP-29 group (managed by group admins)
+//   a:34550:<owner>:<identifier>    — NIP-72 community (managed by its owner)
+//
+// Policy tags:
+//   ["allow", "<kind>"]                — only listed kinds are accepted (if any allow tag is present)
+//   ["deny", "<kind>"]                 — listed kinds are rejected
+//   ["rate", "<events>", "<seconds>"]  — per-pubkey publish limit inside the namespace
+//
+// Relay operators (PUBLIC_KEY and ADMIN_PUBKEYS) may publish a policy for any
+// namespace. An operator policy overrides the community's own policy until the
+// operator publishes an empty policy for that namespace, releasing it.
+// Namespace rules only narrow the global policy, they never allow a kind the
+// relay itself rejects.
+
+// KindNamespacePolicy is the addressable kind carrying a namespace policy.
+const KindNamespacePolicy = 39150
+
+// maxNamespaceLimiters bounds the per-pubkey limiters kept for one namespace.
+const maxNamespaceLimiters = 10000
+
+// NamespacePolicy holds the rules published for a single namespace.
+type NamespacePolicy struct {
+	Namespace  string
+	Allow      map[int]bool
+	Deny       map[int]bool
+	RateEvents int // 0 = no namespace rate limit
+	RateWindow time.Duration
+	Author     string // pubkey that published the policy
+	Operator   bool   // published by a relay operator (overrides community admins)
+	UpdatedAt  nostr.Timestamp
+	limiters   map[string]*rate.Limiter // pubkey -> limiter
+}
+
+// NamespacePolicyStore holds the namespace policies in memory. The policy
+// events themselves are stored like any addressable event and replayed by
+// LoadFromDB at startup.
+type NamespacePolicyStore struct {
+	mu       sync.Mutex
+	policies map[string]*NamespacePolicy // namespace -> policy
+	cfg      *config.Config
+}
+
+// namespacePolicyStoreInstance is the package-level namespace policy store singleton.
+var namespacePolicyStoreInstance *NamespacePolicyStore
+
+// GetNamespacePolicyStore returns the package-level namespace policy store.
+func GetNamespacePolicyStore() *NamespacePolicyStore {
+	return namespacePolicyStoreInstance
+}
+
+// InitNamespacePolicyStore initializes the package-level namespace policy store. Called from NewServer.
+func InitNamespacePolicyStore(cfg *config.Config) *NamespacePolicyStore {
+	namespacePolicyStoreInstance = &NamespacePolicyStore{
+		policies: make(map[string]*NamespacePolicy),
+		cfg:      cfg,
+	}
+	return namespacePolicyStoreInstance
+}
+
+// namespacePolicyPage is the number of stored policies read per query when
+// restoring them.
+const namespacePolicyPage = 500
+
+// LoadFromDB restores the policies from the stored kind 39150 events,
+// replaying them oldest first so operator overrides and releases end up as
+// they were before the restart.
+func (ns *NamespacePolicyStore) LoadFromDB(ctx context.Context, db *storage.DB) {
+	if db == nil {
+		return
+	}
+	var events []nostr.Event
+	since := nostr.Timestamp(0)
+	cursor := storage.PageCursor{}
+	for {
+		page, err := db.GetEvents(storage.WithPageCursor(ctx, cursor), nostr.Filter{
+			Kinds: []int{KindNamespacePolicy},
+			Since: &since,
+			Limit: namespacePolicyPage,
+		})
+		if err != nil {
+			logger.New("namespace").Warn("Failed to load namespace policies", zap.Error(err))
+			return
+		}
+		events = append(events, page...)
+		if len(page) < namespacePolicyPage {
+			break
+		}
+		cursor = storage.CursorAfter(page[len(page)-1])
+	}
+
+	for i := range events {
+		ns.applyPolicyEvent(&events[i], false)
+	}
+	ns.mu.Lock()
+	count := len(ns.policies)
+	ns.mu.Unlock()
+	if count > 0 {
+		logger.New("namespace").Info("Restored namespace policies", zap.Int("policies", count))
+	}
+}
+
+// GetPolicy returns the policy for a namespace (nil if none).
+func (ns *NamespacePolicyStore) GetPolicy(namespace string) *NamespacePolicy {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.policies[namespace]
+}
+
+// isOperator checks if the pubkey is the relay owner or a configured admin.
+func (ns *NamespacePolicyStore) isOperator(pubkey string) bool {
+	pubkey = strings.ToLower(pubkey)
+	if ns.cfg.Relay.PublicKey != "" && strings.ToLower(ns.cfg.Relay.PublicKey) == pubkey {
+		return true
+	}
+	for _, admin := range ns.cfg.Relay.AdminPubkeys {
+		if strings.ToLower(admin) == pubkey {
+			return true
+		}
+	}
+	return false
+}
+
+// isNamespaceAdmin checks if the pubkey administers the community behind a namespace.
+func isNamespaceAdmin(namespace, pubkey string) bool {
+	switch {
+	case strings.HasPrefix(namespace, "h:"):
+		gs := GetGroupStore()
+		return gs != nil && gs.IsAdmin(strings.TrimPrefix(namespace, "h:"), pubkey)
+	case strings.HasPrefix(namespace, "a:34550:"):
+		parts := strings.SplitN(strings.TrimPrefix(namespace, "a:"), ":", 3)
+		return len(parts) == 3 && strings.EqualFold(parts[1], pubkey)
+	}
+	return false
+}
+
+// eventNamespaces returns the namespaces an event is scoped to.
+func eventNamespaces(evt *nostr.Event) []string {
+	var namespaces []string
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[1] == "" {
+			continue
+		}
+		switch {
+		case tag[0] == "h":
+			namespaces = append(namespaces, "h:"+tag[1])
+		case tag[0] == "a" && strings.HasPrefix(tag[1], "34550:"):
+			namespaces = append(namespaces, "a:"+tag[1])
+		}
+	}
+	return namespaces
+}
+
+// HandlePolicyEvent validates and applies a kind 39150 namespace policy event.
+// Returns (accepted, reason).
+func (ns *NamespacePolicyStore) HandlePolicyEvent(evt *nostr.Event) (bool, string) {
+	return ns.applyPolicyEvent(evt, true)
+}
+
+// applyPolicyEvent parses and applies a policy event. checkAdmin is false
+// when replaying stored policies, whose authors were checked on publish and
+// whose groups may not be known yet.
+func (ns *NamespacePolicyStore) applyPolicyEvent(evt *nostr.Event, checkAdmin bool) (bool, string) {
+	namespace := evt.Tags.GetD()
+	if !strings.HasPrefix(namespace, "h:") && !strings.HasPrefix(namespace, "a:34550:") {
+		return false, "invalid: namespace must be 'h:<group>' or 'a:34550:<pubkey>:<d>'"
+	}
+
+	operator := ns.isOperator(evt.PubKey)
+	if !operator && checkAdmin && !isNamespaceAdmin(namespace, evt.PubKey) {
+		return false, "restricted: only namespace admins or relay operators can set its policy"
+	}
+
+	policy := &NamespacePolicy{
+		Namespace: namespace,
+		Allow:     make(map[int]bool),
+		Deny:      make(map[int]bool),
+		Author:    strings.ToLower(evt.PubKey),
+		Operator:  operator,
+		UpdatedAt: evt.CreatedAt,
+		limiters:  make(map[string]*rate.Limiter),
+	}
+
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "allow", "deny":
+			kind, err := strconv.Atoi(tag[1])
+			if err != nil || kind < 0 {
+				return false, fmt.Sprintf("invalid: bad kind in '%s' tag: %s", tag[0], tag[1])
+			}
+			if tag[0] == "allow" {
+				policy.Allow[kind] = true
+			} else {
+				policy.Deny[kind] = true
+			}
+		case "rate":
+			if len(tag) < 3 {
+				return false, "invalid: 'rate' tag must be [\"rate\", \"<events>\", \"<seconds>\"]"
+			}
+			events, err1 := strconv.Atoi(tag[1])
+			seconds, err2 := strconv.Atoi(tag[2])
+			if err1 != nil || err2 != nil || events <= 0 || seconds <= 0 {
+				return false, "invalid: 'rate' tag values must be positive integers"
+			}
+			policy.RateEvents = events
+			policy.RateWindow = time.Duration(seconds) * time.Second
+		}
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	existing := ns.policies[namespace]
+	if existing != nil {
+		if existing.Operator && !operator {
+			return false, "restricted: namespace policy is overridden by the relay operator"
+		}
+		if evt.CreatedAt < existing.UpdatedAt {
+			return false, "duplicate: a newer policy exists for this namespace"
+		}
+	}
+
+	// An empty operator policy releases the namespace back to its community admins
+	if operator && len(policy.Allow) == 0 && len(policy.Deny) == 0 && policy.RateEvents == 0 {
+		delete(ns.policies, namespace)
+		logger.New("namespace").Info("Namespace policy released by operator",
+			zap.String("namespace", namespace))
+		return true, ""
+	}
+
+	ns.policies[namespace] = policy
+	logger.New("namespace").Info("Namespace policy updated",
+		zap.String("namespace", namespace),
+		zap.String("author", policy.Author[:16]+"..."),
+		zap.Bool("operator", operator),
+		zap.Int("allow", len(policy.Allow)),
+		zap.Int("deny", len(policy.Deny)),
+		zap.Int("rate_events", policy.RateEvents))
+
+	return true, ""
+}
+
+// CheckEvent applies the policies of every namespace an event is scoped to.
+// Returns (allowed, reason).
+func (ns *NamespacePolicyStore) CheckEvent(evt *nostr.Event) (bool, string) {
+	namespaces := eventNamespaces(evt)
+	if len(namespaces) == 0 {
+		return true, ""
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	for _, namespace := range namespaces {
+		policy := ns.policies[namespace]
+		if policy == nil {
+			continue
+		}
+
+		if policy.Deny[evt.Kind] {
+			return false, fmt.Sprintf("blocked: kind %d is not allowed in %s", evt.Kind, namespace)
+		}
+		if len(policy.Allow) > 0 && !policy.Allow[evt.Kind] {
+			return false, fmt.Sprintf("blocked: kind %d is not allowed in %s", evt.Kind, namespace)
+		}
+
+		if policy.RateEvents > 0 {
+			pubkey := strings.ToLower(evt.PubKey)
+			limiter := policy.limiters[pubkey]
+			if limiter == nil {
+				if len(policy.limiters) >= maxNamespaceLimiters {
+					policy.limiters = make(map[string]*rate.Limiter)
+				}
+				limiter = rate.NewLimiter(rate.Every(policy.RateWindow/time.Duration(policy.RateEvents)), policy.RateEvents)
+				policy.limiters[pubkey] = limiter
+			}
+			if !limiter.Allow() {
+				return false, fmt.Sprintf("rate-limited: too many events in %s", namespace)
+			}
+		}
+	}
+
+	return true, ""
+}
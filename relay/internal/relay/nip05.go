@@ -0,0 +1,84 @@
+package relay
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/metadata"
+	"github.com/Shugur-Network/relay/internal/nip05"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-05 verification: the identifier a profile claims is resolved through
+// its domain's nostr.json. With NIP05.REQUIRE_FOR_WRITE, only authors whose
+// identifier resolves to their pubkey may publish; profiles (so an author can
+// claim one), deletions, requests to vanish and NIP-43 join requests are
+// always accepted, as are events of the relay admins.
+
+// nip05Instance is the package-level NIP-05 resolver (nil when disabled).
+var nip05Instance *nip05.Resolver
+
+// GetNIP05Resolver returns the NIP-05 resolver, or nil when verification is disabled.
+func GetNIP05Resolver() *nip05.Resolver {
+	return nip05Instance
+}
+
+// InitNIP05Resolver creates the NIP-05 resolver. Called from NewServer.
+func InitNIP05Resolver(cfg *config.Config) *nip05.Resolver {
+	if !cfg.NIP05.Enabled {
+		nip05Instance = nil
+		return nil
+	}
+	nip05Instance = nip05.New(cfg.NIP05)
+	return nip05Instance
+}
+
+// nip05ExemptKinds may be published without a verified identifier.
+var nip05ExemptKinds = map[int]bool{0: true, 5: true, 62: true, 28934: true}
+
+// checkNIP05Write returns the OK reason when evt may not be published because
+// its author has no verified NIP-05 identifier, "" when it may.
+func (c *WsConnection) checkNIP05Write(ctx context.Context, evt *nostr.Event) string {
+	r := GetNIP05Resolver()
+	if r == nil || !r.RequireForWrite() || nip05ExemptKinds[evt.Kind] {
+		return ""
+	}
+	cfg := c.node.Config()
+	pubkey := strings.ToLower(evt.PubKey)
+	if cfg.Relay.PublicKey != "" && strings.ToLower(cfg.Relay.PublicKey) == pubkey {
+		return ""
+	}
+	for _, admin := range cfg.Relay.AdminPubkeys {
+		if strings.ToLower(admin) == pubkey {
+			return ""
+		}
+	}
+
+	identifier := c.claimedNIP05(ctx, pubkey)
+	if identifier == "" {
+		return "restricted: publish a profile with a NIP-05 identifier first"
+	}
+	if v := r.Verify(ctx, identifier, pubkey); !v.Verified {
+		return "restricted: NIP-05 identifier " + v.Identifier + " is not verified: " + v.Error
+	}
+	return ""
+}
+
+// claimedNIP05 returns the NIP-05 identifier the stored profile of pubkey
+// claims, "" when it has none.
+func (c *WsConnection) claimedNIP05(ctx context.Context, pubkey string) string {
+	if cache := GetMetadataCache(); cache != nil {
+		profile, err := cache.Get(ctx, pubkey)
+		if err != nil || profile == nil {
+			return ""
+		}
+		return profile.Metadata.NIP05
+	}
+	events, err := c.node.DB().GetEvents(ctx, nostr.Filter{Kinds: []int{0}, Authors: []string{pubkey}, Limit: 1})
+	if err != nil || len(events) == 0 {
+		return ""
+	}
+	m, _ := metadata.Parse(&events[0])
+	return m.NIP05
+}
@@ -0,0 +1,192 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/nip05"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// NIP-05 hosting: with NIP05.HOSTING enabled the relay is a NIP-05 provider
+// for its community. Local names come from the config file and from the
+// NIP-86 methods setnip05name and removenip05name; both are served from
+// /.well-known/nostr.json, so name@<relay host> resolves to the mapped pubkey
+// with the name's relay hints. Names set through NIP-86 are stored in the
+// nip05_names table; those in the config file cannot be changed that way.
+
+// nip05DirectoryInstance is the package-level hosted names directory (nil when hosting is disabled).
+var nip05DirectoryInstance *nip05.Directory
+
+// GetNIP05Directory returns the hosted names directory, or nil when hosting is disabled.
+func GetNIP05Directory() *nip05.Directory {
+	return nip05DirectoryInstance
+}
+
+// InitNIP05Directory creates the hosted names directory from the config file
+// and the names stored through NIP-86. Called from NewServer.
+func InitNIP05Directory(cfg *config.Config, db *storage.DB) *nip05.Directory {
+	hosting := cfg.NIP05.Hosting
+	if !hosting.Enabled {
+		nip05DirectoryInstance = nil
+		return nil
+	}
+	log := logger.New("nip05")
+
+	relays := hosting.Relays
+	if len(relays) == 0 && cfg.Relay.PublicURL != "" {
+		relays = []string{cfg.Relay.PublicURL}
+	}
+	dir := nip05.NewDirectory(relays)
+	for _, n := range hosting.Names {
+		name := strings.ToLower(n.Name)
+		if !nip05.ValidName(name) {
+			log.Warn("Skipping invalid hosted NIP-05 name", zap.String("name", n.Name))
+			continue
+		}
+		dir.Set(nip05.Name{Name: name, Pubkey: strings.ToLower(n.Pubkey), Relays: n.Relays, Source: nip05.SourceConfig})
+	}
+
+	if db != nil {
+		names, err := db.ListNIP05Names(context.Background())
+		if err != nil {
+			log.Warn("Failed to load hosted NIP-05 names", zap.Error(err))
+		}
+		for _, n := range names {
+			if cur, ok := dir.Get(n.Name); ok && cur.Source == nip05.SourceConfig {
+				continue // the config file wins
+			}
+			dir.Set(nip05.Name{Name: n.Name, Pubkey: n.Pubkey, Relays: n.Relays, Source: nip05.SourceManaged})
+		}
+	}
+	log.Info("Hosting NIP-05 names", zap.Int("names", len(dir.List())))
+
+	nip05DirectoryInstance = dir
+	return dir
+}
+
+// handleNostrJSON serves GET /.well-known/nostr.json[?name=<name>]. Without a
+// name every hosted name is listed.
+func (s *Server) handleNostrJSON(w http.ResponseWriter, r *http.Request) {
+	// NIP-05 requires the document to be readable from web clients
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodGet {
+		errors.HandleHTTPError(w, r, errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed."))
+		return
+	}
+	dir := GetNIP05Directory()
+	if dir == nil {
+		errors.HandleHTTPError(w, r, errors.NotFoundError(r.URL.Path).
+			WithUserMessage("This relay does not host NIP-05 names."))
+		return
+	}
+
+	name := strings.ToLower(r.URL.Query().Get("name"))
+	if name != "" && !nip05.ValidName(name) {
+		writeEventsAPIError(w, r, "INVALID_NAME", "name may only contain a-z, 0-9, '-', '_' and '.'")
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeEventsAPIJSON(w, dir.Document(name))
+}
+
+// --- NIP-05 Hosting ---
+
+// mgmtSetNIP05Name maps a local name to a pubkey: params are the name, the
+// pubkey and optional relay hints.
+func (s *Server) mgmtSetNIP05Name(params []string, admin string) (interface{}, string) {
+	dir := GetNIP05Directory()
+	if dir == nil {
+		return nil, "NIP-05 hosting is disabled"
+	}
+	if len(params) < 2 {
+		return nil, "missing parameters: name and pubkey"
+	}
+	name := strings.ToLower(strings.TrimSpace(params[0]))
+	if !nip05.ValidName(name) {
+		return nil, "invalid name: may only contain a-z, 0-9, '-', '_' and '.'"
+	}
+	if cur, ok := dir.Get(name); ok && cur.Source == nip05.SourceConfig {
+		return nil, "name " + name + " is set in the config file"
+	}
+	pubkey, errMsg := parsePubkeyParam(params[1])
+	if errMsg != "" {
+		return nil, errMsg
+	}
+	var relays []string
+	for _, raw := range params[2:] {
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || (u.Scheme != "wss" && u.Scheme != "ws") || u.Host == "" {
+			return nil, "invalid relay URL: " + raw
+		}
+		relays = append(relays, nostr.NormalizeURL(u.String()))
+	}
+
+	if db := s.node.DB(); db != nil {
+		stored := storage.NIP05Name{Name: name, Pubkey: pubkey, Relays: relays, Actor: admin, CreatedAt: time.Now().Unix()}
+		if err := db.SaveNIP05Name(context.Background(), stored); err != nil {
+			logger.New("nip86").Warn("Failed to persist NIP-05 name", zap.Error(err))
+			return nil, "failed to persist name"
+		}
+	}
+	n := nip05.Name{Name: name, Pubkey: pubkey, Relays: relays, Source: nip05.SourceManaged}
+	dir.Set(n)
+
+	logger.New("nip86").Info("NIP-05 name set via management API", zap.String("name", name))
+	return n, ""
+}
+
+func (s *Server) mgmtRemoveNIP05Name(params []string) (interface{}, string) {
+	dir := GetNIP05Directory()
+	if dir == nil {
+		return nil, "NIP-05 hosting is disabled"
+	}
+	if len(params) < 1 {
+		return nil, "missing name parameter"
+	}
+	name := strings.ToLower(strings.TrimSpace(params[0]))
+	cur, ok := dir.Get(name)
+	if !ok {
+		return nil, "name " + name + " is not hosted"
+	}
+	if cur.Source == nip05.SourceConfig {
+		return nil, "name " + name + " is set in the config file"
+	}
+
+	if db := s.node.DB(); db != nil {
+		if err := db.DeleteNIP05Name(context.Background(), name); err != nil {
+			logger.New("nip86").Warn("Failed to delete NIP-05 name", zap.Error(err))
+			return nil, "failed to persist name"
+		}
+	}
+	dir.Remove(name)
+
+	logger.New("nip86").Info("NIP-05 name removed via management API", zap.String("name", name))
+	return true, ""
+}
+
+func (s *Server) mgmtListNIP05Names() (interface{}, string) {
+	dir := GetNIP05Directory()
+	if dir == nil {
+		return nil, "NIP-05 hosting is disabled"
+	}
+	return dir.List(), ""
+}
@@ -0,0 +1,132 @@
+package relay
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-19 entities in the management API: methods taking a pubkey accept hex,
+// npub or nprofile; methods taking an event ID accept hex, note, nevent or
+// naddr (the latest stored event at that address). Listings return both forms.
+
+// naddrLookupTimeout bounds resolving an naddr to a stored event.
+const naddrLookupTimeout = 5 * time.Second
+
+// pubkeyEntry is a pubkey in a management listing.
+type pubkeyEntry struct {
+	Pubkey string `json:"pubkey"`
+	Npub   string `json:"npub"`
+	decisionInfo
+}
+
+// eventEntry is an event ID in a management listing.
+type eventEntry struct {
+	ID   string `json:"id"`
+	Note string `json:"note"`
+	decisionInfo
+}
+
+// parsePubkeyParam reads a pubkey given as hex, npub or nprofile, returning
+// it as lowercase hex or an error message.
+func parsePubkeyParam(param string) (string, string) {
+	param = strings.TrimSpace(param)
+	if strings.HasPrefix(param, "npub1") || strings.HasPrefix(param, "nprofile1") {
+		_, value, err := nips.DecodeNIP19(param)
+		if err != nil {
+			return "", "invalid pubkey: " + err.Error()
+		}
+		switch v := value.(type) {
+		case string:
+			return v, ""
+		case nostr.ProfilePointer:
+			return v.PublicKey, ""
+		}
+	}
+	pubkey := strings.ToLower(param)
+	if !nostr.IsValid32ByteHex(pubkey) {
+		return "", "invalid pubkey: must be 64 hex characters, npub or nprofile"
+	}
+	return pubkey, ""
+}
+
+// parseEventIDParam reads an event ID given as hex, note, nevent or naddr,
+// returning it as lowercase hex or an error message.
+func (s *Server) parseEventIDParam(param string) (string, string) {
+	param = strings.TrimSpace(param)
+	if strings.HasPrefix(param, "note1") || strings.HasPrefix(param, "nevent1") || strings.HasPrefix(param, "naddr1") {
+		_, value, err := nips.DecodeNIP19(param)
+		if err != nil {
+			return "", "invalid event_id: " + err.Error()
+		}
+		switch v := value.(type) {
+		case string:
+			return v, ""
+		case nostr.EventPointer:
+			return v.ID, ""
+		case nostr.EntityPointer:
+			return s.resolveAddress(v)
+		}
+	}
+	eventID := strings.ToLower(param)
+	if !nostr.IsValid32ByteHex(eventID) {
+		return "", "invalid event_id: must be 64 hex characters, note, nevent or naddr"
+	}
+	return eventID, ""
+}
+
+// resolveAddress returns the ID of the latest stored event at an naddr.
+func (s *Server) resolveAddress(addr nostr.EntityPointer) (string, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), naddrLookupTimeout)
+	defer cancel()
+	events, err := s.node.DB().GetEvents(ctx, nostr.Filter{
+		Kinds:   []int{addr.Kind},
+		Authors: []string{addr.PublicKey},
+		Tags:    nostr.TagMap{"d": []string{addr.Identifier}},
+		Limit:   1,
+	})
+	if err != nil {
+		return "", "failed to resolve naddr"
+	}
+	if len(events) == 0 {
+		return "", "no stored event at naddr address"
+	}
+	return events[0].ID, ""
+}
+
+// isPubkeyEntity reports whether param is a NIP-19 pubkey entity.
+func isPubkeyEntity(param string) bool {
+	param = strings.TrimSpace(param)
+	return strings.HasPrefix(param, "npub1") || strings.HasPrefix(param, "nprofile1")
+}
+
+// npub encodes a hex pubkey as npub, or returns "" when it is not valid hex.
+func npub(pubkey string) string {
+	encoded, err := nips.EncodeNpub(pubkey)
+	if err != nil {
+		return ""
+	}
+	return encoded
+}
+
+// pubkeyEntries lists hex pubkeys with their npub form.
+func pubkeyEntries(pubkeys []string) []pubkeyEntry {
+	entries := make([]pubkeyEntry, 0, len(pubkeys))
+	for _, pk := range pubkeys {
+		entries = append(entries, pubkeyEntry{Pubkey: pk, Npub: npub(pk)})
+	}
+	return entries
+}
+
+// eventEntries lists hex event IDs with their note form.
+func eventEntries(ids []string) []eventEntry {
+	entries := make([]eventEntry, 0, len(ids))
+	for _, id := range ids {
+		note, _ := nips.EncodeNote(id)
+		entries = append(entries, eventEntry{ID: id, Note: note})
+	}
+	return entries
+}
@@ -1,20 +1,33 @@
 package relay
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Shugur-Network/relay/internal/config"
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	"github.com/Shugur-Network/relay/internal/signer"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "29", Name: "Relay Groups", KindRanges: []registry.KindRange{
+		{From: 9000, To: 9030},   // Moderation, join and leave requests
+		{From: 39000, To: 39003}, // Group metadata
+	}})
+}
+
 // NIP-29: Relay-based Groups
 // Implements relay-managed groups with membership enforcement,
 // moderation events, and relay-signed metadata.
@@ -32,17 +45,48 @@ type Group struct {
 	Restricted bool              // only members can write (previously called "closed" for writing)
 	Hidden     bool              // hide metadata from non-members
 	Closed     bool              // join requests not honored
-	InviteCodes map[string]bool  // valid invite codes
+	InviteCodes map[string]*GroupInvite // valid invite codes
 	CreatedAt  time.Time
+	Timeline   []string          // recent event IDs (oldest first), for "previous" tag checks
+	LatestAt   nostr.Timestamp   // created_at of the newest accepted group event
+	Limits     GroupLimits       // capacity and posting policy (see group_limits.go)
+	Muted      map[string]time.Time // pubkey -> end of its mute (see group_mutes.go)
+	posts      groupPosts        // recent posting activity, for Limits
+}
+
+// GroupInvite is a NIP-29 invite code, either admin-provided or relay-generated.
+type GroupInvite struct {
+	Code      string
+	CreatedBy string    // admin pubkey, or "nip86" for management API invites
+	CreatedAt time.Time
+	ExpiresAt time.Time // zero = never expires
+	MaxUses   int       // 0 = unlimited
+	UsedBy    []string  // pubkeys that redeemed the code
+}
+
+// usable reports whether the invite can still be redeemed.
+func (inv *GroupInvite) usable() bool {
+	if !inv.ExpiresAt.IsZero() && time.Now().After(inv.ExpiresAt) {
+		return false
+	}
+	return inv.MaxUses == 0 || len(inv.UsedBy) < inv.MaxUses
 }
 
+const (
+	// groupTimelineSize is how many recent event IDs are kept per group for "previous" checks.
+	groupTimelineSize = 100
+	// groupLatePublishWindow is how far behind the group's latest activity an event may be dated.
+	groupLatePublishWindow = time.Hour
+)
+
 // GroupStore manages all NIP-29 groups in memory.
 type GroupStore struct {
 	mu     sync.RWMutex
 	groups map[string]*Group // group ID -> Group
-	relayPrivateKey string  // hex-encoded secp256k1 private key for signing
-	relayPubkey     string  // hex-encoded public key
-	cfg             *config.Config
+	signer      signer.Signer        // signs relay events; nil disables signing
+	relayPubkey string               // hex-encoded public key
+	retiredKeys map[string]time.Time // previous relay pubkey -> end of its grace window
+	cfg         *config.Config
 }
 
 // groupStoreInstance is the package-level NIP-29 group store singleton.
@@ -62,30 +106,49 @@ func InitGroupStore(cfg *config.Config) *GroupStore {
 // NewGroupStore creates a new group store, initializing or generating the relay keypair.
 func NewGroupStore(cfg *config.Config) *GroupStore {
 	gs := &GroupStore{
-		groups: make(map[string]*Group),
-		cfg:    cfg,
+		groups:      make(map[string]*Group),
+		retiredKeys: make(map[string]time.Time),
+		cfg:         cfg,
 	}
 
-	// Initialize relay keypair for signing group metadata events
-	if cfg.Relay.PrivateKey != "" {
-		gs.relayPrivateKey = cfg.Relay.PrivateKey
-		// Derive public key from private key
-		pub, err := nostr.GetPublicKey(cfg.Relay.PrivateKey)
+	// Initialize relay signer for group metadata events
+	log := logger.New("nip29")
+	source := cfg.Relay.Signer.Source
+	if source != "" && source != signer.SourceConfig {
+		// The key lives outside the config; never fall back to a fresh one
+		s, err := signer.New(context.Background(), cfg.Relay)
+		if err != nil {
+			log.Error("Relay signer unavailable — relay event signing disabled",
+				zap.String("source", source),
+				zap.Error(err))
+			gs.relayPubkey = cfg.Relay.PublicKey
+		} else {
+			gs.signer = s
+			gs.relayPubkey = s.PublicKey()
+		}
+	} else if cfg.Relay.PrivateKey != "" {
+		s, err := signer.NewKeySigner(cfg.Relay.PrivateKey)
 		if err != nil {
-			logger.New("nip29").Error("Invalid relay private key, generating new one",
+			log.Error("Invalid relay private key, generating new one",
 				zap.Error(err))
 			gs.generateKeypair()
 		} else {
-			gs.relayPubkey = pub
+			gs.signer = s
+			gs.relayPubkey = s.PublicKey()
 		}
 	} else if cfg.Relay.PublicKey != "" {
 		// Public key set but no private key — cannot sign events
 		gs.relayPubkey = cfg.Relay.PublicKey
-		logger.New("nip29").Warn("Relay public key set but no private key — NIP-29 metadata signing disabled")
+		log.Warn("Relay public key set but no private key — NIP-29 metadata signing disabled")
 	} else {
 		// Auto-generate keypair
 		gs.generateKeypair()
 	}
+	if gs.signer != nil && cfg.Relay.PublicKey != "" && cfg.Relay.PublicKey != gs.relayPubkey {
+		log.Warn("Configured relay public key differs from the signing key",
+			zap.String("public_key", cfg.Relay.PublicKey),
+			zap.String("signer_pubkey", gs.relayPubkey))
+	}
 
 	// Update config with derived/generated public key
 	if gs.relayPubkey != "" && cfg.Relay.PublicKey == "" {
@@ -99,23 +162,31 @@ func NewGroupStore(cfg *config.Config) *GroupStore {
 }
 
 func (gs *GroupStore) generateKeypair() {
-	sk := nostr.GeneratePrivateKey()
-	pub, err := nostr.GetPublicKey(sk)
+	s, err := signer.NewKeySigner(nostr.GeneratePrivateKey())
 	if err != nil {
 		logger.New("nip29").Error("Failed to generate relay keypair", zap.Error(err))
 		return
 	}
-	gs.relayPrivateKey = sk
-	gs.relayPubkey = pub
+	gs.signer = s
+	gs.relayPubkey = s.PublicKey()
 	logger.New("nip29").Info("Generated new relay keypair for NIP-29",
-		zap.String("pubkey", pub))
+		zap.String("pubkey", gs.relayPubkey))
 }
 
 // GetRelayPubkey returns the relay's public key for NIP-11 "self" field.
 func (gs *GroupStore) GetRelayPubkey() string {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
 	return gs.relayPubkey
 }
 
+// relaySigner returns the signer for relay events, nil when signing is disabled.
+func (gs *GroupStore) relaySigner() signer.Signer {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.signer
+}
+
 // --- Group Operations ---
 
 // GetGroup returns a group by ID (nil if not found).
@@ -152,14 +223,191 @@ func (gs *GroupStore) IsMember(groupID, pubkey string) bool {
 func (gs *GroupStore) IsAdmin(groupID, pubkey string) bool {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
+	return gs.isAdminLocked(groupID, pubkey)
+}
+
+// --- Read Access ---
+
+// groupReadTarget returns the group an event belongs to for read access checks,
+// and whether it is relay-signed group metadata (kinds 39000-39003, keyed by "d").
+func groupReadTarget(evt *nostr.Event) (string, bool) {
+	if evt.Kind >= 39000 && evt.Kind <= 39003 {
+		return evt.Tags.GetD(), true
+	}
+	return getHTag(evt), false
+}
+
+// canReadLocked checks read access to a group's content or metadata. Caller must hold gs.mu.
+// Private groups restrict content to members; hidden groups restrict metadata to members.
+func (gs *GroupStore) canReadLocked(groupID string, metadata bool, pubkey string) bool {
 	g := gs.groups[groupID]
 	if g == nil {
+		return true
+	}
+	protected := g.Private
+	if metadata {
+		protected = g.Hidden
+	}
+	if !protected {
+		return true
+	}
+	return pubkey != "" && g.Members[pubkey]
+}
+
+// kindCreateInvite is the moderation event kind carrying invite codes. Its
+// events are readable by the group's admins only, since anyone holding one
+// of their codes can join a closed group.
+const kindCreateInvite = 9009
+
+// isAdminLocked reports whether pubkey administers a group. Caller must hold gs.mu.
+func (gs *GroupStore) isAdminLocked(groupID, pubkey string) bool {
+	g := gs.groups[groupID]
+	if g == nil || pubkey == "" {
 		return false
 	}
 	_, isAdmin := g.Admins[pubkey]
 	return isAdmin
 }
 
+// CanReadEvent reports whether pubkey (empty if unauthenticated) may read a group event.
+func (gs *GroupStore) CanReadEvent(evt *nostr.Event, pubkey string) bool {
+	groupID, metadata := groupReadTarget(evt)
+	if groupID == "" {
+		return true
+	}
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	if evt.Kind == kindCreateInvite {
+		return gs.isAdminLocked(groupID, pubkey)
+	}
+	return gs.canReadLocked(groupID, metadata, pubkey)
+}
+
+// CheckFilterAccess returns a CLOSED reason if a filter explicitly targets a private
+// or hidden group the pubkey cannot read, or "" if the filter may proceed.
+func (gs *GroupStore) CheckFilterAccess(f nostr.Filter, pubkey string) string {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	check := func(groupID string, metadata bool) string {
+		if gs.canReadLocked(groupID, metadata, pubkey) {
+			return ""
+		}
+		if pubkey == "" {
+			return "auth-required: this group is private, authenticate as a member"
+		}
+		return "restricted: not a member of this group"
+	}
+
+	for _, groupID := range f.Tags["h"] {
+		if reason := check(groupID, false); reason != "" {
+			return reason
+		}
+	}
+	if len(f.Kinds) > 0 && !slices.ContainsFunc(f.Kinds, func(k int) bool { return k != kindCreateInvite }) {
+		for _, groupID := range f.Tags["h"] {
+			if !gs.isAdminLocked(groupID, pubkey) {
+				if pubkey == "" {
+					return "auth-required: invite codes are only shown to group admins"
+				}
+				return "restricted: invite codes are only shown to group admins"
+			}
+		}
+	}
+	for _, groupID := range f.Tags["d"] {
+		for _, k := range f.Kinds {
+			if k >= 39000 && k <= 39003 {
+				if reason := check(groupID, true); reason != "" {
+					return reason
+				}
+				break
+			}
+		}
+	}
+	return ""
+}
+
+// HiddenCountFilters returns filters matching the events of f that pubkey may
+// not read: content of private groups and metadata of hidden groups it is not
+// a member of, and invites of the other groups it does not administer. REQ drops those events one by one; COUNT subtracts the counts
+// of these filters so it does not reveal them either. Filters that name their
+// groups are already gated by CheckFilterAccess.
+func (gs *GroupStore) HiddenCountFilters(f nostr.Filter, pubkey string) []nostr.Filter {
+	gs.mu.RLock()
+	var content, metadata, invites []string
+	for id, g := range gs.groups {
+		member := pubkey != "" && g.Members[pubkey]
+		if g.Private && !member {
+			content = append(content, id)
+		} else if !gs.isAdminLocked(id, pubkey) {
+			invites = append(invites, id)
+		}
+		if g.Hidden && !member {
+			metadata = append(metadata, id)
+		}
+	}
+	gs.mu.RUnlock()
+
+	var hidden []nostr.Filter
+	if len(content) > 0 && len(f.Tags["h"]) == 0 {
+		hf := f.Clone()
+		if hf.Tags == nil {
+			hf.Tags = nostr.TagMap{}
+		}
+		hf.Tags["h"] = content
+		hidden = append(hidden, hf)
+	}
+
+	if len(f.Kinds) == 0 || slices.Contains(f.Kinds, kindCreateInvite) {
+		groups := invites
+		if hs := f.Tags["h"]; len(hs) > 0 {
+			groups = nil
+			for _, id := range invites {
+				if slices.Contains(hs, id) {
+					groups = append(groups, id)
+				}
+			}
+		}
+		if len(groups) > 0 {
+			inf := f.Clone()
+			inf.Kinds = []int{kindCreateInvite}
+			if inf.Tags == nil {
+				inf.Tags = nostr.TagMap{}
+			}
+			inf.Tags["h"] = groups
+			hidden = append(hidden, inf)
+		}
+	}
+
+	if len(metadata) > 0 {
+		var kinds []int
+		for k := 39000; k <= 39003; k++ {
+			if len(f.Kinds) == 0 || slices.Contains(f.Kinds, k) {
+				kinds = append(kinds, k)
+			}
+		}
+		groups := metadata
+		if ds := f.Tags["d"]; len(ds) > 0 {
+			groups = nil
+			for _, id := range metadata {
+				if slices.Contains(ds, id) {
+					groups = append(groups, id)
+				}
+			}
+		}
+		if len(kinds) > 0 && len(groups) > 0 {
+			mf := f.Clone()
+			mf.Kinds = kinds
+			if mf.Tags == nil {
+				mf.Tags = nostr.TagMap{}
+			}
+			mf.Tags["d"] = groups
+			hidden = append(hidden, mf)
+		}
+	}
+	return hidden
+}
+
 // --- Event Processing ---
 
 // ValidateGroupEvent checks if an event targeting a NIP-29 group is valid.
@@ -180,14 +428,24 @@ func (gs *GroupStore) ValidateGroupEvent(evt *nostr.Event) (bool, string) {
 	group := gs.groups[groupID]
 	gs.mu.RUnlock()
 
+	// Reject late publication and unknown timeline references in existing groups
+	if group != nil {
+		if ok, reason := gs.validateTimeline(evt, group); !ok {
+			return false, reason
+		}
+	}
+
 	// For moderation events (9000-9009), check admin permissions
 	if evt.Kind >= 9000 && evt.Kind <= 9009 {
-		return gs.validateModerationEvent(evt, group, groupID)
+		if ok, reason := gs.validateModerationEvent(evt, group, groupID); !ok {
+			return false, reason
+		}
+		return gs.validateLimits(evt, group)
 	}
 
-	// For join requests (9021), always allow
+	// For join requests (9021), allow while the group has room
 	if evt.Kind == 9021 {
-		return true, ""
+		return gs.validateLimits(evt, group)
 	}
 
 	// For leave requests (9022), must be a member
@@ -201,6 +459,13 @@ func (gs *GroupStore) ValidateGroupEvent(evt *nostr.Event) (bool, string) {
 		return true, ""
 	}
 
+	// Muted members may not post until the mute ends
+	if group != nil {
+		if ok, reason := gs.checkMuted(evt, group); !ok {
+			return false, reason
+		}
+	}
+
 	// For regular events in managed groups, check membership
 	if group != nil && group.Restricted {
 		if !group.Members[evt.PubKey] {
@@ -208,7 +473,7 @@ func (gs *GroupStore) ValidateGroupEvent(evt *nostr.Event) (bool, string) {
 		}
 	}
 
-	return true, ""
+	return gs.validateLimits(evt, group)
 }
 
 func (gs *GroupStore) validateModerationEvent(evt *nostr.Event, group *Group, groupID string) (bool, string) {
@@ -226,16 +491,79 @@ func (gs *GroupStore) validateModerationEvent(evt *nostr.Event, group *Group, gr
 	}
 
 	// Check if sender is an admin
-	isRelayOwner := strings.ToLower(evt.PubKey) == strings.ToLower(gs.relayPubkey)
+	isRelayOwner := gs.isRelayKeyLocked(evt.PubKey)
 	_, senderIsAdmin := group.Admins[evt.PubKey]
 
 	if !isRelayOwner && !senderIsAdmin {
 		return false, "not authorized: must be group admin"
 	}
 
+	if evt.Kind == kindMuteUser {
+		return validateMute(evt, group)
+	}
+
+	return true, ""
+}
+
+// validateTimeline enforces NIP-29 late-publish prevention and "previous" tag references.
+// Each "previous" value is the first 8 hex chars of an event ID that must appear in
+// the group's recent timeline.
+func (gs *GroupStore) validateTimeline(evt *nostr.Event, group *Group) (bool, string) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	if group.LatestAt > 0 {
+		cutoff := group.LatestAt.Time().Add(-groupLatePublishWindow)
+		if evt.CreatedAt.Time().Before(cutoff) {
+			return false, nips.FormatErrorMessage(nips.ErrorCodeBlacklisted, "event is too old for this group's timeline")
+		}
+	}
+
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "previous" {
+			continue
+		}
+		for _, prefix := range tag[1:] {
+			if len(prefix) < 8 {
+				return false, nips.FormatErrorMessage(nips.ErrorCodeInvalidEvent, "'previous' reference must be at least 8 hex chars")
+			}
+			if !timelineHasPrefix(group.Timeline, strings.ToLower(prefix)) {
+				return false, nips.FormatErrorMessage(nips.ErrorCodeBlacklisted, "'previous' references an event not in the group's recent timeline")
+			}
+		}
+	}
+
 	return true, ""
 }
 
+// timelineHasPrefix checks if any event ID in the timeline starts with prefix.
+func timelineHasPrefix(timeline []string, prefix string) bool {
+	for i := len(timeline) - 1; i >= 0; i-- {
+		if strings.HasPrefix(timeline[i], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordTimeline appends an accepted event to its group's recent timeline.
+func (gs *GroupStore) recordTimeline(evt *nostr.Event, groupID string) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	group := gs.groups[groupID]
+	if group == nil {
+		return
+	}
+	group.Timeline = append(group.Timeline, evt.ID)
+	if len(group.Timeline) > groupTimelineSize {
+		group.Timeline = group.Timeline[len(group.Timeline)-groupTimelineSize:]
+	}
+	if evt.CreatedAt > group.LatestAt {
+		group.LatestAt = evt.CreatedAt
+	}
+}
+
 // ProcessGroupEvent processes a validated NIP-29 event and updates group state.
 // Returns relay-generated events to broadcast (metadata updates).
 func (gs *GroupStore) ProcessGroupEvent(evt *nostr.Event) []*nostr.Event {
@@ -256,6 +584,8 @@ func (gs *GroupStore) ProcessGroupEvent(evt *nostr.Event) []*nostr.Event {
 		relayEvents = gs.handleRemoveUser(evt, groupID, log)
 	case 9002: // edit-metadata
 		relayEvents = gs.handleEditMetadata(evt, groupID, log)
+	case kindMuteUser: // mute-user
+		relayEvents = gs.handleMuteUser(evt, groupID, log)
 	case 9005: // delete-event
 		log.Info("Delete-event request in group",
 			zap.String("group", groupID),
@@ -263,14 +593,22 @@ func (gs *GroupStore) ProcessGroupEvent(evt *nostr.Event) []*nostr.Event {
 		// Event deletion is handled by the existing NIP-09 pipeline
 	case 9008: // delete-group
 		relayEvents = gs.handleDeleteGroup(evt, groupID, log)
-	case 9009: // create-invite
-		gs.handleCreateInvite(evt, groupID, log)
+	case kindCreateInvite: // create-invite
+		relayEvents = gs.handleCreateInvite(evt, groupID, log)
 	case 9021: // join request
 		relayEvents = gs.handleJoinRequest(evt, groupID, log)
 	case 9022: // leave request
 		relayEvents = gs.handleLeaveRequest(evt, groupID, log)
 	}
 
+	// Track the event in the group's timeline (after create-group so the first event counts)
+	if evt.Kind != 9008 {
+		gs.recordTimeline(evt, groupID)
+	}
+	if isGroupPost(evt) {
+		gs.recordPost(evt, groupID)
+	}
+
 	return relayEvents
 }
 
@@ -290,7 +628,7 @@ func (gs *GroupStore) handleCreateGroup(evt *nostr.Event, groupID string, log *z
 		Members:     map[string]bool{evt.PubKey: true},
 		Admins:      map[string][]string{evt.PubKey: {"admin"}},
 		Roles:       map[string]string{"admin": "Full group control", "moderator": "Can delete messages and remove users"},
-		InviteCodes: make(map[string]bool),
+		InviteCodes: make(map[string]*GroupInvite),
 		CreatedAt:   time.Now(),
 	}
 
@@ -309,6 +647,8 @@ func (gs *GroupStore) handleCreateGroup(evt *nostr.Event, groupID string, log *z
 			group.About = tag[1]
 		case len(tag) >= 2 && tag[0] == "picture":
 			group.Picture = tag[1]
+		case len(tag) >= 2 && isGroupLimitTag(tag[0]):
+			_ = applyGroupLimitTag(&group.Limits, tag) // values checked by validateLimits
 		}
 	}
 
@@ -376,6 +716,7 @@ func (gs *GroupStore) handleRemoveUser(evt *nostr.Event, groupID string, log *za
 		targetPubkey := tag[1]
 		delete(group.Members, targetPubkey)
 		delete(group.Admins, targetPubkey)
+		delete(group.Muted, targetPubkey)
 
 		log.Info("User removed from group",
 			zap.String("group", groupID),
@@ -427,6 +768,8 @@ func (gs *GroupStore) handleEditMetadata(evt *nostr.Event, groupID string, log *
 			group.Hidden = false
 		case "unrestricted":
 			group.Restricted = false
+		case tagMaxMembers, tagRateLimit, tagMaxLength, tagSlowMode:
+			_ = applyGroupLimitTag(&group.Limits, tag) // values checked by validateLimits
 		}
 	}
 
@@ -447,24 +790,122 @@ func (gs *GroupStore) handleDeleteGroup(evt *nostr.Event, groupID string, log *z
 	return nil
 }
 
-func (gs *GroupStore) handleCreateInvite(evt *nostr.Event, groupID string, log *zap.Logger) {
+// handleCreateInvite records an admin-provided invite code, or when the event carries
+// no "code" tag, has the relay generate one. Optional tags: ["ttl", "<seconds>"] and
+// ["max_uses", "<n>"]. Relay-generated codes are returned in a relay-signed kind 9009 event.
+// Both carry the code, so CanReadEvent shows them to group admins only.
+func (gs *GroupStore) handleCreateInvite(evt *nostr.Event, groupID string, log *zap.Logger) []*nostr.Event {
+	var ttl time.Duration
+	maxUses := 1
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "ttl":
+			if secs, err := strconv.Atoi(tag[1]); err == nil && secs > 0 {
+				ttl = time.Duration(secs) * time.Second
+			}
+		case "max_uses":
+			if n, err := strconv.Atoi(tag[1]); err == nil && n >= 0 {
+				maxUses = n
+			}
+		}
+	}
+
+	// Admin-provided codes
+	if codeTag := evt.Tags.GetFirst([]string{"code", ""}); codeTag != nil && len(*codeTag) >= 2 {
+		gs.mu.Lock()
+		defer gs.mu.Unlock()
+
+		group := gs.groups[groupID]
+		if group == nil {
+			return nil
+		}
+		invite := newGroupInvite((*codeTag)[1], evt.PubKey, ttl, maxUses)
+		group.InviteCodes[invite.Code] = invite
+		log.Info("Invite code created for group",
+			zap.String("group", groupID),
+			zap.String("code", codePrefix(invite.Code)))
+		return nil
+	}
+
+	_, inviteEvt, err := gs.CreateGroupInvite(groupID, evt.PubKey, ttl, maxUses)
+	if err != nil {
+		log.Warn("Failed to generate invite code",
+			zap.String("group", groupID),
+			zap.Error(err))
+		return nil
+	}
+	return []*nostr.Event{inviteEvt}
+}
+
+// CreateGroupInvite generates a random invite code for a group and returns it
+// along with a relay-signed kind 9009 event announcing it to the group's admins.
+// ttl of 0 never expires; maxUses of 0 allows unlimited redemptions.
+func (gs *GroupStore) CreateGroupInvite(groupID, createdBy string, ttl time.Duration, maxUses int) (*GroupInvite, *nostr.Event, error) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
 
 	group := gs.groups[groupID]
 	if group == nil {
-		return
+		return nil, nil, fmt.Errorf("group not found: %s", groupID)
+	}
+	if gs.signer == nil {
+		return nil, nil, fmt.Errorf("relay signer not configured")
 	}
 
-	// Extract invite code from tags
-	for _, tag := range evt.Tags {
-		if tag[0] == "code" && len(tag) >= 2 {
-			group.InviteCodes[tag[1]] = true
-			log.Info("Invite code created for group",
-				zap.String("group", groupID),
-				zap.String("code", tag[1][:8]+"..."))
-		}
+	invite := newGroupInvite(generateRandomCode(16), createdBy, ttl, maxUses)
+	group.InviteCodes[invite.Code] = invite
+
+	tags := nostr.Tags{
+		{"h", groupID},
+		{"code", invite.Code},
+		{"max_uses", strconv.Itoa(maxUses)},
+	}
+	if !invite.ExpiresAt.IsZero() {
+		tags = append(tags, nostr.Tag{"expires_at", strconv.FormatInt(invite.ExpiresAt.Unix(), 10)})
 	}
+	if nostr.IsValidPublicKey(createdBy) {
+		tags = append(tags, nostr.Tag{"p", createdBy})
+	}
+
+	evt := gs.signRelayEventLocked(&nostr.Event{
+		Kind:      kindCreateInvite,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Tags:      tags,
+	})
+
+	logger.New("nip29").Info("Relay-generated invite code created",
+		zap.String("group", groupID),
+		zap.String("code", codePrefix(invite.Code)),
+		zap.Duration("ttl", ttl),
+		zap.Int("max_uses", maxUses))
+
+	return invite, evt, nil
+}
+
+// newGroupInvite builds an invite with the given code and limits.
+func newGroupInvite(code, createdBy string, ttl time.Duration, maxUses int) *GroupInvite {
+	now := time.Now()
+	invite := &GroupInvite{
+		Code:      code,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		MaxUses:   maxUses,
+	}
+	if ttl > 0 {
+		invite.ExpiresAt = now.Add(ttl)
+	}
+	return invite
+}
+
+// codePrefix truncates an invite code for logging.
+func codePrefix(code string) string {
+	if len(code) > 8 {
+		return code[:8] + "..."
+	}
+	return code
 }
 
 func (gs *GroupStore) handleJoinRequest(evt *nostr.Event, groupID string, log *zap.Logger) []*nostr.Event {
@@ -491,14 +932,26 @@ func (gs *GroupStore) handleJoinRequest(evt *nostr.Event, groupID string, log *z
 	if group.Closed {
 		// Check for invite code
 		codeTag := evt.Tags.GetFirst([]string{"code", ""})
-		if codeTag == nil || len(*codeTag) < 2 || !group.InviteCodes[(*codeTag)[1]] {
+		var invite *GroupInvite
+		if codeTag != nil && len(*codeTag) >= 2 {
+			invite = group.InviteCodes[(*codeTag)[1]]
+		}
+		if invite == nil || !invite.usable() {
 			log.Debug("Join request rejected (closed group, no valid invite)",
 				zap.String("group", groupID),
 				zap.String("user", evt.PubKey[:16]+"..."))
 			return nil
 		}
-		// Consume invite code
-		delete(group.InviteCodes, (*codeTag)[1])
+		// Consume invite code, dropping it once exhausted
+		invite.UsedBy = append(invite.UsedBy, evt.PubKey)
+		if !invite.usable() {
+			delete(group.InviteCodes, invite.Code)
+		}
+		log.Info("Invite code redeemed",
+			zap.String("group", groupID),
+			zap.String("code", codePrefix(invite.Code)),
+			zap.Int("uses", len(invite.UsedBy)),
+			zap.Int("max_uses", invite.MaxUses))
 	}
 
 	// Accept: add member
@@ -565,7 +1018,7 @@ func (gs *GroupStore) handleLeaveRequest(evt *nostr.Event, groupID string, log *
 // generateGroupMetadataLocked generates kinds 39000, 39001, 39002, 39003 for a group.
 // Must be called with gs.mu held.
 func (gs *GroupStore) generateGroupMetadataLocked(group *Group) []*nostr.Event {
-	if gs.relayPrivateKey == "" {
+	if gs.signer == nil {
 		return nil
 	}
 
@@ -595,6 +1048,7 @@ func (gs *GroupStore) generateGroupMetadataLocked(group *Group) []*nostr.Event {
 	if group.Closed {
 		metaTags = append(metaTags, nostr.Tag{"closed"})
 	}
+	metaTags = append(metaTags, group.Limits.tags()...)
 
 	metaEvt := gs.signRelayEventLocked(&nostr.Event{
 		Kind:      39000,
@@ -608,11 +1062,19 @@ func (gs *GroupStore) generateGroupMetadataLocked(group *Group) []*nostr.Event {
 
 	// kind 39001 — group admins
 	adminTags := nostr.Tags{{"d", group.ID}}
-	for pubkey, roles := range group.Admins {
+	for pubkey := range group.Admins {
 		tag := nostr.Tag{"p", pubkey}
-		tag = append(tag, roles...)
+		tag = append(tag, metadataRoles(group, pubkey)...)
 		adminTags = append(adminTags, tag)
 	}
+	for pubkey := range group.Muted {
+		if _, isAdmin := group.Admins[pubkey]; isAdmin {
+			continue
+		}
+		if _, muted := group.mutedUntil(pubkey); muted {
+			adminTags = append(adminTags, nostr.Tag{"p", pubkey, roleMuted})
+		}
+	}
 	adminsEvt := gs.signRelayEventLocked(&nostr.Event{
 		Kind:      39001,
 		CreatedAt: now,
@@ -643,6 +1105,9 @@ func (gs *GroupStore) generateGroupMetadataLocked(group *Group) []*nostr.Event {
 	for roleName, roleDesc := range group.Roles {
 		roleTags = append(roleTags, nostr.Tag{"role", roleName, roleDesc})
 	}
+	if _, ok := group.Roles[roleMuted]; !ok {
+		roleTags = append(roleTags, nostr.Tag{"role", roleMuted, roleMutedDescription})
+	}
 	rolesEvt := gs.signRelayEventLocked(&nostr.Event{
 		Kind:      39003,
 		CreatedAt: now,
@@ -656,14 +1121,13 @@ func (gs *GroupStore) generateGroupMetadataLocked(group *Group) []*nostr.Event {
 	return events
 }
 
-// signRelayEventLocked signs an event with the relay's private key.
+// signRelayEventLocked signs an event with the relay's signer.
 // Must be called with gs.mu held (or from a safe context).
 func (gs *GroupStore) signRelayEventLocked(evt *nostr.Event) *nostr.Event {
-	if gs.relayPrivateKey == "" {
+	if gs.signer == nil {
 		return nil
 	}
-	evt.PubKey = gs.relayPubkey
-	if err := evt.Sign(gs.relayPrivateKey); err != nil {
+	if err := gs.signer.Sign(context.Background(), evt); err != nil {
 		logger.New("nip29").Error("Failed to sign relay event",
 			zap.Int("kind", evt.Kind),
 			zap.Error(err))
@@ -0,0 +1,104 @@
+package relay
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// TestInviteReadAccess checks that the kind 9009 events carrying invite
+// codes of a closed group reach its admins and nobody else.
+func TestInviteReadAccess(t *testing.T) {
+	admin := strings.Repeat("aa", 32)
+	member := strings.Repeat("bb", 32)
+	stranger := strings.Repeat("cc", 32)
+
+	gs := &GroupStore{groups: map[string]*Group{
+		"club": {
+			ID:      "club",
+			Members: map[string]bool{admin: true, member: true},
+			Admins:  map[string][]string{admin: {"admin"}},
+			Closed:  true,
+		},
+	}}
+	prev := groupStoreInstance
+	groupStoreInstance = gs
+	t.Cleanup(func() { groupStoreInstance = prev })
+
+	invite := &nostr.Event{
+		Kind: kindCreateInvite,
+		Tags: nostr.Tags{{"h", "club"}, {"code", "secret-code"}},
+	}
+	post := &nostr.Event{Kind: 9, Tags: nostr.Tags{{"h", "club"}}}
+
+	for _, tc := range []struct {
+		name   string
+		pubkey string
+		invite bool
+	}{
+		{"admin", admin, true},
+		{"member", member, false},
+		{"stranger", stranger, false},
+		{"unauthenticated", "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &WsConnection{authedPubkeys: map[string]bool{}}
+			if tc.pubkey != "" {
+				c.authedPubkeys[tc.pubkey] = true
+			}
+			if got := c.canReadGroupEvent(invite); got != tc.invite {
+				t.Errorf("REQ sees invite = %v, want %v", got, tc.invite)
+			}
+			if !c.canReadGroupEvent(post) {
+				t.Error("REQ does not see a public group post")
+			}
+
+			reason := gs.CheckFilterAccess(nostr.Filter{
+				Kinds: []int{kindCreateInvite},
+				Tags:  nostr.TagMap{"h": {"club"}},
+			}, tc.pubkey)
+			if (reason == "") != tc.invite {
+				t.Errorf("invite filter reason = %q", reason)
+			}
+			if reason := gs.CheckFilterAccess(nostr.Filter{Kinds: []int{9, kindCreateInvite}, Tags: nostr.TagMap{"h": {"club"}}}, tc.pubkey); reason != "" {
+				t.Errorf("mixed filter reason = %q, want none", reason)
+			}
+
+			hidesInvites := slices.ContainsFunc(gs.HiddenCountFilters(nostr.Filter{}, tc.pubkey), func(f nostr.Filter) bool {
+				return slices.Equal(f.Kinds, []int{kindCreateInvite}) && slices.Equal(f.Tags["h"], []string{"club"})
+			})
+			if hidesInvites == tc.invite {
+				t.Errorf("COUNT hides invites = %v, want %v", hidesInvites, !tc.invite)
+			}
+		})
+	}
+}
+
+// TestTimelineRejectionPrefixes checks that timeline rejections carry the
+// machine-readable prefixes of NIP-01.
+func TestTimelineRejectionPrefixes(t *testing.T) {
+	gs := &GroupStore{}
+	group := &Group{ID: "club", Timeline: []string{strings.Repeat("ab", 32)}, LatestAt: 1_700_000_000}
+
+	for _, tc := range []struct {
+		name   string
+		evt    *nostr.Event
+		prefix string
+	}{
+		{"late", &nostr.Event{CreatedAt: 1_600_000_000}, "blocked: "},
+		{"short previous", &nostr.Event{CreatedAt: 1_700_000_000, Tags: nostr.Tags{{"previous", "abab"}}}, "invalid: "},
+		{"unknown previous", &nostr.Event{CreatedAt: 1_700_000_000, Tags: nostr.Tags{{"previous", "cdcdcdcd"}}}, "blocked: "},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, reason := gs.validateTimeline(tc.evt, group)
+			if ok || !strings.HasPrefix(reason, tc.prefix) {
+				t.Errorf("validateTimeline = %v, %q, want a %q rejection", ok, reason, tc.prefix)
+			}
+		})
+	}
+	if ok, reason := gs.validateTimeline(&nostr.Event{CreatedAt: 1_700_000_000, Tags: nostr.Tags{{"previous", "abababab"}}}, group); !ok {
+		t.Errorf("known previous rejected: %q", reason)
+	}
+}
@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
@@ -9,11 +10,18 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Shugur-Network/relay/internal/config"
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	"github.com/Shugur-Network/relay/internal/storage"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "43", Name: "Relay Access", Kinds: []int{13534, 8000, 8001, 28934, 28935, 28936, 10010}})
+}
+
 // NIP-43: Relay Access Metadata and Requests
 // https://github.com/nostr-protocol/nips/blob/master/43.md
 //
@@ -26,11 +34,19 @@ import (
 //   28936 — Leave request (user-sent)
 //   10010 — Relay membership list (user-signed, replaceable)
 
+// inviteClaimRetention is how long an expired invite code stays in the
+// database, so the invite statistics cover recently expired codes.
+const inviteClaimRetention = 24 * time.Hour
+
 // MembershipStore manages NIP-43 relay membership and invite codes.
+// Issued codes are also stored in the database (see LoadFromDB), where a
+// redemption is recorded once and survives restarts.
 type MembershipStore struct {
 	mu          sync.RWMutex
 	members     map[string]time.Time // pubkey -> joined at
 	inviteCodes map[string]*InviteCode
+	db          *storage.DB // nil until LoadFromDB; invite codes are then kept in memory only
+	replays     int         // redemptions refused because the code was already used
 }
 
 // InviteCode represents a relay invite claim string.
@@ -40,6 +56,17 @@ type InviteCode struct {
 	ExpiresAt time.Time
 	UsedBy    string // pubkey that redeemed it, empty if unused
 	CreatedBy string // admin pubkey that generated it (empty if relay-auto)
+	stored    bool   // recorded in the invite_claims table
+}
+
+// InviteStats counts the invite codes issued by the relay.
+type InviteStats struct {
+	Issued          int  `json:"issued"`
+	Active          int  `json:"active"` // unused and unexpired
+	Redeemed        int  `json:"redeemed"`
+	Expired         int  `json:"expired"` // expired unused
+	ReplaysRejected int  `json:"replays_rejected"`
+	Persistent      bool `json:"persistent"` // counts come from the database
 }
 
 var (
@@ -115,6 +142,19 @@ func (ms *MembershipStore) GenerateInviteCode(createdBy string, ttl time.Duratio
 		CreatedBy: createdBy,
 	}
 
+	ms.mu.RLock()
+	db := ms.db
+	ms.mu.RUnlock()
+	if db != nil {
+		claim := storage.InviteClaim{Code: code, CreatedBy: createdBy, CreatedAt: now.Unix(), ExpiresAt: invite.ExpiresAt.Unix()}
+		if err := db.SaveInviteClaim(context.Background(), claim); err != nil {
+			// The code still works until the next restart
+			logger.New("nip43").Warn("Failed to persist invite code", zap.Error(err))
+		} else {
+			invite.stored = true
+		}
+	}
+
 	ms.mu.Lock()
 	ms.inviteCodes[code] = invite
 	ms.mu.Unlock()
@@ -145,22 +185,52 @@ func (ms *MembershipStore) ValidateInviteCode(code string) error {
 	return nil
 }
 
-// RedeemInviteCode marks an invite code as used by a pubkey.
+// RedeemInviteCode marks an invite code as used by a pubkey. Stored codes are
+// also consumed in the database, which refuses a second redemption even when
+// this store has forgotten the first one.
 func (ms *MembershipStore) RedeemInviteCode(code, pubkey string) error {
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
+	pubkey = strings.ToLower(pubkey)
 
+	ms.mu.Lock()
 	invite, ok := ms.inviteCodes[code]
 	if !ok {
+		ms.mu.Unlock()
 		return fmt.Errorf("restricted: that is an invalid invite code")
 	}
 	if invite.UsedBy != "" {
+		ms.replays++
+		ms.mu.Unlock()
 		return fmt.Errorf("restricted: that invite code has already been used")
 	}
-	if time.Now().After(invite.ExpiresAt) {
+	now := time.Now()
+	if now.After(invite.ExpiresAt) {
+		ms.mu.Unlock()
 		return fmt.Errorf("restricted: that invite code is expired")
 	}
-	invite.UsedBy = strings.ToLower(pubkey)
+	// Claim the code before the database round trip so a concurrent
+	// redemption is refused here
+	invite.UsedBy = pubkey
+	db := ms.db
+	stored := invite.stored
+	ms.mu.Unlock()
+
+	if db == nil || !stored {
+		return nil
+	}
+	consumed, err := db.ConsumeInviteClaim(context.Background(), code, pubkey, now.Unix())
+	if err != nil {
+		logger.New("nip43").Warn("Failed to record invite redemption", zap.Error(err))
+		ms.mu.Lock()
+		invite.UsedBy = ""
+		ms.mu.Unlock()
+		return fmt.Errorf("error: could not redeem invite code, try again later")
+	}
+	if !consumed {
+		ms.mu.Lock()
+		ms.replays++
+		ms.mu.Unlock()
+		return fmt.Errorf("restricted: that invite code has already been used")
+	}
 	return nil
 }
 
@@ -179,6 +249,180 @@ func (ms *MembershipStore) CleanExpired() int {
 	return count
 }
 
+// InviteStats counts the issued invite codes. With a database the counts
+// cover every code still stored, including those issued before a restart.
+func (ms *MembershipStore) InviteStats(ctx context.Context) (InviteStats, error) {
+	ms.mu.RLock()
+	db := ms.db
+	stats := InviteStats{ReplaysRejected: ms.replays}
+	var claims []storage.InviteClaim
+	if db == nil {
+		for _, invite := range ms.inviteCodes {
+			claims = append(claims, storage.InviteClaim{ExpiresAt: invite.ExpiresAt.Unix(), UsedBy: invite.UsedBy})
+		}
+	}
+	ms.mu.RUnlock()
+
+	if db != nil {
+		var err error
+		if claims, err = db.ListInviteClaims(ctx, 0); err != nil {
+			return InviteStats{}, err
+		}
+		stats.Persistent = true
+	}
+
+	now := time.Now().Unix()
+	for _, c := range claims {
+		stats.Issued++
+		switch {
+		case c.UsedBy != "":
+			stats.Redeemed++
+		case c.ExpiresAt <= now:
+			stats.Expired++
+		default:
+			stats.Active++
+		}
+	}
+	return stats, nil
+}
+
+// pruneInviteClaims deletes the stored invite codes expired for longer than
+// inviteClaimRetention.
+func (ms *MembershipStore) pruneInviteClaims(ctx context.Context) (int, error) {
+	ms.mu.RLock()
+	db := ms.db
+	ms.mu.RUnlock()
+	if db == nil {
+		return 0, nil
+	}
+	return db.PruneInviteClaims(ctx, time.Now().Add(-inviteClaimRetention).Unix())
+}
+
+// LoadFromDB restores the unexpired invite codes and the membership from the
+// latest relay-signed kind 13534 list, and keeps db for recording invite
+// codes from then on.
+func (ms *MembershipStore) LoadFromDB(ctx context.Context, db *storage.DB, relayPubkey string) {
+	if db == nil {
+		return
+	}
+	ms.loadInviteCodes(ctx, db)
+	if relayPubkey == "" {
+		return
+	}
+	evt, err := db.GetReplaceableEvent(ctx, relayPubkey, 13534)
+	if err != nil || evt.ID == "" {
+		return
+	}
+
+	ms.mu.Lock()
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "member" {
+			ms.members[strings.ToLower(tag[1])] = evt.CreatedAt.Time()
+		}
+	}
+	count := len(ms.members)
+	ms.mu.Unlock()
+
+	logger.New("nip43").Info("Restored NIP-43 membership list",
+		zap.Int("members", count))
+}
+
+// loadInviteCodes restores the invite codes that have not expired, used ones
+// included so their redemption is still refused.
+func (ms *MembershipStore) loadInviteCodes(ctx context.Context, db *storage.DB) {
+	ms.mu.Lock()
+	ms.db = db
+	ms.mu.Unlock()
+
+	claims, err := db.ListInviteClaims(ctx, time.Now().Unix())
+	if err != nil {
+		logger.New("nip43").Warn("Failed to load invite codes", zap.Error(err))
+		return
+	}
+
+	ms.mu.Lock()
+	for _, c := range claims {
+		ms.inviteCodes[c.Code] = &InviteCode{
+			Code:      c.Code,
+			CreatedAt: time.Unix(c.CreatedAt, 0),
+			ExpiresAt: time.Unix(c.ExpiresAt, 0),
+			UsedBy:    c.UsedBy,
+			CreatedBy: c.CreatedBy,
+			stored:    true,
+		}
+	}
+	ms.mu.Unlock()
+
+	if len(claims) > 0 {
+		logger.New("nip43").Info("Restored NIP-43 invite codes",
+			zap.Int("codes", len(claims)))
+	}
+}
+
+// membersOnly reports whether only members may publish: ACCESS_MODE members
+// or private.
+func membersOnly(cfg *config.Config) bool {
+	return cfg.Relay.AccessMode == "members" || cfg.Relay.AccessMode == "private"
+}
+
+// checkReadAccess returns the CLOSED reason when the connection may not read:
+// AUTH_REQUIRED relays need NIP-42 AUTH first, private relays a member.
+func (c *WsConnection) checkReadAccess() string {
+	cfg := c.node.Config()
+	private := cfg.Relay.AccessMode == "private"
+	if !cfg.Relay.AuthRequired && !private {
+		return ""
+	}
+	authedPK := c.getAuthenticatedPubkey()
+	if authedPK == "" {
+		return "auth-required: this relay requires authentication"
+	}
+	if private && !GetMembershipStore().CanWrite(&nostr.Event{PubKey: authedPK}, cfg) {
+		return "restricted: this relay is private to its members"
+	}
+	return ""
+}
+
+// CanWrite checks if an event may be published on a members-only relay.
+// Members, the relay itself and relay admins may write; anyone may send a join request.
+func (ms *MembershipStore) CanWrite(evt *nostr.Event, cfg *config.Config) bool {
+	if evt.Kind == 28934 {
+		return true
+	}
+	pubkey := strings.ToLower(evt.PubKey)
+	if cfg.Relay.PublicKey != "" && strings.ToLower(cfg.Relay.PublicKey) == pubkey {
+		return true
+	}
+	for _, admin := range cfg.Relay.AdminPubkeys {
+		if strings.ToLower(admin) == pubkey {
+			return true
+		}
+	}
+	return ms.IsMember(pubkey)
+}
+
+// cleanExpiredInvites periodically removes expired and used invite codes from
+// memory and long-expired ones from the database.
+func cleanExpiredInvites(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := GetMembershipStore().CleanExpired(); n > 0 {
+				logger.New("nip43").Debug("Removed expired invite codes", zap.Int("count", n))
+			}
+			if n, err := GetMembershipStore().pruneInviteClaims(ctx); err != nil {
+				logger.New("nip43").Warn("Failed to prune invite codes", zap.Error(err))
+			} else if n > 0 {
+				logger.New("nip43").Debug("Pruned stored invite codes", zap.Int("count", n))
+			}
+		}
+	}
+}
+
 // --- NIP-43 Event Handling ---
 
 // IsNIP43Event returns true if the event kind is a NIP-43 kind.
@@ -208,7 +452,7 @@ func (ms *MembershipStore) HandleNIP43Event(evt *nostr.Event) (bool, string, []*
 		return true, "", nil
 	case 13534, 8000, 8001:
 		// Relay-signed events — only accept from relay's own pubkey
-		if strings.ToLower(evt.PubKey) != strings.ToLower(gs.GetRelayPubkey()) {
+		if !gs.IsRelayKey(evt.PubKey) {
 			return false, "restricted: only relay can publish kind " + fmt.Sprintf("%d", evt.Kind), nil
 		}
 		return true, "", nil
@@ -320,7 +564,11 @@ func (ms *MembershipStore) handleLeaveRequest(evt *nostr.Event, gs *GroupStore)
 
 // GenerateInviteEvent creates a kind 28935 ephemeral invite event for a requesting user.
 func (ms *MembershipStore) GenerateInviteEvent(gs *GroupStore, ttl time.Duration) *nostr.Event {
-	if gs == nil || gs.relayPrivateKey == "" {
+	if gs == nil {
+		return nil
+	}
+	s := gs.relaySigner()
+	if s == nil {
 		return nil
 	}
 
@@ -328,7 +576,6 @@ func (ms *MembershipStore) GenerateInviteEvent(gs *GroupStore, ttl time.Duration
 
 	evt := &nostr.Event{
 		Kind:      28935,
-		PubKey:    gs.relayPubkey,
 		CreatedAt: nostr.Timestamp(time.Now().Unix()),
 		Tags: nostr.Tags{
 			{"claim", invite.Code},
@@ -337,7 +584,7 @@ func (ms *MembershipStore) GenerateInviteEvent(gs *GroupStore, ttl time.Duration
 		Content: "",
 	}
 
-	if err := evt.Sign(gs.relayPrivateKey); err != nil {
+	if err := s.Sign(context.Background(), evt); err != nil {
 		logger.New("nip43").Error("Failed to sign invite event", zap.Error(err))
 		return nil
 	}
@@ -349,13 +596,13 @@ func (ms *MembershipStore) GenerateInviteEvent(gs *GroupStore, ttl time.Duration
 
 // createAddUserEvent creates a kind 8000 relay-signed event for adding a member.
 func (ms *MembershipStore) createAddUserEvent(pubkey string, gs *GroupStore) *nostr.Event {
-	if gs.relayPrivateKey == "" {
+	s := gs.relaySigner()
+	if s == nil {
 		return nil
 	}
 
 	evt := &nostr.Event{
 		Kind:      8000,
-		PubKey:    gs.relayPubkey,
 		CreatedAt: nostr.Timestamp(time.Now().Unix()),
 		Tags: nostr.Tags{
 			{"-"},
@@ -364,7 +611,7 @@ func (ms *MembershipStore) createAddUserEvent(pubkey string, gs *GroupStore) *no
 		Content: "",
 	}
 
-	if err := evt.Sign(gs.relayPrivateKey); err != nil {
+	if err := s.Sign(context.Background(), evt); err != nil {
 		logger.New("nip43").Error("Failed to sign add-user event", zap.Error(err))
 		return nil
 	}
@@ -374,13 +621,13 @@ func (ms *MembershipStore) createAddUserEvent(pubkey string, gs *GroupStore) *no
 
 // createRemoveUserEvent creates a kind 8001 relay-signed event for removing a member.
 func (ms *MembershipStore) createRemoveUserEvent(pubkey string, gs *GroupStore) *nostr.Event {
-	if gs.relayPrivateKey == "" {
+	s := gs.relaySigner()
+	if s == nil {
 		return nil
 	}
 
 	evt := &nostr.Event{
 		Kind:      8001,
-		PubKey:    gs.relayPubkey,
 		CreatedAt: nostr.Timestamp(time.Now().Unix()),
 		Tags: nostr.Tags{
 			{"-"},
@@ -389,7 +636,7 @@ func (ms *MembershipStore) createRemoveUserEvent(pubkey string, gs *GroupStore)
 		Content: "",
 	}
 
-	if err := evt.Sign(gs.relayPrivateKey); err != nil {
+	if err := s.Sign(context.Background(), evt); err != nil {
 		logger.New("nip43").Error("Failed to sign remove-user event", zap.Error(err))
 		return nil
 	}
@@ -399,7 +646,8 @@ func (ms *MembershipStore) createRemoveUserEvent(pubkey string, gs *GroupStore)
 
 // createMembershipListEvent creates a kind 13534 relay-signed membership list event.
 func (ms *MembershipStore) createMembershipListEvent(gs *GroupStore) *nostr.Event {
-	if gs.relayPrivateKey == "" {
+	s := gs.relaySigner()
+	if s == nil {
 		return nil
 	}
 
@@ -412,13 +660,12 @@ func (ms *MembershipStore) createMembershipListEvent(gs *GroupStore) *nostr.Even
 
 	evt := &nostr.Event{
 		Kind:      13534,
-		PubKey:    gs.relayPubkey,
 		CreatedAt: nostr.Timestamp(time.Now().Unix()),
 		Tags:      tags,
 		Content:   "",
 	}
 
-	if err := evt.Sign(gs.relayPrivateKey); err != nil {
+	if err := s.Sign(context.Background(), evt); err != nil {
 		logger.New("nip43").Error("Failed to sign membership list event", zap.Error(err))
 		return nil
 	}
@@ -442,3 +689,17 @@ func generateRandomCode(byteLen int) string {
 	_, _ = rand.Read(b)
 	return hex.EncodeToString(b)
 }
+
+// --- Invite Statistics ---
+
+// mgmtGetInviteStats reports how many NIP-43 invite codes were issued,
+// redeemed and left to expire, and how many redemptions were refused as
+// replays.
+func (s *Server) mgmtGetInviteStats() (interface{}, string) {
+	stats, err := GetMembershipStore().InviteStats(context.Background())
+	if err != nil {
+		logger.New("nip86").Warn("Failed to count invite codes", zap.Error(err))
+		return nil, "failed to count invite codes"
+	}
+	return stats, ""
+}
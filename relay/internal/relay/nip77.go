@@ -9,12 +9,17 @@ import (
 
 	"github.com/Shugur-Network/relay/internal/logger"
 	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip77/negentropy"
 	"github.com/nbd-wtf/go-nostr/nip77/negentropy/storage/vector"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "77", Name: "Negentropy Sync"})
+}
+
 const (
 	maxNegSessions       = 5              // Max concurrent negentropy sessions per connection
 	maxNegRecords        = 500000         // Max records to process per session
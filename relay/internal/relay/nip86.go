@@ -1,9 +1,6 @@
 package relay
 
 import (
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,11 +11,19 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Shugur-Network/relay/internal/audit"
+	"github.com/Shugur-Network/relay/internal/clientip"
 	"github.com/Shugur-Network/relay/internal/logger"
-	nostr "github.com/nbd-wtf/go-nostr"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	"github.com/Shugur-Network/relay/internal/storage"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "86", Name: "Management API"})
+}
+
 // NIP-86: Relay Management API
 // JSON-RPC over HTTP with NIP-98 Authorization
 
@@ -34,17 +39,23 @@ type managementResponse struct {
 	Error  string      `json:"error,omitempty"`
 }
 
-// managementState holds in-memory state for NIP-86 management operations
-// that don't map to existing relay infrastructure.
+// managementState holds the NIP-86 decisions in force, persisted in the
+// moderation_decisions table (see nip86_state.go). Pubkey bans are enforced
+// by the validator blacklist, IP blocks by the client ban list and range
+// blocks by ipBlocks.
 type managementState struct {
-	mu           sync.RWMutex
-	bannedEvents map[string]bool // event ID -> banned
-	blockedIPs   map[string]bool // IP -> blocked (permanent via management)
+	mu            sync.RWMutex
+	bannedPubkeys map[string]storage.ModerationDecision // pubkey -> ban
+	bannedEvents  map[string]storage.ModerationDecision // event ID -> ban
+	blockedIPs    map[string]storage.ModerationDecision // IP -> block
+	blockedCIDRs  map[string]storage.ModerationDecision // canonical CIDR -> block
 }
 
 var mgmtState = &managementState{
-	bannedEvents: make(map[string]bool),
-	blockedIPs:   make(map[string]bool),
+	bannedPubkeys: make(map[string]storage.ModerationDecision),
+	bannedEvents:  make(map[string]storage.ModerationDecision),
+	blockedIPs:    make(map[string]storage.ModerationDecision),
+	blockedCIDRs:  make(map[string]storage.ModerationDecision),
 }
 
 // nip86SupportedMethods lists all implemented NIP-86 methods.
@@ -66,6 +77,31 @@ var nip86SupportedMethods = []string{
 	"blockip",
 	"unblockip",
 	"listblockedips",
+	"blockcidr",
+	"unblockcidr",
+	"listblockedcidrs",
+	"creategroupinvite",
+	"rotaterelaykey",
+	"listeventscores",
+	"getpubkeyscore",
+	"listshadowbannedpubkeys",
+	"shadowbanpubkey",
+	"unshadowbanpubkey",
+	"listshadowrejections",
+	"listspamclusters",
+	"dismissspamcluster",
+	"liststorageconsumers",
+	"getpubkeystorage",
+	"getpubkeytrust",
+	"listtrustedpubkeys",
+	"refreshtrust",
+	"listauditlog",
+	"listreports",
+	"resolvereport",
+	"setnip05name",
+	"removenip05name",
+	"listnip05names",
+	"getinvitestats",
 }
 
 // handleManagementAPI handles NIP-86 JSON-RPC management requests.
@@ -94,37 +130,62 @@ func (s *Server) handleManagementAPI(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	// Verify NIP-98 Authorization
+	clientIP := extractRealClientIP(r)
 	pubkey, authErr := verifyNIP98Auth(r, body, s.cfg.PublicURL)
 	if authErr != "" {
 		log.Warn("NIP-86 auth failure",
 			zap.String("error", authErr),
 			zap.String("client_ip", r.RemoteAddr))
+		recordAudit(audit.Entry{Source: "nip86", Outcome: audit.OutcomeDenied, Message: authErr, ClientIP: clientIP})
 		writeManagementError(w, http.StatusUnauthorized, authErr)
 		return
 	}
 
+	// Parse JSON-RPC request
+	var req managementRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeManagementError(w, http.StatusBadRequest, "invalid JSON request body")
+		return
+	}
+
 	// Check if pubkey is authorized as admin
 	if !s.isAdmin(pubkey) {
 		log.Warn("NIP-86 unauthorized admin attempt",
 			zap.String("pubkey", pubkey[:16]+"..."),
 			zap.String("client_ip", r.RemoteAddr))
+		recordAudit(audit.Entry{
+			Actor:    pubkey,
+			Source:   "nip86",
+			Action:   req.Method,
+			Params:   req.Params,
+			Outcome:  audit.OutcomeDenied,
+			Message:  "not a relay admin",
+			ClientIP: clientIP,
+		})
 		writeManagementError(w, http.StatusForbidden, "pubkey is not authorized as relay admin")
 		return
 	}
 
-	// Parse JSON-RPC request
-	var req managementRequest
-	if err := json.Unmarshal(body, &req); err != nil {
-		writeManagementError(w, http.StatusBadRequest, "invalid JSON request body")
-		return
-	}
-
 	log.Info("NIP-86 management request",
 		zap.String("method", req.Method),
 		zap.String("admin", pubkey[:16]+"..."))
 
 	// Dispatch method
-	result, methodErr := s.dispatchManagementMethod(req.Method, req.Params)
+	result, methodErr := s.dispatchManagementMethod(req.Method, req.Params, pubkey)
+
+	entry := audit.Entry{
+		Actor:    pubkey,
+		Source:   "nip86",
+		Action:   req.Method,
+		Params:   req.Params,
+		Outcome:  audit.OutcomeSuccess,
+		ClientIP: clientIP,
+	}
+	if methodErr != "" {
+		entry.Outcome, entry.Message = audit.OutcomeFailure, methodErr
+	}
+	recordAudit(entry)
+
 	if methodErr != "" {
 		writeManagementResponse(w, managementResponse{Error: methodErr})
 		return
@@ -136,81 +197,14 @@ func (s *Server) handleManagementAPI(w http.ResponseWriter, r *http.Request) {
 // verifyNIP98Auth validates the NIP-98 Authorization header (kind 27235).
 // Returns the authenticated pubkey and an error string (empty on success).
 func verifyNIP98Auth(r *http.Request, body []byte, relayURL string) (string, string) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return "", "missing Authorization header"
-	}
-
-	if !strings.HasPrefix(authHeader, "Nostr ") {
-		return "", "Authorization header must start with 'Nostr '"
+	if len(body) == 0 {
+		return "", "missing request body"
 	}
-
-	// Decode base64 event
-	encoded := strings.TrimPrefix(authHeader, "Nostr ")
-	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	pubkey, err := nips.ValidateHTTPAuth(r.Header.Get("Authorization"), relayURL, http.MethodPost, body)
 	if err != nil {
-		return "", "invalid base64 in Authorization header"
+		return "", err.Error()
 	}
-
-	// Parse event
-	var evt nostr.Event
-	if err := json.Unmarshal(decoded, &evt); err != nil {
-		return "", "invalid event in Authorization header"
-	}
-
-	// Verify kind 27235
-	if evt.Kind != 27235 {
-		return "", "auth event must be kind 27235"
-	}
-
-	// Verify signature
-	ok, err := evt.CheckSignature()
-	if err != nil || !ok {
-		return "", "invalid event signature"
-	}
-
-	// Check created_at is within 60 seconds
-	now := time.Now().Unix()
-	diff := now - int64(evt.CreatedAt)
-	if diff < 0 {
-		diff = -diff
-	}
-	if diff > 60 {
-		return "", "auth event timestamp too old or too far in future"
-	}
-
-	// Verify u tag matches relay URL
-	uTag := evt.Tags.GetFirst([]string{"u", ""})
-	if uTag == nil || len(*uTag) < 2 {
-		return "", "auth event missing 'u' tag"
-	}
-	eventURL := strings.TrimRight((*uTag)[1], "/")
-	expectedURL := strings.TrimRight(relayURL, "/")
-	if eventURL != expectedURL {
-		return "", fmt.Sprintf("auth event 'u' tag mismatch: got %s, expected %s", eventURL, expectedURL)
-	}
-
-	// Verify method tag is POST
-	methodTag := evt.Tags.GetFirst([]string{"method", ""})
-	if methodTag == nil || len(*methodTag) < 2 {
-		return "", "auth event missing 'method' tag"
-	}
-	if strings.ToUpper((*methodTag)[1]) != "POST" {
-		return "", "auth event method must be POST"
-	}
-
-	// Verify payload tag (SHA256 of request body)
-	payloadTag := evt.Tags.GetFirst([]string{"payload", ""})
-	if payloadTag == nil || len(*payloadTag) < 2 {
-		return "", "auth event missing 'payload' tag"
-	}
-	bodyHash := sha256.Sum256(body)
-	expectedPayload := hex.EncodeToString(bodyHash[:])
-	if (*payloadTag)[1] != expectedPayload {
-		return "", "auth event payload hash does not match request body"
-	}
-
-	return evt.PubKey, ""
+	return pubkey, ""
 }
 
 // isAdmin checks if the pubkey is authorized as a relay admin.
@@ -233,13 +227,13 @@ func (s *Server) isAdmin(pubkey string) bool {
 	return false
 }
 
-// dispatchManagementMethod routes a NIP-86 method call to the appropriate handler.
-func (s *Server) dispatchManagementMethod(method string, params []string) (interface{}, string) {
+// dispatchManagementMethod routes a NIP-86 method call from an admin to the appropriate handler.
+func (s *Server) dispatchManagementMethod(method string, params []string, admin string) (interface{}, string) {
 	switch method {
 	case "supportedmethods":
 		return nip86SupportedMethods, ""
 	case "banpubkey":
-		return s.mgmtBanPubkey(params)
+		return s.mgmtBanPubkey(params, admin)
 	case "listbannedpubkeys":
 		return s.mgmtListBannedPubkeys()
 	case "allowpubkey":
@@ -247,7 +241,7 @@ func (s *Server) dispatchManagementMethod(method string, params []string) (inter
 	case "listallowedpubkeys":
 		return s.mgmtListAllowedPubkeys()
 	case "banevent":
-		return s.mgmtBanEvent(params)
+		return s.mgmtBanEvent(params, admin)
 	case "listbannedevents":
 		return s.mgmtListBannedEvents()
 	case "allowevent":
@@ -265,11 +259,61 @@ func (s *Server) dispatchManagementMethod(method string, params []string) (inter
 	case "listallowedkinds":
 		return s.mgmtListAllowedKinds()
 	case "blockip":
-		return s.mgmtBlockIP(params)
+		return s.mgmtBlockIP(params, admin)
 	case "unblockip":
 		return s.mgmtUnblockIP(params)
 	case "listblockedips":
 		return s.mgmtListBlockedIPs()
+	case "blockcidr":
+		return s.mgmtBlockCIDR(params, admin)
+	case "unblockcidr":
+		return s.mgmtUnblockCIDR(params)
+	case "listblockedcidrs":
+		return s.mgmtListBlockedCIDRs()
+	case "creategroupinvite":
+		return s.mgmtCreateGroupInvite(params)
+	case "rotaterelaykey":
+		return s.mgmtRotateRelayKey(params)
+	case "listeventscores":
+		return s.mgmtListEventScores(params)
+	case "getpubkeyscore":
+		return s.mgmtGetPubkeyScore(params)
+	case "listshadowbannedpubkeys":
+		return s.mgmtListShadowBannedPubkeys()
+	case "shadowbanpubkey":
+		return s.mgmtShadowBanPubkey(params)
+	case "unshadowbanpubkey":
+		return s.mgmtUnshadowBanPubkey(params)
+	case "listshadowrejections":
+		return s.mgmtListShadowRejections(params)
+	case "listspamclusters":
+		return s.mgmtListSpamClusters(params)
+	case "dismissspamcluster":
+		return s.mgmtDismissSpamCluster(params)
+	case "liststorageconsumers":
+		return s.mgmtListStorageConsumers(params)
+	case "getpubkeystorage":
+		return s.mgmtGetPubkeyStorage(params)
+	case "getpubkeytrust":
+		return s.mgmtGetPubkeyTrust(params)
+	case "listtrustedpubkeys":
+		return s.mgmtListTrustedPubkeys(params)
+	case "refreshtrust":
+		return s.mgmtRefreshTrust()
+	case "listauditlog":
+		return s.mgmtListAuditLog(params)
+	case "listreports":
+		return s.mgmtListReports(params)
+	case "resolvereport":
+		return s.mgmtResolveReport(params, admin)
+	case "setnip05name":
+		return s.mgmtSetNIP05Name(params, admin)
+	case "removenip05name":
+		return s.mgmtRemoveNIP05Name(params)
+	case "listnip05names":
+		return s.mgmtListNIP05Names()
+	case "getinvitestats":
+		return s.mgmtGetInviteStats()
 	default:
 		return nil, fmt.Sprintf("unknown method: %s", method)
 	}
@@ -277,23 +321,39 @@ func (s *Server) dispatchManagementMethod(method string, params []string) (inter
 
 // --- Pubkey Ban/Allow ---
 
-func (s *Server) mgmtBanPubkey(params []string) (interface{}, string) {
+// Bans and blocks take optional params after the target: a reason and an
+// expiry in seconds (0 or absent = permanent).
+
+func (s *Server) mgmtBanPubkey(params []string, admin string) (interface{}, string) {
 	if len(params) < 1 {
 		return nil, "missing pubkey parameter"
 	}
-	pubkey := strings.ToLower(params[0])
-	if len(pubkey) != 64 {
-		return nil, "invalid pubkey: must be 64 hex characters"
+	pubkey, errMsg := parsePubkeyParam(params[0])
+	if errMsg != "" {
+		return nil, errMsg
 	}
-
-	pv, ok := s.node.GetValidator().(*PluginValidator)
-	if !ok {
+	reason, expiresAt, errMsg := parseDecisionParams(params)
+	if errMsg != "" {
+		return nil, errMsg
+	}
+	if _, ok := s.node.GetValidator().(*PluginValidator); !ok {
 		return nil, "internal error: validator type mismatch"
 	}
-	pv.AddBlacklistedPubkey(pubkey)
+
+	if errMsg := s.saveDecision(storage.ModerationDecision{
+		Type:      storage.ModerationBanPubkey,
+		Target:    pubkey,
+		Reason:    reason,
+		Actor:     admin,
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiresAt,
+	}); errMsg != "" {
+		return nil, errMsg
+	}
 
 	logger.New("nip86").Info("Pubkey banned via management API",
-		zap.String("pubkey", pubkey[:16]+"..."))
+		zap.String("pubkey", pubkey[:16]+"..."),
+		zap.Int64("expires_at", expiresAt))
 
 	return true, ""
 }
@@ -305,20 +365,34 @@ func (s *Server) mgmtListBannedPubkeys() (interface{}, string) {
 	}
 	pubkeys := pv.GetBlacklistedPubkeys()
 	sort.Strings(pubkeys)
-	return pubkeys, ""
+
+	// Config blacklist entries have no decision behind them
+	entries := pubkeyEntries(pubkeys)
+	mgmtState.mu.RLock()
+	for i := range entries {
+		if d, ok := mgmtState.bannedPubkeys[entries[i].Pubkey]; ok {
+			entries[i].decisionInfo = infoOf(d)
+		}
+	}
+	mgmtState.mu.RUnlock()
+	return entries, ""
 }
 
 func (s *Server) mgmtAllowPubkey(params []string) (interface{}, string) {
 	if len(params) < 1 {
 		return nil, "missing pubkey parameter"
 	}
-	pubkey := strings.ToLower(params[0])
-
-	pv, ok := s.node.GetValidator().(*PluginValidator)
-	if !ok {
+	pubkey, errMsg := parsePubkeyParam(params[0])
+	if errMsg != "" {
+		return nil, errMsg
+	}
+	if _, ok := s.node.GetValidator().(*PluginValidator); !ok {
 		return nil, "internal error: validator type mismatch"
 	}
-	pv.RemoveBlacklistedPubkey(pubkey)
+
+	if errMsg := s.liftDecision(storage.ModerationBanPubkey, pubkey); errMsg != "" {
+		return nil, errMsg
+	}
 
 	logger.New("nip86").Info("Pubkey unbanned via management API",
 		zap.String("pubkey", pubkey[:16]+"..."))
@@ -327,30 +401,38 @@ func (s *Server) mgmtAllowPubkey(params []string) (interface{}, string) {
 }
 
 func (s *Server) mgmtListAllowedPubkeys() (interface{}, string) {
-	whitelist := s.fullCfg.RelayPolicy.Whitelist.PubKeys
-	if whitelist == nil {
-		return []string{}, ""
-	}
-	return whitelist, ""
+	return pubkeyEntries(s.fullCfg.RelayPolicy.Whitelist.PubKeys), ""
 }
 
 // --- Event Ban/Allow ---
 
-func (s *Server) mgmtBanEvent(params []string) (interface{}, string) {
+func (s *Server) mgmtBanEvent(params []string, admin string) (interface{}, string) {
 	if len(params) < 1 {
 		return nil, "missing event_id parameter"
 	}
-	eventID := strings.ToLower(params[0])
-	if len(eventID) != 64 {
-		return nil, "invalid event_id: must be 64 hex characters"
+	eventID, errMsg := s.parseEventIDParam(params[0])
+	if errMsg != "" {
+		return nil, errMsg
+	}
+	reason, expiresAt, errMsg := parseDecisionParams(params)
+	if errMsg != "" {
+		return nil, errMsg
 	}
 
-	mgmtState.mu.Lock()
-	mgmtState.bannedEvents[eventID] = true
-	mgmtState.mu.Unlock()
+	if errMsg := s.saveDecision(storage.ModerationDecision{
+		Type:      storage.ModerationBanEvent,
+		Target:    eventID,
+		Reason:    reason,
+		Actor:     admin,
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiresAt,
+	}); errMsg != "" {
+		return nil, errMsg
+	}
 
 	logger.New("nip86").Info("Event banned via management API",
-		zap.String("event_id", eventID[:16]+"..."))
+		zap.String("event_id", eventID[:16]+"..."),
+		zap.Int64("expires_at", expiresAt))
 
 	return true, ""
 }
@@ -364,18 +446,26 @@ func (s *Server) mgmtListBannedEvents() (interface{}, string) {
 		events = append(events, id)
 	}
 	sort.Strings(events)
-	return events, ""
+
+	entries := eventEntries(events)
+	for i := range entries {
+		entries[i].decisionInfo = infoOf(mgmtState.bannedEvents[entries[i].ID])
+	}
+	return entries, ""
 }
 
 func (s *Server) mgmtAllowEvent(params []string) (interface{}, string) {
 	if len(params) < 1 {
 		return nil, "missing event_id parameter"
 	}
-	eventID := strings.ToLower(params[0])
+	eventID, errMsg := s.parseEventIDParam(params[0])
+	if errMsg != "" {
+		return nil, errMsg
+	}
 
-	mgmtState.mu.Lock()
-	delete(mgmtState.bannedEvents, eventID)
-	mgmtState.mu.Unlock()
+	if errMsg := s.liftDecision(storage.ModerationBanEvent, eventID); errMsg != "" {
+		return nil, errMsg
+	}
 
 	logger.New("nip86").Info("Event unbanned via management API",
 		zap.String("event_id", eventID[:16]+"..."))
@@ -492,7 +582,7 @@ func (s *Server) mgmtListAllowedKinds() (interface{}, string) {
 
 // --- IP Block/Unblock ---
 
-func (s *Server) mgmtBlockIP(params []string) (interface{}, string) {
+func (s *Server) mgmtBlockIP(params []string, admin string) (interface{}, string) {
 	if len(params) < 1 {
 		return nil, "missing IP parameter"
 	}
@@ -500,19 +590,26 @@ func (s *Server) mgmtBlockIP(params []string) (interface{}, string) {
 	if ip == "" {
 		return nil, "IP address cannot be empty"
 	}
+	reason, expiresAt, errMsg := parseDecisionParams(params)
+	if errMsg != "" {
+		return nil, errMsg
+	}
 
-	// Track in management state
-	mgmtState.mu.Lock()
-	mgmtState.blockedIPs[ip] = true
-	mgmtState.mu.Unlock()
-
-	// Also add to the relay's client ban list with permanent expiry
-	banListMutex.Lock()
-	clientBanList[ip] = time.Now().Add(100 * 365 * 24 * time.Hour) // ~100 years = permanent
-	banListMutex.Unlock()
+	// Enforced through the relay's client ban list
+	if errMsg := s.saveDecision(storage.ModerationDecision{
+		Type:      storage.ModerationBlockIP,
+		Target:    ip,
+		Reason:    reason,
+		Actor:     admin,
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiresAt,
+	}); errMsg != "" {
+		return nil, errMsg
+	}
 
 	logger.New("nip86").Info("IP blocked via management API",
-		zap.String("ip", ip))
+		zap.String("ip", ip),
+		zap.Int64("expires_at", expiresAt))
 
 	return true, ""
 }
@@ -523,15 +620,9 @@ func (s *Server) mgmtUnblockIP(params []string) (interface{}, string) {
 	}
 	ip := params[0]
 
-	// Remove from management state
-	mgmtState.mu.Lock()
-	delete(mgmtState.blockedIPs, ip)
-	mgmtState.mu.Unlock()
-
-	// Also remove from relay's client ban list
-	banListMutex.Lock()
-	delete(clientBanList, ip)
-	banListMutex.Unlock()
+	if errMsg := s.liftDecision(storage.ModerationBlockIP, ip); errMsg != "" {
+		return nil, errMsg
+	}
 
 	logger.New("nip86").Info("IP unblocked via management API",
 		zap.String("ip", ip))
@@ -548,11 +639,133 @@ func (s *Server) mgmtListBlockedIPs() (interface{}, string) {
 		ips = append(ips, ip)
 	}
 	sort.Strings(ips)
-	return ips, ""
+
+	entries := make([]ipEntry, 0, len(ips))
+	for _, ip := range ips {
+		entries = append(entries, ipEntry{IP: ip, decisionInfo: infoOf(mgmtState.blockedIPs[ip])})
+	}
+	return entries, ""
+}
+
+// mgmtBlockCIDR blocks an address range.
+// Params: [cidr, reason (optional), ttl_seconds (optional, 0 = never)]
+func (s *Server) mgmtBlockCIDR(params []string, admin string) (interface{}, string) {
+	if len(params) < 1 || params[0] == "" {
+		return nil, "missing CIDR parameter"
+	}
+	n, err := clientip.ParseCIDR(params[0])
+	if err != nil {
+		return nil, err.Error()
+	}
+	reason, expiresAt, errMsg := parseDecisionParams(params)
+	if errMsg != "" {
+		return nil, errMsg
+	}
+
+	if errMsg := s.saveDecision(storage.ModerationDecision{
+		Type:      storage.ModerationBlockCIDR,
+		Target:    n.String(),
+		Reason:    reason,
+		Actor:     admin,
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiresAt,
+	}); errMsg != "" {
+		return nil, errMsg
+	}
+
+	logger.New("nip86").Info("Address range blocked via management API",
+		zap.String("cidr", n.String()),
+		zap.Int64("expires_at", expiresAt))
+
+	return true, ""
+}
+
+func (s *Server) mgmtUnblockCIDR(params []string) (interface{}, string) {
+	if len(params) < 1 || params[0] == "" {
+		return nil, "missing CIDR parameter"
+	}
+	n, err := clientip.ParseCIDR(params[0])
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	if errMsg := s.liftDecision(storage.ModerationBlockCIDR, n.String()); errMsg != "" {
+		return nil, errMsg
+	}
+
+	logger.New("nip86").Info("Address range unblocked via management API",
+		zap.String("cidr", n.String()))
+
+	return true, ""
+}
+
+func (s *Server) mgmtListBlockedCIDRs() (interface{}, string) {
+	mgmtState.mu.RLock()
+	defer mgmtState.mu.RUnlock()
+
+	cidrs := make([]string, 0, len(mgmtState.blockedCIDRs))
+	for cidr := range mgmtState.blockedCIDRs {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+
+	entries := make([]cidrEntry, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		entries = append(entries, cidrEntry{CIDR: cidr, decisionInfo: infoOf(mgmtState.blockedCIDRs[cidr])})
+	}
+	return entries, ""
 }
 
 // --- Response Helpers ---
 
+// --- NIP-29 Group Invites ---
+
+// mgmtCreateGroupInvite generates a relay-signed invite code for a group.
+// Params: [group_id, ttl_seconds (optional, 0 = never), max_uses (optional, 0 = unlimited, default 1)]
+func (s *Server) mgmtCreateGroupInvite(params []string) (interface{}, string) {
+	if len(params) < 1 || params[0] == "" {
+		return nil, "missing group_id parameter"
+	}
+	var ttl time.Duration
+	if len(params) >= 2 && params[1] != "" {
+		secs, err := strconv.Atoi(params[1])
+		if err != nil || secs < 0 {
+			return nil, "invalid ttl: must be a non-negative number of seconds"
+		}
+		ttl = time.Duration(secs) * time.Second
+	}
+	maxUses := 1
+	if len(params) >= 3 && params[2] != "" {
+		n, err := strconv.Atoi(params[2])
+		if err != nil || n < 0 {
+			return nil, "invalid max_uses: must be a non-negative number"
+		}
+		maxUses = n
+	}
+
+	gs := GetGroupStore()
+	if gs == nil {
+		return nil, "internal error: group store not initialized"
+	}
+	invite, evt, err := gs.CreateGroupInvite(params[0], "nip86", ttl, maxUses)
+	if err != nil {
+		return nil, err.Error()
+	}
+	queueRelayEvent(s.node, evt)
+
+	result := map[string]interface{}{
+		"code":     invite.Code,
+		"max_uses": invite.MaxUses,
+	}
+	if !invite.ExpiresAt.IsZero() {
+		result["expires_at"] = invite.ExpiresAt.Unix()
+	}
+	if evt != nil {
+		result["event_id"] = evt.ID
+	}
+	return result, ""
+}
+
 func setManagementCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
@@ -577,5 +790,6 @@ func writeManagementError(w http.ResponseWriter, status int, message string) {
 func IsBannedEvent(eventID string) bool {
 	mgmtState.mu.RLock()
 	defer mgmtState.mu.RUnlock()
-	return mgmtState.bannedEvents[strings.ToLower(eventID)]
+	d, banned := mgmtState.bannedEvents[strings.ToLower(eventID)]
+	return banned && !d.Expired(time.Now())
 }
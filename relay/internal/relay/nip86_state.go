@@ -0,0 +1,237 @@
+package relay
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/audit"
+	"github.com/Shugur-Network/relay/internal/clientip"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Persistence of NIP-86 decisions: pubkey bans, event bans, IP blocks and
+// address range blocks are written to the moderation_decisions table, loaded again on startup, and
+// lifted when their optional expiry passes.
+
+// decisionSweepInterval is how often expired decisions are lifted.
+const decisionSweepInterval = time.Minute
+
+// permanentBan is the client ban list expiry of an IP block without one.
+const permanentBan = 100 * 365 * 24 * time.Hour
+
+// liftMethods names the NIP-86 method that lifts each decision type, used
+// for the audit entries of expired decisions.
+var liftMethods = map[string]string{
+	storage.ModerationBanPubkey: "allowpubkey",
+	storage.ModerationBanEvent:  "allowevent",
+	storage.ModerationBlockIP:   "unblockip",
+	storage.ModerationBlockCIDR: "unblockcidr",
+}
+
+// LoadManagementState restores the persisted NIP-86 decisions. Called from
+// NewServer; decisions that expired while the relay was down are dropped.
+func LoadManagementState(ctx context.Context, db *storage.DB, pv *PluginValidator) {
+	if db == nil {
+		return
+	}
+	log := logger.New("nip86")
+	decisions, err := db.ListModerationDecisions(ctx)
+	if err != nil {
+		log.Warn("Failed to load management decisions", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	loaded := 0
+	for _, d := range decisions {
+		if d.Expired(now) {
+			if err := db.DeleteModerationDecision(ctx, d.Type, d.Target); err != nil {
+				log.Warn("Failed to drop expired management decision", zap.Error(err))
+			}
+			continue
+		}
+		applyDecision(pv, d)
+		loaded++
+	}
+	if loaded > 0 {
+		log.Info("Restored management decisions", zap.Int("decisions", loaded))
+	}
+}
+
+// saveDecision persists a decision and then enforces it.
+func (s *Server) saveDecision(d storage.ModerationDecision) string {
+	pv, _ := s.node.GetValidator().(*PluginValidator)
+	return persistDecision(s.node.DB(), pv, d)
+}
+
+// persistDecision writes a decision to db and then enforces it; also used for
+// the IP blocks the relay makes on its own.
+func persistDecision(db *storage.DB, pv *PluginValidator, d storage.ModerationDecision) string {
+	if db != nil {
+		if err := db.SaveModerationDecision(context.Background(), d); err != nil {
+			logger.New("nip86").Warn("Failed to persist management decision",
+				zap.String("type", d.Type),
+				zap.Error(err))
+			return "failed to persist decision"
+		}
+	}
+	applyDecision(pv, d)
+	return ""
+}
+
+// liftDecision stops enforcing a decision and forgets it.
+func (s *Server) liftDecision(decisionType, target string) string {
+	if db := s.node.DB(); db != nil {
+		if err := db.DeleteModerationDecision(context.Background(), decisionType, target); err != nil {
+			logger.New("nip86").Warn("Failed to delete management decision",
+				zap.String("type", decisionType),
+				zap.Error(err))
+			return "failed to persist decision"
+		}
+	}
+	pv, _ := s.node.GetValidator().(*PluginValidator)
+	unapplyDecision(pv, decisionType, target)
+	return ""
+}
+
+// applyDecision enforces a decision in memory.
+func applyDecision(pv *PluginValidator, d storage.ModerationDecision) {
+	mgmtState.mu.Lock()
+	defer mgmtState.mu.Unlock()
+
+	switch d.Type {
+	case storage.ModerationBanPubkey:
+		mgmtState.bannedPubkeys[d.Target] = d
+		if pv != nil {
+			pv.AddBlacklistedPubkey(d.Target)
+		}
+	case storage.ModerationBanEvent:
+		mgmtState.bannedEvents[d.Target] = d
+	case storage.ModerationBlockIP:
+		mgmtState.blockedIPs[d.Target] = d
+		expiry := time.Now().Add(permanentBan)
+		if d.ExpiresAt > 0 {
+			expiry = time.Unix(d.ExpiresAt, 0)
+		}
+		banListMutex.Lock()
+		clientBanList[d.Target] = expiry
+		banListMutex.Unlock()
+	case storage.ModerationBlockCIDR:
+		n, err := clientip.ParseCIDR(d.Target)
+		if err != nil {
+			logger.New("nip86").Warn("Ignoring invalid blocked CIDR", zap.String("cidr", d.Target))
+			return
+		}
+		mgmtState.blockedCIDRs[d.Target] = d
+		ipBlocks.addRange(n)
+	}
+}
+
+// unapplyDecision stops enforcing a decision in memory.
+func unapplyDecision(pv *PluginValidator, decisionType, target string) {
+	mgmtState.mu.Lock()
+	defer mgmtState.mu.Unlock()
+
+	switch decisionType {
+	case storage.ModerationBanPubkey:
+		delete(mgmtState.bannedPubkeys, target)
+		if pv != nil {
+			pv.RemoveBlacklistedPubkey(target)
+		}
+	case storage.ModerationBanEvent:
+		delete(mgmtState.bannedEvents, target)
+	case storage.ModerationBlockIP:
+		delete(mgmtState.blockedIPs, target)
+		banListMutex.Lock()
+		delete(clientBanList, target)
+		banListMutex.Unlock()
+	case storage.ModerationBlockCIDR:
+		delete(mgmtState.blockedCIDRs, target)
+		ipBlocks.removeRange(target)
+	}
+}
+
+// expireManagementDecisions lifts decisions whose expiry has passed until ctx
+// is canceled, recording each in the audit log.
+func (s *Server) expireManagementDecisions(ctx context.Context) {
+	ticker := time.NewTicker(decisionSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		var expired []storage.ModerationDecision
+		mgmtState.mu.RLock()
+		for _, decisions := range []map[string]storage.ModerationDecision{
+			mgmtState.bannedPubkeys, mgmtState.bannedEvents, mgmtState.blockedIPs, mgmtState.blockedCIDRs,
+		} {
+			for _, d := range decisions {
+				if d.Expired(now) {
+					expired = append(expired, d)
+				}
+			}
+		}
+		mgmtState.mu.RUnlock()
+
+		for _, d := range expired {
+			msg := s.liftDecision(d.Type, d.Target)
+			entry := audit.Entry{
+				Actor:   audit.ActorSystem,
+				Source:  "nip86",
+				Action:  liftMethods[d.Type],
+				Params:  []string{d.Target},
+				Outcome: audit.OutcomeSuccess,
+				Message: "expired",
+			}
+			if msg != "" {
+				entry.Outcome, entry.Message = audit.OutcomeFailure, msg
+			}
+			recordAudit(entry)
+		}
+	}
+}
+
+// decisionInfo describes the NIP-86 decision behind a listed entry.
+type decisionInfo struct {
+	Reason    string `json:"reason,omitempty"`
+	Actor     string `json:"actor,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+// ipEntry is a blocked IP in a management listing.
+type ipEntry struct {
+	IP string `json:"ip"`
+	decisionInfo
+}
+
+func infoOf(d storage.ModerationDecision) decisionInfo {
+	return decisionInfo{Reason: d.Reason, Actor: d.Actor, CreatedAt: d.CreatedAt, ExpiresAt: d.ExpiresAt}
+}
+
+// parseDecisionParams reads the optional reason and expiry (seconds from now,
+// 0 = never) that follow the target of a ban or block.
+func parseDecisionParams(params []string) (string, int64, string) {
+	var reason string
+	if len(params) > 1 {
+		reason = params[1]
+	}
+	var expiresAt int64
+	if len(params) > 2 && params[2] != "" {
+		secs, err := strconv.ParseInt(params[2], 10, 64)
+		if err != nil || secs < 0 {
+			return "", 0, "invalid expiry: must be a non-negative number of seconds"
+		}
+		if secs > 0 {
+			expiresAt = time.Now().Unix() + secs
+		}
+	}
+	return reason, expiresAt, ""
+}
@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"context"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	"go.uber.org/zap"
+)
+
+// NIP switches: NIPS.DISABLED switches registered NIPs off. The registry
+// refuses their kinds (KindPolicy.Allows and validateWithDedicatedNIPs) and
+// drops them from NIP-11 and the dashboard. The config file is polled so the
+// list can change while the relay runs.
+
+// InitNIPSwitches applies NIPS.DISABLED. Called from NewServer.
+func InitNIPSwitches(cfg *config.Config) {
+	applyNIPSwitches(cfg.NIPs.Disabled)
+}
+
+// applyNIPSwitches switches off the listed NIPs and every other one back on.
+func applyNIPSwitches(disabled []string) {
+	if unknown := registry.SetDisabled(disabled); len(unknown) > 0 {
+		logger.Warn("Ignoring unknown NIPs in NIPS.DISABLED", zap.Strings("nips", unknown))
+	}
+	if len(disabled) > 0 {
+		logger.Info("NIPs disabled", zap.Strings("nips", disabled))
+	}
+}
+
+// startNIPSwitchReload re-reads NIPS.DISABLED whenever the config file
+// changes, until ctx is canceled.
+func startNIPSwitchReload(ctx context.Context, cfg *config.Config) {
+	path := cfg.File
+	interval := cfg.NIPs.ReloadInterval
+	if path == "" || interval <= 0 {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		logger.Warn("Cannot watch config file for NIP switches", zap.String("file", path), zap.Error(err))
+		return
+	}
+
+	go func() {
+		modTime := info.ModTime()
+		current := slices.Clone(cfg.NIPs.Disabled)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil || !info.ModTime().After(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+
+			reloaded, err := config.Reload(path)
+			if err != nil {
+				// Keep the current switches until the file is fixed
+				logger.Error("Failed to reload NIP switches", zap.String("file", path), zap.Error(err))
+				continue
+			}
+			if slices.Equal(reloaded.NIPs.Disabled, current) {
+				continue
+			}
+			current = reloaded.NIPs.Disabled
+			applyNIPSwitches(current)
+			logger.Info("Reloaded NIP switches", zap.Strings("disabled", current))
+		}
+	}()
+}
@@ -0,0 +1,142 @@
+package nips
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BOLT-11 tagged field types, the bech32 value of the field's letter.
+const (
+	bolt11FieldPaymentHash     = 1  // p
+	bolt11FieldDescription     = 13 // d
+	bolt11FieldDescriptionHash = 23 // h
+)
+
+// bolt11SignatureLength is the length in 5-bit values of the signature and
+// recovery id closing every invoice.
+const bolt11SignatureLength = 104
+
+// Bolt11Invoice holds the parts of a BOLT-11 invoice zap verification reads.
+// The invoice signature is not checked.
+type Bolt11Invoice struct {
+	AmountMsat      int64  // 0 when the invoice leaves the amount to the payer
+	PaymentHash     string // p field, lowercase hex
+	Description     string // d field
+	DescriptionHash string // h field, lowercase hex
+}
+
+// DecodeBolt11 decodes the amount, payment hash and description fields of a
+// BOLT-11 lightning invoice.
+func DecodeBolt11(invoice string) (*Bolt11Invoice, error) {
+	prefix, values, err := bech32DecodeValues(invoice)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invoice encoding: %w", err)
+	}
+	if !strings.HasPrefix(prefix, "ln") {
+		return nil, fmt.Errorf("invoice prefix must start with 'ln', got %q", prefix)
+	}
+
+	inv := &Bolt11Invoice{}
+	if inv.AmountMsat, err = bolt11Amount(prefix[2:]); err != nil {
+		return nil, err
+	}
+
+	// A 35-bit timestamp, then tagged fields up to the signature
+	if len(values) < 7+bolt11SignatureLength {
+		return nil, fmt.Errorf("invoice too short")
+	}
+	fields := values[7 : len(values)-bolt11SignatureLength]
+	for len(fields) >= 3 {
+		fieldType := fields[0]
+		length := int(fields[1])<<5 | int(fields[2])
+		if len(fields) < 3+length {
+			return nil, fmt.Errorf("invoice field overruns the invoice")
+		}
+		data := fields[3 : 3+length]
+		fields = fields[3+length:]
+
+		switch fieldType {
+		case bolt11FieldPaymentHash:
+			if length != 52 {
+				continue // Readers must skip p fields of the wrong length
+			}
+			b, err := convertBits(data, 5, 8, false)
+			if err != nil {
+				return nil, fmt.Errorf("invalid invoice payment hash: %w", err)
+			}
+			inv.PaymentHash = hex.EncodeToString(b)
+		case bolt11FieldDescription:
+			b, err := convertBits(data, 5, 8, false)
+			if err != nil {
+				return nil, fmt.Errorf("invalid invoice description: %w", err)
+			}
+			inv.Description = string(b)
+		case bolt11FieldDescriptionHash:
+			if length != 52 {
+				continue // Readers must skip h fields of the wrong length
+			}
+			b, err := convertBits(data, 5, 8, false)
+			if err != nil {
+				return nil, fmt.Errorf("invalid invoice description hash: %w", err)
+			}
+			inv.DescriptionHash = hex.EncodeToString(b)
+		}
+	}
+	if len(fields) != 0 {
+		return nil, fmt.Errorf("invoice has a truncated field")
+	}
+
+	return inv, nil
+}
+
+// bolt11Amount reads the amount from the part of an invoice prefix after
+// "ln": a currency code, then optionally an amount with a multiplier.
+func bolt11Amount(s string) (int64, error) {
+	i := strings.IndexAny(s, "0123456789")
+	if i < 0 {
+		return 0, nil
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("invoice prefix has no currency")
+	}
+	amount := s[i:]
+
+	// Millisatoshis per unit of the multiplier, 1 BTC being 10^11 msat; a
+	// pico-bitcoin is a tenth of a millisatoshi and handled apart
+	msatPerUnit := int64(100_000_000_000)
+	pico := false
+	switch amount[len(amount)-1] {
+	case 'm':
+		msatPerUnit = 100_000_000
+	case 'u':
+		msatPerUnit = 100_000
+	case 'n':
+		msatPerUnit = 100
+	case 'p':
+		pico = true
+	default:
+		if amount[len(amount)-1] > '9' {
+			return 0, fmt.Errorf("invalid invoice amount multiplier in %q", amount)
+		}
+	}
+	if amount[len(amount)-1] > '9' {
+		amount = amount[:len(amount)-1]
+	}
+
+	n, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil || n <= 0 || (len(amount) > 1 && amount[0] == '0') {
+		return 0, fmt.Errorf("invalid invoice amount %q", s[i:])
+	}
+	if pico {
+		if n%10 != 0 {
+			return 0, fmt.Errorf("invoice amount %q is not a whole millisatoshi", s[i:])
+		}
+		return n / 10, nil
+	}
+	if n > (1<<63-1)/msatPerUnit {
+		return 0, fmt.Errorf("invoice amount %q too large", s[i:])
+	}
+	return n * msatPerUnit, nil
+}
@@ -5,10 +5,17 @@ import (
 	"fmt"
 
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "01", Name: "Basic Protocol", Kinds: []int{0, 1, 2}, KindRanges: []registry.KindRange{
+		{From: 20000, To: 29999}, // Ephemeral events, relayed but not stored
+	}})
+}
+
 // VerifyEventJSON parses the raw JSON into a go-nostr Event
 // and checks the BIP-340 signature. It returns nil if valid, or an error otherwise.
 func VerifyEventJSON(rawEvent []byte) error {
@@ -2,9 +2,14 @@ package nips
 
 import (
 	"github.com/Shugur-Network/relay/internal/relay/nips/common"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "02", Name: "Follow List", Kinds: []int{3}})
+}
+
 // NIP-02: Follow List
 // https://github.com/nostr-protocol/nips/blob/master/02.md
 
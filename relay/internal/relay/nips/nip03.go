@@ -1,12 +1,22 @@
 package nips
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
+	"golang.org/x/crypto/ripemd160" //nolint:staticcheck // required by the OpenTimestamps format
+	"golang.org/x/crypto/sha3"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "03", Name: "OpenTimestamps", Kinds: []int{1040}})
+}
+
 // NIP-03: OpenTimestamps Attestations for Events
 // https://github.com/nostr-protocol/nips/blob/master/03.md
 
@@ -68,3 +78,296 @@ func ValidateOpenTimestampsAttestation(evt *nostr.Event) error {
 func IsOpenTimestampsAttestation(evt *nostr.Event) bool {
 	return evt.Kind == 1040
 }
+
+// OpenTimestamps proof format
+// https://github.com/opentimestamps/python-opentimestamps
+//
+// A detached .ots file is a magic header, a version, the hash op and digest
+// of the timestamped file, then a tree of operations applied to the digest.
+// Each branch ends in attestations of the message computed along it: a
+// Bitcoin block whose merkle root is that message, or a calendar server that
+// promised to get it into one.
+
+// otsMagic starts every detached timestamp file.
+var otsMagic = []byte("\x00OpenTimestamps\x00\x00Proof\x00\xbf\x89\xe2\xe8\x84\xe8\x92\x94")
+
+// Attestation tags.
+var (
+	otsTagBitcoin = []byte{0x05, 0x88, 0x96, 0x0d, 0x73, 0xd7, 0x19, 0x01}
+	otsTagPending = []byte{0x83, 0xdf, 0xe3, 0x0d, 0x2e, 0xf9, 0x0c, 0x8e}
+)
+
+// Operation tags.
+const (
+	otsOpSHA1      = 0x02
+	otsOpRIPEMD160 = 0x03
+	otsOpSHA256    = 0x08
+	otsOpKeccak256 = 0x67
+	otsOpAppend    = 0xf0
+	otsOpPrepend   = 0xf1
+	otsOpReverse   = 0xf2
+	otsOpHexlify   = 0xf3
+	otsAttestation = 0x00
+	otsFork        = 0xff
+)
+
+const (
+	// otsMaxMessage bounds messages and operation arguments, as the
+	// reference implementation does.
+	otsMaxMessage = 4096
+	// otsMaxDepth bounds nested operations.
+	otsMaxDepth = 256
+	// otsMaxAttestations bounds the attestations of one proof.
+	otsMaxAttestations = 64
+	// otsMaxURI bounds the calendar URI of a pending attestation.
+	otsMaxURI = 1000
+)
+
+// Attestation types of OTSAttestation.
+const (
+	OTSAttestationBitcoin = "bitcoin"
+	OTSAttestationPending = "pending"
+	OTSAttestationUnknown = "unknown"
+)
+
+// OTSAttestation is one attestation of a timestamp with the message it attests.
+type OTSAttestation struct {
+	Type       string
+	Height     uint64 // block height of a Bitcoin attestation
+	URI        string // calendar of a pending attestation
+	Commitment []byte // message computed from the digest along the branch
+}
+
+// OTSProof is a parsed detached timestamp.
+type OTSProof struct {
+	Digest       []byte // SHA-256 digest of the timestamped data
+	Attestations []OTSAttestation
+}
+
+// ParseOTSProof parses a detached .ots file. Only SHA-256 file digests are
+// accepted, which is what NIP-03 event IDs are.
+func ParseOTSProof(data []byte) (*OTSProof, error) {
+	r := &otsReader{data: data}
+	magic, err := r.bytes(len(otsMagic))
+	if err != nil || string(magic) != string(otsMagic) {
+		return nil, fmt.Errorf("not an OpenTimestamps proof")
+	}
+	version, err := r.varuint()
+	if err != nil {
+		return nil, err
+	}
+	if version != 1 {
+		return nil, fmt.Errorf("unsupported OpenTimestamps version %d", version)
+	}
+	op, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if op != otsOpSHA256 {
+		return nil, fmt.Errorf("unsupported file hash operation 0x%02x", op)
+	}
+	digest, err := r.bytes(sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &OTSProof{Digest: digest}
+	if err := r.timestamp(digest, 0, &proof.Attestations); err != nil {
+		return nil, err
+	}
+	if r.pos != len(r.data) {
+		return nil, fmt.Errorf("trailing data after OpenTimestamps proof")
+	}
+	return proof, nil
+}
+
+// ParseOTSTimestamp parses a bare timestamp of msg, as served by calendars
+// for a pending commitment, and returns its attestations.
+func ParseOTSTimestamp(data, msg []byte) ([]OTSAttestation, error) {
+	r := &otsReader{data: data}
+	var attestations []OTSAttestation
+	if err := r.timestamp(msg, 0, &attestations); err != nil {
+		return nil, err
+	}
+	if r.pos != len(r.data) {
+		return nil, fmt.Errorf("trailing data after OpenTimestamps timestamp")
+	}
+	return attestations, nil
+}
+
+// otsReader decodes the binary proof format.
+type otsReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *otsReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, fmt.Errorf("truncated OpenTimestamps proof")
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *otsReader) bytes(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.pos < n {
+		return nil, fmt.Errorf("truncated OpenTimestamps proof")
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// varuint reads an unsigned LEB128 integer.
+func (r *otsReader) varuint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("varuint overflows 64 bits")
+}
+
+// varbytes reads a length-prefixed byte string of at most max bytes.
+func (r *otsReader) varbytes(max int) ([]byte, error) {
+	n, err := r.varuint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(max) {
+		return nil, fmt.Errorf("OpenTimestamps field of %d bytes exceeds %d", n, max)
+	}
+	return r.bytes(int(n))
+}
+
+// timestamp reads the operations and attestations applying to msg. Forks
+// (0xff) precede every branch but the last.
+func (r *otsReader) timestamp(msg []byte, depth int, out *[]OTSAttestation) error {
+	if depth > otsMaxDepth {
+		return fmt.Errorf("OpenTimestamps proof nested too deeply")
+	}
+	tag, err := r.byte()
+	if err != nil {
+		return err
+	}
+	for tag == otsFork {
+		if tag, err = r.byte(); err != nil {
+			return err
+		}
+		if err := r.branch(tag, msg, depth, out); err != nil {
+			return err
+		}
+		if tag, err = r.byte(); err != nil {
+			return err
+		}
+	}
+	return r.branch(tag, msg, depth, out)
+}
+
+// branch reads one attestation, or one operation and the timestamp of its result.
+func (r *otsReader) branch(tag byte, msg []byte, depth int, out *[]OTSAttestation) error {
+	if tag == otsAttestation {
+		att, err := r.attestation(msg)
+		if err != nil {
+			return err
+		}
+		if len(*out) >= otsMaxAttestations {
+			return fmt.Errorf("OpenTimestamps proof has too many attestations")
+		}
+		*out = append(*out, att)
+		return nil
+	}
+
+	result, err := r.operation(tag, msg)
+	if err != nil {
+		return err
+	}
+	return r.timestamp(result, depth+1, out)
+}
+
+// attestation reads the attestation of msg following a 0x00 tag.
+func (r *otsReader) attestation(msg []byte) (OTSAttestation, error) {
+	tag, err := r.bytes(8)
+	if err != nil {
+		return OTSAttestation{}, err
+	}
+	payload, err := r.varbytes(8192)
+	if err != nil {
+		return OTSAttestation{}, err
+	}
+	att := OTSAttestation{Type: OTSAttestationUnknown, Commitment: msg}
+	p := &otsReader{data: payload}
+	switch string(tag) {
+	case string(otsTagBitcoin):
+		if att.Height, err = p.varuint(); err != nil {
+			return att, err
+		}
+		att.Type = OTSAttestationBitcoin
+	case string(otsTagPending):
+		uri, err := p.varbytes(otsMaxURI)
+		if err != nil {
+			return att, err
+		}
+		for _, c := range uri {
+			if c < 0x21 || c > 0x7e {
+				return att, fmt.Errorf("invalid calendar URI in pending attestation")
+			}
+		}
+		att.Type, att.URI = OTSAttestationPending, string(uri)
+	}
+	return att, nil
+}
+
+// operation applies the operation with the given tag to msg.
+func (r *otsReader) operation(tag byte, msg []byte) ([]byte, error) {
+	var result []byte
+	switch tag {
+	case otsOpSHA1:
+		sum := sha1.Sum(msg)
+		result = sum[:]
+	case otsOpRIPEMD160:
+		h := ripemd160.New()
+		h.Write(msg)
+		result = h.Sum(nil)
+	case otsOpSHA256:
+		sum := sha256.Sum256(msg)
+		result = sum[:]
+	case otsOpKeccak256:
+		h := sha3.NewLegacyKeccak256()
+		h.Write(msg)
+		result = h.Sum(nil)
+	case otsOpAppend, otsOpPrepend:
+		arg, err := r.varbytes(otsMaxMessage)
+		if err != nil {
+			return nil, err
+		}
+		if len(arg) == 0 {
+			return nil, fmt.Errorf("empty OpenTimestamps operation argument")
+		}
+		if tag == otsOpAppend {
+			result = append(append(make([]byte, 0, len(msg)+len(arg)), msg...), arg...)
+		} else {
+			result = append(append(make([]byte, 0, len(msg)+len(arg)), arg...), msg...)
+		}
+	case otsOpReverse:
+		result = make([]byte, len(msg))
+		for i, b := range msg {
+			result[len(msg)-1-i] = b
+		}
+	case otsOpHexlify:
+		result = []byte(hex.EncodeToString(msg))
+	default:
+		return nil, fmt.Errorf("unknown OpenTimestamps operation 0x%02x", tag)
+	}
+	if len(result) > otsMaxMessage {
+		return nil, fmt.Errorf("OpenTimestamps message exceeds %d bytes", otsMaxMessage)
+	}
+	return result, nil
+}
@@ -4,9 +4,14 @@ import (
 	"encoding/base64"
 	"fmt"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "04", Name: "Encrypted Direct Message", Kinds: []int{4}, Hidden: true})
+}
+
 // NIP-04: Encrypted Direct Message (deprecated, use NIP-17)
 // https://github.com/nostr-protocol/nips/blob/master/04.md
 
@@ -5,10 +5,15 @@ import (
 
 	"github.com/Shugur-Network/relay/internal/logger"
 	"github.com/Shugur-Network/relay/internal/relay/nips/common"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "09", Name: "Event Deletion", Kinds: []int{5}})
+}
+
 // NIP-09: Event Deletion
 // https://github.com/nostr-protocol/nips/blob/master/09.md
 
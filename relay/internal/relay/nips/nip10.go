@@ -0,0 +1,95 @@
+package nips
+
+import (
+	"strings"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-10: Text Notes and Threads
+// https://github.com/nostr-protocol/nips/blob/master/10.md
+
+// ThreadReference returns the thread root and the direct parent an event
+// answers: the NIP-10 "root" and "reply" markers of a kind 1 note (or the
+// deprecated positional e tags when no marker is used), or the NIP-22 E and e
+// tags of a kind 1111 comment. Both are "" for top-level events and for
+// comments on something other than an event.
+func ThreadReference(evt *nostr.Event) (root, parent string) {
+	switch evt.Kind {
+	case 1:
+		return noteReference(evt)
+	case 1111:
+		return CommentReference(evt)
+	}
+	return "", ""
+}
+
+// noteReference resolves the NIP-10 e tags of a kind 1 note.
+func noteReference(evt *nostr.Event) (root, parent string) {
+	var positional []string
+	marked := false
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "e" || !nostr.IsValid32ByteHex(strings.ToLower(tag[1])) {
+			continue
+		}
+		id := strings.ToLower(tag[1])
+		marker := ""
+		if len(tag) >= 4 {
+			marker = tag[3]
+		}
+		switch marker {
+		case "root":
+			marked = true
+			if root == "" {
+				root = id
+			}
+		case "reply":
+			marked = true
+			if parent == "" {
+				parent = id
+			}
+		case "mention":
+			marked = true
+		case "":
+			positional = append(positional, id)
+		}
+	}
+
+	if marked {
+		// A direct reply to the root only carries the root marker
+		if parent == "" {
+			parent = root
+		}
+		if root == "" {
+			root = parent
+		}
+		return root, parent
+	}
+
+	// Deprecated positional scheme: first is the root, last the parent
+	if len(positional) == 0 {
+		return "", ""
+	}
+	return positional[0], positional[len(positional)-1]
+}
+
+// CommentReference returns the root event (E tag) and parent event (e tag)
+// of a NIP-22 comment. Comments on addressable or external content have no
+// root event; top-level comments on an event have it as both.
+func CommentReference(evt *nostr.Event) (root, parent string) {
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || !nostr.IsValid32ByteHex(strings.ToLower(tag[1])) {
+			continue
+		}
+		switch {
+		case tag[0] == "E" && root == "":
+			root = strings.ToLower(tag[1])
+		case tag[0] == "e" && parent == "":
+			parent = strings.ToLower(tag[1])
+		}
+	}
+	if root == "" {
+		root = parent
+	}
+	return root, parent
+}
@@ -6,13 +6,44 @@ import (
 
 	"github.com/Shugur-Network/relay/internal/config"
 	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nip11 "github.com/nbd-wtf/go-nostr/nip11"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "11", Name: "Relay Info"})
+}
+
 // CustomRelayInformationDocument extends the standard NIP-11 document with NIP-XX Time Capsules capability
+// and the event kinds the relay accepts (numbers and [from, to] ranges)
 type CustomRelayInformationDocument struct {
 	nip11.RelayInformationDocument
+	Limitation   *RelayLimitation       `json:"limitation,omitempty"` // replaces the embedded limitation object
+	Self         string                 `json:"self,omitempty"`       // pubkey the relay signs its own events with
 	TimeCapsules *TimeCapsuleCapability `json:"time_capsules,omitempty"`
+	AllowedKinds []interface{}          `json:"allowed_kinds,omitempty"`
+}
+
+// RelayLimitation extends the NIP-11 limitation object with the history a
+// REQ or COUNT may reach back, in seconds, without and with NIP-42 AUTH
+// (absent = unlimited).
+type RelayLimitation struct {
+	nip11.RelayLimitationDocument
+	MaxQueryWindow              int64 `json:"max_query_window,omitempty"`
+	MaxQueryWindowAuthenticated int64 `json:"max_query_window_authenticated,omitempty"`
+}
+
+// NewRelayLimitation returns the limitation object of doc with the query
+// windows of cfg, nil when doc has none.
+func NewRelayLimitation(doc nip11.RelayInformationDocument, cfg config.QueryWindowConfig) *RelayLimitation {
+	if doc.Limitation == nil {
+		return nil
+	}
+	return &RelayLimitation{
+		RelayLimitationDocument:     *doc.Limitation,
+		MaxQueryWindow:              int64(cfg.Anonymous.Seconds()),
+		MaxQueryWindowAuthenticated: int64(cfg.Authenticated.Seconds()),
+	}
 }
 
 // TimeCapsuleCapability represents the NIP-XX Time Capsules capability
@@ -31,6 +62,7 @@ func Nip11Handler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 	// Create custom metadata with NIP-XX Time Capsules capability
 	customMetadata := CustomRelayInformationDocument{
 		RelayInformationDocument: baseMetadata,
+		Limitation:               NewRelayLimitation(baseMetadata, cfg.Relay.ThrottlingConfig.QueryWindow),
 		TimeCapsules: &TimeCapsuleCapability{
 			Version:         "1",
 			Modes:           []string{"public", "private"},
@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "13", Name: "Proof of Work"})
+}
+
 // CountLeadingZeroBits counts the number of leading zero bits in a hex event ID.
 // NIP-13 defines difficulty as the number of leading zero bits in the event ID.
 func CountLeadingZeroBits(hexID string) int {
@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "15", Name: "Marketplace", Kinds: []int{30017, 30018, 30019, 30020, 1021, 1022}})
+}
+
 // NIP-15: Nostr Marketplace (for resilient marketplaces)
 // https://github.com/nostr-protocol/nips/blob/master/15.md
 
@@ -4,10 +4,15 @@ import (
 	"fmt"
 
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "17", Name: "Private DMs", Kinds: []int{14, 15, 10050}})
+}
+
 // NIP-17: Private Direct Messages
 // https://github.com/nostr-protocol/nips/blob/master/17.md
 
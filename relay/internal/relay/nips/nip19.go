@@ -0,0 +1,260 @@
+package nips
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-19: bech32-encoded entities
+// https://github.com/nostr-protocol/nips/blob/master/19.md
+//
+// Only the public entities are supported; nsec is never accepted from
+// clients (see nip49.go for reading the relay's own key).
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// NIP-19 TLV types
+const (
+	tlvSpecial = 0
+	tlvRelay   = 1
+	tlvAuthor  = 2
+	tlvKind    = 3
+)
+
+// DecodeNIP19 decodes an npub, note, nprofile, nevent or naddr entity. The
+// value is a hex string for npub and note, and a nostr.ProfilePointer,
+// nostr.EventPointer or nostr.EntityPointer for the TLV entities.
+func DecodeNIP19(entity string) (string, interface{}, error) {
+	prefix, data, err := bech32Decode(entity)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch prefix {
+	case "npub", "note":
+		if len(data) != 32 {
+			return prefix, nil, fmt.Errorf("%s should hold 32 bytes, got %d", prefix, len(data))
+		}
+		return prefix, hex.EncodeToString(data), nil
+	case "nprofile", "nevent", "naddr":
+		return decodeTLVEntity(prefix, data)
+	default:
+		return prefix, nil, fmt.Errorf("unsupported NIP-19 prefix %q", prefix)
+	}
+}
+
+// EncodeNpub encodes a hex pubkey as npub.
+func EncodeNpub(pubkey string) (string, error) {
+	return encodeHex32("npub", pubkey)
+}
+
+// EncodeNote encodes a hex event ID as note.
+func EncodeNote(eventID string) (string, error) {
+	return encodeHex32("note", eventID)
+}
+
+// EncodeNaddr encodes the address of an addressable event as naddr, with
+// optional relay hints.
+func EncodeNaddr(kind int, pubkey, identifier string, relays ...string) (string, error) {
+	author, err := hex.DecodeString(pubkey)
+	if err != nil || len(author) != 32 {
+		return "", fmt.Errorf("naddr needs a 64 hex character pubkey")
+	}
+	if len(identifier) > 255 {
+		return "", fmt.Errorf("naddr identifier longer than 255 bytes")
+	}
+
+	data := appendTLV(nil, tlvSpecial, []byte(identifier))
+	for _, relay := range relays {
+		if len(relay) <= 255 {
+			data = appendTLV(data, tlvRelay, []byte(relay))
+		}
+	}
+	data = appendTLV(data, tlvAuthor, author)
+	data = appendTLV(data, tlvKind, binary.BigEndian.AppendUint32(nil, uint32(kind)))
+	return bech32Encode("naddr", data), nil
+}
+
+// appendTLV appends one TLV record to data; value must fit in 255 bytes.
+func appendTLV(data []byte, typ byte, value []byte) []byte {
+	data = append(data, typ, byte(len(value)))
+	return append(data, value...)
+}
+
+func encodeHex32(prefix, value string) (string, error) {
+	data, err := hex.DecodeString(value)
+	if err != nil || len(data) != 32 {
+		return "", fmt.Errorf("%s needs 64 hex characters", prefix)
+	}
+	return bech32Encode(prefix, data), nil
+}
+
+// decodeTLVEntity reads the TLV records of an nprofile, nevent or naddr.
+func decodeTLVEntity(prefix string, data []byte) (string, interface{}, error) {
+	var special []byte
+	var relays []string
+	var author string
+	kind := -1
+	for len(data) > 0 {
+		if len(data) < 2 || len(data) < 2+int(data[1]) {
+			return prefix, nil, fmt.Errorf("truncated TLV record in %s", prefix)
+		}
+		typ, value := data[0], data[2:2+int(data[1])]
+		data = data[2+len(value):]
+
+		switch typ {
+		case tlvSpecial:
+			special = value
+		case tlvRelay:
+			relays = append(relays, string(value))
+		case tlvAuthor:
+			if len(value) != 32 {
+				return prefix, nil, fmt.Errorf("author in %s should hold 32 bytes", prefix)
+			}
+			author = hex.EncodeToString(value)
+		case tlvKind:
+			if len(value) != 4 {
+				return prefix, nil, fmt.Errorf("kind in %s should hold 4 bytes", prefix)
+			}
+			kind = int(binary.BigEndian.Uint32(value))
+		}
+	}
+
+	switch prefix {
+	case "nprofile":
+		if len(special) != 32 {
+			return prefix, nil, fmt.Errorf("nprofile without a 32 byte pubkey")
+		}
+		return prefix, nostr.ProfilePointer{PublicKey: hex.EncodeToString(special), Relays: relays}, nil
+	case "nevent":
+		if len(special) != 32 {
+			return prefix, nil, fmt.Errorf("nevent without a 32 byte event ID")
+		}
+		ptr := nostr.EventPointer{ID: hex.EncodeToString(special), Relays: relays, Author: author}
+		if kind >= 0 {
+			ptr.Kind = kind
+		}
+		return prefix, ptr, nil
+	default: // naddr
+		if author == "" || kind < 0 {
+			return prefix, nil, fmt.Errorf("naddr needs an author and a kind")
+		}
+		return prefix, nostr.EntityPointer{PublicKey: author, Kind: kind, Identifier: string(special), Relays: relays}, nil
+	}
+}
+
+// bech32Decode decodes a bech32 string (BIP-173) into its prefix and 8-bit data.
+// NIP-19 entities may exceed BIP-173's 90 character limit.
+func bech32Decode(s string) (string, []byte, error) {
+	prefix, values, err := bech32DecodeValues(s)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := convertBits(values, 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return prefix, data, nil
+}
+
+// bech32DecodeValues decodes a bech32 string into its prefix and 5-bit
+// values, the checksum verified and removed.
+func bech32DecodeValues(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, fmt.Errorf("mixed case in bech32 string")
+	}
+	s = strings.ToLower(s)
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, fmt.Errorf("invalid bech32 string")
+	}
+	prefix := s[:sep]
+
+	values := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			return "", nil, fmt.Errorf("invalid bech32 character %q", s[i])
+		}
+		values = append(values, byte(v))
+	}
+	if bech32Polymod(append(bech32HRPExpand(prefix), values...)) != 1 {
+		return "", nil, fmt.Errorf("invalid bech32 checksum")
+	}
+	return prefix, values[:len(values)-6], nil
+}
+
+// bech32Encode encodes 8-bit data under prefix.
+func bech32Encode(prefix string, data []byte) string {
+	values, _ := convertBits(data, 8, 5, true)
+	checksumInput := append(bech32HRPExpand(prefix), values...)
+	checksumInput = append(checksumInput, 0, 0, 0, 0, 0, 0)
+	mod := bech32Polymod(checksumInput) ^ 1
+
+	var b strings.Builder
+	b.Grow(len(prefix) + 1 + len(values) + 6)
+	b.WriteString(prefix)
+	b.WriteByte('1')
+	for _, v := range values {
+		b.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		b.WriteByte(bech32Charset[(mod>>(5*(5-i)))&31])
+	}
+	return b.String()
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(prefix string) []byte {
+	out := make([]byte, 0, len(prefix)*2+1)
+	for i := 0; i < len(prefix); i++ {
+		out = append(out, prefix[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(prefix); i++ {
+		out = append(out, prefix[i]&31)
+	}
+	return out
+}
+
+// convertBits regroups data from fromBits-bit to toBits-bit values.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, v := range data {
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte((acc>>bits)&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte((acc<<(toBits-bits))&maxv))
+		}
+	} else if bits >= fromBits || (acc<<(toBits-bits))&maxv != 0 {
+		return nil, fmt.Errorf("invalid bech32 padding")
+	}
+	return out, nil
+}
@@ -7,9 +7,14 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "20", Name: "Command Results", Kinds: []int{24133}, Hidden: true})
+}
+
 // CommandResultType defines the type of command result
 type CommandResultType string
 
@@ -3,9 +3,14 @@ package nips
 import (
 	"fmt"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "22", Name: "Comment", Kinds: []int{1111}})
+}
+
 // NIP-22: Comment
 // https://github.com/nostr-protocol/nips/blob/master/22.md
 
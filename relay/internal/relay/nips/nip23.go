@@ -3,9 +3,14 @@ package nips
 import (
 	"fmt"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "23", Name: "Long-form Content", Kinds: []int{30023, 30024}})
+}
+
 // NIP-23: Long-form Content
 // https://github.com/nostr-protocol/nips/blob/master/23.md
 
@@ -3,9 +3,14 @@ package nips
 import (
 	"fmt"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "24", Name: "Extra Metadata"})
+}
+
 // NIP-24: Extra metadata fields and tags
 // https://github.com/nostr-protocol/nips/blob/master/24.md
 
@@ -2,9 +2,14 @@ package nips
 
 import (
 	"github.com/Shugur-Network/relay/internal/relay/nips/common"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "25", Name: "Reactions", Kinds: []int{7}})
+}
+
 // NIP-25: Reactions
 // https://github.com/nostr-protocol/nips/blob/master/25.md
 
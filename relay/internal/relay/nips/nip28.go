@@ -6,10 +6,15 @@ import (
 	"strings"
 
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "28", Name: "Public Chat", Kinds: []int{40, 41, 42, 43, 44}})
+}
+
 // NIP-28: Public Chat
 // https://github.com/nostr-protocol/nips/blob/master/28.md
 //
@@ -0,0 +1,73 @@
+package nips
+
+import (
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-32: Labeling
+// https://github.com/nostr-protocol/nips/blob/master/32.md
+
+// KindLabel is the NIP-32 label event kind.
+const KindLabel = 1985
+
+// LabelNamespaceUGC is the namespace of l tags that name none.
+const LabelNamespaceUGC = "ugc"
+
+// LabelTargetTypes are the tags a label event points at its targets with:
+// events, pubkeys, addressable events, URLs and topics.
+var LabelTargetTypes = []string{"e", "p", "a", "r", "t"}
+
+// Label is a namespaced label value.
+type Label struct {
+	Namespace string
+	Value     string
+}
+
+// LabelTarget is something a label event labels.
+type LabelTarget struct {
+	Type  string // one of LabelTargetTypes
+	Value string
+}
+
+// LabelsOf returns the labels a label event applies and the targets it
+// applies them to. An l tag's namespace is its mark, "ugc" when it has none;
+// a marked l tag whose namespace has no L tag is ignored.
+func LabelsOf(evt *nostr.Event) ([]Label, []LabelTarget) {
+	if evt.Kind != KindLabel {
+		return nil, nil
+	}
+	namespaces := make(map[string]bool)
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "L" {
+			namespaces[tag[1]] = true
+		}
+	}
+	var labels []Label
+	var targets []LabelTarget
+	seen := make(map[[3]string]bool)
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[1] == "" {
+			continue
+		}
+		switch tag[0] {
+		case "l":
+			label := Label{Namespace: LabelNamespaceUGC, Value: tag[1]}
+			if len(tag) >= 3 && tag[2] != "" {
+				if !namespaces[tag[2]] {
+					continue
+				}
+				label.Namespace = tag[2]
+			}
+			if key := [3]string{"l", label.Namespace, label.Value}; !seen[key] {
+				seen[key] = true
+				labels = append(labels, label)
+			}
+		case "e", "p", "a", "r", "t":
+			if key := [3]string{tag[0], tag[1]}; !seen[key] {
+				seen[key] = true
+				targets = append(targets, LabelTarget{Type: tag[0], Value: tag[1]})
+			}
+		}
+	}
+	return labels, targets
+}
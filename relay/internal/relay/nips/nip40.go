@@ -4,9 +4,14 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "40", Name: "Expiration"})
+}
+
 // GetExpirationTime extracts the expiration timestamp from an event
 // Returns the expiration time and true if found, or zero time and false if not found
 func GetExpirationTime(evt nostr.Event) (time.Time, bool) {
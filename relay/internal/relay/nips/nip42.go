@@ -6,11 +6,16 @@ import (
 	"fmt"
 
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip42"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "42", Name: "Authentication"})
+}
+
 // GenerateAuthChallenge creates a random hex challenge string for NIP-42 AUTH.
 func GenerateAuthChallenge() (string, error) {
 	b := make([]byte, 32)
@@ -7,9 +7,14 @@ import (
 	"fmt"
 
 	"github.com/Shugur-Network/relay/internal/relay/nips/common"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "44", Name: "Encrypted Payloads"})
+}
+
 const (
 	NIP44Version1 = 1
 	NIP44Version2 = 2
@@ -10,10 +10,15 @@ import (
 	"time"
 
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "45", Name: "Event Counts"})
+}
+
 // NIP-45: COUNT Command with HyperLogLog support
 // https://github.com/nostr-protocol/nips/blob/master/45.md
 
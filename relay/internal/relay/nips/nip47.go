@@ -0,0 +1,87 @@
+package nips
+
+import (
+	"fmt"
+
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+func init() {
+	registry.Register(registry.NIP{ID: "47", Name: "Wallet Connect", Kinds: []int{13194}})
+}
+
+// NIP-47: Nostr Wallet Connect
+// https://github.com/nostr-protocol/nips/blob/master/47.md
+
+// Wallet Connect event kinds
+const (
+	KindWalletConnectInfo         = 13194 // Wallet service capabilities (replaceable)
+	KindWalletConnectRequest      = 23194 // Client -> wallet service request
+	KindWalletConnectResponse     = 23195 // Wallet service -> client response
+	KindWalletConnectNotification = 23197 // Wallet service -> client notification (NIP-44)
+	KindWalletConnectLegacyNotif  = 23196 // Wallet service -> client notification (NIP-04)
+)
+
+// IsWalletConnectMessage reports whether a kind is an addressed NWC request,
+// response or notification (every kind but the info event).
+func IsWalletConnectMessage(kind int) bool {
+	switch kind {
+	case KindWalletConnectRequest, KindWalletConnectResponse,
+		KindWalletConnectNotification, KindWalletConnectLegacyNotif:
+		return true
+	}
+	return false
+}
+
+// ValidateWalletConnectMessage validates NIP-47 requests, responses and notifications
+func ValidateWalletConnectMessage(evt *nostr.Event) error {
+	if !IsWalletConnectMessage(evt.Kind) {
+		return fmt.Errorf("invalid event kind for wallet connect: %d", evt.Kind)
+	}
+
+	// Exactly one "p" tag naming the other side of the connection
+	recipients := 0
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			if len(tag[1]) != 64 {
+				return fmt.Errorf("invalid pubkey in 'p' tag: %s", tag[1])
+			}
+			recipients++
+		}
+	}
+	if recipients != 1 {
+		return fmt.Errorf("wallet connect events must have exactly one 'p' tag")
+	}
+
+	// Responses reference the request they answer
+	if evt.Kind == KindWalletConnectResponse {
+		hasETag := false
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "e" {
+				hasETag = true
+				break
+			}
+		}
+		if !hasETag {
+			return fmt.Errorf("wallet connect response must have 'e' tag with the request id")
+		}
+	}
+
+	// Payloads are always encrypted
+	if evt.Content == "" {
+		return fmt.Errorf("wallet connect events must have encrypted content")
+	}
+
+	return nil
+}
+
+// WalletConnectRecipient returns the pubkey a wallet connect message is addressed to
+func WalletConnectRecipient(evt *nostr.Event) string {
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			return tag[1]
+		}
+	}
+	return ""
+}
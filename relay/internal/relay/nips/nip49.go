@@ -0,0 +1,105 @@
+package nips
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NIP-49: private key encryption
+// https://github.com/nostr-protocol/nips/blob/master/49.md
+//
+// Used for the relay's own signing key keystore; secret keys are never accepted
+// from clients.
+
+// ncryptsecVersion is the only NIP-49 payload version.
+const ncryptsecVersion = 0x02
+
+// keySecurityNotKnownInsecure marks a key that has not been handled
+// insecurely, such as one generated by the relay itself.
+const keySecurityNotKnownInsecure = 0x01
+
+// DecodeNsec decodes an nsec entity into a hex private key.
+func DecodeNsec(nsec string) (string, error) {
+	prefix, data, err := bech32Decode(nsec)
+	if err != nil {
+		return "", err
+	}
+	if prefix != "nsec" || len(data) != 32 {
+		return "", fmt.Errorf("not an nsec key")
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// EncryptNcryptsec encrypts the hex private key sk with password at a scrypt
+// cost of 2^logN, returning an ncryptsec entity.
+func EncryptNcryptsec(sk, password string, logN uint8) (string, error) {
+	skBytes, err := hex.DecodeString(sk)
+	if err != nil || len(skBytes) != 32 {
+		return "", fmt.Errorf("private key must be 32 bytes of hex")
+	}
+	if logN > 30 {
+		return "", fmt.Errorf("unsupported ncryptsec scrypt cost 2^%d", logN)
+	}
+
+	data := make([]byte, 1+1+16+24+1, 1+1+16+24+1+48)
+	data[0], data[1] = ncryptsecVersion, logN
+	if _, err := rand.Read(data[2:42]); err != nil {
+		return "", err
+	}
+	data[42] = keySecurityNotKnownInsecure
+	salt, nonce, ad := data[2:18], data[18:42], data[42:43]
+
+	key, err := scrypt.Key([]byte(norm.NFKC.String(password)), salt, 1<<logN, 8, 1, 32)
+	if err != nil {
+		return "", err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", err
+	}
+	data = aead.Seal(data, nonce, skBytes, ad)
+	return bech32Encode("ncryptsec", data), nil
+}
+
+// DecryptNcryptsec decrypts an ncryptsec entity with password, returning
+// the hex private key.
+func DecryptNcryptsec(ncryptsec, password string) (string, error) {
+	prefix, data, err := bech32Decode(ncryptsec)
+	if err != nil {
+		return "", err
+	}
+	if prefix != "ncryptsec" {
+		return "", fmt.Errorf("not an ncryptsec key")
+	}
+	// version, log_n, salt, nonce, key security byte, ciphertext
+	if len(data) != 1+1+16+24+1+48 {
+		return "", fmt.Errorf("ncryptsec should hold 91 bytes, got %d", len(data))
+	}
+	if data[0] != ncryptsecVersion {
+		return "", fmt.Errorf("unsupported ncryptsec version %d", data[0])
+	}
+	logN := data[1]
+	if logN > 30 {
+		return "", fmt.Errorf("unsupported ncryptsec scrypt cost 2^%d", logN)
+	}
+	salt, nonce, ad, ciphertext := data[2:18], data[18:42], data[42:43], data[43:]
+
+	key, err := scrypt.Key([]byte(norm.NFKC.String(password)), salt, 1<<logN, 8, 1, 32)
+	if err != nil {
+		return "", err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", err
+	}
+	sk, err := aead.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return "", fmt.Errorf("wrong password or corrupted ncryptsec")
+	}
+	return hex.EncodeToString(sk), nil
+}
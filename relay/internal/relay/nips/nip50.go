@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "50", Name: "Search"})
+}
+
 // SearchOptions represents the search configuration for NIP-50
 type SearchOptions struct {
 	CaseSensitive bool // Whether search should be case sensitive
@@ -5,10 +5,18 @@ import (
 	"strings"
 
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "51", Name: "Lists", Kinds: []int{
+		10000, 10001, 10003, 10004, 10005, 10006, 10007, 10009, 10012, 10015, 10020, 10030, 10101, 10102, // Standard lists
+		30000, 30001, 30002, 30003, 30004, 30005, 30007, 30015, 30030, 30063, 30267, 39089, 39092, // Sets
+	}})
+}
+
 // NIP-51: Lists
 // https://github.com/nostr-protocol/nips/blob/master/51.md
 //
@@ -10,9 +10,14 @@ import (
 
 	nostr "github.com/nbd-wtf/go-nostr"
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "52", Name: "Calendar Events", Kinds: []int{31922, 31923, 31924, 31925}})
+}
+
 // ValidateDateBasedCalendarEvent validates NIP-52 date-based calendar events (kind 31922)
 func ValidateDateBasedCalendarEvent(event *nostr.Event) error {
 	// Basic validation
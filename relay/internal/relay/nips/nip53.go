@@ -10,9 +10,14 @@ import (
 
 	nostr "github.com/nbd-wtf/go-nostr"
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "53", Name: "Live Activities", Kinds: []int{30311, 1311, 30312, 30313, 10312}})
+}
+
 // ValidateLiveStreamingEvent validates NIP-53 live streaming events (kind 30311)
 func ValidateLiveStreamingEvent(event *nostr.Event) error {
 	// Basic validation
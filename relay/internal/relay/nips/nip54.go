@@ -6,9 +6,14 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	"github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "54", Name: "Wiki", Kinds: []int{30818, 818, 30819}})
+}
+
 // ValidateWikiArticle validates NIP-54 wiki article events (kind 30818)
 func ValidateWikiArticle(event *nostr.Event) error {
 	if event.Kind != 30818 {
@@ -679,4 +684,17 @@ func validateBasicEvent(event *nostr.Event) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// NIP-54 event kinds
+const (
+	KindWikiArticle      = 30818
+	KindWikiMergeRequest = 818
+	KindWikiRedirect     = 30819
+)
+
+// NormalizeWikiTopic returns the d tag of the wiki articles about topic:
+// lowercase letters with every run of other characters turned into one dash.
+func NormalizeWikiTopic(topic string) string {
+	return normalizeDTag(topic)
+}
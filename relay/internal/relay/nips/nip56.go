@@ -6,9 +6,14 @@ import (
 
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "56", Name: "Reporting", Kinds: []int{1984}})
+}
+
 // ValidateReport validates NIP-56 report events (kind 1984)
 func ValidateReport(event *nostr.Event) error {
 	// Basic validation
@@ -1,15 +1,23 @@
 package nips
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/Shugur-Network/relay/internal/relay/nips/common"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	"github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "57", Name: "Lightning Zaps", Kinds: []int{9734, 9735}})
+}
+
 // isHexChar checks if a character is a valid hexadecimal character
 func isHexChar(char rune) bool {
 	return (char >= '0' && char <= '9') || (char >= 'a' && char <= 'f') || (char >= 'A' && char <= 'F')
@@ -48,6 +56,85 @@ func ValidateZapReceipt(event *nostr.Event) error {
 	)
 }
 
+// VerifyZapReceipt checks a structurally valid zap receipt against the zap
+// request it embeds: the invoice's description hash must commit to the
+// request, the invoice amount must match the request's amount tag and the
+// receipt must name the request's recipient and zapped event. It returns the
+// embedded zap request.
+func VerifyZapReceipt(event *nostr.Event) (*nostr.Event, error) {
+	bolt11 := GetTagValue(*event, "bolt11")
+	description := GetTagValue(*event, "description")
+
+	invoice, err := DecodeBolt11(bolt11)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bolt11 invoice: %w", err)
+	}
+	if invoice.DescriptionHash == "" {
+		return nil, fmt.Errorf("bolt11 invoice must carry a description hash")
+	}
+	hash := sha256.Sum256([]byte(description))
+	if invoice.DescriptionHash != hex.EncodeToString(hash[:]) {
+		return nil, fmt.Errorf("bolt11 description hash does not match the zap request")
+	}
+
+	var request nostr.Event
+	if err := json.Unmarshal([]byte(description), &request); err != nil {
+		return nil, fmt.Errorf("description must be a valid nostr event: %w", err)
+	}
+	if ok, err := request.CheckSignature(); err != nil || !ok {
+		return nil, fmt.Errorf("zap request signature is invalid")
+	}
+
+	if amount := GetTagValue(request, "amount"); amount != "" {
+		if strconv.FormatInt(invoice.AmountMsat, 10) != amount {
+			return nil, fmt.Errorf("bolt11 amount %d msat does not match the requested %s msat", invoice.AmountMsat, amount)
+		}
+	}
+	if GetTagValue(*event, "p") != GetTagValue(request, "p") {
+		return nil, fmt.Errorf("zap receipt p tag does not match the zap request")
+	}
+	if e := GetTagValue(request, "e"); e != "" && GetTagValue(*event, "e") != e {
+		return nil, fmt.Errorf("zap receipt e tag does not match the zap request")
+	}
+
+	return &request, nil
+}
+
+// ZapEndpoint returns the LNURL-pay endpoint of a kind 0 profile, from its
+// lud16 lightning address or else its lud06 bech32 LNURL, or "" when the
+// profile names neither.
+func ZapEndpoint(profile *nostr.Event) (string, error) {
+	var metadata struct {
+		LUD06 string `json:"lud06"`
+		LUD16 string `json:"lud16"`
+	}
+	if err := json.Unmarshal([]byte(profile.Content), &metadata); err != nil {
+		return "", fmt.Errorf("invalid profile metadata: %w", err)
+	}
+
+	if address := strings.TrimSpace(metadata.LUD16); address != "" {
+		name, domain, ok := strings.Cut(address, "@")
+		if !ok || name == "" || domain == "" || strings.ContainsAny(domain, "/@?#") {
+			return "", fmt.Errorf("invalid lud16 address %q", address)
+		}
+		return "https://" + domain + "/.well-known/lnurlp/" + url.PathEscape(strings.ToLower(name)), nil
+	}
+
+	if lnurl := strings.TrimSpace(metadata.LUD06); lnurl != "" {
+		prefix, data, err := bech32Decode(lnurl)
+		if err != nil || prefix != "lnurl" {
+			return "", fmt.Errorf("invalid lud06 LNURL")
+		}
+		endpoint := string(data)
+		if u, err := url.Parse(endpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+			return "", fmt.Errorf("lud06 LNURL must encode an https URL")
+		}
+		return endpoint, nil
+	}
+
+	return "", nil
+}
+
 // validateZapRequestTags validates the tag structure for zap request events
 func validateZapRequestTags(helper *common.ValidationHelper, event *nostr.Event) error {
 	var hasRelaysTag bool
@@ -8,9 +8,14 @@ import (
 	"strings"
 
 	"github.com/Shugur-Network/relay/internal/relay/nips/common"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "58", Name: "Badges", Kinds: []int{8, 30008, 30009}})
+}
+
 // ValidateBadgeDefinition validates NIP-58 badge definition events (kind 30009)
 func ValidateBadgeDefinition(event *nostr.Event) error {
 	return common.ValidateEventWithCallback(
@@ -63,6 +68,20 @@ func ValidateProfileBadges(event *nostr.Event) error {
 	)
 }
 
+// BadgeAwardDefinition returns the author and d tag of the badge definition
+// a badge award references, or empty strings when it has no a tag.
+func BadgeAwardDefinition(event *nostr.Event) (pubkey, d string) {
+	for _, tag := range event.Tags {
+		if len(tag) >= 2 && tag[0] == "a" {
+			parts := strings.SplitN(tag[1], ":", 3)
+			if len(parts) == 3 {
+				return parts[1], parts[2]
+			}
+		}
+	}
+	return "", ""
+}
+
 // validateBadgeDefinitionTags validates tags for badge definition events
 func validateBadgeDefinitionTags(helper *common.ValidationHelper, event *nostr.Event) error {
 	var hasDTag bool
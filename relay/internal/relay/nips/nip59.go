@@ -3,9 +3,14 @@ package nips
 import (
 	"fmt"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "59", Name: "Gift Wrap", Kinds: []int{1059}})
+}
+
 // NIP-59: Gift Wrap
 // https://github.com/nostr-protocol/nips/blob/master/59.md
 
@@ -8,9 +8,14 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	"github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "60", Name: "Cashu Wallets", Kinds: []int{17375, 7375, 7376, 7374}})
+}
+
 // NIP-60: Cashu Wallets
 // https://github.com/nostr-protocol/nips/blob/master/60.md
 //
@@ -4,9 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	"github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "61", Name: "Nutzaps", Kinds: []int{9321, 10019}})
+}
+
 // NIP-61: Nutzaps
 // Validates nutzap info events (kind 10019) and nutzap events (kind 9321)
 
@@ -5,10 +5,15 @@ import (
 	"strings"
 
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "62", Name: "Request to Vanish", Kinds: []int{62}})
+}
+
 // NIP-62: Request to Vanish
 // https://github.com/nostr-protocol/nips/blob/master/62.md
 
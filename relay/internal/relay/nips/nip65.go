@@ -6,9 +6,14 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "65", Name: "Relay List Metadata", Kinds: []int{10002}})
+}
+
 // NIP-65: Relay List Metadata
 // https://github.com/nostr-protocol/nips/blob/master/65.md
 
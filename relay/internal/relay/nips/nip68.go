@@ -0,0 +1,136 @@
+package nips
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+func init() {
+	registry.Register(registry.NIP{ID: "68", Name: "Picture-first Feeds", Kinds: []int{KindPicture}})
+}
+
+// KindPicture is the NIP-68 picture-first event kind.
+const KindPicture = 20
+
+const (
+	// maxPictureImages bounds the imeta images of one picture event.
+	maxPictureImages = 20
+	// maxPictureDescription bounds the content, a description of the pictures.
+	maxPictureDescription = 10000
+)
+
+// ValidatePictureEvent validates NIP-68 picture-first events (kind 20)
+func ValidatePictureEvent(event *nostr.Event) error {
+	// Basic validation
+	if event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	logger.Debug("NIP-68: Validating picture event",
+		zap.String("event_id", event.ID),
+		zap.String("pubkey", event.PubKey))
+
+	if event.Kind != KindPicture {
+		return fmt.Errorf("invalid kind for picture event: expected 20, got %d", event.Kind)
+	}
+
+	if len(event.Content) > maxPictureDescription {
+		return fmt.Errorf("picture description too long (max %d characters)", maxPictureDescription)
+	}
+
+	// Validate required and optional tags
+	if err := validatePictureEventTags(event); err != nil {
+		return fmt.Errorf("invalid picture event tags: %w", err)
+	}
+
+	logger.Debug("NIP-68: Picture event validation successful",
+		zap.String("event_id", event.ID))
+	return nil
+}
+
+// validatePictureEventTags validates tags for picture events
+func validatePictureEventTags(event *nostr.Event) error {
+	var hasTitleTag bool
+	var images int
+
+	for _, tag := range event.Tags {
+		if len(tag) == 0 {
+			continue
+		}
+
+		switch tag[0] {
+		case "title":
+			if err := validateMediaTitleTag(tag); err != nil {
+				return err
+			}
+			hasTitleTag = true
+		case "imeta":
+			if err := validatePictureImageTag(tag); err != nil {
+				return err
+			}
+			images++
+		case "m":
+			// Media type filter tags name the image types used
+			if len(tag) != 2 || !strings.HasPrefix(strings.ToLower(tag[1]), "image/") {
+				return fmt.Errorf("m tag must name an image/* MIME type")
+			}
+		case "x":
+			if len(tag) != 2 || !isHexChar64(tag[1]) {
+				return fmt.Errorf("x tag must be a 64-character hex sha256")
+			}
+		case "t":
+			if err := validateHashtagTag(tag); err != nil {
+				return err
+			}
+		case "g":
+			if err := validateGeohashTag(tag); err != nil {
+				return err
+			}
+		default:
+			// Other tags are allowed
+		}
+	}
+
+	// Required tags validation
+	if !hasTitleTag {
+		return fmt.Errorf("picture event must have a title tag")
+	}
+	if images == 0 {
+		return fmt.Errorf("picture event must have at least one imeta tag")
+	}
+	if images > maxPictureImages {
+		return fmt.Errorf("too many images (max %d)", maxPictureImages)
+	}
+
+	return nil
+}
+
+// validatePictureImageTag validates one imeta image: an image URL with an
+// image/* MIME type, its sha256 hash and its dimensions.
+func validatePictureImageTag(tag nostr.Tag) error {
+	meta, err := validateIMetaTag(tag)
+	if err != nil {
+		return err
+	}
+
+	mimeType := strings.ToLower(meta.Get("m"))
+	if mimeType == "" {
+		return fmt.Errorf("picture imeta must have an m (MIME type) field")
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return fmt.Errorf("picture imeta MIME type must be image/*, got %s", mimeType)
+	}
+	if meta.Get("x") == "" {
+		return fmt.Errorf("picture imeta must have an x (sha256 hash) field")
+	}
+	if meta.Get("dim") == "" {
+		return fmt.Errorf("picture imeta must have a dim (dimensions) field")
+	}
+
+	return nil
+}
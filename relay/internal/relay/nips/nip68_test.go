@@ -0,0 +1,95 @@
+package nips
+
+import (
+	"strings"
+	"testing"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	testImageHash = "d2b2d6bd1a2d4ee2c5f7a62e11b8ae5d9c1f0d1b2e3f4a5b6c7d8e9f0a1b2c3d"
+	testBlurhash  = "LEHV6nWB2yk8pyo0adR*.7kCMdnj"
+)
+
+// testImeta returns a valid picture imeta tag with fields replaced or, when
+// the value is empty, removed.
+func testImeta(fields ...string) nostr.Tag {
+	values := map[string]string{
+		"url": "https://example.com/photo.jpg",
+		"m":   "image/jpeg",
+		"x":   testImageHash,
+		"dim": "3024x4032",
+	}
+	order := []string{"url", "m", "x", "dim"}
+	for i := 0; i+1 < len(fields); i += 2 {
+		if _, ok := values[fields[i]]; !ok {
+			order = append(order, fields[i])
+		}
+		values[fields[i]] = fields[i+1]
+	}
+	tag := nostr.Tag{"imeta"}
+	for _, name := range order {
+		if values[name] != "" {
+			tag = append(tag, name+" "+values[name])
+		}
+	}
+	return tag
+}
+
+func TestValidatePictureEvent(t *testing.T) {
+	title := nostr.Tag{"title", "Sunset"}
+	manyImages := nostr.Tags{title}
+	for i := 0; i <= maxPictureImages; i++ {
+		manyImages = append(manyImages, testImeta())
+	}
+
+	tests := []struct {
+		name    string
+		kind    int
+		content string
+		tags    nostr.Tags
+		wantErr string
+	}{
+		{"minimal", KindPicture, "", nostr.Tags{title, testImeta()}, ""},
+		{"blurhash and filters", KindPicture, "At the beach", nostr.Tags{
+			title, testImeta("blurhash", testBlurhash, "alt", "A sunset"),
+			{"m", "image/jpeg"}, {"x", testImageHash}, {"t", "sunset"}, {"g", "u4pruyd"},
+		}, ""},
+		{"several images", KindPicture, "", nostr.Tags{title, testImeta(), testImeta("url", "https://example.com/2.png", "m", "image/png")}, ""},
+		{"wrong kind", 1, "", nostr.Tags{title, testImeta()}, "invalid kind"},
+		{"description too long", KindPicture, strings.Repeat("a", maxPictureDescription+1), nostr.Tags{title, testImeta()}, "description too long"},
+		{"no title", KindPicture, "", nostr.Tags{testImeta()}, "must have a title tag"},
+		{"empty title", KindPicture, "", nostr.Tags{{"title", " "}, testImeta()}, "title cannot be empty"},
+		{"no imeta", KindPicture, "", nostr.Tags{title}, "at least one imeta tag"},
+		{"too many images", KindPicture, "", manyImages, "too many images"},
+		{"no url", KindPicture, "", nostr.Tags{title, testImeta("url", "")}, "exactly one url"},
+		{"non-http url", KindPicture, "", nostr.Tags{title, testImeta("url", "ftp://example.com/photo.jpg")}, "http or https"},
+		{"no mime type", KindPicture, "", nostr.Tags{title, testImeta("m", "")}, "must have an m"},
+		{"video mime type", KindPicture, "", nostr.Tags{title, testImeta("m", "video/mp4")}, "must be image/*"},
+		{"no hash", KindPicture, "", nostr.Tags{title, testImeta("x", "")}, "must have an x"},
+		{"bad hash", KindPicture, "", nostr.Tags{title, testImeta("x", "abc")}, "64-character hex"},
+		{"no dimensions", KindPicture, "", nostr.Tags{title, testImeta("dim", "")}, "must have a dim"},
+		{"bad dimensions", KindPicture, "", nostr.Tags{title, testImeta("dim", "wide")}, "invalid imeta dim"},
+		{"zero dimensions", KindPicture, "", nostr.Tags{title, testImeta("dim", "0x100")}, "invalid imeta dim"},
+		{"blurhash bad character", KindPicture, "", nostr.Tags{title, testImeta("blurhash", "LEHV6nWB2yk8pyo0adR*.7kCMdn\"")}, "invalid character"},
+		{"blurhash bad length", KindPicture, "", nostr.Tags{title, testImeta("blurhash", testBlurhash[:20])}, "does not match"},
+		{"non-image m filter", KindPicture, "", nostr.Tags{title, testImeta(), {"m", "video/mp4"}}, "image/* MIME type"},
+		{"bad x filter", KindPicture, "", nostr.Tags{title, testImeta(), {"x", "xyz"}}, "64-character hex"},
+		{"bad geohash", KindPicture, "", nostr.Tags{title, testImeta(), {"g", "abc!"}}, "invalid geohash"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			evt := &nostr.Event{Kind: tc.kind, Content: tc.content, Tags: tc.tags}
+			err := ValidatePictureEvent(evt)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && err == nil:
+				t.Fatalf("expected error containing %q", tc.wantErr)
+			case tc.wantErr != "" && !strings.Contains(err.Error(), tc.wantErr):
+				t.Fatalf("error %q does not contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
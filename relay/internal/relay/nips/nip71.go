@@ -0,0 +1,239 @@
+package nips
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+func init() {
+	registry.Register(registry.NIP{ID: "71", Name: "Video Events", Kinds: []int{KindVideo, KindShortVideo, KindAddressableVideo, KindAddressableShortVideo}})
+}
+
+// NIP-71 video event kinds
+const (
+	KindVideo                 = 21    // Normal video
+	KindShortVideo            = 22    // Short-form portrait video
+	KindAddressableVideo      = 34235 // Addressable normal video
+	KindAddressableShortVideo = 34236 // Addressable short-form video
+)
+
+// maxVideoVariants bounds the imeta variants of one video event.
+const maxVideoVariants = 20
+
+// videoSegmentTimestamp matches segment start/end times (HH:MM:SS.sss).
+var videoSegmentTimestamp = regexp.MustCompile(`^\d{2}:[0-5]\d:[0-5]\d(\.\d{1,3})?$`)
+
+// streamingPlaylistTypes are the non-video MIME types a video variant may use.
+var streamingPlaylistTypes = map[string]bool{
+	"application/x-mpegurl":         true, // HLS
+	"application/vnd.apple.mpegurl": true, // HLS
+	"application/dash+xml":          true, // MPEG-DASH
+}
+
+// IsVideoEvent reports whether kind is a NIP-71 video kind.
+func IsVideoEvent(kind int) bool {
+	switch kind {
+	case KindVideo, KindShortVideo, KindAddressableVideo, KindAddressableShortVideo:
+		return true
+	}
+	return false
+}
+
+// ValidateVideoEvent validates NIP-71 video events (kinds 21, 22, 34235 and 34236)
+func ValidateVideoEvent(event *nostr.Event) error {
+	// Basic validation
+	if event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	logger.Debug("NIP-71: Validating video event",
+		zap.String("event_id", event.ID),
+		zap.String("pubkey", event.PubKey),
+		zap.Int("kind", event.Kind))
+
+	if !IsVideoEvent(event.Kind) {
+		return fmt.Errorf("invalid kind for video event: expected 21, 22, 34235 or 34236, got %d", event.Kind)
+	}
+
+	// Validate required and optional tags
+	if err := validateVideoEventTags(event); err != nil {
+		return fmt.Errorf("invalid video event tags: %w", err)
+	}
+
+	logger.Debug("NIP-71: Video event validation successful",
+		zap.String("event_id", event.ID))
+	return nil
+}
+
+// validateVideoEventTags validates tags for video events
+func validateVideoEventTags(event *nostr.Event) error {
+	addressable := event.Kind == KindAddressableVideo || event.Kind == KindAddressableShortVideo
+	var hasDTag bool
+	var hasTitleTag bool
+	var variants int
+	var hasLegacyURL bool
+
+	for _, tag := range event.Tags {
+		if len(tag) == 0 {
+			continue
+		}
+
+		switch tag[0] {
+		case "d":
+			if err := validateCalendarDTag(tag); err != nil {
+				return err
+			}
+			hasDTag = true
+		case "title":
+			if err := validateMediaTitleTag(tag); err != nil {
+				return err
+			}
+			hasTitleTag = true
+		case "published_at":
+			if err := validateTimestampTag(tag, "published_at"); err != nil {
+				return err
+			}
+		case "imeta":
+			if err := validateVideoVariantTag(tag); err != nil {
+				return err
+			}
+			variants++
+		case "url":
+			// Addressable videos published before imeta carry a bare url tag
+			if len(tag) < 2 {
+				return fmt.Errorf("url tag must have a value")
+			}
+			if err := validateMediaURL(tag[1]); err != nil {
+				return fmt.Errorf("invalid url tag: %w", err)
+			}
+			hasLegacyURL = true
+		case "duration":
+			if len(tag) != 2 {
+				return fmt.Errorf("duration tag must have exactly 2 elements")
+			}
+			if err := validateVideoDuration(tag[1]); err != nil {
+				return err
+			}
+		case "text-track":
+			if len(tag) < 2 || tag[1] == "" {
+				return fmt.Errorf("text-track tag must reference a track")
+			}
+		case "segment":
+			if err := validateVideoSegmentTag(tag); err != nil {
+				return err
+			}
+		case "t":
+			if err := validateHashtagTag(tag); err != nil {
+				return err
+			}
+		default:
+			// Other tags are allowed
+		}
+	}
+
+	// Required tags validation
+	if addressable && !hasDTag {
+		return fmt.Errorf("addressable video event must have a d tag")
+	}
+	if !hasTitleTag {
+		return fmt.Errorf("video event must have a title tag")
+	}
+	if variants == 0 && !(addressable && hasLegacyURL) {
+		return fmt.Errorf("video event must have at least one imeta tag")
+	}
+	if variants > maxVideoVariants {
+		return fmt.Errorf("too many video variants (max %d)", maxVideoVariants)
+	}
+
+	return nil
+}
+
+// validateMediaTitleTag validates the title tag for video and picture events
+func validateMediaTitleTag(tag nostr.Tag) error {
+	if len(tag) != 2 {
+		return fmt.Errorf("title tag must have exactly 2 elements")
+	}
+
+	title := tag[1]
+	if strings.TrimSpace(title) == "" {
+		return fmt.Errorf("title cannot be empty")
+	}
+
+	if len(title) > 500 {
+		return fmt.Errorf("title too long (max 500 characters)")
+	}
+
+	return nil
+}
+
+// validateVideoVariantTag validates one imeta video variant: a video (or
+// streaming playlist) URL with its MIME type, plus optional duration and bitrate.
+func validateVideoVariantTag(tag nostr.Tag) error {
+	meta, err := validateIMetaTag(tag)
+	if err != nil {
+		return err
+	}
+
+	mimeType := strings.ToLower(meta.Get("m"))
+	if mimeType == "" {
+		return fmt.Errorf("video imeta must have an m (MIME type) field")
+	}
+	if !strings.HasPrefix(mimeType, "video/") && !streamingPlaylistTypes[mimeType] {
+		return fmt.Errorf("video imeta MIME type must be video/* or a streaming playlist, got %s", mimeType)
+	}
+
+	if duration := meta.Get("duration"); duration != "" {
+		if err := validateVideoDuration(duration); err != nil {
+			return err
+		}
+	}
+	if bitrate := meta.Get("bitrate"); bitrate != "" {
+		if n, err := strconv.ParseInt(bitrate, 10, 64); err != nil || n <= 0 {
+			return fmt.Errorf("video imeta bitrate must be a positive integer")
+		}
+	}
+
+	return nil
+}
+
+// validateVideoDuration validates a video duration in seconds
+func validateVideoDuration(value string) error {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid video duration: %w", err)
+	}
+	// Longer than a week is not a video file
+	if seconds < 0 || seconds > 7*24*3600 {
+		return fmt.Errorf("video duration out of range: %s", value)
+	}
+	return nil
+}
+
+// validateVideoSegmentTag validates a chapter segment: ["segment", start, end, title, thumbnail]
+func validateVideoSegmentTag(tag nostr.Tag) error {
+	if len(tag) < 3 {
+		return fmt.Errorf("segment tag must have a start and end time")
+	}
+	if !videoSegmentTimestamp.MatchString(tag[1]) || !videoSegmentTimestamp.MatchString(tag[2]) {
+		return fmt.Errorf("segment times must be formatted HH:MM:SS.sss")
+	}
+	if tag[1] > tag[2] {
+		return fmt.Errorf("segment must not end before it starts")
+	}
+	if len(tag) >= 4 && len(tag[3]) > 500 {
+		return fmt.Errorf("segment title too long (max 500 characters)")
+	}
+	if len(tag) >= 5 && tag[4] != "" {
+		if err := validateMediaURL(tag[4]); err != nil {
+			return fmt.Errorf("invalid segment thumbnail: %w", err)
+		}
+	}
+	return nil
+}
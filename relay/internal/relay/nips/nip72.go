@@ -7,9 +7,14 @@ import (
 	"strings"
 
 	"github.com/Shugur-Network/relay/internal/relay/nips/common"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	"github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "72", Name: "Communities", Kinds: []int{34550, 4550}})
+}
+
 // NIP-72: Moderated Communities (Reddit-style Nostr Communities)
 //
 // Event Kinds:
@@ -4,9 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "78", Name: "App-specific Data", Kinds: []int{30078}})
+}
+
 // NIP-78: Application-specific data
 // https://github.com/nostr-protocol/nips/blob/master/78.md
 
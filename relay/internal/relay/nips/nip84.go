@@ -0,0 +1,178 @@
+package nips
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+func init() {
+	registry.Register(registry.NIP{ID: "84", Name: "Highlights", Kinds: []int{KindHighlight}})
+}
+
+// KindHighlight is the NIP-84 highlight kind.
+const KindHighlight = 9802
+
+const (
+	// maxHighlightContext bounds the context tag, the text surrounding the highlight.
+	maxHighlightContext = 10000
+	// maxHighlightComment bounds the comment tag of a quote highlight.
+	maxHighlightComment = 10000
+)
+
+// highlightRoles are the attribution roles a p tag may carry.
+var highlightRoles = map[string]bool{
+	"author":  true,
+	"editor":  true,
+	"mention": true,
+}
+
+// ValidateHighlight validates NIP-84 highlight events (kind 9802). A
+// maxContentLength above zero bounds the highlighted text.
+func ValidateHighlight(event *nostr.Event, maxContentLength int) error {
+	// Basic validation
+	if event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	logger.Debug("NIP-84: Validating highlight",
+		zap.String("event_id", event.ID),
+		zap.String("pubkey", event.PubKey))
+
+	if event.Kind != KindHighlight {
+		return fmt.Errorf("invalid kind for highlight: expected 9802, got %d", event.Kind)
+	}
+
+	if maxContentLength > 0 && len(event.Content) > maxContentLength {
+		return fmt.Errorf("highlight too long (max %d characters)", maxContentLength)
+	}
+
+	// Validate source and attribution tags
+	if err := validateHighlightTags(event); err != nil {
+		return fmt.Errorf("invalid highlight tags: %w", err)
+	}
+
+	logger.Debug("NIP-84: Highlight validation successful",
+		zap.String("event_id", event.ID))
+	return nil
+}
+
+// validateHighlightTags validates tags for highlights
+func validateHighlightTags(event *nostr.Event) error {
+	var sources int
+
+	for _, tag := range event.Tags {
+		if len(tag) == 0 {
+			continue
+		}
+
+		switch tag[0] {
+		case "e":
+			if len(tag) < 2 || !isHexChar64(tag[1]) {
+				return fmt.Errorf("e tag must reference the source by its 64-character hex id")
+			}
+			if err := validateHighlightRelayHint(tag); err != nil {
+				return err
+			}
+			sources++
+		case "a":
+			if len(tag) < 2 {
+				return fmt.Errorf("a tag must reference the source address")
+			}
+			if err := validateAddressableReference(tag[1]); err != nil {
+				return fmt.Errorf("invalid a tag: %w", err)
+			}
+			if err := validateHighlightRelayHint(tag); err != nil {
+				return err
+			}
+			sources++
+		case "r":
+			if len(tag) < 2 {
+				return fmt.Errorf("r tag must have a URL")
+			}
+			if err := validateHighlightSourceURL(tag[1]); err != nil {
+				return fmt.Errorf("invalid r tag: %w", err)
+			}
+			sources++
+		case "p":
+			if err := validateHighlightAttribution(tag); err != nil {
+				return err
+			}
+		case "context":
+			if len(tag) != 2 {
+				return fmt.Errorf("context tag must have exactly 2 elements")
+			}
+			if len(tag[1]) > maxHighlightContext {
+				return fmt.Errorf("context too long (max %d characters)", maxHighlightContext)
+			}
+		case "comment":
+			if len(tag) != 2 {
+				return fmt.Errorf("comment tag must have exactly 2 elements")
+			}
+			if len(tag[1]) > maxHighlightComment {
+				return fmt.Errorf("comment too long (max %d characters)", maxHighlightComment)
+			}
+		default:
+			// Other tags are allowed
+		}
+	}
+
+	// Required tags validation
+	if sources == 0 {
+		return fmt.Errorf("highlight must reference its source with an e, a or r tag")
+	}
+
+	return nil
+}
+
+// validateHighlightAttribution validates a p tag naming an author, editor or
+// mentioned pubkey of the highlighted source: ["p", <pubkey>, <relay>, <role>].
+func validateHighlightAttribution(tag nostr.Tag) error {
+	if len(tag) < 2 || len(tag) > 4 {
+		return fmt.Errorf("p tag must have 2-4 elements")
+	}
+	if !isHexChar64(tag[1]) {
+		return fmt.Errorf("p tag must be a 64-character hex pubkey")
+	}
+	if err := validateHighlightRelayHint(tag); err != nil {
+		return err
+	}
+	if len(tag) == 4 && tag[3] != "" && !highlightRoles[tag[3]] {
+		return fmt.Errorf("invalid p tag role '%s', allowed: author, editor, mention", tag[3])
+	}
+	return nil
+}
+
+// validateHighlightRelayHint validates the optional relay hint in the third element of a tag
+func validateHighlightRelayHint(tag nostr.Tag) error {
+	if len(tag) >= 3 && tag[2] != "" {
+		if err := validateRelayURL(tag[2]); err != nil {
+			return fmt.Errorf("invalid %s tag relay hint: %w", tag[0], err)
+		}
+	}
+	return nil
+}
+
+// validateHighlightSourceURL validates the web page a highlight was taken from
+func validateHighlightSourceURL(sourceURL string) error {
+	if len(sourceURL) > 2000 {
+		return fmt.Errorf("URL too long (max 2000 characters)")
+	}
+
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL format: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL must use http or https scheme, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("URL must have a host")
+	}
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package nips
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+func init() {
+	registry.Register(registry.NIP{ID: "88", Name: "Polls", Kinds: []int{KindPoll, KindPollResponse}})
+}
+
+// NIP-88 poll kinds
+const (
+	KindPoll         = 1068
+	KindPollResponse = 1018
+)
+
+// Poll types; a poll without a polltype tag is single choice.
+const (
+	PollSingleChoice   = "singlechoice"
+	PollMultipleChoice = "multiplechoice"
+)
+
+// maxPollOptions bounds the options of one poll.
+const maxPollOptions = 100
+
+// pollOptionIDPattern matches option identifiers.
+var pollOptionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// PollOption is one answer of a poll.
+type PollOption struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// Poll is the parsed definition of a kind 1068 poll.
+type Poll struct {
+	Options []PollOption
+	Type    string
+	EndsAt  int64 // unix time; 0 when the poll never closes
+}
+
+// HasOption reports whether the poll offers the option with id.
+func (p Poll) HasOption(id string) bool {
+	for _, opt := range p.Options {
+		if opt.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePoll reads the options, type and closing time of a poll event.
+func ParsePoll(event *nostr.Event) Poll {
+	poll := Poll{Type: PollSingleChoice}
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "option":
+			label := ""
+			if len(tag) >= 3 {
+				label = tag[2]
+			}
+			poll.Options = append(poll.Options, PollOption{ID: tag[1], Label: label})
+		case "polltype":
+			poll.Type = tag[1]
+		case "endsAt":
+			poll.EndsAt, _ = strconv.ParseInt(tag[1], 10, 64)
+		}
+	}
+	return poll
+}
+
+// PollResponseChoices returns the poll a response answers and the option
+// IDs it selects, in tag order.
+func PollResponseChoices(event *nostr.Event) (pollID string, choices []string) {
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "e":
+			if pollID == "" {
+				pollID = tag[1]
+			}
+		case "response":
+			choices = append(choices, tag[1])
+		}
+	}
+	return pollID, choices
+}
+
+// ValidatePoll validates NIP-88 poll events (kind 1068)
+func ValidatePoll(event *nostr.Event) error {
+	// Basic validation
+	if event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	logger.Debug("NIP-88: Validating poll",
+		zap.String("event_id", event.ID),
+		zap.String("pubkey", event.PubKey))
+
+	if event.Kind != KindPoll {
+		return fmt.Errorf("invalid kind for poll: expected 1068, got %d", event.Kind)
+	}
+
+	if strings.TrimSpace(event.Content) == "" {
+		return fmt.Errorf("poll must have a question in its content")
+	}
+
+	// Validate required and optional tags
+	if err := validatePollTags(event); err != nil {
+		return fmt.Errorf("invalid poll tags: %w", err)
+	}
+
+	logger.Debug("NIP-88: Poll validation successful",
+		zap.String("event_id", event.ID))
+	return nil
+}
+
+// ValidatePollResponse validates NIP-88 poll response events (kind 1018)
+func ValidatePollResponse(event *nostr.Event) error {
+	// Basic validation
+	if event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	logger.Debug("NIP-88: Validating poll response",
+		zap.String("event_id", event.ID),
+		zap.String("pubkey", event.PubKey))
+
+	if event.Kind != KindPollResponse {
+		return fmt.Errorf("invalid kind for poll response: expected 1018, got %d", event.Kind)
+	}
+
+	// Validate required tags
+	if err := validatePollResponseTags(event); err != nil {
+		return fmt.Errorf("invalid poll response tags: %w", err)
+	}
+
+	logger.Debug("NIP-88: Poll response validation successful",
+		zap.String("event_id", event.ID))
+	return nil
+}
+
+// validatePollTags validates tags for poll events
+func validatePollTags(event *nostr.Event) error {
+	optionIDs := make(map[string]bool)
+
+	for _, tag := range event.Tags {
+		if len(tag) == 0 {
+			continue
+		}
+
+		switch tag[0] {
+		case "option":
+			if len(tag) != 3 {
+				return fmt.Errorf("option tag must have exactly 3 elements")
+			}
+			if !pollOptionIDPattern.MatchString(tag[1]) {
+				return fmt.Errorf("option id must be 1-64 alphanumeric characters, got %q", tag[1])
+			}
+			if optionIDs[tag[1]] {
+				return fmt.Errorf("duplicate option id %q", tag[1])
+			}
+			optionIDs[tag[1]] = true
+			if strings.TrimSpace(tag[2]) == "" {
+				return fmt.Errorf("option label cannot be empty")
+			}
+			if len(tag[2]) > 500 {
+				return fmt.Errorf("option label too long (max 500 characters)")
+			}
+		case "polltype":
+			if len(tag) != 2 {
+				return fmt.Errorf("polltype tag must have exactly 2 elements")
+			}
+			if tag[1] != PollSingleChoice && tag[1] != PollMultipleChoice {
+				return fmt.Errorf("polltype must be %s or %s, got %q", PollSingleChoice, PollMultipleChoice, tag[1])
+			}
+		case "endsAt":
+			if err := validateTimestampTag(tag, "endsAt"); err != nil {
+				return err
+			}
+			if endsAt, _ := strconv.ParseInt(tag[1], 10, 64); endsAt <= int64(event.CreatedAt) {
+				return fmt.Errorf("endsAt must be after the poll's created_at")
+			}
+		case "relay":
+			if len(tag) < 2 {
+				return fmt.Errorf("relay tag must have a URL")
+			}
+			if err := validateRelayURL(tag[1]); err != nil {
+				return fmt.Errorf("invalid relay tag: %w", err)
+			}
+		default:
+			// Other tags are allowed
+		}
+	}
+
+	// Required tags validation
+	if len(optionIDs) < 2 {
+		return fmt.Errorf("poll must have at least two option tags")
+	}
+	if len(optionIDs) > maxPollOptions {
+		return fmt.Errorf("too many options (max %d)", maxPollOptions)
+	}
+
+	return nil
+}
+
+// validatePollResponseTags validates tags for poll responses
+func validatePollResponseTags(event *nostr.Event) error {
+	var pollRefs int
+	choices := make(map[string]bool)
+
+	for _, tag := range event.Tags {
+		if len(tag) == 0 {
+			continue
+		}
+
+		switch tag[0] {
+		case "e":
+			if len(tag) < 2 || !nostr.IsValid32ByteHex(tag[1]) {
+				return fmt.Errorf("e tag must reference the poll by its 64-character hex id")
+			}
+			pollRefs++
+		case "response":
+			if len(tag) != 2 {
+				return fmt.Errorf("response tag must have exactly 2 elements")
+			}
+			if !pollOptionIDPattern.MatchString(tag[1]) {
+				return fmt.Errorf("response must name an option id, got %q", tag[1])
+			}
+			if choices[tag[1]] {
+				return fmt.Errorf("duplicate response %q", tag[1])
+			}
+			choices[tag[1]] = true
+		default:
+			// Other tags are allowed
+		}
+	}
+
+	// Required tags validation
+	if pollRefs != 1 {
+		return fmt.Errorf("poll response must have exactly one e tag referencing the poll")
+	}
+	if len(choices) == 0 {
+		return fmt.Errorf("poll response must have at least one response tag")
+	}
+	if len(choices) > maxPollOptions {
+		return fmt.Errorf("too many responses (max %d)", maxPollOptions)
+	}
+
+	return nil
+}
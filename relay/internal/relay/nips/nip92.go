@@ -0,0 +1,145 @@
+package nips
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// IMeta holds the fields of a NIP-92 "imeta" tag. Each field may repeat
+// (fallback URLs, for instance), so values are kept in tag order.
+type IMeta map[string][]string
+
+// Get returns the first value of an imeta field, or "" when it is absent.
+func (m IMeta) Get(field string) string {
+	if values := m[field]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// blurhashAlphabet is the base83 alphabet blurhash strings are encoded with.
+const blurhashAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// ParseIMetaTag splits an imeta tag into its "key value" fields.
+func ParseIMetaTag(tag nostr.Tag) (IMeta, error) {
+	if len(tag) < 2 || tag[0] != "imeta" {
+		return nil, fmt.Errorf("not an imeta tag")
+	}
+	meta := make(IMeta, len(tag)-1)
+	for _, entry := range tag[1:] {
+		key, value, ok := strings.Cut(entry, " ")
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("imeta entry %q must be a key and value separated by a space", entry)
+		}
+		meta[key] = append(meta[key], value)
+	}
+	return meta, nil
+}
+
+// validateIMetaTag checks the fields NIP-92 and NIP-94 give a fixed format
+// and returns the parsed tag. An imeta tag must carry a url and at least one
+// other field.
+func validateIMetaTag(tag nostr.Tag) (IMeta, error) {
+	meta, err := ParseIMetaTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(meta["url"]) != 1 {
+		return nil, fmt.Errorf("imeta tag must have exactly one url")
+	}
+	if len(meta) < 2 {
+		return nil, fmt.Errorf("imeta tag must have at least one field besides url")
+	}
+	if err := validateMediaURL(meta.Get("url")); err != nil {
+		return nil, fmt.Errorf("invalid imeta url: %w", err)
+	}
+	for _, field := range []string{"fallback", "image", "thumb"} {
+		for _, u := range meta[field] {
+			if err := validateMediaURL(u); err != nil {
+				return nil, fmt.Errorf("invalid imeta %s: %w", field, err)
+			}
+		}
+	}
+	if m := meta.Get("m"); m != "" && !isMIMEType(m) {
+		return nil, fmt.Errorf("invalid imeta mime type %q", m)
+	}
+	for _, field := range []string{"x", "ox"} {
+		if x := meta.Get(field); x != "" && !isHexChar64(x) {
+			return nil, fmt.Errorf("imeta %s must be a 64-character hex sha256", field)
+		}
+	}
+	if dim := meta.Get("dim"); dim != "" {
+		if err := validateImageDimensions(dim); err != nil {
+			return nil, fmt.Errorf("invalid imeta dim: %w", err)
+		}
+	}
+	if bh := meta.Get("blurhash"); bh != "" {
+		if err := validateBlurhash(bh); err != nil {
+			return nil, fmt.Errorf("invalid imeta blurhash: %w", err)
+		}
+	}
+	if size := meta.Get("size"); size != "" {
+		if n, err := strconv.ParseInt(size, 10, 64); err != nil || n <= 0 {
+			return nil, fmt.Errorf("imeta size must be a positive integer")
+		}
+	}
+	if alt := meta.Get("alt"); len(alt) > 2000 {
+		return nil, fmt.Errorf("imeta alt too long (max 2000 characters)")
+	}
+	return meta, nil
+}
+
+// validateMediaURL checks a media file URL is an absolute http(s) URL.
+func validateMediaURL(mediaURL string) error {
+	if mediaURL == "" {
+		return fmt.Errorf("media URL cannot be empty")
+	}
+
+	u, err := url.Parse(mediaURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL format: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("media URL must use http or https scheme, got %s", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("media URL must have a host")
+	}
+
+	if len(mediaURL) > 2000 {
+		return fmt.Errorf("media URL too long (max 2000 characters)")
+	}
+
+	return nil
+}
+
+// isMIMEType reports whether s looks like a type/subtype MIME type.
+func isMIMEType(s string) bool {
+	typ, sub, ok := strings.Cut(s, "/")
+	return ok && typ != "" && sub != "" && !strings.ContainsAny(s, " \t") && len(s) <= 255
+}
+
+// validateBlurhash checks a blurhash string is base83 and as long as its
+// component count (encoded in the first character) requires.
+func validateBlurhash(hash string) error {
+	if len(hash) < 6 {
+		return fmt.Errorf("blurhash too short")
+	}
+	for _, c := range hash {
+		if !strings.ContainsRune(blurhashAlphabet, c) {
+			return fmt.Errorf("blurhash contains invalid character %q", c)
+		}
+	}
+	sizeFlag := strings.IndexByte(blurhashAlphabet, hash[0])
+	numX, numY := sizeFlag%9+1, sizeFlag/9+1
+	if want := 4 + 2*numX*numY; len(hash) != want {
+		return fmt.Errorf("blurhash length %d does not match its %dx%d components (want %d)", len(hash), numX, numY, want)
+	}
+	return nil
+}
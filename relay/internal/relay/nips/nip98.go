@@ -0,0 +1,112 @@
+package nips
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// KindHTTPAuth is the NIP-98 HTTP Auth event kind.
+const KindHTTPAuth = 27235
+
+// HTTPAuthWindow is how far the created_at of an auth event may be from now.
+const HTTPAuthWindow = 60 * time.Second
+
+// ValidateHTTPAuth validates a NIP-98 "Authorization: Nostr <base64 event>"
+// header for a request to url with the given method. When body is non-empty
+// the event must carry a matching "payload" tag.
+// Returns the authenticated pubkey.
+func ValidateHTTPAuth(authHeader, url, method string, body []byte) (string, error) {
+	evt, err := ValidateHTTPAuthEvent(authHeader, url, method, body)
+	if err != nil {
+		return "", err
+	}
+	return evt.PubKey, nil
+}
+
+// ValidateHTTPAuthEvent is ValidateHTTPAuth returning the whole auth event,
+// for callers that refuse to accept the same event twice.
+func ValidateHTTPAuthEvent(authHeader, url, method string, body []byte) (*nostr.Event, error) {
+	if authHeader == "" {
+		return nil, errors.New("missing Authorization header")
+	}
+
+	if !strings.HasPrefix(authHeader, "Nostr ") {
+		return nil, errors.New("Authorization header must start with 'Nostr '")
+	}
+
+	// Decode base64 event
+	encoded := strings.TrimPrefix(authHeader, "Nostr ")
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("invalid base64 in Authorization header")
+	}
+
+	// Parse event
+	var evt nostr.Event
+	if err := json.Unmarshal(decoded, &evt); err != nil {
+		return nil, errors.New("invalid event in Authorization header")
+	}
+
+	// Verify kind 27235
+	if evt.Kind != KindHTTPAuth {
+		return nil, errors.New("auth event must be kind 27235")
+	}
+
+	// Verify signature
+	ok, err := evt.CheckSignature()
+	if err != nil || !ok {
+		return nil, errors.New("invalid event signature")
+	}
+
+	// Check created_at is within HTTPAuthWindow
+	now := time.Now().Unix()
+	diff := now - int64(evt.CreatedAt)
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > int64(HTTPAuthWindow/time.Second) {
+		return nil, errors.New("auth event timestamp too old or too far in future")
+	}
+
+	// Verify u tag matches the request URL
+	uTag := evt.Tags.GetFirst([]string{"u", ""})
+	if uTag == nil || len(*uTag) < 2 {
+		return nil, errors.New("auth event missing 'u' tag")
+	}
+	eventURL := strings.TrimRight((*uTag)[1], "/")
+	expectedURL := strings.TrimRight(url, "/")
+	if eventURL != expectedURL {
+		return nil, fmt.Errorf("auth event 'u' tag mismatch: got %s, expected %s", eventURL, expectedURL)
+	}
+
+	// Verify method tag
+	methodTag := evt.Tags.GetFirst([]string{"method", ""})
+	if methodTag == nil || len(*methodTag) < 2 {
+		return nil, errors.New("auth event missing 'method' tag")
+	}
+	if !strings.EqualFold((*methodTag)[1], method) {
+		return nil, fmt.Errorf("auth event method must be %s", strings.ToUpper(method))
+	}
+
+	// Verify payload tag (SHA256 of request body)
+	if len(body) > 0 {
+		payloadTag := evt.Tags.GetFirst([]string{"payload", ""})
+		if payloadTag == nil || len(*payloadTag) < 2 {
+			return nil, errors.New("auth event missing 'payload' tag")
+		}
+		bodyHash := sha256.Sum256(body)
+		if (*payloadTag)[1] != hex.EncodeToString(bodyHash[:]) {
+			return nil, errors.New("auth event payload hash does not match request body")
+		}
+	}
+
+	return &evt, nil
+}
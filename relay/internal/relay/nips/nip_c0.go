@@ -0,0 +1,179 @@
+package nips
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+func init() {
+	registry.Register(registry.NIP{ID: "C0", Name: "Code Snippets", Kinds: []int{KindCodeSnippet}})
+}
+
+// KindCodeSnippet is the NIP-C0 code snippet kind.
+const KindCodeSnippet = 1337
+
+const (
+	// maxCodeSnippetSize bounds the code carried in the content.
+	maxCodeSnippetSize = 100 * 1024
+	// maxCodeSnippetDeps bounds the dep tags of one snippet.
+	maxCodeSnippetDeps = 100
+)
+
+var (
+	// codeLanguagePattern matches lowercase language names such as "c++", "c#" or "objective-c".
+	codeLanguagePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9+#._-]{0,49}$`)
+	// codeExtensionPattern matches file extensions given without the leading dot.
+	codeExtensionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_+-]{0,15}$`)
+)
+
+// ValidateCodeSnippet validates NIP-C0 code snippet events (kind 1337)
+func ValidateCodeSnippet(event *nostr.Event) error {
+	// Basic validation
+	if event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	logger.Debug("NIP-C0: Validating code snippet",
+		zap.String("event_id", event.ID),
+		zap.String("pubkey", event.PubKey))
+
+	if event.Kind != KindCodeSnippet {
+		return fmt.Errorf("invalid kind for code snippet: expected 1337, got %d", event.Kind)
+	}
+
+	if strings.TrimSpace(event.Content) == "" {
+		return fmt.Errorf("code snippet content cannot be empty")
+	}
+	if len(event.Content) > maxCodeSnippetSize {
+		return fmt.Errorf("code snippet too large (max %d bytes)", maxCodeSnippetSize)
+	}
+
+	// Validate optional tags
+	if err := validateCodeSnippetTags(event); err != nil {
+		return fmt.Errorf("invalid code snippet tags: %w", err)
+	}
+
+	logger.Debug("NIP-C0: Code snippet validation successful",
+		zap.String("event_id", event.ID))
+	return nil
+}
+
+// CodeSnippetLanguage returns the language named by a snippet's l tag, or "" when it has none.
+func CodeSnippetLanguage(event *nostr.Event) string {
+	for _, tag := range event.Tags {
+		if len(tag) >= 2 && tag[0] == "l" {
+			return tag[1]
+		}
+	}
+	return ""
+}
+
+// validateCodeSnippetTags validates tags for code snippets
+func validateCodeSnippetTags(event *nostr.Event) error {
+	var deps int
+
+	for _, tag := range event.Tags {
+		if len(tag) == 0 {
+			continue
+		}
+
+		switch tag[0] {
+		case "l":
+			if len(tag) != 2 {
+				return fmt.Errorf("l tag must have exactly 2 elements")
+			}
+			if !codeLanguagePattern.MatchString(tag[1]) {
+				return fmt.Errorf("l tag must be a lowercase language name, got %q", tag[1])
+			}
+		case "name":
+			if err := validateCodeSnippetName(tag); err != nil {
+				return err
+			}
+		case "extension":
+			if len(tag) != 2 {
+				return fmt.Errorf("extension tag must have exactly 2 elements")
+			}
+			if !codeExtensionPattern.MatchString(tag[1]) {
+				return fmt.Errorf("extension tag must be a file extension without the leading dot, got %q", tag[1])
+			}
+		case "runtime":
+			if err := validateCodeSnippetText(tag, 100); err != nil {
+				return err
+			}
+		case "license":
+			if err := validateCodeSnippetText(tag, 100); err != nil {
+				return err
+			}
+		case "description":
+			if len(tag) != 2 {
+				return fmt.Errorf("description tag must have exactly 2 elements")
+			}
+			if len(tag[1]) > 2000 {
+				return fmt.Errorf("description too long (max 2000 characters)")
+			}
+		case "dep":
+			if err := validateCodeSnippetText(tag, 200); err != nil {
+				return err
+			}
+			if strings.ContainsAny(tag[1], " \t") {
+				return fmt.Errorf("dep tag cannot contain whitespace")
+			}
+			deps++
+		case "repo":
+			if err := validateCodeSnippetText(tag, 2000); err != nil {
+				return err
+			}
+		default:
+			// Other tags are allowed
+		}
+	}
+
+	if deps > maxCodeSnippetDeps {
+		return fmt.Errorf("too many dep tags (max %d)", maxCodeSnippetDeps)
+	}
+
+	return nil
+}
+
+// validateCodeSnippetName validates the name tag, a file name without a path
+func validateCodeSnippetName(tag nostr.Tag) error {
+	if len(tag) != 2 {
+		return fmt.Errorf("name tag must have exactly 2 elements")
+	}
+
+	name := tag[1]
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("name tag must be a file name")
+	}
+	if len(name) > 255 {
+		return fmt.Errorf("name too long (max 255 characters)")
+	}
+	if strings.ContainsAny(name, "/\\\x00\n\r") {
+		return fmt.Errorf("name tag must not contain path separators or control characters")
+	}
+
+	return nil
+}
+
+// validateCodeSnippetText validates a single-line text tag of at most maxLen characters
+func validateCodeSnippetText(tag nostr.Tag, maxLen int) error {
+	if len(tag) != 2 {
+		return fmt.Errorf("%s tag must have exactly 2 elements", tag[0])
+	}
+	if tag[1] == "" {
+		return fmt.Errorf("%s tag cannot be empty", tag[0])
+	}
+	if len(tag[1]) > maxLen {
+		return fmt.Errorf("%s tag too long (max %d characters)", tag[0], maxLen)
+	}
+	if strings.ContainsAny(tag[1], "\n\r") {
+		return fmt.Errorf("%s tag must be a single line", tag[0])
+	}
+	return nil
+}
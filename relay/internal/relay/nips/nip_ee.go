@@ -5,10 +5,15 @@ import (
 	"strings"
 
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+func init() {
+	registry.Register(registry.NIP{ID: "EE", Name: "MLS E2EE Messaging", Kinds: []int{443, 444, 445, 10051}})
+}
+
 // NIP-EE: E2EE Messaging using the Messaging Layer Security (MLS) Protocol
 // https://nips.nostr.com/EE
 //
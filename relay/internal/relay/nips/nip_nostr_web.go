@@ -7,9 +7,21 @@ import (
 	"fmt"
 
 	"github.com/Shugur-Network/relay/internal/relay/nips/common"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{
+		ID:          "YY",
+		Name:        "Nostr Web Pages",
+		Kinds:       []int{1125, 1126, 31126, 11126},
+		Custom:      true,
+		Description: "Censorship-resistant static websites on Nostr",
+		Link:        "https://github.com/Shugur-Network/nw-nips",
+	})
+}
+
 // ValidateAsset validates NIP-YY Asset events (kind 1125)
 // All web assets (HTML, CSS, JavaScript, fonts, etc.) use kind 1125
 func ValidateAsset(event *nostr.Event) error {
@@ -7,9 +7,21 @@ import (
 	"strconv"
 
 	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	nostr "github.com/nbd-wtf/go-nostr"
 )
 
+func init() {
+	registry.Register(registry.NIP{
+		ID:          "XX",
+		Name:        "Time Capsules",
+		Kinds:       []int{1041},
+		Custom:      true,
+		Description: "Time-locked message delivery with threshold witness mode and VDF support",
+		Link:        "https://github.com/Shugur-Network/NIP-XX_Time-Capsules",
+	})
+}
+
 // ValidateTimeCapsuleEvent validates time capsule events according to NIP-XX
 // Public time capsules: content is Base64 of binary age v1 ciphertext with exactly one tlock recipient
 // Private time capsules are delivered via NIP-59 (kinds 13, 1059) and validated separately
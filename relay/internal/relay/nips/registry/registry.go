@@ -0,0 +1,260 @@
+// Package registry records the NIPs the relay implements. Each NIP module
+// registers itself from an init function; the NIP-11 supported_nips list, the
+// dashboard's NIP names and custom NIPs, and the kinds of the default kinds
+// policy are all derived from the registry so they cannot drift apart.
+package registry
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// KindRange is an inclusive range of event kinds.
+type KindRange struct {
+	From, To int
+}
+
+// NIP describes a NIP implemented by the relay.
+type NIP struct {
+	ID         string      // "01", "7D"; custom NIPs use their draft identifier
+	Name       string      // Short name shown on the dashboard
+	Kinds      []int       // Kinds accepted by the default kinds policy
+	KindRanges []KindRange // Kind ranges accepted by the default kinds policy
+
+	// Custom NIPs are drafts outside the NIPs repository: they are listed
+	// with their description and link instead of in supported_nips.
+	Custom      bool
+	Description string
+	Link        string
+
+	// Hidden entries contribute kinds but are not advertised, for deprecated
+	// NIPs still served to older clients and for relay-specific kinds.
+	Hidden bool
+}
+
+var (
+	mu   sync.RWMutex
+	nips = make(map[string]NIP)
+
+	// Switched off NIPs and the kinds only they accept
+	disabled       = make(map[string]bool)
+	disabledKinds  = make(map[int]string)
+	disabledRanges []disabledRange
+	enabledKinds   = make(map[int]bool)
+)
+
+// disabledRange is a kind range of a switched off NIP.
+type disabledRange struct {
+	KindRange
+	id string
+}
+
+// Register adds a NIP to the registry. Registering the same ID twice panics,
+// except for hidden entries, whose kinds are merged.
+func Register(nip NIP) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	key := nip.ID
+	if nip.Hidden {
+		key = "hidden:" + nip.ID + ":" + nip.Name
+	} else if nip.ID == "" {
+		panic("registry: NIP registered without an ID")
+	}
+	if prev, ok := nips[key]; ok {
+		if !nip.Hidden {
+			panic(fmt.Sprintf("registry: NIP-%s registered twice", nip.ID))
+		}
+		nip.Kinds = append(prev.Kinds, nip.Kinds...)
+		nip.KindRanges = append(prev.KindRanges, nip.KindRanges...)
+	}
+	nips[key] = nip
+}
+
+// All returns every registered NIP, hidden entries included, in NIP order:
+// numbered NIPs ascending, then hexadecimal and custom identifiers.
+func All() []NIP {
+	mu.RLock()
+	all := make([]NIP, 0, len(nips))
+	for _, nip := range nips {
+		all = append(all, nip)
+	}
+	mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool { return less(all[i].ID, all[j].ID) })
+	return all
+}
+
+// less orders NIP identifiers: decimal ones numerically, before the others.
+func less(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return na < nb
+	case errA == nil:
+		return true
+	case errB == nil:
+		return false
+	}
+	return a < b
+}
+
+// NormalizeID returns the registry form of a NIP identifier: decimal ones
+// with at least two digits, the others upper case.
+func NormalizeID(id string) string {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	if n, err := strconv.Atoi(id); err == nil && n >= 0 {
+		return fmt.Sprintf("%02d", n)
+	}
+	return id
+}
+
+// SetDisabled switches off the NIPs with the given IDs and switches every
+// other NIP back on. A switched off NIP is not advertised and its kinds, the
+// ones no enabled NIP also accepts, are refused. It returns the IDs that name
+// no registered NIP.
+func SetDisabled(ids []string) (unknown []string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	registered := make(map[string]bool, len(nips))
+	for _, nip := range nips {
+		registered[nip.ID] = true
+	}
+	disabled = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		id = NormalizeID(id)
+		if id == "" || !registered[id] {
+			unknown = append(unknown, id)
+			continue
+		}
+		disabled[id] = true
+	}
+
+	enabledKinds = make(map[int]bool)
+	for _, nip := range nips {
+		if !disabled[nip.ID] {
+			for _, k := range nip.Kinds {
+				enabledKinds[k] = true
+			}
+		}
+	}
+	disabledKinds = make(map[int]string)
+	disabledRanges = nil
+	for _, nip := range nips {
+		if !disabled[nip.ID] {
+			continue
+		}
+		for _, k := range nip.Kinds {
+			if !enabledKinds[k] {
+				disabledKinds[k] = nip.ID
+			}
+		}
+		for _, r := range nip.KindRanges {
+			disabledRanges = append(disabledRanges, disabledRange{KindRange: r, id: nip.ID})
+		}
+	}
+	return unknown
+}
+
+// Enabled reports whether the NIP with id is not switched off.
+func Enabled(id string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return !disabled[NormalizeID(id)]
+}
+
+// DisabledBy returns the ID of the switched off NIP kind belongs to, or ""
+// when kind is not refused.
+func DisabledBy(kind int) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(disabled) == 0 {
+		return ""
+	}
+	if id, ok := disabledKinds[kind]; ok {
+		return id
+	}
+	if enabledKinds[kind] {
+		return ""
+	}
+	for _, r := range disabledRanges {
+		if kind >= r.From && kind <= r.To {
+			return r.id
+		}
+	}
+	return ""
+}
+
+// Supported returns the advertised NIPs in NIP-11 supported_nips form:
+// decimal identifiers as numbers, the others as strings.
+func Supported() []interface{} {
+	supported := make([]interface{}, 0)
+	for _, nip := range All() {
+		if nip.Hidden || nip.Custom || !Enabled(nip.ID) {
+			continue
+		}
+		if n, err := strconv.Atoi(nip.ID); err == nil {
+			supported = append(supported, n)
+		} else {
+			supported = append(supported, nip.ID)
+		}
+	}
+	return supported
+}
+
+// Custom returns the registered custom NIPs.
+func Custom() []NIP {
+	custom := make([]NIP, 0)
+	for _, nip := range All() {
+		if nip.Custom && !nip.Hidden && Enabled(nip.ID) {
+			custom = append(custom, nip)
+		}
+	}
+	return custom
+}
+
+// Name returns the short name of the NIP with id ("01", "7D"), or "".
+func Name(id string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return nips[id].Name
+}
+
+// DefaultKinds returns the kinds and kind ranges registered NIPs accept,
+// switched off NIPs included; see DisabledBy.
+func DefaultKinds() (map[int]bool, []KindRange) {
+	kinds := make(map[int]bool)
+	var ranges []KindRange
+	for _, nip := range All() {
+		for _, k := range nip.Kinds {
+			kinds[k] = true
+		}
+		ranges = append(ranges, nip.KindRanges...)
+	}
+	return kinds, ranges
+}
+
+// ForKind returns the ID of the advertised NIP that defines kind, or "" when
+// no registered NIP does.
+func ForKind(kind int) string {
+	for _, nip := range All() {
+		if nip.Hidden {
+			continue
+		}
+		if slices.Contains(nip.Kinds, kind) {
+			return nip.ID
+		}
+		for _, r := range nip.KindRanges {
+			if kind >= r.From && kind <= r.To {
+				return nip.ID
+			}
+		}
+	}
+	return ""
+}
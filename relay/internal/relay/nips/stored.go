@@ -0,0 +1,40 @@
+package nips
+
+import "github.com/Shugur-Network/relay/internal/relay/nips/registry"
+
+// NIPs the relay supports by storing and serving their events as they are,
+// without a module of their own.
+func init() {
+	registry.Register(registry.NIP{ID: "18", Name: "Reposts", Kinds: []int{6, 16}})
+	registry.Register(registry.NIP{ID: "30", Name: "Custom Emoji"})
+	registry.Register(registry.NIP{ID: "32", Name: "Labeling", Kinds: []int{1985}})
+	registry.Register(registry.NIP{ID: "34", Name: "Git Stuff", Kinds: []int{
+		1617, 1618, 1619, 1621, // Patches, pull requests, issues, comments
+		1630, 1631, 1632, 1633, // Status
+		10317, 30617, 30618, // Repository state and announcements
+	}})
+	registry.Register(registry.NIP{ID: "35", Name: "Torrents", Kinds: []int{2003}})
+	registry.Register(registry.NIP{ID: "37", Name: "Draft Wraps", Kinds: []int{31234, 10013}})
+	registry.Register(registry.NIP{ID: "39", Name: "External Identities", Kinds: []int{10011}})
+	registry.Register(registry.NIP{ID: "64", Name: "Chess (PGN)", Kinds: []int{64}})
+	registry.Register(registry.NIP{ID: "66", Name: "Relay Discovery", Kinds: []int{30166, 10166}})
+	registry.Register(registry.NIP{ID: "69", Name: "P2P Orders", Kinds: []int{38383}})
+	registry.Register(registry.NIP{ID: "70", Name: "Protected Events"})
+	registry.Register(registry.NIP{ID: "75", Name: "Zap Goals", Kinds: []int{9041}})
+	registry.Register(registry.NIP{ID: "85", Name: "Trusted Assertions", Kinds: []int{30382, 10040}})
+	registry.Register(registry.NIP{ID: "87", Name: "Ecash Mint Discovery", Kinds: []int{38000, 38172, 38173}})
+	registry.Register(registry.NIP{ID: "89", Name: "App Handlers", Kinds: []int{31989, 31990}})
+	registry.Register(registry.NIP{ID: "90", Name: "Data Vending Machine", KindRanges: []registry.KindRange{
+		{From: 5000, To: 5999}, // Job requests
+		{From: 6000, To: 6999}, // Job results
+		{From: 7000, To: 7000}, // Job feedback
+	}})
+	registry.Register(registry.NIP{ID: "94", Name: "File Metadata", Kinds: []int{1063}})
+	registry.Register(registry.NIP{ID: "99", Name: "Classified Listings", Kinds: []int{30402, 30403}})
+	registry.Register(registry.NIP{ID: "7D", Name: "Threads", Kinds: []int{11}})
+	registry.Register(registry.NIP{ID: "A0", Name: "Voice Messages", Kinds: []int{1222, 1244}})
+	registry.Register(registry.NIP{ID: "A4", Name: "Public Messages", Kinds: []int{24}})
+	registry.Register(registry.NIP{ID: "B0", Name: "Web Bookmarking", Kinds: []int{39701}})
+	registry.Register(registry.NIP{ID: "B7", Name: "Blossom Server List", Kinds: []int{10063}})
+	registry.Register(registry.NIP{ID: "C7", Name: "Chats", Kinds: []int{9}})
+}
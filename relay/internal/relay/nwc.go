@@ -0,0 +1,221 @@
+package relay
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// NWC mode: relay-side handling of NIP-47 Wallet Connect traffic.
+//
+// Requests, responses and notifications (kinds 23194-23197) are ephemeral and
+// never reach the database. In NWC mode the relay additionally:
+//   - keeps each message in memory for RETENTION (or until its expiration
+//     tag, whichever is sooner) so a wallet or client that reconnects briefly
+//     still receives it from a REQ
+//   - delivers a message only to subscriptions that name its recipient in a
+//     "#p" filter, or to a connection authenticated as its author or recipient
+//   - applies a per-pubkey rate limit reserved for Wallet Connect traffic
+
+// maxNWCRetained bounds the messages held for replay.
+const maxNWCRetained = 100000
+
+// maxNWCLimiters bounds the per-pubkey limiters kept in memory.
+const maxNWCLimiters = 10000
+
+// nwcEntry is a retained Wallet Connect message.
+type nwcEntry struct {
+	evt     *nostr.Event
+	expires time.Time
+}
+
+// NWCStore retains Wallet Connect messages and enforces NWC rate limits.
+type NWCStore struct {
+	mu        sync.Mutex
+	retained  []nwcEntry // ordered by arrival
+	limiters  map[string]*rate.Limiter
+	retention time.Duration
+	limit     rate.Limit
+	burst     int
+}
+
+// nwcStoreInstance is the package-level NWC store singleton (nil when NWC mode is off).
+var nwcStoreInstance *NWCStore
+
+// GetNWCStore returns the package-level NWC store, or nil when NWC mode is disabled.
+func GetNWCStore() *NWCStore {
+	return nwcStoreInstance
+}
+
+// InitNWCStore initializes the package-level NWC store. Called from NewServer.
+func InitNWCStore(cfg *config.Config) *NWCStore {
+	nc := cfg.Relay.NWC
+	if !nc.Enabled {
+		nwcStoreInstance = nil
+		return nil
+	}
+
+	retention := nc.Retention
+	if retention <= 0 {
+		retention = time.Minute
+	}
+	burst := nc.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	limit := rate.Inf
+	if nc.EventsPerMinute > 0 {
+		limit = rate.Limit(float64(nc.EventsPerMinute) / 60)
+	}
+
+	nwcStoreInstance = &NWCStore{
+		limiters:  make(map[string]*rate.Limiter),
+		retention: retention,
+		limit:     limit,
+		burst:     burst,
+	}
+	logger.New("nwc").Info("Wallet Connect mode enabled",
+		zap.Duration("retention", retention),
+		zap.Int("events_per_minute", nc.EventsPerMinute))
+	return nwcStoreInstance
+}
+
+// CheckEvent applies the Wallet Connect rate limit to a message.
+// Returns (allowed, reason).
+func (ns *NWCStore) CheckEvent(evt *nostr.Event) (bool, string) {
+	if ns.limit == rate.Inf {
+		return true, ""
+	}
+
+	pubkey := strings.ToLower(evt.PubKey)
+
+	ns.mu.Lock()
+	limiter := ns.limiters[pubkey]
+	if limiter == nil {
+		if len(ns.limiters) >= maxNWCLimiters {
+			ns.limiters = make(map[string]*rate.Limiter)
+		}
+		limiter = rate.NewLimiter(ns.limit, ns.burst)
+		ns.limiters[pubkey] = limiter
+	}
+	ns.mu.Unlock()
+
+	if !limiter.Allow() {
+		metrics.NWCMessages.WithLabelValues("rate_limited").Inc()
+		return false, "rate-limited: too many wallet connect messages"
+	}
+	return true, ""
+}
+
+// Retain keeps an accepted message available for replay until it expires.
+func (ns *NWCStore) Retain(evt *nostr.Event) {
+	now := time.Now()
+	expires := now.Add(ns.retention)
+	if exp, ok := nips.GetExpirationTime(*evt); ok && exp.Before(expires) {
+		expires = exp
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.pruneLocked(now)
+	if len(ns.retained) >= maxNWCRetained {
+		ns.retained = ns.retained[1:]
+	}
+	ns.retained = append(ns.retained, nwcEntry{evt: evt, expires: expires})
+	metrics.NWCMessages.WithLabelValues("accepted").Inc()
+}
+
+// pruneLocked drops expired messages from the head of the queue. Caller must hold ns.mu.
+func (ns *NWCStore) pruneLocked(now time.Time) {
+	i := 0
+	for i < len(ns.retained) && now.After(ns.retained[i].expires) {
+		i++
+	}
+	if i > 0 {
+		ns.retained = append([]nwcEntry(nil), ns.retained[i:]...)
+	}
+}
+
+// CleanExpired drops every expired message and returns how many were removed.
+func (ns *NWCStore) CleanExpired() int {
+	now := time.Now()
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	kept := ns.retained[:0]
+	for _, entry := range ns.retained {
+		if now.Before(entry.expires) {
+			kept = append(kept, entry)
+		}
+	}
+	removed := len(ns.retained) - len(kept)
+	ns.retained = kept
+	return removed
+}
+
+// Query returns the retained messages matching a filter that authedPK may receive.
+func (ns *NWCStore) Query(filter nostr.Filter, authedPK string) []nostr.Event {
+	now := time.Now()
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	var events []nostr.Event
+	for _, entry := range ns.retained {
+		if now.After(entry.expires) || !storage.MatchesFilter(filter, entry.evt) {
+			continue
+		}
+		if !canDeliverNWC(entry.evt, filter, authedPK) {
+			continue
+		}
+		events = append(events, *entry.evt)
+	}
+	if len(events) > 0 {
+		metrics.NWCMessages.WithLabelValues("replayed").Add(float64(len(events)))
+	}
+	return events
+}
+
+// canDeliverNWC checks whether a Wallet Connect message may be sent to a subscription:
+// the filter must name the recipient in "#p", or the connection must be
+// authenticated as the author or the recipient.
+func canDeliverNWC(evt *nostr.Event, filter nostr.Filter, authedPK string) bool {
+	recipient := nips.WalletConnectRecipient(evt)
+	if authedPK != "" && (authedPK == evt.PubKey || authedPK == recipient) {
+		return true
+	}
+	for _, pubkey := range filter.Tags["p"] {
+		if pubkey == recipient {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanExpiredNWC periodically drops expired Wallet Connect messages.
+func cleanExpiredNWC(ctx context.Context) {
+	ns := GetNWCStore()
+	if ns == nil {
+		return
+	}
+	ticker := time.NewTicker(ns.retention)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := ns.CleanExpired(); n > 0 {
+				logger.New("nwc").Debug("Removed expired wallet connect messages", zap.Int("count", n))
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/opentimestamps"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// openTimestampsInstance is the package-level NIP-03 verifier (nil when verification is disabled).
+var openTimestampsInstance *opentimestamps.Verifier
+
+// GetOpenTimestamps returns the NIP-03 verifier, or nil when verification is disabled.
+func GetOpenTimestamps() *opentimestamps.Verifier {
+	return openTimestampsInstance
+}
+
+// InitOpenTimestamps creates the NIP-03 verifier and hooks it into event
+// storage. Called from NewServer; workers are started by startOpenTimestamps.
+func InitOpenTimestamps(cfg *config.Config, db *storage.DB) *opentimestamps.Verifier {
+	if !cfg.OpenTimestamps.Enabled || db == nil {
+		openTimestampsInstance = nil
+		return nil
+	}
+
+	v := opentimestamps.New(cfg.OpenTimestamps)
+	db.SetTimestampVerifier(v.Submit)
+	openTimestampsInstance = v
+	return v
+}
+
+// startOpenTimestamps launches the verifier and verifies the attestations
+// stored before the last restart.
+func startOpenTimestamps(ctx context.Context, db *storage.DB) {
+	v := GetOpenTimestamps()
+	if v == nil {
+		return
+	}
+	v.Start(ctx)
+
+	go func() {
+		events, err := db.GetEvents(ctx, nostr.Filter{
+			Kinds: []int{opentimestamps.KindAttestation},
+			Limit: 10000,
+		})
+		if err != nil {
+			logger.New("opentimestamps").Warn("Failed to load stored attestations", zap.Error(err))
+			return
+		}
+		for i := range events {
+			if ctx.Err() != nil {
+				return
+			}
+			v.Verify(ctx, &events[i])
+		}
+		logger.New("opentimestamps").Info("Verified stored attestations", zap.Int("attestations", len(events)))
+	}()
+}
@@ -0,0 +1,54 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/domain"
+	"github.com/Shugur-Network/relay/internal/outbox"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// outboxInstance is the package-level outbox for relay-signed events (nil when disabled).
+var outboxInstance *outbox.Outbox
+
+// InitOutbox creates the package-level outbox if enabled. Called from NewServer
+// after the relay keypair is known. When relay hints are enabled, events
+// addressed to a pubkey also go to the read relays of its NIP-65 list; the
+// receipts of zaps paid to the relay's lightning address go to the relays
+// their zap request lists.
+func InitOutbox(cfg *config.Config, relayPubkey string, db *storage.DB) *outbox.Outbox {
+	outboxInstance = nil
+	if !cfg.Outbox.Enabled || relayPubkey == "" {
+		return nil
+	}
+	if len(cfg.Outbox.Relays) == 0 && !cfg.Outbox.UseRelayHints && !cfg.LNURL.Enabled {
+		return nil
+	}
+	outboxInstance = outbox.New(cfg.Outbox, relayPubkey)
+	if db != nil {
+		outboxInstance.SetHintResolver(db.ReadRelaysFor)
+	}
+	return outboxInstance
+}
+
+// startOutbox starts publishing to external relays until ctx is canceled.
+func startOutbox(ctx context.Context) {
+	if outboxInstance != nil {
+		outboxInstance.Start(ctx)
+	}
+}
+
+// queueRelayEvent stores a relay-generated event locally and, for broadcast
+// kinds, publishes it to the configured external relays.
+func queueRelayEvent(node domain.NodeInterface, evt *nostr.Event) bool {
+	if evt == nil {
+		return false
+	}
+	ok := node.GetEventProcessor().QueueEvent(*evt)
+	if ok && outboxInstance != nil {
+		outboxInstance.Publish(evt)
+	}
+	return ok
+}
@@ -13,7 +13,10 @@ import (
 	"github.com/Shugur-Network/relay/internal/logger"
 	"github.com/Shugur-Network/relay/internal/metrics"
 	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
 	"github.com/Shugur-Network/relay/internal/storage"
+	"github.com/Shugur-Network/relay/internal/tracing"
+	"github.com/Shugur-Network/relay/internal/workers"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
@@ -28,7 +31,7 @@ type ValidationLimits struct {
 	OldestEventTime   int64
 	RelayStartupTime  time.Time
 	MaxMetadataLength int
-	AllowedKinds      map[int]bool
+	AllowedKinds      *KindPolicy
 	RequiredTags      map[int][]string
 	MaxCreatedAt      int64
 	MinCreatedAt      int64
@@ -43,13 +46,15 @@ type PluginValidator struct {
 
 	verifiedPubkeys map[string]time.Time
 	db              *storage.DB
+	sigVerifier     *workers.SignatureVerifier
 }
 
 // Ensure PluginValidator implements domain.EventValidator
 var _ domain.EventValidator = (*PluginValidator)(nil)
 
-// NewPluginValidator returns a PluginValidator with default settings
-func NewPluginValidator(cfg *config.Config, database *storage.DB) *PluginValidator {
+// NewPluginValidator returns a PluginValidator with default settings that
+// checks signatures on the shared sigVerifier pool.
+func NewPluginValidator(cfg *config.Config, database *storage.DB, sigVerifier *workers.SignatureVerifier) *PluginValidator {
 	// Use configuration values for content length limits
 	maxContentLength := cfg.Relay.ThrottlingConfig.MaxContentLen
 	if maxContentLength == 0 {
@@ -65,154 +70,7 @@ func NewPluginValidator(cfg *config.Config, database *storage.DB) *PluginValidat
 		OldestEventTime:   1609459200, // Jan 1, 2021
 		RelayStartupTime:  time.Now(),
 		MaxMetadataLength: 10000,
-		AllowedKinds: map[int]bool{
-			0: true, 1: true, 2: true, 3: true, 4: true, 5: true,
-			6: true, 7: true, 9: true, 11: true, 16: true, 20: true, 21: true, 24: true,
-			40: true, 41: true, 42: true, 43: true, 44: true, 62: true,
-			14: true, 15: true, 1059: true, 10050: true,
-			1984: true, 1985: true, 9734: true, 9735: true, 10002: true,
-			30023: true, 30024: true, // NIP-23: Long-form Content + Drafts
-			31989: true, 31990: true, // NIP-89: App Handlers
-			1111: true, // NIP-22: Comment
-			// NIP-C7 Chats (kind 9), NIP-7D Threads (kind 11), NIP-A4 Public Messages (kind 24)
-			// NIP-68 Picture-first (kind 20), NIP-71 Video (kind 21), NIP-62 Vanish (kind 62)
-			// NIP-32 Labeling (kind 1985)
-			// NIP-20 Command Results
-			24133: true,
-			// NIP-16 Ephemeral Events (20000-29999) — range-allowed in validator
-			// NIP-33 Addressable Events
-			30000: true, 30001: true, 30002: true, 30003: true,
-			// NIP-51 Lists - Standard Lists
-			10000: true, // Mute list
-			10001: true, // Pinned notes
-			10003: true, // Bookmarks
-			10004: true, // Communities
-			10005: true, // Public chats
-			10006: true, // Blocked relays
-			10007: true, // Search relays
-			10009: true, // Simple groups
-			10012: true, // Relay feeds
-			10015: true, // Interests
-			10020: true, // Media follows
-			10030: true, // Emojis
-			10101: true, // Good wiki authors
-			10102: true, // Good wiki relays
-			// NIP-51 Lists - Sets
-			30004: true, // Curation sets (articles/notes)
-			30005: true, // Curation sets (videos)
-			30007: true, // Kind mute sets
-			30015: true, // Interest sets
-			30030: true, // Emoji sets
-			30063: true, // Release artifact sets
-			30267: true, // App curation sets
-			39089: true, // Starter packs
-			39092: true, // Media starter packs
-			// NIP-15 Marketplace
-			30017: true, // Stall
-			30018: true, // Product
-			30019: true, // Marketplace UI/UX
-			30020: true, // Auction Product
-			1021:  true, // Bid
-			1022:  true, // Bid Confirmation
-			// Other NIPs
-			8:     true, // NIP-58: Badge Award
-			1040:  true, // NIP-03 OpenTimestamps attestation
-			1041:  true, // NIP-XX Time-Lock Encrypted Messages
-			1063:  true, // NIP-94: File Metadata
-			1222:  true, // NIP-A0: Voice Messages
-			1244:  true, // NIP-A0: Voice Reply
-			1337:  true, // NIP-C0: Code Snippets
-			2003:  true, // NIP-35: Torrents
-			1018:  true, // NIP-88: Polls (response)
-			1068:  true, // NIP-88: Polls (poll event)
-			9041:  true, // NIP-75: Zap Goals
-			9802:  true, // NIP-84: Highlights
-			13194: true, // NIP-47: Wallet Connect info
-			30008: true, // NIP-58: Profile Badges
-			30009: true, // NIP-58: Badge Definition
-			30078: true, // NIP-78 Application-specific Data
-			30315: true, // NIP-38: User Statuses
-			30382: true, // NIP-85: Trusted Assertions
-			10040: true, // NIP-85: Trusted Assertion Delegation
-			30402: true, // NIP-99: Classified Listings
-			30403: true, // NIP-99: Draft Classified Listing
-			// NIP-52 Calendar Events
-			31922: true, // Date-based Calendar Event
-			31923: true, // Time-based Calendar Event  
-			31924: true, // Calendar
-			31925: true, // Calendar Event RSVP
-			// NIP-53 Live Activities
-			30311: true, // Live Streaming Event
-			1311:  true, // Live Chat Message
-			30312: true, // Meeting Space
-			30313: true, // Meeting Room Event
-			10312: true, // Room Presence
-			// NIP-54 Wiki
-			30818: true, // Wiki Article
-			818:   true, // Merge Request
-			30819: true, // Wiki Redirect
-			// NIP-60 Cashu Wallets
-			17375: true, // Wallet Event
-			7375:  true, // Token Event
-			7376:  true, // Spending History Event
-			7374:  true, // Quote Event
-			// NIP-61 Nutzaps
-			9321:  true, // Nutzap event  
-			10019: true, // Nutzap info event
-			// NIP-34 Git Stuff
-			1617:  true, // Patches
-			1618:  true, // Pull Requests
-			1619:  true, // Issues
-			1621:  true, // Comments on Git
-			1630:  true, 1631: true, 1632: true, 1633: true, // Patch status
-			10317: true, // Repository state
-			30617: true, // Repository
-			30618: true, // Repository announcements
-			// NIP-37 Draft Wraps
-			31234: true, // Draft event
-			10013: true, // Draft list
-			// NIP-71 Video Events
-			34235: true, // Video event
-			34236: true, // Short-form vertical video
-			// NIP-87 Ecash Mint Discoverability
-			38000: true, // Mint recommendation
-			38172: true, // Mint trust
-			38173: true, // Mint trust revocation
-			// NIP-69 P2P Order Events
-			38383: true, // P2P Order
-			// NIP-B0 Web Bookmarking
-			39701: true, // Web Bookmark
-			// NIP-B7 Blossom Server List
-			10063: true, // User Blossom Server List
-			// NIP-72 Moderated Communities
-			34550: true, // Community Definition
-			4550:  true, // Moderation Approval
-			// NIP-EE MLS E2EE Messaging
-			443:   true, // MLS KeyPackage Event
-			444:   true, // MLS Welcome Event (inner, arrives via gift wrap)
-			445:   true, // MLS Group Event (encrypted group messages)
-			10051: true, // KeyPackage Relays List
-			// NIP-YY Nostr Web Pages
-			1125:  true, // Asset (HTML, CSS, JavaScript, fonts, etc.)
-			1126:  true, // Page Manifest
-			31126: true, // Site Index
-			11126: true, // Entrypoint
-			// NIP-43 Relay Access Metadata
-			13534: true, // Membership list (relay-signed)
-			8000:  true, // Add user (relay-signed)
-			8001:  true, // Remove user (relay-signed)
-			28934: true, // Join request (user-sent)
-			28935: true, // Invite request (ephemeral, relay-generated)
-			28936: true, // Leave request (user-sent)
-			10010: true, // User relay membership list
-			// NIP-66 Relay Discovery and Liveness Monitoring
-			30166: true, // Relay Discovery (addressable)
-			10166: true, // Relay Monitor Announcement (replaceable)
-			// NIP-39 External Identities in Profiles
-			10011: true, // External Identity List (replaceable)
-			// NIP-64 Chess (PGN)
-			64: true, // Chess game in PGN format
-		},
+		AllowedKinds:      NewKindPolicy(cfg.RelayPolicy.Kinds),
 		RequiredTags: map[int][]string{
 			5:     {"e"},      // Deletion events must have an "e" tag
 			7:     {"e", "p"}, // Reaction events require "e" and "p" tags
@@ -277,6 +135,8 @@ func NewPluginValidator(cfg *config.Config, database *storage.DB) *PluginValidat
 			28934: {"claim"},   // Join request requires "claim" tag with invite code
 			// NIP-66 Relay Discovery
 			30166: {"d"},       // Relay Discovery requires "d" tag (relay URL)
+			// Namespace policies
+			39150: {"d"},       // Namespace policy requires "d" tag (namespace)
 		},
 		MaxCreatedAt: time.Now().Unix() + 300,    // 5 minutes in future
 		MinCreatedAt: time.Now().Unix() - 172800, // 2 days in past
@@ -288,6 +148,7 @@ func NewPluginValidator(cfg *config.Config, database *storage.DB) *PluginValidat
 		limits:          defaultLimits,
 		verifiedPubkeys: make(map[string]time.Time),
 		db:              database,
+		sigVerifier:     sigVerifier,
 	}
 }
 
@@ -313,25 +174,11 @@ func (pv *PluginValidator) ValidateEvent(ctx context.Context, event nostr.Event)
 	}
 
 	// 2. Check if kind is allowed
-	if !pv.limits.AllowedKinds[event.Kind] {
-		// Check if it's an ephemeral event (20000-29999) - these should be allowed per NIP-16
-		if event.Kind >= 20000 && event.Kind < 30000 {
-			// Ephemeral events are allowed but not stored
-		} else if event.Kind >= 5000 && event.Kind <= 5999 {
-			// NIP-90 DVM job requests
-		} else if event.Kind >= 6000 && event.Kind <= 6999 {
-			// NIP-90 DVM job results
-		} else if event.Kind == 7000 {
-			// NIP-90 DVM job feedback
-		} else if event.Kind >= 9000 && event.Kind <= 9030 {
-			// NIP-29 Relay-based Groups moderation events
-		} else if event.Kind == 9021 || event.Kind == 9022 {
-			// NIP-29 join/leave requests
-		} else if event.Kind >= 39000 && event.Kind <= 39003 {
-			// NIP-29 group metadata events
-		} else {
-			return false, fmt.Sprintf("unsupported event kind: %d", event.Kind)
-		}
+	pv.mu.RLock()
+	kindAllowed := pv.limits.AllowedKinds.Allows(event.Kind)
+	pv.mu.RUnlock()
+	if !kindAllowed {
+		return false, fmt.Sprintf("unsupported event kind: %d", event.Kind)
 	}
 
 	// 3. Check blacklist (case-insensitive)
@@ -371,8 +218,12 @@ func (pv *PluginValidator) ValidateEvent(ctx context.Context, event nostr.Event)
 		}
 	}
 
-	// 6b. NIP-13: Proof of Work validation
-	if err := nips.ValidatePoW(event, pv.config.Relay.MinPowDifficulty); err != nil {
+	// 6b. NIP-13: Proof of Work validation (trusted pubkeys are exempt, unknown ones may need more)
+	minPow := pv.config.Relay.MinPowDifficulty
+	if rep := GetReputation(); rep != nil {
+		minPow = rep.MinPow(event.PubKey, minPow)
+	}
+	if err := nips.ValidatePoW(event, minPow); err != nil {
 		return false, err.Error()
 	}
 
@@ -447,6 +298,10 @@ func (pv *PluginValidator) ValidateEvent(ctx context.Context, event nostr.Event)
 
 // validateWithDedicatedNIPs validates events using dedicated NIP validation functions
 func (pv *PluginValidator) validateWithDedicatedNIPs(event *nostr.Event) error {
+	if nip := registry.DisabledBy(event.Kind); nip != "" {
+		return fmt.Errorf("NIP-%s is disabled on this relay", nip)
+	}
+
 	switch event.Kind {
 	case 3:
 		return nips.ValidateFollowList(event)
@@ -457,19 +312,36 @@ func (pv *PluginValidator) validateWithDedicatedNIPs(event *nostr.Event) error {
 	case 7:
 		return nips.ValidateReaction(event)
 	case 8:
-		return nips.ValidateBadgeAward(event)
+		if err := nips.ValidateBadgeAward(event); err != nil {
+			return err
+		}
+		return pv.checkBadgeAward(event)
 	case 14, 15, 10050:
 		return nips.ValidatePrivateDirectMessage(event)
 	case 40, 41, 42, 43, 44:
 		return nips.ValidatePublicChat(event)
+	case 1018:
+		if err := nips.ValidatePollResponse(event); err != nil {
+			return err
+		}
+		return pv.checkPollResponse(event)
 	case 1040:
 		return nips.ValidateOpenTimestampsAttestation(event)
+	case 1068:
+		return nips.ValidatePoll(event)
+	case 1337:
+		return nips.ValidateCodeSnippet(event)
 	case 1984:
 		return nips.ValidateReport(event)
 	case 9734:
 		return nips.ValidateZapRequest(event)
 	case 9735:
-		return nips.ValidateZapReceipt(event)
+		if err := nips.ValidateZapReceipt(event); err != nil {
+			return err
+		}
+		return pv.checkZapReceipt(event)
+	case 9802:
+		return nips.ValidateHighlight(event, pv.config.Relay.Highlights.MaxLength)
 	case 24133:
 		return nips.ValidateCommandResult(event)
 	case 30008:
@@ -536,6 +408,12 @@ func (pv *PluginValidator) validateWithDedicatedNIPs(event *nostr.Event) error {
 		return nips.ValidateNutzapEvent(event)
 	case 10019:
 		return nips.ValidateNutzapInfoEvent(event)
+	// NIP-68 Picture-first validation
+	case 20:
+		return nips.ValidatePictureEvent(event)
+	// NIP-71 Video Events validation
+	case 21, 22, 34235, 34236:
+		return nips.ValidateVideoEvent(event)
 	// NIP-72 Moderated Communities validation
 	case 34550:
 		return nips.ValidateCommunityDefinition(event)
@@ -569,6 +447,10 @@ func (pv *PluginValidator) validateWithDedicatedNIPs(event *nostr.Event) error {
 	case 11126:
 		return nips.ValidateEntrypoint(event)
 	default:
+		// NIP-47 Wallet Connect messages are checked strictly in NWC mode
+		if nips.IsWalletConnectMessage(event.Kind) && pv.config.Relay.NWC.Enabled {
+			return nips.ValidateWalletConnectMessage(event)
+		}
 		// Check for NIP-16 ephemeral events
 		if event.Kind >= 20000 && event.Kind < 30000 {
 			return nips.ValidateEventTreatment(event)
@@ -588,11 +470,6 @@ func (pv *PluginValidator) validateWithDedicatedNIPs(event *nostr.Event) error {
 
 // ValidateFilter ensures a filter is within safe limits
 func (pv *PluginValidator) ValidateFilter(f nostr.Filter) error {
-	// Apply limit cap
-	if f.Limit <= 0 || f.Limit > 500 {
-		f.Limit = 500
-	}
-
 	// Validate time range
 	if f.Since != nil && f.Until != nil && f.Since.Time().Unix() > f.Until.Time().Unix() {
 		return fmt.Errorf("'since' timestamp is after 'until' timestamp")
@@ -605,17 +482,19 @@ func (pv *PluginValidator) ValidateFilter(f nostr.Filter) error {
 		return fmt.Errorf("'until' timestamp is too far in the future")
 	}
 
-	// Check IDs format
+	// Check IDs and authors: full values or NIP-01 prefixes
+	minPrefix := pv.config.Relay.MinFilterPrefix
+	if minPrefix <= 0 {
+		minPrefix = defaultMinFilterPrefix
+	}
 	for _, id := range f.IDs {
-		if len(id) != 64 || !isHexString(id) {
-			return fmt.Errorf("invalid event ID: %s", id)
+		if err := checkHexPrefix("event ID", id, minPrefix); err != nil {
+			return err
 		}
 	}
-
-	// Check authors format
 	for _, author := range f.Authors {
-		if len(author) != 64 || !isHexString(author) {
-			return fmt.Errorf("invalid pubkey in authors: %s", author)
+		if err := checkHexPrefix("pubkey in authors", author, minPrefix); err != nil {
+			return err
 		}
 	}
 
@@ -663,29 +542,78 @@ func (pv *PluginValidator) GetBlacklistedPubkeys() []string {
 func (pv *PluginValidator) GetAllowedKinds() []int {
 	pv.mu.RLock()
 	defer pv.mu.RUnlock()
-	kinds := make([]int, 0, len(pv.limits.AllowedKinds))
-	for k := range pv.limits.AllowedKinds {
-		kinds = append(kinds, k)
-	}
-	return kinds
+	return pv.limits.AllowedKinds.Kinds()
 }
 
-// AddAllowedKind adds an event kind to the allowed kinds map
+// AllowedKindRanges returns the allowed event kinds in NIP-11 notation:
+// single kinds as numbers and consecutive runs as [from, to] pairs.
+func (pv *PluginValidator) AllowedKindRanges() []interface{} {
+	pv.mu.RLock()
+	defer pv.mu.RUnlock()
+	return pv.limits.AllowedKinds.Ranges()
+}
+
+// IsKindAllowed reports whether events of kind are accepted.
+func (pv *PluginValidator) IsKindAllowed(kind int) bool {
+	pv.mu.RLock()
+	defer pv.mu.RUnlock()
+	return pv.limits.AllowedKinds.Allows(kind)
+}
+
+// AddAllowedKind allows an event kind, overriding any exclusion
 func (pv *PluginValidator) AddAllowedKind(kind int) {
 	pv.mu.Lock()
 	defer pv.mu.Unlock()
-	pv.limits.AllowedKinds[kind] = true
+	pv.limits.AllowedKinds.Allow(kind)
 }
 
-// RemoveAllowedKind removes an event kind from the allowed kinds map
+// RemoveAllowedKind disallows an event kind, including within allowed ranges
 func (pv *PluginValidator) RemoveAllowedKind(kind int) {
 	pv.mu.Lock()
 	defer pv.mu.Unlock()
-	delete(pv.limits.AllowedKinds, kind)
+	pv.limits.AllowedKinds.Disallow(kind)
+}
+
+// MsgAlreadyDeleted answers a NIP-09 request whose events are all deleted already.
+const MsgAlreadyDeleted = "duplicate: already deleted"
+
+// deletionAlreadyApplied reports whether a NIP-09 request only names events
+// that already have tombstones. Requests with "a" tags always apply, since
+// they cover versions up to their own created_at.
+func (pv *PluginValidator) deletionAlreadyApplied(ctx context.Context, event nostr.Event) (bool, error) {
+	var ids []string
+	for _, t := range event.Tags {
+		if len(t) < 2 {
+			continue
+		}
+		switch t[0] {
+		case "a":
+			return false, nil
+		case "e":
+			ids = append(ids, t[1])
+		}
+	}
+	return pv.db.AllDeleted(ctx, ids, event.PubKey)
 }
 
 // ValidateAndProcessEvent performs validation and processing of incoming events
 func (pv *PluginValidator) ValidateAndProcessEvent(ctx context.Context, event nostr.Event) (bool, string, error) {
+	ctx, span := tracing.StartChild(ctx, "event.validate", tracing.SpanKindInternal,
+		tracing.Int("nostr.kind", event.Kind))
+	defer span.End()
+
+	valid, msg, err := pv.validateAndProcessEvent(ctx, event)
+	switch {
+	case err != nil:
+		span.RecordError(err)
+	case !valid:
+		span.SetError(msg)
+	}
+	return valid, msg, err
+}
+
+// validateAndProcessEvent implements ValidateAndProcessEvent
+func (pv *PluginValidator) validateAndProcessEvent(ctx context.Context, event nostr.Event) (bool, string, error) {
 	// Check event size using configured limit
 	if len(event.Content) > pv.limits.MaxContentLength {
 		return false, fmt.Sprintf("invalid: event content too large (max %d bytes)", pv.limits.MaxContentLength), nil
@@ -695,18 +623,15 @@ func (pv *PluginValidator) ValidateAndProcessEvent(ctx context.Context, event no
 	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	// Direct database check for duplicates with retry
+	// Duplicate check, answered from the Bloom filter and recent IDs when
+	// possible and from the database with retry otherwise
 	var exists bool
-	var err error
-	for i := 0; i < 3; i++ {
-		exists, err = pv.db.EventExists(dbCtx, event.ID)
-		if err == nil {
-			break
-		}
-		if i < 2 {
-			time.Sleep(100 * time.Millisecond)
-			continue
-		}
+	err := pv.db.Retry(dbCtx, func(ctx context.Context) error {
+		var err error
+		exists, err = pv.db.SeenEvent(ctx, event.ID)
+		return err
+	})
+	if err != nil {
 		return false, "error checking event existence", fmt.Errorf("database error after retries: %w", err)
 	}
 
@@ -721,9 +646,21 @@ func (pv *PluginValidator) ValidateAndProcessEvent(ctx context.Context, event no
 		return false, "invalid: event ID does not match content", nil
 	}
 
-	// Verify signature (important for security)
-	valid, err := event.CheckSignature()
-	if err != nil || !valid {
+	// Tombstoned events were deleted, replaced or removed and stay gone
+	deleted, err := pv.db.IsEventDeleted(dbCtx, event.ID, event.PubKey)
+	if err != nil {
+		return false, "error checking event existence", fmt.Errorf("database error: %w", err)
+	}
+	if deleted {
+		return false, "blocked: event has been deleted", nil
+	}
+
+	// Verify signature (important for security) on the shared verification pool
+	valid, err := pv.sigVerifier.Verify(ctx, &event)
+	if err != nil {
+		return false, "error: signature verification canceled", err
+	}
+	if !valid {
 		return false, "invalid: signature verification failed", nil
 	}
 
@@ -752,6 +689,9 @@ func (pv *PluginValidator) ValidateAndProcessEvent(ctx context.Context, event no
 		); err != nil {
 			return false, err.Error(), nil
 		}
+		if done, err := pv.deletionAlreadyApplied(dbCtx, event); err == nil && done {
+			return true, MsgAlreadyDeleted, nil
+		}
 	case 0: // Metadata
 		if err := pv.validateMetadataEvent(event); err != nil {
 			return false, err.Error(), nil
@@ -769,6 +709,10 @@ func (pv *PluginValidator) ValidateAndProcessEvent(ctx context.Context, event no
 		if err := nips.ValidateKeyPackageEvent(&event); err != nil {
 			return false, fmt.Sprintf("invalid MLS KeyPackage: %s", err.Error()), nil
 		}
+		if kp := GetKeyPackages(); kp != nil && kp.Duplicate(&event) {
+			metrics.KeyPackagesDropped.WithLabelValues("duplicate").Inc()
+			return false, "duplicate: this KeyPackage is already published under another event", nil
+		}
 	case 445: // NIP-EE MLS Group Event
 		if err := nips.ValidateGroupEvent(&event); err != nil {
 			return false, fmt.Sprintf("invalid MLS Group event: %s", err.Error()), nil
@@ -0,0 +1,184 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Poll results: GET /api/polls/<id>/results tallies the NIP-88 responses to
+// a kind 1068 poll on the relay, so clients need not download every response.
+// Each pubkey's latest response before the poll's endsAt counts once; a
+// single choice poll counts only the first option of a response, and options
+// the poll does not offer are ignored.
+
+// pollMaxResponses bounds the responses read for one tally.
+const pollMaxResponses = 10000
+
+// pollOptionResult is the tally of one poll option.
+type pollOptionResult struct {
+	nips.PollOption
+	Votes int `json:"votes"`
+}
+
+// pollResultsResponse is the body of /api/polls/<id>/results.
+type pollResultsResponse struct {
+	PollID    string             `json:"poll_id"`
+	PollType  string             `json:"poll_type"`
+	EndsAt    int64              `json:"ends_at,omitempty"`
+	Closed    bool               `json:"closed"`
+	Options   []pollOptionResult `json:"options"`
+	Voters    int                `json:"voters"`
+	Truncated bool               `json:"truncated"` // more than pollMaxResponses responses were stored
+}
+
+// handlePollResultsAPI serves GET /api/polls/<id>/results.
+func (s *Server) handlePollResultsAPI(w http.ResponseWriter, r *http.Request) {
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled) {
+		return
+	}
+	id := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/polls/"), "/results"))
+	if !nostr.IsValid32ByteHex(id) {
+		writeEventsAPIError(w, r, "INVALID_EVENT_ID", "poll id must be 64 hex characters")
+		return
+	}
+
+	release, ok := s.acquireEventsAPISlot(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(eventsAPIContext(r), eventsAPIQueryTimeout)
+	defer cancel()
+
+	db := s.node.DB()
+	events, err := db.GetEvents(ctx, nostr.Filter{IDs: []string{id}, Kinds: []int{nips.KindPoll}, Limit: 1})
+	if err != nil {
+		logger.Warn("Poll results query failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("poll query", err))
+		return
+	}
+	if len(events) == 0 || !visibleAnonymously(db, &events[0]) {
+		errors.HandleHTTPError(w, r, errors.NotFoundError("poll"))
+		return
+	}
+
+	response, err := s.tallyPoll(ctx, &events[0])
+	if err != nil {
+		logger.Warn("Poll results query failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("poll responses query", err))
+		return
+	}
+	writeEventsAPIJSON(w, response)
+}
+
+// tallyPoll counts the stored responses to poll.
+func (s *Server) tallyPoll(ctx context.Context, poll *nostr.Event) (*pollResultsResponse, error) {
+	def := nips.ParsePoll(poll)
+	f := nostr.Filter{
+		Kinds: []int{nips.KindPollResponse},
+		Tags:  nostr.TagMap{"e": []string{poll.ID}},
+		Limit: pollMaxResponses,
+	}
+	if def.EndsAt > 0 {
+		until := nostr.Timestamp(def.EndsAt)
+		f.Until = &until
+	}
+	responses, err := s.node.DB().GetEvents(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only each pubkey's latest response counts
+	db := s.node.DB()
+	latest := make(map[string]*nostr.Event)
+	for i := range responses {
+		resp := &responses[i]
+		if cur := latest[resp.PubKey]; cur != nil &&
+			(cur.CreatedAt > resp.CreatedAt || (cur.CreatedAt == resp.CreatedAt && cur.ID < resp.ID)) {
+			continue
+		}
+		if !visibleAnonymously(db, resp) {
+			continue
+		}
+		latest[resp.PubKey] = resp
+	}
+
+	votes := make(map[string]int, len(def.Options))
+	voters := 0
+	for _, resp := range latest {
+		_, choices := nips.PollResponseChoices(resp)
+		if def.Type != nips.PollMultipleChoice && len(choices) > 1 {
+			choices = choices[:1]
+		}
+		counted := false
+		seen := make(map[string]bool, len(choices))
+		for _, choice := range choices {
+			if seen[choice] || !def.HasOption(choice) {
+				continue
+			}
+			seen[choice] = true
+			votes[choice]++
+			counted = true
+		}
+		if counted {
+			voters++
+		}
+	}
+
+	result := &pollResultsResponse{
+		PollID:    poll.ID,
+		PollType:  def.Type,
+		EndsAt:    def.EndsAt,
+		Closed:    def.EndsAt > 0 && nostr.Now() >= nostr.Timestamp(def.EndsAt),
+		Options:   make([]pollOptionResult, 0, len(def.Options)),
+		Voters:    voters,
+		Truncated: len(responses) >= pollMaxResponses,
+	}
+	for _, opt := range def.Options {
+		result.Options = append(result.Options, pollOptionResult{PollOption: opt, Votes: votes[opt.ID]})
+	}
+	return result, nil
+}
+
+// checkPollResponse checks a poll response against its poll when the relay
+// stores the poll: the options must exist, a single choice poll takes one
+// option, and closed polls take no responses. Responses to polls stored
+// elsewhere are accepted as they are.
+func (pv *PluginValidator) checkPollResponse(event *nostr.Event) error {
+	if pv.db == nil {
+		return nil
+	}
+	pollID, choices := nips.PollResponseChoices(event)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	poll, err := pv.db.GetEventByID(ctx, pollID)
+	if err != nil || poll.ID == "" {
+		return nil
+	}
+	if poll.Kind != nips.KindPoll {
+		return fmt.Errorf("e tag must reference a kind %d poll", nips.KindPoll)
+	}
+
+	def := nips.ParsePoll(&poll)
+	if def.EndsAt > 0 && int64(event.CreatedAt) > def.EndsAt {
+		return fmt.Errorf("poll closed at %d", def.EndsAt)
+	}
+	if def.Type != nips.PollMultipleChoice && len(choices) > 1 {
+		return fmt.Errorf("single choice poll takes one response")
+	}
+	for _, choice := range choices {
+		if !def.HasOption(choice) {
+			return fmt.Errorf("poll has no option %q", choice)
+		}
+	}
+	return nil
+}
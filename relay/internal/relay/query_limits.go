@@ -0,0 +1,71 @@
+package relay
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+)
+
+// Defaults for the per-connection query limits left unset in ThrottlingConfig.
+const (
+	defaultMaxEventsPerReq      = 2000
+	defaultMaxConcurrentQueries = 4
+)
+
+// queryLimits bounds what a single connection may ask of storage.
+type queryLimits struct {
+	maxLimit        int // largest filter limit accepted
+	maxEventsPerReq int // stored events sent per REQ before it is closed
+	maxConcurrent   int // REQ and COUNT queries running at once
+
+	// History REQ and COUNT may reach back (0 = unlimited)
+	anonymousWindow     time.Duration
+	authenticatedWindow time.Duration
+}
+
+// newQueryLimits resolves the query limits from cfg, falling back to defaults.
+func newQueryLimits(cfg config.ThrottlingConfig) queryLimits {
+	l := queryLimits{
+		maxLimit:        cfg.MaxLimit,
+		maxEventsPerReq: cfg.MaxEventsPerReq,
+		maxConcurrent:   cfg.MaxConcurrentQueries,
+
+		anonymousWindow:     cfg.QueryWindow.Anonymous,
+		authenticatedWindow: cfg.QueryWindow.Authenticated,
+	}
+	if l.maxLimit <= 0 {
+		l.maxLimit = constants.MaxLimit
+	}
+	if l.maxEventsPerReq <= 0 {
+		l.maxEventsPerReq = defaultMaxEventsPerReq
+	}
+	if l.maxConcurrent <= 0 {
+		l.maxConcurrent = defaultMaxConcurrentQueries
+	}
+	return l
+}
+
+// acquireQuery reserves a query slot for a REQ or COUNT command, returning
+// the CLOSED reason when the connection already runs as many queries as it
+// may or the database pool is saturated.
+func (c *WsConnection) acquireQuery(command string) string {
+	if c.node.DB().Overloaded() {
+		metrics.QueriesShed.WithLabelValues(command).Inc()
+		return nips.FormatErrorMessage(nips.ErrorCodeRateLimited, "server busy")
+	}
+	if n := c.activeQueries.Add(1); int(n) > c.queryLimits.maxConcurrent {
+		c.activeQueries.Add(-1)
+		return nips.FormatErrorMessage(nips.ErrorCodeRateLimited,
+			fmt.Sprintf("too many concurrent queries (max %d)", c.queryLimits.maxConcurrent))
+	}
+	return ""
+}
+
+// releaseQuery frees a slot reserved by acquireQuery.
+func (c *WsConnection) releaseQuery() {
+	c.activeQueries.Add(-1)
+}
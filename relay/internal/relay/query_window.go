@@ -0,0 +1,60 @@
+package relay
+
+import (
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Query windows: NIP-01 matches events with since <= created_at <= until,
+// both bounds inclusive. A REQ or COUNT may reach back at most the history
+// window of its connection class, anonymous or authenticated; since values
+// before it, or a missing since, are clamped to its start. Filters naming
+// event ids are direct lookups and are not clamped.
+
+// maxSinceAhead bounds how far in the future a since may lie; beyond it the
+// filter can only be a mistake.
+const maxSinceAhead = 365 * 24 * time.Hour
+
+// queryWindowReason returns the CLOSED reason when f asks for an empty or
+// absurd time window, "" when the window is acceptable.
+func queryWindowReason(f nostr.Filter, now time.Time) string {
+	if f.Since != nil && f.Until != nil && *f.Since > *f.Until {
+		return nips.FormatErrorMessage(nips.ErrorCodeInvalidFilter, "since is after until")
+	}
+	if f.Since != nil && f.Since.Time().After(now.Add(maxSinceAhead)) {
+		return nips.FormatErrorMessage(nips.ErrorCodeInvalidFilter, "since is more than a year in the future")
+	}
+	return ""
+}
+
+// clampQueryWindow returns f with its history limited to window before now,
+// and false when nothing inside the window can match. A filter without since
+// also gets until = now, so it keeps returning the newest events first.
+func clampQueryWindow(f nostr.Filter, window time.Duration, now time.Time) (nostr.Filter, bool) {
+	if window <= 0 || len(f.IDs) > 0 {
+		return f, true
+	}
+	floor := nostr.Timestamp(now.Add(-window).Unix())
+	if f.Until != nil && *f.Until < floor {
+		return f, false
+	}
+	if f.Since != nil && *f.Since >= floor {
+		return f, true
+	}
+	if f.Since == nil && f.Until == nil {
+		until := nostr.Timestamp(now.Unix())
+		f.Until = &until
+	}
+	f.Since = &floor
+	return f, true
+}
+
+// queryWindow returns the history window of c's connection class.
+func (c *WsConnection) queryWindow() time.Duration {
+	if c.hasAuthentication() {
+		return c.queryLimits.authenticatedWindow
+	}
+	return c.queryLimits.anonymousWindow
+}
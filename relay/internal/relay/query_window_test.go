@@ -0,0 +1,98 @@
+package relay
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+func ts(n int64) *nostr.Timestamp {
+	t := nostr.Timestamp(n)
+	return &t
+}
+
+func TestQueryWindowReason(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name   string
+		filter nostr.Filter
+		want   string
+	}{
+		{"no bounds", nostr.Filter{}, ""},
+		{"since before until", nostr.Filter{Since: ts(100), Until: ts(200)}, ""},
+		{"since equals until", nostr.Filter{Since: ts(100), Until: ts(100)}, ""},
+		{"since after until", nostr.Filter{Since: ts(200), Until: ts(100)}, "unsupported: since is after until"},
+		{"since in the near future", nostr.Filter{Since: ts(now.Add(time.Hour).Unix())}, ""},
+		{"since in the far future", nostr.Filter{Since: ts(now.Add(2 * maxSinceAhead).Unix())}, "unsupported: since is more than a year in the future"},
+		{"until in the far future", nostr.Filter{Until: ts(now.Add(2 * maxSinceAhead).Unix())}, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := queryWindowReason(tc.filter, now); got != tc.want {
+				t.Errorf("reason = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClampQueryWindow(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	window := 24 * time.Hour
+	floor := now.Add(-window).Unix()
+	id := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name      string
+		filter    nostr.Filter
+		window    time.Duration
+		wantOK    bool
+		wantSince *nostr.Timestamp
+		wantUntil *nostr.Timestamp
+	}{
+		{"unlimited", nostr.Filter{Since: ts(1)}, 0, true, ts(1), nil},
+		{"no bounds", nostr.Filter{}, window, true, ts(floor), ts(now.Unix())},
+		{"since inside", nostr.Filter{Since: ts(floor + 10)}, window, true, ts(floor + 10), nil},
+		{"since at the floor", nostr.Filter{Since: ts(floor)}, window, true, ts(floor), nil},
+		{"since before", nostr.Filter{Since: ts(5)}, window, true, ts(floor), nil},
+		{"until only", nostr.Filter{Until: ts(floor + 10)}, window, true, ts(floor), ts(floor + 10)},
+		{"until at the floor", nostr.Filter{Until: ts(floor)}, window, true, ts(floor), ts(floor)},
+		{"until before", nostr.Filter{Since: ts(1), Until: ts(floor - 1)}, window, false, ts(1), ts(floor - 1)},
+		{"ids", nostr.Filter{IDs: []string{id}}, window, true, nil, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := clampQueryWindow(tc.filter, tc.window, now)
+			if ok != tc.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tc.wantOK)
+			}
+			if !sameTimestamp(got.Since, tc.wantSince) || !sameTimestamp(got.Until, tc.wantUntil) {
+				t.Errorf("window = [%v, %v], want [%v, %v]", show(got.Since), show(got.Until), show(tc.wantSince), show(tc.wantUntil))
+			}
+		})
+	}
+}
+
+func TestClampQueryWindowKeepsCaller(t *testing.T) {
+	since := nostr.Timestamp(5)
+	f := nostr.Filter{Since: &since}
+	clampQueryWindow(f, time.Hour, time.Unix(1_700_000_000, 0))
+	if since != 5 || *f.Since != 5 {
+		t.Errorf("caller's filter was modified: since = %d", *f.Since)
+	}
+}
+
+func sameTimestamp(a, b *nostr.Timestamp) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func show(t *nostr.Timestamp) interface{} {
+	if t == nil {
+		return "nil"
+	}
+	return int64(*t)
+}
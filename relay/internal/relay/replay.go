@@ -0,0 +1,86 @@
+package relay
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/Shugur-Network/relay/internal/metrics"
+)
+
+// replayDedupSize bounds the event IDs remembered per subscription while its
+// stored events are being replayed. Evicting an ID only risks a duplicate.
+const replayDedupSize = 1024
+
+// replaySet remembers the events sent to one subscription between REQ and
+// EOSE, when the stored-events query and live dispatch can both deliver the
+// same event.
+type replaySet struct {
+	mu    sync.Mutex
+	order *list.List // least recently seen at the front
+	ids   map[string]*list.Element
+}
+
+func newReplaySet() *replaySet {
+	return &replaySet{
+		order: list.New(),
+		ids:   make(map[string]*list.Element),
+	}
+}
+
+// markSent records id and reports whether it had not been sent yet.
+func (r *replaySet) markSent(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if elem, ok := r.ids[id]; ok {
+		r.order.MoveToBack(elem)
+		return false
+	}
+	r.ids[id] = r.order.PushBack(id)
+	if r.order.Len() > replayDedupSize {
+		oldest := r.order.Front()
+		r.order.Remove(oldest)
+		delete(r.ids, oldest.Value.(string))
+	}
+	return true
+}
+
+// startReplay begins tracking sent events for subID until endReplay.
+func (c *WsConnection) startReplay(subID string) *replaySet {
+	r := newReplaySet()
+	c.subMu.Lock()
+	c.replays[subID] = r
+	c.subMu.Unlock()
+	return r
+}
+
+// endReplay stops tracking subID once EOSE is sent, unless the subscription
+// was replaced by a newer REQ with its own replay.
+func (c *WsConnection) endReplay(subID string, r *replaySet) {
+	c.subMu.Lock()
+	if c.replays[subID] == r {
+		delete(c.replays, subID)
+	}
+	c.subMu.Unlock()
+}
+
+// shouldSendReplayed reports whether a stored event may be sent to a
+// subscription still replaying.
+func (r *replaySet) shouldSendReplayed(id string) bool {
+	if r.markSent(id) {
+		return true
+	}
+	metrics.ReplayDuplicatesSuppressed.WithLabelValues("stored").Inc()
+	return false
+}
+
+// shouldSendLive reports whether a live event may be sent to subID. Callers
+// hold subMu; subscriptions past EOSE have no replay set and always pass.
+func (c *WsConnection) shouldSendLive(subID, id string) bool {
+	r := c.replays[subID]
+	if r == nil || r.markSent(id) {
+		return true
+	}
+	metrics.ReplayDuplicatesSuppressed.WithLabelValues("live").Inc()
+	return false
+}
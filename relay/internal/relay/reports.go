@@ -0,0 +1,106 @@
+package relay
+
+import (
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/audit"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// InitReports hooks the NIP-56 report pipeline into the audit log.
+// Called from NewServer; a nil tracker means the pipeline is disabled.
+func InitReports(db *storage.DB) {
+	if db == nil || db.ReportTracker() == nil {
+		return
+	}
+	db.ReportTracker().SetActionHook(func(target storage.ReportTarget, action string) {
+		recordAudit(audit.Entry{
+			Actor:   audit.ActorSystem,
+			Source:  "nip56",
+			Action:  action,
+			Params:  []string{target.Type, target.ID},
+			Outcome: audit.OutcomeSuccess,
+			Message: strconv.Itoa(target.Reporters) + " unique reporters",
+		})
+	})
+}
+
+// isWithheld reports whether an event must not be served: banned through
+// NIP-86, hidden by the report pipeline or labeled by a trusted labeler with
+// a withheld NIP-32 label.
+func (c *WsConnection) isWithheld(evt *nostr.Event) bool {
+	return isWithheldEvent(c.node.DB(), evt)
+}
+
+// isWithheldEvent is isWithheld for readers without a connection.
+func isWithheldEvent(db *storage.DB, evt *nostr.Event) bool {
+	if IsBannedEvent(evt.ID) || isLabelWithheld(evt) {
+		return true
+	}
+	if db != nil {
+		if rt := db.ReportTracker(); rt != nil {
+			return rt.IsHidden(evt.ID)
+		}
+	}
+	return false
+}
+
+// --- NIP-56 Reports ---
+
+func (s *Server) mgmtListReports(params []string) (interface{}, string) {
+	rt := s.node.DB().ReportTracker()
+	if rt == nil {
+		return nil, "report pipeline is disabled"
+	}
+
+	filter := storage.ReportFilter{Limit: 100}
+	if len(params) > 0 && params[0] != "" {
+		switch params[0] {
+		case storage.ReportStatusOpen, storage.ReportStatusHidden, storage.ReportStatusFlagged, storage.ReportStatusDismissed:
+			filter.Status = params[0]
+		default:
+			return nil, "invalid status: must be open, hidden, flagged or dismissed"
+		}
+	}
+	if len(params) > 1 && params[1] != "" {
+		if params[1] != storage.ReportTargetEvent && params[1] != storage.ReportTargetPubkey {
+			return nil, "invalid type: must be event or pubkey"
+		}
+		filter.Type = params[1]
+	}
+	if len(params) > 2 && params[2] != "" {
+		n, err := strconv.Atoi(params[2])
+		if err != nil || n < 1 || n > 1000 {
+			return nil, "invalid limit: must be between 1 and 1000"
+		}
+		filter.Limit = n
+	}
+	return rt.ListTargets(filter), ""
+}
+
+func (s *Server) mgmtResolveReport(params []string, admin string) (interface{}, string) {
+	rt := s.node.DB().ReportTracker()
+	if rt == nil {
+		return nil, "report pipeline is disabled"
+	}
+	if len(params) < 2 {
+		return nil, "missing parameters: id and action (hide or dismiss)"
+	}
+	// Report targets are events or pubkeys; bare hex may be either
+	var id, errMsg string
+	if isPubkeyEntity(params[0]) {
+		id, errMsg = parsePubkeyParam(params[0])
+	} else {
+		id, errMsg = s.parseEventIDParam(params[0])
+	}
+	if errMsg != "" {
+		return nil, "invalid id: must be an event ID or pubkey as hex or NIP-19 entity"
+	}
+
+	target, err := rt.Resolve(id, params[1], admin)
+	if err != nil {
+		return nil, err.Error()
+	}
+	return target, ""
+}
@@ -0,0 +1,112 @@
+package relay
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/reputation"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"go.uber.org/zap"
+)
+
+// reputationInstance is the package-level trust graph (nil when web-of-trust is disabled).
+var reputationInstance *reputation.Graph
+
+// GetReputation returns the trust graph, or nil when web-of-trust is disabled.
+func GetReputation() *reputation.Graph {
+	return reputationInstance
+}
+
+// InitReputation builds the trust graph over the stored follow lists. Called
+// from NewServer; the graph is computed and refreshed by startReputation.
+func InitReputation(cfg *config.Config, db *storage.DB) *reputation.Graph {
+	if !cfg.Reputation.Enabled || db == nil || len(cfg.Reputation.Anchors) == 0 {
+		reputationInstance = nil
+		return nil
+	}
+	reputationInstance = reputation.New(cfg.Reputation, db.FollowLists)
+	return reputationInstance
+}
+
+// startReputation computes the trust graph and keeps it refreshed.
+func startReputation(ctx context.Context) {
+	if g := GetReputation(); g != nil {
+		g.Start(ctx)
+	}
+}
+
+// hasTrustedAuth reports whether the connection is authenticated as a trusted pubkey.
+func (c *WsConnection) hasTrustedAuth() bool {
+	g := GetReputation()
+	if g == nil {
+		return false
+	}
+	c.authMu.RLock()
+	defer c.authMu.RUnlock()
+	for pubkey := range c.authedPubkeys {
+		if g.IsTrusted(pubkey) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Web of Trust ---
+
+func (s *Server) mgmtGetPubkeyTrust(params []string) (interface{}, string) {
+	g := GetReputation()
+	if g == nil {
+		return nil, "web of trust is disabled"
+	}
+	if len(params) < 1 {
+		return nil, "missing pubkey parameter"
+	}
+	pubkey, errMsg := parsePubkeyParam(params[0])
+	if errMsg != "" {
+		return nil, errMsg
+	}
+	trust, _ := g.Lookup(pubkey)
+	return trust, ""
+}
+
+func (s *Server) mgmtListTrustedPubkeys(params []string) (interface{}, string) {
+	g := GetReputation()
+	if g == nil {
+		return []reputation.Trust{}, ""
+	}
+	limit := 100
+	if len(params) > 0 && params[0] != "" {
+		n, err := strconv.Atoi(params[0])
+		if err != nil || n < 1 || n > 10000 {
+			return nil, "invalid limit: must be between 1 and 10000"
+		}
+		limit = n
+	}
+	type trusted struct {
+		reputation.Trust
+		Npub string `json:"npub"`
+	}
+	top := g.TopTrusted(limit)
+	entries := make([]trusted, 0, len(top))
+	for _, t := range top {
+		entries = append(entries, trusted{Trust: t, Npub: npub(t.Pubkey)})
+	}
+	return entries, ""
+}
+
+func (s *Server) mgmtRefreshTrust() (interface{}, string) {
+	g := GetReputation()
+	if g == nil {
+		return nil, "web of trust is disabled"
+	}
+	if err := g.Refresh(context.Background()); err != nil {
+		logger.New("nip86").Warn("Trust graph refresh failed", zap.Error(err))
+		return nil, "failed to refresh trust graph"
+	}
+
+	logger.New("nip86").Info("Trust graph refreshed via management API")
+
+	return g.Stats(), ""
+}
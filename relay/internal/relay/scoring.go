@@ -0,0 +1,147 @@
+package relay
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/audit"
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/scoring"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"go.uber.org/zap"
+)
+
+// scoringInstance is the package-level content scoring pipeline (nil when scoring is disabled).
+var scoringInstance *scoring.Pipeline
+
+// GetContentScoring returns the content scoring pipeline, or nil when scoring is disabled.
+func GetContentScoring() *scoring.Pipeline {
+	return scoringInstance
+}
+
+// InitContentScoring builds the content scoring pipeline and hooks it into event storage.
+// Called from NewServer; workers are started by startContentScoring.
+func InitContentScoring(cfg *config.Config, db *storage.DB) *scoring.Pipeline {
+	if !cfg.Scoring.Enabled || db == nil {
+		scoringInstance = nil
+		return nil
+	}
+
+	p := scoring.New(cfg.Scoring)
+	p.SetRemover(db.RemoveEvent)
+	db.SetContentScorer(p.Submit)
+	p.SetShadowBanHook(func(pubkey string, deleted int) {
+		recordAudit(audit.Entry{
+			Actor:   audit.ActorSystem,
+			Source:  "scoring",
+			Action:  "shadowbanpubkey",
+			Params:  []string{pubkey},
+			Outcome: audit.OutcomeSuccess,
+			Message: strconv.Itoa(deleted) + " events removed as spam",
+		})
+	})
+	scoringInstance = p
+	return p
+}
+
+// startContentScoring launches the scoring workers when scoring is enabled.
+func startContentScoring(ctx context.Context) {
+	if p := GetContentScoring(); p != nil {
+		p.Start(ctx)
+	}
+}
+
+// --- Content Scoring ---
+
+func (s *Server) mgmtListEventScores(params []string) (interface{}, string) {
+	p := GetContentScoring()
+	if p == nil {
+		return nil, "content scoring is disabled"
+	}
+
+	limit, minScore := 100, 0.0
+	if len(params) > 0 && params[0] != "" {
+		n, err := strconv.Atoi(params[0])
+		if err != nil || n < 1 || n > 1000 {
+			return nil, "invalid limit: must be between 1 and 1000"
+		}
+		limit = n
+	}
+	if len(params) > 1 && params[1] != "" {
+		f, err := strconv.ParseFloat(params[1], 64)
+		if err != nil || f < 0 || f > 1 {
+			return nil, "invalid min_score: must be between 0 and 1"
+		}
+		minScore = f
+	}
+	return p.RecentScores(limit, minScore), ""
+}
+
+func (s *Server) mgmtGetPubkeyScore(params []string) (interface{}, string) {
+	p := GetContentScoring()
+	if p == nil {
+		return nil, "content scoring is disabled"
+	}
+	if len(params) < 1 {
+		return nil, "missing pubkey parameter"
+	}
+	pubkey, errMsg := parsePubkeyParam(params[0])
+	if errMsg != "" {
+		return nil, errMsg
+	}
+
+	summary := p.AuthorScore(pubkey)
+	if summary == nil {
+		return &scoring.AuthorSummary{Pubkey: pubkey}, ""
+	}
+	return summary, ""
+}
+
+func (s *Server) mgmtListShadowBannedPubkeys() (interface{}, string) {
+	p := GetContentScoring()
+	if p == nil {
+		return []pubkeyEntry{}, ""
+	}
+	return pubkeyEntries(p.ShadowBanned()), ""
+}
+
+func (s *Server) mgmtShadowBanPubkey(params []string) (interface{}, string) {
+	p := GetContentScoring()
+	if p == nil {
+		return nil, "content scoring is disabled"
+	}
+	if len(params) < 1 {
+		return nil, "missing pubkey parameter"
+	}
+	pubkey, errMsg := parsePubkeyParam(params[0])
+	if errMsg != "" {
+		return nil, errMsg
+	}
+	p.ShadowBan(pubkey)
+
+	logger.New("nip86").Info("Pubkey shadow-banned via management API",
+		zap.String("pubkey", pubkey[:16]+"..."))
+
+	return true, ""
+}
+
+func (s *Server) mgmtUnshadowBanPubkey(params []string) (interface{}, string) {
+	p := GetContentScoring()
+	if p == nil {
+		return nil, "content scoring is disabled"
+	}
+	if len(params) < 1 {
+		return nil, "missing pubkey parameter"
+	}
+	pubkey, errMsg := parsePubkeyParam(params[0])
+	if errMsg != "" {
+		return nil, errMsg
+	}
+	p.LiftShadowBan(pubkey)
+
+	logger.New("nip86").Info("Pubkey shadow-ban lifted via management API",
+		zap.String("pubkey", pubkey[:16]+"..."))
+
+	return true, ""
+}
@@ -2,18 +2,22 @@ package relay
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/Shugur-Network/relay/internal/clientip"
 	"github.com/Shugur-Network/relay/internal/config"
-	"github.com/Shugur-Network/relay/internal/constants"
 	"github.com/Shugur-Network/relay/internal/domain"
 	"github.com/Shugur-Network/relay/internal/health"
 	"github.com/Shugur-Network/relay/internal/logger"
 	"github.com/Shugur-Network/relay/internal/metrics"
 	"github.com/Shugur-Network/relay/internal/relay/nips"
 	"github.com/Shugur-Network/relay/internal/storage"
+	"github.com/Shugur-Network/relay/internal/tlscert"
 	"github.com/Shugur-Network/relay/internal/web"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
@@ -26,6 +30,8 @@ type Server struct {
 	node          domain.NodeInterface
 	webHandler    *web.Handler
 	healthChecker *health.HealthChecker
+	eventsAPI     *eventsAPI
+	streamAPI     *streamAPI
 }
 
 // NewServer constructs a new Server with the given RelayConfig and NodeInterface.
@@ -35,18 +41,125 @@ func NewServer(relayCfg config.RelayConfig, node domain.NodeInterface, fullCfg *
 	// Create adapters for health checker
 	dbAdapter := &dbHealthAdapter{db: node.DB()}
 	nodeAdapter := &nodeHealthAdapter{node: node}
+	pipelineAdapter := &pipelineHealthAdapter{node: node}
 	
 	// Create health checker
 	healthChecker := health.NewHealthChecker(
 		dbAdapter,
 		nodeAdapter,
+		pipelineAdapter,
 		fullCfg,
 		logger.New("health"),
 		config.Version,
 	)
 
+	// Open the audit log for admin and moderation actions
+	InitAuditLog(fullCfg)
+
+	// Audit automatic actions of the NIP-56 report pipeline
+	InitReports(node.DB())
+
+	// Honour client IP headers only from trusted proxies
+	if err := clientip.SetTrustedProxies(fullCfg.Relay.TrustedProxies); err != nil {
+		logger.Warn("Ignoring invalid trusted proxies", zap.Error(err))
+	}
+
+	// Load address range, country and ASN blocks
+	InitIPBlocking(fullCfg)
+
+	// Restore NIP-86 bans and blocks made before the last restart
+	pv, _ := node.GetValidator().(*PluginValidator)
+	LoadManagementState(context.Background(), node.DB(), pv)
+
 	// Initialize NIP-29 group store
-	InitGroupStore(fullCfg)
+	gs := InitGroupStore(fullCfg)
+
+	// Restore previous relay keys still honoured after a rotation
+	gs.LoadKeyTransitions(context.Background(), node.DB())
+
+	// Restore NIP-43 membership from the relay-signed membership list
+	GetMembershipStore().LoadFromDB(context.Background(), node.DB(), gs.GetRelayPubkey())
+
+	// Initialize outbox for relay-signed events
+	InitOutbox(fullCfg, gs.GetRelayPubkey(), node.DB())
+
+	// Initialize namespace policy store
+	InitNamespacePolicyStore(fullCfg).LoadFromDB(context.Background(), node.DB())
+
+	// Initialize NIP-47 Wallet Connect mode
+	InitNWCStore(fullCfg)
+
+	// Initialize NIP-17 DM inbox mode
+	InitDMInbox(fullCfg)
+
+	// Initialize virtual relays served by hostname
+	InitTenants(fullCfg)
+
+	// Initialize asynchronous content scoring
+	InitContentScoring(fullCfg, node.DB())
+
+	// Initialize the web-of-trust graph
+	InitReputation(fullCfg, node.DB())
+
+	// Initialize near-duplicate spam clustering
+	webHandler.SetSpamClusters(InitSpamClusters(fullCfg))
+
+	// Initialize client telemetry
+	webHandler.SetClientStats(InitClientStats(fullCfg))
+
+	// Initialize client anomaly detection
+	webHandler.SetAnomalyDetector(InitAnomalyDetector(fullCfg))
+
+	// Initialize NIP-03 OpenTimestamps verification
+	webHandler.SetOpenTimestamps(InitOpenTimestamps(fullCfg, node.DB()))
+
+	// Initialize the kind 0 metadata cache behind /api/profile
+	InitMetadataCache(fullCfg, node.DB())
+
+	// Initialize the NIP-EE KeyPackage index behind /api/keypackages
+	InitKeyPackages(fullCfg, node.DB())
+
+	// Initialize the time capsule unlock schedule behind /api/capsules
+	InitCapsuleUnlocks(fullCfg, node.DB())
+
+	// Initialize the NIP-15 marketplace index behind /api/marketplace
+	InitMarketplace(fullCfg, node.DB())
+
+	// Initialize the NIP-99 classified listing index behind /api/classifieds
+	InitClassifieds(fullCfg, node.DB())
+
+	// Initialize the NIP-53 live activity tracker behind /api/live
+	InitLiveTracker(fullCfg, node.DB())
+
+	// Initialize the NIP-89 application handler index behind /api/handlers
+	InitAppHandlers(fullCfg, node.DB())
+
+	// Initialize the NIP-72 community index behind /api/communities
+	InitCommunities(fullCfg, node.DB())
+
+	// Initialize the NIP-32 labels API, filter extension and withheld labels
+	InitLabels(fullCfg, node.DB())
+
+	// Initialize NIP-05 verification
+	webHandler.SetNIP05Resolver(InitNIP05Resolver(fullCfg))
+
+	// Serve the hosted NIP-05 names
+	InitNIP05Directory(fullCfg, node.DB())
+
+	// Initialize NIP-57 zap receipt verification
+	InitZapVerifier(fullCfg, node.DB())
+
+	// Serve the relay's lightning address and publish its zap receipts
+	InitLNURLPay(fullCfg, node)
+
+	// Switch off the NIPs listed in NIPS.DISABLED
+	InitNIPSwitches(fullCfg)
+
+	// Initialize the MQTT/Kafka event sink
+	InitEventSink(fullCfg, node.DB())
+
+	// Initialize webhook notifications for matching events
+	InitWebhooks(fullCfg, node.DB())
 
 	return &Server{
 		cfg:           relayCfg,
@@ -54,22 +167,103 @@ func NewServer(relayCfg config.RelayConfig, node domain.NodeInterface, fullCfg *
 		node:          node,
 		webHandler:    webHandler,
 		healthChecker: healthChecker,
+		eventsAPI:     newEventsAPI(fullCfg),
+		streamAPI:     newStreamAPI(fullCfg),
 	}
 }
 
+// proxyHeaderTimeout bounds how long a trusted proxy may take to send its
+// PROXY protocol header.
+const proxyHeaderTimeout = 5 * time.Second
+
 // ListenAndServe starts your WebSocket relay server and serves NIP-11 on normal HTTP requests.
 func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 	upgrader := websocket.Upgrader{
 		ReadBufferSize:    1024 * 1024,
 		WriteBufferSize:   1024 * 1024,
 		CheckOrigin:       func(r *http.Request) bool { return true },
-		EnableCompression: true,
+		EnableCompression: s.cfg.Compression.Enabled,
 		HandshakeTimeout:  10 * time.Second,
 	}
 
 	// Start background task to clean expired bans
 	go cleanExpiredBans()
 
+	// Start background task to lift expired NIP-86 bans and blocks
+	go s.expireManagementDecisions(ctx)
+
+	// Start background task to drop expired NIP-43 invite codes
+	go cleanExpiredInvites(ctx)
+
+	// Start background task to lift lapsed NIP-29 group mutes
+	go expireGroupMutes(ctx, s.node)
+
+	// Start background task to drop expired Wallet Connect messages
+	go cleanExpiredNWC(ctx)
+
+	// Start background task to delete gift wraps delivered in DM inbox mode
+	go purgeDeliveredDMs(ctx, s.node)
+
+	// Start background task to drop tenant records of deleted events
+	go pruneEventTenants(ctx, s.node)
+
+	// Start content scoring workers
+	startContentScoring(ctx)
+
+	// Verify NIP-03 attestations and upgrade pending ones
+	startOpenTimestamps(ctx, s.node.DB())
+
+	// Index stored KeyPackages and delete expired ones
+	startKeyPackages(ctx, s.node.DB())
+
+	// Schedule stored time capsules and notify recipients as they unlock
+	startCapsuleUnlocks(ctx, s.node)
+
+	// Index stored marketplace stalls and products
+	startMarketplace(ctx, s.node.DB())
+
+	// Index stored classified listings and delete aged and sold ones
+	startClassifieds(ctx, s.node.DB())
+
+	// Track stored live activities and forget stale room presences
+	startLiveTracker(ctx, s.node.DB())
+
+	// Index stored application handlers and recommendations
+	startAppHandlers(ctx, s.node.DB())
+
+	// Index stored community posts and approvals and approve allowlisted posts
+	startCommunities(ctx, s.node)
+
+	// Load the events and pubkeys withheld by label
+	startLabels(ctx)
+
+	// Compute and periodically refresh trust scores
+	startReputation(ctx)
+
+	// Publish relay-signed events to external relays
+	startOutbox(ctx)
+
+	// Stream accepted events to the MQTT/Kafka sink
+	startEventSink(ctx)
+
+	// POST matching events to the configured webhooks
+	startWebhooks(ctx)
+
+	// Withdraw NIP-38 user statuses from live subscribers when they expire
+	startStatusExpiry(ctx, s.node)
+
+	// Flush client telemetry to its snapshot file
+	startClientStats(ctx)
+
+	// Compare client behaviour to the baseline every window
+	startAnomalyDetector(ctx)
+
+	// Publish the receipts of zaps paid to the relay's lightning address
+	startLNURLPay(ctx)
+
+	// Apply changes to NIPS.DISABLED without a restart
+	startNIPSwitchReload(ctx, s.fullCfg)
+
 	// Root handler
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// Track request metrics
@@ -79,13 +273,23 @@ func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 			metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds())
 		}()
 
-		if isWebSocketRequest(r) {
+		if r.URL.Path == "/api/live" && isWebSocketRequest(r) {
+			// Dashboard live stream (a WebSocket, but not a relay connection)
+			if s.webHandler.Authorize(w, r) {
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleLiveAPI)(w, r)
+			}
+		} else if isWebSocketRequest(r) {
 			// Handle as relay WebSocket connection
 			handleWebSocketConnection(ctx, w, r, upgrader, s.node, s.cfg)
 		} else if r.Header.Get("Content-Type") == "application/nostr+json+rpc" {
 			// NIP-86: Relay Management API (JSON-RPC)
 			s.handleManagementAPI(w, r)
 		} else {
+			// Dashboard authentication (static files, health and NIP-11 stay public)
+			if requiresDashboardAuth(r) && !s.webHandler.Authorize(w, r) {
+				return
+			}
+
 			// Handle HTTP requests with input validation
 			switch {
 			case r.URL.Path == "/" && r.Header.Get("Accept") != "application/nostr+json":
@@ -96,8 +300,8 @@ func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 				apiHeaders := web.APISecurityHeaders()
 				apiHeaders.Apply(w)
 				// Serve NIP-11 metadata for Nostr clients
-				metadata := constants.DefaultRelayMetadata(s.fullCfg)
-				nips.ServeRelayMetadata(w, metadata)
+				metadata := s.relayDocument(r.Host)
+				nips.ServeCustomRelayMetadata(w, metadata)
 			case strings.HasPrefix(r.URL.Path, "/static/"):
 				// Serve static files with validation
 				web.SecureValidatedHandlerFunc(s.webHandler.HandleStatic)(w, r)
@@ -107,11 +311,11 @@ func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 				apiHeaders.Apply(w)
 				// Serve relay info API with validation
 				web.ValidatedHandlerFunc(web.APIInputValidation(), func(w http.ResponseWriter, r *http.Request) {
-					metadata := constants.DefaultRelayMetadata(s.fullCfg)
+					metadata := s.relayDocument(r.Host)
 					w.Header().Set("Content-Type", "application/json")
 					w.Header().Set("Access-Control-Allow-Origin", "*")
 					w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-					nips.ServeRelayMetadata(w, metadata)
+					nips.ServeCustomRelayMetadata(w, metadata)
 				})(w, r)
 			case r.URL.Path == "/api/stats":
 				// Serve relay statistics API with validation
@@ -122,9 +326,113 @@ func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 			case r.URL.Path == "/api/cluster":
 				// Serve cluster information API with validation
 				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleClusterAPI)(w, r)
+			case r.URL.Path == "/api/relay-hints":
+				// Serve NIP-65 relay hints for a pubkey with validation
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleRelayHintsAPI)(w, r)
+			case r.URL.Path == "/api/dvm/jobs":
+				// Serve NIP-90 DVM job tracking with validation
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleDVMJobsAPI)(w, r)
+			case r.URL.Path == "/api/reports":
+				// Serve the NIP-56 report queue with validation
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleReportsAPI)(w, r)
+			case r.URL.Path == "/api/spam-clusters":
+				// Serve near-duplicate spam clusters with validation
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleSpamClustersAPI)(w, r)
+			case r.URL.Path == "/api/clients":
+				// Serve client telemetry with validation
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleClientsAPI)(w, r)
+			case r.URL.Path == "/api/anomalies":
+				// Serve client anomaly alerts with validation
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleAnomaliesAPI)(w, r)
+			case r.URL.Path == "/api/nip05":
+				// Serve NIP-05 verification state with validation
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleNIP05API)(w, r)
+			case r.URL.Path == "/api/opentimestamps":
+				// Serve NIP-03 attestation verification with validation
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleOpenTimestampsAPI)(w, r)
+			case r.URL.Path == "/api/events":
+				// Serve stored events over HTTP with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleEventsAPI)(w, r)
+			case strings.HasPrefix(r.URL.Path, "/api/event/"):
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleEventAPI)(w, r)
+			case strings.HasPrefix(r.URL.Path, "/api/thread/"):
+				// Serve the reply tree of a conversation with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleThreadAPI)(w, r)
+			case strings.HasPrefix(r.URL.Path, "/api/polls/") && strings.HasSuffix(r.URL.Path, "/results"):
+				// Tally NIP-88 poll responses with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handlePollResultsAPI)(w, r)
+			case strings.HasPrefix(r.URL.Path, "/api/profile/"):
+				// Serve cached kind 0 profiles with NIP-05 status with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleProfileAPI)(w, r)
+			case isGroupExportPath(r.URL.Path):
+				// Stream a NIP-29 group's history to a group admin with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleGroupExport)(w, r)
+			case strings.HasPrefix(r.URL.Path, "/api/keypackages/"):
+				// Serve the freshest NIP-EE KeyPackages of a pubkey with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleKeyPackagesAPI)(w, r)
+			case isCapsuleUnlocksPath(r.URL.Path):
+				// List the upcoming time capsule unlocks of a pubkey with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleCapsuleUnlocksAPI)(w, r)
+			case r.URL.Path == "/api/marketplace/products":
+				// Search NIP-15 marketplace products with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleMarketplaceProductsAPI)(w, r)
+			case r.URL.Path == "/api/marketplace/stalls":
+				// Browse NIP-15 marketplace stalls with their products with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleMarketplaceStallsAPI)(w, r)
+			case r.URL.Path == "/api/classifieds":
+				// Search NIP-99 classified listings by location with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleClassifiedsAPI)(w, r)
+			case r.URL.Path == "/api/live":
+				// List NIP-53 live activities with their participants with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleLiveActivitiesAPI)(w, r)
+			case r.URL.Path == "/api/handlers":
+				// Rank NIP-89 application handlers for a kind with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleAppHandlersAPI)(w, r)
+			case r.URL.Path == communityQueuePath:
+				// Serve a NIP-72 community's moderation queue to its moderators with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleCommunityQueueAPI)(w, r)
+			case r.URL.Path == "/api/communities/approved":
+				// List a NIP-72 community's approved posts with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleCommunityApprovedAPI)(w, r)
+			case r.URL.Path == "/api/labels":
+				// List NIP-32 labels with the labeled events with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleLabelsAPI)(w, r)
+			case r.URL.Path == "/api/wiki":
+				// Resolve a NIP-54 wiki topic to ranked articles with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleWikiAPI)(w, r)
+			case r.URL.Path == "/api/graph/followers" || r.URL.Path == "/api/graph/following":
+				// Serve follower and follow lists from the follow graph with validation
+				web.SecureValidatedEventsAPIHandlerFunc(s.handleGraphAPI)(w, r)
+			case r.URL.Path == "/api/stream":
+				// Follow live events over Server-Sent Events with validation
+				web.SecureValidatedEventsAPIHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					s.handleStreamAPI(ctx, w, r)
+				})(w, r)
+			case strings.HasPrefix(r.URL.Path, "/a/") || strings.HasPrefix(r.URL.Path, "/article/"):
+				// Render stored NIP-23 articles as HTML pages with validation
+				web.SecureValidatedArticleHandlerFunc(s.handleArticle)(w, r)
+			case r.URL.Path == "/api/auth/login":
+				// Exchange a NIP-98 signed request for a dashboard session
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleLogin)(w, r)
+			case r.URL.Path == "/api/auth/logout":
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleLogout)(w, r)
+			case r.URL.Path == "/api/auth/session":
+				web.SecureValidatedAPIHandlerFunc(s.webHandler.HandleSession)(w, r)
+			case r.URL.Path == "/.well-known/nostr.json":
+				// Serve the hosted NIP-05 names with validation
+				web.SecureValidatedWellKnownHandlerFunc(s.handleNostrJSON)(w, r)
+			case strings.HasPrefix(r.URL.Path, "/.well-known/lnurlp/"):
+				// Serve the relay's lightning address (LNURL-pay) with validation
+				web.SecureValidatedWellKnownHandlerFunc(s.handleLNURLPay)(w, r)
 			case r.URL.Path == "/health":
 				// Serve health check endpoint - no validation needed for basic health checks
 				s.healthChecker.HandleHealth(w, r)
+			case r.URL.Path == "/healthz":
+				// Liveness probe for orchestrators
+				s.healthChecker.HandleLiveness(w, r)
+			case r.URL.Path == "/readyz":
+				// Readiness probe: database, event queues and dispatcher
+				s.healthChecker.HandleReadiness(w, r)
 			default:
 				// Log invalid requests for security monitoring
 				logger.Warn("Invalid request path",
@@ -152,8 +460,46 @@ func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 		_ = httpSrv.Shutdown(shutdownCtx)
 	}()
 
-	logger.Info("Relay WebSocket server listening", zap.String("address", addr))
-	return httpSrv.ListenAndServe()
+	// Native TLS: certificates from files or ACME, plain HTTP redirected
+	tlsCfg := s.fullCfg.TLS
+	var certManager *tlscert.Manager
+	if tlsCfg.Enabled {
+		var err error
+		if certManager, err = tlscert.New(tlsCfg); err != nil {
+			return fmt.Errorf("TLS: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	// PROXY headers precede the TLS handshake
+	if s.cfg.ProxyProtocol {
+		ln = clientip.NewProxyListener(ln, proxyHeaderTimeout)
+	}
+	if certManager != nil {
+		certManager.Start(ctx)
+		ln = tls.NewListener(ln, certManager.TLSConfig())
+		if tlsCfg.HTTPAddr != "" {
+			go certManager.ServeHTTP(ctx, tlsCfg.HTTPAddr)
+		}
+	}
+
+	logger.Info("Relay WebSocket server listening",
+		zap.String("address", addr),
+		zap.Bool("tls", certManager != nil),
+		zap.Bool("proxy_protocol", s.cfg.ProxyProtocol))
+	return httpSrv.Serve(ln)
+}
+
+// requiresDashboardAuth reports whether an HTTP request goes through dashboard
+// authentication: the dashboard page and every /api/* endpoint.
+func requiresDashboardAuth(r *http.Request) bool {
+	if r.Header.Get("Accept") == "application/nostr+json" || isGroupExportPath(r.URL.Path) || r.URL.Path == communityQueuePath {
+		return false
+	}
+	return r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, "/api/")
 }
 
 // isWebSocketRequest checks if the request is a WebSocket upgrade request
@@ -198,3 +544,41 @@ func (n *nodeHealthAdapter) GetConnectionCount() int {
 func (n *nodeHealthAdapter) GetStartTime() time.Time {
 	return n.node.GetStartTime()
 }
+
+// pipelineHealthAdapter adapts the node's event processor and dispatcher to health.PipelineInterface
+type pipelineHealthAdapter struct {
+	node domain.NodeInterface
+}
+
+func (p *pipelineHealthAdapter) EventQueues() []health.QueueStats {
+	ep := p.node.GetEventProcessor()
+	if ep == nil {
+		return nil
+	}
+	var queues []health.QueueStats
+	for _, class := range []storage.EventPriority{storage.PriorityHigh, storage.PriorityNormal} {
+		length, capacity := ep.QueueUsage(class)
+		queues = append(queues, health.QueueStats{Name: class.String(), Length: length, Capacity: capacity})
+	}
+	return queues
+}
+
+func (p *pipelineHealthAdapter) DispatcherRunning() bool {
+	ed := p.node.GetEventDispatcher()
+	return ed != nil && ed.Running()
+}
+
+func (p *pipelineHealthAdapter) DispatcherHeartbeat() time.Time {
+	if ed := p.node.GetEventDispatcher(); ed != nil {
+		return ed.Heartbeat()
+	}
+	return time.Time{}
+}
+
+func (p *pipelineHealthAdapter) DispatcherBuffer() health.QueueStats {
+	stats := health.QueueStats{Name: "dispatcher"}
+	if ed := p.node.GetEventDispatcher(); ed != nil {
+		stats.Length, stats.Capacity = ed.BufferUsage()
+	}
+	return stats
+}
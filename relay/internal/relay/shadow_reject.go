@@ -0,0 +1,140 @@
+package relay
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Shadow rejection: events from shadow-banned or low-reputation pubkeys and
+// suspected spam are answered OK true but neither stored nor dispatched, so
+// spammers cannot tell they are being filtered.
+
+// Shadow rejection reasons.
+const (
+	ShadowRejectBanned        = "shadow_banned"  // author shadow-banned by content scoring
+	ShadowRejectLowReputation = "low_reputation" // unknown pubkey over its web-of-trust rate limit
+	ShadowRejectSpam          = "spam"           // content rated as spam by the local scorers
+	ShadowRejectCluster       = "spam_cluster"   // near-duplicate of a note flooded by many pubkeys
+)
+
+// maxShadowRejectPubkeys bounds the per-pubkey counts kept for the management API.
+const maxShadowRejectPubkeys = 10000
+
+// ShadowRejectStat counts the shadow rejections of one pubkey.
+type ShadowRejectStat struct {
+	Pubkey     string    `json:"pubkey"`
+	Count      int64     `json:"count"`
+	LastReason string    `json:"last_reason"`
+	LastAt     time.Time `json:"last_at"`
+}
+
+// shadowRejectTracker counts shadow rejections by reason and by pubkey.
+type shadowRejectTracker struct {
+	mu       sync.Mutex
+	byReason map[string]int64
+	pubkeys  map[string]*ShadowRejectStat
+}
+
+var shadowRejections = &shadowRejectTracker{
+	byReason: make(map[string]int64),
+	pubkeys:  make(map[string]*ShadowRejectStat),
+}
+
+// record counts one shadow rejection of pubkey for reason.
+func (t *shadowRejectTracker) record(pubkey, reason string) {
+	metrics.ShadowRejected.WithLabelValues(reason).Inc()
+
+	pubkey = strings.ToLower(pubkey)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.byReason[reason]++
+	stat := t.pubkeys[pubkey]
+	if stat == nil {
+		if len(t.pubkeys) >= maxShadowRejectPubkeys {
+			t.pubkeys = make(map[string]*ShadowRejectStat)
+		}
+		stat = &ShadowRejectStat{Pubkey: pubkey}
+		t.pubkeys[pubkey] = stat
+	}
+	stat.Count++
+	stat.LastReason = reason
+	stat.LastAt = time.Now()
+}
+
+// snapshot returns the counts by reason and the limit pubkeys with the most
+// shadow rejections.
+func (t *shadowRejectTracker) snapshot(limit int) (map[string]int64, []ShadowRejectStat) {
+	t.mu.Lock()
+	byReason := make(map[string]int64, len(t.byReason))
+	for reason, n := range t.byReason {
+		byReason[reason] = n
+	}
+	stats := make([]ShadowRejectStat, 0, len(t.pubkeys))
+	for _, stat := range t.pubkeys {
+		stats = append(stats, *stat)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].LastAt.After(stats[j].LastAt)
+	})
+	if len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return byReason, stats
+}
+
+// shadowRejectReason returns why evt is shadow rejected by content scoring,
+// or "" when it is not.
+func shadowRejectReason(ctx context.Context, evt *nostr.Event) string {
+	cs := GetContentScoring()
+	if cs == nil {
+		return ""
+	}
+	if cs.IsShadowBanned(evt.PubKey) {
+		return ShadowRejectBanned
+	}
+	if cs.IsSuspectedSpam(ctx, evt) {
+		return ShadowRejectSpam
+	}
+	return ""
+}
+
+// shadowReject acknowledges evt as accepted without storing or dispatching it.
+func (c *WsConnection) shadowReject(evt *nostr.Event, reason string) {
+	shadowRejections.record(evt.PubKey, reason)
+	c.sendOK(evt.ID, true, "")
+}
+
+// --- Shadow Rejections ---
+
+func (s *Server) mgmtListShadowRejections(params []string) (interface{}, string) {
+	limit := 100
+	if len(params) > 0 && params[0] != "" {
+		n, err := strconv.Atoi(params[0])
+		if err != nil || n < 1 || n > 1000 {
+			return nil, "limit must be between 1 and 1000"
+		}
+		limit = n
+	}
+	byReason, pubkeys := shadowRejections.snapshot(limit)
+	var total int64
+	for _, n := range byReason {
+		total += n
+	}
+	return map[string]interface{}{
+		"total":     total,
+		"by_reason": byReason,
+		"pubkeys":   pubkeys,
+	}, ""
+}
@@ -0,0 +1,39 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/sink"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"go.uber.org/zap"
+)
+
+// sinkInstance is the package-level MQTT/Kafka event sink (nil when disabled).
+var sinkInstance *sink.Sink
+
+// InitEventSink builds the event sink and hooks it into event storage.
+// Called from NewServer; publishing is started by startEventSink.
+func InitEventSink(cfg *config.Config, db *storage.DB) *sink.Sink {
+	sinkInstance = nil
+	if !cfg.Sink.Enabled || db == nil {
+		return nil
+	}
+
+	s, err := sink.New(cfg.Sink)
+	if err != nil {
+		logger.New("sink").Error("Event sink disabled", zap.Error(err))
+		return nil
+	}
+	db.SetEventSink(s.Submit)
+	sinkInstance = s
+	return s
+}
+
+// startEventSink starts publishing accepted events until ctx is canceled.
+func startEventSink(ctx context.Context) {
+	if sinkInstance != nil {
+		sinkInstance.Start(ctx)
+	}
+}
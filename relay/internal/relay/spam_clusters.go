@@ -0,0 +1,116 @@
+package relay
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/audit"
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/spamcluster"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// spamClustersInstance is the package-level near-duplicate detector (nil when disabled).
+var spamClustersInstance *spamcluster.Detector
+
+// GetSpamClusters returns the near-duplicate detector, or nil when it is disabled.
+func GetSpamClusters() *spamcluster.Detector {
+	return spamClustersInstance
+}
+
+// InitSpamClusters creates the near-duplicate detector and audits the
+// clusters it flags. Called from NewServer.
+func InitSpamClusters(cfg *config.Config) *spamcluster.Detector {
+	if !cfg.SpamClusters.Enabled {
+		spamClustersInstance = nil
+		return nil
+	}
+
+	d := spamcluster.New(cfg.SpamClusters)
+	d.SetFlagHook(func(c spamcluster.Cluster) {
+		metrics.SpamClusters.WithLabelValues("flagged").Inc()
+		logger.New("spamclusters").Warn("Flagged near-duplicate spam cluster",
+			zap.String("cluster", c.ID),
+			zap.Int("pubkeys", c.Pubkeys),
+			zap.Int64("events", c.Events))
+		recordAudit(audit.Entry{
+			Actor:   audit.ActorSystem,
+			Source:  "spamclusters",
+			Action:  "flagspamcluster",
+			Params:  []string{c.ID},
+			Outcome: audit.OutcomeSuccess,
+			Message: fmt.Sprintf("%d pubkeys posted %d near-identical notes", c.Pubkeys, c.Events),
+		})
+	})
+	spamClustersInstance = d
+	return d
+}
+
+// checkSpamCluster adds evt to its near-duplicate cluster and returns the
+// action to take on it, "" when it passes.
+func checkSpamCluster(evt *nostr.Event) string {
+	d := GetSpamClusters()
+	if d == nil {
+		return ""
+	}
+	decision := d.Check(evt)
+	if decision.Action != "" {
+		metrics.SpamClusters.WithLabelValues(decision.Action).Inc()
+	}
+	return decision.Action
+}
+
+// --- Spam Clusters ---
+
+// mgmtListSpamClusters lists near-duplicate clusters.
+// Params: [limit (optional, default 100), status (optional: flagged or all, default all)]
+func (s *Server) mgmtListSpamClusters(params []string) (interface{}, string) {
+	d := GetSpamClusters()
+	if d == nil {
+		return nil, "spam clustering is disabled"
+	}
+	limit := 100
+	if len(params) > 0 && params[0] != "" {
+		n, err := strconv.Atoi(params[0])
+		if err != nil || n < 1 || n > 1000 {
+			return nil, "limit must be between 1 and 1000"
+		}
+		limit = n
+	}
+	flaggedOnly := false
+	if len(params) > 1 && params[1] != "" {
+		switch params[1] {
+		case "flagged":
+			flaggedOnly = true
+		case "all":
+		default:
+			return nil, "status must be flagged or all"
+		}
+	}
+
+	total, flagged, clusters := d.Snapshot(limit, flaggedOnly)
+	return map[string]interface{}{
+		"total":    total,
+		"flagged":  flagged,
+		"clusters": clusters,
+	}, ""
+}
+
+// mgmtDismissSpamCluster lifts the flag of a cluster found to be a false positive.
+// Params: [cluster_id]
+func (s *Server) mgmtDismissSpamCluster(params []string) (interface{}, string) {
+	d := GetSpamClusters()
+	if d == nil {
+		return nil, "spam clustering is disabled"
+	}
+	if len(params) < 1 || params[0] == "" {
+		return nil, "missing cluster_id parameter"
+	}
+	if !d.Dismiss(params[0]) {
+		return nil, "cluster not found: " + params[0]
+	}
+	return true, ""
+}
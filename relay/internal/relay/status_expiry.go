@@ -0,0 +1,224 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/domain"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+func init() {
+	registry.Register(registry.NIP{ID: "38", Name: "User Statuses", Kinds: []int{KindUserStatus}})
+}
+
+// Status expiry: NIP-38 user statuses (kind 30315) with an expiration tag,
+// such as "now playing" music statuses, are withdrawn as soon as they expire.
+// Queries stop returning them right away (storage drops expired events), and
+// live subscribers whose filters matched the status receive a synthetic,
+// relay-signed kind 5 deletion so clients clear it without re-querying.
+//
+// The tracker follows the event dispatcher like a websocket connection, so on
+// a cluster every node sees every status and notifies its own subscribers.
+
+// KindUserStatus is the NIP-38 user status kind.
+const KindUserStatus = 30315
+
+// maxTrackedStatuses bounds the statuses waiting for their expiration.
+const maxTrackedStatuses = 100000
+
+// withdrawnStatusTTL is how long a synthetic deletion stays resolvable to the
+// status it withdraws, long enough for the dispatcher to deliver it.
+const withdrawnStatusTTL = time.Minute
+
+// withdrawnStatus is a status a synthetic deletion was emitted for.
+type withdrawnStatus struct {
+	status *nostr.Event
+	until  time.Time
+}
+
+// StatusExpiry tracks expiring user statuses and announces their withdrawal.
+type StatusExpiry struct {
+	mu        sync.Mutex
+	pending   map[string]*nostr.Event    // status address -> latest status with an expiration
+	withdrawn map[string]withdrawnStatus // synthetic deletion ID -> withdrawn status
+}
+
+// statusExpiryInstance is the package-level status expiry tracker.
+var statusExpiryInstance = newStatusExpiry()
+
+// GetStatusExpiry returns the package-level status expiry tracker.
+func GetStatusExpiry() *StatusExpiry {
+	return statusExpiryInstance
+}
+
+func newStatusExpiry() *StatusExpiry {
+	return &StatusExpiry{
+		pending:   make(map[string]*nostr.Event),
+		withdrawn: make(map[string]withdrawnStatus),
+	}
+}
+
+// statusAddress is the NIP-33 address of a user status.
+func statusAddress(evt *nostr.Event) string {
+	return fmt.Sprintf("%d:%s:%s", evt.Kind, evt.PubKey, evt.Tags.GetD())
+}
+
+// observe updates the tracker with a live event: statuses replace the pending
+// entry of their address, and the author's deletions cancel it.
+func (se *StatusExpiry) observe(evt *nostr.Event) {
+	switch {
+	case evt.Kind == KindUserStatus:
+		addr := statusAddress(evt)
+		se.mu.Lock()
+		defer se.mu.Unlock()
+		if cur := se.pending[addr]; cur != nil && cur.CreatedAt > evt.CreatedAt {
+			return // an older version arriving late
+		}
+		if _, ok := nips.GetExpirationTime(*evt); !ok || nips.IsExpired(*evt) {
+			delete(se.pending, addr)
+			return
+		}
+		if _, tracked := se.pending[addr]; !tracked && len(se.pending) >= maxTrackedStatuses {
+			return
+		}
+		se.pending[addr] = evt
+	case nips.IsDeletionEvent(*evt):
+		se.mu.Lock()
+		defer se.mu.Unlock()
+		for addr, status := range se.pending {
+			if status.PubKey == evt.PubKey && deletionTargetsStatus(evt, status, addr) {
+				delete(se.pending, addr)
+			}
+		}
+	}
+}
+
+// deletionTargetsStatus reports whether a NIP-09 deletion names status by ID or address.
+func deletionTargetsStatus(del, status *nostr.Event, addr string) bool {
+	for _, tag := range del.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		if (tag[0] == "e" && tag[1] == status.ID) || (tag[0] == "a" && tag[1] == addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// expire removes and returns the statuses whose expiration has passed, and
+// forgets synthetic deletions that have been delivered.
+func (se *StatusExpiry) expire(now time.Time) []*nostr.Event {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	var expired []*nostr.Event
+	for addr, status := range se.pending {
+		if exp, _ := nips.GetExpirationTime(*status); !now.Before(exp) {
+			expired = append(expired, status)
+			delete(se.pending, addr)
+		}
+	}
+	for id, w := range se.withdrawn {
+		if now.After(w.until) {
+			delete(se.withdrawn, id)
+		}
+	}
+	return expired
+}
+
+// withdrawnStatus returns the status a synthetic deletion withdraws, or nil
+// when evt is not one. Live dispatch matches subscriptions against it.
+func (se *StatusExpiry) withdrawnStatus(evt *nostr.Event) *nostr.Event {
+	if se == nil || evt.Kind != nostr.KindDeletion {
+		return nil
+	}
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	if w, ok := se.withdrawn[evt.ID]; ok {
+		return w.status
+	}
+	return nil
+}
+
+// withdraw signs a deletion for an expired status and broadcasts it to local subscribers.
+func (se *StatusExpiry) withdraw(ctx context.Context, node domain.NodeInterface, status *nostr.Event) {
+	gs := GetGroupStore()
+	if gs == nil || gs.relaySigner() == nil {
+		return // no relay key to sign the deletion with
+	}
+	dispatcher := node.GetEventDispatcher()
+	if dispatcher == nil {
+		return
+	}
+
+	del := &nostr.Event{
+		Kind:      nostr.KindDeletion,
+		CreatedAt: nostr.Now(),
+		Tags: nostr.Tags{
+			{"e", status.ID},
+			{"a", statusAddress(status)},
+			{"p", status.PubKey},
+			{"k", strconv.Itoa(status.Kind)},
+		},
+		Content: "status expired",
+	}
+	if err := gs.relaySigner().Sign(ctx, del); err != nil {
+		logger.Warn("Failed to sign status withdrawal",
+			zap.String("status_id", status.ID),
+			zap.Error(err))
+		return
+	}
+
+	se.mu.Lock()
+	se.withdrawn[del.ID] = withdrawnStatus{status: status, until: time.Now().Add(withdrawnStatusTTL)}
+	se.mu.Unlock()
+
+	if !dispatcher.Broadcast(del) {
+		logger.Warn("Local broadcast buffer full, dropping status withdrawal",
+			zap.String("status_id", status.ID))
+		return
+	}
+	logger.Debug("Withdrew expired user status",
+		zap.String("status_id", status.ID),
+		zap.String("pubkey", status.PubKey))
+}
+
+// startStatusExpiry follows live events and withdraws expired statuses until ctx is canceled.
+func startStatusExpiry(ctx context.Context, node domain.NodeInterface) {
+	dispatcher := node.GetEventDispatcher()
+	if dispatcher == nil {
+		return
+	}
+	se := GetStatusExpiry()
+	clientID := "status-expiry-" + generateClientID()
+	live := dispatcher.AddClient(clientID)
+
+	go func() {
+		defer dispatcher.RemoveClient(clientID)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt := <-live:
+				if evt == nil {
+					return // dispatcher stopped
+				}
+				se.observe(evt)
+			case now := <-ticker.C:
+				for _, status := range se.expire(now) {
+					se.withdraw(ctx, node, status)
+				}
+			}
+		}
+	}()
+}
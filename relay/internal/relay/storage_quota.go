@@ -0,0 +1,103 @@
+package relay
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Storage quotas: every pubkey may store up to RELAY_POLICY.STORAGE_QUOTA.
+// MAX_BYTES_PER_PUBKEY. Usage is accounted by the storage layer on insert and
+// delete, so deleting events frees quota again.
+
+// storageQuotaExempt reports whether evt is not held against its author's
+// quota: ephemeral events are never stored, deletions (NIP-09) and vanish
+// requests (NIP-62) free space, and the relay and its admins are unlimited.
+func storageQuotaExempt(evt *nostr.Event, cfg *config.Config) bool {
+	if nips.IsEphemeral(evt.Kind) || evt.Kind == 5 || evt.Kind == 62 {
+		return true
+	}
+	pubkey := strings.ToLower(evt.PubKey)
+	if cfg.Relay.PublicKey != "" && strings.ToLower(cfg.Relay.PublicKey) == pubkey {
+		return true
+	}
+	for _, admin := range cfg.Relay.AdminPubkeys {
+		if strings.ToLower(admin) == pubkey {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStorageQuota returns the OK rejection message when storing evt would
+// take its author over the storage quota.
+func (c *WsConnection) checkStorageQuota(ctx context.Context, evt *nostr.Event) string {
+	cfg := c.node.Config()
+	quota := cfg.RelayPolicy.StorageQuota.MaxBytesPerPubkey
+	if quota <= 0 || storageQuotaExempt(evt, cfg) {
+		return ""
+	}
+	usage, err := c.node.DB().GetPubkeyStorage(ctx, evt.PubKey)
+	if err != nil {
+		// Accounting unavailable: do not refuse writes over it
+		logger.Warn("Failed to read pubkey storage usage",
+			zap.String("pubkey", evt.PubKey),
+			zap.Error(err))
+		return ""
+	}
+	if usage.Bytes+storage.EventStorageSize(evt) > quota {
+		return nips.FormatErrorMessage(nips.ErrorCodeBlacklisted, "storage quota exceeded")
+	}
+	return ""
+}
+
+// --- Storage Usage ---
+
+func (s *Server) mgmtListStorageConsumers(params []string) (interface{}, string) {
+	limit := 100
+	if len(params) > 0 && params[0] != "" {
+		n, err := strconv.Atoi(params[0])
+		if err != nil || n < 1 || n > 1000 {
+			return nil, "limit must be between 1 and 1000"
+		}
+		limit = n
+	}
+	consumers, err := s.node.DB().TopStorageConsumers(context.Background(), limit)
+	if err != nil {
+		return nil, "failed to read storage usage"
+	}
+	type consumer struct {
+		storage.PubkeyStorage
+		Npub string `json:"npub"`
+	}
+	entries := make([]consumer, 0, len(consumers))
+	for _, c := range consumers {
+		entries = append(entries, consumer{PubkeyStorage: c, Npub: npub(c.Pubkey)})
+	}
+	return map[string]interface{}{
+		"max_bytes_per_pubkey": s.fullCfg.RelayPolicy.StorageQuota.MaxBytesPerPubkey,
+		"pubkeys":              entries,
+	}, ""
+}
+
+func (s *Server) mgmtGetPubkeyStorage(params []string) (interface{}, string) {
+	if len(params) < 1 {
+		return nil, "missing pubkey parameter"
+	}
+	pubkey, errMsg := parsePubkeyParam(params[0])
+	if errMsg != "" {
+		return nil, errMsg
+	}
+	usage, err := s.node.DB().GetPubkeyStorage(context.Background(), pubkey)
+	if err != nil {
+		return nil, "failed to read storage usage"
+	}
+	return usage, ""
+}
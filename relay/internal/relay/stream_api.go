@@ -0,0 +1,236 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Event stream: GET /api/stream?filter=<json> follows live events over
+// Server-Sent Events, for browsers and serverless consumers without a
+// websocket. The filter is a NIP-01 filter object or an array of them, checked
+// like a REQ from an unauthenticated client. Filters with a limit first replay
+// that many stored events, followed by an "eose" event:
+//
+//	id: <event id>
+//	event: event
+//	data: {"id":...}
+//
+// Streams are registered on the EventDispatcher like a websocket connection
+// and share its per-IP connection limit, ban list and IP blocks.
+
+// Default for WEB.STREAM.KEEPALIVE when left unset.
+const defaultStreamKeepalive = 30 * time.Second
+
+// streamAPI holds the event stream state of a Server.
+type streamAPI struct {
+	enabled   bool
+	keepalive time.Duration
+}
+
+// newStreamAPI builds the event stream settings from cfg.
+func newStreamAPI(cfg *config.Config) *streamAPI {
+	keepalive := cfg.Web.Stream.Keepalive
+	if keepalive <= 0 {
+		keepalive = defaultStreamKeepalive
+	}
+	return &streamAPI{enabled: cfg.Web.Stream.Enabled, keepalive: keepalive}
+}
+
+// handleStreamAPI serves GET /api/stream until the client goes away or ctx,
+// the server's lifetime, ends.
+func (s *Server) handleStreamAPI(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if !s.prepareEventsAPI(w, r, s.streamAPI.enabled) {
+		return
+	}
+	filters, err := s.streamFilters(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeEventsAPIError(w, r, "INVALID_FILTER", err.Error())
+		return
+	}
+
+	// Admission: the websocket ban list, IP blocks, global and per-IP connection limits
+	clientIP := extractRealClientIP(r)
+	if remaining := clientBanRemaining(clientIP); remaining > 0 {
+		errors.HandleHTTPError(w, r, errors.ClientBannedError("excessive messages", remaining.String()))
+		return
+	}
+	if reason := ipBlocks.blockedReason(clientIP); reason != "" {
+		errors.HandleHTTPError(w, r, errors.AuthorizationError("connection", "blocked "+reason))
+		return
+	}
+	throttling := s.cfg.ThrottlingConfig
+	if metrics.GetActiveConnectionsCount() >= int64(throttling.MaxConnections) {
+		errors.HandleHTTPError(w, r, errors.ConnectionLimitError(
+			int(metrics.GetActiveConnectionsCount()), throttling.MaxConnections))
+		return
+	}
+	if !acquireIPConnection(clientIP, throttling.MaxConnectionsPerIP) {
+		errors.HandleHTTPError(w, r, errors.RateLimitError("connections from this IP"))
+		return
+	}
+	defer releaseIPConnection(clientIP)
+
+	dispatcher := s.node.GetEventDispatcher()
+	if dispatcher == nil {
+		errors.HandleHTTPError(w, r, errors.InternalError("event dispatcher not available", nil).
+			WithUserMessage("Live events are temporarily unavailable."))
+		return
+	}
+	// Register before the replay so no event falls between the two
+	clientID := "sse-" + generateClientID()
+	live := dispatcher.AddClient(clientID)
+	defer dispatcher.RemoveClient(clientID)
+
+	var stored []nostr.Event
+	for _, f := range filters {
+		if f.Limit <= 0 {
+			continue
+		}
+		events, _, ok := s.queryEventsAPI(w, r, f)
+		if !ok {
+			return
+		}
+		stored = append(stored, events...)
+	}
+
+	// Streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logger.Debug("Could not clear stream write deadline", zap.Error(err))
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	metrics.IncrementActiveConnections()
+	defer metrics.DecrementActiveConnections()
+
+	sent := make(map[string]bool, len(stored))
+	for i := range stored {
+		if sent[stored[i].ID] {
+			continue
+		}
+		sent[stored[i].ID] = true
+		if writeStreamEvent(w, &stored[i]) != nil {
+			return
+		}
+	}
+	if _, err := fmt.Fprint(w, "event: eose\ndata: {}\n\n"); err != nil {
+		return
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	tenant := GetTenants().ForHost(r.Host).ID()
+	keepalive := time.NewTicker(s.streamAPI.keepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case evt := <-live:
+			if evt == nil {
+				return // dispatcher stopped
+			}
+			if sent[evt.ID] || !s.streamMatches(r.Context(), evt, filters, tenant) {
+				continue
+			}
+			if writeStreamEvent(w, evt) != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// streamFilters parses and validates the filter parameter of /api/stream.
+func (s *Server) streamFilters(param string) ([]nostr.Filter, error) {
+	if param == "" {
+		return nil, fmt.Errorf("missing filter parameter")
+	}
+	var raw interface{}
+	if err := json.Unmarshal([]byte(param), &raw); err != nil {
+		return nil, fmt.Errorf("filter must be a JSON object or array")
+	}
+	raws, isList := raw.([]interface{})
+	if !isList {
+		raws = []interface{}{raw}
+	}
+	if len(raws) == 0 || len(raws) > constants.MaxFilters {
+		return nil, fmt.Errorf("between 1 and %d filters are allowed", constants.MaxFilters)
+	}
+
+	filters := make([]nostr.Filter, 0, len(raws))
+	for _, rf := range raws {
+		f, err := parseFilterFromRaw(rf)
+		if err != nil {
+			return nil, err
+		}
+		if f.Limit > s.eventsAPI.limits.maxLimit {
+			return nil, fmt.Errorf("limit %d exceeds the maximum of %d", f.Limit, s.eventsAPI.limits.maxLimit)
+		}
+		if err := s.checkAnonymousFilter(f); err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if n := filterComplexity(filters); n > maxFilterComplexity {
+		return nil, fmt.Errorf("filters too complex (%d values, max %d)", n, maxFilterComplexity)
+	}
+	return filters, nil
+}
+
+// streamMatches reports whether a live event goes to a stream with filters on
+// tenant's relay.
+func (s *Server) streamMatches(ctx context.Context, evt *nostr.Event, filters []nostr.Filter, tenant string) bool {
+	// NIP-38: a withdrawn status's deletion goes wherever the status went
+	if status := GetStatusExpiry().withdrawnStatus(evt); status != nil {
+		evt = status
+	}
+	matched := false
+	for _, f := range filters {
+		if storage.MatchesFilter(f, evt) {
+			matched = true
+			break
+		}
+	}
+	if !matched || !visibleAnonymously(s.node.DB(), evt) {
+		return false
+	}
+	if GetTenants() != nil {
+		return s.node.DB().EventInTenant(ctx, evt.ID, tenant)
+	}
+	return true
+}
+
+// writeStreamEvent writes evt as an SSE "event" message.
+func writeStreamEvent(w http.ResponseWriter, evt *nostr.Event) error {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: event\ndata: %s\n\n", evt.ID, data)
+	return err
+}
@@ -2,11 +2,15 @@ package relay
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/Shugur-Network/relay/internal/constants"
 	"github.com/Shugur-Network/relay/internal/logger"
 	"github.com/Shugur-Network/relay/internal/metrics"
 	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
@@ -39,18 +43,30 @@ func (c *WsConnection) handleRequest(ctx context.Context, arr []interface{}) {
 		return
 	}
 
+	// AUTH_REQUIRED and private relays only serve authenticated readers
+	if reason := c.checkReadAccess(); reason != "" {
+		c.sendClosed(subID, reason)
+		return
+	}
+
 	// Remove existing subscription if present
 	if c.hasSubscription(subID) {
 		logger.Debug("Replacing existing subscription",
 			zap.String("sub_id", subID),
 			zap.String("client", c.RemoteAddr()))
 		c.removeSubscription(subID)
+		c.countAnomalyChurn()
 	}
 
-	// Parse the filter with support for #tag syntax
-	var f nostr.Filter
-	if len(arr) >= 3 {
-		filter, err := parseFilterFromRaw(arr[2])
+	// Parse the filters with support for #tag syntax
+	if len(arr)-2 > constants.MaxFilters {
+		c.sendClosed(subID, nips.FormatErrorMessage(nips.ErrorCodeInvalidFilter,
+			fmt.Sprintf("too many filters (max %d)", constants.MaxFilters)))
+		return
+	}
+	filters := make([]nostr.Filter, 0, len(arr)-2)
+	for _, raw := range arr[2:] {
+		f, err := parseFilterFromRaw(raw)
 		if err != nil {
 			logger.Warn("Failed to parse filter",
 				zap.String("sub_id", subID),
@@ -59,15 +75,72 @@ func (c *WsConnection) handleRequest(ctx context.Context, arr []interface{}) {
 			c.sendNotice("Invalid filter: " + err.Error())
 			return
 		}
-		f = filter
-	} else {
-		c.sendNotice("REQ command missing filter")
+
+		if reason := c.checkRequestFilter(subID, f); reason != "" {
+			c.sendClosed(subID, reason)
+			return
+		}
+		if f.Limit <= 0 {
+			f.Limit = c.queryLimits.maxLimit
+		}
+
+		filters = append(filters, f)
+	}
+	c.countAnomalyRequest(filters)
+
+	// NIP-43: A REQ asking for invites (kind 28935) alone is answered with a
+	// freshly generated, relay-signed claim. Mixed with other filters, it is
+	// queried like any other; invites are ephemeral, so nothing is stored.
+	if isInviteRequest(filters) {
+		c.handleInviteRequest(subID)
 		return
 	}
 
-	// Apply cap to limit if needed
-	if f.Limit <= 0 || f.Limit > 500 {
-		f.Limit = 500
+	// Collapse duplicate and overlapping filters before they reach storage
+	received := len(filters)
+	filters = mergeFilters(filters, c.queryLimits.maxLimit)
+	if len(filters) < received {
+		logger.Debug("Merged subscription filters",
+			zap.String("sub_id", subID),
+			zap.Int("received", received),
+			zap.Int("merged", len(filters)),
+			zap.String("client", c.RemoteAddr()))
+	}
+	if n := filterComplexity(filters); n > maxFilterComplexity {
+		c.sendClosed(subID, nips.FormatErrorMessage(nips.ErrorCodeInvalidFilter,
+			fmt.Sprintf("filters too complex (%d values, max %d)", n, maxFilterComplexity)))
+		return
+	}
+
+	if reason := c.acquireQuery("REQ"); reason != "" {
+		c.sendClosed(subID, reason)
+		return
+	}
+
+	// Track sent events until EOSE so live dispatch cannot duplicate the replay.
+	// The replay set must exist before the subscription becomes visible to it.
+	replay := c.startReplay(subID)
+
+	// Store subscription
+	c.addSubscription(subID, filters)
+
+	// Update metrics
+	metrics.ActiveSubscriptions.Inc()
+
+	// Query DB and send events in a goroutine
+	go c.processSubscription(ctx, subID, filters, replay)
+}
+
+// checkRequestFilter validates one REQ filter and returns the CLOSED reason
+// when it must be refused.
+func (c *WsConnection) checkRequestFilter(subID string, f nostr.Filter) string {
+	if f.Limit > c.queryLimits.maxLimit {
+		return nips.FormatErrorMessage(nips.ErrorCodeRateLimited,
+			fmt.Sprintf("limit %d exceeds the maximum of %d", f.Limit, c.queryLimits.maxLimit))
+	}
+
+	if reason := queryWindowReason(f, time.Now()); reason != "" {
+		return reason
 	}
 
 	// Validate filter with the validator
@@ -76,8 +149,7 @@ func (c *WsConnection) handleRequest(ctx context.Context, arr []interface{}) {
 			zap.String("sub_id", subID),
 			zap.Error(err),
 			zap.String("client", c.RemoteAddr()))
-		c.sendClosed(subID, nips.FormatErrorMessage(nips.ErrorCodeInvalidFilter, err.Error()))
-		return
+		return nips.FormatErrorMessage(nips.ErrorCodeInvalidFilter, err.Error())
 	}
 
 	// Check special validation for specific filter types
@@ -85,8 +157,7 @@ func (c *WsConnection) handleRequest(ctx context.Context, arr []interface{}) {
 		switch {
 		case containsKind(f.Kinds, nips.KindRelayList):
 			if err := nips.ValidateRelayListFilter(f); err != nil {
-				c.sendClosed(subID, nips.FormatErrorMessage(nips.ErrorCodeInvalidFilter, err.Error()))
-				return
+				return nips.FormatErrorMessage(nips.ErrorCodeInvalidFilter, err.Error())
 			}
 		}
 	}
@@ -94,8 +165,14 @@ func (c *WsConnection) handleRequest(ctx context.Context, arr []interface{}) {
 	// Validate search if present
 	if f.Search != "" {
 		if err := nips.ValidateSearchFilter(f, nips.DefaultSearchOptions()); err != nil {
-			c.sendClosed(subID, nips.FormatErrorMessage(nips.ErrorCodeInvalidFilter, err.Error()))
-			return
+			return nips.FormatErrorMessage(nips.ErrorCodeInvalidFilter, err.Error())
+		}
+	}
+
+	// NIP-17 DM inbox: authenticated gift wrap and DM relay list queries only
+	if inbox := GetDMInbox(); inbox != nil {
+		if reason := inbox.CheckFilter(f, c.getAuthenticatedPubkey()); reason != "" {
+			return reason
 		}
 	}
 
@@ -109,69 +186,50 @@ func (c *WsConnection) handleRequest(ctx context.Context, arr []interface{}) {
 			}
 		}
 		if requiresAuth && !c.hasAuthentication() {
-			c.sendClosed(subID, "auth-required: this query requires authentication")
-			return
+			return "auth-required: this query requires authentication"
 		}
 	}
 
-	// Store subscription
-	c.addSubscription(subID, []nostr.Filter{f})
-
-	// Update metrics
-	metrics.ActiveSubscriptions.Inc()
-
-	// Query DB and send events in a goroutine
-	go c.processSubscription(ctx, subID, f)
+	// NIP-29: Private and hidden groups are readable by authenticated members only
+	if gs := GetGroupStore(); gs != nil {
+		if reason := gs.CheckFilterAccess(f, c.getAuthenticatedPubkey()); reason != "" {
+			return reason
+		}
+	}
+	return ""
 }
 
-// processSubscription handles the database query and sending events to the client
-func (c *WsConnection) processSubscription(ctx context.Context, subID string, f nostr.Filter) {
+// processSubscription handles the database queries and sending events to the client
+func (c *WsConnection) processSubscription(ctx context.Context, subID string, filters []nostr.Filter, replay *replaySet) {
+	defer c.releaseQuery()
+	defer c.endReplay(subID, replay)
+	start := time.Now()
+
 	// Create a context with timeout for the query
 	_, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	// Query events from the database
-	start := time.Now()
-	events, err := c.QueryEvents(ctx, f)
-	duration := time.Since(start)
-
-	// Log query performance
-	logger.Debug("Query execution completed",
-		zap.String("sub_id", subID),
-		zap.Duration("duration", duration),
-		zap.Int("events_count", len(events)),
-		zap.String("client", c.RemoteAddr()))
-
-	if err != nil {
-		logger.Error("Failed to query events",
-			zap.String("sub_id", subID),
-			zap.Error(err),
-			zap.String("client", c.RemoteAddr()))
-		c.sendNotice(nips.ErrDatabaseError)
-		return
-	}
-
-	// Check if client is still connected before proceeding
-	if c.isClosed.Load() {
-		return
-	}
+	var events []nostr.Event
+	for _, f := range filters {
+		filterEvents, err := c.queryFilter(ctx, subID, f)
+		if err != nil {
+			logger.Error("Failed to query events",
+				zap.String("sub_id", subID),
+				zap.Error(err),
+				zap.String("client", c.RemoteAddr()))
+			c.sendNotice(nips.ErrDatabaseError)
+			return
+		}
 
-	// Apply special validation for specific event kinds
-	if len(f.Kinds) == 1 {
-		switch f.Kinds[0] {
-		case nips.KindRelayList:
-			// Filter out invalid relay list events
-			validEvents := make([]nostr.Event, 0, len(events))
-			for _, evt := range events {
-				if err := nips.ValidateKind10002(evt); err == nil {
-					validEvents = append(validEvents, evt)
-				}
-			}
-			events = validEvents
+		// Check if client is still connected before proceeding
+		if c.isClosed.Load() {
+			return
 		}
+		events = append(events, filterEvents...)
 	}
 
 	// Send events to the client
+	inbox := GetDMInbox()
 	sentCount := 0
 	for _, evt := range events {
 		// Check again if client is still connected
@@ -191,9 +249,49 @@ func (c *WsConnection) processSubscription(ctx context.Context, subID string, f
 			}
 		}
 
+		// Skip banned events and events hidden by reports
+		if c.isWithheld(&evt) {
+			continue
+		}
+
+		// NIP-29: Skip events from private/hidden groups the client cannot read
+		if !c.canReadGroupEvent(&evt) {
+			continue
+		}
+
+		// NIP-53: Skip live streams that ended in all but status
+		if hiddenStaleLive(&evt) {
+			continue
+		}
+
+		// Skip events live dispatch already delivered during the replay
+		if !replay.shouldSendReplayed(evt.ID) {
+			continue
+		}
+
+		// NIP-17 DM inbox: gift wraps only go to their recipient
+		if inbox != nil && !inbox.CanDeliver(&evt, c.getAuthenticatedPubkey()) {
+			continue
+		}
+
+		// Close the subscription once it has used up its result budget
+		if sentCount >= c.queryLimits.maxEventsPerReq {
+			logger.Debug("Subscription exceeded its result budget",
+				zap.String("sub_id", subID),
+				zap.Int("max_events", c.queryLimits.maxEventsPerReq),
+				zap.String("client", c.RemoteAddr()))
+			c.RemoveSubscription(subID)
+			c.sendClosed(subID, nips.FormatErrorMessage(nips.ErrorCodeRateLimited,
+				fmt.Sprintf("too many results (max %d events per request)", c.queryLimits.maxEventsPerReq)))
+			return
+		}
+
 		// Send the event
 		c.SendEvent(subID, &evt)
 		sentCount++
+		if inbox != nil {
+			inbox.MarkDelivered(&evt)
+		}
 	}
 
 	logger.Debug("Subscription events sent",
@@ -204,7 +302,135 @@ func (c *WsConnection) processSubscription(ctx context.Context, subID string, f
 	// Send EOSE (End of Stored Events)
 	if !c.isClosed.Load() {
 		c.sendEOSE(subID)
+		metrics.RecordEOSE(filterShape(filters), time.Since(start), sentCount)
+	}
+}
+
+// filterShape names the fields a REQ's filters query by, such as
+// "authors+kinds", for labeling query metrics. Filters of different shapes
+// make a "mixed" request.
+func filterShape(filters []nostr.Filter) string {
+	shape := ""
+	for i, f := range filters {
+		var parts []string
+		if len(f.IDs) > 0 {
+			parts = append(parts, "ids")
+		}
+		if len(f.Authors) > 0 {
+			parts = append(parts, "authors")
+		}
+		if len(f.Kinds) > 0 {
+			parts = append(parts, "kinds")
+		}
+		if len(f.Tags) > 0 {
+			parts = append(parts, "tags")
+		}
+		if f.Search != "" {
+			parts = append(parts, "search")
+		}
+		s := strings.Join(parts, "+")
+		if s == "" {
+			s = "scan"
+		}
+		if i > 0 && s != shape {
+			return "mixed"
+		}
+		shape = s
+	}
+	return shape
+}
+
+// queryFilter runs one subscription filter against storage and the retained
+// Wallet Connect messages.
+func (c *WsConnection) queryFilter(ctx context.Context, subID string, f nostr.Filter) ([]nostr.Event, error) {
+	// Query events from the database, within the history window
+	start := time.Now()
+	var events []nostr.Event
+	var err error
+	if qf, ok := clampQueryWindow(f, c.queryWindow(), start); ok {
+		events, err = c.QueryEvents(ctx, qf)
+	}
+	duration := time.Since(start)
+
+	// Log query performance
+	logger.Debug("Query execution completed",
+		zap.String("sub_id", subID),
+		zap.Duration("duration", duration),
+		zap.Int("events_count", len(events)),
+		zap.String("client", c.RemoteAddr()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// NIP-47: Replay retained Wallet Connect messages addressed to this subscriber
+	if nwc := GetNWCStore(); nwc != nil && hasWalletConnectKind(f.Kinds) {
+		events = append(events, nwc.Query(f, c.getAuthenticatedPubkey())...)
+	}
+
+	// Apply special validation for specific event kinds
+	if len(f.Kinds) == 1 {
+		switch f.Kinds[0] {
+		case nips.KindRelayList:
+			// Filter out invalid relay list events
+			validEvents := make([]nostr.Event, 0, len(events))
+			for _, evt := range events {
+				if err := nips.ValidateKind10002(evt); err == nil {
+					validEvents = append(validEvents, evt)
+				}
+			}
+			events = validEvents
+		}
+	}
+	return events, nil
+}
+
+// handleInviteRequest answers a REQ for kind 28935 with an ephemeral invite event.
+// On members-only relays only authenticated members may hand out invites.
+func (c *WsConnection) handleInviteRequest(subID string) {
+	cfg := c.node.Config()
+	ms := GetMembershipStore()
+
+	if membersOnly(cfg) {
+		authedPK := c.getAuthenticatedPubkey()
+		if authedPK == "" {
+			c.sendClosed(subID, "auth-required: invite codes are only issued to members")
+			return
+		}
+		if !ms.CanWrite(&nostr.Event{PubKey: authedPK}, cfg) {
+			c.sendClosed(subID, "restricted: invite codes are only issued to members")
+			return
+		}
 	}
+
+	ttl := cfg.Relay.InviteTTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if evt := ms.GenerateInviteEvent(GetGroupStore(), ttl); evt != nil {
+		c.sendMessage("EVENT", subID, evt)
+	}
+	c.sendEOSE(subID)
+}
+
+// isInviteRequest reports whether every filter of a REQ asks for kind 28935 only.
+func isInviteRequest(filters []nostr.Filter) bool {
+	for _, f := range filters {
+		if len(f.Kinds) != 1 || f.Kinds[0] != 28935 {
+			return false
+		}
+	}
+	return len(filters) > 0
+}
+
+// hasWalletConnectKind reports whether a filter asks for NIP-47 messages
+func hasWalletConnectKind(kinds []int) bool {
+	for _, k := range kinds {
+		if nips.IsWalletConnectMessage(k) {
+			return true
+		}
+	}
+	return false
 }
 
 // isAuthorizedForDM checks if a client should receive a DM
@@ -246,6 +472,41 @@ func isAuthorizedForDM(evt *nostr.Event, filters []nostr.Filter) bool {
 	return false
 }
 
+// canReadGroupEvent checks NIP-29 read access for the connection's authenticated pubkey
+func (c *WsConnection) canReadGroupEvent(evt *nostr.Event) bool {
+	gs := GetGroupStore()
+	if gs == nil {
+		return true
+	}
+	return gs.CanReadEvent(evt, c.getAuthenticatedPubkey())
+}
+
+// countReadable counts the events matching f that the client may read. Like
+// REQ it leaves out private and hidden group events the client cannot see;
+// withheld reports whether any were subtracted.
+func (c *WsConnection) countReadable(ctx context.Context, f nostr.Filter) (count int64, withheld bool, err error) {
+	db := c.node.DB()
+	count, err = db.GetEventCount(ctx, f)
+	if err != nil {
+		return 0, false, err
+	}
+	gs := GetGroupStore()
+	if gs == nil {
+		return count, false, nil
+	}
+	for _, hf := range gs.HiddenCountFilters(f, c.getAuthenticatedPubkey()) {
+		n, err := db.GetEventCount(ctx, hf)
+		if err != nil {
+			return 0, false, err
+		}
+		if n > 0 {
+			count -= n
+			withheld = true
+		}
+	}
+	return max(count, 0), withheld, nil
+}
+
 // eventHasPTag checks if an event has a "p" tag with the given pubkey
 func eventHasPTag(evt *nostr.Event, pubkey string) bool {
 	for _, tag := range evt.Tags {
@@ -295,6 +556,7 @@ func (c *WsConnection) handleClose(arr []interface{}) {
 	// Remove subscription and send confirmation
 	c.removeSubscription(subID)
 	c.sendClosed(subID, "subscription closed")
+	c.countAnomalyChurn()
 
 	// Update metrics
 	metrics.ActiveSubscriptions.Dec()
@@ -337,11 +599,43 @@ func (c *WsConnection) handleCountRequest(ctx context.Context, arr []interface{}
 		c.sendNotice("COUNT command missing filter")
 		return
 	}
+	if reason := queryWindowReason(countCmd.Filter, time.Now()); reason != "" {
+		c.sendClosed(countCmd.SubID, reason)
+		return
+	}
+
+	if reason := c.checkReadAccess(); reason != "" {
+		c.sendClosed(countCmd.SubID, reason)
+		return
+	}
+
+	// NIP-17 DM inbox: counts follow the same rules as queries
+	if inbox := GetDMInbox(); inbox != nil {
+		if reason := inbox.CheckFilter(countCmd.Filter, c.getAuthenticatedPubkey()); reason != "" {
+			c.sendClosed(countCmd.SubID, reason)
+			return
+		}
+	}
+
+	// NIP-29: Counting events of private/hidden groups requires membership
+	if gs := GetGroupStore(); gs != nil {
+		if reason := gs.CheckFilterAccess(countCmd.Filter, c.getAuthenticatedPubkey()); reason != "" {
+			c.sendClosed(countCmd.SubID, reason)
+			return
+		}
+	}
+
+	if reason := c.acquireQuery("COUNT"); reason != "" {
+		c.sendClosed(countCmd.SubID, reason)
+		return
+	}
 
 	// Process count in a goroutine
 	go func() {
+		defer c.releaseQuery()
+
 		// Create a context with timeout for the count operation
-		countCtx, cancel := context.WithTimeout(ctx, nips.CountTimeout)
+		countCtx, cancel := context.WithTimeout(storage.WithReplicaRead(ctx), nips.CountTimeout)
 		defer cancel()
 
 		// Validate the filter using NIP-45
@@ -355,9 +649,15 @@ func (c *WsConnection) handleCountRequest(ctx context.Context, arr []interface{}
 			return
 		}
 
-		// Get count from database
+		// Get count from database, within the history window
 		start := time.Now()
-		count, err := c.node.DB().GetEventCount(countCtx, countCmd.Filter)
+		var count int64
+		var withheld bool
+		filter, inWindow := clampQueryWindow(countCmd.Filter, c.queryWindow(), start)
+		if inWindow {
+			countCmd.Filter = filter
+			count, withheld, err = c.countReadable(c.tenantScope(countCtx), countCmd.Filter)
+		}
 		duration := time.Since(start)
 
 		// Check if client is still connected
@@ -385,8 +685,9 @@ func (c *WsConnection) handleCountRequest(ctx context.Context, arr []interface{}
 		// Build the count response (NIP-45 format)
 		response := &nips.CountResponse{Count: count}
 
-		// NIP-45 HyperLogLog: compute HLL if filter is eligible
-		if nips.IsHLLEligible(countCmd.Filter) {
+		// NIP-45 HyperLogLog: compute HLL if filter is eligible. The pubkeys
+		// would include those of withheld group events, so it is skipped then.
+		if inWindow && nips.IsHLLEligible(countCmd.Filter) && !withheld {
 			offset, offsetErr := nips.ComputeHLLOffset(countCmd.Filter)
 			if offsetErr == nil {
 				pubkeys, pkErr := c.node.DB().GetEventPubkeys(countCtx, countCmd.Filter)
@@ -423,12 +724,36 @@ func (c *WsConnection) addSubscription(subID string, filters []nostr.Filter) {
 	c.subMu.Lock()
 	defer c.subMu.Unlock()
 	c.subscriptions[subID] = filters
+	c.routeThreads()
+	if c.coalesce != nil {
+		c.coalesce.forget(subID)
+	}
 }
 
 func (c *WsConnection) removeSubscription(subID string) {
 	c.subMu.Lock()
 	defer c.subMu.Unlock()
 	delete(c.subscriptions, subID)
+	delete(c.replays, subID)
+	c.routeThreads()
+	if c.coalesce != nil {
+		c.coalesce.forget(subID)
+	}
+}
+
+// routeThreads hands the filters of all subscriptions to the dispatcher, so
+// thread replies reach this connection only when a subscription can match
+// them. Callers hold c.subMu.
+func (c *WsConnection) routeThreads() {
+	eventDispatcher := c.node.GetEventDispatcher()
+	if eventDispatcher == nil || c.clientID == "" {
+		return
+	}
+	var filters []nostr.Filter
+	for _, subFilters := range c.subscriptions {
+		filters = append(filters, subFilters...)
+	}
+	eventDispatcher.SetClientFilters(c.clientID, filters)
 }
 
 func (c *WsConnection) getSubscriptionFilters(subID string) []nostr.Filter {
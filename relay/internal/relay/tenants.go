@@ -0,0 +1,239 @@
+package relay
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/domain"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"go.uber.org/zap"
+)
+
+// Multi-tenant mode: one process serves several virtual relays, chosen by the
+// Host header of the WebSocket or NIP-11 request.
+//
+// Each tenant has its own NIP-11 document and publish policies. Events are
+// stored once, with the tenants they were published through recorded in
+// storage; a connection only reads and receives the events of its tenant.
+// Hosts that match no tenant are served by the main relay, which sees the
+// events no tenant owns.
+
+// tenantPruneInterval is how often tenant rows of deleted events are dropped.
+const tenantPruneInterval = time.Hour
+
+// Tenant is a virtual relay.
+type Tenant struct {
+	Name  string
+	Hosts []string
+
+	cfg          config.TenantConfig
+	allowedKinds map[int]bool
+	blacklist    map[string]bool
+	whitelist    map[string]bool
+}
+
+// TenantRegistry routes hosts to tenants.
+type TenantRegistry struct {
+	tenants []*Tenant
+	byHost  map[string]*Tenant
+}
+
+// tenantsInstance is the package-level tenant registry (nil when no tenants are configured).
+var tenantsInstance *TenantRegistry
+
+// GetTenants returns the tenant registry, or nil outside multi-tenant mode.
+func GetTenants() *TenantRegistry {
+	return tenantsInstance
+}
+
+// InitTenants builds the tenant registry. Called from NewServer.
+func InitTenants(cfg *config.Config) *TenantRegistry {
+	if len(cfg.Tenants) == 0 {
+		tenantsInstance = nil
+		return nil
+	}
+
+	tr := &TenantRegistry{byHost: make(map[string]*Tenant)}
+	for _, tc := range cfg.Tenants {
+		t := &Tenant{
+			Name:         tc.Name,
+			cfg:          tc,
+			allowedKinds: make(map[int]bool, len(tc.AllowedKinds)),
+			blacklist:    make(map[string]bool, len(tc.Blacklist)),
+			whitelist:    make(map[string]bool, len(tc.Whitelist)),
+		}
+		for _, k := range tc.AllowedKinds {
+			t.allowedKinds[k] = true
+		}
+		for _, pk := range tc.Blacklist {
+			t.blacklist[strings.ToLower(pk)] = true
+		}
+		for _, pk := range tc.Whitelist {
+			t.whitelist[strings.ToLower(pk)] = true
+		}
+		for _, host := range tc.Hosts {
+			host = strings.ToLower(host)
+			t.Hosts = append(t.Hosts, host)
+			tr.byHost[host] = t
+		}
+		tr.tenants = append(tr.tenants, t)
+	}
+
+	tenantsInstance = tr
+	logger.New("tenants").Info("Multi-tenant mode enabled", zap.Int("tenants", len(tr.tenants)))
+	return tr
+}
+
+// ForHost returns the tenant serving a Host header, or nil for the main relay.
+// A tenant host without a port matches the host on any port.
+func (tr *TenantRegistry) ForHost(host string) *Tenant {
+	if tr == nil {
+		return nil
+	}
+	host = strings.ToLower(host)
+	if t := tr.byHost[host]; t != nil {
+		return t
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return tr.byHost[h]
+	}
+	return nil
+}
+
+// ID returns the storage scope of a tenant; the main relay (nil) is "".
+func (t *Tenant) ID() string {
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
+
+// CheckEvent applies the tenant's publish policies. Returns (allowed, reason).
+func (t *Tenant) CheckEvent(evt *nostr.Event) (bool, string) {
+	pubkey := strings.ToLower(evt.PubKey)
+	if t.blacklist[pubkey] {
+		return false, "blocked: pubkey is blacklisted on this relay"
+	}
+	if len(t.whitelist) > 0 && !t.whitelist[pubkey] {
+		return false, "restricted: this relay only accepts events from whitelisted pubkeys"
+	}
+	if len(t.allowedKinds) > 0 && !t.allowedKinds[evt.Kind] {
+		return false, "blocked: event kind is not accepted by this relay"
+	}
+	return true, ""
+}
+
+// Metadata returns the main relay's NIP-11 document with the tenant's overrides.
+func (t *Tenant) Metadata(doc nip11.RelayInformationDocument) nip11.RelayInformationDocument {
+	if t.cfg.RelayName != "" {
+		doc.Name = t.cfg.RelayName
+	}
+	if t.cfg.Description != "" {
+		doc.Description = t.cfg.Description
+	}
+	if t.cfg.Contact != "" {
+		doc.Contact = t.cfg.Contact
+	}
+	if t.cfg.Icon != "" {
+		doc.Icon = t.cfg.Icon
+	}
+	if t.cfg.Banner != "" {
+		doc.Banner = t.cfg.Banner
+	}
+	if doc.Limitation != nil && (len(t.allowedKinds) > 0 || len(t.whitelist) > 0) {
+		limitation := *doc.Limitation
+		limitation.RestrictedWrites = true
+		doc.Limitation = &limitation
+	}
+	return doc
+}
+
+// relayURL is the URL NIP-42 AUTH events for the tenant must name.
+func (t *Tenant) relayURL() string {
+	return "wss://" + t.Hosts[0]
+}
+
+// tenantScope scopes storage reads to the connection's tenant in multi-tenant mode.
+func (c *WsConnection) tenantScope(ctx context.Context) context.Context {
+	if GetTenants() == nil {
+		return ctx
+	}
+	return storage.WithTenant(ctx, c.tenant.ID())
+}
+
+// inTenant reports whether a live event belongs to the connection's tenant.
+func (c *WsConnection) inTenant(evt *nostr.Event) bool {
+	if GetTenants() == nil {
+		return true
+	}
+	return c.node.DB().EventInTenant(c.eventCtx, evt.ID, c.tenant.ID())
+}
+
+// relayMetadata returns the NIP-11 document for the relay serving host.
+func (s *Server) relayMetadata(host string) nip11.RelayInformationDocument {
+	metadata := constants.DefaultRelayMetadata(s.fullCfg)
+	if t := GetTenants().ForHost(host); t != nil {
+		return t.Metadata(metadata)
+	}
+	return metadata
+}
+
+// relayDocument returns the NIP-11 document for host with the event kinds it
+// accepts. A tenant's kind list narrows the relay-wide policy.
+func (s *Server) relayDocument(host string) nips.CustomRelayInformationDocument {
+	doc := nips.CustomRelayInformationDocument{RelayInformationDocument: s.relayMetadata(host)}
+	doc.Limitation = nips.NewRelayLimitation(doc.RelayInformationDocument, s.fullCfg.Relay.ThrottlingConfig.QueryWindow)
+	if gs := GetGroupStore(); gs != nil {
+		doc.Self = gs.GetRelayPubkey()
+		// A pubkey that was the relay's own key before a rotation follows it
+		if gs.isRetiredKey(doc.PubKey) {
+			doc.PubKey = doc.Self
+		}
+	}
+	pv, ok := s.node.GetValidator().(*PluginValidator)
+	if !ok {
+		return doc
+	}
+	if t := GetTenants().ForHost(host); t != nil && len(t.allowedKinds) > 0 {
+		kinds := make([]int, 0, len(t.allowedKinds))
+		for k := range t.allowedKinds {
+			if pv.IsKindAllowed(k) {
+				kinds = append(kinds, k)
+			}
+		}
+		doc.AllowedKinds = kindRuns(kinds)
+		return doc
+	}
+	doc.AllowedKinds = pv.AllowedKindRanges()
+	return doc
+}
+
+// pruneEventTenants periodically drops tenant rows of events no longer stored.
+func pruneEventTenants(ctx context.Context, node domain.NodeInterface) {
+	if GetTenants() == nil {
+		return
+	}
+	ticker := time.NewTicker(tenantPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := node.DB().PruneEventTenants(ctx)
+			if err != nil {
+				logger.New("tenants").Warn("Failed to prune event tenants", zap.Error(err))
+			} else if n > 0 {
+				logger.New("tenants").Debug("Pruned event tenants", zap.Int("count", n))
+			}
+		}
+	}
+}
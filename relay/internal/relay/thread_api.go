@@ -0,0 +1,243 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Thread API: the reply tree of a conversation in one call, built from the
+// thread index of kind 1 replies (NIP-10) and kind 1111 comments (NIP-22).
+//
+//	GET /api/thread/<id>?scope=thread|replies&depth=8&limit=200&since=<ts>
+//
+// scope=thread (the default) climbs to the root of the conversation the
+// event belongs to; scope=replies returns the replies below the event only.
+// The tree is filled breadth first until limit replies or depth levels are
+// reached: nodes with replies left out carry more_replies, which can be
+// expanded by requesting that node with scope=replies. Direct replies of the
+// top node are paged oldest first; "next" continues with the following page.
+// Nodes whose event is not stored or not visible keep their id, so their
+// replies stay in place.
+
+const (
+	threadDefaultDepth = 8
+	threadMaxDepth     = 32
+	threadDefaultLimit = 200
+	threadMaxLimit     = 1000
+
+	// threadFetchBatch bounds the ids of one event lookup.
+	threadFetchBatch = 500
+)
+
+// threadNode is one event of a reply tree.
+type threadNode struct {
+	ID          string        `json:"id"`
+	Event       *nostr.Event  `json:"event,omitempty"`
+	Replies     []*threadNode `json:"replies,omitempty"`
+	MoreReplies bool          `json:"more_replies,omitempty"`
+}
+
+// threadAPIResponse is the body of /api/thread/<id>.
+type threadAPIResponse struct {
+	Root      *threadNode `json:"root"`
+	Count     int         `json:"count"` // replies in the tree
+	Truncated bool        `json:"truncated"`
+	Next      string      `json:"next,omitempty"`
+}
+
+// threadQuery holds the parameters of a thread request.
+type threadQuery struct {
+	scope string
+	depth int
+	limit int
+	since int64
+}
+
+// handleThreadAPI serves GET /api/thread/<id>.
+func (s *Server) handleThreadAPI(w http.ResponseWriter, r *http.Request) {
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled) {
+		return
+	}
+	id := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/api/thread/"))
+	if !nostr.IsValid32ByteHex(id) {
+		writeEventsAPIError(w, r, "INVALID_EVENT_ID", "event id must be 64 hex characters")
+		return
+	}
+	q, err := parseThreadQuery(r.URL.Query())
+	if err != nil {
+		writeEventsAPIError(w, r, "INVALID_THREAD_QUERY", err.Error())
+		return
+	}
+
+	release, ok := s.acquireEventsAPISlot(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(eventsAPIContext(r), eventsAPIQueryTimeout)
+	defer cancel()
+
+	db := s.node.DB()
+	events, err := db.GetEvents(ctx, nostr.Filter{IDs: []string{id}, Limit: 1})
+	if err != nil {
+		logger.Warn("Thread API query failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("thread query", err))
+		return
+	}
+	if len(events) == 0 || !visibleAnonymously(db, &events[0]) {
+		errors.HandleHTTPError(w, r, errors.NotFoundError("event"))
+		return
+	}
+
+	rootID := id
+	if q.scope == "thread" {
+		if root, _ := nips.ThreadReference(&events[0]); root != "" {
+			rootID = root
+		}
+	}
+
+	response, err := s.buildThread(ctx, rootID, q)
+	if err != nil {
+		logger.Warn("Thread API query failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("thread query", err))
+		return
+	}
+	if response.Next != "" {
+		query := r.URL.Query()
+		query.Set("since", response.Next)
+		response.Next = r.URL.Path + "?" + query.Encode()
+	}
+	writeEventsAPIJSON(w, response)
+}
+
+// parseThreadQuery validates the parameters of a thread request.
+func parseThreadQuery(query url.Values) (threadQuery, error) {
+	q := threadQuery{scope: "thread", depth: threadDefaultDepth, limit: threadDefaultLimit}
+	for param := range query {
+		switch param {
+		case "scope":
+			q.scope = query.Get(param)
+			if q.scope != "thread" && q.scope != "replies" {
+				return q, fmt.Errorf("scope must be thread or replies")
+			}
+		case "depth":
+			n, err := strconv.Atoi(query.Get(param))
+			if err != nil || n < 1 || n > threadMaxDepth {
+				return q, fmt.Errorf("depth must be between 1 and %d", threadMaxDepth)
+			}
+			q.depth = n
+		case "limit":
+			n, err := strconv.Atoi(query.Get(param))
+			if err != nil || n < 1 || n > threadMaxLimit {
+				return q, fmt.Errorf("limit must be between 1 and %d", threadMaxLimit)
+			}
+			q.limit = n
+		case "since":
+			ts, err := strconv.ParseInt(query.Get(param), 10, 64)
+			if err != nil || ts < 0 {
+				return q, fmt.Errorf("since must be a unix timestamp")
+			}
+			q.since = ts
+		default:
+			return q, fmt.Errorf("unknown parameter %q", param)
+		}
+	}
+	return q, nil
+}
+
+// buildThread walks the replies below rootID breadth first and attaches the
+// visible events. Next holds the since value of the following page of direct
+// replies, if any.
+func (s *Server) buildThread(ctx context.Context, rootID string, q threadQuery) (threadAPIResponse, error) {
+	db := s.node.DB()
+	root := &threadNode{ID: rootID}
+	nodes := map[string]*threadNode{rootID: root}
+	response := threadAPIResponse{Root: root}
+
+	frontier := []string{rootID}
+	budget := q.limit
+	for depth := 1; len(frontier) > 0; depth++ {
+		if depth > q.depth || budget == 0 {
+			// Only find out which nodes have replies left out
+			replies, err := db.ThreadReplies(ctx, frontier, 0, threadMaxLimit)
+			if err != nil {
+				return response, err
+			}
+			for _, reply := range replies {
+				nodes[reply.Parent].MoreReplies = true
+				response.Truncated = true
+			}
+			break
+		}
+
+		var since int64
+		if depth == 1 {
+			since = q.since
+		}
+		replies, err := db.ThreadReplies(ctx, frontier, since, budget+1)
+		if err != nil {
+			return response, err
+		}
+
+		var next []string
+		for _, reply := range replies {
+			parent := nodes[reply.Parent]
+			if _, seen := nodes[reply.ID]; seen || parent == nil {
+				continue // reply cycles and parents outside the walk
+			}
+			if budget == 0 {
+				parent.MoreReplies = true
+				response.Truncated = true
+				if depth == 1 && response.Next == "" {
+					response.Next = strconv.FormatInt(reply.CreatedAt, 10)
+				}
+				continue
+			}
+			node := &threadNode{ID: reply.ID}
+			parent.Replies = append(parent.Replies, node)
+			nodes[reply.ID] = node
+			next = append(next, reply.ID)
+			budget--
+		}
+		frontier = next
+	}
+	response.Count = len(nodes) - 1
+
+	if err := s.attachThreadEvents(ctx, nodes); err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// attachThreadEvents looks up the events of nodes, leaving out those an
+// unauthenticated reader may not see.
+func (s *Server) attachThreadEvents(ctx context.Context, nodes map[string]*threadNode) error {
+	db := s.node.DB()
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	for start := 0; start < len(ids); start += threadFetchBatch {
+		batch := ids[start:min(start+threadFetchBatch, len(ids))]
+		events, err := db.GetEvents(ctx, nostr.Filter{IDs: batch, Limit: len(batch)})
+		if err != nil {
+			return err
+		}
+		for i := range events {
+			if node := nodes[events[i].ID]; node != nil && visibleAnonymously(db, &events[i]) {
+				node.Event = &events[i]
+			}
+		}
+	}
+	return nil
+}
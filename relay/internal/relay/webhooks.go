@@ -0,0 +1,39 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"github.com/Shugur-Network/relay/internal/webhook"
+	"go.uber.org/zap"
+)
+
+// webhookInstance is the package-level webhook notifier (nil when disabled).
+var webhookInstance *webhook.Notifier
+
+// InitWebhooks builds the webhook notifier and hooks it into event storage.
+// Called from NewServer; deliveries are started by startWebhooks.
+func InitWebhooks(cfg *config.Config, db *storage.DB) *webhook.Notifier {
+	webhookInstance = nil
+	if !cfg.Webhooks.Enabled || len(cfg.Webhooks.Endpoints) == 0 || db == nil {
+		return nil
+	}
+
+	n, err := webhook.New(cfg.Webhooks)
+	if err != nil {
+		logger.New("webhook").Error("Webhooks disabled", zap.Error(err))
+		return nil
+	}
+	db.SetWebhookNotifier(n.Submit)
+	webhookInstance = n
+	return n
+}
+
+// startWebhooks starts delivering matching events until ctx is canceled.
+func startWebhooks(ctx context.Context) {
+	if webhookInstance != nil {
+		webhookInstance.Start(ctx)
+	}
+}
@@ -0,0 +1,333 @@
+package relay
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Wiki API: everything a client needs to render a NIP-54 wiki page.
+//
+//	GET /api/wiki?d=<topic>
+//
+// The topic is normalized like the d tags of wiki articles. While no article
+// covers it, the redirect (kind 30819) of the most trusted author is
+// followed to the topic it names, up to wikiMaxRedirects hops. The articles
+// (kind 30818) on the resolved topic are ranked by their author's
+// web-of-trust score, plus one for every trusted labeler that gave the
+// article or its author a WIKI.PREFERRED_LABELS label and minus one for
+// every one that gave it a WIKI.DEMOTED_LABELS label; ties go to the newer
+// article. Each article carries the merge requests (kind 818) made against
+// it since its author last updated it, newest first.
+
+const (
+	wikiDefaultMaxArticles = 50
+	// wikiMaxRedirects bounds the redirect hops followed from a topic.
+	wikiMaxRedirects = 5
+	// wikiMaxRedirectEvents bounds the redirects read for one topic.
+	wikiMaxRedirectEvents = 50
+	// wikiMaxMergeRequests bounds the merge requests read for one page.
+	wikiMaxMergeRequests = 200
+	// wikiMaxLabels bounds the labels read per target type for one page.
+	wikiMaxLabels = 1000
+	// wikiMaxTopicLength bounds the d parameter.
+	wikiMaxTopicLength = 256
+)
+
+// wikiRedirect is one redirect hop of /api/wiki.
+type wikiRedirect struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Address string       `json:"address"` // the article the redirect names
+	Event   *nostr.Event `json:"event"`
+}
+
+// wikiArticle is a ranked article of /api/wiki.
+type wikiArticle struct {
+	Address       string         `json:"address"`
+	Pubkey        string         `json:"pubkey"`
+	Title         string         `json:"title,omitempty"`
+	Summary       string         `json:"summary,omitempty"`
+	Score         float64        `json:"score"`
+	Trust         float64        `json:"trust"`            // the author's web-of-trust score
+	Labels        []string       `json:"labels,omitempty"` // ranking labels from trusted labelers
+	MergeRequests []*nostr.Event `json:"merge_requests"`
+	Event         *nostr.Event   `json:"event"`
+}
+
+// wikiAPIResponse is the body of /api/wiki.
+type wikiAPIResponse struct {
+	Requested string         `json:"requested"` // the normalized requested topic
+	Topic     string         `json:"topic"`     // the topic after redirects
+	Redirects []wikiRedirect `json:"redirects"`
+	Articles  []wikiArticle  `json:"articles"`
+}
+
+// handleWikiAPI serves GET /api/wiki.
+func (s *Server) handleWikiAPI(w http.ResponseWriter, r *http.Request) {
+	cfg := s.fullCfg.Wiki
+	if !s.prepareEventsAPI(w, r, s.eventsAPI.enabled && cfg.Enabled) {
+		return
+	}
+	raw := r.URL.Query().Get("d")
+	if len(raw) > wikiMaxTopicLength {
+		writeEventsAPIError(w, r, "INVALID_TOPIC", "d must be at most "+strconv.Itoa(wikiMaxTopicLength)+" characters")
+		return
+	}
+	topic := nips.NormalizeWikiTopic(raw)
+	if topic == "" {
+		writeEventsAPIError(w, r, "INVALID_TOPIC", "d must name a topic with at least one letter")
+		return
+	}
+
+	release, ok := s.acquireEventsAPISlot(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(eventsAPIContext(r), eventsAPIQueryTimeout)
+	defer cancel()
+
+	response, err := wikiPage(ctx, s.node.DB(), cfg, topic)
+	if err != nil {
+		logger.Warn("Wiki query failed", zap.Error(err))
+		errors.HandleHTTPError(w, r, errors.HandleDatabaseError("wiki query", err))
+		return
+	}
+	writeEventsAPIJSON(w, response)
+}
+
+// wikiPage resolves topic through its redirects and ranks the articles on
+// the topic it ends at.
+func wikiPage(ctx context.Context, db *storage.DB, cfg config.WikiConfig, topic string) (*wikiAPIResponse, error) {
+	limit := cfg.MaxArticles
+	if limit <= 0 {
+		limit = wikiDefaultMaxArticles
+	}
+	response := &wikiAPIResponse{Requested: topic, Redirects: []wikiRedirect{}, Articles: []wikiArticle{}}
+
+	visited := map[string]bool{topic: true}
+	var articles []nostr.Event
+	for {
+		var err error
+		if articles, err = wikiEvents(ctx, db, nips.KindWikiArticle, topic, limit); err != nil {
+			return nil, err
+		}
+		if len(articles) > 0 || len(response.Redirects) >= wikiMaxRedirects {
+			break
+		}
+		redirect, err := wikiRedirectFrom(ctx, db, topic)
+		if err != nil {
+			return nil, err
+		}
+		if redirect == nil || visited[redirect.To] {
+			break
+		}
+		visited[redirect.To] = true
+		response.Redirects = append(response.Redirects, *redirect)
+		topic = redirect.To
+	}
+	response.Topic = topic
+	if len(articles) == 0 {
+		return response, nil
+	}
+
+	byAddress := make(map[string]*wikiArticle, len(articles))
+	response.Articles = make([]wikiArticle, len(articles))
+	for i := range articles {
+		evt := &articles[i]
+		trust := wikiTrust(evt.PubKey)
+		response.Articles[i] = wikiArticle{
+			Address:       fmt.Sprintf("%d:%s:%s", nips.KindWikiArticle, evt.PubKey, topic),
+			Pubkey:        evt.PubKey,
+			Title:         articleTag(evt, "title"),
+			Summary:       articleTag(evt, "summary"),
+			Score:         trust,
+			Trust:         trust,
+			MergeRequests: []*nostr.Event{},
+			Event:         evt,
+		}
+	}
+	for i := range response.Articles {
+		byAddress[response.Articles[i].Address] = &response.Articles[i]
+	}
+
+	if err := rankWikiArticles(ctx, db, cfg, response.Articles); err != nil {
+		return nil, err
+	}
+	if err := attachWikiMergeRequests(ctx, db, byAddress); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(response.Articles, func(a, b wikiArticle) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			cmp.Compare(b.Event.CreatedAt, a.Event.CreatedAt),
+			cmp.Compare(a.Event.ID, b.Event.ID),
+		)
+	})
+	return response, nil
+}
+
+// wikiEvents returns the visible events of a NIP-54 kind with d tag topic.
+func wikiEvents(ctx context.Context, db *storage.DB, kind int, topic string, limit int) ([]nostr.Event, error) {
+	events, err := db.GetEvents(ctx, nostr.Filter{
+		Kinds: []int{kind},
+		Tags:  nostr.TagMap{"d": []string{topic}},
+		Limit: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	visible := events[:0]
+	for i := range events {
+		if visibleAnonymously(db, &events[i]) {
+			visible = append(visible, events[i])
+		}
+	}
+	return visible, nil
+}
+
+// wikiRedirectFrom returns the redirect away from topic of its most trusted
+// author, the newest on equal trust, or nil when topic has none.
+func wikiRedirectFrom(ctx context.Context, db *storage.DB, topic string) (*wikiRedirect, error) {
+	redirects, err := wikiEvents(ctx, db, nips.KindWikiRedirect, topic, wikiMaxRedirectEvents)
+	if err != nil {
+		return nil, err
+	}
+	var best *wikiRedirect
+	var bestTrust float64
+	for i := range redirects {
+		evt := &redirects[i]
+		address := articleTag(evt, "redirect")
+		parts := strings.SplitN(address, ":", 3)
+		if len(parts) != 3 || parts[0] != strconv.Itoa(nips.KindWikiArticle) || !nostr.IsValid32ByteHex(parts[1]) {
+			continue
+		}
+		to := nips.NormalizeWikiTopic(parts[2])
+		if to == "" || to == topic {
+			continue
+		}
+		trust := wikiTrust(evt.PubKey)
+		if best != nil && (trust < bestTrust || (trust == bestTrust && evt.CreatedAt <= best.Event.CreatedAt)) {
+			continue
+		}
+		best = &wikiRedirect{From: topic, To: to, Address: address, Event: evt}
+		bestTrust = trust
+	}
+	return best, nil
+}
+
+// wikiTrust returns the web-of-trust score of pubkey, 0 when web-of-trust
+// is disabled or does not reach it.
+func wikiTrust(pubkey string) float64 {
+	if g := GetReputation(); g != nil {
+		if t, ok := g.Lookup(pubkey); ok {
+			return t.Score
+		}
+	}
+	return 0
+}
+
+// rankWikiArticles adds the preferred and demoted labels of the trusted
+// labelers to the scores of articles.
+func rankWikiArticles(ctx context.Context, db *storage.DB, cfg config.WikiConfig, articles []wikiArticle) error {
+	l := labelsInstance
+	if l == nil || len(l.cfg.TrustedLabelers) == 0 || len(cfg.PreferredLabels)+len(cfg.DemotedLabels) == 0 {
+		return nil
+	}
+
+	targets := map[string][]string{}
+	for _, a := range articles {
+		targets["a"] = append(targets["a"], a.Address)
+		targets["e"] = append(targets["e"], a.Event.ID)
+		if !slices.Contains(targets["p"], a.Pubkey) {
+			targets["p"] = append(targets["p"], a.Pubkey)
+		}
+	}
+	var records []storage.LabelRecord
+	for _, targetType := range []string{"a", "e", "p"} {
+		recs, err := db.Labels(ctx, storage.LabelQuery{
+			Labelers:   l.cfg.TrustedLabelers,
+			TargetType: targetType,
+			Targets:    targets[targetType],
+			Limit:      wikiMaxLabels,
+		})
+		if err != nil {
+			return err
+		}
+		records = append(records, recs...)
+	}
+
+	for i := range articles {
+		a := &articles[i]
+		preferred := make(map[string]bool)
+		demoted := make(map[string]bool)
+		for _, rec := range records {
+			if (rec.TargetType == "a" && rec.Target != a.Address) ||
+				(rec.TargetType == "e" && rec.Target != a.Event.ID) ||
+				(rec.TargetType == "p" && rec.Target != a.Pubkey) {
+				continue
+			}
+			label := rec.Namespace + "/" + rec.Label
+			switch {
+			case slices.Contains(cfg.PreferredLabels, label):
+				preferred[rec.Labeler] = true
+			case slices.Contains(cfg.DemotedLabels, label):
+				demoted[rec.Labeler] = true
+			default:
+				continue
+			}
+			if !slices.Contains(a.Labels, label) {
+				a.Labels = append(a.Labels, label)
+			}
+		}
+		a.Score += float64(len(preferred) - len(demoted))
+		slices.Sort(a.Labels)
+	}
+	return nil
+}
+
+// attachWikiMergeRequests adds to each article the visible merge requests
+// made against it after its current version was published.
+func attachWikiMergeRequests(ctx context.Context, db *storage.DB, byAddress map[string]*wikiArticle) error {
+	addresses := make([]string, 0, len(byAddress))
+	for address := range byAddress {
+		addresses = append(addresses, address)
+	}
+	requests, err := db.GetEvents(ctx, nostr.Filter{
+		Kinds: []int{nips.KindWikiMergeRequest},
+		Tags:  nostr.TagMap{"a": addresses},
+		Limit: wikiMaxMergeRequests,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range requests {
+		evt := &requests[i]
+		if !visibleAnonymously(db, evt) {
+			continue
+		}
+		for _, tag := range evt.Tags {
+			if len(tag) < 2 || tag[0] != "a" {
+				continue
+			}
+			if a := byAddress[tag[1]]; a != nil && evt.CreatedAt > a.Event.CreatedAt && !slices.Contains(a.MergeRequests, evt) {
+				a.MergeRequests = append(a.MergeRequests, evt)
+			}
+		}
+	}
+	return nil
+}
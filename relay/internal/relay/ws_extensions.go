@@ -0,0 +1,89 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket extension negotiation. gorilla/websocket decides on
+// permessage-deflate inside Upgrade and does not expose the outcome, so the
+// upgrade is run through a hijacker that keeps the handshake response it
+// writes, and the negotiated extensions are read back from that response.
+
+// perMessageDeflate is the RFC 7692 extension token.
+const perMessageDeflate = "permessage-deflate"
+
+// extensionNames returns the extension tokens of Sec-WebSocket-Extensions
+// header values, lowercased and without their parameters.
+func extensionNames(values []string) []string {
+	var names []string
+	for _, value := range values {
+		for _, ext := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(ext, ";")
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// upgradeWebSocket upgrades r and reports whether permessage-deflate was
+// negotiated, as announced in the handshake response.
+func upgradeWebSocket(upgrader *websocket.Upgrader, w http.ResponseWriter, r *http.Request) (*websocket.Conn, bool, error) {
+	rec := &handshakeRecorder{ResponseWriter: w}
+	ws, err := upgrader.Upgrade(rec, r, nil)
+	if err != nil {
+		return ws, false, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rec.response)), r)
+	if err != nil {
+		return ws, false, nil
+	}
+	for _, name := range extensionNames(resp.Header.Values("Sec-WebSocket-Extensions")) {
+		if name == perMessageDeflate {
+			return ws, true, nil
+		}
+	}
+	return ws, false, nil
+}
+
+// handshakeRecorder is a ResponseWriter whose hijacked connection keeps the
+// first write, the handshake response of gorilla/websocket.
+type handshakeRecorder struct {
+	http.ResponseWriter
+	response []byte
+}
+
+func (h *handshakeRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := h.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not implement http.Hijacker")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &handshakeConn{Conn: conn, rec: h}, brw, nil
+}
+
+// handshakeConn records the first write on a hijacked connection.
+type handshakeConn struct {
+	net.Conn
+	rec      *handshakeRecorder
+	recorded bool
+}
+
+func (c *handshakeConn) Write(p []byte) (int, error) {
+	if !c.recorded {
+		c.recorded = true
+		c.rec.response = append([]byte(nil), p...)
+	}
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,74 @@
+package relay
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestExtensionNames(t *testing.T) {
+	got := extensionNames([]string{
+		"permessage-deflate; client_max_window_bits, x-webkit-deflate-frame",
+		" X-Permessage-Deflate-Foo ;a=1",
+	})
+	want := []string{"permessage-deflate", "x-webkit-deflate-frame", "x-permessage-deflate-foo"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extensionNames = %q, want %q", got, want)
+	}
+}
+
+// TestUpgradeWebSocketCompression checks that compression is reported from
+// the extensions the handshake actually negotiated.
+func TestUpgradeWebSocketCompression(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		enabled    bool
+		extensions string
+		want       bool
+	}{
+		{"offered", true, "permessage-deflate; client_max_window_bits", true},
+		{"disabled", false, "permessage-deflate", false},
+		{"not offered", true, "", false},
+		{"lookalike extension", true, "x-permessage-deflate-foo", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			upgrader := websocket.Upgrader{EnableCompression: tc.enabled}
+			result := make(chan bool, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ws, compressed, err := upgradeWebSocket(&upgrader, w, r)
+				if err != nil {
+					t.Errorf("upgrade: %v", err)
+					result <- false
+					return
+				}
+				ws.Close()
+				result <- compressed
+			}))
+			defer srv.Close()
+
+			// The gorilla dialer refuses custom extension offers, so the
+			// handshake is written by hand
+			conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			req := "GET / HTTP/1.1\r\nHost: relay\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+				"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"
+			if tc.extensions != "" {
+				req += "Sec-WebSocket-Extensions: " + tc.extensions + "\r\n"
+			}
+			if _, err := conn.Write([]byte(req + "\r\n")); err != nil {
+				t.Fatalf("write handshake: %v", err)
+			}
+			if got := <-result; got != tc.want {
+				t.Errorf("compressed = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Zap receipt verification: with VERIFY_RECEIPTS a NIP-57 zap receipt is
+// accepted only when its bolt11 invoice commits to the embedded zap request
+// and carries the requested amount. With VERIFY_PROVIDER the receipt must
+// further be signed by the nostrPubkey the recipient's LNURL-pay endpoint
+// announces. Endpoints are looked up from the recipient's stored kind 0 and
+// cached; a recipient without a stored profile or an unreachable endpoint
+// cannot be checked and its receipts are accepted. Receipts of zaps paid to
+// the relay's own lightning address are checked against the invoices it
+// issued instead (see lnurl.go).
+
+// maxZapProviders bounds the provider lookups cached in memory.
+const maxZapProviders = 10000
+
+// maxLNURLResponse bounds the LNURL-pay response read.
+const maxLNURLResponse = 64 * 1024
+
+// zapProvider is a cached LNURL-pay lookup.
+type zapProvider struct {
+	pubkey  string // "" when the endpoint does not support zaps
+	expires time.Time
+}
+
+// ZapVerifier verifies zap receipts against their zap requests and providers.
+type ZapVerifier struct {
+	db             *storage.DB
+	client         *http.Client
+	verifyProvider bool
+	cacheTTL       time.Duration
+
+	mu        sync.Mutex
+	providers map[string]zapProvider // keyed by LNURL-pay endpoint
+}
+
+// zapVerifierInstance is the package-level zap verifier (nil when receipts are not verified).
+var zapVerifierInstance *ZapVerifier
+
+// GetZapVerifier returns the zap verifier, or nil when receipts are not verified.
+func GetZapVerifier() *ZapVerifier {
+	return zapVerifierInstance
+}
+
+// InitZapVerifier creates the zap verifier. Called from NewServer.
+func InitZapVerifier(cfg *config.Config, db *storage.DB) *ZapVerifier {
+	if !cfg.Zaps.VerifyReceipts {
+		zapVerifierInstance = nil
+		return nil
+	}
+
+	timeout := cfg.Zaps.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	cacheTTL := cfg.Zaps.CacheTTL
+	if cacheTTL <= 0 {
+		cacheTTL = time.Hour
+	}
+	zapVerifierInstance = &ZapVerifier{
+		db:             db,
+		client:         &http.Client{Timeout: timeout},
+		verifyProvider: cfg.Zaps.VerifyProvider,
+		cacheTTL:       cacheTTL,
+		providers:      make(map[string]zapProvider),
+	}
+	return zapVerifierInstance
+}
+
+// Verify checks a structurally valid zap receipt.
+func (v *ZapVerifier) Verify(ctx context.Context, event *nostr.Event) error {
+	if _, err := nips.VerifyZapReceipt(event); err != nil {
+		return err
+	}
+	// Zaps paid to the relay's own lightning addresses are checked against
+	// the invoices it issued
+	ln := GetLNURLPay()
+	if ln != nil && ln.Signs(event.PubKey) {
+		return ln.VerifyReceipt(event)
+	}
+	if !v.verifyProvider || v.db == nil {
+		return nil
+	}
+
+	recipient := nips.GetTagValue(*event, "p")
+	profiles, err := v.db.GetEvents(ctx, nostr.Filter{Kinds: []int{0}, Authors: []string{recipient}, Limit: 1})
+	if err != nil || len(profiles) == 0 {
+		return nil
+	}
+	endpoint, err := nips.ZapEndpoint(&profiles[0])
+	if err != nil {
+		return fmt.Errorf("recipient's zap address is invalid: %w", err)
+	}
+	if endpoint == "" {
+		return fmt.Errorf("recipient has no lightning address to be zapped at")
+	}
+	if ln != nil && ln.Serves(endpoint) {
+		// Only the relay signs receipts for its addresses
+		return fmt.Errorf("zap receipt is not signed by the recipient's zap provider")
+	}
+
+	provider, err := v.provider(ctx, endpoint)
+	if err != nil {
+		logger.Debug("Zap provider lookup failed",
+			zap.String("endpoint", endpoint),
+			zap.Error(err))
+		return nil
+	}
+	if provider == "" {
+		return fmt.Errorf("recipient's lightning address does not support zaps")
+	}
+	if provider != event.PubKey {
+		return fmt.Errorf("zap receipt is not signed by the recipient's zap provider")
+	}
+	return nil
+}
+
+// provider returns the nostrPubkey an LNURL-pay endpoint announces, or ""
+// when it does not allow zaps.
+func (v *ZapVerifier) provider(ctx context.Context, endpoint string) (string, error) {
+	now := time.Now()
+	v.mu.Lock()
+	cached, ok := v.providers[endpoint]
+	v.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.pubkey, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("LNURL-pay endpoint returned %s", resp.Status)
+	}
+
+	var params struct {
+		AllowsNostr bool   `json:"allowsNostr"`
+		NostrPubkey string `json:"nostrPubkey"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxLNURLResponse)).Decode(&params); err != nil {
+		return "", fmt.Errorf("invalid LNURL-pay response: %w", err)
+	}
+	pubkey := ""
+	if params.AllowsNostr && nostr.IsValid32ByteHex(params.NostrPubkey) {
+		pubkey = params.NostrPubkey
+	}
+
+	v.mu.Lock()
+	if len(v.providers) >= maxZapProviders {
+		for key, p := range v.providers {
+			if now.After(p.expires) || len(v.providers) >= maxZapProviders {
+				delete(v.providers, key)
+			}
+		}
+	}
+	v.providers[endpoint] = zapProvider{pubkey: pubkey, expires: now.Add(v.cacheTTL)}
+	v.mu.Unlock()
+	return pubkey, nil
+}
+
+// checkZapReceipt runs the deep zap receipt checks when they are enabled.
+func (pv *PluginValidator) checkZapReceipt(event *nostr.Event) error {
+	v := GetZapVerifier()
+	if v == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), v.client.Timeout+time.Second)
+	defer cancel()
+	return v.Verify(ctx, event)
+}
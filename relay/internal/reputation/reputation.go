@@ -0,0 +1,307 @@
+package reputation
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// followDecay is the share of a follower's trust passed on through one follow.
+	followDecay = 0.5
+	// maxGraphPubkeys bounds the pubkeys scored in one refresh.
+	maxGraphPubkeys = 500000
+	// authorBatch bounds the authors in one follow list lookup.
+	authorBatch = 500
+	// maxUnknownLimiters bounds the per-pubkey limiters kept for unknown pubkeys.
+	maxUnknownLimiters = 10000
+)
+
+// FollowLister returns the pubkeys each author follows in its latest follow
+// list (kind 3), such as storage.DB.FollowLists.
+type FollowLister func(ctx context.Context, authors []string) (map[string][]string, error)
+
+// Trust describes one pubkey's place in the trust graph.
+type Trust struct {
+	Pubkey    string  `json:"pubkey"`
+	Score     float64 `json:"score"`
+	Depth     int     `json:"depth"`     // follow hops from the nearest anchor (0 = anchor)
+	Followers int     `json:"followers"` // followers inside the graph
+	Trusted   bool    `json:"trusted"`
+}
+
+// Stats summarizes the last refresh.
+type Stats struct {
+	Pubkeys     int       `json:"pubkeys"`
+	Trusted     int       `json:"trusted"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+}
+
+// Graph computes trust scores from the follow lists reachable from the anchors.
+//
+// Anchors score 1. A pubkey first reached at depth d is scored from its
+// followers at depth d-1: every follower f contributes score(f)*followDecay
+// and contributions combine as 1 - Π(1 - c), so more followers raise the
+// score towards, but never past, 1.
+type Graph struct {
+	cfg     config.ReputationConfig
+	follows FollowLister
+
+	mu          sync.RWMutex
+	scores      map[string]Trust
+	refreshedAt time.Time
+
+	limiterMu    sync.Mutex
+	limiters     map[string]*rate.Limiter
+	unknownLimit rate.Limit
+	unknownBurst int
+}
+
+// New creates a trust graph reading follow lists through follows.
+func New(cfg config.ReputationConfig, follows FollowLister) *Graph {
+	burst := cfg.Unknown.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	limit := rate.Inf
+	if cfg.Unknown.EventsPerMinute > 0 {
+		limit = rate.Limit(float64(cfg.Unknown.EventsPerMinute) / 60)
+	}
+	return &Graph{
+		cfg:          cfg,
+		follows:      follows,
+		scores:       make(map[string]Trust),
+		limiters:     make(map[string]*rate.Limiter),
+		unknownLimit: limit,
+		unknownBurst: burst,
+	}
+}
+
+// Start computes the graph and refreshes it every REFRESH_INTERVAL until ctx is canceled.
+func (g *Graph) Start(ctx context.Context) {
+	interval := g.cfg.RefreshInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	go func() {
+		log := logger.New("reputation")
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := g.Refresh(ctx); err != nil && ctx.Err() == nil {
+				log.Warn("Failed to refresh trust graph", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Refresh rebuilds every trust score from the stored follow lists.
+func (g *Graph) Refresh(ctx context.Context) error {
+	maxDepth := g.cfg.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = 2
+	}
+
+	scores := make(map[string]Trust)
+	var frontier []string
+	for _, anchor := range g.cfg.Anchors {
+		anchor = strings.ToLower(anchor)
+		if _, ok := scores[anchor]; ok {
+			continue
+		}
+		scores[anchor] = Trust{Pubkey: anchor, Score: 1}
+		frontier = append(frontier, anchor)
+	}
+
+	for depth := 1; depth <= maxDepth && len(frontier) > 0; depth++ {
+		follows, err := g.followLists(ctx, frontier)
+		if err != nil {
+			return err
+		}
+
+		// distrust[p] = Π(1 - contribution) over p's followers in the frontier
+		distrust := make(map[string]float64)
+		followers := make(map[string]int)
+		for _, follower := range frontier {
+			contribution := scores[follower].Score * followDecay
+			for _, followed := range follows[follower] {
+				if followed == follower {
+					continue
+				}
+				if prev, ok := scores[followed]; ok {
+					prev.Followers++
+					scores[followed] = prev
+					continue
+				}
+				if _, ok := distrust[followed]; !ok {
+					if len(scores)+len(distrust) >= maxGraphPubkeys {
+						continue
+					}
+					distrust[followed] = 1
+				}
+				distrust[followed] *= 1 - contribution
+				followers[followed]++
+			}
+		}
+
+		frontier = make([]string, 0, len(distrust))
+		for pubkey, d := range distrust {
+			scores[pubkey] = Trust{Pubkey: pubkey, Score: 1 - d, Depth: depth, Followers: followers[pubkey]}
+			frontier = append(frontier, pubkey)
+		}
+	}
+
+	trusted := 0
+	for pubkey, t := range scores {
+		t.Trusted = t.Score >= g.cfg.TrustThreshold
+		if t.Trusted {
+			trusted++
+		}
+		scores[pubkey] = t
+	}
+
+	g.mu.Lock()
+	g.scores = scores
+	g.refreshedAt = time.Now()
+	g.mu.Unlock()
+
+	metrics.TrustGraphPubkeys.Set(float64(len(scores)))
+	logger.New("reputation").Info("Trust graph refreshed",
+		zap.Int("pubkeys", len(scores)),
+		zap.Int("trusted", trusted),
+		zap.Int("anchors", len(g.cfg.Anchors)))
+	return nil
+}
+
+// followLists returns the followed pubkeys of each author in batches.
+func (g *Graph) followLists(ctx context.Context, authors []string) (map[string][]string, error) {
+	follows := make(map[string][]string, len(authors))
+	for start := 0; start < len(authors); start += authorBatch {
+		batch, err := g.follows(ctx, authors[start:min(start+authorBatch, len(authors))])
+		if err != nil {
+			return nil, err
+		}
+		for author, followed := range batch {
+			follows[author] = followed
+		}
+	}
+	return follows, nil
+}
+
+// Lookup returns the trust entry of a pubkey and whether it is in the graph.
+func (g *Graph) Lookup(pubkey string) (Trust, bool) {
+	pubkey = strings.ToLower(pubkey)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	t, ok := g.scores[pubkey]
+	if !ok {
+		return Trust{Pubkey: pubkey}, false
+	}
+	return t, true
+}
+
+// IsTrusted reports whether a pubkey scores at or above the trust threshold.
+func (g *Graph) IsTrusted(pubkey string) bool {
+	t, _ := g.Lookup(pubkey)
+	return t.Trusted
+}
+
+// MinPow returns the NIP-13 difficulty required from a pubkey: none for
+// trusted pubkeys, at least the unknown-pubkey minimum outside the graph.
+func (g *Graph) MinPow(pubkey string, relayMin int) int {
+	t, known := g.Lookup(pubkey)
+	switch {
+	case t.Trusted:
+		if relayMin > 0 {
+			metrics.ReputationDecisions.WithLabelValues("trusted_bypass").Inc()
+		}
+		return 0
+	case !known:
+		return max(relayMin, g.cfg.Unknown.MinPowDifficulty)
+	}
+	return relayMin
+}
+
+// CheckEvent applies the unknown-pubkey rate limit. Returns (allowed, reason).
+func (g *Graph) CheckEvent(evt *nostr.Event) (bool, string) {
+	if g.unknownLimit == rate.Inf {
+		return true, ""
+	}
+	if _, known := g.Lookup(evt.PubKey); known {
+		return true, ""
+	}
+
+	pubkey := strings.ToLower(evt.PubKey)
+	g.limiterMu.Lock()
+	limiter := g.limiters[pubkey]
+	if limiter == nil {
+		if len(g.limiters) >= maxUnknownLimiters {
+			g.limiters = make(map[string]*rate.Limiter)
+		}
+		limiter = rate.NewLimiter(g.unknownLimit, g.unknownBurst)
+		g.limiters[pubkey] = limiter
+	}
+	g.limiterMu.Unlock()
+
+	if !limiter.Allow() {
+		metrics.ReputationDecisions.WithLabelValues("unknown_rate_limited").Inc()
+		return false, "rate-limited: slow down, this pubkey is not yet known to the relay"
+	}
+	return true, ""
+}
+
+// ShadowRejects reports whether events refused by CheckEvent should be
+// acknowledged and dropped rather than refused.
+func (g *Graph) ShadowRejects() bool {
+	return g.cfg.Unknown.ShadowReject
+}
+
+// TopTrusted returns up to limit trusted pubkeys, highest score first.
+func (g *Graph) TopTrusted(limit int) []Trust {
+	g.mu.RLock()
+	trusted := make([]Trust, 0)
+	for _, t := range g.scores {
+		if t.Trusted {
+			trusted = append(trusted, t)
+		}
+	}
+	g.mu.RUnlock()
+
+	sort.Slice(trusted, func(i, j int) bool {
+		if trusted[i].Score != trusted[j].Score {
+			return trusted[i].Score > trusted[j].Score
+		}
+		return trusted[i].Pubkey < trusted[j].Pubkey
+	})
+	if len(trusted) > limit {
+		trusted = trusted[:limit]
+	}
+	return trusted
+}
+
+// Stats summarizes the graph.
+func (g *Graph) Stats() Stats {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	stats := Stats{Pubkeys: len(g.scores), RefreshedAt: g.refreshedAt}
+	for _, t := range g.scores {
+		if t.Trusted {
+			stats.Trusted++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,189 @@
+package scoring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// RegexScorer scores 1 when the content matches any configured pattern.
+type RegexScorer struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRegexScorer compiles the given patterns.
+func NewRegexScorer(patterns []string) (*RegexScorer, error) {
+	s := &RegexScorer{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		s.patterns = append(s.patterns, re)
+	}
+	return s, nil
+}
+
+// Name implements Scorer.
+func (s *RegexScorer) Name() string { return "regex" }
+
+// Score implements Scorer.
+func (s *RegexScorer) Score(_ context.Context, evt *nostr.Event) (float64, error) {
+	for _, re := range s.patterns {
+		if re.MatchString(evt.Content) {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// maxBayesTokens bounds the tokens considered for one event.
+const maxBayesTokens = 200
+
+// BayesModel holds token counts for a naive Bayes spam classifier.
+type BayesModel struct {
+	SpamDocs int            `json:"spam_docs"`
+	HamDocs  int            `json:"ham_docs"`
+	Spam     map[string]int `json:"spam"`
+	Ham      map[string]int `json:"ham"`
+}
+
+// BayesScorer scores content with a naive Bayes token model.
+type BayesScorer struct {
+	model     BayesModel
+	spamTotal int
+	hamTotal  int
+	vocab     int
+}
+
+// LoadBayesScorer reads a JSON token model from path.
+func LoadBayesScorer(path string) (*BayesScorer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bayes model: %w", err)
+	}
+	var model BayesModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("failed to parse bayes model: %w", err)
+	}
+	if model.SpamDocs <= 0 || model.HamDocs <= 0 {
+		return nil, fmt.Errorf("bayes model needs both spam and ham documents")
+	}
+	return NewBayesScorer(model), nil
+}
+
+// NewBayesScorer creates a scorer from an in-memory model.
+func NewBayesScorer(model BayesModel) *BayesScorer {
+	s := &BayesScorer{model: model}
+	vocab := make(map[string]bool)
+	for token, n := range model.Spam {
+		s.spamTotal += n
+		vocab[token] = true
+	}
+	for token, n := range model.Ham {
+		s.hamTotal += n
+		vocab[token] = true
+	}
+	s.vocab = len(vocab)
+	return s
+}
+
+// Name implements Scorer.
+func (s *BayesScorer) Name() string { return "bayes" }
+
+// Score implements Scorer. It returns P(spam | tokens) with Laplace smoothing.
+func (s *BayesScorer) Score(_ context.Context, evt *nostr.Event) (float64, error) {
+	tokens := tokenize(evt.Content)
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	total := float64(s.model.SpamDocs + s.model.HamDocs)
+	logSpam := math.Log(float64(s.model.SpamDocs) / total)
+	logHam := math.Log(float64(s.model.HamDocs) / total)
+	spamDenom := float64(s.spamTotal + s.vocab + 1)
+	hamDenom := float64(s.hamTotal + s.vocab + 1)
+	for _, token := range tokens {
+		logSpam += math.Log(float64(s.model.Spam[token]+1) / spamDenom)
+		logHam += math.Log(float64(s.model.Ham[token]+1) / hamDenom)
+	}
+
+	// P(spam) = 1 / (1 + e^(logHam - logSpam))
+	return 1 / (1 + math.Exp(logHam-logSpam)), nil
+}
+
+// tokenize splits content into distinct lowercase word tokens.
+func tokenize(content string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) < 2 || len(word) > 32 || seen[word] {
+			continue
+		}
+		seen[word] = true
+		tokens = append(tokens, word)
+		if len(tokens) >= maxBayesTokens {
+			break
+		}
+	}
+	return tokens
+}
+
+// HTTPScorer posts the event as JSON to an external classifier that answers {"score": 0-1}.
+type HTTPScorer struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPScorer creates a scorer for the classifier at url.
+func NewHTTPScorer(url string, timeout time.Duration) *HTTPScorer {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &HTTPScorer{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Name implements Scorer.
+func (s *HTTPScorer) Name() string { return "http" }
+
+// Score implements Scorer.
+func (s *HTTPScorer) Score(ctx context.Context, evt *nostr.Event) (float64, error) {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("classifier returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Score float64 `json:"score"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("invalid classifier response: %w", err)
+	}
+	return math.Max(0, math.Min(1, result.Score)), nil
+}
@@ -0,0 +1,350 @@
+package scoring
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+const (
+	// scoreTimeout bounds scoring a single event across all scorers.
+	scoreTimeout = 5 * time.Second
+	// maxRecords bounds the recent event scores kept for the management API.
+	maxRecords = 10000
+	// maxAuthors bounds the per-pubkey score summaries kept in memory.
+	maxAuthors = 100000
+)
+
+// Scorer rates how likely an event is to be spam or malicious, from 0 (clean) to 1.
+type Scorer interface {
+	Name() string
+	Score(ctx context.Context, evt *nostr.Event) (float64, error)
+}
+
+// Remover deletes an event that scored above the delete threshold.
+type Remover func(ctx context.Context, evt *nostr.Event) error
+
+// Record is the outcome of scoring one event.
+type Record struct {
+	EventID  string    `json:"event_id"`
+	Pubkey   string    `json:"pubkey"`
+	Kind     int       `json:"kind"`
+	Score    float64   `json:"score"`
+	Scorer   string    `json:"scorer"` // scorer that produced the highest score
+	Action   string    `json:"action"` // "none", "deleted"
+	ScoredAt time.Time `json:"scored_at"`
+}
+
+// AuthorSummary aggregates the scores of one pubkey's events.
+type AuthorSummary struct {
+	Pubkey       string  `json:"pubkey"`
+	Scored       int     `json:"scored"`
+	Deleted      int     `json:"deleted"`
+	MaxScore     float64 `json:"max_score"`
+	MeanScore    float64 `json:"mean_score"`
+	ShadowBanned bool    `json:"shadow_banned"`
+	total        float64
+}
+
+// Pipeline scores stored events in the background. Events are accepted and
+// stored first; the pipeline removes them afterwards when they score at or
+// above the delete threshold, and shadow-bans authors that keep publishing spam.
+type Pipeline struct {
+	cfg     config.ScoringConfig
+	scorers []Scorer
+	local   []Scorer // in-process scorers, cheap enough for the write path
+	kinds   map[int]bool
+	queue   chan nostr.Event
+	remove  Remover
+	onBan   func(pubkey string, deleted int)
+
+	mu           sync.RWMutex
+	records      []Record // ring of recent scores, oldest first
+	authors      map[string]*AuthorSummary
+	shadowBanned map[string]bool
+}
+
+// New creates a pipeline from configuration, building every configured scorer.
+func New(cfg config.ScoringConfig) *Pipeline {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 10000
+	}
+	p := &Pipeline{
+		cfg:          cfg,
+		kinds:        make(map[int]bool, len(cfg.Kinds)),
+		queue:        make(chan nostr.Event, queueSize),
+		authors:      make(map[string]*AuthorSummary),
+		shadowBanned: make(map[string]bool),
+	}
+	for _, kind := range cfg.Kinds {
+		p.kinds[kind] = true
+	}
+
+	log := logger.New("scoring")
+	if len(cfg.Regex.Patterns) > 0 {
+		if s, err := NewRegexScorer(cfg.Regex.Patterns); err != nil {
+			log.Warn("Regex scorer disabled", zap.Error(err))
+		} else {
+			p.scorers = append(p.scorers, s)
+			p.local = append(p.local, s)
+		}
+	}
+	if cfg.Bayes.ModelPath != "" {
+		if s, err := LoadBayesScorer(cfg.Bayes.ModelPath); err != nil {
+			log.Warn("Bayes scorer disabled", zap.Error(err))
+		} else {
+			p.scorers = append(p.scorers, s)
+			p.local = append(p.local, s)
+		}
+	}
+	if cfg.HTTP.URL != "" {
+		p.scorers = append(p.scorers, NewHTTPScorer(cfg.HTTP.URL, cfg.HTTP.Timeout))
+	}
+	return p
+}
+
+// AddScorer registers an additional scorer. Must be called before Start.
+func (p *Pipeline) AddScorer(s Scorer) {
+	p.scorers = append(p.scorers, s)
+}
+
+// SetRemover sets how flagged events are deleted. Must be called before Start.
+func (p *Pipeline) SetRemover(remove Remover) {
+	p.remove = remove
+}
+
+// SetShadowBanHook sets a callback run when a pubkey is shadow-banned
+// automatically. Must be called before Start.
+func (p *Pipeline) SetShadowBanHook(fn func(pubkey string, deleted int)) {
+	p.onBan = fn
+}
+
+// Start launches the scoring workers. They exit when ctx is canceled.
+func (p *Pipeline) Start(ctx context.Context) {
+	workers := p.cfg.Workers
+	if workers <= 0 {
+		workers = 2
+	}
+	for i := 0; i < workers; i++ {
+		go p.worker(ctx)
+	}
+	names := make([]string, 0, len(p.scorers))
+	for _, s := range p.scorers {
+		names = append(names, s.Name())
+	}
+	logger.New("scoring").Info("Content scoring started",
+		zap.Int("workers", workers),
+		zap.Strings("scorers", names),
+		zap.Float64("delete_threshold", p.cfg.DeleteThreshold))
+}
+
+// Submit queues a stored event for scoring without blocking.
+func (p *Pipeline) Submit(evt *nostr.Event) {
+	if len(p.scorers) == 0 || evt.Content == "" {
+		return
+	}
+	if len(p.kinds) > 0 && !p.kinds[evt.Kind] {
+		return
+	}
+	select {
+	case p.queue <- *evt:
+	default:
+		metrics.ContentScoring.WithLabelValues("dropped").Inc()
+	}
+}
+
+// IsSuspectedSpam scores an incoming event with the local scorers and reports
+// whether it reaches the shadow reject threshold.
+func (p *Pipeline) IsSuspectedSpam(ctx context.Context, evt *nostr.Event) bool {
+	if p.cfg.ShadowRejectThreshold <= 0 || len(p.local) == 0 || evt.Content == "" {
+		return false
+	}
+	if len(p.kinds) > 0 && !p.kinds[evt.Kind] {
+		return false
+	}
+	for _, s := range p.local {
+		if score, err := s.Score(ctx, evt); err == nil && score >= p.cfg.ShadowRejectThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// worker scores queued events until ctx is canceled.
+func (p *Pipeline) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-p.queue:
+			p.process(ctx, &evt)
+		}
+	}
+}
+
+// process scores one event and applies the configured actions.
+func (p *Pipeline) process(ctx context.Context, evt *nostr.Event) {
+	scoreCtx, cancel := context.WithTimeout(ctx, scoreTimeout)
+	score, scorer := p.score(scoreCtx, evt)
+	cancel()
+
+	record := Record{
+		EventID:  evt.ID,
+		Pubkey:   evt.PubKey,
+		Kind:     evt.Kind,
+		Score:    score,
+		Scorer:   scorer,
+		Action:   "none",
+		ScoredAt: time.Now(),
+	}
+
+	if p.cfg.DeleteThreshold > 0 && score >= p.cfg.DeleteThreshold && p.remove != nil {
+		if err := p.remove(ctx, evt); err != nil {
+			logger.New("scoring").Warn("Failed to delete flagged event",
+				zap.String("event_id", evt.ID),
+				zap.Error(err))
+		} else {
+			record.Action = "deleted"
+			metrics.ContentScoring.WithLabelValues("deleted").Inc()
+		}
+	}
+	metrics.ContentScoring.WithLabelValues("scored").Inc()
+
+	p.record(record)
+}
+
+// score runs every scorer and returns the highest score and the scorer that produced it.
+func (p *Pipeline) score(ctx context.Context, evt *nostr.Event) (float64, string) {
+	best, bestName := 0.0, ""
+	for _, s := range p.scorers {
+		score, err := s.Score(ctx, evt)
+		if err != nil {
+			metrics.ContentScoring.WithLabelValues("error").Inc()
+			logger.New("scoring").Debug("Scorer failed",
+				zap.String("scorer", s.Name()),
+				zap.String("event_id", evt.ID),
+				zap.Error(err))
+			continue
+		}
+		if score > best || bestName == "" {
+			best, bestName = score, s.Name()
+		}
+	}
+	return best, bestName
+}
+
+// record stores a score and updates its author's summary, shadow-banning repeat offenders.
+func (p *Pipeline) record(r Record) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.records) >= maxRecords {
+		p.records = append(p.records[:0], p.records[len(p.records)-maxRecords+1:]...)
+	}
+	p.records = append(p.records, r)
+
+	summary := p.authors[r.Pubkey]
+	if summary == nil {
+		if len(p.authors) >= maxAuthors {
+			p.authors = make(map[string]*AuthorSummary)
+		}
+		summary = &AuthorSummary{Pubkey: r.Pubkey}
+		p.authors[r.Pubkey] = summary
+	}
+	summary.Scored++
+	summary.total += r.Score
+	summary.MeanScore = summary.total / float64(summary.Scored)
+	if r.Score > summary.MaxScore {
+		summary.MaxScore = r.Score
+	}
+	if r.Action == "deleted" {
+		summary.Deleted++
+		if p.cfg.ShadowBanAfter > 0 && summary.Deleted >= p.cfg.ShadowBanAfter && !p.shadowBanned[r.Pubkey] {
+			p.shadowBanned[r.Pubkey] = true
+			metrics.ContentScoring.WithLabelValues("shadow_banned").Inc()
+			logger.New("scoring").Info("Pubkey shadow-banned by content scoring",
+				zap.String("pubkey", r.Pubkey[:16]+"..."),
+				zap.Int("deleted_events", summary.Deleted))
+			if p.onBan != nil {
+				go p.onBan(r.Pubkey, summary.Deleted)
+			}
+		}
+	}
+}
+
+// IsShadowBanned reports whether a pubkey's events are silently discarded.
+func (p *Pipeline) IsShadowBanned(pubkey string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.shadowBanned[strings.ToLower(pubkey)]
+}
+
+// ShadowBan adds a pubkey to the shadow-ban list.
+func (p *Pipeline) ShadowBan(pubkey string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.shadowBanned[strings.ToLower(pubkey)] = true
+}
+
+// LiftShadowBan removes a pubkey from the shadow-ban list and resets its deleted count.
+func (p *Pipeline) LiftShadowBan(pubkey string) {
+	pubkey = strings.ToLower(pubkey)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.shadowBanned, pubkey)
+	if summary := p.authors[pubkey]; summary != nil {
+		summary.Deleted = 0
+	}
+}
+
+// ShadowBanned lists shadow-banned pubkeys in sorted order.
+func (p *Pipeline) ShadowBanned() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	pubkeys := make([]string, 0, len(p.shadowBanned))
+	for pubkey := range p.shadowBanned {
+		pubkeys = append(pubkeys, pubkey)
+	}
+	sort.Strings(pubkeys)
+	return pubkeys
+}
+
+// RecentScores returns up to limit of the most recent scores, newest first,
+// optionally only those at or above minScore.
+func (p *Pipeline) RecentScores(limit int, minScore float64) []Record {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	records := make([]Record, 0, min(limit, len(p.records)))
+	for i := len(p.records) - 1; i >= 0 && len(records) < limit; i-- {
+		if p.records[i].Score >= minScore {
+			records = append(records, p.records[i])
+		}
+	}
+	return records
+}
+
+// AuthorScore returns the score summary of a pubkey, or nil if none of its events were scored.
+func (p *Pipeline) AuthorScore(pubkey string) *AuthorSummary {
+	pubkey = strings.ToLower(pubkey)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	summary := p.authors[pubkey]
+	if summary == nil {
+		if !p.shadowBanned[pubkey] {
+			return nil
+		}
+		return &AuthorSummary{Pubkey: pubkey, ShadowBanned: true}
+	}
+	copied := *summary
+	copied.ShadowBanned = p.shadowBanned[pubkey]
+	return &copied
+}
@@ -0,0 +1,230 @@
+// Package signer signs the events the relay publishes under its own key:
+// NIP-29 group metadata, NIP-43 membership events and invites. The key can
+// come from the config, an environment-injected secret, a NIP-49 encrypted
+// keystore, or stay in an external NIP-46 bunker so it never touches the
+// relay's disk.
+package signer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip46"
+)
+
+// Key sources of RELAY.SIGNER.SOURCE.
+const (
+	SourceConfig   = "config"
+	SourceEnv      = "env"
+	SourceKeystore = "keystore"
+	SourceBunker   = "bunker"
+)
+
+// defaultTimeout bounds bunker requests when RELAY.SIGNER.TIMEOUT is unset.
+const defaultTimeout = 10 * time.Second
+
+// keystoreLogN is the scrypt cost of keys written to the keystore.
+const keystoreLogN = 16
+
+// Signer signs events as the relay.
+type Signer interface {
+	// PublicKey returns the hex public key events are signed with.
+	PublicKey() string
+	// Sign sets the pubkey, ID and signature of evt.
+	Sign(ctx context.Context, evt *nostr.Event) error
+}
+
+// Rotator is a signer whose key the relay can replace.
+type Rotator interface {
+	Signer
+	// Rotate generates and persists a new key, returning its signer. The
+	// rotated signer keeps signing with the old key, so it can still sign
+	// the transition to the new one.
+	Rotate() (Signer, error)
+}
+
+// New returns the signer of the configured source, or nil when the source
+// needs no signer: the config source with no private key set.
+func New(ctx context.Context, cfg config.RelayConfig) (Signer, error) {
+	sc := cfg.Signer
+	switch sc.Source {
+	case "", SourceConfig:
+		if cfg.PrivateKey == "" {
+			return nil, nil
+		}
+		return NewKeySigner(cfg.PrivateKey)
+	case SourceEnv:
+		if sc.Env == "" {
+			return nil, fmt.Errorf("signer source env needs RELAY.SIGNER.ENV")
+		}
+		secret := os.Getenv(sc.Env)
+		if secret == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", sc.Env)
+		}
+		// Keep the key out of the environment of anything started later
+		_ = os.Unsetenv(sc.Env)
+		return NewKeySigner(secret)
+	case SourceKeystore:
+		return openKeystore(sc.Keystore, sc.PasswordEnv)
+	case SourceBunker:
+		timeout := sc.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		return NewBunkerSigner(ctx, sc.BunkerURL, timeout)
+	default:
+		return nil, fmt.Errorf("unknown signer source %q", sc.Source)
+	}
+}
+
+// keySigner signs with a private key held in memory.
+type keySigner struct {
+	sk     string
+	pubkey string
+}
+
+// NewKeySigner returns a signer for a hex or nsec private key.
+func NewKeySigner(key string) (Signer, error) {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "nsec1") {
+		sk, err := nips.DecodeNsec(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nsec: %w", err)
+		}
+		key = sk
+	}
+	pubkey, err := nostr.GetPublicKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return &keySigner{sk: key, pubkey: pubkey}, nil
+}
+
+func (s *keySigner) PublicKey() string {
+	return s.pubkey
+}
+
+func (s *keySigner) Sign(_ context.Context, evt *nostr.Event) error {
+	evt.PubKey = s.pubkey
+	return evt.Sign(s.sk)
+}
+
+// keystoreSigner signs with a key decrypted from a NIP-49 keystore file,
+// keeping the password to write rotated keys back.
+type keystoreSigner struct {
+	*keySigner
+	path     string
+	password string
+}
+
+// openKeystore decrypts the ncryptsec in the file at path with the password
+// in the environment variable passwordEnv.
+func openKeystore(path, passwordEnv string) (Signer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("signer source keystore needs RELAY.SIGNER.KEYSTORE")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading keystore: %w", err)
+	}
+	var password string
+	if passwordEnv != "" {
+		password = os.Getenv(passwordEnv)
+		_ = os.Unsetenv(passwordEnv)
+	}
+	sk, err := nips.DecryptNcryptsec(strings.TrimSpace(string(data)), password)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting keystore %s: %w", path, err)
+	}
+	s, err := NewKeySigner(sk)
+	if err != nil {
+		return nil, err
+	}
+	return &keystoreSigner{keySigner: s.(*keySigner), path: path, password: password}, nil
+}
+
+// Rotate writes a new key to the keystore, replacing the file atomically.
+func (s *keystoreSigner) Rotate() (Signer, error) {
+	next, err := NewKeySigner(nostr.GeneratePrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := nips.EncryptNcryptsec(next.(*keySigner).sk, s.password, keystoreLogN)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting new key: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".keystore-*")
+	if err != nil {
+		return nil, fmt.Errorf("writing keystore: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(encrypted + "\n"); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("writing keystore: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("writing keystore: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return nil, fmt.Errorf("replacing keystore: %w", err)
+	}
+	return &keystoreSigner{keySigner: next.(*keySigner), path: s.path, password: s.password}, nil
+}
+
+// bunkerSigner asks a NIP-46 remote signer to sign.
+type bunkerSigner struct {
+	client  *nip46.BunkerClient
+	pubkey  string
+	timeout time.Duration
+}
+
+// NewBunkerSigner connects to the bunker:// URL with an ephemeral client key
+// and fetches the public key it signs with. ctx bounds the lifetime of the
+// bunker's relay subscriptions; each request is bounded by timeout.
+func NewBunkerSigner(ctx context.Context, bunkerURL string, timeout time.Duration) (Signer, error) {
+	if bunkerURL == "" {
+		return nil, fmt.Errorf("signer source bunker needs RELAY.SIGNER.BUNKER_URL")
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client, err := nip46.ConnectBunker(connectCtx, nostr.GeneratePrivateKey(), bunkerURL, nostr.NewSimplePool(ctx), nil)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to bunker: %w", err)
+	}
+	pubkey, err := client.GetPublicKey(connectCtx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching bunker public key: %w", err)
+	}
+	if !nostr.IsValidPublicKey(pubkey) {
+		return nil, fmt.Errorf("bunker returned invalid public key %q", pubkey)
+	}
+	return &bunkerSigner{client: client, pubkey: pubkey, timeout: timeout}, nil
+}
+
+func (s *bunkerSigner) PublicKey() string {
+	return s.pubkey
+}
+
+func (s *bunkerSigner) Sign(ctx context.Context, evt *nostr.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	evt.PubKey = s.pubkey
+	if err := s.client.SignEvent(ctx, evt); err != nil {
+		return fmt.Errorf("bunker sign_event: %w", err)
+	}
+	// The bunker checks nothing against our key; make sure it used it
+	if evt.PubKey != s.pubkey {
+		return fmt.Errorf("bunker signed with %s instead of %s", evt.PubKey, s.pubkey)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Kafka is reached through a Confluent-compatible REST Proxy (v2 API), one
+// produce request per topic and batch. Records are keyed by author pubkey so
+// each author's events stay ordered within a partition.
+
+const (
+	kafkaContentType = "application/vnd.kafka.json.v2+json"
+	kafkaAccept      = "application/vnd.kafka.v2+json"
+	// defaultKafkaTimeout is used when SINK.KAFKA.TIMEOUT is unset.
+	defaultKafkaTimeout = 10 * time.Second
+)
+
+// kafkaRecord is a record of a produce request.
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// kafkaProduceResponse is the REST Proxy's answer to a produce request.
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition int     `json:"partition"`
+		Offset    int64   `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+// kafkaPublisher produces events through a Kafka REST Proxy.
+type kafkaPublisher struct {
+	cfg    config.SinkKafkaConfig
+	base   string
+	client *http.Client
+}
+
+func newKafkaPublisher(cfg config.SinkKafkaConfig) *kafkaPublisher {
+	if cfg.Topic == "" {
+		cfg.Topic = "nostr-events-{kind}"
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultKafkaTimeout
+	}
+	return &kafkaPublisher{
+		cfg:    cfg,
+		base:   strings.TrimRight(cfg.RestURL, "/"),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Publish implements publisher, producing the batch topic by topic.
+func (p *kafkaPublisher) Publish(ctx context.Context, events []nostr.Event) error {
+	var topics []string
+	records := make(map[string][]kafkaRecord)
+	for i := range events {
+		value, err := json.Marshal(&events[i])
+		if err != nil {
+			continue
+		}
+		topic := topicFor(p.cfg.Topic, events[i].Kind)
+		if _, ok := records[topic]; !ok {
+			topics = append(topics, topic)
+		}
+		records[topic] = append(records[topic], kafkaRecord{Key: events[i].PubKey, Value: value})
+	}
+
+	for _, topic := range topics {
+		if err := p.produce(ctx, topic, records[topic]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// produce sends one produce request and checks every record was written.
+func (p *kafkaPublisher) produce(ctx context.Context, topic string, records []kafkaRecord) error {
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		p.base+"/topics/"+url.PathEscape(topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", kafkaAccept)
+	if p.cfg.Username != "" {
+		req.SetBasicAuth(p.cfg.Username, p.cfg.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka produce to %s: %w", topic, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kafka produce to %s: status %d: %s", topic, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var result kafkaProduceResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("kafka produce to %s: invalid response: %w", topic, err)
+	}
+	for _, o := range result.Offsets {
+		if o.ErrorCode != nil || o.Error != nil {
+			msg := "unknown error"
+			if o.Error != nil {
+				msg = *o.Error
+			}
+			return fmt.Errorf("kafka produce to %s: partition %d: %s", topic, o.Partition, msg)
+		}
+	}
+	return nil
+}
+
+// Close implements publisher.
+func (p *kafkaPublisher) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
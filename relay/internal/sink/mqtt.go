@@ -0,0 +1,384 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// A minimal MQTT 3.1.1 client: it connects with a clean session and publishes
+// at QoS 0 or 1, which is all the sink needs.
+
+// MQTT control packet types.
+const (
+	mqttConnect    = 1
+	mqttConnAck    = 2
+	mqttPublish    = 3
+	mqttPubAck     = 4
+	mqttPingReq    = 12
+	mqttDisconnect = 14
+)
+
+const (
+	// mqttTimeout bounds connecting and waiting for the PUBACKs of a batch.
+	mqttTimeout = 10 * time.Second
+	// defaultMQTTKeepAlive is used when SINK.MQTT.KEEP_ALIVE is unset.
+	defaultMQTTKeepAlive = 30 * time.Second
+	// maxMQTTPacket bounds packets read from the broker; it only sends acks.
+	maxMQTTPacket = 1 << 16
+)
+
+// connAckErrors describes the CONNACK return codes of a refused connection.
+var connAckErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// mqttPublisher publishes events to an MQTT broker, reconnecting as needed.
+type mqttPublisher struct {
+	cfg      config.SinkMQTTConfig
+	addr     string
+	tls      *tls.Config
+	clientID string
+	conn     *mqttConn
+	nextID   uint16
+}
+
+// mqttConn is one broker connection. A reader goroutine collects PUBACKs and a
+// keep-alive goroutine pings the broker while the sink is idle.
+type mqttConn struct {
+	conn      net.Conn
+	writeMu   sync.Mutex
+	lastWrite time.Time
+	acks      chan uint16
+	dead      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+func newMQTTPublisher(cfg config.SinkMQTTConfig) (*mqttPublisher, error) {
+	u, err := url.Parse(cfg.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SINK.MQTT.BROKER: %w", err)
+	}
+	p := &mqttPublisher{cfg: cfg, addr: u.Host, clientID: cfg.ClientID}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		if u.Port() == "" {
+			p.addr = net.JoinHostPort(u.Hostname(), "1883")
+		}
+	case "ssl", "tls", "mqtts":
+		if u.Port() == "" {
+			p.addr = net.JoinHostPort(u.Hostname(), "8883")
+		}
+		p.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("unsupported SINK.MQTT.BROKER scheme %q (use tcp or ssl)", u.Scheme)
+	}
+	if p.clientID == "" {
+		b := make([]byte, 6)
+		_, _ = rand.Read(b)
+		p.clientID = "shugur-relay-" + hex.EncodeToString(b)
+	}
+	if p.cfg.Topic == "" {
+		p.cfg.Topic = "nostr/events/{kind}"
+	}
+	if p.cfg.KeepAlive <= 0 {
+		p.cfg.KeepAlive = defaultMQTTKeepAlive
+	}
+	return p, nil
+}
+
+// Publish implements publisher. At QoS 1 it waits for every PUBACK; any
+// failure drops the connection so the retry starts on a fresh one.
+func (p *mqttPublisher) Publish(ctx context.Context, events []nostr.Event) error {
+	if p.conn == nil || p.conn.closed() {
+		c, err := p.connect(ctx)
+		if err != nil {
+			return err
+		}
+		p.conn = c
+	}
+	c := p.conn
+
+	pending := make(map[uint16]bool, len(events))
+	for i := range events {
+		payload, err := json.Marshal(&events[i])
+		if err != nil {
+			continue
+		}
+		var id uint16
+		if p.cfg.QoS > 0 {
+			id = p.packetID()
+			pending[id] = true
+		}
+		if err := c.write(publishPacket(topicFor(p.cfg.Topic, events[i].Kind), payload, p.cfg.QoS, id)); err != nil {
+			c.close(err)
+			return fmt.Errorf("mqtt publish: %w", err)
+		}
+	}
+
+	timeout := time.NewTimer(mqttTimeout)
+	defer timeout.Stop()
+	for len(pending) > 0 {
+		select {
+		case id := <-c.acks:
+			delete(pending, id)
+		case <-c.dead:
+			return fmt.Errorf("mqtt connection lost: %w", c.err)
+		case <-timeout.C:
+			c.close(fmt.Errorf("timed out waiting for PUBACK"))
+			return fmt.Errorf("mqtt: %d of %d events not acknowledged", len(pending), len(events))
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close implements publisher.
+func (p *mqttPublisher) Close() error {
+	if p.conn == nil || p.conn.closed() {
+		return nil
+	}
+	err := p.conn.write([]byte{mqttDisconnect << 4, 0})
+	p.conn.close(io.EOF)
+	return err
+}
+
+// packetID returns the next non-zero packet identifier.
+func (p *mqttPublisher) packetID() uint16 {
+	p.nextID++
+	if p.nextID == 0 {
+		p.nextID = 1
+	}
+	return p.nextID
+}
+
+// connect dials the broker and completes the CONNECT handshake.
+func (p *mqttPublisher) connect(ctx context.Context) (*mqttConn, error) {
+	ctx, cancel := context.WithTimeout(ctx, mqttTimeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{}
+	if p.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: p.tls}).DialContext(ctx, "tcp", p.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", p.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mqtt connect: %w", err)
+	}
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	r := bufio.NewReader(conn)
+	if _, err := conn.Write(p.connectPacket()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt connect: %w", err)
+	}
+	typ, body, err := readMQTTPacket(r)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt connect: %w", err)
+	}
+	if typ != mqttConnAck || len(body) != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt connect: unexpected packet type %d", typ)
+	}
+	if code := body[1]; code != 0 {
+		conn.Close()
+		if msg, ok := connAckErrors[code]; ok {
+			return nil, fmt.Errorf("mqtt connect refused: %s", msg)
+		}
+		return nil, fmt.Errorf("mqtt connect refused: code %d", code)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	c := &mqttConn{
+		conn:      conn,
+		lastWrite: time.Now(),
+		acks:      make(chan uint16, 1024),
+		dead:      make(chan struct{}),
+	}
+	go c.read(r)
+	go c.keepAlive(p.cfg.KeepAlive)
+	return c, nil
+}
+
+// connectPacket builds the CONNECT packet.
+func (p *mqttPublisher) connectPacket() []byte {
+	flags := byte(0x02) // clean session
+	if p.cfg.Username != "" {
+		flags |= 0x80
+		if p.cfg.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body := appendMQTTString(nil, "MQTT")
+	body = append(body, 4, flags) // protocol level 3.1.1
+	body = binary.BigEndian.AppendUint16(body, uint16(p.cfg.KeepAlive/time.Second))
+	body = appendMQTTString(body, p.clientID)
+	if flags&0x80 != 0 {
+		body = appendMQTTString(body, p.cfg.Username)
+	}
+	if flags&0x40 != 0 {
+		body = appendMQTTString(body, p.cfg.Password)
+	}
+	return mqttPacket(mqttConnect<<4, body)
+}
+
+// publishPacket builds a PUBLISH packet; id is only sent at QoS 1.
+func publishPacket(topic string, payload []byte, qos int, id uint16) []byte {
+	body := appendMQTTString(make([]byte, 0, len(topic)+len(payload)+4), topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	body = append(body, payload...)
+	return mqttPacket(mqttPublish<<4|byte(qos)<<1, body)
+}
+
+// write sends a packet.
+func (c *mqttConn) write(packet []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(mqttTimeout))
+	_, err := c.conn.Write(packet)
+	c.lastWrite = time.Now()
+	return err
+}
+
+// read forwards PUBACKs until the connection fails.
+func (c *mqttConn) read(r *bufio.Reader) {
+	for {
+		typ, body, err := readMQTTPacket(r)
+		if err != nil {
+			c.close(err)
+			return
+		}
+		if typ == mqttPubAck && len(body) == 2 {
+			select {
+			case c.acks <- binary.BigEndian.Uint16(body):
+			case <-c.dead:
+				return
+			}
+		}
+	}
+}
+
+// keepAlive sends PINGREQ when nothing was written for half the keep-alive
+// interval, so the broker does not drop an idle connection.
+func (c *mqttConn) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.dead:
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			idle := time.Since(c.lastWrite)
+			c.writeMu.Unlock()
+			if idle < interval/2 {
+				continue
+			}
+			if err := c.write([]byte{mqttPingReq << 4, 0}); err != nil {
+				c.close(err)
+				return
+			}
+		}
+	}
+}
+
+// close shuts the connection down once, recording why.
+func (c *mqttConn) close(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.dead)
+		c.conn.Close()
+	})
+}
+
+// closed reports whether the connection has been shut down.
+func (c *mqttConn) closed() bool {
+	select {
+	case <-c.dead:
+		return true
+	default:
+		return false
+	}
+}
+
+// mqttPacket prefixes body with the fixed header.
+func mqttPacket(header byte, body []byte) []byte {
+	b := make([]byte, 0, len(body)+5)
+	b = append(b, header)
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, body...)
+}
+
+// appendMQTTString appends a length-prefixed UTF-8 string.
+func appendMQTTString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// readMQTTPacket reads one packet and returns its type and body.
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	size, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, fmt.Errorf("malformed remaining length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	if size > maxMQTTPacket {
+		return 0, nil, fmt.Errorf("packet of %d bytes from broker", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
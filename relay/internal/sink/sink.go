@@ -0,0 +1,236 @@
+// Package sink streams accepted events to a message broker, so downstream
+// analytics and search pipelines can consume the relay without holding a
+// websocket subscription. Events are published as NIP-01 JSON to one topic per
+// kind, over MQTT 3.1.1 or through a Kafka REST Proxy.
+//
+// Delivery is at least once while the relay runs: a batch is retried with
+// exponential backoff until the broker acknowledges it, and events queued at
+// shutdown get one final attempt. When the broker stays down the queue fills
+// and new events are dropped and counted rather than slowing the relay.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Defaults for settings left unset.
+const (
+	defaultQueueSize     = 10000
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+	defaultRetryDelay    = time.Second
+	defaultMaxRetryDelay = time.Minute
+)
+
+// shutdownTimeout bounds the final delivery of queued events at shutdown.
+const shutdownTimeout = 5 * time.Second
+
+// publisher delivers batches of events to a broker.
+type publisher interface {
+	// Publish returns nil once every event of the batch was accepted by the broker.
+	Publish(ctx context.Context, events []nostr.Event) error
+	Close() error
+}
+
+// Sink queues accepted events and publishes them in batches.
+type Sink struct {
+	cfg   config.SinkConfig
+	kinds map[int]bool
+	queue chan nostr.Event
+	pub   publisher
+	log   *zap.Logger
+}
+
+// New creates a sink for the configured driver.
+func New(cfg config.SinkConfig) (*Sink, error) {
+	var pub publisher
+	switch cfg.Driver {
+	case "", "mqtt":
+		if cfg.MQTT.Broker == "" {
+			return nil, fmt.Errorf("SINK.MQTT.BROKER is required for the mqtt driver")
+		}
+		p, err := newMQTTPublisher(cfg.MQTT)
+		if err != nil {
+			return nil, err
+		}
+		pub = p
+	case "kafka":
+		if cfg.Kafka.RestURL == "" {
+			return nil, fmt.Errorf("SINK.KAFKA.REST_URL is required for the kafka driver")
+		}
+		pub = newKafkaPublisher(cfg.Kafka)
+	default:
+		return nil, fmt.Errorf("unknown sink driver %q", cfg.Driver)
+	}
+
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = defaultRetryDelay
+	}
+	if cfg.MaxRetryDelay < cfg.RetryDelay {
+		cfg.MaxRetryDelay = defaultMaxRetryDelay
+	}
+
+	s := &Sink{
+		cfg:   cfg,
+		kinds: make(map[int]bool, len(cfg.Kinds)),
+		queue: make(chan nostr.Event, cfg.QueueSize),
+		pub:   pub,
+		log:   logger.New("sink"),
+	}
+	for _, kind := range cfg.Kinds {
+		s.kinds[kind] = true
+	}
+	return s, nil
+}
+
+// Start launches the publishing goroutine. It exits when ctx is canceled,
+// after a final attempt to deliver the queued events.
+func (s *Sink) Start(ctx context.Context) {
+	go s.run(ctx)
+	s.log.Info("Event sink started",
+		zap.String("driver", s.driver()),
+		zap.Ints("kinds", s.cfg.Kinds),
+		zap.Int("batch_size", s.cfg.BatchSize))
+}
+
+// Submit queues an accepted event for publishing without blocking.
+func (s *Sink) Submit(evt *nostr.Event) {
+	if evt == nil {
+		return
+	}
+	if nips.IsEphemeral(evt.Kind) && !s.cfg.IncludeEphemeral {
+		return
+	}
+	if len(s.kinds) > 0 && !s.kinds[evt.Kind] {
+		return
+	}
+	select {
+	case s.queue <- *evt:
+	default:
+		metrics.SinkEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+// run collects events into batches and delivers them.
+func (s *Sink) run(ctx context.Context) {
+	defer func() {
+		if err := s.pub.Close(); err != nil {
+			s.log.Debug("Closing event sink failed", zap.Error(err))
+		}
+	}()
+
+	flush := time.NewTicker(s.cfg.FlushInterval)
+	defer flush.Stop()
+
+	batch := make([]nostr.Event, 0, s.cfg.BatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			s.drain(batch)
+			return
+		case evt := <-s.queue:
+			batch = append(batch, evt)
+			if len(batch) < s.cfg.BatchSize {
+				continue
+			}
+		case <-flush.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		s.deliver(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+// deliver publishes a batch, retrying with exponential backoff until it is
+// acknowledged or ctx is canceled.
+func (s *Sink) deliver(ctx context.Context, batch []nostr.Event) {
+	delay := s.cfg.RetryDelay
+	for {
+		err := s.pub.Publish(ctx, batch)
+		if err == nil {
+			metrics.SinkEvents.WithLabelValues("published").Add(float64(len(batch)))
+			return
+		}
+		metrics.SinkEvents.WithLabelValues("retried").Add(float64(len(batch)))
+		s.log.Warn("Event sink publish failed, retrying",
+			zap.Int("events", len(batch)),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			s.drain(batch)
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > s.cfg.MaxRetryDelay {
+			delay = s.cfg.MaxRetryDelay
+		}
+	}
+}
+
+// drain makes one last attempt to deliver batch and the queued events at
+// shutdown. Events it cannot deliver are counted as dropped.
+func (s *Sink) drain(batch []nostr.Event) {
+	pending := append([]nostr.Event(nil), batch...)
+queued:
+	for {
+		select {
+		case evt := <-s.queue:
+			pending = append(pending, evt)
+		default:
+			break queued
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for len(pending) > 0 {
+		n := min(len(pending), s.cfg.BatchSize)
+		if err := s.pub.Publish(ctx, pending[:n]); err != nil {
+			metrics.SinkEvents.WithLabelValues("dropped").Add(float64(len(pending)))
+			s.log.Warn("Event sink stopped with undelivered events",
+				zap.Int("events", len(pending)),
+				zap.Error(err))
+			return
+		}
+		metrics.SinkEvents.WithLabelValues("published").Add(float64(n))
+		pending = pending[n:]
+	}
+}
+
+// driver returns the configured driver name.
+func (s *Sink) driver() string {
+	if s.cfg.Driver == "" {
+		return "mqtt"
+	}
+	return s.cfg.Driver
+}
+
+// topicFor expands the {kind} placeholder of a topic template.
+func topicFor(template string, kind int) string {
+	return strings.ReplaceAll(template, "{kind}", strconv.Itoa(kind))
+}
@@ -0,0 +1,382 @@
+// Package spamcluster detects bursts of nearly identical notes posted by many
+// pubkeys. Notes are normalized and fingerprinted with a 64-bit SimHash; notes
+// whose fingerprints differ in only a few bits join the same cluster, and a
+// cluster reaching enough distinct pubkeys within the window is flagged so its
+// further copies can be throttled or shadow rejected.
+package spamcluster
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/time/rate"
+)
+
+// Actions on events of a flagged cluster.
+const (
+	ActionThrottle     = "throttle"
+	ActionShadowReject = "shadow_reject"
+)
+
+const (
+	// bands splits fingerprints into 16-bit bands for candidate lookup; two
+	// fingerprints within 3 bits of each other share at least one band.
+	bands = 4
+	// shingleSize is the number of words hashed together as one feature.
+	shingleSize = 3
+	// sampleLength bounds the content kept to show what a cluster is about.
+	sampleLength = 200
+	// maxClusterPubkeys bounds the pubkeys remembered per cluster.
+	maxClusterPubkeys = 10000
+)
+
+// urlPattern matches links and nostr: references, which spammers vary
+// between copies.
+var urlPattern = regexp.MustCompile(`(?i)(https?://|nostr:|www\.)\S+`)
+
+// Cluster describes a group of near-duplicate notes.
+type Cluster struct {
+	ID        string    `json:"id"`      // fingerprint of the first note, hex
+	Sample    string    `json:"sample"`  // content of the first note, truncated
+	Events    int64     `json:"events"`  // notes matched, including dropped ones
+	Pubkeys   int       `json:"pubkeys"` // distinct pubkeys within the window
+	Dropped   int64     `json:"dropped"` // notes throttled or shadow rejected
+	Flagged   bool      `json:"flagged"`
+	Dismissed bool      `json:"dismissed"` // flag lifted by an admin
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	FlaggedAt time.Time `json:"flagged_at,omitempty"`
+}
+
+// cluster is a tracked Cluster with its fingerprint and pubkeys.
+type cluster struct {
+	Cluster
+	fingerprint uint64
+	pubkeys     map[string]time.Time // pubkey -> last copy
+	limiter     *rate.Limiter        // events let through while throttled
+}
+
+// Decision is the outcome of checking one event.
+type Decision struct {
+	Action    string // "" when the event passes
+	ClusterID string // cluster the event joined, "" when not clustered
+}
+
+// Detector groups incoming notes into near-duplicate clusters.
+type Detector struct {
+	cfg   config.SpamClustersConfig
+	kinds map[int]bool
+
+	mu        sync.Mutex
+	clusters  map[string]*cluster
+	index     [bands]map[uint16][]*cluster
+	lastPrune time.Time
+	onFlag    func(Cluster)
+}
+
+// New creates a detector, filling in defaults for unset limits.
+func New(cfg config.SpamClustersConfig) *Detector {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Minute
+	}
+	if cfg.MinPubkeys <= 0 {
+		cfg.MinPubkeys = 5
+	}
+	if cfg.MaxClusters <= 0 {
+		cfg.MaxClusters = 10000
+	}
+	if cfg.Action == "" {
+		cfg.Action = ActionShadowReject
+	}
+
+	d := &Detector{
+		cfg:      cfg,
+		clusters: make(map[string]*cluster),
+	}
+	if len(cfg.Kinds) > 0 {
+		d.kinds = make(map[int]bool, len(cfg.Kinds))
+		for _, k := range cfg.Kinds {
+			d.kinds[k] = true
+		}
+	}
+	for i := range d.index {
+		d.index[i] = make(map[uint16][]*cluster)
+	}
+	return d
+}
+
+// SetFlagHook sets a function called when a cluster is flagged.
+func (d *Detector) SetFlagHook(fn func(Cluster)) {
+	d.mu.Lock()
+	d.onFlag = fn
+	d.mu.Unlock()
+}
+
+// Check adds evt to its cluster and returns the action to take on it.
+func (d *Detector) Check(evt *nostr.Event) Decision {
+	if d.kinds != nil && !d.kinds[evt.Kind] {
+		return Decision{}
+	}
+	text := Normalize(evt.Content)
+	if len(text) < d.cfg.MinLength || text == "" {
+		return Decision{}
+	}
+	fp := Fingerprint(text)
+	now := time.Now()
+
+	d.mu.Lock()
+	if now.Sub(d.lastPrune) >= d.cfg.Window/4 {
+		d.pruneLocked(now)
+	}
+	c := d.matchLocked(fp)
+	if c == nil {
+		c = d.addLocked(fp, evt.Content, now)
+	}
+	c.Events++
+	c.LastSeen = now
+	if _, ok := c.pubkeys[evt.PubKey]; ok || len(c.pubkeys) < maxClusterPubkeys {
+		c.pubkeys[evt.PubKey] = now
+	}
+	c.Pubkeys = d.recentPubkeysLocked(c, now)
+
+	var flagged *Cluster
+	if !c.Flagged && !c.Dismissed && c.Pubkeys >= d.cfg.MinPubkeys {
+		c.Flagged, c.FlaggedAt = true, now
+		if d.cfg.Action == ActionThrottle {
+			per := d.cfg.ThrottlePerMinute
+			c.limiter = rate.NewLimiter(rate.Limit(float64(per)/60), per)
+		}
+		snapshot := c.Cluster
+		flagged = &snapshot
+	}
+
+	decision := Decision{ClusterID: c.ID}
+	if c.Flagged && !c.Dismissed && (c.limiter == nil || !c.limiter.Allow()) {
+		c.Dropped++
+		decision.Action = d.cfg.Action
+	}
+	onFlag := d.onFlag
+	d.mu.Unlock()
+
+	if flagged != nil && onFlag != nil {
+		onFlag(*flagged)
+	}
+	return decision
+}
+
+// matchLocked returns the cluster closest to fp within the distance, or nil.
+func (d *Detector) matchLocked(fp uint64) *cluster {
+	var best *cluster
+	bestDist := d.cfg.Distance + 1
+	for i := 0; i < bands; i++ {
+		for _, c := range d.index[i][band(fp, i)] {
+			if dist := bits.OnesCount64(fp ^ c.fingerprint); dist < bestDist {
+				best, bestDist = c, dist
+			}
+		}
+	}
+	return best
+}
+
+// addLocked starts a cluster for fp, evicting the least recently seen
+// cluster when the detector is full.
+func (d *Detector) addLocked(fp uint64, content string, now time.Time) *cluster {
+	if len(d.clusters) >= d.cfg.MaxClusters {
+		var oldest *cluster
+		for _, c := range d.clusters {
+			if oldest == nil || c.LastSeen.Before(oldest.LastSeen) {
+				oldest = c
+			}
+		}
+		d.removeLocked(oldest)
+	}
+
+	id := fmt.Sprintf("%016x", fp)
+	if len(content) > sampleLength {
+		content = strings.ToValidUTF8(content[:sampleLength], "") + "…"
+	}
+	c := &cluster{
+		Cluster: Cluster{
+			ID:        id,
+			Sample:    content,
+			FirstSeen: now,
+		},
+		fingerprint: fp,
+		pubkeys:     make(map[string]time.Time),
+	}
+	d.clusters[id] = c
+	for i := 0; i < bands; i++ {
+		b := band(fp, i)
+		d.index[i][b] = append(d.index[i][b], c)
+	}
+	return c
+}
+
+// removeLocked forgets c.
+func (d *Detector) removeLocked(c *cluster) {
+	if c == nil {
+		return
+	}
+	delete(d.clusters, c.ID)
+	for i := 0; i < bands; i++ {
+		b := band(c.fingerprint, i)
+		list := d.index[i][b]
+		for j, other := range list {
+			if other == c {
+				list = append(list[:j], list[j+1:]...)
+				break
+			}
+		}
+		if len(list) == 0 {
+			delete(d.index[i], b)
+		} else {
+			d.index[i][b] = list
+		}
+	}
+}
+
+// recentPubkeysLocked counts the pubkeys of c seen within the window,
+// forgetting the others.
+func (d *Detector) recentPubkeysLocked(c *cluster, now time.Time) int {
+	for pk, seen := range c.pubkeys {
+		if now.Sub(seen) >= d.cfg.Window {
+			delete(c.pubkeys, pk)
+		}
+	}
+	return len(c.pubkeys)
+}
+
+// pruneLocked drops clusters not seen within the window; a flagged cluster
+// that goes quiet is forgotten with them.
+func (d *Detector) pruneLocked(now time.Time) {
+	d.lastPrune = now
+	for _, c := range d.clusters {
+		if now.Sub(c.LastSeen) >= d.cfg.Window {
+			d.removeLocked(c)
+		}
+	}
+}
+
+// Dismiss lifts the flag of a cluster so its notes pass again, for false
+// positives. It reports whether the cluster exists.
+func (d *Detector) Dismiss(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.clusters[strings.ToLower(id)]
+	if c == nil {
+		return false
+	}
+	c.Dismissed = true
+	return true
+}
+
+// Snapshot returns the number of tracked and flagged clusters and up to limit
+// clusters, flagged ones first, then by distinct pubkeys. With flaggedOnly,
+// only flagged clusters that were not dismissed are listed.
+func (d *Detector) Snapshot(limit int, flaggedOnly bool) (int, int, []Cluster) {
+	now := time.Now()
+	d.mu.Lock()
+	d.pruneLocked(now)
+	total, flagged := len(d.clusters), 0
+	list := make([]Cluster, 0, len(d.clusters))
+	for _, c := range d.clusters {
+		active := c.Flagged && !c.Dismissed
+		if active {
+			flagged++
+		}
+		if flaggedOnly && !active {
+			continue
+		}
+		c.Pubkeys = d.recentPubkeysLocked(c, now)
+		list = append(list, c.Cluster)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		fi, fj := list[i].Flagged && !list[i].Dismissed, list[j].Flagged && !list[j].Dismissed
+		if fi != fj {
+			return fi
+		}
+		if list[i].Pubkeys != list[j].Pubkeys {
+			return list[i].Pubkeys > list[j].Pubkeys
+		}
+		return list[i].LastSeen.After(list[j].LastSeen)
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return total, flagged, list
+}
+
+// Normalize reduces content to lowercase words so trivial variations, such as
+// links, numbers, punctuation, case and spacing, do not tell copies apart.
+func Normalize(content string) string {
+	text := urlPattern.ReplaceAllString(norm.NFKC.String(content), " url ")
+	var b strings.Builder
+	b.Grow(len(text))
+	space := true
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r):
+			b.WriteRune(r)
+			space = false
+		case unicode.IsDigit(r):
+			b.WriteByte('0')
+			space = false
+		case !space:
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Fingerprint returns the SimHash of normalized text over word shingles.
+func Fingerprint(text string) uint64 {
+	words := strings.Fields(text)
+	var features []string
+	if len(words) < shingleSize {
+		features = words
+	} else {
+		for i := 0; i+shingleSize <= len(words); i++ {
+			features = append(features, strings.Join(words[i:i+shingleSize], " "))
+		}
+	}
+
+	var weights [64]int
+	h := fnv.New64a()
+	for _, f := range features {
+		h.Reset()
+		_, _ = h.Write([]byte(f))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var fp uint64
+	for i, w := range weights {
+		if w > 0 {
+			fp |= 1 << uint(i)
+		}
+	}
+	return fp
+}
+
+// band returns the i-th 16-bit band of fp.
+func band(fp uint64, i int) uint16 {
+	return uint16(fp >> (16 * uint(i)))
+}
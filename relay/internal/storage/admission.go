@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Admission control: the pool serving REQ and COUNT is sampled periodically,
+// and while queries waited longer than the threshold for a connection on
+// average, or gave up waiting, new client queries are refused instead of
+// piling up behind the ones already queued.
+
+// admission tracks whether the query pool is saturated.
+type admission struct {
+	maxWait    time.Duration
+	overloaded atomic.Bool
+}
+
+// StartAdmissionControl samples the query pool every interval until ctx is
+// canceled. A maxWait of zero leaves admission control off.
+func (db *DB) StartAdmissionControl(ctx context.Context, maxWait, interval time.Duration) {
+	if maxWait <= 0 || !db.usesPool() {
+		return
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	a := &admission{maxWait: maxWait}
+	db.admission = a
+
+	// REQ and COUNT wait on the read pool when there is one
+	pool := db.Pool
+	if db.readPool != nil {
+		pool = db.readPool
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		prev := pool.Stat()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			stat := pool.Stat()
+			a.sample(prev, stat)
+			prev = stat
+		}
+	}()
+
+	logger.Info("Query admission control enabled",
+		zap.Duration("max_wait", maxWait),
+		zap.Duration("interval", interval))
+}
+
+// sample updates the overload state from the pool activity between two stats.
+func (a *admission) sample(prev, stat *pgxpool.Stat) {
+	var wait time.Duration
+	if acquired := stat.AcquireCount() - prev.AcquireCount(); acquired > 0 {
+		wait = (stat.EmptyAcquireWaitTime() - prev.EmptyAcquireWaitTime()) / time.Duration(acquired)
+	}
+	canceled := stat.CanceledAcquireCount() - prev.CanceledAcquireCount()
+	metrics.DBPoolWait.Set(wait.Seconds())
+
+	overloaded := wait > a.maxWait || canceled > 0
+	if a.overloaded.Swap(overloaded) != overloaded {
+		if overloaded {
+			logger.Warn("Database pool saturated, shedding queries",
+				zap.Duration("avg_wait", wait),
+				zap.Int64("canceled_acquires", canceled),
+				zap.Int32("acquired_conns", stat.AcquiredConns()),
+				zap.Int32("max_conns", stat.MaxConns()))
+		} else {
+			logger.Info("Database pool recovered, accepting queries",
+				zap.Duration("avg_wait", wait))
+		}
+	}
+}
+
+// Overloaded reports whether client queries should be refused because the
+// query pool is saturated.
+func (db *DB) Overloaded() bool {
+	return db.admission != nil && db.admission.overloaded.Load()
+}
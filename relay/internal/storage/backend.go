@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Storage drivers selectable with DATABASE.DRIVER.
+const (
+	DriverPostgres = "postgres" // PostgreSQL, Aurora or CockroachDB through pgx (default)
+	DriverSQLite   = "sqlite"   // Embedded SQLite file for single-node deployments
+)
+
+// ErrUnsupportedByDriver is returned by operations the configured storage driver does not provide.
+var ErrUnsupportedByDriver = errors.New("operation not supported by the storage driver")
+
+// StorageBackend is the set of event storage operations a driver must provide.
+//
+// DB wraps a backend with the driver-independent parts of storage: the
+// duplicate Bloom filter, the query result cache and the event dispatcher.
+// It reaches the events only through its backend: postgresBackend for the
+// PostgreSQL driver (postgres.go), SQLiteBackend for the SQLite one.
+type StorageBackend interface {
+	// Writes
+	InsertEvent(ctx context.Context, evt nostr.Event) error
+	BatchInsertEvents(ctx context.Context, events []nostr.Event) error // regular events only
+	InsertReplaceableEvent(ctx context.Context, evt nostr.Event) error
+	InsertAddressableEvent(ctx context.Context, evt nostr.Event) error
+	PersistDeletion(ctx context.Context, del nostr.Event) error
+	PersistVanish(ctx context.Context, evt nostr.Event) error
+	CleanExpiredEvents(ctx context.Context) (int, error)
+	PruneOldEvents(ctx context.Context, before int64) (int, error)
+	DeleteEventByID(ctx context.Context, eventID string) error // tombstones, see tombstones.go
+
+	// Reads
+	GetEvents(ctx context.Context, filter nostr.Filter) ([]nostr.Event, error)
+	GetEventByID(ctx context.Context, eventID string) (nostr.Event, error)
+	GetReplaceableEvent(ctx context.Context, pubkey string, kind int) (nostr.Event, error)
+	GetAddressableEvent(ctx context.Context, pubkey string, kind int, dVal string) (nostr.Event, error)
+	GetEventCount(ctx context.Context, filter nostr.Filter) (int64, error)
+	GetEventPubkeys(ctx context.Context, filter nostr.Filter) ([]string, error)
+	EventExists(ctx context.Context, eventID string) (bool, error)
+	IsVanishedPubkey(ctx context.Context, pubkey string) (bool, error)
+	IsEventDeleted(ctx context.Context, eventID, pubkey string) (bool, error)
+	GetTotalEventCount(ctx context.Context) (int64, error)
+	GetPubkeyStorage(ctx context.Context, pubkey string) (PubkeyStorage, error)
+	TopStorageConsumers(ctx context.Context, limit int) ([]PubkeyStorage, error)
+	ForEachEventID(ctx context.Context, fn func(id string)) error
+	GetReceivedAt(ctx context.Context, ids []string) (map[string]int64, error) // see received_at.go
+
+	// Multi-tenant ownership (see tenants.go)
+	TagEventTenant(ctx context.Context, eventID, tenant string) error
+	GetEventTenants(ctx context.Context, eventID string) ([]string, error)
+	PruneEventTenants(ctx context.Context, taggedBefore int64) (int, error)
+
+	// NIP-86 moderation decisions (see moderation.go)
+	SaveModerationDecision(ctx context.Context, d ModerationDecision) error
+	DeleteModerationDecision(ctx context.Context, decisionType, target string) error
+	ListModerationDecisions(ctx context.Context) ([]ModerationDecision, error)
+
+	// Hosted NIP-05 names (see nip05_names.go)
+	SaveNIP05Name(ctx context.Context, n NIP05Name) error
+	DeleteNIP05Name(ctx context.Context, name string) error
+	ListNIP05Names(ctx context.Context) ([]NIP05Name, error)
+
+	// NIP-43 invite claims (see invite_claims.go)
+	SaveInviteClaim(ctx context.Context, c InviteClaim) error
+	ConsumeInviteClaim(ctx context.Context, code, pubkey string, usedAt int64) (bool, error)
+	ListInviteClaims(ctx context.Context, expiresAfter int64) ([]InviteClaim, error)
+	PruneInviteClaims(ctx context.Context, before int64) (int, error)
+
+	// Lifecycle
+	Version(ctx context.Context) (string, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// usesPool reports whether storage goes through the PostgreSQL pool, which
+// the features beyond StorageBackend (follow graph, relay hints, cluster
+// dispatch...) need.
+func (db *DB) usesPool() bool {
+	return db.Pool != nil
+}
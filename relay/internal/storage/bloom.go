@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/willf/bloom"
+)
+
+// bloomFileMagic identifies a persisted sharded Bloom filter.
+const bloomFileMagic = "SHBLOOM1"
+
+// DefaultBloomShards is the shard count used when none is configured.
+const DefaultBloomShards = 16
+
+// ShardedBloom is the duplicate-suppression Bloom filter, split by event ID
+// prefix so concurrent inserts and lookups rarely contend on the same lock.
+type ShardedBloom struct {
+	shards []*bloomShard
+}
+
+type bloomShard struct {
+	mu     sync.RWMutex
+	filter *bloom.BloomFilter
+}
+
+// NewShardedBloom creates a filter sized for n total entries at false positive rate fp.
+func NewShardedBloom(n uint, fp float64, shardCount int) *ShardedBloom {
+	if shardCount <= 0 {
+		shardCount = DefaultBloomShards
+	}
+	perShard := n / uint(shardCount)
+	if perShard == 0 {
+		perShard = 1
+	}
+	sb := &ShardedBloom{shards: make([]*bloomShard, shardCount)}
+	for i := range sb.shards {
+		sb.shards[i] = &bloomShard{filter: bloom.NewWithEstimates(perShard, fp)}
+	}
+	return sb
+}
+
+// shardFor picks a shard from the first byte of the hex event ID.
+func (sb *ShardedBloom) shardFor(id string) *bloomShard {
+	var idx int
+	if len(id) >= 2 {
+		if b, err := hex.DecodeString(id[:2]); err == nil {
+			idx = int(b[0])
+		}
+	}
+	return sb.shards[idx%len(sb.shards)]
+}
+
+// AddString adds an event ID to the filter.
+func (sb *ShardedBloom) AddString(id string) {
+	shard := sb.shardFor(id)
+	shard.mu.Lock()
+	shard.filter.AddString(id)
+	shard.mu.Unlock()
+}
+
+// Test reports whether an event ID may be in the filter.
+func (sb *ShardedBloom) Test(id []byte) bool {
+	shard := sb.shardFor(string(id))
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	return shard.filter.Test(id)
+}
+
+// ClearAll empties every shard.
+func (sb *ShardedBloom) ClearAll() {
+	for _, shard := range sb.shards {
+		shard.mu.Lock()
+		shard.filter.ClearAll()
+		shard.mu.Unlock()
+	}
+}
+
+// SaveToFile writes the filter to path atomically (temp file + rename).
+func (sb *ShardedBloom) SaveToFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create bloom directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create bloom file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	err = sb.writeTo(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write bloom file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (sb *ShardedBloom) writeTo(w io.Writer) error {
+	if _, err := io.WriteString(w, bloomFileMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(sb.shards))); err != nil {
+		return err
+	}
+	for _, shard := range sb.shards {
+		shard.mu.RLock()
+		_, err := shard.filter.WriteTo(w)
+		shard.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadShardedBloom reads a filter saved with SaveToFile. The shard count must
+// match, since IDs are routed to shards by prefix.
+func LoadShardedBloom(path string, shardCount int) (*ShardedBloom, error) {
+	if shardCount <= 0 {
+		shardCount = DefaultBloomShards
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(bloomFileMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != bloomFileMagic {
+		return nil, fmt.Errorf("not a bloom filter file: %s", path)
+	}
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, fmt.Errorf("failed to read shard count: %w", err)
+	}
+	if int(count) != shardCount {
+		return nil, fmt.Errorf("bloom file has %d shards, expected %d", count, shardCount)
+	}
+
+	sb := &ShardedBloom{shards: make([]*bloomShard, shardCount)}
+	for i := range sb.shards {
+		filter := &bloom.BloomFilter{}
+		if _, err := filter.ReadFrom(r); err != nil {
+			return nil, fmt.Errorf("failed to read bloom shard %d: %w", i, err)
+		}
+		sb.shards[i] = &bloomShard{filter: filter}
+	}
+	return sb, nil
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
@@ -50,6 +52,9 @@ type EventDispatcher struct {
 	clients     map[string]chan *nostr.Event
 	clientsMu   sync.RWMutex
 	eventBuffer chan *nostr.Event
+	cluster     *clusterDispatch // nil unless cluster dispatch is enabled
+	threads     *threadRoutes    // subscriber lists of the thread-scoped clients
+	heartbeat   atomic.Int64     // unix nanos of the last broadcast loop iteration
 	ctx         context.Context
 	cancel      context.CancelFunc
 }
@@ -62,6 +67,7 @@ func NewEventDispatcher(db *DB) *EventDispatcher {
 		db:          db,
 		clients:     make(map[string]chan *nostr.Event),
 		eventBuffer: make(chan *nostr.Event, 1000),
+		threads:     newThreadRoutes(),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
@@ -74,7 +80,9 @@ func (ed *EventDispatcher) Start() error {
 	}
 
 	logger.Info("Starting event dispatcher...")
+	ed.heartbeat.Store(time.Now().UnixNano())
 	go ed.processEvents()
+	ed.startCluster()
 	logger.Info("✅ Event dispatcher started")
 	return nil
 }
@@ -116,6 +124,7 @@ func (ed *EventDispatcher) RemoveClient(clientID string) {
 	if clientChan, exists := ed.clients[clientID]; exists {
 		close(clientChan)
 		delete(ed.clients, clientID)
+		ed.threads.removeClient(clientID)
 		logger.Debug("Removed event dispatcher client", zap.String("client_id", clientID))
 	}
 }
@@ -127,6 +136,37 @@ func (ed *EventDispatcher) GetClientCount() int {
 	return len(ed.clients)
 }
 
+// Running reports whether the dispatcher has been started and not stopped.
+func (ed *EventDispatcher) Running() bool {
+	return ed.heartbeat.Load() != 0 && ed.ctx.Err() == nil
+}
+
+// Heartbeat returns when the broadcast loop last ticked (zero before Start).
+func (ed *EventDispatcher) Heartbeat() time.Time {
+	ts := ed.heartbeat.Load()
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ts)
+}
+
+// BufferUsage returns the number of events waiting to be broadcast and the buffer capacity.
+func (ed *EventDispatcher) BufferUsage() (length, capacity int) {
+	return len(ed.eventBuffer), cap(ed.eventBuffer)
+}
+
+// Broadcast hands an event the relay produced itself to local clients only.
+// It is not stored or announced to cluster peers. Returns false when the
+// broadcast buffer is full.
+func (ed *EventDispatcher) Broadcast(evt *nostr.Event) bool {
+	select {
+	case ed.eventBuffer <- evt:
+		return true
+	default:
+		return false
+	}
+}
+
 // processEvents processes events from the buffer and broadcasts them to clients
 func (ed *EventDispatcher) processEvents() {
 	ticker := time.NewTicker(10 * time.Millisecond)
@@ -141,6 +181,7 @@ func (ed *EventDispatcher) processEvents() {
 		case event := <-ed.eventBuffer:
 			batch = append(batch, event)
 		case <-ticker.C:
+			ed.heartbeat.Store(time.Now().UnixNano())
 			if len(batch) > 0 {
 				ed.broadcastEvents(batch)
 				batch = batch[:0] // Clear batch
@@ -163,9 +204,22 @@ func (ed *EventDispatcher) broadcastEvents(events []*nostr.Event) {
 
 	ed.clientsMu.RLock()
 	defer ed.clientsMu.RUnlock()
+	ed.threads.mu.RLock()
+	defer ed.threads.mu.RUnlock()
+	countThreadEvents(events)
 
+	var routed, skipped int
 	for clientID, clientChan := range ed.clients {
+		scoped := ed.threads.scoped(clientID)
 		for _, event := range events {
+			// Thread-scoped clients only get the thread events of their roots
+			if scoped && threadKinds[event.Kind] {
+				if !ed.threads.follows(clientID, event) {
+					skipped++
+					continue
+				}
+				routed++
+			}
 			select {
 			case clientChan <- event:
 				logger.Debug("Event sent to client successfully",
@@ -179,4 +233,6 @@ func (ed *EventDispatcher) broadcastEvents(events []*nostr.Event) {
 			}
 		}
 	}
+	metrics.ThreadDeliveries.WithLabelValues("routed").Add(float64(routed))
+	metrics.ThreadDeliveries.WithLabelValues("skipped").Add(float64(skipped))
 }
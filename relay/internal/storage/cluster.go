@@ -20,8 +20,7 @@ func (db *DB) GetDatabaseInfo(ctx context.Context) (*DatabaseInfo, error) {
 		return nil, fmt.Errorf("database is not connected")
 	}
 
-	var version string
-	err := db.Pool.QueryRow(ctx, "SELECT version()").Scan(&version)
+	version, err := db.backend.Version(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query database version: %w", err)
 	}
@@ -32,6 +31,20 @@ func (db *DB) GetDatabaseInfo(ctx context.Context) (*DatabaseInfo, error) {
 	}, nil
 }
 
+// IsCockroachDB reports whether the PostgreSQL driver is connected to
+// CockroachDB, which reports crdb_version on connect.
+func (db *DB) IsCockroachDB(ctx context.Context) (bool, error) {
+	if !db.usesPool() {
+		return false, nil
+	}
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+	return conn.Conn().PgConn().ParameterStatus("crdb_version") != "", nil
+}
+
 // GetClusterHealth returns database health information
 func (db *DB) GetClusterHealth(ctx context.Context) (map[string]interface{}, error) {
 	if !db.isConnected() {
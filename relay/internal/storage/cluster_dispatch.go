@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/jackc/pgx/v5"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Cluster dispatch: every node announces the events it accepted on a
+// PostgreSQL NOTIFY channel and LISTENs for the announcements of its peers,
+// feeding remote events into the local dispatcher so subscriptions see the
+// same stream whichever node they are connected to. CockroachDB accepts
+// neither LISTEN nor pg_notify, so there the announcements go through the
+// cluster_events table instead, which every node polls (see cluster_poll.go).
+
+const (
+	// DefaultClusterChannel is the NOTIFY channel used when none is configured.
+	DefaultClusterChannel = "nostr_events"
+	// maxNotifyPayload keeps payloads under PostgreSQL's 8000-byte NOTIFY limit.
+	// Larger events are announced by ID and fetched by the receiving node.
+	maxNotifyPayload = 7900
+	// clusterReconnectDelay is the initial delay before re-establishing LISTEN.
+	clusterReconnectDelay = time.Second
+	// clusterReconnectMaxDelay caps the reconnect backoff.
+	clusterReconnectMaxDelay = 30 * time.Second
+)
+
+// clusterNotification is the NOTIFY payload exchanged between nodes.
+type clusterNotification struct {
+	Node  string       `json:"n"`
+	ID    string       `json:"id,omitempty"` // set when the event is too large to inline
+	Event *nostr.Event `json:"e,omitempty"`
+}
+
+// clusterDispatch holds the state of the cross-node event feed.
+type clusterDispatch struct {
+	channel string
+	nodeID  string
+	outbox  chan *nostr.Event
+
+	// poll is set on CockroachDB, where announcements go through the
+	// cluster_events table polled every pollInterval.
+	poll         bool
+	pollInterval time.Duration
+}
+
+// EnableClusterDispatch makes the dispatcher exchange events with the other
+// relay nodes sharing the database: through LISTEN/NOTIFY on PostgreSQL and
+// the polled cluster_events table on CockroachDB. Must be called before
+// Start. It fails unless the PostgreSQL driver is in use.
+func (ed *EventDispatcher) EnableClusterDispatch(ctx context.Context, cfg config.ClusterDispatchConfig) error {
+	if !ed.db.usesPool() {
+		return fmt.Errorf("cluster event dispatch requires the PostgreSQL driver")
+	}
+	crdb, err := ed.db.IsCockroachDB(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to detect the database server: %w", err)
+	}
+	if crdb {
+		if err := ed.db.ensureClusterEventsSchema(ctx); err != nil {
+			return err
+		}
+	}
+
+	channel := cfg.Channel
+	if channel == "" {
+		channel = DefaultClusterChannel
+	}
+	idBytes := make([]byte, 8)
+	_, _ = rand.Read(idBytes)
+	ed.cluster = &clusterDispatch{
+		channel: channel,
+		nodeID:  hex.EncodeToString(idBytes),
+		outbox:  make(chan *nostr.Event, 1000),
+
+		poll:         crdb,
+		pollInterval: cfg.PollInterval,
+	}
+	if ed.cluster.pollInterval <= 0 {
+		ed.cluster.pollInterval = defaultClusterPollInterval
+	}
+	return nil
+}
+
+// startCluster launches the publisher and the LISTEN consumer or poller.
+func (ed *EventDispatcher) startCluster() {
+	if ed.cluster == nil {
+		return
+	}
+	go ed.publishCluster()
+	if ed.cluster.poll {
+		go ed.pollCluster()
+		logger.Info("✅ Cluster event dispatch started",
+			zap.String("table", "cluster_events"),
+			zap.Duration("poll_interval", ed.cluster.pollInterval),
+			zap.String("node_id", ed.cluster.nodeID))
+		return
+	}
+	go ed.listenCluster()
+	logger.Info("✅ Cluster event dispatch started",
+		zap.String("channel", ed.cluster.channel),
+		zap.String("node_id", ed.cluster.nodeID))
+}
+
+// announce queues a locally accepted event for the other nodes without blocking.
+func (ed *EventDispatcher) announce(evt *nostr.Event) {
+	if ed.cluster == nil {
+		return
+	}
+	select {
+	case ed.cluster.outbox <- evt:
+	default:
+		metrics.ClusterDispatchEvents.WithLabelValues("dropped").Inc()
+		logger.Warn("Cluster dispatch queue full, peers will miss event",
+			zap.String("event_id", evt.ID))
+	}
+}
+
+// publishCluster sends queued events as NOTIFY payloads, or on CockroachDB
+// writes them to cluster_events.
+func (ed *EventDispatcher) publishCluster() {
+	for {
+		select {
+		case <-ed.ctx.Done():
+			return
+		case evt := <-ed.cluster.outbox:
+			if ed.cluster.poll {
+				ed.publishClusterEvent(evt)
+				continue
+			}
+			payload, err := json.Marshal(clusterNotification{Node: ed.cluster.nodeID, Event: evt})
+			if err == nil && len(payload) > maxNotifyPayload {
+				if nips.IsEphemeral(evt.Kind) {
+					// Ephemeral events are never stored, so peers could not fetch them
+					metrics.ClusterDispatchEvents.WithLabelValues("dropped").Inc()
+					continue
+				}
+				payload, err = json.Marshal(clusterNotification{Node: ed.cluster.nodeID, ID: evt.ID})
+			}
+			if err != nil {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(ed.ctx, 3*time.Second)
+			_, err = ed.db.Pool.Exec(ctx, "SELECT pg_notify($1, $2)", ed.cluster.channel, string(payload))
+			cancel()
+			if err != nil {
+				metrics.ClusterDispatchEvents.WithLabelValues("dropped").Inc()
+				logger.Warn("Failed to announce event to cluster",
+					zap.String("event_id", evt.ID),
+					zap.Error(err))
+				continue
+			}
+			metrics.ClusterDispatchEvents.WithLabelValues("sent").Inc()
+		}
+	}
+}
+
+// listenCluster holds a dedicated connection on the NOTIFY channel,
+// reconnecting with backoff until the dispatcher stops.
+func (ed *EventDispatcher) listenCluster() {
+	delay := clusterReconnectDelay
+	for {
+		err := ed.listenClusterOnce()
+		if ed.ctx.Err() != nil {
+			return
+		}
+		logger.Warn("Cluster dispatch listener disconnected, reconnecting",
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		select {
+		case <-ed.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, clusterReconnectMaxDelay)
+	}
+}
+
+// listenClusterOnce consumes notifications until the connection fails.
+func (ed *EventDispatcher) listenClusterOnce() error {
+	conn, err := ed.db.Pool.Acquire(ed.ctx)
+	if err != nil {
+		return err
+	}
+	// The connection carries LISTEN state, so it must not go back to the pool
+	defer conn.Hijack().Close(context.Background())
+
+	if _, err := conn.Exec(ed.ctx, "LISTEN "+pgx.Identifier{ed.cluster.channel}.Sanitize()); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ed.ctx)
+		if err != nil {
+			return err
+		}
+		ed.handleClusterNotification(notification.Payload)
+	}
+}
+
+// handleClusterNotification feeds a peer's event into the local dispatcher.
+func (ed *EventDispatcher) handleClusterNotification(payload string) {
+	var msg clusterNotification
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		logger.Debug("Ignoring malformed cluster notification", zap.Error(err))
+		return
+	}
+	if msg.Node == ed.cluster.nodeID {
+		return // our own announcement
+	}
+
+	evt := msg.Event
+	if evt == nil && msg.ID != "" {
+		ctx, cancel := context.WithTimeout(ed.ctx, 3*time.Second)
+		fetched, err := ed.db.GetEventByID(ctx, msg.ID)
+		cancel()
+		if err != nil {
+			logger.Debug("Failed to fetch announced event",
+				zap.String("event_id", msg.ID),
+				zap.Error(err))
+			return
+		}
+		evt = &fetched
+	}
+	if evt == nil {
+		return
+	}
+	ed.receiveClusterEvent(evt)
+}
+
+// receiveClusterEvent feeds an event a peer accepted into the local dispatcher.
+func (ed *EventDispatcher) receiveClusterEvent(evt *nostr.Event) {
+	if ed.ctx.Err() != nil {
+		return
+	}
+
+	if !nips.IsEphemeral(evt.Kind) {
+		// The peer stored it, so keep local duplicate and cache state in step
+		ed.db.markStored(evt.ID)
+		ed.db.invalidateQueryCache(evt)
+		ed.db.putHotStore(evt)
+		ed.db.trackDVM(evt)
+		ed.db.trackReport(evt)
+	}
+
+	select {
+	case ed.eventBuffer <- evt:
+		metrics.ClusterDispatchEvents.WithLabelValues("received").Inc()
+	default:
+		metrics.ClusterDispatchEvents.WithLabelValues("dropped").Inc()
+		logger.Warn("Local broadcast buffer full, dropping cluster event",
+			zap.String("event_id", evt.ID))
+	}
+}
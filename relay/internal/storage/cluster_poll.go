@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// CockroachDB cluster dispatch: every node writes the events it accepted to
+// cluster_events, ephemeral ones included, and reads the rows of the other
+// nodes written since its last poll. A row may become visible after rows
+// with a later announced_at were read, so each poll reads back
+// clusterPollLookback and skips the rows it has seen. Rows older than
+// clusterEventsRetention are deleted by whichever node prunes first.
+
+const (
+	defaultClusterPollInterval = 250 * time.Millisecond
+	// clusterPollLookback is how far before the newest row read each poll
+	// starts, covering transactions that commit out of timestamp order.
+	clusterPollLookback = 10 * time.Second
+	// clusterPollBatch bounds the rows read per poll.
+	clusterPollBatch = 1000
+	// clusterEventsRetention is how long announcements are kept for slow pollers.
+	clusterEventsRetention = 5 * time.Minute
+	// clusterPruneInterval is how often a node deletes expired announcements.
+	clusterPruneInterval = time.Minute
+)
+
+// clusterEventsDDL creates the announcement table of CockroachDB cluster dispatch.
+const clusterEventsDDL = `CREATE TABLE IF NOT EXISTS cluster_events (
+  announced_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  node_id TEXT NOT NULL,
+  event_id CHAR(64) NOT NULL,
+  event JSONB NOT NULL,
+  CONSTRAINT cluster_events_pkey PRIMARY KEY (announced_at, node_id, event_id)
+)`
+
+// ensureClusterEventsSchema creates the cluster_events table.
+func (db *DB) ensureClusterEventsSchema(ctx context.Context) error {
+	if _, err := db.Pool.Exec(ctx, clusterEventsDDL); err != nil {
+		return fmt.Errorf("failed to create cluster_events table: %w", err)
+	}
+	return nil
+}
+
+// publishClusterEvent writes a locally accepted event to cluster_events.
+func (ed *EventDispatcher) publishClusterEvent(evt *nostr.Event) {
+	ctx, cancel := context.WithTimeout(ed.ctx, 3*time.Second)
+	defer cancel()
+	if _, err := ed.db.Pool.Exec(ctx,
+		`INSERT INTO cluster_events (node_id, event_id, event) VALUES ($1, $2, $3)`,
+		ed.cluster.nodeID, evt.ID, evt); err != nil {
+		metrics.ClusterDispatchEvents.WithLabelValues("dropped").Inc()
+		logger.Warn("Failed to announce event to cluster",
+			zap.String("event_id", evt.ID),
+			zap.Error(err))
+		return
+	}
+	metrics.ClusterDispatchEvents.WithLabelValues("sent").Inc()
+}
+
+// clusterPoller is the read position of a node in cluster_events.
+type clusterPoller struct {
+	highWater time.Time            // newest announced_at read
+	behind    bool                 // the last poll filled a batch
+	seen      map[string]time.Time // rows read within the lookback, by node and event
+}
+
+// pollCluster reads the announcements of the other nodes until the
+// dispatcher stops.
+func (ed *EventDispatcher) pollCluster() {
+	p := &clusterPoller{seen: make(map[string]time.Time)}
+	ticker := time.NewTicker(ed.cluster.pollInterval)
+	defer ticker.Stop()
+	lastPrune := time.Now()
+
+	for {
+		select {
+		case <-ed.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := ed.pollClusterOnce(p); err != nil && ed.ctx.Err() == nil {
+			logger.Warn("Failed to poll cluster events", zap.Error(err))
+		}
+		if time.Since(lastPrune) >= clusterPruneInterval {
+			lastPrune = time.Now()
+			ed.pruneClusterEvents()
+		}
+	}
+}
+
+// pollClusterOnce feeds the unseen announcements of the other nodes into
+// the local dispatcher. The first poll only takes the database's time, so
+// announcements made before the node started are not replayed.
+func (ed *EventDispatcher) pollClusterOnce(p *clusterPoller) error {
+	ctx, cancel := context.WithTimeout(ed.ctx, 5*time.Second)
+	defer cancel()
+
+	if p.highWater.IsZero() {
+		return ed.db.Pool.QueryRow(ctx, `SELECT now()`).Scan(&p.highWater)
+	}
+	since := p.highWater.Add(-clusterPollLookback)
+	if p.behind {
+		since = p.highWater
+	}
+
+	rows, err := ed.db.Pool.Query(ctx,
+		`SELECT announced_at, node_id, event_id, event FROM cluster_events
+		 WHERE announced_at >= $1 AND node_id <> $2 ORDER BY announced_at LIMIT $3`,
+		since, ed.cluster.nodeID, clusterPollBatch)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	read := 0
+	for rows.Next() {
+		var at time.Time
+		var nodeID, eventID string
+		var raw []byte
+		if err := rows.Scan(&at, &nodeID, &eventID, &raw); err != nil {
+			return err
+		}
+		read++
+		if at.After(p.highWater) {
+			p.highWater = at
+		}
+		key := nodeID + ":" + eventID
+		if _, ok := p.seen[key]; ok {
+			continue
+		}
+		p.seen[key] = at
+
+		var evt nostr.Event
+		if err := json.Unmarshal(raw, &evt); err != nil {
+			logger.Debug("Ignoring malformed cluster event", zap.String("event_id", eventID), zap.Error(err))
+			continue
+		}
+		ed.receiveClusterEvent(&evt)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	p.behind = read == clusterPollBatch
+
+	for key, at := range p.seen {
+		if at.Before(p.highWater.Add(-clusterPollLookback)) {
+			delete(p.seen, key)
+		}
+	}
+	return nil
+}
+
+// pruneClusterEvents deletes the announcements every node has had time to read.
+func (ed *EventDispatcher) pruneClusterEvents() {
+	ctx, cancel := context.WithTimeout(ed.ctx, 10*time.Second)
+	defer cancel()
+	if _, err := ed.db.Pool.Exec(ctx,
+		`DELETE FROM cluster_events WHERE announced_at < now() - $1::INTERVAL`,
+		clusterEventsRetention.String()); err != nil && ed.ctx.Err() == nil {
+		logger.Warn("Failed to prune cluster events", zap.Error(err))
+	}
+}
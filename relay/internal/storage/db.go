@@ -3,16 +3,18 @@ package storage
 import (
 	"context"
 	"fmt"
-	"strings"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Shugur-Network/relay/internal/config"
 	"github.com/Shugur-Network/relay/internal/constants"
 	"github.com/Shugur-Network/relay/internal/logger"
 	"github.com/Shugur-Network/relay/internal/metrics"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/willf/bloom"
+	nostr "github.com/nbd-wtf/go-nostr"
 
 	"go.uber.org/zap"
 )
@@ -28,20 +30,57 @@ const (
 	DBStateClosed
 )
 
-// DB represents the PostgreSQL database connection
+// DB represents the event store: the built-in PostgreSQL pool, or another
+// StorageBackend selected with DATABASE.DRIVER
 type DB struct {
-	Pool            *pgxpool.Pool
-	Bloom           *bloom.BloomFilter
-	eventDispatcher *EventDispatcher
-	state           DBState
-	stateMu         sync.RWMutex
-	errors          chan error
-	errorCount      int32
-	errorCountMu    sync.RWMutex
+	Pool              *pgxpool.Pool
+	readPool          *pgxpool.Pool  // REQ and COUNT queries; nil = Pool
+	admission         *admission     // nil = queries never shed
+	backend           StorageBackend // postgresBackend on Pool, or the DATABASE.DRIVER backend
+	Bloom             *ShardedBloom
+	bloomComplete     atomic.Bool // Bloom holds every stored ID of every writer, see SeenEvent
+	recent            recentIDs
+	retry             retryPolicy // zero = defaults, see SetRetryPolicy
+	breaker           circuitBreaker
+	bloomPath         string
+	eventDispatcher   *EventDispatcher
+	queryCache        *QueryCache
+	hotStore          *HotStore
+	dvmTracker        *DVMTracker
+	reportTracker     *ReportTracker
+	tenants           tenantCache
+	contentScorer     func(evt *nostr.Event)
+	timestampVerifier func(evt *nostr.Event)
+	eventSink         func(evt *nostr.Event)
+	webhooks          func(evt *nostr.Event)
+	metadataObserver  func(evt *nostr.Event)
+	keyPackages       func(evt *nostr.Event)
+	capsuleObserver   func(evt *nostr.Event)
+	marketplace       func(evt *nostr.Event)
+	classifieds       func(evt *nostr.Event)
+	liveObserver      func(evt *nostr.Event)
+	appHandlers       func(evt *nostr.Event)
+	communities       func(evt *nostr.Event)
+	labelObserver     func(evt *nostr.Event)
+	state             DBState
+	stateMu           sync.RWMutex
+	errors            chan error
+	errorCount        int32
+	errorCountMu      sync.RWMutex
 }
 
 // createPoolBasedOnLoad creates optimized pool configuration based on expected WebSocket load
-func createPoolBasedOnLoad(ctx context.Context, dbURI string, maxWSConnections int) (*pgxpool.Pool, error) {
+func createPoolBasedOnLoad(ctx context.Context, dbURI string, maxWSConnections int, settings config.PoolConfig) (*pgxpool.Pool, error) {
+	config, err := poolConfigBasedOnLoad(dbURI, maxWSConnections, settings)
+	if err != nil {
+		return nil, err
+	}
+	return pgxpool.NewWithConfig(ctx, config)
+}
+
+// poolConfigBasedOnLoad parses dbURI and sizes the pool for the expected
+// WebSocket load. Non-zero DATABASE.POOL settings override the sizing.
+func poolConfigBasedOnLoad(dbURI string, maxWSConnections int, settings config.PoolConfig) (*pgxpool.Config, error) {
 	config, err := pgxpool.ParseConfig(dbURI)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse database URI: %w", err)
@@ -76,20 +115,40 @@ func createPoolBasedOnLoad(ctx context.Context, dbURI string, maxWSConnections i
 	config.MaxConnIdleTime = constants.DBConnMaxIdleTime
 	config.ConnConfig.ConnectTimeout = constants.DBConnAcquireTimeout
 	config.HealthCheckPeriod = 30 * time.Second // Regular health checks
+	config.ConnConfig.Tracer = queryTracer{}    // Spans for queries issued under a traced command
+
+	// Operator overrides
+	if settings.MaxConns > 0 {
+		config.MaxConns = int32(settings.MaxConns)
+		scaleType = "configured"
+	}
+	if settings.MinConns > 0 {
+		config.MinConns = min(int32(settings.MinConns), config.MaxConns)
+	}
+	if settings.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = settings.MaxConnLifetime
+	}
+	if settings.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = settings.MaxConnIdleTime
+	}
+	if settings.HealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = settings.HealthCheckPeriod
+	}
 	
 	logger.Info("Database connection pool configured based on load",
 		zap.String("scale_type", scaleType),
 		zap.Int("max_ws_connections", maxWSConnections),
-		zap.Int32("db_max_conns", maxConns),
-		zap.Int32("db_min_conns", minConns),
-		zap.Duration("max_lifetime", constants.DBConnMaxLifetime),
-		zap.Duration("max_idle_time", constants.DBConnMaxIdleTime))
+		zap.Int32("db_max_conns", config.MaxConns),
+		zap.Int32("db_min_conns", config.MinConns),
+		zap.Duration("max_lifetime", config.MaxConnLifetime),
+		zap.Duration("max_idle_time", config.MaxConnIdleTime),
+		zap.Duration("health_check_period", config.HealthCheckPeriod))
 	
-	return pgxpool.NewWithConfig(ctx, config)
+	return config, nil
 }
 
 // InitDB initializes the PostgreSQL connection with retries and optimized connection pooling
-func InitDB(ctx context.Context, dbURI string, maxWSConnections int) (*DB, error) {
+func InitDB(ctx context.Context, dbURI string, maxWSConnections int, settings config.PoolConfig) (*DB, error) {
 	var pool *pgxpool.Pool
 	var err error
 	backoff := 2 * time.Second
@@ -103,12 +162,13 @@ func InitDB(ctx context.Context, dbURI string, maxWSConnections int) (*DB, error
 	for i := 0; i < 5; i++ { // Retry up to 5 times
 		attempts++
 		// Create pool with load-based configuration
-		pool, err = createPoolBasedOnLoad(ctx, dbURI, maxWSConnections)
+		pool, err = createPoolBasedOnLoad(ctx, dbURI, maxWSConnections, settings)
 		if err == nil {
 			// Test the actual connection
 			if err = pool.Ping(ctx); err == nil {
 				db.Pool = pool
-				db.Bloom = bloom.NewWithEstimates(10_000_000, 0.01) // 10M entries with 1% false positive rate
+				db.backend = &postgresBackend{db: db}
+				db.Bloom = NewShardedBloom(10_000_000, 0.01, DefaultBloomShards) // 10M entries with 1% false positive rate
 				db.state = DBStateConnected
 
 				// Log pool configuration for verification
@@ -149,15 +209,24 @@ func (db *DB) CloseDB() error {
 	db.state = DBStateDisconnecting
 	db.stateMu.Unlock()
 
-	if db.Pool != nil {
-		db.Pool.Close()
-		db.state = DBStateClosed
-		logger.Debug("Database connection closed")
-		metrics.DBConnections.WithLabelValues("closed").Inc()
-		return nil
+	// Persist the Bloom filter so the next start does not begin empty
+	db.saveBloom()
+
+	if db.hotStore != nil {
+		if err := db.hotStore.Close(); err != nil {
+			logger.Warn("Failed to close hot store", zap.Error(err))
+		}
+	}
+
+	if db.backend == nil {
+		return fmt.Errorf("database is not initialized")
 	}
 
-	return fmt.Errorf("database pool is nil")
+	err := db.backend.Close()
+	db.state = DBStateClosed
+	logger.Debug("Database connection closed")
+	metrics.DBConnections.WithLabelValues("closed").Inc()
+	return err
 }
 
 // ExecuteQuery handles single-row queries (SELECT)
@@ -165,6 +234,9 @@ func (db *DB) ExecuteQuery(ctx context.Context, query string, args ...interface{
 	if !db.isConnected() {
 		return nil, fmt.Errorf("database is not connected")
 	}
+	if !db.usesPool() {
+		return nil, ErrUnsupportedByDriver
+	}
 
 	logger.Debug("Executing query",
 		zap.String("query", query),
@@ -179,6 +251,9 @@ func (db *DB) ExecuteBatch(ctx context.Context, batch *pgx.Batch) error {
 	if !db.isConnected() {
 		return fmt.Errorf("database is not connected")
 	}
+	if !db.usesPool() {
+		return ErrUnsupportedByDriver
+	}
 
 	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
@@ -216,6 +291,9 @@ func (db *DB) ExecuteCommand(ctx context.Context, query string, args ...interfac
 	if !db.isConnected() {
 		return fmt.Errorf("database is not connected")
 	}
+	if !db.usesPool() {
+		return ErrUnsupportedByDriver
+	}
 
 	logger.Debug("Executing command",
 		zap.String("query", query),
@@ -232,7 +310,7 @@ func (db *DB) ExecuteCommand(ctx context.Context, query string, args ...interfac
 	return err
 }
 
-// RebuildBloomFilter fetches all event IDs from PostgreSQL and updates the Bloom filter.
+// RebuildBloomFilter fetches all event IDs from storage and updates the Bloom filter.
 func (db *DB) RebuildBloomFilter(ctx context.Context) error {
 	if !db.isConnected() {
 		return fmt.Errorf("database is not connected")
@@ -240,39 +318,21 @@ func (db *DB) RebuildBloomFilter(ctx context.Context) error {
 
 	logger.Info("Rebuilding Bloom filter from database...")
 
-	query := `SELECT id FROM events`
-	rows, err := db.Pool.Query(ctx, query)
-	if err != nil {
-		db.recordError(fmt.Errorf("failed to fetch event IDs: %w", err))
-		metrics.DBErrors.WithLabelValues("bloom_filter_fetch_failed").Inc()
-		return err
-	}
-	defer rows.Close()
-
+	// IDs are added to the live filter without clearing it, so this can run
+	// in the background while events are being processed
 	count := 0
-	db.Bloom.ClearAll()
-
-	for rows.Next() {
-		var eventID string
-		if err := rows.Scan(&eventID); err != nil {
-			db.recordError(fmt.Errorf("failed to scan event ID: %w", err))
-			logger.Debug("Failed to scan event ID",
-				zap.Error(err))
-			continue
-		}
-
-		db.Bloom.AddString(eventID)
+	err := db.backend.ForEachEventID(ctx, func(id string) {
+		db.Bloom.AddString(id)
 		count++
 
 		if count%100000 == 0 {
 			logger.Debug("Bloom filter progress",
 				zap.Int("events", count))
 		}
-	}
-
-	if err := rows.Err(); err != nil {
-		db.recordError(fmt.Errorf("error scanning rows: %w", err))
-		metrics.DBErrors.WithLabelValues("bloom_filter_scan_failed").Inc()
+	})
+	if err != nil {
+		db.recordError(fmt.Errorf("failed to fetch event IDs: %w", err))
+		metrics.DBErrors.WithLabelValues("bloom_filter_fetch_failed").Inc()
 		return err
 	}
 
@@ -282,6 +342,80 @@ func (db *DB) RebuildBloomFilter(ctx context.Context) error {
 	return nil
 }
 
+// LoadOrRebuildBloom restores the Bloom filter from path when a snapshot exists,
+// otherwise it rebuilds it from an id-only scan in the background. Bloom
+// misses are trusted by SeenEvent only after a rebuild on the database's sole
+// writer: a snapshot misses the IDs stored after it was saved, and a shared
+// database receives the writes of other nodes.
+func (db *DB) LoadOrRebuildBloom(ctx context.Context, path string, shards int, singleWriter bool) {
+	if shards > 0 && shards != len(db.Bloom.shards) {
+		db.Bloom = NewShardedBloom(10_000_000, 0.01, shards)
+	}
+
+	if path != "" {
+		loaded, err := LoadShardedBloom(path, len(db.Bloom.shards))
+		if err == nil {
+			db.Bloom = loaded
+			logger.Info("Bloom filter loaded from disk",
+				zap.String("path", path),
+				zap.Int("shards", len(loaded.shards)))
+			return
+		}
+		if !os.IsNotExist(err) {
+			logger.Warn("Failed to load Bloom filter, rebuilding", zap.Error(err))
+		}
+	}
+
+	exclusive := singleWriter || !db.usesPool()
+	go func() {
+		if err := db.RebuildBloomFilter(ctx); err != nil {
+			logger.Warn("Failed to rebuild bloom filter", zap.Error(err))
+			return
+		}
+		db.bloomComplete.Store(exclusive)
+	}()
+}
+
+// StartBloomPersistence saves the Bloom filter to path every interval and on shutdown.
+func (db *DB) StartBloomPersistence(ctx context.Context, path string, interval time.Duration) {
+	if path == "" {
+		return
+	}
+	db.bloomPath = path
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				db.saveBloom()
+			}
+		}
+	}()
+}
+
+// saveBloom writes the Bloom filter to its configured path, if any.
+func (db *DB) saveBloom() {
+	if db.bloomPath == "" || db.Bloom == nil {
+		return
+	}
+	start := time.Now()
+	if err := db.Bloom.SaveToFile(db.bloomPath); err != nil {
+		logger.Warn("Failed to save Bloom filter", zap.String("path", db.bloomPath), zap.Error(err))
+		metrics.DBErrors.WithLabelValues("bloom_filter_save_failed").Inc()
+		return
+	}
+	logger.Debug("Bloom filter saved",
+		zap.String("path", db.bloomPath),
+		zap.Duration("duration", time.Since(start)))
+}
+
 // isConnected checks if the database is in a connected state
 func (db *DB) isConnected() bool {
 	db.stateMu.RLock()
@@ -306,48 +440,210 @@ func (db *DB) recordError(err error) {
 	}
 }
 
-// Add this helper function to your DB struct
-func (db *DB) executeWithRetry(ctx context.Context, f func(context.Context) error) error {
-	retries := 3
-	var lastErr error
 
-	for i := 0; i < retries; i++ {
-		err := f(ctx)
-		if err == nil {
-			return nil
-		}
+// SetEventDispatcher sets the event dispatcher reference for immediate local broadcasting
+func (db *DB) SetEventDispatcher(ed *EventDispatcher) {
+	db.eventDispatcher = ed
+}
 
-		// Check if error is a timeout or deadlock (retryable)
-		if strings.Contains(err.Error(), "statement timeout") ||
-			strings.Contains(err.Error(), "deadlock") {
-			lastErr = err
-			// Exponential backoff
-			time.Sleep(time.Duration(1<<i) * 100 * time.Millisecond)
-			continue
-		}
+// SetQueryCache enables the COUNT/REQ result cache
+func (db *DB) SetQueryCache(qc *QueryCache) {
+	db.queryCache = qc
+}
 
-		// Not a retryable error
-		return err
+// invalidateQueryCache drops cached results affected by a newly stored or
+// removed event
+func (db *DB) invalidateQueryCache(evt *nostr.Event) {
+	if db.queryCache != nil {
+		db.queryCache.Invalidate(evt)
 	}
+}
 
-	return fmt.Errorf("operation failed after %d retries: %w", retries, lastErr)
+// clearQueryCache drops every cached result after a bulk deletion
+func (db *DB) clearQueryCache() {
+	if db.queryCache != nil {
+		db.queryCache.Clear()
+	}
 }
 
-// SetEventDispatcher sets the event dispatcher reference for immediate local broadcasting
-func (db *DB) SetEventDispatcher(ed *EventDispatcher) {
-	db.eventDispatcher = ed
+// SetContentScorer sets the function newly stored events are submitted to for content scoring
+func (db *DB) SetContentScorer(submit func(evt *nostr.Event)) {
+	db.contentScorer = submit
+}
+
+// scoreContent submits a newly stored event for content scoring
+func (db *DB) scoreContent(evt *nostr.Event) {
+	if db.contentScorer != nil {
+		db.contentScorer(evt)
+	}
+}
+
+// SetTimestampVerifier sets the function newly stored events are submitted to
+// for NIP-03 OpenTimestamps verification
+func (db *DB) SetTimestampVerifier(submit func(evt *nostr.Event)) {
+	db.timestampVerifier = submit
+}
+
+// verifyTimestamp submits a newly stored event for OpenTimestamps verification
+func (db *DB) verifyTimestamp(evt *nostr.Event) {
+	if db.timestampVerifier != nil {
+		db.timestampVerifier(evt)
+	}
+}
+
+// SetEventSink sets the function accepted events are streamed to (newly
+// stored events and ephemeral events)
+func (db *DB) SetEventSink(submit func(evt *nostr.Event)) {
+	db.eventSink = submit
+}
+
+// sinkEvent streams an accepted event to the event sink
+func (db *DB) sinkEvent(evt *nostr.Event) {
+	if db.eventSink != nil {
+		db.eventSink(evt)
+	}
+}
+
+// SetWebhookNotifier sets the function accepted events are submitted to for
+// webhook delivery
+func (db *DB) SetWebhookNotifier(submit func(evt *nostr.Event)) {
+	db.webhooks = submit
+}
+
+// notifyWebhooks submits an accepted event for webhook delivery
+func (db *DB) notifyWebhooks(evt *nostr.Event) {
+	if db.webhooks != nil {
+		db.webhooks(evt)
+	}
+}
+
+// SetMetadataObserver sets the function newly stored events are passed to so
+// the kind 0 metadata cache stays current
+func (db *DB) SetMetadataObserver(observe func(evt *nostr.Event)) {
+	db.metadataObserver = observe
+}
+
+// observeMetadata passes a newly stored event to the metadata cache
+func (db *DB) observeMetadata(evt *nostr.Event) {
+	if db.metadataObserver != nil {
+		db.metadataObserver(evt)
+	}
+}
+
+// SetKeyPackageObserver sets the function newly stored events are passed to
+// so the NIP-EE KeyPackage index stays current
+func (db *DB) SetKeyPackageObserver(observe func(evt *nostr.Event)) {
+	db.keyPackages = observe
+}
+
+// observeKeyPackages passes a newly stored event to the KeyPackage index
+func (db *DB) observeKeyPackages(evt *nostr.Event) {
+	if db.keyPackages != nil {
+		db.keyPackages(evt)
+	}
+}
+
+// SetCapsuleObserver sets the function newly stored events are passed to
+// so the time capsule unlock schedule stays current
+func (db *DB) SetCapsuleObserver(observe func(evt *nostr.Event)) {
+	db.capsuleObserver = observe
+}
+
+// observeCapsules passes a newly stored event to the capsule unlock schedule
+func (db *DB) observeCapsules(evt *nostr.Event) {
+	if db.capsuleObserver != nil {
+		db.capsuleObserver(evt)
+	}
+}
+
+// SetMarketplaceObserver sets the function newly stored events are passed to
+// so the NIP-15 marketplace index stays current
+func (db *DB) SetMarketplaceObserver(observe func(evt *nostr.Event)) {
+	db.marketplace = observe
+}
+
+// observeMarketplace passes a newly stored event to the marketplace index
+func (db *DB) observeMarketplace(evt *nostr.Event) {
+	if db.marketplace != nil {
+		db.marketplace(evt)
+	}
+}
+
+// SetClassifiedsObserver sets the function newly stored events are passed to
+// so the NIP-99 classified listing index stays current
+func (db *DB) SetClassifiedsObserver(observe func(evt *nostr.Event)) {
+	db.classifieds = observe
+}
+
+// observeClassifieds passes a newly stored event to the classified listing index
+func (db *DB) observeClassifieds(evt *nostr.Event) {
+	if db.classifieds != nil {
+		db.classifieds(evt)
+	}
+}
+
+// SetLiveObserver sets the function newly stored events are passed to so
+// the NIP-53 live activity tracker stays current
+func (db *DB) SetLiveObserver(observe func(evt *nostr.Event)) {
+	db.liveObserver = observe
+}
+
+// observeLive passes a newly stored event to the live activity tracker
+func (db *DB) observeLive(evt *nostr.Event) {
+	if db.liveObserver != nil {
+		db.liveObserver(evt)
+	}
+}
+
+// SetAppHandlersObserver sets the function newly stored events are passed to
+// so the NIP-89 application handler index stays current
+func (db *DB) SetAppHandlersObserver(observe func(evt *nostr.Event)) {
+	db.appHandlers = observe
+}
+
+// observeAppHandlers passes a newly stored event to the application handler index
+func (db *DB) observeAppHandlers(evt *nostr.Event) {
+	if db.appHandlers != nil {
+		db.appHandlers(evt)
+	}
+}
+
+// SetCommunitiesObserver sets the function newly stored events are passed to
+// so the NIP-72 community index stays current
+func (db *DB) SetCommunitiesObserver(observe func(evt *nostr.Event)) {
+	db.communities = observe
+}
+
+// observeCommunities passes a newly stored event to the community index
+func (db *DB) observeCommunities(evt *nostr.Event) {
+	if db.communities != nil {
+		db.communities(evt)
+	}
+}
+
+// SetLabelObserver sets the function newly stored events are passed to so
+// the labels withholding events stay current
+func (db *DB) SetLabelObserver(observe func(evt *nostr.Event)) {
+	db.labelObserver = observe
+}
+
+// observeLabels passes a newly stored event to the label observer
+func (db *DB) observeLabels(evt *nostr.Event) {
+	if db.labelObserver != nil {
+		db.labelObserver(evt)
+	}
 }
 
 // Ping checks database connectivity
 func (db *DB) Ping() error {
-	if db.Pool == nil {
-		return fmt.Errorf("database pool is not initialized")
+	if db.backend == nil {
+		return fmt.Errorf("database is not initialized")
 	}
-	
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	
-	return db.Pool.Ping(ctx)
+
+	return db.backend.Ping(ctx)
 }
 
 // Stats returns database connection pool statistics
@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-90 Data Vending Machine job tracking.
+//
+// Event kinds:
+//   5000-5999 — job request (the job ID is the request's event ID)
+//   6000-6999 — job result  (kind = request kind + 1000, "e" tag = job ID)
+//   7000      — job feedback ("e" tag = job ID, "status" tag = job status)
+//
+// The tracker keeps an in-memory index of recent jobs so operators can see
+// what DVMs on the relay are doing without querying the event store.
+
+// DVM job statuses. Feedback statuses are taken from the "status" tag as published.
+const (
+	DVMStatusPending = "pending" // request seen, no feedback or result yet
+	DVMStatusSuccess = "success" // at least one result published
+	DVMStatusError   = "error"
+)
+
+// DVMJob is the tracked state of a single NIP-90 job.
+type DVMJob struct {
+	ID          string   `json:"id"`
+	Kind        int      `json:"kind"` // request kind
+	Customer    string   `json:"customer,omitempty"`
+	Status      string   `json:"status"`
+	Providers   []string `json:"providers,omitempty"`
+	Results     int      `json:"results"`
+	Feedback    int      `json:"feedback"`
+	RequestedAt int64    `json:"requested_at,omitempty"` // when the relay received the request
+	UpdatedAt   int64    `json:"updated_at"`
+	CompletedAt int64    `json:"completed_at,omitempty"` // when the first result arrived
+}
+
+// DVMJobFilter selects jobs for ListJobs. Zero values match everything.
+type DVMJobFilter struct {
+	Status   string
+	Kind     int
+	Customer string
+	Limit    int
+}
+
+// DVMTracker indexes NIP-90 requests, results and feedback by job ID.
+type DVMTracker struct {
+	mu        sync.Mutex
+	jobs      map[string]*DVMJob
+	order     []string // job IDs by first sighting, oldest first
+	maxJobs   int
+	retention time.Duration
+}
+
+// NewDVMTracker creates a tracker keeping at most maxJobs jobs for retention.
+func NewDVMTracker(maxJobs int, retention time.Duration) *DVMTracker {
+	if maxJobs <= 0 {
+		maxJobs = 10000
+	}
+	if retention <= 0 {
+		retention = 24 * time.Hour
+	}
+	return &DVMTracker{
+		jobs:      make(map[string]*DVMJob),
+		maxJobs:   maxJobs,
+		retention: retention,
+	}
+}
+
+// IsDVMKind reports whether a kind belongs to NIP-90.
+func IsDVMKind(kind int) bool {
+	return (kind >= 5000 && kind <= 6999) || kind == 7000
+}
+
+// Track records a stored NIP-90 event.
+func (t *DVMTracker) Track(evt *nostr.Event) {
+	if !IsDVMKind(evt.Kind) {
+		return
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case evt.Kind < 6000:
+		if _, exists := t.jobs[evt.ID]; exists {
+			return
+		}
+		t.addLocked(&DVMJob{
+			ID:          evt.ID,
+			Kind:        evt.Kind,
+			Customer:    evt.PubKey,
+			Status:      DVMStatusPending,
+			RequestedAt: now.Unix(),
+			UpdatedAt:   now.Unix(),
+		}, now)
+		metrics.DVMJobs.WithLabelValues(strconv.Itoa(evt.Kind), "requested").Inc()
+
+	case evt.Kind < 7000:
+		jobID := dvmJobRef(evt)
+		if jobID == "" {
+			return
+		}
+		job := t.jobLocked(jobID, evt.Kind-1000, now)
+		job.Results++
+		job.addProvider(evt.PubKey)
+		job.UpdatedAt = now.Unix()
+		if job.CompletedAt == 0 {
+			job.CompletedAt = now.Unix()
+			metrics.DVMJobs.WithLabelValues(strconv.Itoa(job.Kind), "completed").Inc()
+			if job.RequestedAt > 0 {
+				metrics.DVMJobLatency.WithLabelValues(strconv.Itoa(job.Kind)).
+					Observe(now.Sub(time.Unix(job.RequestedAt, 0)).Seconds())
+			}
+		}
+		job.Status = DVMStatusSuccess
+
+	default:
+		jobID := dvmJobRef(evt)
+		if jobID == "" {
+			return
+		}
+		status := ""
+		if tag := evt.Tags.GetFirst([]string{"status", ""}); tag != nil && len(*tag) >= 2 {
+			status = (*tag)[1]
+		}
+		job := t.jobLocked(jobID, 0, now)
+		job.Feedback++
+		job.addProvider(evt.PubKey)
+		job.UpdatedAt = now.Unix()
+		// A delivered result is final; later feedback does not reopen the job
+		if status != "" && job.Status != DVMStatusSuccess {
+			job.Status = status
+			if status == DVMStatusError && job.Kind > 0 {
+				metrics.DVMJobs.WithLabelValues(strconv.Itoa(job.Kind), "error").Inc()
+			}
+		}
+	}
+}
+
+// jobLocked returns a job, creating a placeholder for results or feedback
+// whose request was not seen. Caller must hold t.mu.
+func (t *DVMTracker) jobLocked(id string, kind int, now time.Time) *DVMJob {
+	if job, ok := t.jobs[id]; ok {
+		if job.Kind == 0 {
+			job.Kind = kind
+		}
+		return job
+	}
+	job := &DVMJob{ID: id, Kind: kind, Status: DVMStatusPending, UpdatedAt: now.Unix()}
+	t.addLocked(job, now)
+	return job
+}
+
+// addLocked inserts a job, evicting expired and excess jobs. Caller must hold t.mu.
+func (t *DVMTracker) addLocked(job *DVMJob, now time.Time) {
+	cutoff := now.Add(-t.retention).Unix()
+	drop := 0
+	for drop < len(t.order) {
+		old := t.jobs[t.order[drop]]
+		if old != nil && old.UpdatedAt >= cutoff && len(t.order)-drop < t.maxJobs {
+			break
+		}
+		delete(t.jobs, t.order[drop])
+		drop++
+	}
+	if drop > 0 {
+		t.order = append([]string(nil), t.order[drop:]...)
+	}
+	t.jobs[job.ID] = job
+	t.order = append(t.order, job.ID)
+}
+
+// addProvider records a service provider pubkey once.
+func (j *DVMJob) addProvider(pubkey string) {
+	for _, p := range j.Providers {
+		if p == pubkey {
+			return
+		}
+	}
+	j.Providers = append(j.Providers, pubkey)
+}
+
+// dvmJobRef returns the job ID referenced by a result or feedback event.
+func dvmJobRef(evt *nostr.Event) string {
+	if tag := evt.Tags.GetFirst([]string{"e", ""}); tag != nil && len(*tag) >= 2 {
+		return (*tag)[1]
+	}
+	return ""
+}
+
+// ListJobs returns matching jobs, most recently updated first.
+func (t *DVMTracker) ListJobs(filter DVMJobFilter) []DVMJob {
+	t.mu.Lock()
+	jobs := make([]DVMJob, 0, len(t.jobs))
+	for _, job := range t.jobs {
+		if filter.Status != "" && job.Status != filter.Status {
+			continue
+		}
+		if filter.Kind != 0 && job.Kind != filter.Kind {
+			continue
+		}
+		if filter.Customer != "" && job.Customer != filter.Customer {
+			continue
+		}
+		copied := *job
+		copied.Providers = append([]string(nil), job.Providers...)
+		jobs = append(jobs, copied)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].UpdatedAt > jobs[j].UpdatedAt
+	})
+	if filter.Limit > 0 && len(jobs) > filter.Limit {
+		jobs = jobs[:filter.Limit]
+	}
+	return jobs
+}
+
+// StatusCounts aggregates tracked jobs by status and by request kind.
+func (t *DVMTracker) StatusCounts() (byStatus map[string]int, byKind map[int]map[string]int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	byStatus = make(map[string]int)
+	byKind = make(map[int]map[string]int)
+	for _, job := range t.jobs {
+		byStatus[job.Status]++
+		if byKind[job.Kind] == nil {
+			byKind[job.Kind] = make(map[string]int)
+		}
+		byKind[job.Kind][job.Status]++
+	}
+	return byStatus, byKind
+}
+
+// SetDVMTracker enables NIP-90 job tracking
+func (db *DB) SetDVMTracker(t *DVMTracker) {
+	db.dvmTracker = t
+}
+
+// DVMTracker returns the NIP-90 job tracker, or nil when tracking is disabled
+func (db *DB) DVMTracker() *DVMTracker {
+	return db.dvmTracker
+}
+
+// trackDVM feeds a stored event to the NIP-90 job tracker
+func (db *DB) trackDVM(evt *nostr.Event) {
+	if db.dvmTracker != nil {
+		db.dvmTracker.Track(evt)
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"runtime"
 	"strings"
 	"time"
@@ -9,20 +10,65 @@ import (
 	"github.com/Shugur-Network/relay/internal/logger"
 	"github.com/Shugur-Network/relay/internal/metrics"
 	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/tracing"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
+// EventPriority is the processing class an event is queued under.
+type EventPriority int
+
+const (
+	// PriorityHigh covers latency-sensitive traffic: ephemeral events and live chat.
+	PriorityHigh EventPriority = iota
+	// PriorityNormal covers regular client publishes.
+	PriorityNormal
+)
+
+// String returns the metric label for a priority class.
+func (p EventPriority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// EventPriorityFor classifies a client-published event.
+func EventPriorityFor(evt *nostr.Event) EventPriority {
+	if nips.IsEphemeral(evt.Kind) {
+		return PriorityHigh
+	}
+	switch evt.Kind {
+	case 9, 42, 1311: // NIP-29 group chat, NIP-28 channel message, NIP-53 live chat
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// queuedEvent is an event waiting for a storage worker, with the trace it
+// was accepted under so the storage span joins the client's command span.
+type queuedEvent struct {
+	evt      nostr.Event
+	trace    tracing.SpanContext
+	queuedAt time.Time
+	done     chan error // receives the storage outcome, nil when nobody waits
+}
+
 // EventProcessor manages event processing with a worker pool
 type EventProcessor struct {
-	eventChan   chan nostr.Event
+	queues      [2]chan queuedEvent // indexed by EventPriority
 	db          *DB
+	batcher     *insertBatcher // nil = one transaction per event
 	workerCount int
 	ctx         context.Context
 	cancel      context.CancelFunc
 }
 
-// NewEventProcessor creates a new event processor
+// NewEventProcessor creates a new event processor. The buffer is split
+// across the priority queues (high 1/4, normal 3/4) and so are the
+// workers, with the normal class getting whatever is left over.
 func NewEventProcessor(ctx context.Context, db *DB, bufferSize int) *EventProcessor {
 	ctx, cancel := context.WithCancel(ctx)
 
@@ -30,21 +76,56 @@ func NewEventProcessor(ctx context.Context, db *DB, bufferSize int) *EventProces
 	workerCount := runtime.NumCPU() * 2
 
 	ep := &EventProcessor{
-		eventChan:   make(chan nostr.Event, bufferSize),
 		db:          db,
 		workerCount: workerCount,
 		ctx:         ctx,
 		cancel:      cancel,
 	}
+	ep.queues[PriorityHigh] = make(chan queuedEvent, max(1, bufferSize/4))
+	ep.queues[PriorityNormal] = make(chan queuedEvent, max(1, bufferSize-bufferSize/4))
+
+	highWorkers := max(1, workerCount/4)
+	normalWorkers := max(1, workerCount-highWorkers)
 
 	// Start worker goroutines
-	for i := 0; i < workerCount; i++ {
-		go ep.processEvents(ctx)
+	for i := 0; i < highWorkers; i++ {
+		go ep.processEvents(ctx, PriorityHigh)
+	}
+	for i := 0; i < normalWorkers; i++ {
+		go ep.processEvents(ctx, PriorityNormal)
 	}
 
 	return ep
 }
 
+// enqueue performs a non-blocking send on the queue for the given class.
+func (ep *EventProcessor) enqueue(ctx context.Context, evt nostr.Event, class EventPriority) bool {
+	return ep.enqueueItem(ctx, evt, class, nil)
+}
+
+// enqueueItem is enqueue for an event whose storage outcome is sent on done.
+func (ep *EventProcessor) enqueueItem(ctx context.Context, evt nostr.Event, class EventPriority, done chan error) bool {
+	item := queuedEvent{evt: evt, trace: tracing.SpanContextFromContext(ctx), queuedAt: time.Now(), done: done}
+	select {
+	case ep.queues[class] <- item:
+		ep.updateQueueDepth(class)
+		return true
+	default:
+		metrics.EventQueueDrops.WithLabelValues(class.String()).Inc()
+		return false
+	}
+}
+
+// updateQueueDepth publishes the current length of a priority queue.
+func (ep *EventProcessor) updateQueueDepth(class EventPriority) {
+	metrics.SetEventQueueDepth(class.String(), len(ep.queues[class]))
+}
+
+// QueueUsage returns the number of queued events and the capacity of a priority queue.
+func (ep *EventProcessor) QueueUsage(class EventPriority) (length, capacity int) {
+	return len(ep.queues[class]), cap(ep.queues[class])
+}
+
 // QueueDeletion is called by the validator AFTER it has verified
 // that the deleter has the right to try.  The function will:
 //  1. delete all owned referenced events (same pubkey)
@@ -52,155 +133,294 @@ func NewEventProcessor(ctx context.Context, db *DB, bufferSize int) *EventProces
 //
 // It reuses the same retry / back‑pressure mechanism.
 func (ep *EventProcessor) QueueDeletion(evt nostr.Event) bool {
-	select {
-	case ep.eventChan <- evt:
-		return true
-	default:
+	if !ep.enqueue(context.Background(), evt, PriorityNormal) {
 		logger.Warn("Deletion queue full, dropping event",
 			zap.String("event_id", evt.ID),
 			zap.String("pubkey", evt.PubKey),
 			zap.Int("kind", evt.Kind))
 		return false
 	}
+	return true
 }
 
 // QueueVanish handles NIP-62 vanish requests.
 // Deletes all events from the pubkey and prevents re-broadcast.
 func (ep *EventProcessor) QueueVanish(evt nostr.Event) bool {
-	select {
-	case ep.eventChan <- evt:
-		return true
-	default:
+	if !ep.enqueue(context.Background(), evt, PriorityNormal) {
 		logger.Warn("Vanish queue full, dropping event",
 			zap.String("event_id", evt.ID),
 			zap.String("pubkey", evt.PubKey))
 		return false
 	}
+	return true
 }
 
-// QueueEvent adds an event to processing queue with non-blocking behavior
+// QueueEvent adds an event to processing queue with non-blocking behavior.
+// The priority class is derived from the event kind.
 func (ep *EventProcessor) QueueEvent(evt nostr.Event) bool {
+	return ep.QueueEventContext(context.Background(), evt)
+}
+
+// QueueEventContext is QueueEvent for an event accepted under a traced
+// command; the storage span continues the trace found in ctx.
+func (ep *EventProcessor) QueueEventContext(ctx context.Context, evt nostr.Event) bool {
+	return ep.queueEvent(ctx, evt, EventPriorityFor(&evt))
+}
+
+// QueueEventDurable is QueueEventContext for callers that wait for the event
+// to be written: the returned channel receives nil once it is stored, or the
+// error that made storage give up.
+func (ep *EventProcessor) QueueEventDurable(ctx context.Context, evt nostr.Event) (<-chan error, bool) {
+	done := make(chan error, 1)
+	if ep.db.Bloom.Test([]byte(evt.ID)) {
+		done <- nil // Already stored
+		return done, true
+	}
+	class := EventPriorityFor(&evt)
+	if !ep.enqueueItem(ctx, evt, class, done) {
+		logger.Warn("Event processing queue full, dropping event",
+			zap.String("event_id", evt.ID),
+			zap.String("pubkey", evt.PubKey),
+			zap.Int("kind", evt.Kind),
+			zap.String("class", class.String()))
+		return nil, false
+	}
+	return done, true
+}
+
+// QueueEventWithPriority adds an event to the queue of the given class
+func (ep *EventProcessor) QueueEventWithPriority(evt nostr.Event, class EventPriority) bool {
+	return ep.queueEvent(context.Background(), evt, class)
+}
+
+// queueEvent adds an event to the queue of the given class
+func (ep *EventProcessor) queueEvent(ctx context.Context, evt nostr.Event, class EventPriority) bool {
 	// Check bloom filter first to avoid processing duplicates
 	if ep.db.Bloom.Test([]byte(evt.ID)) {
 		return true // Already processed, consider it "queued"
 	}
 
 	// Try to add to queue non-blocking
-	select {
-	case ep.eventChan <- evt:
-		return true
-	default:
+	if !ep.enqueue(ctx, evt, class) {
 		// Queue full - this is backpressure
 		logger.Warn("Event processing queue full, dropping event",
 			zap.String("event_id", evt.ID),
 			zap.String("pubkey", evt.PubKey),
-			zap.Int("kind", evt.Kind))
+			zap.Int("kind", evt.Kind),
+			zap.String("class", class.String()))
 		return false
 	}
+	return true
 }
 
-// processEvents handles database insertion with retries
-func (ep *EventProcessor) processEvents(ctx context.Context) {
+// processEvents handles database insertion with retries for one worker of
+// the given class.
+func (ep *EventProcessor) processEvents(ctx context.Context, class EventPriority) {
 	for {
-		select {
-		case <-ep.ctx.Done():
+		item, ok := ep.nextEvent()
+		if !ok {
 			return
-		case evt, ok := <-ep.eventChan:
-			if !ok {
-				// Channel closed
-				return
+		}
+
+		evtCtx, span := tracing.StartChild(tracing.ContextWithRemoteParent(ctx, item.trace), "event.store", tracing.SpanKindConsumer,
+			tracing.String("nostr.event_id", item.evt.ID),
+			tracing.Int("nostr.kind", item.evt.Kind),
+			tracing.String("queue.class", class.String()),
+			tracing.Int("queue.wait_ms", int(time.Since(item.queuedAt).Milliseconds())))
+		evtCtx = WithReceivedAt(evtCtx, item.queuedAt)
+		if ep.batcher != nil && ep.batcher.batches(item.evt) {
+			ep.batcher.add(pendingInsert{ctx: evtCtx, evt: item.evt, span: span, done: item.done})
+			continue
+		}
+		err := ep.processEvent(evtCtx, item.evt)
+		span.RecordError(err)
+		span.End()
+		if item.done != nil {
+			item.done <- err
+		}
+	}
+}
+
+// nextEvent blocks until an event is available for a worker. Both classes
+// of workers take from either queue, but the high-priority queue is always
+// drained first.
+func (ep *EventProcessor) nextEvent() (queuedEvent, bool) {
+	high, normal := ep.queues[PriorityHigh], ep.queues[PriorityNormal]
+
+	select {
+	case evt := <-high:
+		ep.updateQueueDepth(PriorityHigh)
+		return evt, true
+	default:
+	}
+
+	select {
+	case <-ep.ctx.Done():
+		return queuedEvent{}, false
+	case evt := <-high:
+		ep.updateQueueDepth(PriorityHigh)
+		return evt, true
+	case evt := <-normal:
+		ep.updateQueueDepth(PriorityNormal)
+		return evt, true
+	}
+}
+
+// processEvent stores a single event, retrying with backoff on failure
+func (ep *EventProcessor) processEvent(ctx context.Context, evt nostr.Event) error {
+	// Store with the shared retry policy
+	err := ep.db.Retry(ctx, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+		return ep.storeEvent(ctx, evt)
+	})
+	return ep.finishEvent(evt, err)
+}
+
+// finishEvent runs the post-insert steps of an event once storing it
+// returned err, and returns the error left for the caller.
+func (ep *EventProcessor) finishEvent(evt nostr.Event, err error) error {
+	switch {
+	case errors.Is(err, ErrStaleEvent):
+		// An older version of a replaceable event: nothing to store or broadcast
+		logger.Debug("Dropping superseded replaceable event",
+			zap.String("event_id", evt.ID),
+			zap.Int("kind", evt.Kind))
+		ep.db.Bloom.AddString(evt.ID)
+		err = nil
+	case err == nil || strings.Contains(err.Error(), "duplicate key"):
+		ep.afterStore(evt, err == nil)
+		err = nil
+	}
+
+	if err != nil {
+		logger.Error("Failed to insert event after retries",
+			zap.String("event_id", evt.ID),
+			zap.String("pubkey", evt.PubKey),
+			zap.Int("kind", evt.Kind),
+			zap.Error(err))
+	} else {
+		logger.Debug("Event successfully processed",
+			zap.String("event_id", evt.ID),
+			zap.String("pubkey", evt.PubKey),
+			zap.Int("kind", evt.Kind))
+	}
+	return err
+}
+
+// storeEvent writes an event the way its kind requires.
+func (ep *EventProcessor) storeEvent(ctx context.Context, evt nostr.Event) error {
+	switch {
+	case nips.IsEphemeral(evt.Kind):
+		// Ephemeral events (NIP-16) should not be stored
+		logger.Debug("Skipping storage of ephemeral event",
+			zap.String("event_id", evt.ID),
+			zap.Int("kind", evt.Kind))
+		return nil // No error, just don't store
+	case nips.IsVanishEvent(evt):
+		return ep.db.persistVanish(ctx, evt)
+	case nips.IsDeletionEvent(evt):
+		return ep.db.persistDeletion(ctx, evt)
+	case nips.IsReplaceable(evt.Kind):
+		return ep.db.InsertReplaceableEvent(ctx, evt)
+	case nips.IsAddressable(evt):
+		return ep.db.InsertAddressableEvent(ctx, evt)
+	default:
+		return ep.db.InsertEvent(ctx, evt)
+	}
+}
+
+// afterStore runs once an event is stored; created is false when it was
+// already there. It updates duplicate tracking, caches and metrics and
+// dispatches the event to live subscribers.
+func (ep *EventProcessor) afterStore(evt nostr.Event, created bool) {
+	// For ephemeral events, skip bloom filter and metrics but still broadcast
+	if nips.IsEphemeral(evt.Kind) {
+		ep.db.sinkEvent(&evt)
+		ep.db.notifyWebhooks(&evt)
+
+		// Broadcast ephemeral event immediately to local clients for real-time streaming
+		if ep.db.eventDispatcher != nil {
+			logger.Debug("Broadcasting ephemeral event to local clients",
+				zap.String("event_id", evt.ID),
+				zap.String("pubkey", evt.PubKey),
+				zap.Int("kind", evt.Kind))
+
+			// Send event to local event dispatcher for immediate broadcasting
+			select {
+			case ep.db.eventDispatcher.eventBuffer <- &evt:
+				logger.Debug("Ephemeral event added to local broadcast buffer", zap.String("event_id", evt.ID))
+			default:
+				logger.Warn("Local broadcast buffer full, ephemeral event may not stream immediately", zap.String("event_id", evt.ID))
 			}
+			ep.db.eventDispatcher.announce(&evt)
+		}
+	} else {
+		// Only add to bloom filter after successful insertion for non-ephemeral events
+		ep.db.markStored(evt.ID)
 
-			// Process with retries and backoff
-			var err error
-			for attempt := 0; attempt < 3; attempt++ {
-				if attempt > 0 {
-					// Exponential backoff
-					backoff := time.Duration(1<<attempt) * 50 * time.Millisecond
-					time.Sleep(backoff)
-				}
+		// Increment the stored events metric only for new events
+		if created {
+			metrics.EventsStored.Inc()
+
+			// Drop cached query results this event could change
+			ep.db.invalidateQueryCache(&evt)
+			ep.db.putHotStore(&evt)
+			ep.db.trackDVM(&evt)
+			ep.db.trackReport(&evt)
+			ep.db.indexThread(ep.ctx, &evt)
+			ep.db.indexLabels(ep.ctx, &evt)
+			ep.db.scoreContent(&evt)
+			ep.db.verifyTimestamp(&evt)
+			ep.db.sinkEvent(&evt)
+			ep.db.notifyWebhooks(&evt)
+			ep.db.observeMetadata(&evt)
+			ep.db.observeKeyPackages(&evt)
+			ep.db.observeCapsules(&evt)
+			ep.db.observeMarketplace(&evt)
+			ep.db.observeClassifieds(&evt)
+			ep.db.observeLive(&evt)
+			ep.db.observeAppHandlers(&evt)
+			ep.db.observeCommunities(&evt)
+			ep.db.observeLabels(&evt)
 
-				ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
-				switch {
-				case nips.IsEphemeral(evt.Kind):
-					// Ephemeral events (NIP-16) should not be stored
-					logger.Debug("Skipping storage of ephemeral event",
+			// Keep NIP-65 outbox hints in step with the latest relay list
+			if evt.Kind == KindRelayListMetadata {
+				hintCtx, hintCancel := context.WithTimeout(ep.ctx, 3*time.Second)
+				if hintErr := ep.db.RecordRelayHints(hintCtx, &evt); hintErr != nil {
+					logger.Warn("Failed to record relay hints",
 						zap.String("event_id", evt.ID),
-						zap.Int("kind", evt.Kind))
-					err = nil // No error, just don't store
-				case nips.IsVanishEvent(evt):
-					err = ep.db.persistVanish(ctx, evt)
-				case nips.IsDeletionEvent(evt):
-					err = ep.db.persistDeletion(ctx, evt)
-				case nips.IsReplaceable(evt.Kind):
-					err = ep.db.InsertReplaceableEvent(ctx, evt)
-				case nips.IsAddressable(evt):
-					err = ep.db.InsertAddressableEvent(ctx, evt)
-				default:
-					err = ep.db.InsertEvent(ctx, evt)
+						zap.Error(hintErr))
 				}
-				cancel()
-
-				if err == nil || strings.Contains(err.Error(), "duplicate key") {
-					// For ephemeral events, skip bloom filter and metrics but still broadcast
-					if nips.IsEphemeral(evt.Kind) {
-						// Broadcast ephemeral event immediately to local clients for real-time streaming
-						if ep.db.eventDispatcher != nil {
-							logger.Debug("Broadcasting ephemeral event to local clients",
-								zap.String("event_id", evt.ID),
-								zap.String("pubkey", evt.PubKey),
-								zap.Int("kind", evt.Kind))
-
-							// Send event to local event dispatcher for immediate broadcasting
-							select {
-							case ep.db.eventDispatcher.eventBuffer <- &evt:
-								logger.Debug("Ephemeral event added to local broadcast buffer", zap.String("event_id", evt.ID))
-							default:
-								logger.Warn("Local broadcast buffer full, ephemeral event may not stream immediately", zap.String("event_id", evt.ID))
-							}
-						}
-					} else {
-						// Only add to bloom filter after successful insertion for non-ephemeral events
-						ep.db.Bloom.AddString(evt.ID)
-
-						// Increment the stored events metric only for new events
-						if err == nil {
-							metrics.EventsStored.Inc()
-
-							// Broadcast event immediately to local clients for real-time streaming
-							if ep.db.eventDispatcher != nil {
-								logger.Debug("Broadcasting event to local clients",
-									zap.String("event_id", evt.ID),
-									zap.String("pubkey", evt.PubKey),
-									zap.Int("kind", evt.Kind))
-
-								// Send event to local event dispatcher for immediate broadcasting
-								select {
-								case ep.db.eventDispatcher.eventBuffer <- &evt:
-									logger.Debug("Event added to local broadcast buffer", zap.String("event_id", evt.ID))
-								default:
-									logger.Warn("Local broadcast buffer full, event may not stream immediately", zap.String("event_id", evt.ID))
-								}
-							}
-						}
-					}
-
-					err = nil
-					break
+				hintCancel()
+			}
+
+			// Keep the follow graph in step with the latest follow list
+			if evt.Kind == nostr.KindFollowList {
+				graphCtx, graphCancel := context.WithTimeout(ep.ctx, 3*time.Second)
+				if graphErr := ep.db.RecordFollowList(graphCtx, &evt); graphErr != nil {
+					logger.Warn("Failed to record follow list",
+						zap.String("event_id", evt.ID),
+						zap.Error(graphErr))
 				}
+				graphCancel()
 			}
 
-			if err != nil {
-				logger.Error("Failed to insert event after retries",
-					zap.String("event_id", evt.ID),
-					zap.String("pubkey", evt.PubKey),
-					zap.Int("kind", evt.Kind),
-					zap.Error(err))
-			} else {
-				logger.Debug("Event successfully processed",
+			// Broadcast event immediately to local clients for real-time streaming
+			if ep.db.eventDispatcher != nil {
+				logger.Debug("Broadcasting event to local clients",
 					zap.String("event_id", evt.ID),
 					zap.String("pubkey", evt.PubKey),
 					zap.Int("kind", evt.Kind))
+
+				// Send event to local event dispatcher for immediate broadcasting
+				select {
+				case ep.db.eventDispatcher.eventBuffer <- &evt:
+					logger.Debug("Event added to local broadcast buffer", zap.String("event_id", evt.ID))
+				default:
+					logger.Warn("Local broadcast buffer full, event may not stream immediately", zap.String("event_id", evt.ID))
+				}
+				ep.db.eventDispatcher.announce(&evt)
 			}
 		}
 	}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Tag index: the single-letter tags of every stored event are copied into the
+// event_tags side table, so #e/#p/#a style filters become B-tree lookups
+// instead of JSONB containment scans. Rows are written in the same statement
+// as the event and removed with it through the foreign key.
+
+// maxIndexedTagValue bounds the tag values copied to event_tags, keeping the
+// B-tree index within PostgreSQL's row size limit. Filters on longer values
+// fall back to JSONB containment.
+const maxIndexedTagValue = 512
+
+// eventTagsDDL creates the tag index side table, one row per indexed tag value.
+const eventTagsDDL = `CREATE TABLE IF NOT EXISTS event_tags (
+  event_id CHAR(64) NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  tag_name TEXT NOT NULL,
+  tag_value TEXT NOT NULL,
+  CONSTRAINT event_tags_pkey PRIMARY KEY (event_id, tag_name, tag_value)
+)`
+
+// eventTagsIndexDDL serves tag filters, which look up events by tag value.
+const eventTagsIndexDDL = `CREATE INDEX IF NOT EXISTS event_tags_name_value ON event_tags (tag_name, tag_value, event_id)`
+
+// eventTagsBackfillSQL indexes the tags of events stored before the table existed.
+const eventTagsBackfillSQL = `INSERT INTO event_tags (event_id, tag_name, tag_value)
+SELECT e.id, t->>0, t->>1 FROM events e, jsonb_array_elements(e.tags) AS t
+WHERE jsonb_typeof(e.tags) = 'array' AND jsonb_typeof(t) = 'array'
+  AND length(t->>0) = 1 AND t->>1 IS NOT NULL AND length(t->>1) <= $1
+ON CONFLICT DO NOTHING`
+
+// insertEventQuery returns the statement storing an event and the time it
+// was received ($10) together with its indexed tags ($8, $9). With ignoreDuplicate an already stored ID writes
+// nothing; otherwise it fails with a unique violation.
+func insertEventQuery(ignoreDuplicate bool) string {
+	onConflict := ""
+	if ignoreDuplicate {
+		onConflict = "ON CONFLICT (id) DO NOTHING"
+	}
+	return `WITH ins AS (
+  INSERT INTO events (id, pubkey, created_at, kind, tags, content, sig, received_at)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $10)
+  ` + onConflict + `
+  RETURNING id
+)
+INSERT INTO event_tags (event_id, tag_name, tag_value)
+SELECT ins.id, t.name, t.value FROM ins, unnest($8::text[], $9::text[]) AS t(name, value)
+ON CONFLICT DO NOTHING`
+}
+
+// insertEventArgs returns the arguments of insertEventQuery for evt.
+func insertEventArgs(ctx context.Context, evt nostr.Event) []interface{} {
+	names, values := indexedTags(evt)
+	return []interface{}{evt.ID, evt.PubKey, evt.CreatedAt.Time().Unix(),
+		evt.Kind, evt.Tags, evt.Content, evt.Sig, names, values, receivedAtFromContext(ctx)}
+}
+
+// indexedTags returns the distinct single-letter tags of evt as parallel
+// name and value slices.
+func indexedTags(evt nostr.Event) ([]string, []string) {
+	names := make([]string, 0, len(evt.Tags))
+	values := make([]string, 0, len(evt.Tags))
+	seen := make(map[[2]string]bool, len(evt.Tags))
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || len(tag[0]) != 1 || len(tag[1]) > maxIndexedTagValue {
+			continue
+		}
+		key := [2]string{tag[0], tag[1]}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		names = append(names, tag[0])
+		values = append(values, tag[1])
+	}
+	return names, values
+}
+
+// ensureEventTagsSchema creates the event_tags table, indexing the tags of
+// already stored events the first time.
+func (db *DB) ensureEventTagsSchema(ctx context.Context) error {
+	var exists bool
+	if err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'event_tags')`,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check event_tags table: %w", err)
+	}
+
+	for _, ddl := range []string{eventTagsDDL, eventTagsIndexDDL} {
+		if _, err := db.Pool.Exec(ctx, ddl); err != nil {
+			return fmt.Errorf("failed to create event_tags table: %w", err)
+		}
+	}
+	if exists {
+		return nil
+	}
+
+	start := time.Now()
+	result, err := db.Pool.Exec(ctx, eventTagsBackfillSQL, maxIndexedTagValue)
+	if err != nil {
+		return fmt.Errorf("failed to index tags of stored events: %w", err)
+	}
+	logger.Info("Indexed tags of stored events",
+		zap.Int64("rows", result.RowsAffected()),
+		zap.Duration("took", time.Since(start)))
+	return nil
+}
+
+// tagConditions returns the conditions of the tag filters of a query, to
+// be joined with AND using placeholders from $argIndex. NIP-01 matches an
+// event that has every tag listed, with any of the values listed for it, so
+// the values of one tag are alternatives and each tag is one condition.
+// Tags are taken in name order so identical filters produce identical
+// statements. GetEvents and the COUNT and pubkey queries share it.
+func tagConditions(tags nostr.TagMap, argIndex int) ([]string, []interface{}) {
+	names := make([]string, 0, len(tags))
+	for name, values := range tags {
+		if len(values) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	conds := make([]string, 0, len(names))
+	var args []interface{}
+	for _, name := range names {
+		cond, condArgs := tagCondition(name, tags[name], argIndex+len(args))
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+	return conds, args
+}
+
+// tagCondition returns the SQL condition matching events with any of values
+// in tag name, using placeholders from $argIndex. Values of single-letter
+// tags go through event_tags; other tags and over-long values use JSONB
+// containment, one alternative per value since containment of several
+// values would require all of them.
+func tagCondition(name string, values []string, argIndex int) (string, []interface{}) {
+	var indexed, contained []string
+	for _, v := range values {
+		if len(name) == 1 && len(v) <= maxIndexedTagValue {
+			indexed = append(indexed, v)
+		} else {
+			contained = append(contained, v)
+		}
+	}
+
+	var alternatives []string
+	var args []interface{}
+	if len(indexed) > 0 {
+		alternatives = append(alternatives, fmt.Sprintf(
+			"id IN (SELECT event_id FROM event_tags WHERE tag_name = $%d AND tag_value = ANY($%d::text[]))",
+			argIndex, argIndex+1))
+		args = append(args, name, indexed)
+	}
+	for _, v := range contained {
+		alternatives = append(alternatives, fmt.Sprintf("tags @> $%d", argIndex+len(args)))
+		args = append(args, [][]string{{name, v}})
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], args
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
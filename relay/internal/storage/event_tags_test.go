@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+func TestTagConditions(t *testing.T) {
+	long := strings.Repeat("x", maxIndexedTagValue+1)
+	cases := []struct {
+		name     string
+		tags     nostr.TagMap
+		argIndex int
+		conds    []string
+		args     []interface{}
+	}{
+		{
+			name:     "indexed values",
+			tags:     nostr.TagMap{"e": {"a", "b"}},
+			argIndex: 3,
+			conds:    []string{"id IN (SELECT event_id FROM event_tags WHERE tag_name = $3 AND tag_value = ANY($4::text[]))"},
+			args:     []interface{}{"e", []string{"a", "b"}},
+		},
+		{
+			name:     "contained values",
+			tags:     nostr.TagMap{"title": {"a", "b"}},
+			argIndex: 1,
+			conds:    []string{"(tags @> $1 OR tags @> $2)"},
+			args:     []interface{}{[][]string{{"title", "a"}}, [][]string{{"title", "b"}}},
+		},
+		{
+			name:     "over-long value",
+			tags:     nostr.TagMap{"r": {"a", long}},
+			argIndex: 1,
+			conds:    []string{"(id IN (SELECT event_id FROM event_tags WHERE tag_name = $1 AND tag_value = ANY($2::text[])) OR tags @> $3)"},
+			args:     []interface{}{"r", []string{"a"}, [][]string{{"r", long}}},
+		},
+		{
+			name:     "several tags",
+			tags:     nostr.TagMap{"p": {"c"}, "e": {"a", "b"}, "t": {}},
+			argIndex: 2,
+			conds: []string{
+				"id IN (SELECT event_id FROM event_tags WHERE tag_name = $2 AND tag_value = ANY($3::text[]))",
+				"id IN (SELECT event_id FROM event_tags WHERE tag_name = $4 AND tag_value = ANY($5::text[]))",
+			},
+			args: []interface{}{"e", []string{"a", "b"}, "p", []string{"c"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conds, args := tagConditions(tc.tags, tc.argIndex)
+			if !reflect.DeepEqual(conds, tc.conds) {
+				t.Errorf("conds = %q, want %q", conds, tc.conds)
+			}
+			if !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("args = %v, want %v", args, tc.args)
+			}
+		})
+	}
+}
+
+// maxPlaceholder returns the highest $n placeholder of a statement.
+func maxPlaceholder(query string) int {
+	highest := 0
+	for _, m := range regexp.MustCompile(`\$(\d+)`).FindAllStringSubmatch(query, -1) {
+		if n, _ := strconv.Atoi(m[1]); n > highest {
+			highest = n
+		}
+	}
+	return highest
+}
+
+// TestMultiValueTagQueries checks that GetEvents and COUNT both turn a
+// filter with several values per tag into one condition per tag, with the
+// placeholders numbered after the other conditions.
+func TestMultiValueTagQueries(t *testing.T) {
+	filter := nostr.Filter{
+		Kinds: []int{1},
+		Tags:  nostr.TagMap{"t": {"nostr", "bitcoin"}, "p": {testPubkey, strings.Repeat("cd", 32)}},
+		Limit: 10,
+	}
+	pTag := "id IN (SELECT event_id FROM event_tags WHERE tag_name = $%d AND tag_value = ANY($%d::text[]))"
+
+	where, args := filterWhere(context.Background(), filter)
+	want := " WHERE kind = ANY($1) AND " + fmt.Sprintf(pTag, 2, 3) + " AND " + fmt.Sprintf(pTag, 4, 5)
+	if where != want {
+		t.Errorf("count WHERE = %q, want %q", where, want)
+	}
+	if len(args) != maxPlaceholder(where) {
+		t.Errorf("count query has %d placeholders and %d arguments", maxPlaceholder(where), len(args))
+	}
+
+	query, args, err := CompileFilter(filter).BuildQuery()
+	if err != nil {
+		t.Fatalf("BuildQuery: %v", err)
+	}
+	for _, cond := range []string{fmt.Sprintf(pTag, 2, 3), fmt.Sprintf(pTag, 4, 5)} {
+		if !strings.Contains(query, " AND "+cond) {
+			t.Errorf("query %q lacks %q", query, cond)
+		}
+	}
+	if len(args) != maxPlaceholder(query) {
+		t.Errorf("events query has %d placeholders and %d arguments", maxPlaceholder(query), len(args))
+	}
+	if got := args[1]; got != "p" {
+		t.Errorf("first tag name argument = %v, want p", got)
+	}
+}
+
+// TestSQLiteEventCountTags counts events by multi-value tag filters: any
+// value of a tag matches, and every tag must match.
+func TestSQLiteEventCountTags(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+
+	events := []nostr.Tags{
+		{{"t", "nostr"}},
+		{{"t", "bitcoin"}, {"p", testPubkey}},
+		{{"t", "nostr"}, {"p", testPubkey}},
+		{{"t", "other"}, {"p", testPubkey}},
+	}
+	for i, tags := range events {
+		evt := testEvent(i, 1, testSince+nostr.Timestamp(i))
+		evt.Tags = tags
+		if err := s.InsertEvent(ctx, evt); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		tags nostr.TagMap
+		want int64
+	}{
+		{"one value", nostr.TagMap{"t": {"nostr"}}, 2},
+		{"any value", nostr.TagMap{"t": {"nostr", "bitcoin"}}, 3},
+		{"every tag", nostr.TagMap{"t": {"nostr", "bitcoin"}, "p": {testPubkey}}, 2},
+		{"unknown value", nostr.TagMap{"t": {"missing"}}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			count, err := s.GetEventCount(ctx, nostr.Filter{Tags: tc.tags})
+			if err != nil {
+				t.Fatalf("GetEventCount: %v", err)
+			}
+			if count != tc.want {
+				t.Errorf("count = %d, want %d", count, tc.want)
+			}
+		})
+	}
+}
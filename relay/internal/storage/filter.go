@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -10,14 +12,18 @@ import (
 
 // CompiledFilter represents a pre-compiled filter for efficient matching
 type CompiledFilter struct {
-	IDs     map[string]bool
-	Authors map[string]bool
-	Kinds   map[int]bool
-	Since   *time.Time
-	Until   *time.Time
-	Tags    map[string]map[string]bool
-	Limit   int
-	Search  string
+	IDs      map[string]bool
+	Authors  map[string]bool
+	Kinds    map[int]bool
+	Since    *time.Time
+	Until    *time.Time
+	Tags     map[string]map[string]bool
+	Limit    int
+	Search   string
+	Tenant   string // tenant scope ("" = main relay), applied when Scoped
+	Scoped   bool
+	Received ReceivedRange // received_at bounds, see received_at.go
+	Page     *PageCursor   // set for paged queries, see page_cursor.go
 }
 
 // CompileFilter pre-compiles a nostr filter for efficient matching
@@ -72,16 +78,21 @@ func CompileFilter(f nostr.Filter) *CompiledFilter {
 	return cf
 }
 
-// GetBestIndex determines the most efficient index to use for the filter
+// GetBestIndex determines the most efficient index to use for the filter:
+// "id" (events_pkey), "pubkey_created" (events_pubkey_created_at),
+// "kind_created" (events_kind_created_at) or "created_at"
+// (events_created_at_desc). The last three end in created_at, so for a single
+// author or kind the ORDER BY and LIMIT of BuildQuery are read in index order
+// without a sort.
 func (cf *CompiledFilter) GetBestIndex() string {
 	// If we have IDs, use the primary key index
 	if len(cf.IDs) > 0 {
 		return "id"
 	}
 
-	// If we have both authors and kinds, use the composite index
-	if len(cf.Authors) > 0 && len(cf.Kinds) > 0 {
-		return "pubkey_kind_created"
+	// Authors are more selective than kinds, which are checked on the rows found
+	if len(cf.Authors) > 0 {
+		return "pubkey_created"
 	}
 
 	// If we only have kinds, use the kind index
@@ -102,58 +113,66 @@ func (cf *CompiledFilter) BuildQuery() (string, []interface{}, error) {
 	// Start with base SELECT
 	query.WriteString(`SELECT id, pubkey, kind, created_at, content, tags, sig FROM events`)
 
-	// Add WHERE clause based on best index
-	switch cf.GetBestIndex() {
-	case "id":
-		// Use primary key index
-		placeholders := make([]string, len(cf.IDs))
-		i := 0
-		for id := range cf.IDs {
-			placeholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, id)
-			argIndex++
-			i++
-		}
-		query.WriteString(fmt.Sprintf(" WHERE id = ANY(ARRAY[%s]::text[])", strings.Join(placeholders, ",")))
-
-	case "pubkey_kind_created":
-		// Use composite index for authors and kinds
-		authorPlaceholders := make([]string, len(cf.Authors))
-		i := 0
-		for author := range cf.Authors {
-			authorPlaceholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, author)
-			argIndex++
-			i++
+	addAuthors := func() {
+		cond, condArgs := hexCondition("pubkey", mapKeys(cf.Authors), argIndex)
+		query.WriteString(cond)
+		args = append(args, condArgs...)
+		argIndex += len(condArgs)
+	}
+	addKinds := func() {
+		// A single kind is an equality, so the kind index returns rows in created_at order
+		if len(cf.Kinds) == 1 {
+			for kind := range cf.Kinds {
+				query.WriteString(fmt.Sprintf("kind = $%d", argIndex))
+				args = append(args, kind)
+				argIndex++
+			}
+			return
 		}
-		kindPlaceholders := make([]string, len(cf.Kinds))
-		i = 0
+		kindPlaceholders := make([]string, 0, len(cf.Kinds))
 		for kind := range cf.Kinds {
-			kindPlaceholders[i] = fmt.Sprintf("$%d", argIndex)
+			kindPlaceholders = append(kindPlaceholders, fmt.Sprintf("$%d", argIndex))
 			args = append(args, kind)
 			argIndex++
-			i++
 		}
-		query.WriteString(fmt.Sprintf(" WHERE pubkey = ANY(ARRAY[%s]::text[]) AND kind = ANY(ARRAY[%s]::integer[])",
-			strings.Join(authorPlaceholders, ","), strings.Join(kindPlaceholders, ",")))
+		query.WriteString(fmt.Sprintf("kind = ANY(ARRAY[%s]::integer[])", strings.Join(kindPlaceholders, ",")))
+	}
+
+	// Add WHERE clause based on best index
+	best := cf.GetBestIndex()
+	switch best {
+	case "id":
+		// Use primary key index; full IDs are exact matches, prefixes range scans
+		cond, condArgs := hexCondition("id", mapKeys(cf.IDs), argIndex)
+		query.WriteString(" WHERE " + cond)
+		args = append(args, condArgs...)
+		argIndex += len(condArgs)
+
+	case "pubkey_created":
+		// Use the author index
+		query.WriteString(" WHERE ")
+		addAuthors()
 
 	case "kind_created":
 		// Use kind index
-		kindPlaceholders := make([]string, len(cf.Kinds))
-		i := 0
-		for kind := range cf.Kinds {
-			kindPlaceholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, kind)
-			argIndex++
-			i++
-		}
-		query.WriteString(fmt.Sprintf(" WHERE kind = ANY(ARRAY[%s]::integer[])", strings.Join(kindPlaceholders, ",")))
+		query.WriteString(" WHERE ")
+		addKinds()
 
 	default:
 		// Use created_at index
 		query.WriteString(" WHERE true")
 	}
 
+	// Fields the chosen index does not cover still narrow the result
+	if best == "id" && len(cf.Authors) > 0 {
+		query.WriteString(" AND ")
+		addAuthors()
+	}
+	if (best == "id" || best == "pubkey_created") && len(cf.Kinds) > 0 {
+		query.WriteString(" AND ")
+		addKinds()
+	}
+
 	// Add time filters
 	if cf.Since != nil {
 		query.WriteString(fmt.Sprintf(" AND created_at >= $%d", argIndex))
@@ -174,32 +193,77 @@ func (cf *CompiledFilter) BuildQuery() (string, []interface{}, error) {
 	}
 
 	// Add tag filters
+	tags := make(nostr.TagMap, len(cf.Tags))
 	for tagName, tagValues := range cf.Tags {
-		if len(tagValues) > 0 {
-			query.WriteString(fmt.Sprintf(" AND tags @> $%d", argIndex))
-			tagArray := make([][]string, len(tagValues))
-			i := 0
-			for value := range tagValues {
-				tagArray[i] = []string{tagName, value}
-				i++
-			}
-			args = append(args, tagArray)
+		values := mapKeys(tagValues)
+		sort.Strings(values)
+		tags[tagName] = values
+	}
+	tagConds, tagArgs := tagConditions(tags, argIndex)
+	for _, cond := range tagConds {
+		query.WriteString(" AND " + cond)
+	}
+	args = append(args, tagArgs...)
+	argIndex += len(tagArgs)
+
+	// Bound the first-seen time for propagation debugging
+	conds, condArgs := receivedRangeConds(cf.Received, func() string {
+		argIndex++
+		return fmt.Sprintf("$%d", argIndex-1)
+	})
+	for _, cond := range conds {
+		query.WriteString(" AND " + cond)
+	}
+	args = append(args, condArgs...)
+
+	// Scope the query to one tenant in multi-tenant mode
+	if cf.Scoped {
+		cond, condArgs := tenantCondition(cf.Tenant, argIndex)
+		query.WriteString(" AND " + cond)
+		args = append(args, condArgs...)
+		argIndex += len(condArgs)
+	}
+
+	// Resume after the previous page of a paged query
+	ascending := cf.Since != nil && cf.Until == nil
+	if cf.Page != nil {
+		cond, condArgs := pageCond(*cf.Page, ascending, func() string {
 			argIndex++
+			return fmt.Sprintf("$%d", argIndex-1)
+		})
+		if cond != "" {
+			query.WriteString(" AND " + cond)
+			args = append(args, condArgs...)
 		}
 	}
 
-	// // Add ordering and limit - use DESC order to get newest events first
-	// query.WriteString(" ORDER BY created_at DESC LIMIT $")
-	// Add ordering and limit
-	// Use ASC order for since-only filters to get oldest events since the timestamp
-	// Use DESC order for all other cases to get newest events first
-	if cf.Since != nil && cf.Until == nil {
-		query.WriteString(" ORDER BY created_at ASC LIMIT $")
-	} else {
-		query.WriteString(" ORDER BY created_at DESC LIMIT $")
-	}
+	// Add ordering and limit: oldest first for since-only filters, so the
+	// limit keeps the events right after since, newest first otherwise
+	query.WriteString(pageOrder(ascending, cf.Page != nil) + " LIMIT $")
 	query.WriteString(fmt.Sprintf("%d", argIndex))
 	args = append(args, cf.Limit)
 
 	return query.String(), args, nil
 }
+
+// droppedIndexDDL removes the (pubkey, kind, created_at) index earlier
+// versions created; author filters use events_pubkey_created_at, so it only
+// added write cost.
+const droppedIndexDDL = `DROP INDEX IF EXISTS events_pubkey_kind_created_at`
+
+// ensureQueryIndexes drops the indexes BuildQuery no longer relies on.
+func (db *DB) ensureQueryIndexes(ctx context.Context) error {
+	if _, err := db.Pool.Exec(ctx, droppedIndexDDL); err != nil {
+		return fmt.Errorf("failed to drop events_pubkey_kind_created_at: %w", err)
+	}
+	return nil
+}
+
+// mapKeys returns the keys of a compiled filter set.
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
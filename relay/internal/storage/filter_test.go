@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+var (
+	testPubkey = strings.Repeat("ab", 32)
+	testSince  = nostr.Timestamp(1_700_000_000)
+)
+
+// queryPlanCases are the filter shapes whose ORDER BY must be read in index
+// order, with the index expected to serve each.
+var queryPlanCases = []struct {
+	name   string
+	filter nostr.Filter
+	index  string // PostgreSQL index
+	order  string
+}{
+	{"recent", nostr.Filter{Limit: 10}, "events_created_at_desc", "ORDER BY created_at DESC"},
+	{"kind", nostr.Filter{Kinds: []int{1}, Limit: 10}, "events_kind_created_at", "ORDER BY created_at DESC"},
+	{"author", nostr.Filter{Authors: []string{testPubkey}, Limit: 10}, "events_pubkey_created_at", "ORDER BY created_at DESC"},
+	{"author and kind", nostr.Filter{Authors: []string{testPubkey}, Kinds: []int{1}, Limit: 10}, "events_pubkey_created_at", "ORDER BY created_at DESC"},
+	{"since only", nostr.Filter{Kinds: []int{1}, Since: &testSince, Limit: 10}, "events_kind_created_at", "ORDER BY created_at ASC"},
+	{"since and until", nostr.Filter{Kinds: []int{1}, Since: &testSince, Until: &testSince, Limit: 10}, "events_kind_created_at", "ORDER BY created_at DESC"},
+}
+
+func TestBuildQueryOrder(t *testing.T) {
+	for _, tc := range queryPlanCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, args, err := CompileFilter(tc.filter).BuildQuery()
+			if err != nil {
+				t.Fatalf("BuildQuery: %v", err)
+			}
+			if !strings.Contains(query, tc.order+" LIMIT $") {
+				t.Errorf("query %q does not end with %q", query, tc.order)
+			}
+			if got := args[len(args)-1]; got != 10 {
+				t.Errorf("limit argument = %v, want 10", got)
+			}
+		})
+	}
+}
+
+// TestBuildQueryExplain checks with EXPLAIN that PostgreSQL reads each filter
+// shape from its index without sorting. It needs a scratch database in
+// RELAY_TEST_POSTGRES_URL; the events table is created as a temporary table.
+func TestBuildQueryExplain(t *testing.T) {
+	url := os.Getenv("RELAY_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("RELAY_TEST_POSTGRES_URL not set")
+	}
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	for _, stmt := range []string{
+		`CREATE TEMP TABLE events (
+			id TEXT PRIMARY KEY, pubkey TEXT NOT NULL, created_at BIGINT NOT NULL,
+			kind INTEGER NOT NULL, tags JSONB NOT NULL, content TEXT NOT NULL, sig TEXT NOT NULL)`,
+		`CREATE INDEX events_created_at_desc ON events (created_at DESC)`,
+		`CREATE INDEX events_kind_created_at ON events (kind ASC, created_at ASC)`,
+		`CREATE INDEX events_pubkey_created_at ON events (pubkey ASC, created_at ASC)`,
+		`INSERT INTO events SELECT md5(i::text) || md5(i::text), CASE WHEN i % 100 = 0 THEN '` + testPubkey + `' ELSE md5((i % 1000)::text) END,
+			1700000000 + i, i % 20, '[]', '', '' FROM generate_series(1, 20000) i`,
+		`ANALYZE events`,
+	} {
+		if _, err := conn.Exec(ctx, stmt); err != nil {
+			t.Fatalf("setup %q: %v", stmt, err)
+		}
+	}
+
+	for _, tc := range queryPlanCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, args, err := CompileFilter(tc.filter).BuildQuery()
+			if err != nil {
+				t.Fatalf("BuildQuery: %v", err)
+			}
+			plan := explain(t, conn, query, args)
+			if !strings.Contains(plan, tc.index) {
+				t.Errorf("plan does not use %s:\n%s", tc.index, plan)
+			}
+			if strings.Contains(plan, "Sort") {
+				t.Errorf("plan sorts instead of reading in index order:\n%s", plan)
+			}
+		})
+	}
+}
+
+// explain returns the text plan of query.
+func explain(t *testing.T, conn *pgx.Conn, query string, args []interface{}) string {
+	t.Helper()
+	rows, err := conn.Query(context.Background(), "EXPLAIN "+query, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN %s: %v", query, err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			t.Fatalf("scan plan: %v", err)
+		}
+		plan = append(plan, line)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("EXPLAIN %s: %v", query, err)
+	}
+	return strings.Join(plan, "\n")
+}
+
+// testEvent returns an unsigned event stored with a made-up id.
+func testEvent(n int, kind int, createdAt nostr.Timestamp) nostr.Event {
+	return nostr.Event{
+		ID:        fmt.Sprintf("%064x", n),
+		PubKey:    testPubkey,
+		CreatedAt: createdAt,
+		Kind:      kind,
+		Tags:      nostr.Tags{},
+		Sig:       strings.Repeat("0", 128),
+	}
+}
@@ -0,0 +1,335 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Follow graph: the p tags of every pubkey's latest follow list (kind 3) are
+// copied into the follow_graph side table, so followers and follows are index
+// lookups instead of scans over every stored contact list. Each row points at
+// the follow list it came from, and goes away with it when the list is
+// replaced or deleted.
+
+// maxFollowsPerList bounds the edges recorded from one follow list.
+const maxFollowsPerList = 10000
+
+// followGraphDDL creates the follow graph side table, one row per follow edge.
+const followGraphDDL = `CREATE TABLE IF NOT EXISTS follow_graph (
+  follower CHAR(64) NOT NULL,
+  followed CHAR(64) NOT NULL,
+  event_id CHAR(64) NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  CONSTRAINT follow_graph_pkey PRIMARY KEY (follower, followed)
+)`
+
+// followGraphIndexDDL serves follower lookups and the cascade from events.
+var followGraphIndexDDL = []string{
+	`CREATE INDEX IF NOT EXISTS follow_graph_followed ON follow_graph (followed, follower)`,
+	`CREATE INDEX IF NOT EXISTS follow_graph_event ON follow_graph (event_id)`,
+}
+
+// FollowedPubkeys returns the distinct, valid p tags of a follow list in
+// lowercase hex.
+func FollowedPubkeys(evt *nostr.Event) []string {
+	var followed []string
+	seen := make(map[string]bool)
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "p" {
+			continue
+		}
+		pubkey := strings.ToLower(tag[1])
+		if seen[pubkey] || !nostr.IsValid32ByteHex(pubkey) {
+			continue
+		}
+		seen[pubkey] = true
+		followed = append(followed, pubkey)
+		if len(followed) >= maxFollowsPerList {
+			break
+		}
+	}
+	return followed
+}
+
+// ensureFollowGraphSchema creates the follow_graph table, indexing the
+// stored follow lists the first time.
+func (db *DB) ensureFollowGraphSchema(ctx context.Context) error {
+	var exists bool
+	if err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'follow_graph')`,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check follow_graph table: %w", err)
+	}
+
+	for _, ddl := range append([]string{followGraphDDL}, followGraphIndexDDL...) {
+		if _, err := db.Pool.Exec(ctx, ddl); err != nil {
+			return fmt.Errorf("failed to create follow_graph table: %w", err)
+		}
+	}
+	if exists {
+		return nil
+	}
+
+	start := time.Now()
+	rows, err := db.Pool.Query(ctx,
+		`SELECT DISTINCT ON (pubkey) id, pubkey, created_at, tags FROM events
+		 WHERE kind = 3 ORDER BY pubkey, created_at DESC, id`)
+	if err != nil {
+		return fmt.Errorf("failed to read stored follow lists: %w", err)
+	}
+	var lists []nostr.Event
+	for rows.Next() {
+		var evt nostr.Event
+		var createdAt int64
+		var rawTags []byte
+		if err := rows.Scan(&evt.ID, &evt.PubKey, &createdAt, &rawTags); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan follow list: %w", err)
+		}
+		if json.Unmarshal(rawTags, &evt.Tags) != nil {
+			continue
+		}
+		evt.Kind, evt.CreatedAt = nostr.KindFollowList, nostr.Timestamp(createdAt)
+		lists = append(lists, evt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read stored follow lists: %w", err)
+	}
+
+	for i := range lists {
+		if err := db.RecordFollowList(ctx, &lists[i]); err != nil {
+			return err
+		}
+	}
+	logger.Info("Indexed stored follow lists",
+		zap.Int("lists", len(lists)),
+		zap.Duration("took", time.Since(start)))
+	return nil
+}
+
+// RecordFollowList replaces the follows stored for the author of a kind 3
+// event. Older follow lists never overwrite newer ones.
+func (db *DB) RecordFollowList(ctx context.Context, evt *nostr.Event) error {
+	if evt.Kind != nostr.KindFollowList || !db.usesPool() {
+		return nil
+	}
+	follower := strings.ToLower(evt.PubKey)
+	followed := FollowedPubkeys(evt)
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin follow graph transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var newest int64
+	if err := tx.QueryRow(ctx,
+		`SELECT COALESCE(MAX(e.created_at), 0) FROM follow_graph g JOIN events e ON e.id = g.event_id
+		 WHERE g.follower = $1`,
+		follower).Scan(&newest); err != nil {
+		return fmt.Errorf("failed to read follow graph: %w", err)
+	}
+	if newest > int64(evt.CreatedAt) {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM follow_graph WHERE follower = $1`, follower); err != nil {
+		return fmt.Errorf("failed to clear follow graph: %w", err)
+	}
+	if len(followed) > 0 {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO follow_graph (follower, followed, event_id)
+			 SELECT $1::text, f, $3::text FROM unnest($2::text[]) AS f ON CONFLICT DO NOTHING`,
+			follower, followed, evt.ID); err != nil {
+			return fmt.Errorf("failed to insert follow graph: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit follow graph: %w", err)
+	}
+	return nil
+}
+
+// FollowLists returns the followed pubkeys of each author from their latest
+// follow list. Authors without one are left out.
+func (db *DB) FollowLists(ctx context.Context, authors []string) (map[string][]string, error) {
+	follows := make(map[string][]string, len(authors))
+	if len(authors) == 0 {
+		return follows, nil
+	}
+	if !db.usesPool() {
+		lists, err := db.latestFollowLists(ctx, nostr.Filter{Authors: authors})
+		if err != nil {
+			return nil, err
+		}
+		for _, evt := range lists {
+			follows[evt.PubKey] = FollowedPubkeys(&evt)
+		}
+		return follows, nil
+	}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT follower, followed FROM follow_graph WHERE follower = ANY($1)`, lowerAll(authors))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query follow graph: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var follower, followed string
+		if err := rows.Scan(&follower, &followed); err != nil {
+			return nil, fmt.Errorf("failed to scan follow graph: %w", err)
+		}
+		follows[follower] = append(follows[follower], followed)
+	}
+	return follows, rows.Err()
+}
+
+// Following returns up to limit pubkeys followed by pubkey in hex order,
+// starting after the pubkey after. Reads are scoped to the tenant of ctx.
+func (db *DB) Following(ctx context.Context, pubkey, after string, limit int) ([]string, error) {
+	pubkey = strings.ToLower(pubkey)
+	if !db.usesPool() {
+		lists, err := db.latestFollowLists(ctx, nostr.Filter{Authors: []string{pubkey}})
+		if err != nil || len(lists) == 0 {
+			return nil, err
+		}
+		return pageSorted(FollowedPubkeys(&lists[0]), after, limit), nil
+	}
+	return db.queryFollowGraph(ctx, "followed", "follower", pubkey, after, limit)
+}
+
+// Followers returns up to limit pubkeys following pubkey in hex order,
+// starting after the pubkey after. Reads are scoped to the tenant of ctx.
+func (db *DB) Followers(ctx context.Context, pubkey, after string, limit int) ([]string, error) {
+	pubkey = strings.ToLower(pubkey)
+	if !db.usesPool() {
+		lists, err := db.latestFollowLists(ctx, nostr.Filter{Tags: nostr.TagMap{"p": []string{pubkey}}})
+		if err != nil {
+			return nil, err
+		}
+		var followers []string
+		for _, evt := range lists {
+			if slices.Contains(FollowedPubkeys(&evt), pubkey) {
+				followers = append(followers, evt.PubKey)
+			}
+		}
+		return pageSorted(followers, after, limit), nil
+	}
+	return db.queryFollowGraph(ctx, "follower", "followed", pubkey, after, limit)
+}
+
+// FollowCounts returns how many pubkeys follow pubkey and how many it follows.
+func (db *DB) FollowCounts(ctx context.Context, pubkey string) (followers, following int, err error) {
+	pubkey = strings.ToLower(pubkey)
+	if !db.usesPool() {
+		followerList, err := db.Followers(ctx, pubkey, "", maxFollowsPerList*10)
+		if err != nil {
+			return 0, 0, err
+		}
+		followingList, err := db.Following(ctx, pubkey, "", maxFollowsPerList)
+		if err != nil {
+			return 0, 0, err
+		}
+		return len(followerList), len(followingList), nil
+	}
+
+	for _, c := range []struct {
+		column string
+		dest   *int
+	}{{"followed", &followers}, {"follower", &following}} {
+		query := `SELECT COUNT(*) FROM follow_graph g JOIN events ON events.id = g.event_id WHERE g.` + c.column + ` = $1`
+		args := []interface{}{pubkey}
+		if tenant, ok := TenantFromContext(ctx); ok {
+			cond, condArgs := tenantCondition(tenant, 2)
+			query += " AND " + cond
+			args = append(args, condArgs...)
+		}
+		if err := db.Pool.QueryRow(ctx, query, args...).Scan(c.dest); err != nil {
+			return 0, 0, fmt.Errorf("failed to count follow graph: %w", err)
+		}
+	}
+	return followers, following, nil
+}
+
+// queryFollowGraph returns a page of the column values of the rows whose key
+// column is pubkey.
+func (db *DB) queryFollowGraph(ctx context.Context, column, key, pubkey, after string, limit int) ([]string, error) {
+	query := `SELECT g.` + column + ` FROM follow_graph g JOIN events ON events.id = g.event_id
+	 WHERE g.` + key + ` = $1 AND g.` + column + ` > $2`
+	args := []interface{}{pubkey, strings.ToLower(after)}
+	if tenant, ok := TenantFromContext(ctx); ok {
+		cond, condArgs := tenantCondition(tenant, len(args)+1)
+		query += " AND " + cond
+		args = append(args, condArgs...)
+	}
+	query += fmt.Sprintf(" ORDER BY g.%s LIMIT $%d", column, len(args)+1)
+	args = append(args, limit)
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query follow graph: %w", err)
+	}
+	defer rows.Close()
+
+	var pubkeys []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("failed to scan follow graph: %w", err)
+		}
+		pubkeys = append(pubkeys, p)
+	}
+	return pubkeys, rows.Err()
+}
+
+// latestFollowLists returns the latest stored follow list of each author
+// matching f, for drivers without the follow_graph table.
+func (db *DB) latestFollowLists(ctx context.Context, f nostr.Filter) ([]nostr.Event, error) {
+	f.Kinds = []int{nostr.KindFollowList}
+	f.Limit = maxFollowsPerList * 10
+	events, err := db.GetEvents(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]int, len(events))
+	var lists []nostr.Event
+	for _, evt := range events {
+		if i, ok := latest[evt.PubKey]; ok {
+			if evt.CreatedAt > lists[i].CreatedAt {
+				lists[i] = evt
+			}
+			continue
+		}
+		latest[evt.PubKey] = len(lists)
+		lists = append(lists, evt)
+	}
+	return lists, nil
+}
+
+// pageSorted returns up to limit of pubkeys in order, starting after after.
+func pageSorted(pubkeys []string, after string, limit int) []string {
+	slices.SortFunc(pubkeys, cmp.Compare[string])
+	start, _ := slices.BinarySearch(pubkeys, after)
+	if start < len(pubkeys) && pubkeys[start] == after {
+		start++
+	}
+	return pubkeys[start:min(len(pubkeys), start+limit)]
+}
+
+// lowerAll returns values in lowercase.
+func lowerAll(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToLower(v)
+	}
+	return out
+}
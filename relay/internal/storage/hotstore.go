@@ -0,0 +1,531 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/dgraph-io/badger/v4"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Hot store: an embedded Badger tier holding the most recent events so the
+// bulk of REQs never reach SQL. The SQL store stays the source of truth; the
+// hot store only answers a filter when it can prove the answer is complete.
+//
+// Key layout (ts = 8-byte big-endian created_at, id = hex event ID):
+//   e<id>                          -> event JSON
+//   c<ts><id>                      -> created_at index
+//   a<pubkey><ts><id>              -> author index
+//   k<kind:4><ts><id>              -> kind index
+//   r<pubkey><kind:4><ts><id>      -> author+kind index (replaceable lookups)
+//   t<name>\x00<value>\x00<ts><id> -> single-letter tag index
+//
+// Every key of an event carries the same TTL, so events age out of the
+// window without a separate pruning pass.
+
+const (
+	hotBackfillPage = 1000
+	hotDefaultLimit = 500 // same default as CompileFilter
+)
+
+// HotStore is the recent-events tier in front of SQL.
+type HotStore struct {
+	kv     *badger.DB
+	window time.Duration
+
+	// coveredSince is the unix time from which the hot store is known to hold
+	// every stored event. It starts at open time and moves back to the edge of
+	// the window once the backfill from SQL completes.
+	coveredSince atomic.Int64
+}
+
+// OpenHotStore opens (creating if needed) a hot store at path keeping events for window.
+func OpenHotStore(path string, window time.Duration) (*HotStore, error) {
+	opts := badger.DefaultOptions(path).WithLogger(nil)
+	kv, err := badger.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hot store: %w", err)
+	}
+	hs := &HotStore{kv: kv, window: window}
+	hs.coveredSince.Store(time.Now().Unix())
+	return hs, nil
+}
+
+// Close flushes and closes the hot store.
+func (hs *HotStore) Close() error {
+	return hs.kv.Close()
+}
+
+// cutoff is the oldest created_at the hot store can answer for.
+func (hs *HotStore) cutoff() int64 {
+	return max(hs.coveredSince.Load(), time.Now().Add(-hs.window).Unix())
+}
+
+func hotTS(ts nostr.Timestamp) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(ts))
+	return b
+}
+
+func hotKind(kind int) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(kind))
+	return b
+}
+
+func hotKey(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// hotTagPrefix is the index prefix for one tag name/value pair.
+func hotTagPrefix(name, value string) []byte {
+	return hotKey([]byte("t"), []byte(name), []byte{0}, []byte(value), []byte{0})
+}
+
+// indexKeys returns every index key of an event (not including the e<id> record).
+func hotIndexKeys(evt *nostr.Event) [][]byte {
+	ts, id := hotTS(evt.CreatedAt), []byte(evt.ID)
+	keys := [][]byte{
+		hotKey([]byte("c"), ts, id),
+		hotKey([]byte("a"), []byte(evt.PubKey), ts, id),
+		hotKey([]byte("k"), hotKind(evt.Kind), ts, id),
+		hotKey([]byte("r"), []byte(evt.PubKey), hotKind(evt.Kind), ts, id),
+	}
+	seen := make(map[string]bool)
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || len(tag[0]) != 1 || seen[tag[0]+"\x00"+tag[1]] {
+			continue
+		}
+		seen[tag[0]+"\x00"+tag[1]] = true
+		keys = append(keys, hotKey(hotTagPrefix(tag[0], tag[1]), ts, id))
+	}
+	return keys
+}
+
+// ttlFor returns how long an event belongs in the hot store, or 0 if it does not.
+func (hs *HotStore) ttlFor(evt *nostr.Event) time.Duration {
+	expires := evt.CreatedAt.Time().Add(hs.window)
+	if exp := evt.Tags.GetFirst([]string{"expiration", ""}); exp != nil && len(*exp) >= 2 {
+		if unix, err := strconv.ParseInt((*exp)[1], 10, 64); err == nil {
+			if at := time.Unix(unix, 0); at.Before(expires) {
+				expires = at
+			}
+		}
+	}
+	ttl := time.Until(expires)
+	if ttl <= 0 {
+		return 0
+	}
+	return ttl
+}
+
+// Put records a newly stored event, applying the same replacement and
+// deletion semantics as the SQL store.
+func (hs *HotStore) Put(evt *nostr.Event) error {
+	if nips.IsEphemeral(evt.Kind) {
+		return nil
+	}
+	return hs.kv.Update(func(txn *badger.Txn) error {
+		switch {
+		case nips.IsVanishEvent(*evt):
+			if err := hs.deleteMatching(txn, hotKey([]byte("a"), []byte(evt.PubKey)), func(old *nostr.Event) bool {
+				return old.CreatedAt <= evt.CreatedAt
+			}); err != nil {
+				return err
+			}
+		case nips.IsDeletionEvent(*evt):
+			if err := hs.applyDeletion(txn, evt); err != nil {
+				return err
+			}
+		case nips.IsReplaceable(evt.Kind):
+			if err := hs.deleteMatching(txn, hotKey([]byte("r"), []byte(evt.PubKey), hotKind(evt.Kind)), func(*nostr.Event) bool {
+				return true
+			}); err != nil {
+				return err
+			}
+		case nips.IsAddressable(*evt):
+			d := evt.Tags.GetD()
+			if err := hs.deleteMatching(txn, hotKey([]byte("r"), []byte(evt.PubKey), hotKind(evt.Kind)), func(old *nostr.Event) bool {
+				return old.Tags.GetD() == d
+			}); err != nil {
+				return err
+			}
+		}
+		return hs.setEvent(txn, evt)
+	})
+}
+
+// Remove deletes an event from the hot store.
+func (hs *HotStore) Remove(evt *nostr.Event) error {
+	return hs.kv.Update(func(txn *badger.Txn) error {
+		return hs.deleteEvent(txn, evt)
+	})
+}
+
+// setEvent writes the event record and its index keys with a shared TTL.
+func (hs *HotStore) setEvent(txn *badger.Txn, evt *nostr.Event) error {
+	ttl := hs.ttlFor(evt)
+	if ttl == 0 {
+		return nil
+	}
+	raw, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	if err := txn.SetEntry(badger.NewEntry(hotKey([]byte("e"), []byte(evt.ID)), raw).WithTTL(ttl)); err != nil {
+		return err
+	}
+	for _, key := range hotIndexKeys(evt) {
+		if err := txn.SetEntry(badger.NewEntry(key, nil).WithTTL(ttl)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDeletion removes the events a NIP-09 request refers to.
+func (hs *HotStore) applyDeletion(txn *badger.Txn, del *nostr.Event) error {
+	eIDs, aTags := deletionTargets(*del)
+	for _, id := range eIDs {
+		old, err := hs.getEvent(txn, id)
+		if err != nil || old == nil || old.PubKey != del.PubKey {
+			continue
+		}
+		if err := hs.deleteEvent(txn, old); err != nil {
+			return err
+		}
+	}
+	for _, tag := range aTags {
+		parts := strings.SplitN(tag[1], ":", 3)
+		if len(parts) != 3 || parts[1] != del.PubKey {
+			continue
+		}
+		kind, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		if err := hs.deleteMatching(txn, hotKey([]byte("r"), []byte(del.PubKey), hotKind(kind)), func(old *nostr.Event) bool {
+			return old.Tags.GetD() == parts[2] && old.CreatedAt <= del.CreatedAt
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteMatching deletes the events under an index prefix accepted by match.
+func (hs *HotStore) deleteMatching(txn *badger.Txn, prefix []byte, match func(*nostr.Event) bool) error {
+	var victims []*nostr.Event
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	for it.Rewind(); it.Valid(); it.Next() {
+		key := it.Item().Key()
+		old, err := hs.getEvent(txn, string(key[len(key)-64:]))
+		if err != nil || old == nil {
+			continue
+		}
+		if match(old) {
+			victims = append(victims, old)
+		}
+	}
+	it.Close()
+
+	for _, old := range victims {
+		if err := hs.deleteEvent(txn, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteEvent removes an event record and all its index keys.
+func (hs *HotStore) deleteEvent(txn *badger.Txn, evt *nostr.Event) error {
+	if err := txn.Delete(hotKey([]byte("e"), []byte(evt.ID))); err != nil {
+		return err
+	}
+	for _, key := range hotIndexKeys(evt) {
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getEvent loads an event record, returning nil if it is absent.
+func (hs *HotStore) getEvent(txn *badger.Txn, id string) (*nostr.Event, error) {
+	item, err := txn.Get(hotKey([]byte("e"), []byte(id)))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var evt nostr.Event
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &evt)
+	}); err != nil {
+		return nil, err
+	}
+	return &evt, nil
+}
+
+// hotScanPrefixes picks the most selective index for a filter.
+func hotScanPrefixes(filter nostr.Filter) [][]byte {
+	var prefixes [][]byte
+	switch {
+	case len(filter.Authors) > 0 && len(filter.Kinds) > 0:
+		for _, author := range filter.Authors {
+			for _, kind := range filter.Kinds {
+				prefixes = append(prefixes, hotKey([]byte("r"), []byte(author), hotKind(kind)))
+			}
+		}
+	case len(filter.Authors) > 0:
+		for _, author := range filter.Authors {
+			prefixes = append(prefixes, hotKey([]byte("a"), []byte(author)))
+		}
+	case len(filter.Tags) > 0:
+		// Use the tag with the fewest values; every match must carry one of them
+		var best string
+		for name, values := range filter.Tags {
+			if len(values) > 0 && (best == "" || len(values) < len(filter.Tags[best])) {
+				best = name
+			}
+		}
+		if best == "" {
+			return [][]byte{[]byte("c")}
+		}
+		for _, value := range filter.Tags[best] {
+			prefixes = append(prefixes, hotTagPrefix(best, value))
+		}
+	case len(filter.Kinds) > 0:
+		for _, kind := range filter.Kinds {
+			prefixes = append(prefixes, hotKey([]byte("k"), hotKind(kind)))
+		}
+	default:
+		prefixes = append(prefixes, []byte("c"))
+	}
+	return prefixes
+}
+
+// Query answers a filter from the hot store. ok is false when the result
+// could be incomplete and the caller must fall back to SQL.
+func (hs *HotStore) Query(filter nostr.Filter) ([]nostr.Event, bool) {
+	// Its indexes are keyed by full IDs and pubkeys
+	if filter.Search != "" || HasPrefixes(filter) {
+		return nil, false
+	}
+	cutoff := hs.cutoff()
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = hotDefaultLimit
+	}
+
+	var events []nostr.Event
+	err := hs.kv.View(func(txn *badger.Txn) error {
+		if len(filter.IDs) > 0 {
+			for _, id := range filter.IDs {
+				evt, err := hs.getEvent(txn, id)
+				if err != nil {
+					return err
+				}
+				if evt == nil {
+					// Could be older than the window
+					return errHotIncomplete
+				}
+				if filter.Matches(evt) {
+					events = append(events, *evt)
+				}
+			}
+			return nil
+		}
+
+		seen := make(map[string]bool)
+		for _, prefix := range hotScanPrefixes(filter) {
+			if err := hs.scanPrefix(txn, prefix, filter, limit, seen, &events); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, errHotIncomplete) {
+			logger.Warn("Hot store query failed", zap.Error(err))
+		}
+		return nil, false
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CreatedAt > events[j].CreatedAt
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	// Complete when the filter stays inside the covered range, or when the
+	// newest `limit` matches all fall inside it
+	complete := len(filter.IDs) > 0 ||
+		(filter.Since != nil && int64(*filter.Since) >= cutoff) ||
+		(len(events) == limit && int64(events[len(events)-1].CreatedAt) >= cutoff)
+	if !complete {
+		return nil, false
+	}
+	return events, true
+}
+
+// errHotIncomplete aborts a hot store lookup that cannot be answered completely.
+var errHotIncomplete = errors.New("hot store result incomplete")
+
+// scanPrefix walks one index newest-first, collecting up to limit matches.
+func (hs *HotStore) scanPrefix(txn *badger.Txn, prefix []byte, filter nostr.Filter, limit int, seen map[string]bool, out *[]nostr.Event) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Reverse = true
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	// Reverse iteration starts at the last key <= seek
+	seek := hotKey(prefix, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	if filter.Until != nil {
+		seek = hotKey(prefix, hotTS(*filter.Until), []byte{0xff})
+	}
+
+	found := 0
+	for it.Seek(seek); it.Valid() && found < limit; it.Next() {
+		key := it.Item().Key()
+		if len(key) < len(prefix)+8+64 {
+			continue
+		}
+		ts := int64(binary.BigEndian.Uint64(key[len(key)-72 : len(key)-64]))
+		if filter.Since != nil && ts < int64(*filter.Since) {
+			break
+		}
+		id := string(key[len(key)-64:])
+		if seen[id] {
+			continue
+		}
+		evt, err := hs.getEvent(txn, id)
+		if err != nil {
+			return err
+		}
+		if evt == nil || !filter.Matches(evt) {
+			continue
+		}
+		seen[id] = true
+		*out = append(*out, *evt)
+		found++
+	}
+	return nil
+}
+
+// Backfill loads the window's events from SQL, then marks the whole window as covered.
+func (hs *HotStore) Backfill(ctx context.Context, db *DB) {
+	start := time.Now()
+	since := nostr.Timestamp(start.Add(-hs.window).Unix())
+	until := nostr.Timestamp(start.Unix())
+	loaded := 0
+
+	for ctx.Err() == nil {
+		s, u := since, until
+		events, err := db.GetEvents(ctx, nostr.Filter{Since: &s, Until: &u, Limit: hotBackfillPage})
+		if err != nil {
+			logger.Warn("Hot store backfill failed", zap.Error(err))
+			return
+		}
+		oldest := until
+		if err := hs.kv.Update(func(txn *badger.Txn) error {
+			for i := range events {
+				if err := hs.setEvent(txn, &events[i]); err != nil {
+					return err
+				}
+				oldest = min(oldest, events[i].CreatedAt)
+			}
+			return nil
+		}); err != nil {
+			logger.Warn("Hot store backfill write failed", zap.Error(err))
+			return
+		}
+		loaded += len(events)
+		if len(events) < hotBackfillPage || oldest <= since {
+			break
+		}
+		until = oldest - 1
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	hs.coveredSince.Store(int64(since))
+	logger.Info("✅ Hot store backfill completed",
+		zap.Int("events", loaded),
+		zap.Duration("window", hs.window),
+		zap.Duration("duration", time.Since(start)))
+}
+
+// StartGC runs Badger value-log garbage collection until ctx is canceled.
+func (hs *HotStore) StartGC(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for hs.kv.RunValueLogGC(0.5) == nil {
+				}
+			}
+		}
+	}()
+}
+
+// SetHotStore attaches the recent-events tier consulted before SQL.
+func (db *DB) SetHotStore(hs *HotStore) {
+	db.hotStore = hs
+}
+
+// queryHotStore serves a filter from the hot store when it can answer completely.
+func (db *DB) queryHotStore(filter nostr.Filter) ([]nostr.Event, bool) {
+	if db.hotStore == nil {
+		return nil, false
+	}
+	events, ok := db.hotStore.Query(filter)
+	if ok {
+		metrics.HotStoreLookups.WithLabelValues("hit").Inc()
+		// Same ordering as the SQL path, which Query returns newest first
+		if filter.Since != nil && filter.Until == nil {
+			slices.Reverse(events)
+		}
+	} else {
+		metrics.HotStoreLookups.WithLabelValues("miss").Inc()
+	}
+	return events, ok
+}
+
+// putHotStore mirrors a stored event into the hot store.
+func (db *DB) putHotStore(evt *nostr.Event) {
+	if db.hotStore == nil {
+		return
+	}
+	if err := db.hotStore.Put(evt); err != nil {
+		logger.Warn("Failed to write event to hot store",
+			zap.String("event_id", evt.ID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/Shugur-Network/relay/internal/tracing"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Micro-batched inserts: regular events taken off the queue within a few
+// milliseconds of each other share one transaction. Workers hand events to
+// the batcher and move on to the next one; the post-insert steps (Bloom
+// filter, dispatch, metrics) and the durable acknowledgement run once the
+// batch is committed, so nothing downstream sees an event before that.
+
+// batchInsertTimeout bounds one batch transaction.
+const batchInsertTimeout = 3 * time.Second
+
+// pendingInsert is an event waiting for its batch to be flushed.
+type pendingInsert struct {
+	ctx  context.Context
+	evt  nostr.Event
+	span *tracing.Span
+	done chan error // receives the storage outcome, nil when nobody waits
+}
+
+// insertBatcher groups regular events into batches flushed when maxSize
+// events are pending or maxDelay after the first one arrived.
+type insertBatcher struct {
+	ep       *EventProcessor
+	maxSize  int
+	maxDelay time.Duration
+	slots    chan struct{} // bounds the batches being written at once
+
+	mu      sync.Mutex
+	pending []pendingInsert
+	timer   *time.Timer
+}
+
+// EnableInsertBatching makes the processor store regular events in batches
+// of up to maxSize, capped at the worker count, waiting at most maxDelay for
+// a batch to fill. Other drivers store events one by one anyway, so it only
+// applies to PostgreSQL.
+func (ep *EventProcessor) EnableInsertBatching(maxSize int, maxDelay time.Duration) {
+	if maxSize <= 1 || maxDelay <= 0 || !ep.db.usesPool() {
+		return
+	}
+	maxSize = min(maxSize, ep.workerCount)
+	ep.batcher = &insertBatcher{
+		ep:       ep,
+		maxSize:  maxSize,
+		maxDelay: maxDelay,
+		slots:    make(chan struct{}, ep.workerCount),
+	}
+	logger.Info("Insert batching enabled",
+		zap.Int("max_size", maxSize),
+		zap.Duration("max_delay", maxDelay))
+}
+
+// batches reports whether evt is stored through the batcher: regular events
+// only, the others need their own statements.
+func (b *insertBatcher) batches(evt nostr.Event) bool {
+	return !nips.IsEphemeral(evt.Kind) &&
+		!nips.IsVanishEvent(evt) &&
+		!nips.IsDeletionEvent(evt) &&
+		!nips.IsReplaceable(evt.Kind) &&
+		!nips.IsAddressable(evt)
+}
+
+// add hands p to the current batch without waiting for it to be written.
+// Filling a batch blocks only while every write slot is busy, which keeps
+// the queue's backpressure.
+func (b *insertBatcher) add(p pendingInsert) {
+	if b.ep.db.Bloom.Test([]byte(p.evt.ID)) {
+		b.complete(p, b.ep.finishEvent(p.evt, nil))
+		return
+	}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, p)
+	switch {
+	case len(b.pending) >= b.maxSize:
+		batch := b.take()
+		b.mu.Unlock()
+		b.slots <- struct{}{}
+		go b.flush(batch)
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.maxDelay, b.flushPending)
+		b.mu.Unlock()
+	default:
+		b.mu.Unlock()
+	}
+}
+
+// take removes the pending batch. Callers hold b.mu.
+func (b *insertBatcher) take() []pendingInsert {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// flushPending writes the pending batch when its delay has passed.
+func (b *insertBatcher) flushPending() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+	b.slots <- struct{}{}
+	b.flush(batch)
+}
+
+// flush writes a batch with BatchInsertEvents and releases its write slot.
+// When the batch fails, its events are stored one by one so a single bad
+// event cannot fail the others.
+func (b *insertBatcher) flush(batch []pendingInsert) {
+	defer func() { <-b.slots }()
+
+	events := make([]nostr.Event, len(batch))
+	for i, p := range batch {
+		events[i] = p.evt
+	}
+	// The batch is stamped with the received_at of its first event
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(batch[0].ctx), batchInsertTimeout)
+	err := b.ep.db.BatchInsertEvents(ctx, events)
+	cancel()
+	if err != nil {
+		logger.Debug("Batch insert failed, inserting events one by one",
+			zap.Int("events", len(batch)),
+			zap.Error(err))
+	}
+
+	for _, p := range batch {
+		if err == nil {
+			b.complete(p, b.ep.finishEvent(p.evt, nil))
+		} else {
+			b.complete(p, b.ep.processEvent(p.ctx, p.evt))
+		}
+	}
+}
+
+// complete ends the storage span of p and reports its outcome.
+func (b *insertBatcher) complete(p pendingInsert, err error) {
+	p.span.RecordError(err)
+	p.span.End()
+	if p.done != nil {
+		p.done <- err
+	}
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// NIP-43 invite claims: the invite codes the relay hands out in kind 28935
+// events. Each code is stored when issued and marked consumed when a kind
+// 28934 join request redeems it, so a claim cannot be redeemed again after a
+// restart. Rows are kept until they have been expired for a while.
+
+// InviteClaim is one issued invite code.
+type InviteClaim struct {
+	Code      string `json:"code"`
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+	UsedBy    string `json:"used_by,omitempty"` // empty while unused
+	UsedAt    int64  `json:"used_at,omitempty"`
+}
+
+// inviteClaimsDDL creates the invite claims table, keyed by invite code.
+const inviteClaimsDDL = `CREATE TABLE IF NOT EXISTS invite_claims (
+  code TEXT NOT NULL PRIMARY KEY,
+  created_by TEXT NOT NULL DEFAULT '',
+  created_at BIGINT NOT NULL,
+  expires_at BIGINT NOT NULL,
+  used_by TEXT NOT NULL DEFAULT '',
+  used_at BIGINT NOT NULL DEFAULT 0
+)`
+
+// ensureInviteClaimsSchema creates the invite_claims table if it does not exist.
+func (db *DB) ensureInviteClaimsSchema(ctx context.Context) error {
+	if _, err := db.Pool.Exec(ctx, inviteClaimsDDL); err != nil {
+		return fmt.Errorf("failed to create invite_claims table: %w", err)
+	}
+	return nil
+}
+
+// SaveInviteClaim records an issued invite code.
+func (db *DB) SaveInviteClaim(ctx context.Context, c InviteClaim) error {
+	if !db.isConnected() {
+		return fmt.Errorf("database is not connected")
+	}
+	return db.backend.SaveInviteClaim(ctx, c)
+}
+
+// SaveInviteClaim inserts an invite code; a code already stored is left as is.
+func (p *postgresBackend) SaveInviteClaim(ctx context.Context, c InviteClaim) error {
+	_, err := p.db.Pool.Exec(ctx,
+		`INSERT INTO invite_claims (code, created_by, created_at, expires_at)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (code) DO NOTHING`,
+		c.Code, c.CreatedBy, c.CreatedAt, c.ExpiresAt)
+	return err
+}
+
+// ConsumeInviteClaim marks an unused, unexpired invite code as redeemed by
+// pubkey at usedAt. It reports false when the code is unknown, expired or
+// already redeemed, so only one redemption of a code ever succeeds.
+func (db *DB) ConsumeInviteClaim(ctx context.Context, code, pubkey string, usedAt int64) (bool, error) {
+	if !db.isConnected() {
+		return false, fmt.Errorf("database is not connected")
+	}
+	return db.backend.ConsumeInviteClaim(ctx, code, pubkey, usedAt)
+}
+
+// ConsumeInviteClaim redeems an invite code in a single conditional update.
+func (p *postgresBackend) ConsumeInviteClaim(ctx context.Context, code, pubkey string, usedAt int64) (bool, error) {
+	result, err := p.db.Pool.Exec(ctx,
+		`UPDATE invite_claims SET used_by = $2, used_at = $3
+		 WHERE code = $1 AND used_by = '' AND expires_at > $3`,
+		code, pubkey, usedAt)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// ListInviteClaims returns the invite codes expiring after expiresAfter.
+func (db *DB) ListInviteClaims(ctx context.Context, expiresAfter int64) ([]InviteClaim, error) {
+	if !db.isConnected() {
+		return nil, fmt.Errorf("database is not connected")
+	}
+	return db.backend.ListInviteClaims(ctx, expiresAfter)
+}
+
+// ListInviteClaims returns the invite codes expiring after expiresAfter, oldest first.
+func (p *postgresBackend) ListInviteClaims(ctx context.Context, expiresAfter int64) ([]InviteClaim, error) {
+	rows, err := p.db.Pool.Query(ctx,
+		`SELECT code, created_by, created_at, expires_at, used_by, used_at
+		 FROM invite_claims WHERE expires_at > $1 ORDER BY created_at`, expiresAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invite claims: %w", err)
+	}
+	defer rows.Close()
+
+	var claims []InviteClaim
+	for rows.Next() {
+		var c InviteClaim
+		if err := rows.Scan(&c.Code, &c.CreatedBy, &c.CreatedAt, &c.ExpiresAt, &c.UsedBy, &c.UsedAt); err != nil {
+			return nil, err
+		}
+		claims = append(claims, c)
+	}
+	return claims, rows.Err()
+}
+
+// PruneInviteClaims deletes the invite codes that expired before the given Unix time.
+func (db *DB) PruneInviteClaims(ctx context.Context, before int64) (int, error) {
+	if !db.isConnected() {
+		return 0, fmt.Errorf("database is not connected")
+	}
+	return db.backend.PruneInviteClaims(ctx, before)
+}
+
+// PruneInviteClaims deletes invite codes expired before the given Unix time.
+func (p *postgresBackend) PruneInviteClaims(ctx context.Context, before int64) (int, error) {
+	result, err := p.db.Pool.Exec(ctx, `DELETE FROM invite_claims WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Label index: every stored kind 1985 label event (NIP-32) is recorded in the
+// event_labels side table once per label and target, so the events a labeler
+// marked ugc/spam are found by a lookup on the label instead of a scan of
+// every label event. Rows are removed with their label event through the
+// foreign key.
+
+// eventLabelsDDL creates the label index side table, one row per label and
+// target of a label event.
+const eventLabelsDDL = `CREATE TABLE IF NOT EXISTS event_labels (
+  label_id CHAR(64) NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  namespace TEXT NOT NULL,
+  label TEXT NOT NULL,
+  target_type CHAR(1) NOT NULL,
+  target TEXT NOT NULL,
+  CONSTRAINT event_labels_pkey PRIMARY KEY (label_id, namespace, label, target_type, target)
+)`
+
+// eventLabelsIndexDDL serves label lookups; eventLabelsTargetIndexDDL serves
+// the labels of a target.
+const (
+	eventLabelsIndexDDL       = `CREATE INDEX IF NOT EXISTS event_labels_label ON event_labels (namespace, label, target_type, label_id)`
+	eventLabelsTargetIndexDDL = `CREATE INDEX IF NOT EXISTS event_labels_target ON event_labels (target_type, target)`
+)
+
+// labelBackfillBatch is the number of index rows written per statement when
+// indexing the labels stored before the table existed.
+const labelBackfillBatch = 1000
+
+// maxLabelRows bounds the rows one label event adds, so a label event with
+// many labels and targets cannot flood the index.
+const maxLabelRows = 1000
+
+// LabelRecord is one row of the label index: a label a labeler applied to a
+// target with a label event.
+type LabelRecord struct {
+	LabelID    string `json:"label_id"` // ID of the kind 1985 event
+	Labeler    string `json:"labeler"`
+	Namespace  string `json:"namespace"`
+	Label      string `json:"label"`
+	TargetType string `json:"target_type"` // e, p, a, r or t
+	Target     string `json:"target"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+// LabelQuery selects label records, newest first. Empty fields match every
+// record; Namespace defaults to "ugc" when Label is set.
+type LabelQuery struct {
+	Namespace  string
+	Label      string
+	Labelers   []string
+	TargetType string
+	Target     string
+	Targets    []string // any of them, with TargetType
+	Until      int64    // > 0 skips records created after it
+	Limit      int
+}
+
+// labelRows returns the label index rows of a label event as parallel
+// namespace, label, target type and target slices.
+func labelRows(evt *nostr.Event) (namespaces, labels, types, targets []string) {
+	ls, ts := nips.LabelsOf(evt)
+	for _, l := range ls {
+		for _, t := range ts {
+			if len(namespaces) >= maxLabelRows {
+				return
+			}
+			namespaces = append(namespaces, l.Namespace)
+			labels = append(labels, l.Value)
+			types = append(types, t.Type)
+			targets = append(targets, t.Value)
+		}
+	}
+	return
+}
+
+// ensureLabelSchema creates the event_labels table, indexing the labels
+// already stored the first time.
+func (db *DB) ensureLabelSchema(ctx context.Context) error {
+	var exists bool
+	if err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'event_labels')`,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check event_labels table: %w", err)
+	}
+
+	for _, ddl := range []string{eventLabelsDDL, eventLabelsIndexDDL, eventLabelsTargetIndexDDL} {
+		if _, err := db.Pool.Exec(ctx, ddl); err != nil {
+			return fmt.Errorf("failed to create event_labels table: %w", err)
+		}
+	}
+	if exists {
+		return nil
+	}
+
+	start := time.Now()
+	indexed, err := db.backfillLabels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to index labels of stored events: %w", err)
+	}
+	logger.Info("Indexed labels of stored events",
+		zap.Int("rows", indexed),
+		zap.Duration("took", time.Since(start)))
+	return nil
+}
+
+// backfillLabels records the stored label events in event_labels.
+func (db *DB) backfillLabels(ctx context.Context) (int, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT id, tags FROM events WHERE kind = $1`, nips.KindLabel)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids, namespaces, labels, types, targets []string
+	for rows.Next() {
+		evt := nostr.Event{Kind: nips.KindLabel}
+		var rawTags []byte
+		if err := rows.Scan(&evt.ID, &rawTags); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if json.Unmarshal(rawTags, &evt.Tags) != nil {
+			continue
+		}
+		n, l, ty, t := labelRows(&evt)
+		for range n {
+			ids = append(ids, evt.ID)
+		}
+		namespaces = append(namespaces, n...)
+		labels = append(labels, l...)
+		types = append(types, ty...)
+		targets = append(targets, t...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for start := 0; start < len(ids); start += labelBackfillBatch {
+		end := min(start+labelBackfillBatch, len(ids))
+		if _, err := db.Pool.Exec(ctx,
+			`INSERT INTO event_labels (label_id, namespace, label, target_type, target)
+			 SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[]) ON CONFLICT DO NOTHING`,
+			ids[start:end], namespaces[start:end], labels[start:end], types[start:end], targets[start:end]); err != nil {
+			return start, err
+		}
+	}
+	return len(ids), nil
+}
+
+// indexLabels records a newly stored label event in the label index.
+// Drivers without the table find labels through the tag index instead.
+func (db *DB) indexLabels(ctx context.Context, evt *nostr.Event) {
+	if evt.Kind != nips.KindLabel || !db.usesPool() {
+		return
+	}
+	namespaces, labels, types, targets := labelRows(evt)
+	if len(namespaces) == 0 {
+		return
+	}
+	ids := make([]string, len(namespaces))
+	for i := range ids {
+		ids[i] = evt.ID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	if _, err := db.Pool.Exec(ctx,
+		`INSERT INTO event_labels (label_id, namespace, label, target_type, target)
+		 SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[]) ON CONFLICT DO NOTHING`,
+		ids, namespaces, labels, types, targets); err != nil {
+		logger.Warn("Failed to index labels",
+			zap.String("event_id", evt.ID),
+			zap.Error(err))
+	}
+}
+
+// Labels returns up to q.Limit label records matching q, newest first.
+// Reads are scoped to the tenant of ctx like GetEvents.
+func (db *DB) Labels(ctx context.Context, q LabelQuery) ([]LabelRecord, error) {
+	if q.Limit <= 0 {
+		return nil, nil
+	}
+	if q.Label != "" && q.Namespace == "" {
+		q.Namespace = nips.LabelNamespaceUGC
+	}
+	if !db.usesPool() {
+		return db.labelsFromTags(ctx, q)
+	}
+
+	query := `SELECT l.label_id, events.pubkey, l.namespace, l.label, l.target_type, l.target, events.created_at
+	 FROM event_labels l JOIN events ON events.id = l.label_id WHERE TRUE`
+	var args []interface{}
+	for _, cond := range []struct {
+		column string
+		value  string
+	}{
+		{"l.namespace", q.Namespace},
+		{"l.label", q.Label},
+		{"l.target_type", q.TargetType},
+		{"l.target", q.Target},
+	} {
+		if cond.value != "" {
+			args = append(args, cond.value)
+			query += fmt.Sprintf(" AND %s = $%d", cond.column, len(args))
+		}
+	}
+	if len(q.Targets) > 0 {
+		args = append(args, q.Targets)
+		query += fmt.Sprintf(" AND l.target = ANY($%d)", len(args))
+	}
+	if len(q.Labelers) > 0 {
+		args = append(args, q.Labelers)
+		query += fmt.Sprintf(" AND events.pubkey = ANY($%d)", len(args))
+	}
+	if q.Until > 0 {
+		args = append(args, q.Until)
+		query += fmt.Sprintf(" AND events.created_at <= $%d", len(args))
+	}
+	if tenant, ok := TenantFromContext(ctx); ok {
+		cond, condArgs := tenantCondition(tenant, len(args)+1)
+		query += " AND " + cond
+		args = append(args, condArgs...)
+	}
+	query += fmt.Sprintf(" ORDER BY events.created_at DESC, l.label_id, l.target_type, l.target LIMIT $%d", len(args)+1)
+	args = append(args, q.Limit)
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query labels: %w", err)
+	}
+	defer rows.Close()
+
+	var records []LabelRecord
+	for rows.Next() {
+		var r LabelRecord
+		if err := rows.Scan(&r.LabelID, &r.Labeler, &r.Namespace, &r.Label, &r.TargetType, &r.Target, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan label: %w", err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// labelsFromTags finds label events through #l, #L and target tag filters
+// and expands their labels from the tags, for drivers without the label
+// index.
+func (db *DB) labelsFromTags(ctx context.Context, q LabelQuery) ([]LabelRecord, error) {
+	f := nostr.Filter{
+		Kinds:   []int{nips.KindLabel},
+		Authors: q.Labelers,
+		Tags:    nostr.TagMap{},
+		Limit:   q.Limit,
+	}
+	if q.Label != "" {
+		f.Tags["l"] = []string{q.Label}
+	}
+	if q.Namespace != "" && q.Namespace != nips.LabelNamespaceUGC {
+		f.Tags["L"] = []string{q.Namespace}
+	}
+	if q.TargetType != "" && q.Target != "" {
+		f.Tags[q.TargetType] = []string{q.Target}
+	} else if q.TargetType != "" && len(q.Targets) > 0 {
+		f.Tags[q.TargetType] = q.Targets
+	}
+	if q.Until > 0 {
+		until := nostr.Timestamp(q.Until)
+		f.Until = &until
+	}
+	events, err := db.GetEvents(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []LabelRecord
+	for i := range events {
+		evt := &events[i]
+		labels, targets := nips.LabelsOf(evt)
+		for _, l := range labels {
+			if (q.Namespace != "" && l.Namespace != q.Namespace) || (q.Label != "" && l.Value != q.Label) {
+				continue
+			}
+			for _, t := range targets {
+				if (q.TargetType != "" && t.Type != q.TargetType) || (q.Target != "" && t.Value != q.Target) ||
+					(len(q.Targets) > 0 && !slices.Contains(q.Targets, t.Value)) {
+					continue
+				}
+				records = append(records, LabelRecord{
+					LabelID:    evt.ID,
+					Labeler:    evt.PubKey,
+					Namespace:  l.Namespace,
+					Label:      l.Value,
+					TargetType: t.Type,
+					Target:     t.Value,
+					CreatedAt:  int64(evt.CreatedAt),
+				})
+			}
+		}
+	}
+	slices.SortStableFunc(records, func(a, b LabelRecord) int {
+		return cmp.Or(cmp.Compare(b.CreatedAt, a.CreatedAt), cmp.Compare(a.LabelID, b.LabelID))
+	})
+	if len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	return records, nil
+}
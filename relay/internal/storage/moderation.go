@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Moderation decisions: pubkey bans, event bans, IP blocks and address range
+// blocks made through the NIP-86 management API. The relay enforces them from
+// memory; this table keeps them across restarts and is read once on startup.
+
+// Moderation decision types.
+const (
+	ModerationBanPubkey = "pubkey"
+	ModerationBanEvent  = "event"
+	ModerationBlockIP   = "ip"
+	ModerationBlockCIDR = "cidr"
+)
+
+// ModerationDecision is one persisted management decision.
+type ModerationDecision struct {
+	Type      string `json:"type"`
+	Target    string `json:"target"` // pubkey, event ID, IP or CIDR range
+	Reason    string `json:"reason,omitempty"`
+	Actor     string `json:"actor,omitempty"` // admin pubkey
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at,omitempty"` // 0 = never
+}
+
+// Expired reports whether the decision has lapsed at now.
+func (d ModerationDecision) Expired(now time.Time) bool {
+	return d.ExpiresAt > 0 && d.ExpiresAt <= now.Unix()
+}
+
+// moderationDDL creates the decisions table, one row per moderated subject.
+const moderationDDL = `CREATE TABLE IF NOT EXISTS moderation_decisions (
+  type TEXT NOT NULL,
+  target TEXT NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  actor TEXT NOT NULL DEFAULT '',
+  created_at BIGINT NOT NULL,
+  expires_at BIGINT NOT NULL DEFAULT 0,
+  CONSTRAINT moderation_decisions_pkey PRIMARY KEY (type, target)
+)`
+
+// ensureModerationSchema creates the moderation_decisions table if it does not exist.
+func (db *DB) ensureModerationSchema(ctx context.Context) error {
+	if _, err := db.Pool.Exec(ctx, moderationDDL); err != nil {
+		return fmt.Errorf("failed to create moderation_decisions table: %w", err)
+	}
+	return nil
+}
+
+// SaveModerationDecision records a decision, replacing an earlier one for the
+// same target.
+func (db *DB) SaveModerationDecision(ctx context.Context, d ModerationDecision) error {
+	if !db.isConnected() {
+		return fmt.Errorf("database is not connected")
+	}
+	return db.backend.SaveModerationDecision(ctx, d)
+}
+
+// SaveModerationDecision upserts a decision.
+func (p *postgresBackend) SaveModerationDecision(ctx context.Context, d ModerationDecision) error {
+	_, err := p.db.Pool.Exec(ctx,
+		`INSERT INTO moderation_decisions (type, target, reason, actor, created_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (type, target) DO UPDATE SET
+		   reason = EXCLUDED.reason, actor = EXCLUDED.actor,
+		   created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at`,
+		d.Type, d.Target, d.Reason, d.Actor, d.CreatedAt, d.ExpiresAt)
+	return err
+}
+
+// DeleteModerationDecision forgets the decision for a target.
+func (db *DB) DeleteModerationDecision(ctx context.Context, decisionType, target string) error {
+	if !db.isConnected() {
+		return fmt.Errorf("database is not connected")
+	}
+	return db.backend.DeleteModerationDecision(ctx, decisionType, target)
+}
+
+// DeleteModerationDecision deletes the decision for a target.
+func (p *postgresBackend) DeleteModerationDecision(ctx context.Context, decisionType, target string) error {
+	_, err := p.db.Pool.Exec(ctx,
+		`DELETE FROM moderation_decisions WHERE type = $1 AND target = $2`, decisionType, target)
+	return err
+}
+
+// ListModerationDecisions returns every recorded decision, expired ones included.
+func (db *DB) ListModerationDecisions(ctx context.Context) ([]ModerationDecision, error) {
+	if !db.isConnected() {
+		return nil, fmt.Errorf("database is not connected")
+	}
+	return db.backend.ListModerationDecisions(ctx)
+}
+
+// ListModerationDecisions returns every decision, oldest first.
+func (p *postgresBackend) ListModerationDecisions(ctx context.Context) ([]ModerationDecision, error) {
+	rows, err := p.db.Pool.Query(ctx,
+		`SELECT type, target, reason, actor, created_at, expires_at FROM moderation_decisions ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query moderation decisions: %w", err)
+	}
+	defer rows.Close()
+
+	var decisions []ModerationDecision
+	for rows.Next() {
+		var d ModerationDecision
+		if err := rows.Scan(&d.Type, &d.Target, &d.Reason, &d.Actor, &d.CreatedAt, &d.ExpiresAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, rows.Err()
+}
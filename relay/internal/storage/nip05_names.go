@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Hosted NIP-05 names: local usernames the relay serves from its own
+// /.well-known/nostr.json, set through the NIP-86 management API. Names from
+// the config file are never stored; this table keeps the managed ones across
+// restarts and is read once on startup.
+
+// NIP05Name is one hosted name -> pubkey mapping.
+type NIP05Name struct {
+	Name      string   `json:"name"`
+	Pubkey    string   `json:"pubkey"`
+	Relays    []string `json:"relays,omitempty"` // relay hints; empty = the relay's defaults
+	Actor     string   `json:"actor,omitempty"`  // admin pubkey
+	CreatedAt int64    `json:"created_at"`
+}
+
+// nip05NamesDDL creates the hosted names table, keyed by name.
+const nip05NamesDDL = `CREATE TABLE IF NOT EXISTS nip05_names (
+  name TEXT NOT NULL PRIMARY KEY,
+  pubkey TEXT NOT NULL,
+  relays TEXT[] NOT NULL DEFAULT '{}',
+  actor TEXT NOT NULL DEFAULT '',
+  created_at BIGINT NOT NULL
+)`
+
+// ensureNIP05NamesSchema creates the nip05_names table if it does not exist.
+func (db *DB) ensureNIP05NamesSchema(ctx context.Context) error {
+	if _, err := db.Pool.Exec(ctx, nip05NamesDDL); err != nil {
+		return fmt.Errorf("failed to create nip05_names table: %w", err)
+	}
+	return nil
+}
+
+// SaveNIP05Name records a hosted name, replacing an earlier mapping of it.
+func (db *DB) SaveNIP05Name(ctx context.Context, n NIP05Name) error {
+	if !db.isConnected() {
+		return fmt.Errorf("database is not connected")
+	}
+	return db.backend.SaveNIP05Name(ctx, n)
+}
+
+// SaveNIP05Name upserts a hosted name.
+func (p *postgresBackend) SaveNIP05Name(ctx context.Context, n NIP05Name) error {
+	relays := n.Relays
+	if relays == nil {
+		relays = []string{}
+	}
+	_, err := p.db.Pool.Exec(ctx,
+		`INSERT INTO nip05_names (name, pubkey, relays, actor, created_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (name) DO UPDATE SET
+		   pubkey = EXCLUDED.pubkey, relays = EXCLUDED.relays,
+		   actor = EXCLUDED.actor, created_at = EXCLUDED.created_at`,
+		n.Name, n.Pubkey, relays, n.Actor, n.CreatedAt)
+	return err
+}
+
+// DeleteNIP05Name forgets a hosted name.
+func (db *DB) DeleteNIP05Name(ctx context.Context, name string) error {
+	if !db.isConnected() {
+		return fmt.Errorf("database is not connected")
+	}
+	return db.backend.DeleteNIP05Name(ctx, name)
+}
+
+// DeleteNIP05Name deletes a hosted name.
+func (p *postgresBackend) DeleteNIP05Name(ctx context.Context, name string) error {
+	_, err := p.db.Pool.Exec(ctx, `DELETE FROM nip05_names WHERE name = $1`, name)
+	return err
+}
+
+// ListNIP05Names returns every hosted name.
+func (db *DB) ListNIP05Names(ctx context.Context) ([]NIP05Name, error) {
+	if !db.isConnected() {
+		return nil, fmt.Errorf("database is not connected")
+	}
+	return db.backend.ListNIP05Names(ctx)
+}
+
+// ListNIP05Names returns every hosted name, by name.
+func (p *postgresBackend) ListNIP05Names(ctx context.Context) ([]NIP05Name, error) {
+	rows, err := p.db.Pool.Query(ctx,
+		`SELECT name, pubkey, relays, actor, created_at FROM nip05_names ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query NIP-05 names: %w", err)
+	}
+	defer rows.Close()
+
+	var names []NIP05Name
+	for rows.Next() {
+		var n NIP05Name
+		if err := rows.Scan(&n.Name, &n.Pubkey, &n.Relays, &n.Actor, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Keyset pagination: paged queries order events by (created_at, id) in the
+// direction GetEvents applies the limit, and a cursor naming the last event
+// of a page resumes right after it. Unlike moving until below the last
+// timestamp, this neither repeats events nor skips the others sharing the
+// boundary second.
+
+// PageCursor is the position after which a paged query resumes: the
+// created_at and id of the last event of the previous page. The zero cursor
+// starts at the first page.
+type PageCursor struct {
+	CreatedAt int64
+	ID        string
+}
+
+// String encodes the cursor as "<created_at>:<id>".
+func (c PageCursor) String() string {
+	return strconv.FormatInt(c.CreatedAt, 10) + ":" + c.ID
+}
+
+// ParsePageCursor decodes a cursor written by PageCursor.String.
+func ParsePageCursor(s string) (PageCursor, error) {
+	ts, id, found := strings.Cut(s, ":")
+	createdAt, err := strconv.ParseInt(ts, 10, 64)
+	if !found || err != nil || createdAt < 0 || !nostr.IsValid32ByteHex(id) {
+		return PageCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return PageCursor{CreatedAt: createdAt, ID: strings.ToLower(id)}, nil
+}
+
+// CursorAfter returns the cursor resuming after evt.
+func CursorAfter(evt nostr.Event) PageCursor {
+	return PageCursor{CreatedAt: int64(evt.CreatedAt), ID: evt.ID}
+}
+
+type pageCursorKey struct{}
+
+// WithPageCursor makes GetEvents called with ctx a paged query resuming
+// after c. Paged queries bypass the result cache and the hot store, which do
+// not order by id.
+func WithPageCursor(ctx context.Context, c PageCursor) context.Context {
+	return context.WithValue(ctx, pageCursorKey{}, c)
+}
+
+// PageCursorFromContext returns the cursor of a paged query, if any.
+func PageCursorFromContext(ctx context.Context) (PageCursor, bool) {
+	c, ok := ctx.Value(pageCursorKey{}).(PageCursor)
+	return c, ok
+}
+
+// ascendingOrder reports whether GetEvents returns f oldest first, which it
+// does for since-only filters so the limit keeps the events right after since.
+func ascendingOrder(f nostr.Filter) bool {
+	return f.Since != nil && f.Until == nil
+}
+
+// pageOrder returns the ORDER BY clause of a query, tie-broken by id when paged.
+func pageOrder(ascending, paged bool) string {
+	dir := "DESC"
+	if ascending {
+		dir = "ASC"
+	}
+	if paged {
+		return " ORDER BY created_at " + dir + ", id " + dir
+	}
+	return " ORDER BY created_at " + dir
+}
+
+// pageCond returns the condition selecting the events after c in the given
+// order, or "" for the first page.
+func pageCond(c PageCursor, ascending bool, placeholder func() string) (string, []interface{}) {
+	if c.ID == "" {
+		return "", nil
+	}
+	op := "<"
+	if ascending {
+		op = ">"
+	}
+	cond := fmt.Sprintf("(created_at %s %s OR (created_at = %s AND id %s %s))",
+		op, placeholder(), placeholder(), op, placeholder())
+	return cond, []interface{}{c.CreatedAt, c.CreatedAt, c.ID}
+}
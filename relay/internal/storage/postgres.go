@@ -0,0 +1,637 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// postgresBackend is the StorageBackend of the PostgreSQL driver, also used
+// for Aurora and CockroachDB. It runs its queries on the pools of db, with
+// db's retry policy; reads go to the read replica when one is configured.
+type postgresBackend struct {
+	db *DB
+}
+
+var _ StorageBackend = (*postgresBackend)(nil)
+
+// InsertEvent inserts a regular event.
+func (p *postgresBackend) InsertEvent(ctx context.Context, evt nostr.Event) error {
+	if _, err := p.db.Pool.Exec(ctx, insertEventQuery(true), insertEventArgs(ctx, evt)...); err != nil {
+		return fmt.Errorf("failed to insert event: %w", err)
+	}
+	return nil
+}
+
+// BatchInsertEvents inserts regular events in transactions of up to 50.
+func (p *postgresBackend) BatchInsertEvents(ctx context.Context, events []nostr.Event) error {
+	// Use smaller batches for efficiency
+	const batchSize = 50
+
+	// Execute in smaller batches with retries
+	for i := 0; i < len(events); i += batchSize {
+		end := min(i+batchSize, len(events))
+		batchEvents := events[i:end]
+		err := p.db.Retry(ctx, func(retryCtx context.Context) error {
+			return p.insertEventBatch(retryCtx, batchEvents)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper for actual batch insertion
+func (p *postgresBackend) insertEventBatch(ctx context.Context, events []nostr.Event) error {
+	tx, err := p.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			p.db.recordError(fmt.Errorf("rollback failed: %w", rollbackErr))
+		}
+	}()
+
+	batch := &pgx.Batch{}
+	for _, evt := range events {
+		batch.Queue(insertEventQuery(true), insertEventArgs(ctx, evt)...)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	if err := results.Close(); err != nil {
+		return fmt.Errorf("batch execution failed: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("transaction commit failed: %w", err)
+	}
+	return nil
+}
+
+// InsertReplaceableEvent replaces the stored event for (pubkey, kind) unless
+// it supersedes evt (ErrStaleEvent).
+func (p *postgresBackend) InsertReplaceableEvent(ctx context.Context, evt nostr.Event) error {
+	return p.upsertReplaceable(ctx, evt, "pubkey = $1 AND kind = $2",
+		[]interface{}{evt.PubKey, evt.Kind})
+}
+
+// InsertAddressableEvent replaces the stored event for (pubkey, kind, d)
+// unless it supersedes evt (ErrStaleEvent).
+func (p *postgresBackend) InsertAddressableEvent(ctx context.Context, evt nostr.Event) error {
+	dVal := nips.GetTagValue(evt, "d")
+	if dVal == "" {
+		return p.InsertEvent(ctx, evt) // fallback
+	}
+	return p.upsertReplaceable(ctx, evt, "pubkey = $1 AND kind = $2 AND nostr_d_tag(tags) = $3",
+		[]interface{}{evt.PubKey, evt.Kind, dVal})
+}
+
+// upsertReplaceable replaces the versions matching where with evt in a single
+// transaction. Stored rows are locked, so writers for the same address
+// serialize; when nothing is stored yet, the unique replaceable/addressable
+// index fails the slower of two first writers, which retries and then
+// compares against the winner.
+func (p *postgresBackend) upsertReplaceable(ctx context.Context, evt nostr.Event, where string, args []interface{}) error {
+	var err error
+	for attempt := 0; attempt < maxReplaceableAttempts; attempt++ {
+		err = p.tryUpsertReplaceable(ctx, evt, where, args)
+		if !isRetryableUpsert(err) {
+			break
+		}
+	}
+	if err != nil {
+		if errors.Is(err, ErrStaleEvent) {
+			return err
+		}
+		return fmt.Errorf("failed to insert replaceable event: %w", err)
+	}
+	return nil
+}
+
+func (p *postgresBackend) tryUpsertReplaceable(ctx context.Context, evt nostr.Event, where string, args []interface{}) error {
+	tx, err := p.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			p.db.recordError(fmt.Errorf("rollback failed: %w", rollbackErr))
+		}
+	}()
+
+	rows, err := tx.Query(ctx, `SELECT id, created_at FROM events WHERE `+where+` FOR UPDATE`, args...)
+	if err != nil {
+		return err
+	}
+	stored := 0
+	for rows.Next() {
+		var id string
+		var createdAt int64
+		if err := rows.Scan(&id, &createdAt); err != nil {
+			rows.Close()
+			return err
+		}
+		if !supersedes(evt.CreatedAt.Time().Unix(), evt.ID, createdAt, id) {
+			rows.Close()
+			return ErrStaleEvent
+		}
+		stored++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if stored > 0 {
+		if _, err := tombstoneEvents(ctx, tx, where, args, evt.ID, TombstoneReplaced); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, insertEventQuery(false), insertEventArgs(ctx, evt)...); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// isRetryableUpsert reports whether a replaceable upsert lost a race: a unique
+// violation from a concurrent first insert, or a CockroachDB serialization failure.
+func isRetryableUpsert(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "23505" || pgErr.Code == "40001"
+}
+
+// PersistDeletion tombstones the events a NIP-09 request targets and stores it.
+func (p *postgresBackend) PersistDeletion(ctx context.Context, del nostr.Event) error {
+	eIDs, aTags := deletionTargets(del)
+	if len(eIDs) == 0 && len(aTags) == 0 {
+		return errors.New("deletion event without e or a tags")
+	}
+
+	tx, err := p.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			p.db.recordError(fmt.Errorf("rollback failed: %w", rollbackErr))
+		}
+	}()
+
+	// 1) tombstone events by "e" tag (referenced by event ID) — only if owned by
+	//    deleter; IDs not stored here get a bare tombstone
+	if len(eIDs) > 0 {
+		_, err = tombstoneEvents(ctx, tx, "id = ANY($1) AND pubkey = $2",
+			[]interface{}{eIDs, del.PubKey}, del.ID, TombstoneDeleted)
+		if err != nil {
+			return err
+		}
+		if err = tombstoneMissing(ctx, tx, eIDs, del); err != nil {
+			return err
+		}
+	}
+
+	// 2) tombstone events by "a" tag (addressable events) — NIP-09 spec
+	//    format: <kind>:<pubkey>:<d-identifier>
+	//    only delete versions up to the deletion request's created_at
+	for _, tag := range aTags {
+		parts := strings.SplitN(tag[1], ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		// Only delete if the pubkey in the "a" tag matches the deleter
+		if parts[1] != del.PubKey {
+			continue
+		}
+		_, err = tombstoneEvents(ctx, tx,
+			"kind = $1 AND pubkey = $2 AND tags @> $3::jsonb AND created_at <= $4",
+			[]interface{}{parts[0], del.PubKey,
+				fmt.Sprintf(`[["d","%s"]]`, parts[2]),
+				del.CreatedAt.Time().Unix()},
+			del.ID, TombstoneDeleted)
+		if err != nil {
+			logger.Warn("NIP-09: Failed to delete addressable event",
+				zap.String("a_tag", tag[1]),
+				zap.Error(err))
+		}
+	}
+
+	// 3) insert the deletion event itself
+	_, err = tx.Exec(ctx, insertEventQuery(false), insertEventArgs(ctx, del)...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// PersistVanish tombstones every event of the pubkey and the gift wraps
+// addressed to it, and stores the vanish request (NIP-62).
+func (p *postgresBackend) PersistVanish(ctx context.Context, evt nostr.Event) error {
+	tx, err := p.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			p.db.recordError(fmt.Errorf("rollback failed: %w", rollbackErr))
+		}
+	}()
+
+	// 1) Tombstone ALL events from this pubkey up to the vanish request's created_at
+	deletedCount, err := tombstoneEvents(ctx, tx, "pubkey = $1 AND created_at <= $2",
+		[]interface{}{evt.PubKey, evt.CreatedAt.Time().Unix()}, evt.ID, TombstoneVanished)
+	if err != nil {
+		return fmt.Errorf("failed to delete events for vanish: %w", err)
+	}
+
+	// 2) Tombstone gift-wrapped events (kind 1059) that p-tagged this pubkey
+	giftDeleted, err := tombstoneEvents(ctx, tx, "kind = 1059 AND tags @> $1::jsonb",
+		[]interface{}{fmt.Sprintf(`[["p","%s"]]`, evt.PubKey)}, evt.ID, TombstoneVanished)
+	if err != nil {
+		logger.Warn("NIP-62: Failed to delete gift-wrapped events",
+			zap.String("pubkey", evt.PubKey),
+			zap.Error(err))
+		// Non-fatal: continue with vanish
+	} else {
+		if giftDeleted > 0 {
+			logger.Info("NIP-62: Deleted gift-wrapped events",
+				zap.String("pubkey", evt.PubKey),
+				zap.Int64("count", giftDeleted))
+		}
+	}
+
+	// 3) Store the vanish request itself for bookkeeping
+	_, err = tx.Exec(ctx, insertEventQuery(false), insertEventArgs(ctx, evt)...)
+	if err != nil {
+		return fmt.Errorf("failed to store vanish request: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	logger.Info("NIP-62: Vanish request processed",
+		zap.String("pubkey", evt.PubKey),
+		zap.Int64("events_deleted", deletedCount))
+	return nil
+}
+
+// DeleteEventByID tombstones one event on behalf of the relay.
+func (p *postgresBackend) DeleteEventByID(ctx context.Context, eventID string) error {
+	_, err := tombstoneEvents(ctx, p.db.Pool, "id = $1", []interface{}{eventID}, "", TombstoneRemoved)
+	return err
+}
+
+// CleanExpiredEvents deletes events whose NIP-40 expiration has passed.
+func (p *postgresBackend) CleanExpiredEvents(ctx context.Context) (int, error) {
+	query := `
+		DELETE FROM events
+		WHERE EXISTS (
+			SELECT 1 FROM jsonb_array_elements(tags) AS tag
+			WHERE tag->>0 = 'expiration'
+			AND tag->>1 IS NOT NULL
+			AND (tag->>1)::BIGINT <= $1
+		)
+	`
+
+	result, err := p.db.Pool.Exec(ctx, query, time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired events: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// PruneOldEvents deletes regular events created before the given Unix time.
+func (p *postgresBackend) PruneOldEvents(ctx context.Context, before int64) (int, error) {
+	result, err := p.db.Pool.Exec(ctx,
+		`DELETE FROM events WHERE created_at < $1 AND NOT `+retentionKeepKinds, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune old events: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// GetEvents runs the compiled filter, scoped by the tenant, received range
+// and page cursor found in ctx.
+func (p *postgresBackend) GetEvents(ctx context.Context, filter nostr.Filter) ([]nostr.Event, error) {
+	// Compile the filter for efficient processing
+	cf := CompileFilter(filter)
+	cf.Tenant, cf.Scoped = TenantFromContext(ctx)
+	cf.Received, _ = ReceivedRangeFromContext(ctx)
+	if page, paged := PageCursorFromContext(ctx); paged {
+		cf.Page = &page
+	}
+
+	// Build the optimized query
+	query, args, err := cf.BuildQuery()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	// Create context with timeout
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Log the query for debugging
+	logger.Debug("Executing query",
+		zap.String("query", query),
+		zap.Int("arg_count", len(args)))
+
+	// Execute query
+	var rows pgx.Rows
+	err = p.db.Retry(queryCtx, func(ctx context.Context) error {
+		var err error
+		rows, err = p.db.queryPool(ctx).Query(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	// Preallocate slice with capacity to reduce allocations.
+	// This size balances memory usage with performance for
+	// typical filter cap used by the relay and reduces slice
+	// growth for common queries while keeping memory modest.
+	events := make([]nostr.Event, 0, constants.DefaultQueryPrealloc) // Process rows
+	for rows.Next() {
+		var evt nostr.Event
+		var createdAt int64
+		var rawTags []byte
+
+		if err := rows.Scan(&evt.ID, &evt.PubKey, &evt.Kind, &createdAt, &evt.Content, &rawTags, &evt.Sig); err != nil {
+			logger.Warn("Row scan failed", zap.Error(err))
+			continue
+		}
+
+		evt.CreatedAt = nostr.Timestamp(createdAt)
+
+		// Parse tags
+		if len(rawTags) > 0 {
+			if err := json.Unmarshal(rawTags, &evt.Tags); err != nil {
+				logger.Warn("Failed to unmarshal tags", zap.Error(err))
+				evt.Tags = []nostr.Tag{}
+			}
+		}
+
+		events = append(events, evt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	return events, nil
+}
+
+// GetEventByID retrieves a single event by its ID.
+func (p *postgresBackend) GetEventByID(ctx context.Context, eventID string) (nostr.Event, error) {
+	query := `SELECT id, pubkey, kind, created_at, content, tags, sig FROM events WHERE id = $1`
+	row := p.db.Pool.QueryRow(ctx, query, eventID)
+
+	var evt nostr.Event
+	var createdAt int64
+	err := row.Scan(&evt.ID, &evt.PubKey, &evt.Kind, &createdAt, &evt.Content, &evt.Tags, &evt.Sig)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("event not found: %w", err)
+	}
+
+	evt.CreatedAt = nostr.Timestamp(createdAt) // Convert Unix timestamp to nostr.Timestamp
+
+	return evt, nil
+}
+
+// GetReplaceableEvent retrieves the latest replaceable event for a given pubkey and kind.
+func (p *postgresBackend) GetReplaceableEvent(ctx context.Context, pubkey string, kind int) (nostr.Event, error) {
+	query := `
+		SELECT id, pubkey, kind, created_at, content, tags, sig
+		FROM events
+		WHERE pubkey = $1 AND kind = $2
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	row, err := p.db.ExecuteQuery(ctx, query, pubkey, kind)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to fetch replaceable event: %w", err)
+	}
+
+	var evt nostr.Event
+	var createdAt int64
+	err = row.Scan(&evt.ID, &evt.PubKey, &evt.Kind, &createdAt, &evt.Content, &evt.Tags, &evt.Sig)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("replaceable event not found: %w", err)
+	}
+
+	evt.CreatedAt = nostr.Timestamp(createdAt) // Convert Unix timestamp to nostr.Timestamp
+
+	return evt, nil
+}
+
+// GetAddressableEvent retrieves the latest addressable event for a given pubkey, kind, and 'd' tag.
+func (p *postgresBackend) GetAddressableEvent(ctx context.Context, pubkey string, kind int, dVal string) (nostr.Event, error) {
+	query := `
+		SELECT id, pubkey, kind, created_at, content, tags, sig
+		FROM events
+		WHERE pubkey = $1 AND kind = $2 AND tags @> $3
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	row, err := p.db.ExecuteQuery(ctx, query, pubkey, kind, fmt.Sprintf(`[["d", "%s"]]`, dVal))
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to fetch addressable event: %w", err)
+	}
+
+	var evt nostr.Event
+	var createdAt int64
+	err = row.Scan(&evt.ID, &evt.PubKey, &evt.Kind, &createdAt, &evt.Content, &evt.Tags, &evt.Sig)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("addressable event not found: %w", err)
+	}
+
+	evt.CreatedAt = nostr.Timestamp(createdAt) // Convert Unix timestamp to nostr.Timestamp
+
+	return evt, nil
+}
+
+// filterWhere returns the WHERE clause of a COUNT or pubkey query for
+// filter, scoped to the tenant found in ctx.
+func filterWhere(ctx context.Context, filter nostr.Filter) (string, []interface{}) {
+	var conds []string
+	args := make([]interface{}, 0, 10)
+	argIndex := 1
+	add := func(cond string, condArgs ...interface{}) {
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+		argIndex += len(condArgs)
+	}
+
+	// Apply filters in order of index selectivity; IDs are primary keys
+	if len(filter.IDs) > 0 {
+		cond, condArgs := hexCondition("id", filter.IDs, argIndex)
+		add(cond, condArgs...)
+	}
+	if len(filter.Authors) > 0 {
+		cond, condArgs := hexCondition("pubkey", filter.Authors, argIndex)
+		add(cond, condArgs...)
+	}
+	if len(filter.Kinds) > 0 {
+		add(fmt.Sprintf("kind = ANY($%d)", argIndex), filter.Kinds)
+	}
+
+	// Always apply time filters after key/author filters
+	if filter.Since != nil {
+		add(fmt.Sprintf("created_at >= $%d", argIndex), filter.Since.Time().Unix())
+	}
+	if filter.Until != nil {
+		add(fmt.Sprintf("created_at <= $%d", argIndex), filter.Until.Time().Unix())
+	}
+
+	// Handle tag filtering
+	tagConds, tagArgs := tagConditions(filter.Tags, argIndex)
+	conds = append(conds, tagConds...)
+	args = append(args, tagArgs...)
+	argIndex += len(tagArgs)
+
+	// Scope the query to one tenant in multi-tenant mode
+	if tenant, ok := TenantFromContext(ctx); ok {
+		cond, condArgs := tenantCondition(tenant, argIndex)
+		add(cond, condArgs...)
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// GetEventCount returns the count of events matching the given filter.
+func (p *postgresBackend) GetEventCount(ctx context.Context, filter nostr.Filter) (int64, error) {
+	where, args := filterWhere(ctx, filter)
+	query := `SELECT COUNT(*) FROM events` + where
+
+	// Log the query for debugging
+	logger.Debug("Executing count query",
+		zap.String("query", query),
+		zap.Int("arg_count", len(args)))
+
+	// Execute query with timeout
+	var count int64
+	err := p.db.Retry(ctx, func(ctx context.Context) error {
+		return p.db.queryPool(ctx).QueryRow(ctx, query, args...).Scan(&count)
+	})
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return 0, fmt.Errorf("count operation timed out")
+		}
+		return 0, fmt.Errorf("failed to execute count query: %w", err)
+	}
+	return count, nil
+}
+
+// GetEventPubkeys returns pubkeys of events matching the given filter.
+func (p *postgresBackend) GetEventPubkeys(ctx context.Context, filter nostr.Filter) ([]string, error) {
+	where, args := filterWhere(ctx, filter)
+	rows, err := p.db.queryPool(ctx).Query(ctx, `SELECT pubkey FROM events`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event pubkeys: %w", err)
+	}
+	defer rows.Close()
+
+	var pubkeys []string
+	for rows.Next() {
+		var pk string
+		if err := rows.Scan(&pk); err != nil {
+			return nil, fmt.Errorf("failed to scan pubkey: %w", err)
+		}
+		pubkeys = append(pubkeys, pk)
+	}
+	return pubkeys, rows.Err()
+}
+
+// EventExists reports whether an event is stored.
+func (p *postgresBackend) EventExists(ctx context.Context, eventID string) (bool, error) {
+	var exists bool
+	err := p.db.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM events WHERE id = $1)`,
+		eventID,
+	).Scan(&exists)
+	return exists, err
+}
+
+// IsVanishedPubkey reports whether a pubkey has stored a vanish request.
+func (p *postgresBackend) IsVanishedPubkey(ctx context.Context, pubkey string) (bool, error) {
+	var count int64
+	err := p.db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM events WHERE kind = 62 AND pubkey = $1`,
+		pubkey).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetTotalEventCount returns the number of stored events.
+func (p *postgresBackend) GetTotalEventCount(ctx context.Context) (int64, error) {
+	var count int64
+	if err := p.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM events").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to get total event count: %w", err)
+	}
+	return count, nil
+}
+
+// ForEachEventID calls fn for every stored event ID.
+func (p *postgresBackend) ForEachEventID(ctx context.Context, fn func(id string)) error {
+	rows, err := p.db.Pool.Query(ctx, `SELECT id FROM events`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		fn(id)
+	}
+	return rows.Err()
+}
+
+// Version returns the server version string.
+func (p *postgresBackend) Version(ctx context.Context) (string, error) {
+	var version string
+	if err := p.db.Pool.QueryRow(ctx, "SELECT version()").Scan(&version); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// Ping checks the primary is reachable.
+func (p *postgresBackend) Ping(ctx context.Context) error {
+	return p.db.Pool.Ping(ctx)
+}
+
+// Close closes the read replica pool and the primary pool.
+func (p *postgresBackend) Close() error {
+	if p.db.readPool != nil {
+		p.db.readPool.Close()
+	}
+	p.db.Pool.Close()
+	return nil
+}
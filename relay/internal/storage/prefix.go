@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-01 lets filters name ids and authors by hex prefix. Full 64 character
+// values are matched exactly; shorter ones become range scans on the index.
+
+// HasPrefixes reports whether f names any id or author by prefix.
+func HasPrefixes(f nostr.Filter) bool {
+	for _, id := range f.IDs {
+		if len(id) < 64 {
+			return true
+		}
+	}
+	for _, author := range f.Authors {
+		if len(author) < 64 {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesFilter is nostr.Filter.Matches with prefix matching of ids and authors.
+func MatchesFilter(f nostr.Filter, evt *nostr.Event) bool {
+	if !HasPrefixes(f) {
+		return f.Matches(evt)
+	}
+	if evt == nil {
+		return false
+	}
+	if f.IDs != nil && !matchesHex(f.IDs, evt.ID) {
+		return false
+	}
+	if f.Authors != nil && !matchesHex(f.Authors, evt.PubKey) {
+		return false
+	}
+	f.IDs, f.Authors = nil, nil
+	return f.Matches(evt)
+}
+
+// matchesHex reports whether value equals or starts with any of prefixes.
+func matchesHex(prefixes []string, value string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(value, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// hexRange returns the bounds [lo, hi) of the hex strings starting with prefix.
+// 'g' sorts after every hex digit, so prefix+"g" is above all of them.
+func hexRange(prefix string) (string, string) {
+	return prefix, prefix + "g"
+}
+
+// hexCondition returns the SQL condition matching column against full values
+// or prefixes, using placeholders from $argIndex.
+func hexCondition(column string, values []string, argIndex int) (string, []interface{}) {
+	var exact []string
+	var conds []string
+	var args []interface{}
+	for _, v := range values {
+		if len(v) >= 64 {
+			exact = append(exact, v)
+			continue
+		}
+		lo, hi := hexRange(v)
+		conds = append(conds, fmt.Sprintf("(%s >= $%d AND %s < $%d)", column, argIndex, column, argIndex+1))
+		args = append(args, lo, hi)
+		argIndex += 2
+	}
+	switch {
+	case len(exact) == 1:
+		// An equality keeps index order for the ORDER BY created_at that follows
+		conds = append(conds, fmt.Sprintf("%s = $%d", column, argIndex))
+		args = append(args, exact[0])
+	case len(exact) > 1:
+		conds = append(conds, fmt.Sprintf("%s = ANY($%d::text[])", column, argIndex))
+		args = append(args, exact)
+	}
+	if len(conds) == 1 {
+		return conds[0], args
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
@@ -2,99 +2,85 @@ package storage
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"sort"
-	"strings"
 	"time"
 
-	"github.com/Shugur-Network/relay/internal/constants"
 	"github.com/Shugur-Network/relay/internal/logger"
 	"github.com/Shugur-Network/relay/internal/relay/nips"
-	"github.com/jackc/pgx/v5"
 	nostr "github.com/nbd-wtf/go-nostr"
 	"go.uber.org/zap"
 )
 
-// GetEvents retrieves events based on Nostr filters
+// GetEvents retrieves events based on Nostr filters, in the order the limit
+// is applied: newest first, or oldest first for since-only filters. Queries
+// made with WithPageCursor are also ordered by id within a second.
 func (db *DB) GetEvents(ctx context.Context, filter nostr.Filter) ([]nostr.Event, error) {
-	// Compile the filter for efficient processing
-	cf := CompileFilter(filter)
-
-	// Build the optimized query
-	query, args, err := cf.BuildQuery()
-	if err != nil {
-		return nil, fmt.Errorf("failed to build query: %w", err)
+	_, scoped := TenantFromContext(ctx)
+	received, bounded := ReceivedRangeFromContext(ctx)
+	_, paged := PageCursorFromContext(ctx)
+	scope := tenantCacheScope(ctx)
+	if bounded {
+		scope += fmt.Sprintf("#received:%d-%d", received.Since, received.Until)
+	}
+
+	// Paged queries need the (created_at, id) order only storage applies, so
+	// they skip the result cache and the hot store
+	cached := db.queryCache != nil && !paged
+
+	// Serve repeated small filters from the result cache
+	if cached {
+		if events, ok := db.queryCache.GetEvents(scope, filter); ok {
+			return dropExpired(events), nil
+		}
 	}
 
-	// Create context with timeout
-	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	// Log the query for debugging
-	logger.Debug("Executing query",
-		zap.String("query", query),
-		zap.Int("arg_count", len(args)))
+	// Recent-event filters are answered by the hot store when it holds the full
+	// result. It does not know event tenants or received times, so scoped and
+	// received-bounded queries skip it.
+	if !scoped && !bounded && !paged {
+		if events, ok := db.queryHotStore(filter); ok {
+			if db.queryCache != nil {
+				db.queryCache.PutEvents(scope, filter, events)
+			}
+			return events, nil
+		}
+	}
 
-	// Execute query
-	rows, err := db.Pool.Query(queryCtx, query, args...)
+	events, err := db.backend.GetEvents(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query events: %w", err)
+		return nil, err
+	}
+	events = dropExpired(events)
+	if cached {
+		db.queryCache.PutEvents(scope, filter, events)
 	}
-	defer rows.Close()
-
-	// Preallocate slice with capacity to reduce allocations.
-	// This size balances memory usage with performance for
-	// typical filter cap used by the relay and reduces slice
-	// growth for common queries while keeping memory modest.
-	events := make([]nostr.Event, 0, constants.DefaultQueryPrealloc)	// Process rows
-	for rows.Next() {
-		var evt nostr.Event
-		var createdAt int64
-		var rawTags []byte
-
-		if err := rows.Scan(&evt.ID, &evt.PubKey, &evt.Kind, &createdAt, &evt.Content, &rawTags, &evt.Sig); err != nil {
-			logger.Warn("Row scan failed", zap.Error(err))
+	return events, nil
+}
+
+// dropExpired removes events whose NIP-40 expiration has passed, such as
+// NIP-38 statuses, which stay in storage until the expired-events cleaner runs.
+func dropExpired(events []nostr.Event) []nostr.Event {
+	for i := range events {
+		if !nips.IsExpired(events[i]) {
 			continue
 		}
-
-		evt.CreatedAt = nostr.Timestamp(createdAt)
-
-		// Parse tags
-		if len(rawTags) > 0 {
-			if err := json.Unmarshal(rawTags, &evt.Tags); err != nil {
-				logger.Warn("Failed to unmarshal tags", zap.Error(err))
-				evt.Tags = []nostr.Tag{}
+		// Copy rather than filter in place: the slice may be shared with the query cache
+		kept := make([]nostr.Event, 0, len(events)-1)
+		kept = append(kept, events[:i]...)
+		for _, evt := range events[i+1:] {
+			if !nips.IsExpired(evt) {
+				kept = append(kept, evt)
 			}
 		}
-
-		events = append(events, evt)
+		return kept
 	}
-
-	// Reorder events in ascending order by created_at
-	sort.Slice(events, func(i, j int) bool {
-		return events[i].CreatedAt < events[j].CreatedAt
-	})
-
-	return events, nil
+	return events
 }
 
 // GetEventByID retrieves a single event by its ID.
 func (db *DB) GetEventByID(ctx context.Context, eventID string) (nostr.Event, error) {
-	query := `SELECT id, pubkey, kind, created_at, content, tags, sig FROM events WHERE id = $1`
-	row := db.Pool.QueryRow(ctx, query, eventID)
-
-	var evt nostr.Event
-	var createdAt int64
-	err := row.Scan(&evt.ID, &evt.PubKey, &evt.Kind, &createdAt, &evt.Content, &evt.Tags, &evt.Sig)
-	if err != nil {
-		return nostr.Event{}, fmt.Errorf("event not found: %w", err)
-	}
-
-	evt.CreatedAt = nostr.Timestamp(createdAt) // Convert Unix timestamp to nostr.Timestamp
-
-	return evt, nil
+	return db.backend.GetEventByID(ctx, eventID)
 }
 
 // InsertEvent directly inserts a single event
@@ -109,167 +95,41 @@ func (db *DB) InsertEvent(ctx context.Context, evt nostr.Event) error {
 	// No need to add to Bloom filter here - that should be handled by the caller
 	// so that we can control when the event is considered "processed"
 
-	_, err := db.Pool.Exec(ctx,
-		`INSERT INTO events (id, pubkey, created_at, kind, tags, content, sig)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)
-		 ON CONFLICT (id) DO NOTHING`,
-		evt.ID, evt.PubKey, evt.CreatedAt.Time().Unix(),
-		evt.Kind, evt.Tags, evt.Content, evt.Sig)
-
-	if err != nil {
-		return fmt.Errorf("failed to insert event: %w", err)
-	}
-
-	return nil
+	return db.backend.InsertEvent(ctx, evt)
 }
 
-// Modified EventBuffer that processes events one at a time
-
-// BatchInsertEvents optimized for PostgreSQL with timeout handling
+// BatchInsertEvents stores regular events in as few transactions as the
+// driver allows. The events count as stored once all of them are written.
 func (db *DB) BatchInsertEvents(ctx context.Context, events []nostr.Event) error {
 	if len(events) == 0 {
 		return nil
 	}
 
-	// Use smaller batches for efficiency
-	const batchSize = 50
-
-	// Execute in smaller batches with retries
-	for i := 0; i < len(events); i += batchSize {
-		end := i + batchSize
-		if end > len(events) {
-			end = len(events)
-		}
-
-		batchEvents := events[i:end]
-		err := db.executeWithRetry(ctx, func(retryCtx context.Context) error {
-			return db.insertEventBatch(retryCtx, batchEvents)
-		})
-
-		if err != nil {
-			return fmt.Errorf("batch insert failed: %w", err)
-		}
+	if err := db.backend.BatchInsertEvents(ctx, events); err != nil {
+		return fmt.Errorf("batch insert failed: %w", err)
 	}
 
-	return nil
-}
-
-// Helper for actual batch insertion
-func (db *DB) insertEventBatch(ctx context.Context, events []nostr.Event) error {
-	tx, err := db.Pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer func() {
-		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-			db.recordError(fmt.Errorf("rollback failed: %w", rollbackErr))
-		}
-	}()
-
-	batch := &pgx.Batch{}
+	// Only committed events count as stored, so a failed batch can be retried
 	for _, evt := range events {
-		// Add event to bloom filter first
 		db.Bloom.AddString(evt.ID)
-
-		batch.Queue(
-			`INSERT INTO events (id, pubkey, created_at, kind, tags, content, sig)
-             VALUES ($1, $2, $3, $4, $5, $6, $7)
-             ON CONFLICT (id) DO NOTHING`,
-			evt.ID,
-			evt.PubKey,
-			evt.CreatedAt.Time().Unix(),
-			evt.Kind,
-			evt.Tags,
-			evt.Content,
-			evt.Sig,
-		)
 	}
-
-	results := tx.SendBatch(ctx, batch)
-	if err := results.Close(); err != nil {
-		return fmt.Errorf("batch execution failed: %w", err)
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("transaction commit failed: %w", err)
-	}
-
 	return nil
 }
 
 // GetReplaceableEvent retrieves the latest replaceable event for a given pubkey and kind.
 func (db *DB) GetReplaceableEvent(ctx context.Context, pubkey string, kind int) (nostr.Event, error) {
-	query := `
-		SELECT id, pubkey, kind, created_at, content, tags, sig
-		FROM events
-		WHERE pubkey = $1 AND kind = $2
-		ORDER BY created_at DESC
-		LIMIT 1`
-
-	row, err := db.ExecuteQuery(ctx, query, pubkey, kind)
-	if err != nil {
-		return nostr.Event{}, fmt.Errorf("failed to fetch replaceable event: %w", err)
-	}
-
-	var evt nostr.Event
-	var createdAt int64
-	err = row.Scan(&evt.ID, &evt.PubKey, &evt.Kind, &createdAt, &evt.Content, &evt.Tags, &evt.Sig)
-	if err != nil {
-		return nostr.Event{}, fmt.Errorf("replaceable event not found: %w", err)
-	}
-
-	evt.CreatedAt = nostr.Timestamp(createdAt) // Convert Unix timestamp to nostr.Timestamp
-
-	return evt, nil
+	return db.backend.GetReplaceableEvent(ctx, pubkey, kind)
 }
 
 // GetAddressableEvent retrieves the latest addressable event for a given pubkey, kind, and 'd' tag.
 func (db *DB) GetAddressableEvent(ctx context.Context, pubkey string, kind int, dVal string) (nostr.Event, error) {
-	query := `
-		SELECT id, pubkey, kind, created_at, content, tags, sig
-		FROM events
-		WHERE pubkey = $1 AND kind = $2 AND tags @> $3
-		ORDER BY created_at DESC
-		LIMIT 1`
-
-	row, err := db.ExecuteQuery(ctx, query, pubkey, kind, fmt.Sprintf(`[["d", "%s"]]`, dVal))
-	if err != nil {
-		return nostr.Event{}, fmt.Errorf("failed to fetch addressable event: %w", err)
-	}
-
-	var evt nostr.Event
-	var createdAt int64
-	err = row.Scan(&evt.ID, &evt.PubKey, &evt.Kind, &createdAt, &evt.Content, &evt.Tags, &evt.Sig)
-	if err != nil {
-		return nostr.Event{}, fmt.Errorf("addressable event not found: %w", err)
-	}
-
-	evt.CreatedAt = nostr.Timestamp(createdAt) // Convert Unix timestamp to nostr.Timestamp
-
-	return evt, nil
+	return db.backend.GetAddressableEvent(ctx, pubkey, kind, dVal)
 }
 
 // DeleteExpiredEvents removes events that have expired based on the "expiration" tag.
 func (db *DB) DeleteExpiredEvents(ctx context.Context) error {
-	query := `
-		DELETE FROM events
-		WHERE EXISTS (
-			SELECT 1 FROM jsonb_array_elements(tags) AS tag
-			WHERE tag->>0 = 'expiration' 
-			AND tag->>1 IS NOT NULL 
-			AND (tag->>1)::BIGINT < extract(epoch FROM now())
-		)`
-
-	logger.Debug("🗑 Deleting expired events...")
-
-	_, err := db.Pool.Exec(ctx, query)
-	if err != nil {
-		logger.Error("❌ Failed to delete expired events", zap.Error(err))
-		return fmt.Errorf("failed to delete expired events: %w", err)
-	}
-
-	logger.Debug("✅ Expired events deleted successfully")
-	return nil
+	_, err := db.CleanExpiredEvents(ctx)
+	return err
 }
 
 // CleanExpiredEvents removes events with expiration tags that have passed their expiration time
@@ -280,26 +140,17 @@ func (db *DB) CleanExpiredEvents(ctx context.Context) (int, error) {
 
 	logger.Debug("Deleting expired events...")
 
-	query := `
-		DELETE FROM events
-		WHERE EXISTS (
-			SELECT 1 FROM jsonb_array_elements(tags) AS tag
-			WHERE tag->>0 = 'expiration' 
-			AND tag->>1 IS NOT NULL 
-			AND (tag->>1)::BIGINT <= $1
-		)
-	`
-
-	result, err := db.Pool.Exec(ctx, query, time.Now().Unix())
+	count, err := db.backend.CleanExpiredEvents(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete expired events: %w", err)
+		return 0, err
+	}
+	if count > 0 {
+		db.clearQueryCache()
 	}
-
-	count := result.RowsAffected()
 	logger.Debug("Expired events deleted",
-		zap.Int64("count", count))
+		zap.Int("count", count))
 
-	return int(count), nil
+	return count, nil
 }
 
 // StartExpiredEventsCleaner starts a background goroutine to clean expired events periodically
@@ -324,254 +175,141 @@ func (db *DB) StartExpiredEventsCleaner(ctx context.Context, interval time.Durat
 	}()
 }
 
-// GetEventCount returns the count of events matching the given filter
-func (db *DB) GetEventCount(ctx context.Context, filter nostr.Filter) (int64, error) {
-	// PERFORMANCE: Create a query builder with reasonable capacity
-	query := strings.Builder{}
-	query.Grow(256) // Pre-allocate string builder capacity
-	args := make([]interface{}, 0, 10)
-	argIndex := 1
-
-	// Start with base SELECT COUNT
-	query.WriteString(`SELECT COUNT(*) FROM events`)
-
-	// Track if we need to add WHERE
-	needsWhere := false
-	addWhere := func() {
-		if !needsWhere {
-			query.WriteString(` WHERE `)
-			needsWhere = true
-		} else {
-			query.WriteString(` AND `)
-		}
-	}
-
-	// Add filters in order of index selectivity
-	hasIDFilter := len(filter.IDs) > 0
-	hasAuthorFilter := len(filter.Authors) > 0
-	hasKindFilter := len(filter.Kinds) > 0
-	hasSinceFilter := filter.Since != nil
-	hasUntilFilter := filter.Until != nil
-
-	// Apply filters based on most efficient index usage
-	if hasIDFilter {
-		// IDs are primary keys - most selective
-		addWhere()
-		query.WriteString(fmt.Sprintf("id = ANY($%d)", argIndex))
-		args = append(args, filter.IDs)
-		argIndex++
-	}
-
-	if hasAuthorFilter {
-		addWhere()
-		query.WriteString(fmt.Sprintf("pubkey = ANY($%d)", argIndex))
-		args = append(args, filter.Authors)
-		argIndex++
-	}
+// retentionKeepKinds matches the replaceable and addressable kinds, which
+// retention never removes: they hold current state, not history.
+const retentionKeepKinds = `(kind IN (0, 3) OR kind BETWEEN 10000 AND 19999 OR kind BETWEEN 30000 AND 39999)`
 
-	if hasKindFilter {
-		addWhere()
-		query.WriteString(fmt.Sprintf("kind = ANY($%d)", argIndex))
-		args = append(args, filter.Kinds)
-		argIndex++
+// PruneOldEvents removes regular events created before the given Unix time.
+// Unlike deletions they leave no tombstone, so the events may be published
+// again.
+func (db *DB) PruneOldEvents(ctx context.Context, before int64) (int, error) {
+	if !db.isConnected() {
+		return 0, fmt.Errorf("database is not connected")
 	}
-
-	// Always apply time filters after key/author filters
-	if hasSinceFilter {
-		addWhere()
-		query.WriteString(fmt.Sprintf("created_at >= $%d", argIndex))
-		args = append(args, filter.Since.Time().Unix())
-		argIndex++
+	count, err := db.backend.PruneOldEvents(ctx, before)
+	if count > 0 {
+		db.clearQueryCache()
 	}
+	return count, err
+}
 
-	if hasUntilFilter {
-		addWhere()
-		query.WriteString(fmt.Sprintf("created_at <= $%d", argIndex))
-		args = append(args, filter.Until.Time().Unix())
-		argIndex++
+// StartRetentionPruner periodically removes regular events older than maxAge.
+func (db *DB) StartRetentionPruner(ctx context.Context, maxAge, interval time.Duration) {
+	if maxAge <= 0 {
+		return
 	}
-
-	// Handle tag filtering
-	if len(filter.Tags) > 0 {
-		for tagName, tagValues := range filter.Tags {
-			if len(tagValues) > 0 {
-				addWhere()
-				// Use the inverted index on tags
-				query.WriteString(fmt.Sprintf("tags @> $%d", argIndex))
-				tagArray := make([][]string, len(tagValues))
-				for i, val := range tagValues {
-					tagArray[i] = []string{tagName, val}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				count, err := db.PruneOldEvents(ctx, time.Now().Add(-maxAge).Unix())
+				if err != nil {
+					logger.Error("Failed to prune old events", zap.Error(err))
+				} else if count > 0 {
+					logger.Info("Pruned events past retention",
+						zap.Int("count", count),
+						zap.Duration("max_age", maxAge))
 				}
-				args = append(args, tagArray)
-				argIndex++
 			}
 		}
-	}
+	}()
+}
 
-	// Log the query for debugging
-	logger.Debug("Executing count query",
-		zap.String("query", query.String()),
-		zap.Int("arg_count", len(args)))
+// GetEventCount returns the count of events matching the given filter
+func (db *DB) GetEventCount(ctx context.Context, filter nostr.Filter) (int64, error) {
+	scope := tenantCacheScope(ctx)
 
-	// Execute query with timeout
-	var count int64
-	err := db.Pool.QueryRow(ctx, query.String(), args...).Scan(&count)
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return 0, fmt.Errorf("count operation timed out")
+	// Serve repeated COUNT filters from the result cache
+	if db.queryCache != nil {
+		if count, ok := db.queryCache.GetCount(scope, filter); ok {
+			return count, nil
 		}
-		return 0, fmt.Errorf("failed to execute count query: %w", err)
 	}
 
-	return count, nil
+	count, err := db.backend.GetEventCount(ctx, filter)
+	if err == nil && db.queryCache != nil {
+		db.queryCache.PutCount(scope, filter, count)
+	}
+	return count, err
 }
 
 // GetEventPubkeys returns pubkeys of events matching the given filter.
 // Used for NIP-45 HyperLogLog computation.
 func (db *DB) GetEventPubkeys(ctx context.Context, filter nostr.Filter) ([]string, error) {
-	query := strings.Builder{}
-	query.Grow(256)
-	args := make([]interface{}, 0, 10)
-	argIndex := 1
-
-	query.WriteString(`SELECT pubkey FROM events`)
-
-	needsWhere := false
-	addWhere := func() {
-		if !needsWhere {
-			query.WriteString(` WHERE `)
-			needsWhere = true
-		} else {
-			query.WriteString(` AND `)
-		}
-	}
+	return db.backend.GetEventPubkeys(ctx, filter)
+}
 
-	if len(filter.IDs) > 0 {
-		addWhere()
-		query.WriteString(fmt.Sprintf("id = ANY($%d)", argIndex))
-		args = append(args, filter.IDs)
-		argIndex++
-	}
-	if len(filter.Authors) > 0 {
-		addWhere()
-		query.WriteString(fmt.Sprintf("pubkey = ANY($%d)", argIndex))
-		args = append(args, filter.Authors)
-		argIndex++
-	}
-	if len(filter.Kinds) > 0 {
-		addWhere()
-		query.WriteString(fmt.Sprintf("kind = ANY($%d)", argIndex))
-		args = append(args, filter.Kinds)
-		argIndex++
-	}
-	if filter.Since != nil {
-		addWhere()
-		query.WriteString(fmt.Sprintf("created_at >= $%d", argIndex))
-		args = append(args, filter.Since.Time().Unix())
-		argIndex++
-	}
-	if filter.Until != nil {
-		addWhere()
-		query.WriteString(fmt.Sprintf("created_at <= $%d", argIndex))
-		args = append(args, filter.Until.Time().Unix())
-		argIndex++
-	}
-	if len(filter.Tags) > 0 {
-		for tagName, tagValues := range filter.Tags {
-			if len(tagValues) > 0 {
-				addWhere()
-				query.WriteString(fmt.Sprintf("tags @> $%d", argIndex))
-				tagArray := make([][]string, len(tagValues))
-				for i, val := range tagValues {
-					tagArray[i] = []string{tagName, val}
-				}
-				args = append(args, tagArray)
-				argIndex++
-			}
-		}
-	}
+// EventExists reports whether an event is stored.
+func (db *DB) EventExists(ctx context.Context, eventID string) (bool, error) {
+	return db.backend.EventExists(ctx, eventID)
+}
 
-	rows, err := db.Pool.Query(ctx, query.String(), args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query event pubkeys: %w", err)
+// RemoveEvent tombstones a stored event on behalf of the relay (moderation),
+// keeping the hot store and query cache in step.
+func (db *DB) RemoveEvent(ctx context.Context, evt *nostr.Event) error {
+	if err := db.backend.DeleteEventByID(ctx, evt.ID); err != nil {
+		return fmt.Errorf("failed to remove event: %w", err)
 	}
-	defer rows.Close()
 
-	var pubkeys []string
-	for rows.Next() {
-		var pk string
-		if err := rows.Scan(&pk); err != nil {
-			return nil, fmt.Errorf("failed to scan pubkey: %w", err)
+	if db.hotStore != nil {
+		if err := db.hotStore.Remove(evt); err != nil {
+			logger.Warn("Failed to remove event from hot store",
+				zap.String("event_id", evt.ID),
+				zap.Error(err))
 		}
-		pubkeys = append(pubkeys, pk)
 	}
-	return pubkeys, rows.Err()
+	db.invalidateQueryCache(evt)
+	return nil
 }
 
-func (db *DB) EventExists(ctx context.Context, eventID string) (bool, error) {
-	var exists bool
-	err := db.Pool.QueryRow(ctx,
-		`SELECT EXISTS(SELECT 1 FROM events WHERE id = $1)`,
-		eventID,
-	).Scan(&exists)
-	return exists, err
-}
+// ErrStaleEvent is returned when a replaceable or addressable event loses to
+// the version already stored; the event is dropped without error to the client.
+var ErrStaleEvent = errors.New("stale: a newer version of this event is stored")
 
-func (db *DB) InsertReplaceableEvent(ctx context.Context, evt nostr.Event) error {
-	// First, delete any existing replaceable event for this pubkey and kind
-	_, err := db.Pool.Exec(ctx,
-		`DELETE FROM events 
-		 WHERE pubkey = $1 AND kind = $2`,
-		evt.PubKey, evt.Kind)
-	if err != nil {
-		return fmt.Errorf("failed to delete old replaceable event: %w", err)
-	}
+// maxReplaceableAttempts bounds retries of a replaceable upsert that lost a
+// race with a concurrent writer for the same address.
+const maxReplaceableAttempts = 3
 
-	// Then insert the new event
-	_, err = db.Pool.Exec(ctx,
-		`INSERT INTO events (id, pubkey, created_at, kind, tags, content, sig)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		evt.ID, evt.PubKey, evt.CreatedAt.Time().Unix(),
-		evt.Kind, evt.Tags, evt.Content, evt.Sig)
-	if err != nil {
-		return fmt.Errorf("failed to insert new replaceable event: %w", err)
+// supersedes reports whether a replaceable event replaces the stored version:
+// the newer one wins, and on equal timestamps the lowest ID (NIP-01).
+func supersedes(createdAt int64, id string, storedAt int64, storedID string) bool {
+	if createdAt != storedAt {
+		return createdAt > storedAt
 	}
+	return id < storedID
+}
 
-	// Add to Bloom filter
+// InsertReplaceableEvent stores evt as the version of (pubkey, kind), unless
+// the stored version supersedes it (ErrStaleEvent).
+func (db *DB) InsertReplaceableEvent(ctx context.Context, evt nostr.Event) error {
+	if err := db.backend.InsertReplaceableEvent(ctx, evt); err != nil {
+		return err
+	}
 	db.Bloom.AddString(evt.ID)
-
 	return nil
 }
 
-// InsertAddressableEvent upserts (pubkey, kind, dTag) = unique
+// InsertAddressableEvent stores evt as the version of (pubkey, kind, d),
+// unless the stored version supersedes it (ErrStaleEvent).
 func (db *DB) InsertAddressableEvent(ctx context.Context, evt nostr.Event) error {
 	dVal := nips.GetTagValue(evt, "d")
 	if dVal == "" {
 		return db.InsertEvent(ctx, evt) // fallback
 	}
 
-	_, err := db.Pool.Exec(ctx,
-		`DELETE FROM events 
-         WHERE pubkey=$1 AND kind=$2 AND tags @> $3`,
-		evt.PubKey, evt.Kind, fmt.Sprintf(`[["d","%s"]]`, dVal),
-	)
-	if err != nil {
+	if err := db.backend.InsertAddressableEvent(ctx, evt); err != nil {
 		return err
 	}
-
-	_, err = db.Pool.Exec(ctx,
-		`INSERT INTO events (id,pubkey,created_at,kind,tags,content,sig)
-         VALUES ($1,$2,$3,$4,$5,$6,$7)`,
-		evt.ID, evt.PubKey, evt.CreatedAt.Time().Unix(),
-		evt.Kind, evt.Tags, evt.Content, evt.Sig,
-	)
-	if err == nil {
-		db.Bloom.AddString(evt.ID)
-	}
-	return err
+	db.Bloom.AddString(evt.ID)
+	return nil
 }
 
-func (db *DB) persistDeletion(ctx context.Context, del nostr.Event) error {
+// deletionTargets returns the "e" event IDs and "a" tags referenced by a NIP-09 request.
+func deletionTargets(del nostr.Event) ([]string, []nostr.Tag) {
 	var eIDs []string
 	var aTags []nostr.Tag
 	for _, t := range del.Tags {
@@ -582,69 +320,13 @@ func (db *DB) persistDeletion(ctx context.Context, del nostr.Event) error {
 			aTags = append(aTags, t)
 		}
 	}
-	if len(eIDs) == 0 && len(aTags) == 0 {
-		return errors.New("deletion event without e or a tags")
-	}
-
-	tx, err := db.Pool.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-			db.recordError(fmt.Errorf("rollback failed: %w", rollbackErr))
-		}
-	}()
-
-	// 1) delete events by "e" tag (referenced by event ID) — only if owned by deleter
-	if len(eIDs) > 0 {
-		_, err = tx.Exec(ctx,
-			`DELETE FROM events WHERE id = ANY($1) AND pubkey = $2`,
-			eIDs, del.PubKey)
-		if err != nil {
-			return err
-		}
-	}
-
-	// 2) delete events by "a" tag (addressable events) — NIP-09 spec
-	//    format: <kind>:<pubkey>:<d-identifier>
-	//    only delete versions up to the deletion request's created_at
-	for _, tag := range aTags {
-		parts := strings.SplitN(tag[1], ":", 3)
-		if len(parts) != 3 {
-			continue
-		}
-		// Only delete if the pubkey in the "a" tag matches the deleter
-		if parts[1] != del.PubKey {
-			continue
-		}
-		_, err = tx.Exec(ctx,
-			`DELETE FROM events WHERE kind = $1 AND pubkey = $2
-			 AND tags @> $3::jsonb AND created_at <= $4`,
-			parts[0], del.PubKey,
-			fmt.Sprintf(`[["d","%s"]]`, parts[2]),
-			del.CreatedAt.Time().Unix())
-		if err != nil {
-			logger.Warn("NIP-09: Failed to delete addressable event",
-				zap.String("a_tag", tag[1]),
-				zap.Error(err))
-		}
-	}
-
-	// 3) insert the deletion event itself
-	_, err = tx.Exec(ctx,
-		`INSERT INTO events (id,pubkey,created_at,kind,tags,content,sig)
-		 VALUES ($1,$2,$3,$4,$5,$6,$7)`,
-		del.ID, del.PubKey, del.CreatedAt.Time().Unix(),
-		del.Kind, del.Tags, del.Content, del.Sig)
-	if err != nil {
-		return err
-	}
+	return eIDs, aTags
+}
 
-	if err := tx.Commit(ctx); err != nil {
+func (db *DB) persistDeletion(ctx context.Context, del nostr.Event) error {
+	if err := db.backend.PersistDeletion(ctx, del); err != nil {
 		return err
 	}
-
 	db.Bloom.AddString(del.ID)
 	return nil
 }
@@ -653,61 +335,9 @@ func (db *DB) persistDeletion(ctx context.Context, del nostr.Event) error {
 // Also deletes gift-wrapped events (kind 1059) addressed to this pubkey.
 // Stores the vanish request itself and adds pubkey to vanished set.
 func (db *DB) persistVanish(ctx context.Context, evt nostr.Event) error {
-	tx, err := db.Pool.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-			db.recordError(fmt.Errorf("rollback failed: %w", rollbackErr))
-		}
-	}()
-
-	// 1) Delete ALL events from this pubkey up to the vanish request's created_at
-	result, err := tx.Exec(ctx,
-		`DELETE FROM events WHERE pubkey = $1 AND created_at <= $2`,
-		evt.PubKey, evt.CreatedAt.Time().Unix())
-	if err != nil {
-		return fmt.Errorf("failed to delete events for vanish: %w", err)
-	}
-	deletedCount := result.RowsAffected()
-
-	// 2) Delete gift-wrapped events (kind 1059) that p-tagged this pubkey
-	giftResult, err := tx.Exec(ctx,
-		`DELETE FROM events WHERE kind = 1059 AND tags @> $1::jsonb`,
-		fmt.Sprintf(`[["p","%s"]]`, evt.PubKey))
-	if err != nil {
-		logger.Warn("NIP-62: Failed to delete gift-wrapped events",
-			zap.String("pubkey", evt.PubKey),
-			zap.Error(err))
-		// Non-fatal: continue with vanish
-	} else {
-		giftDeleted := giftResult.RowsAffected()
-		if giftDeleted > 0 {
-			logger.Info("NIP-62: Deleted gift-wrapped events",
-				zap.String("pubkey", evt.PubKey),
-				zap.Int64("count", giftDeleted))
-		}
-	}
-
-	// 3) Store the vanish request itself for bookkeeping
-	_, err = tx.Exec(ctx,
-		`INSERT INTO events (id,pubkey,created_at,kind,tags,content,sig)
-		 VALUES ($1,$2,$3,$4,$5,$6,$7)`,
-		evt.ID, evt.PubKey, evt.CreatedAt.Time().Unix(),
-		evt.Kind, evt.Tags, evt.Content, evt.Sig)
-	if err != nil {
-		return fmt.Errorf("failed to store vanish request: %w", err)
-	}
-
-	if err := tx.Commit(ctx); err != nil {
+	if err := db.backend.PersistVanish(ctx, evt); err != nil {
 		return err
 	}
-
-	logger.Info("NIP-62: Vanish request processed",
-		zap.String("pubkey", evt.PubKey),
-		zap.Int64("events_deleted", deletedCount))
-
 	db.Bloom.AddString(evt.ID)
 	return nil
 }
@@ -718,14 +348,7 @@ func (db *DB) IsVanishedPubkey(ctx context.Context, pubkey string) (bool, error)
 	if !db.isConnected() {
 		return false, fmt.Errorf("database is not connected")
 	}
-	var count int64
-	err := db.Pool.QueryRow(ctx,
-		`SELECT COUNT(*) FROM events WHERE kind = 62 AND pubkey = $1`,
-		pubkey).Scan(&count)
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
+	return db.backend.IsVanishedPubkey(ctx, pubkey)
 }
 
 // GetTotalEventCount returns the total number of events stored in the database
@@ -733,12 +356,5 @@ func (db *DB) GetTotalEventCount(ctx context.Context) (int64, error) {
 	if !db.isConnected() {
 		return 0, fmt.Errorf("database is not connected")
 	}
-
-	var count int64
-	err := db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM events").Scan(&count)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get total event count: %w", err)
-	}
-
-	return count, nil
+	return db.backend.GetTotalEventCount(ctx)
 }
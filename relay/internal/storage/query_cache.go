@@ -0,0 +1,319 @@
+package storage
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// QueryCache caches COUNT results and small REQ results keyed by a canonical
+// form of the filter. Entries expire after a short TTL and are dropped early
+// when a newly stored or deleted event could change their result. Entries
+// are indexed by the kinds, or else the authors, their filter names, so an
+// event only has to be checked against the entries that could match it.
+type QueryCache struct {
+	mu         sync.Mutex
+	entries    map[string]*queryCacheEntry
+	byKind     map[int]map[string]struct{}    // entry keys by filter kind
+	byAuthor   map[string]map[string]struct{} // entry keys by filter author, for filters without kinds
+	unindexed  map[string]struct{}            // entry keys of filters naming neither
+	ttl        time.Duration
+	maxEntries int
+	maxLimit   int // REQ filters with a larger limit are not cached
+}
+
+type queryCacheEntry struct {
+	filter  nostr.Filter
+	events  []nostr.Event
+	count   int64
+	expires time.Time
+}
+
+// NewQueryCache creates a query result cache. Zero values fall back to defaults.
+func NewQueryCache(ttl time.Duration, maxEntries, maxLimit int) *QueryCache {
+	if ttl <= 0 {
+		ttl = 5 * time.Second
+	}
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	if maxLimit <= 0 {
+		maxLimit = 100
+	}
+	qc := &QueryCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxLimit:   maxLimit,
+	}
+	qc.reset()
+	return qc
+}
+
+// GetEvents returns cached REQ results for a filter. Scope keeps the results
+// of different tenants apart ("" outside multi-tenant mode).
+func (qc *QueryCache) GetEvents(scope string, filter nostr.Filter) ([]nostr.Event, bool) {
+	if filter.Limit > qc.maxLimit {
+		return nil, false
+	}
+	entry := qc.get("req"+scope, filter)
+	if entry == nil {
+		return nil, false
+	}
+	events := make([]nostr.Event, len(entry.events))
+	copy(events, entry.events)
+	return events, true
+}
+
+// PutEvents caches REQ results for a filter.
+func (qc *QueryCache) PutEvents(scope string, filter nostr.Filter, events []nostr.Event) {
+	if filter.Limit > qc.maxLimit {
+		return
+	}
+	stored := make([]nostr.Event, len(events))
+	copy(stored, events)
+	qc.put("req"+scope, filter, &queryCacheEntry{events: stored})
+}
+
+// GetCount returns a cached COUNT result for a filter.
+func (qc *QueryCache) GetCount(scope string, filter nostr.Filter) (int64, bool) {
+	entry := qc.get("count"+scope, filter)
+	if entry == nil {
+		return 0, false
+	}
+	return entry.count, true
+}
+
+// PutCount caches a COUNT result for a filter.
+func (qc *QueryCache) PutCount(scope string, filter nostr.Filter, count int64) {
+	qc.put("count"+scope, filter, &queryCacheEntry{count: count})
+}
+
+func (qc *QueryCache) get(prefix string, filter nostr.Filter) *queryCacheEntry {
+	key, ok := queryCacheKey(prefix, filter)
+	if !ok {
+		return nil
+	}
+
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+
+	entry := qc.entries[key]
+	if entry == nil || time.Now().After(entry.expires) {
+		if entry != nil {
+			qc.remove(key)
+		}
+		metrics.QueryCacheLookups.WithLabelValues("miss").Inc()
+		return nil
+	}
+	metrics.QueryCacheLookups.WithLabelValues("hit").Inc()
+	return entry
+}
+
+func (qc *QueryCache) put(prefix string, filter nostr.Filter, entry *queryCacheEntry) {
+	key, ok := queryCacheKey(prefix, filter)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	entry.filter = filter
+	entry.expires = now.Add(qc.ttl)
+
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+
+	if len(qc.entries) >= qc.maxEntries {
+		// Drop expired entries first, then everything if still full
+		for k, e := range qc.entries {
+			if now.After(e.expires) {
+				qc.remove(k)
+			}
+		}
+		if len(qc.entries) >= qc.maxEntries {
+			qc.reset()
+		}
+	}
+	if qc.entries[key] != nil {
+		qc.remove(key)
+	}
+	qc.entries[key] = entry
+	kinds, authors := indexOf(filter)
+	switch {
+	case len(kinds) > 0:
+		for _, kind := range kinds {
+			addKey(qc.byKind, kind, key)
+		}
+	case len(authors) > 0:
+		for _, author := range authors {
+			addKey(qc.byAuthor, author, key)
+		}
+	default:
+		qc.unindexed[key] = struct{}{}
+	}
+}
+
+// indexOf returns the kinds an entry for filter is indexed under, or else its
+// authors; neither when the filter names no kind and some author is a prefix.
+func indexOf(filter nostr.Filter) ([]int, []string) {
+	if len(filter.Kinds) > 0 {
+		return filter.Kinds, nil
+	}
+	for _, author := range filter.Authors {
+		if len(author) != 64 {
+			return nil, nil
+		}
+	}
+	return nil, filter.Authors
+}
+
+func addKey[K comparable](index map[K]map[string]struct{}, k K, key string) {
+	keys := index[k]
+	if keys == nil {
+		keys = make(map[string]struct{})
+		index[k] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+func removeKey[K comparable](index map[K]map[string]struct{}, k K, key string) {
+	if keys := index[k]; keys != nil {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(index, k)
+		}
+	}
+}
+
+// remove drops an entry and its index keys. Callers hold qc.mu.
+func (qc *QueryCache) remove(key string) {
+	entry := qc.entries[key]
+	if entry == nil {
+		return
+	}
+	delete(qc.entries, key)
+	kinds, authors := indexOf(entry.filter)
+	for _, kind := range kinds {
+		removeKey(qc.byKind, kind, key)
+	}
+	for _, author := range authors {
+		removeKey(qc.byAuthor, author, key)
+	}
+	delete(qc.unindexed, key)
+}
+
+// reset drops every entry. Callers hold qc.mu.
+func (qc *QueryCache) reset() {
+	qc.entries = make(map[string]*queryCacheEntry)
+	qc.byKind = make(map[int]map[string]struct{})
+	qc.byAuthor = make(map[string]map[string]struct{})
+	qc.unindexed = make(map[string]struct{})
+}
+
+// Clear drops every cached result, for deletions that do not know which
+// events they removed, such as the expiry cleaner and the retention pruner.
+func (qc *QueryCache) Clear() {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.reset()
+}
+
+// Invalidate drops cached results that a newly stored or removed event could
+// change. Deletions and vanish requests flush the whole cache; replaceable
+// and addressable events drop every entry that could include their kind and
+// author, since they also remove an older version. Only the entries indexed
+// under the event's kind or author, and those without an index, are checked.
+func (qc *QueryCache) Invalidate(evt *nostr.Event) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+
+	if nips.IsDeletionEvent(*evt) || nips.IsVanishEvent(*evt) {
+		qc.reset()
+		return
+	}
+
+	replaces := nips.IsReplaceable(evt.Kind) || nips.IsAddressable(*evt)
+	check := func(key string) {
+		entry := qc.entries[key]
+		if entry == nil {
+			return
+		}
+		if MatchesFilter(entry.filter, evt) ||
+			replaces && (len(entry.filter.Kinds) == 0 || containsInt(entry.filter.Kinds, evt.Kind)) {
+			qc.remove(key)
+		}
+	}
+	for key := range qc.byKind[evt.Kind] {
+		check(key)
+	}
+	for key := range qc.byAuthor[evt.PubKey] {
+		check(key)
+	}
+	for key := range qc.unindexed {
+		check(key)
+	}
+}
+
+// queryCacheKey builds an order-independent key for a filter.
+// Search filters are never cached.
+func queryCacheKey(prefix string, f nostr.Filter) (string, bool) {
+	if f.Search != "" {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+
+	writeSorted := func(name string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		sorted := append([]string(nil), values...)
+		sort.Strings(sorted)
+		b.WriteString("|" + name + "=")
+		b.WriteString(strings.Join(sorted, ","))
+	}
+
+	writeSorted("ids", f.IDs)
+	writeSorted("authors", f.Authors)
+
+	if len(f.Kinds) > 0 {
+		kinds := make([]string, len(f.Kinds))
+		for i, k := range f.Kinds {
+			kinds[i] = strconv.Itoa(k)
+		}
+		writeSorted("kinds", kinds)
+	}
+
+	tagNames := make([]string, 0, len(f.Tags))
+	for name := range f.Tags {
+		tagNames = append(tagNames, name)
+	}
+	sort.Strings(tagNames)
+	for _, name := range tagNames {
+		writeSorted("#"+name, f.Tags[name])
+	}
+
+	if f.Since != nil {
+		b.WriteString("|since=" + strconv.FormatInt(int64(*f.Since), 10))
+	}
+	if f.Until != nil {
+		b.WriteString("|until=" + strconv.FormatInt(int64(*f.Until), 10))
+	}
+	b.WriteString("|limit=" + strconv.Itoa(f.Limit))
+
+	return b.String(), true
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Read replica routing: REQ and COUNT traffic can be served from a second
+// pool, connected to a PostgreSQL standby or Aurora reader endpoint, or to
+// CockroachDB with follower reads. Replicas lag the primary, so only queries
+// made with a WithReplicaRead context use it; writes and the relay's own
+// lookups, which must see events it just stored, stay on the primary pool.
+
+type replicaReadKey struct{}
+
+// WithReplicaRead lets GetEvents and GetEventCount made with ctx use the read
+// pool, accepting results that lag the primary by the replication delay.
+func WithReplicaRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadKey{}, true)
+}
+
+// queryPool returns the pool reads made with ctx run on.
+func (db *DB) queryPool(ctx context.Context) *pgxpool.Pool {
+	if db.readPool != nil {
+		if ok, _ := ctx.Value(replicaReadKey{}).(bool); ok {
+			return db.readPool
+		}
+	}
+	return db.Pool
+}
+
+// OpenReadPool connects the pool REQ and COUNT queries run on. With
+// followerReads, connections to CockroachDB read at
+// follower_read_timestamp(), so any replica near the node can answer;
+// the setting is ignored by other databases.
+func (db *DB) OpenReadPool(ctx context.Context, dbURI string, maxWSConnections int, settings config.PoolConfig, followerReads bool) error {
+	if !db.usesPool() {
+		return ErrUnsupportedByDriver
+	}
+
+	poolConfig, err := poolConfigBasedOnLoad(dbURI, maxWSConnections, settings)
+	if err != nil {
+		return err
+	}
+	if followerReads {
+		poolConfig.AfterConnect = enableFollowerReads
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create read pool: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return fmt.Errorf("failed to connect to read replica: %w", err)
+	}
+
+	db.readPool = pool
+	logger.Info("Read pool connected",
+		zap.Bool("follower_reads", followerReads),
+		zap.Int32("db_max_connections", pool.Stat().MaxConns()))
+	return nil
+}
+
+// enableFollowerReads makes read-only transactions on a CockroachDB
+// connection use follower reads. CockroachDB reports crdb_version on connect.
+func enableFollowerReads(ctx context.Context, conn *pgx.Conn) error {
+	if conn.PgConn().ParameterStatus("crdb_version") == "" {
+		return nil
+	}
+	if _, err := conn.Exec(ctx, "SET default_transaction_use_follower_reads = on"); err != nil {
+		// Still usable, reads are served by leaseholders instead
+		logger.Warn("Failed to enable follower reads", zap.Error(err))
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// First-seen timestamps: every stored event records when this relay received
+// it in events.received_at (unix seconds), so operators can compare it with
+// created_at to debug propagation delays. Events stored before the column
+// existed have no received_at.
+
+// receivedAtDDL adds the column to events tables created before it existed.
+const receivedAtDDL = `ALTER TABLE events ADD COLUMN IF NOT EXISTS received_at BIGINT NULL`
+
+// receivedAtIndexDDL serves received_at range filters.
+const receivedAtIndexDDL = `CREATE INDEX IF NOT EXISTS events_received_at ON events (received_at DESC)`
+
+// ensureReceivedAtSchema adds the received_at column and its index if missing.
+func (db *DB) ensureReceivedAtSchema(ctx context.Context) error {
+	for _, ddl := range []string{receivedAtDDL, receivedAtIndexDDL} {
+		if _, err := db.Pool.Exec(ctx, ddl); err != nil {
+			return fmt.Errorf("failed to add events.received_at: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSQLiteReceivedAt adds the received_at column to SQLite databases
+// created before it existed. SQLite has no ADD COLUMN IF NOT EXISTS.
+func migrateSQLiteReceivedAt(ctx context.Context, sqlDB *sql.DB) error {
+	var exists bool
+	if err := sqlDB.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pragma_table_info('events') WHERE name = 'received_at')`,
+	).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := sqlDB.ExecContext(ctx, `ALTER TABLE events ADD COLUMN received_at INTEGER`); err != nil {
+			return err
+		}
+	}
+	_, err := sqlDB.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS events_received_at ON events (received_at DESC)`)
+	return err
+}
+
+type receivedAtKey struct{}
+
+// WithReceivedAt sets the time events stored with ctx were received. Without
+// it, events are stamped with the time they are written.
+func WithReceivedAt(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, receivedAtKey{}, t)
+}
+
+// receivedAtFromContext returns the received time of events stored with ctx.
+func receivedAtFromContext(ctx context.Context) int64 {
+	if t, ok := ctx.Value(receivedAtKey{}).(time.Time); ok && !t.IsZero() {
+		return t.Unix()
+	}
+	return time.Now().Unix()
+}
+
+// ReceivedRange bounds the received_at of events returned by GetEvents.
+// Zero bounds are open; events without received_at never match.
+type ReceivedRange struct {
+	Since int64
+	Until int64
+}
+
+type receivedRangeKey struct{}
+
+// WithReceivedRange limits GetEvents made with ctx to events received within r.
+func WithReceivedRange(ctx context.Context, r ReceivedRange) context.Context {
+	return context.WithValue(ctx, receivedRangeKey{}, r)
+}
+
+// ReceivedRangeFromContext returns the received_at bounds of ctx, if any.
+func ReceivedRangeFromContext(ctx context.Context) (ReceivedRange, bool) {
+	r, ok := ctx.Value(receivedRangeKey{}).(ReceivedRange)
+	return r, ok && (r.Since > 0 || r.Until > 0)
+}
+
+// receivedRangeConds returns the conditions of r for the given placeholder
+// function.
+func receivedRangeConds(r ReceivedRange, placeholder func() string) ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if r.Since > 0 {
+		conds = append(conds, "received_at >= "+placeholder())
+		args = append(args, r.Since)
+	}
+	if r.Until > 0 {
+		conds = append(conds, "received_at <= "+placeholder())
+		args = append(args, r.Until)
+	}
+	return conds, args
+}
+
+// GetReceivedAt returns the received_at of the stored events among ids.
+// Events without one are left out.
+func (db *DB) GetReceivedAt(ctx context.Context, ids []string) (map[string]int64, error) {
+	if !db.isConnected() {
+		return nil, fmt.Errorf("database is not connected")
+	}
+	if len(ids) == 0 {
+		return map[string]int64{}, nil
+	}
+	return db.backend.GetReceivedAt(ctx, ids)
+}
+
+// GetReceivedAt returns the received_at of the stored events among ids.
+func (p *postgresBackend) GetReceivedAt(ctx context.Context, ids []string) (map[string]int64, error) {
+	rows, err := p.db.Pool.Query(ctx,
+		`SELECT id, received_at FROM events WHERE id = ANY($1) AND received_at IS NOT NULL`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query received_at: %w", err)
+	}
+	defer rows.Close()
+
+	received := make(map[string]int64, len(ids))
+	for rows.Next() {
+		var id string
+		var at int64
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		received[id] = at
+	}
+	return received, rows.Err()
+}
+
+// GetReceivedAt returns the received_at of the stored events among ids.
+func (s *SQLiteBackend) GetReceivedAt(ctx context.Context, ids []string) (map[string]int64, error) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, received_at FROM events WHERE id IN (`+
+			strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")+`) AND received_at IS NOT NULL`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query received_at: %w", err)
+	}
+	defer rows.Close()
+
+	received := make(map[string]int64, len(ids))
+	for rows.Next() {
+		var id string
+		var at int64
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		received[id] = at
+	}
+	return received, rows.Err()
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Shugur-Network/relay/internal/metrics"
+)
+
+// maxRecentIDs bounds the recently stored event IDs kept for duplicate checks.
+const maxRecentIDs = 100000
+
+// recentIDs remembers the IDs of the most recently stored events, so
+// duplicates of fresh events, the common case of clients publishing to
+// several relays and retrying, are recognized without a query.
+type recentIDs struct {
+	mu    sync.Mutex
+	ids   map[string]struct{}
+	order []string // insertion order for eviction
+	next  int
+}
+
+func (r *recentIDs) add(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ids == nil {
+		r.ids = make(map[string]struct{})
+	}
+	if _, known := r.ids[id]; known {
+		return
+	}
+	if len(r.order) < maxRecentIDs {
+		r.order = append(r.order, id)
+	} else {
+		delete(r.ids, r.order[r.next])
+		r.order[r.next] = id
+		r.next = (r.next + 1) % maxRecentIDs
+	}
+	r.ids[id] = struct{}{}
+}
+
+func (r *recentIDs) has(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.ids[id]
+	return ok
+}
+
+// markStored records that an event is in storage.
+func (db *DB) markStored(id string) {
+	db.Bloom.AddString(id)
+	db.recent.add(id)
+}
+
+// SeenEvent reports whether an event is stored, answering from memory when
+// it can: once a rebuilt Bloom filter holds every stored ID, which requires
+// this node to be the database's only writer, a miss means the event is new,
+// and a recently stored ID is a duplicate. Only the remaining Bloom
+// hits, which may be false positives, are looked up in storage.
+func (db *DB) SeenEvent(ctx context.Context, id string) (bool, error) {
+	if db.bloomComplete.Load() && !db.Bloom.Test([]byte(id)) {
+		metrics.DuplicateChecks.WithLabelValues("bloom").Inc()
+		return false, nil
+	}
+	if db.recent.has(id) {
+		metrics.DuplicateChecks.WithLabelValues("recent").Inc()
+		return true, nil
+	}
+	metrics.DuplicateChecks.WithLabelValues("storage").Inc()
+	return db.EventExists(ctx, id)
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// KindRelayListMetadata is the NIP-65 relay list kind.
+const KindRelayListMetadata = 10002
+
+// maxRelayHintsPerPubkey bounds the hints recorded from a single relay list.
+const maxRelayHintsPerPubkey = 50
+
+// relayHintsDDL creates the NIP-65 hint side table, one row per pubkey and
+// relay URL.
+const relayHintsDDL = `CREATE TABLE IF NOT EXISTS relay_hints (
+  pubkey CHAR(64) NOT NULL,
+  url TEXT NOT NULL,
+  read BOOLEAN NOT NULL DEFAULT TRUE,
+  write BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at BIGINT NOT NULL,
+  CONSTRAINT relay_hints_pkey PRIMARY KEY (pubkey, url)
+)`
+
+// RelayHint is a relay advertised by a pubkey in its NIP-65 relay list.
+type RelayHint struct {
+	URL       string `json:"url"`
+	Read      bool   `json:"read"`
+	Write     bool   `json:"write"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// ensureRelayHintsSchema creates the relay_hints table if it does not exist.
+func (db *DB) ensureRelayHintsSchema(ctx context.Context) error {
+	if _, err := db.Pool.Exec(ctx, relayHintsDDL); err != nil {
+		return fmt.Errorf("failed to create relay_hints table: %w", err)
+	}
+	return nil
+}
+
+// ParseRelayHints extracts the "r" tags of a kind 10002 event. A tag without
+// a marker means the relay is used for both reading and writing.
+func ParseRelayHints(evt *nostr.Event) []RelayHint {
+	var hints []RelayHint
+	seen := make(map[string]bool)
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "r" {
+			continue
+		}
+		url := nostr.NormalizeURL(tag[1])
+		if url == "" || seen[url] || (!strings.HasPrefix(url, "wss://") && !strings.HasPrefix(url, "ws://")) {
+			continue
+		}
+		seen[url] = true
+
+		hint := RelayHint{URL: url, Read: true, Write: true, CreatedAt: int64(evt.CreatedAt)}
+		if len(tag) >= 3 {
+			switch tag[2] {
+			case "read":
+				hint.Write = false
+			case "write":
+				hint.Read = false
+			}
+		}
+		hints = append(hints, hint)
+		if len(hints) >= maxRelayHintsPerPubkey {
+			break
+		}
+	}
+	return hints
+}
+
+// RecordRelayHints replaces the hints stored for the author of a kind 10002
+// event. Older relay lists never overwrite newer ones.
+func (db *DB) RecordRelayHints(ctx context.Context, evt *nostr.Event) error {
+	if evt.Kind != KindRelayListMetadata || !db.usesPool() {
+		return nil
+	}
+	hints := ParseRelayHints(evt)
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin relay hints transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var newest int64
+	if err := tx.QueryRow(ctx,
+		`SELECT COALESCE(MAX(created_at), 0) FROM relay_hints WHERE pubkey = $1`,
+		evt.PubKey).Scan(&newest); err != nil {
+		return fmt.Errorf("failed to read relay hints: %w", err)
+	}
+	if newest > int64(evt.CreatedAt) {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM relay_hints WHERE pubkey = $1`, evt.PubKey); err != nil {
+		return fmt.Errorf("failed to clear relay hints: %w", err)
+	}
+	for _, hint := range hints {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO relay_hints (pubkey, url, read, write, created_at) VALUES ($1, $2, $3, $4, $5)`,
+			evt.PubKey, hint.URL, hint.Read, hint.Write, hint.CreatedAt); err != nil {
+			return fmt.Errorf("failed to insert relay hint: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit relay hints: %w", err)
+	}
+
+	logger.Debug("Recorded NIP-65 relay hints",
+		zap.String("pubkey", evt.PubKey),
+		zap.Int("relays", len(hints)))
+	return nil
+}
+
+// GetRelayHints returns the relays a pubkey advertises in its latest relay list.
+// Drivers without the hints table report no hints.
+func (db *DB) GetRelayHints(ctx context.Context, pubkey string) ([]RelayHint, error) {
+	if !db.usesPool() {
+		return nil, nil
+	}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT url, read, write, created_at FROM relay_hints WHERE pubkey = $1 ORDER BY url`,
+		strings.ToLower(pubkey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relay hints: %w", err)
+	}
+	defer rows.Close()
+
+	var hints []RelayHint
+	for rows.Next() {
+		var hint RelayHint
+		if err := rows.Scan(&hint.URL, &hint.Read, &hint.Write, &hint.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan relay hint: %w", err)
+		}
+		hints = append(hints, hint)
+	}
+	return hints, rows.Err()
+}
+
+// ReadRelaysFor returns the relays a pubkey reads from (its NIP-65 inbox),
+// which is where events addressed to that pubkey should be delivered.
+func (db *DB) ReadRelaysFor(ctx context.Context, pubkey string) []string {
+	return db.relaysFor(ctx, pubkey, func(h RelayHint) bool { return h.Read })
+}
+
+// WriteRelaysFor returns the relays a pubkey publishes to (its NIP-65 outbox),
+// which is where that pubkey's own events should be fetched from.
+func (db *DB) WriteRelaysFor(ctx context.Context, pubkey string) []string {
+	return db.relaysFor(ctx, pubkey, func(h RelayHint) bool { return h.Write })
+}
+
+func (db *DB) relaysFor(ctx context.Context, pubkey string, keep func(RelayHint) bool) []string {
+	hints, err := db.GetRelayHints(ctx, pubkey)
+	if err != nil {
+		logger.Warn("Failed to load relay hints", zap.String("pubkey", pubkey), zap.Error(err))
+		return nil
+	}
+	var urls []string
+	for _, hint := range hints {
+		if keep(hint) {
+			urls = append(urls, hint.URL)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+func TestSupersedes(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt int64
+		id        string
+		storedAt  int64
+		storedID  string
+		want      bool
+	}{
+		{"newer", 2, "bb", 1, "aa", true},
+		{"older", 1, "aa", 2, "bb", false},
+		{"same second, lower id", 1, "aa", 1, "bb", true},
+		{"same second, higher id", 1, "bb", 1, "aa", false},
+		{"same event", 1, "aa", 1, "aa", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := supersedes(tc.createdAt, tc.id, tc.storedAt, tc.storedID); got != tc.want {
+				t.Errorf("supersedes = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestSQLiteReplaceableRace writes two versions of the same replaceable and
+// addressable event at once, many times over, and checks the newer one is
+// always the version left stored whichever writer commits first.
+func TestSQLiteReplaceableRace(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   int
+		tags   nostr.Tags
+		insert func(*SQLiteBackend, context.Context, nostr.Event) error
+		filter nostr.Filter
+	}{
+		{
+			name:   "replaceable",
+			kind:   0,
+			insert: (*SQLiteBackend).InsertReplaceableEvent,
+			filter: nostr.Filter{Kinds: []int{0}, Authors: []string{testPubkey}},
+		},
+		{
+			name:   "addressable",
+			kind:   30023,
+			tags:   nostr.Tags{{"d", "article"}},
+			insert: (*SQLiteBackend).InsertAddressableEvent,
+			filter: nostr.Filter{Kinds: []int{30023}, Authors: []string{testPubkey}, Tags: nostr.TagMap{"d": {"article"}}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestSQLite(t)
+			ctx := context.Background()
+
+			n := 0
+			for round := 0; round < 20; round++ {
+				base := testSince + nostr.Timestamp(2*round)
+				older := testEvent(n+1, tc.kind, base)
+				newer := testEvent(n+2, tc.kind, base+1)
+				older.Tags, newer.Tags = tc.tags, tc.tags
+				n += 2
+
+				var wg sync.WaitGroup
+				errs := make(chan error, 2)
+				for _, evt := range []nostr.Event{newer, older} {
+					wg.Add(1)
+					go func(evt nostr.Event) {
+						defer wg.Done()
+						if err := tc.insert(s, ctx, evt); err != nil && !errors.Is(err, ErrStaleEvent) {
+							errs <- err
+						}
+					}(evt)
+				}
+				wg.Wait()
+				close(errs)
+				for err := range errs {
+					t.Fatalf("round %d: insert: %v", round, err)
+				}
+
+				filter := tc.filter
+				filter.Limit = 10
+				events, err := s.GetEvents(ctx, filter)
+				if err != nil {
+					t.Fatalf("GetEvents: %v", err)
+				}
+				if len(events) != 1 || events[0].ID != newer.ID {
+					var ids []string
+					for _, evt := range events {
+						ids = append(ids, evt.ID)
+					}
+					t.Fatalf("round %d: stored %v, want only %s", round, ids, newer.ID)
+				}
+			}
+		})
+	}
+}
+
+// TestSQLiteReplaceableTieBreak checks that of two versions created in the
+// same second the one with the lowest id is kept, in either arrival order.
+func TestSQLiteReplaceableTieBreak(t *testing.T) {
+	low := testEvent(1, 0, testSince)
+	high := testEvent(2, 0, testSince)
+
+	for _, order := range [][]nostr.Event{{low, high}, {high, low}} {
+		s := newTestSQLite(t)
+		ctx := context.Background()
+		for _, evt := range order {
+			if err := s.InsertReplaceableEvent(ctx, evt); err != nil && !errors.Is(err, ErrStaleEvent) {
+				t.Fatalf("InsertReplaceableEvent: %v", err)
+			}
+		}
+		events, err := s.GetEvents(ctx, nostr.Filter{Kinds: []int{0}, Authors: []string{testPubkey}, Limit: 10})
+		if err != nil {
+			t.Fatalf("GetEvents: %v", err)
+		}
+		if len(events) != 1 || events[0].ID != low.ID {
+			t.Fatalf("inserting %s then %s kept %v, want %s", order[0].ID, order[1].ID, events, low.ID)
+		}
+	}
+}
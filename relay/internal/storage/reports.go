@@ -0,0 +1,404 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// NIP-56 report pipeline.
+//
+// Every stored kind 1984 report is attributed to its targets: the reported
+// event ("e" tags) and the reported pubkeys ("p" tags). Each target counts
+// unique reporters inside a sliding window; crossing the configured
+// thresholds hides an event from query results or flags a pubkey for admin
+// review. The queue is in memory and rebuilt from stored reports on start.
+
+// KindReport is the NIP-56 report kind.
+const KindReport = 1984
+
+// Report target types.
+const (
+	ReportTargetEvent  = "event"
+	ReportTargetPubkey = "pubkey"
+)
+
+// Report target statuses.
+const (
+	ReportStatusOpen      = "open"      // reported, below thresholds or awaiting review
+	ReportStatusHidden    = "hidden"    // event withheld from query results
+	ReportStatusFlagged   = "flagged"   // pubkey awaiting admin review
+	ReportStatusDismissed = "dismissed" // reviewed by an admin, no further automatic action
+)
+
+// ReportTarget is the aggregated state of one reported event or pubkey.
+type ReportTarget struct {
+	Type          string         `json:"type"`
+	ID            string         `json:"id"`               // event ID or pubkey
+	Pubkey        string         `json:"pubkey,omitempty"` // author of a reported event
+	Status        string         `json:"status"`
+	Reporters     int            `json:"reporters"` // unique reporters inside the window
+	Reasons       map[string]int `json:"reasons"`   // report type -> reporters
+	FirstReportAt int64          `json:"first_report_at"`
+	LastReportAt  int64          `json:"last_report_at"`
+	ResolvedBy    string         `json:"resolved_by,omitempty"`
+
+	reports map[string]reportEntry // reporter pubkey -> latest report
+}
+
+type reportEntry struct {
+	at     int64
+	reason string
+}
+
+// ReportFilter selects targets for ListTargets. Zero values match everything.
+type ReportFilter struct {
+	Status string
+	Type   string
+	Limit  int
+}
+
+// ReportTracker aggregates NIP-56 reports and applies moderation thresholds.
+type ReportTracker struct {
+	mu      sync.RWMutex
+	cfg     config.ReportsConfig
+	types   map[string]bool
+	targets map[string]*ReportTarget // keyed by type + ":" + id
+	hidden  map[string]bool          // event IDs withheld from results
+	onAct   func(target ReportTarget, action string)
+}
+
+// NewReportTracker creates a tracker from configuration.
+func NewReportTracker(cfg config.ReportsConfig) *ReportTracker {
+	if cfg.Window <= 0 {
+		cfg.Window = 24 * time.Hour
+	}
+	if cfg.MaxTargets <= 0 {
+		cfg.MaxTargets = 10000
+	}
+	t := &ReportTracker{
+		cfg:     cfg,
+		types:   make(map[string]bool, len(cfg.Types)),
+		targets: make(map[string]*ReportTarget),
+		hidden:  make(map[string]bool),
+	}
+	for _, typ := range cfg.Types {
+		t.types[typ] = true
+	}
+	return t
+}
+
+// SetActionHook sets a callback run after an automatic hide or flag.
+func (t *ReportTracker) SetActionHook(fn func(target ReportTarget, action string)) {
+	t.onAct = fn
+}
+
+// Track feeds a stored event to the tracker; events other than reports are ignored.
+func (t *ReportTracker) Track(evt *nostr.Event) {
+	t.track(evt, true)
+}
+
+// track records a report; notify is false while replaying stored reports so
+// restarts don't repeat metrics and action hooks.
+func (t *ReportTracker) track(evt *nostr.Event, notify bool) {
+	if evt.Kind != KindReport {
+		return
+	}
+	at := int64(evt.CreatedAt)
+	now := time.Now().Unix()
+	if at > now {
+		at = now
+	}
+	if at < now-int64(t.cfg.Window.Seconds()) {
+		return
+	}
+
+	// The reported event's author is the first "p" tag
+	var author, pubkeyReason string
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			author = strings.ToLower(tag[1])
+			if len(tag) >= 3 {
+				pubkeyReason = tag[2]
+			}
+			break
+		}
+	}
+
+	type action struct {
+		target ReportTarget
+		name   string
+	}
+	var actions []action
+
+	t.mu.Lock()
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || len(tag[1]) != 64 {
+			continue
+		}
+		var typ, reason, owner string
+		switch tag[0] {
+		case "e":
+			typ, owner, reason = ReportTargetEvent, author, pubkeyReason
+		case "p":
+			typ = ReportTargetPubkey
+		default:
+			continue
+		}
+		if len(tag) >= 3 && tag[2] != "" {
+			reason = tag[2]
+		}
+		if reason == "" {
+			reason = "other"
+		}
+		id := strings.ToLower(tag[1])
+		// Self-reports never count
+		if (typ == ReportTargetPubkey && id == evt.PubKey) || (typ == ReportTargetEvent && owner == evt.PubKey) {
+			continue
+		}
+		if len(t.types) > 0 && !t.types[reason] {
+			continue
+		}
+		if name := t.add(typ, id, owner, evt.PubKey, reason, at); name != "" {
+			actions = append(actions, action{target: t.snapshot(t.targets[typ+":"+id]), name: name})
+		}
+	}
+	t.evict()
+	t.mu.Unlock()
+
+	if !notify {
+		return
+	}
+	metrics.ReportActions.WithLabelValues("received").Inc()
+	for _, a := range actions {
+		metrics.ReportActions.WithLabelValues(a.name).Inc()
+		logger.Info("Report threshold reached",
+			zap.String("action", a.name),
+			zap.String("type", a.target.Type),
+			zap.String("target", a.target.ID),
+			zap.Int("reporters", a.target.Reporters))
+		if t.onAct != nil {
+			t.onAct(a.target, a.name)
+		}
+	}
+}
+
+// add records one report against a target and returns the automatic action
+// taken, if any. Caller holds the lock.
+func (t *ReportTracker) add(typ, id, owner, reporter, reason string, at int64) string {
+	key := typ + ":" + id
+	target := t.targets[key]
+	if target == nil {
+		target = &ReportTarget{
+			Type:          typ,
+			ID:            id,
+			Pubkey:        owner,
+			Status:        ReportStatusOpen,
+			FirstReportAt: at,
+			reports:       make(map[string]reportEntry),
+		}
+		t.targets[key] = target
+	}
+	if prev, ok := target.reports[reporter]; !ok || at >= prev.at {
+		target.reports[reporter] = reportEntry{at: at, reason: reason}
+	}
+	if at > target.LastReportAt {
+		target.LastReportAt = at
+	}
+	t.recount(target, time.Now().Unix())
+
+	if target.Status != ReportStatusOpen {
+		return ""
+	}
+	switch {
+	case typ == ReportTargetEvent && t.cfg.HideThreshold > 0 && target.Reporters >= t.cfg.HideThreshold:
+		target.Status = ReportStatusHidden
+		t.hidden[id] = true
+		return ReportStatusHidden
+	case typ == ReportTargetPubkey && t.cfg.FlagThreshold > 0 && target.Reporters >= t.cfg.FlagThreshold:
+		target.Status = ReportStatusFlagged
+		return ReportStatusFlagged
+	}
+	return ""
+}
+
+// recount drops reports outside the window and refreshes the reporter counts.
+func (t *ReportTracker) recount(target *ReportTarget, now int64) {
+	cutoff := now - int64(t.cfg.Window.Seconds())
+	target.Reasons = make(map[string]int)
+	for reporter, r := range target.reports {
+		if r.at < cutoff {
+			delete(target.reports, reporter)
+			continue
+		}
+		target.Reasons[r.reason]++
+	}
+	target.Reporters = len(target.reports)
+}
+
+// evict keeps the queue within MaxTargets, dropping the stalest open targets
+// first so hidden events stay hidden. Caller holds the lock.
+func (t *ReportTracker) evict() {
+	excess := len(t.targets) - t.cfg.MaxTargets
+	if excess <= 0 {
+		return
+	}
+	keys := make([]string, 0, len(t.targets))
+	for key := range t.targets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := t.targets[keys[i]], t.targets[keys[j]]
+		if (a.Status == ReportStatusOpen) != (b.Status == ReportStatusOpen) {
+			return a.Status == ReportStatusOpen
+		}
+		return a.LastReportAt < b.LastReportAt
+	})
+	for _, key := range keys[:excess] {
+		delete(t.hidden, t.targets[key].ID)
+		delete(t.targets, key)
+	}
+}
+
+// snapshot copies a target for callers outside the lock.
+func (t *ReportTracker) snapshot(target *ReportTarget) ReportTarget {
+	cp := *target
+	cp.reports = nil
+	cp.Reasons = make(map[string]int, len(target.Reasons))
+	for k, v := range target.Reasons {
+		cp.Reasons[k] = v
+	}
+	return cp
+}
+
+// IsHidden reports whether an event is withheld because of reports.
+func (t *ReportTracker) IsHidden(eventID string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.hidden[eventID]
+}
+
+// ListTargets returns matching targets, most reported first.
+func (t *ReportTracker) ListTargets(filter ReportFilter) []ReportTarget {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now().Unix()
+	out := make([]ReportTarget, 0)
+	for _, target := range t.targets {
+		if filter.Type != "" && target.Type != filter.Type {
+			continue
+		}
+		t.recount(target, now)
+		if filter.Status != "" && target.Status != filter.Status {
+			continue
+		}
+		if target.Status == ReportStatusOpen && target.Reporters == 0 {
+			continue // every report aged out
+		}
+		out = append(out, t.snapshot(target))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Reporters != out[j].Reporters {
+			return out[i].Reporters > out[j].Reporters
+		}
+		return out[i].LastReportAt > out[j].LastReportAt
+	})
+	if filter.Limit > 0 && len(out) > filter.Limit {
+		out = out[:filter.Limit]
+	}
+	return out
+}
+
+// StatusCounts returns the number of targets per status.
+func (t *ReportTracker) StatusCounts() map[string]int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	counts := map[string]int{
+		ReportStatusOpen:      0,
+		ReportStatusHidden:    0,
+		ReportStatusFlagged:   0,
+		ReportStatusDismissed: 0,
+	}
+	for _, target := range t.targets {
+		counts[target.Status]++
+	}
+	return counts
+}
+
+// Resolve applies an admin decision to a target: "hide" withholds a reported
+// event, "dismiss" clears any automatic action and stops further ones.
+func (t *ReportTracker) Resolve(id, action, admin string) (ReportTarget, error) {
+	id = strings.ToLower(id)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	target := t.targets[ReportTargetEvent+":"+id]
+	if target == nil {
+		target = t.targets[ReportTargetPubkey+":"+id]
+	}
+	if target == nil {
+		return ReportTarget{}, fmt.Errorf("no reports for %s", id)
+	}
+
+	switch action {
+	case "hide":
+		if target.Type != ReportTargetEvent {
+			return ReportTarget{}, fmt.Errorf("only reported events can be hidden")
+		}
+		target.Status = ReportStatusHidden
+		t.hidden[id] = true
+		metrics.ReportActions.WithLabelValues("hidden").Inc()
+	case "dismiss":
+		target.Status = ReportStatusDismissed
+		delete(t.hidden, id)
+		metrics.ReportActions.WithLabelValues("dismissed").Inc()
+	default:
+		return ReportTarget{}, fmt.Errorf("unknown action %q: use hide or dismiss", action)
+	}
+	target.ResolvedBy = admin
+	return t.snapshot(target), nil
+}
+
+// Preload replays reports stored within the window so thresholds and hidden
+// events survive a restart.
+func (t *ReportTracker) Preload(ctx context.Context, db *DB) {
+	since := nostr.Timestamp(time.Now().Add(-t.cfg.Window).Unix())
+	events, err := db.GetEvents(ctx, nostr.Filter{Kinds: []int{KindReport}, Since: &since, Limit: 50000})
+	if err != nil {
+		logger.Warn("Failed to preload NIP-56 reports", zap.Error(err))
+		return
+	}
+	for i := len(events) - 1; i >= 0; i-- { // oldest first
+		t.track(&events[i], false)
+	}
+	logger.Info("Preloaded NIP-56 reports", zap.Int("reports", len(events)))
+}
+
+// SetReportTracker enables the NIP-56 report pipeline
+func (db *DB) SetReportTracker(t *ReportTracker) {
+	db.reportTracker = t
+}
+
+// ReportTracker returns the NIP-56 report tracker, or nil when it is disabled
+func (db *DB) ReportTracker() *ReportTracker {
+	return db.reportTracker
+}
+
+// trackReport feeds a stored event to the NIP-56 report tracker
+func (db *DB) trackReport(evt *nostr.Event) {
+	if db.reportTracker != nil {
+		db.reportTracker.Track(evt)
+	}
+}
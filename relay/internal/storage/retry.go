@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// Storage retries: Retry runs an operation with exponential backoff and
+// jitter, retrying only errors that may go away on their own. A circuit
+// breaker shared by every caller stops sending work to a database that keeps
+// failing with connection-level errors, so requests fail fast instead of
+// piling up behind timeouts while it recovers.
+
+// ErrCircuitOpen is returned while the circuit breaker refuses storage work.
+var ErrCircuitOpen = errors.New("storage unavailable: circuit breaker open")
+
+// Defaults for the retry settings left unset in DATABASE.RETRY.
+const (
+	defaultRetryAttempts    = 3
+	defaultRetryBaseDelay   = 50 * time.Millisecond
+	defaultRetryMaxDelay    = 2 * time.Second
+	defaultBreakerThreshold = 20
+	defaultBreakerCooldown  = 10 * time.Second
+)
+
+// errorClass says how a storage error should be handled.
+type errorClass int
+
+const (
+	errPermanent errorClass = iota // Retrying cannot help
+	errTransient                   // Lost a race: serialization failure, deadlock
+	errDegraded                    // The database is unreachable or overloaded
+)
+
+// classifyError sorts err into an errorClass.
+func classifyError(err error) errorClass {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return errPermanent
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "40001", pgErr.Code == "40P01": // serialization_failure, deadlock_detected
+			return errTransient
+		case pgErr.Code == "57014", pgErr.Code == "55P03": // query_canceled (statement timeout), lock_not_available
+			return errTransient
+		case pgErr.Code == "53300", pgErr.Code == "57P01", pgErr.Code == "57P03": // too_many_connections, admin_shutdown, cannot_connect_now
+			return errDegraded
+		case strings.HasPrefix(pgErr.Code, "08"): // connection_exception
+			return errDegraded
+		}
+		return errPermanent
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) || errors.As(err, &netErr) {
+		return errDegraded
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) || pgconn.SafeToRetry(err) {
+		return errDegraded
+	}
+	return errPermanent
+}
+
+// IsRetryable reports whether a storage operation that failed with err may
+// succeed when tried again.
+func IsRetryable(err error) bool {
+	return classifyError(err) != errPermanent
+}
+
+// retryPolicy holds the backoff and circuit breaker settings.
+type retryPolicy struct {
+	attempts         int
+	baseDelay        time.Duration
+	maxDelay         time.Duration
+	breakerThreshold int
+	breakerCooldown  time.Duration
+}
+
+// newRetryPolicy resolves the retry settings from cfg, falling back to defaults.
+func newRetryPolicy(cfg config.RetryConfig) retryPolicy {
+	p := retryPolicy{
+		attempts:         cfg.Attempts,
+		baseDelay:        cfg.BaseDelay,
+		maxDelay:         cfg.MaxDelay,
+		breakerThreshold: cfg.BreakerThreshold,
+		breakerCooldown:  cfg.BreakerCooldown,
+	}
+	if p.attempts <= 0 {
+		p.attempts = defaultRetryAttempts
+	}
+	if p.baseDelay <= 0 {
+		p.baseDelay = defaultRetryBaseDelay
+	}
+	if p.maxDelay <= 0 {
+		p.maxDelay = defaultRetryMaxDelay
+	}
+	if p.breakerThreshold <= 0 {
+		p.breakerThreshold = defaultBreakerThreshold
+	}
+	if p.breakerCooldown <= 0 {
+		p.breakerCooldown = defaultBreakerCooldown
+	}
+	return p
+}
+
+// backoff returns the full-jitter delay before retry number attempt (1-based).
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.baseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > p.maxDelay {
+		ceiling = p.maxDelay
+	}
+	return ceiling/2 + rand.N(ceiling/2+1)
+}
+
+// circuitBreaker trips after threshold consecutive degraded failures and
+// refuses work for cooldown, then lets a single probe through.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether an operation may run.
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.openUntil.IsZero() {
+		return true
+	}
+	if time.Now().Before(cb.openUntil) || cb.probing {
+		return false
+	}
+	cb.probing = true // Half-open: one operation decides
+	return true
+}
+
+// record updates the breaker with the outcome of an operation.
+func (cb *circuitBreaker) record(err error, p retryPolicy) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if classifyError(err) != errDegraded {
+		if !cb.openUntil.IsZero() {
+			logger.Info("Storage circuit breaker closed")
+			metrics.DBCircuitOpen.Set(0)
+		}
+		cb.failures = 0
+		cb.openUntil = time.Time{}
+		cb.probing = false
+		return
+	}
+
+	cb.failures++
+	if cb.probing || cb.failures >= p.breakerThreshold {
+		if cb.openUntil.IsZero() {
+			logger.Warn("Storage circuit breaker opened",
+				zap.Int("consecutive_failures", cb.failures),
+				zap.Duration("cooldown", p.breakerCooldown),
+				zap.Error(err))
+			metrics.DBCircuitOpen.Set(1)
+		}
+		cb.openUntil = time.Now().Add(p.breakerCooldown)
+		cb.probing = false
+	}
+}
+
+// SetRetryPolicy applies the DATABASE.RETRY settings.
+func (db *DB) SetRetryPolicy(cfg config.RetryConfig) {
+	db.retry = newRetryPolicy(cfg)
+}
+
+// Retry runs f until it succeeds, fails with an error retrying cannot fix,
+// or the attempts of the retry policy are used up.
+func (db *DB) Retry(ctx context.Context, f func(context.Context) error) error {
+	p := db.retry
+	if p.attempts == 0 {
+		p = newRetryPolicy(config.RetryConfig{})
+	}
+
+	var err error
+	for attempt := 1; attempt <= p.attempts; attempt++ {
+		if attempt > 1 {
+			metrics.StorageRetries.WithLabelValues("retried").Inc()
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(p.backoff(attempt - 1)):
+			}
+		}
+		if !db.breaker.allow() {
+			metrics.StorageRetries.WithLabelValues("circuit_open").Inc()
+			return ErrCircuitOpen
+		}
+
+		err = f(ctx)
+		db.breaker.record(err, p)
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+	}
+	metrics.StorageRetries.WithLabelValues("exhausted").Inc()
+	return err
+}
@@ -52,11 +52,18 @@ func (db *DB) InitializeSchema(ctx context.Context) error {
 		return fmt.Errorf("database is not connected")
 	}
 
+	// Other drivers create their schema when opened
+	if !db.usesPool() {
+		return nil
+	}
+
 	logger.Info("Initializing database schema...")
 
 	// Fast path: if the events table already exists, skip DDL entirely.
 	// All DDL uses IF NOT EXISTS / CREATE OR REPLACE, so re-running is safe
-	// but slow (~2min on 60K+ rows due to index existence checks).
+	// but slow (~2min on 60K+ rows due to index existence checks). Tables,
+	// columns and indexes added after the main schema are not part of it, so
+	// their ensure functions below run on every startup instead.
 	var tableExists bool
 	if err := db.Pool.QueryRow(ctx,
 		`SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'events')`,
@@ -64,7 +71,43 @@ func (db *DB) InitializeSchema(ctx context.Context) error {
 		logger.Warn("Could not check for existing schema, running full DDL", zap.Error(err))
 	} else if tableExists {
 		logger.Info("✅ Database schema already exists, skipping DDL")
-		return nil
+		if err := db.ensureRelayHintsSchema(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureTenantSchema(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureTombstoneSchema(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureEventTagsSchema(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureModerationSchema(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureNIP05NamesSchema(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureInviteClaimsSchema(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureReceivedAtSchema(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureQueryIndexes(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureThreadSchema(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureLabelSchema(ctx); err != nil {
+			return err
+		}
+		if err := db.ensureFollowGraphSchema(ctx); err != nil {
+			return err
+		}
+		return db.ensurePubkeyStorageSchema(ctx)
 	}
 
 	// Split DDL into individual statements and execute each one.
@@ -78,6 +121,39 @@ func (db *DB) InitializeSchema(ctx context.Context) error {
 			return fmt.Errorf("failed to initialize database schema: %w", err)
 		}
 	}
+	if err := db.ensureRelayHintsSchema(ctx); err != nil {
+		return err
+	}
+	if err := db.ensureTenantSchema(ctx); err != nil {
+		return err
+	}
+	if err := db.ensureTombstoneSchema(ctx); err != nil {
+		return err
+	}
+	if err := db.ensureEventTagsSchema(ctx); err != nil {
+		return err
+	}
+	if err := db.ensurePubkeyStorageSchema(ctx); err != nil {
+		return err
+	}
+	if err := db.ensureModerationSchema(ctx); err != nil {
+		return err
+	}
+	if err := db.ensureNIP05NamesSchema(ctx); err != nil {
+		return err
+	}
+	if err := db.ensureInviteClaimsSchema(ctx); err != nil {
+		return err
+	}
+	if err := db.ensureThreadSchema(ctx); err != nil {
+		return err
+	}
+	if err := db.ensureLabelSchema(ctx); err != nil {
+		return err
+	}
+	if err := db.ensureFollowGraphSchema(ctx); err != nil {
+		return err
+	}
 
 	logger.Info("✅ Database schema initialized successfully")
 	return nil
@@ -122,8 +198,11 @@ func (db *DB) VerifySchema(ctx context.Context) error {
 	if !db.isConnected() {
 		return fmt.Errorf("database is not connected")
 	}
+	if !db.usesPool() {
+		return nil
+	}
 
-	requiredTables := []string{"events"}
+	requiredTables := []string{"events", "relay_hints", "event_tenants", "event_tombstones", "event_tags", "pubkey_storage", "moderation_decisions"}
 
 	for _, table := range requiredTables {
 		var exists bool
@@ -13,6 +13,7 @@ CREATE TABLE IF NOT EXISTS events (
   tags JSONB NULL,
   content TEXT NULL,
   sig CHAR(128) NOT NULL,
+  received_at BIGINT NULL,
 
   -- Primary key
   CONSTRAINT events_pkey PRIMARY KEY (id),
@@ -34,6 +35,9 @@ CREATE INDEX IF NOT EXISTS events_kind_created_at
 CREATE INDEX IF NOT EXISTS events_pubkey_created_at
   ON events (pubkey ASC, created_at ASC);
 
+CREATE INDEX IF NOT EXISTS events_received_at
+  ON events (received_at DESC);
+
 -- GIN indexes for JSONB queries
 CREATE INDEX IF NOT EXISTS events_tags ON events USING GIN (tags);
 
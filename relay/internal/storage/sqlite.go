@@ -0,0 +1,959 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+
+	_ "modernc.org/sqlite" // pure-Go driver, keeps CGO_ENABLED=0 builds working
+)
+
+// sqliteSchema mirrors the PostgreSQL schema. Tags are stored as JSON text
+// and matched with json_each.
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS events (
+  id TEXT NOT NULL PRIMARY KEY,
+  pubkey TEXT NOT NULL,
+  created_at INTEGER NOT NULL,
+  kind INTEGER NOT NULL,
+  tags TEXT NOT NULL DEFAULT '[]',
+  content TEXT NOT NULL DEFAULT '',
+  sig TEXT NOT NULL,
+  received_at INTEGER
+)`,
+	`CREATE INDEX IF NOT EXISTS events_created_at ON events (created_at DESC)`,
+	`CREATE INDEX IF NOT EXISTS events_kind_created_at ON events (kind, created_at)`,
+	`CREATE INDEX IF NOT EXISTS events_pubkey_created_at ON events (pubkey, created_at)`,
+	`CREATE INDEX IF NOT EXISTS events_pubkey_kind ON events (pubkey, kind)`,
+	`DROP INDEX IF EXISTS events_pubkey_kind_created_at`,
+	`CREATE TABLE IF NOT EXISTS event_tenants (
+  event_id TEXT NOT NULL,
+  tenant TEXT NOT NULL,
+  tagged_at INTEGER NOT NULL,
+  PRIMARY KEY (event_id, tenant)
+)`,
+	`CREATE INDEX IF NOT EXISTS event_tenants_tenant ON event_tenants (tenant, event_id)`,
+	`CREATE TABLE IF NOT EXISTS event_tombstones (
+  event_id TEXT NOT NULL PRIMARY KEY,
+  pubkey TEXT NOT NULL,
+  created_at INTEGER,
+  kind INTEGER,
+  tags TEXT,
+  content TEXT,
+  sig TEXT,
+  deleted_at INTEGER NOT NULL,
+  deleted_by TEXT,
+  reason TEXT NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS event_tombstones_pubkey ON event_tombstones (pubkey, deleted_at)`,
+	`CREATE TABLE IF NOT EXISTS pubkey_storage (
+  pubkey TEXT NOT NULL PRIMARY KEY,
+  bytes INTEGER NOT NULL DEFAULT 0,
+  events INTEGER NOT NULL DEFAULT 0
+)`,
+	`CREATE INDEX IF NOT EXISTS pubkey_storage_bytes ON pubkey_storage (bytes DESC)`,
+	`CREATE TRIGGER IF NOT EXISTS events_pubkey_storage_insert AFTER INSERT ON events BEGIN
+  INSERT INTO pubkey_storage (pubkey, bytes, events) VALUES (NEW.pubkey, ` + sqliteEventBytes("NEW") + `, 1)
+  ON CONFLICT (pubkey) DO UPDATE SET bytes = bytes + excluded.bytes, events = events + 1;
+END`,
+	`CREATE TRIGGER IF NOT EXISTS events_pubkey_storage_delete AFTER DELETE ON events BEGIN
+  UPDATE pubkey_storage SET bytes = bytes - ` + sqliteEventBytes("OLD") + `, events = events - 1
+  WHERE pubkey = OLD.pubkey;
+END`,
+	`CREATE TABLE IF NOT EXISTS moderation_decisions (
+  type TEXT NOT NULL,
+  target TEXT NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  actor TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL,
+  expires_at INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (type, target)
+)`,
+	`CREATE TABLE IF NOT EXISTS nip05_names (
+  name TEXT NOT NULL PRIMARY KEY,
+  pubkey TEXT NOT NULL,
+  relays TEXT NOT NULL DEFAULT '[]',
+  actor TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS invite_claims (
+  code TEXT NOT NULL PRIMARY KEY,
+  created_by TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL,
+  expires_at INTEGER NOT NULL,
+  used_by TEXT NOT NULL DEFAULT '',
+  used_at INTEGER NOT NULL DEFAULT 0
+)`,
+}
+
+// sqliteEventBytes is the accounted size of the event row alias, matching
+// nostr_event_bytes in the PostgreSQL schema.
+func sqliteEventBytes(row string) string {
+	return fmt.Sprintf("(%d + length(CAST(%s.content AS BLOB)) + length(CAST(%s.tags AS BLOB)))",
+		eventOverheadBytes, row, row)
+}
+
+// sqliteEventColumns is the column list scanned by scanSQLiteEvent.
+const sqliteEventColumns = `id, pubkey, kind, created_at, content, tags, sig`
+
+// SQLiteBackend stores events in an embedded SQLite database file.
+type SQLiteBackend struct {
+	db   *sql.DB
+	path string
+}
+
+var _ StorageBackend = (*SQLiteBackend)(nil)
+
+// NewSQLiteBackend opens (creating if needed) the SQLite database at path.
+func NewSQLiteBackend(ctx context.Context, path string) (*SQLiteBackend, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create SQLite directory: %w", err)
+		}
+	}
+
+	// WAL lets readers proceed while a write is in progress; the busy timeout
+	// serializes concurrent writers from the event processor workers
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)&_txlock=immediate", path)
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("failed to connect to SQLite database: %w", err)
+	}
+
+	// Databases created before storage accounting need their events counted once
+	var accounted bool
+	if err := sqlDB.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'pubkey_storage')`,
+	).Scan(&accounted); err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("failed to check SQLite schema: %w", err)
+	}
+
+	for _, stmt := range sqliteSchema {
+		if _, err := sqlDB.ExecContext(ctx, stmt); err != nil {
+			_ = sqlDB.Close()
+			return nil, fmt.Errorf("failed to initialize SQLite schema: %w", err)
+		}
+	}
+
+	if err := migrateSQLiteReceivedAt(ctx, sqlDB); err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("failed to add events.received_at: %w", err)
+	}
+
+	if !accounted {
+		if _, err := sqlDB.ExecContext(ctx,
+			`INSERT INTO pubkey_storage (pubkey, bytes, events)
+			 SELECT pubkey, SUM(`+sqliteEventBytes("events")+`), COUNT(*) FROM events GROUP BY pubkey
+			 ON CONFLICT (pubkey) DO UPDATE SET bytes = excluded.bytes, events = excluded.events`); err != nil {
+			_ = sqlDB.Close()
+			return nil, fmt.Errorf("failed to count stored events per pubkey: %w", err)
+		}
+	}
+
+	return &SQLiteBackend{db: sqlDB, path: path}, nil
+}
+
+// InitSQLite opens an SQLite-backed DB for single-node deployments.
+func InitSQLite(ctx context.Context, path string) (*DB, error) {
+	backend, err := NewSQLiteBackend(ctx, path)
+	if err != nil {
+		metrics.DBConnections.WithLabelValues("failure").Inc()
+		return nil, err
+	}
+
+	db := &DB{
+		backend: backend,
+		Bloom:   NewShardedBloom(10_000_000, 0.01, DefaultBloomShards), // 10M entries with 1% false positive rate
+		state:   DBStateConnected,
+		errors:  make(chan error, 100),
+	}
+
+	logger.Info("✅ SQLite database opened", zap.String("path", path))
+	metrics.DBConnections.WithLabelValues("success").Inc()
+	return db, nil
+}
+
+// encodeTags serializes tags for the JSON text column.
+func encodeTags(tags nostr.Tags) (string, error) {
+	if tags == nil {
+		return "[]", nil
+	}
+	raw, err := json.Marshal(tags)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode tags: %w", err)
+	}
+	return string(raw), nil
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSQLiteEvent reads one row selected with sqliteEventColumns.
+func scanSQLiteEvent(row rowScanner) (nostr.Event, error) {
+	var evt nostr.Event
+	var createdAt int64
+	var rawTags string
+	if err := row.Scan(&evt.ID, &evt.PubKey, &evt.Kind, &createdAt, &evt.Content, &rawTags, &evt.Sig); err != nil {
+		return nostr.Event{}, err
+	}
+	evt.CreatedAt = nostr.Timestamp(createdAt)
+	if err := json.Unmarshal([]byte(rawTags), &evt.Tags); err != nil {
+		logger.Warn("Failed to unmarshal tags", zap.Error(err))
+		evt.Tags = nostr.Tags{}
+	}
+	return evt, nil
+}
+
+// insertSQLiteEvent inserts an event, ignoring duplicates.
+func insertSQLiteEvent(ctx context.Context, exec interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}, evt nostr.Event) error {
+	tags, err := encodeTags(evt.Tags)
+	if err != nil {
+		return err
+	}
+	_, err = exec.ExecContext(ctx,
+		`INSERT INTO events (id, pubkey, created_at, kind, tags, content, sig, received_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (id) DO NOTHING`,
+		evt.ID, evt.PubKey, int64(evt.CreatedAt), evt.Kind, tags, evt.Content, evt.Sig, receivedAtFromContext(ctx))
+	return err
+}
+
+// sqliteTagMatch matches events carrying a tag with the given name whose value is one of n placeholders.
+func sqliteTagMatch(n int) string {
+	return `EXISTS (SELECT 1 FROM json_each(events.tags) AS t
+		WHERE json_extract(t.value, '$[0]') = ? AND json_extract(t.value, '$[1]') IN (` +
+		strings.TrimSuffix(strings.Repeat("?,", n), ",") + `))`
+}
+
+// buildSQLiteWhere translates a Nostr filter into a WHERE clause.
+// Tag filters follow NIP-01: an event matches if it has any of the listed values.
+func buildSQLiteWhere(ctx context.Context, filter nostr.Filter) (string, []any) {
+	var conds []string
+	var args []any
+
+	in := func(column string, n int) string {
+		return column + " IN (" + strings.TrimSuffix(strings.Repeat("?,", n), ",") + ")"
+	}
+	// hex matches full values exactly and shorter ones as prefixes (NIP-01)
+	hex := func(column string, values []string) {
+		var alts []string
+		for _, v := range values {
+			if len(v) >= 64 {
+				alts = append(alts, column+" = ?")
+				args = append(args, v)
+				continue
+			}
+			lo, hi := hexRange(v)
+			alts = append(alts, "("+column+" >= ? AND "+column+" < ?)")
+			args = append(args, lo, hi)
+		}
+		conds = append(conds, "("+strings.Join(alts, " OR ")+")")
+	}
+
+	if len(filter.IDs) > 0 {
+		hex("id", filter.IDs)
+	}
+	if len(filter.Authors) > 0 {
+		hex("pubkey", filter.Authors)
+	}
+	if len(filter.Kinds) > 0 {
+		conds = append(conds, in("kind", len(filter.Kinds)))
+		for _, kind := range filter.Kinds {
+			args = append(args, kind)
+		}
+	}
+	if filter.Since != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, int64(*filter.Since))
+	}
+	if filter.Until != nil {
+		conds = append(conds, "created_at <= ?")
+		args = append(args, int64(*filter.Until))
+	}
+	if filter.Search != "" {
+		conds = append(conds, "content LIKE ?")
+		args = append(args, "%"+filter.Search+"%")
+	}
+
+	// Sort tag names so identical filters produce identical statements
+	tagNames := make([]string, 0, len(filter.Tags))
+	for name, values := range filter.Tags {
+		if len(values) > 0 {
+			tagNames = append(tagNames, name)
+		}
+	}
+	sort.Strings(tagNames)
+	for _, name := range tagNames {
+		values := filter.Tags[name]
+		conds = append(conds, sqliteTagMatch(len(values)))
+		args = append(args, name)
+		for _, value := range values {
+			args = append(args, value)
+		}
+	}
+
+	// Bound the first-seen time for propagation debugging
+	if received, ok := ReceivedRangeFromContext(ctx); ok {
+		receivedConds, receivedArgs := receivedRangeConds(received, func() string { return "?" })
+		conds = append(conds, receivedConds...)
+		args = append(args, receivedArgs...)
+	}
+
+	// Resume after the previous page of a paged query
+	if page, ok := PageCursorFromContext(ctx); ok {
+		if cond, condArgs := pageCond(page, ascendingOrder(filter), func() string { return "?" }); cond != "" {
+			conds = append(conds, cond)
+			args = append(args, condArgs...)
+		}
+	}
+
+	// Scope to one tenant in multi-tenant mode
+	if tenant, ok := TenantFromContext(ctx); ok {
+		if tenant == "" {
+			conds = append(conds, "NOT EXISTS (SELECT 1 FROM event_tenants t WHERE t.event_id = events.id)")
+		} else {
+			conds = append(conds, "EXISTS (SELECT 1 FROM event_tenants t WHERE t.event_id = events.id AND t.tenant = ?)")
+			args = append(args, tenant)
+		}
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// InsertEvent inserts a regular event.
+func (s *SQLiteBackend) InsertEvent(ctx context.Context, evt nostr.Event) error {
+	if err := insertSQLiteEvent(ctx, s.db, evt); err != nil {
+		return fmt.Errorf("failed to insert event: %w", err)
+	}
+	return nil
+}
+
+// BatchInsertEvents inserts regular events in one transaction.
+func (s *SQLiteBackend) BatchInsertEvents(ctx context.Context, events []nostr.Event) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		for _, evt := range events {
+			if err := insertSQLiteEvent(ctx, tx, evt); err != nil {
+				return fmt.Errorf("failed to insert event: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// InsertReplaceableEvent replaces the stored event for (pubkey, kind) unless
+// it supersedes evt (ErrStaleEvent).
+func (s *SQLiteBackend) InsertReplaceableEvent(ctx context.Context, evt nostr.Event) error {
+	return s.upsertReplaceable(ctx, evt, `pubkey = ? AND kind = ?`, []any{evt.PubKey, evt.Kind})
+}
+
+// InsertAddressableEvent replaces the stored event for (pubkey, kind, d)
+// unless it supersedes evt (ErrStaleEvent).
+func (s *SQLiteBackend) InsertAddressableEvent(ctx context.Context, evt nostr.Event) error {
+	dVal := nips.GetTagValue(evt, "d")
+	if dVal == "" {
+		return s.InsertEvent(ctx, evt) // fallback
+	}
+	return s.upsertReplaceable(ctx, evt, `pubkey = ? AND kind = ? AND `+sqliteTagMatch(1),
+		[]any{evt.PubKey, evt.Kind, "d", dVal})
+}
+
+// upsertReplaceable replaces the versions matching where with evt. Write
+// transactions take the database lock when they begin (_txlock=immediate),
+// so the check and the replacement cannot interleave with another writer.
+func (s *SQLiteBackend) upsertReplaceable(ctx context.Context, evt nostr.Event, where string, args []any) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT id, created_at FROM events WHERE `+where, args...)
+		if err != nil {
+			return err
+		}
+		stored := 0
+		for rows.Next() {
+			var id string
+			var createdAt int64
+			if err := rows.Scan(&id, &createdAt); err != nil {
+				rows.Close()
+				return err
+			}
+			if !supersedes(int64(evt.CreatedAt), evt.ID, createdAt, id) {
+				rows.Close()
+				return ErrStaleEvent
+			}
+			stored++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if stored > 0 {
+			if _, err := sqliteTombstone(ctx, tx, where, args, evt.ID, TombstoneReplaced); err != nil {
+				return fmt.Errorf("failed to delete old replaceable event: %w", err)
+			}
+		}
+		if err := insertSQLiteEvent(ctx, tx, evt); err != nil {
+			return fmt.Errorf("failed to insert new replaceable event: %w", err)
+		}
+		return nil
+	})
+}
+
+// PersistDeletion applies a NIP-09 deletion request and stores it.
+func (s *SQLiteBackend) PersistDeletion(ctx context.Context, del nostr.Event) error {
+	eIDs, aTags := deletionTargets(del)
+	if len(eIDs) == 0 && len(aTags) == 0 {
+		return errors.New("deletion event without e or a tags")
+	}
+
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		// 1) tombstone events by "e" tag — only if owned by deleter; IDs not
+		//    stored here get a bare tombstone
+		for _, id := range eIDs {
+			if _, err := sqliteTombstone(ctx, tx, `id = ? AND pubkey = ?`,
+				[]any{id, del.PubKey}, del.ID, TombstoneDeleted); err != nil {
+				return err
+			}
+		}
+		for _, id := range validTombstoneIDs(eIDs) {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO event_tombstones (event_id, pubkey, deleted_at, deleted_by, reason)
+				 SELECT ?, ?, ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM events WHERE id = ?)
+				 ON CONFLICT (event_id) DO NOTHING`,
+				id, del.PubKey, time.Now().Unix(), del.ID, TombstoneDeleted, id); err != nil {
+				return err
+			}
+		}
+
+		// 2) tombstone addressable events by "a" tag up to the request's created_at
+		for _, tag := range aTags {
+			parts := strings.SplitN(tag[1], ":", 3)
+			if len(parts) != 3 || parts[1] != del.PubKey {
+				continue
+			}
+			if _, err := sqliteTombstone(ctx, tx,
+				`kind = ? AND pubkey = ? AND created_at <= ? AND `+sqliteTagMatch(1),
+				[]any{parts[0], del.PubKey, int64(del.CreatedAt), "d", parts[2]},
+				del.ID, TombstoneDeleted); err != nil {
+				logger.Warn("NIP-09: Failed to delete addressable event",
+					zap.String("a_tag", tag[1]),
+					zap.Error(err))
+			}
+		}
+
+		// 3) insert the deletion event itself
+		return insertSQLiteEvent(ctx, tx, del)
+	})
+}
+
+// PersistVanish applies a NIP-62 request to vanish and stores it.
+func (s *SQLiteBackend) PersistVanish(ctx context.Context, evt nostr.Event) error {
+	var deleted int64
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		deleted, err = sqliteTombstone(ctx, tx, `pubkey = ? AND created_at <= ?`,
+			[]any{evt.PubKey, int64(evt.CreatedAt)}, evt.ID, TombstoneVanished)
+		if err != nil {
+			return fmt.Errorf("failed to delete events for vanish: %w", err)
+		}
+
+		if _, err := sqliteTombstone(ctx, tx, `kind = 1059 AND `+sqliteTagMatch(1),
+			[]any{"p", evt.PubKey}, evt.ID, TombstoneVanished); err != nil {
+			logger.Warn("NIP-62: Failed to delete gift-wrapped events",
+				zap.String("pubkey", evt.PubKey),
+				zap.Error(err))
+		}
+
+		if err := insertSQLiteEvent(ctx, tx, evt); err != nil {
+			return fmt.Errorf("failed to store vanish request: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("NIP-62: Vanish request processed",
+		zap.String("pubkey", evt.PubKey),
+		zap.Int64("events_deleted", deleted))
+	return nil
+}
+
+// DeleteEventByID tombstones a single event regardless of its author.
+func (s *SQLiteBackend) DeleteEventByID(ctx context.Context, eventID string) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := sqliteTombstone(ctx, tx, `id = ?`, []any{eventID}, "", TombstoneRemoved)
+		return err
+	})
+}
+
+// sqliteTombstone moves the events matching where into event_tombstones and
+// returns how many were moved. A bare tombstone for the same ID is replaced.
+func sqliteTombstone(ctx context.Context, tx *sql.Tx, where string, args []any, deletedBy, reason string) (int64, error) {
+	var by any
+	if deletedBy != "" {
+		by = deletedBy
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO event_tombstones (event_id, pubkey, created_at, kind, tags, content, sig, deleted_at, deleted_by, reason)
+		 SELECT id, pubkey, created_at, kind, tags, content, sig, ?, ?, ? FROM events WHERE `+where+`
+		 ON CONFLICT (event_id) DO UPDATE SET
+		   pubkey = excluded.pubkey, created_at = excluded.created_at, kind = excluded.kind,
+		   tags = excluded.tags, content = excluded.content, sig = excluded.sig,
+		   deleted_at = excluded.deleted_at, deleted_by = excluded.deleted_by, reason = excluded.reason`,
+		append([]any{time.Now().Unix(), by, reason}, args...)...); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM events WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CleanExpiredEvents removes events whose NIP-40 expiration has passed.
+func (s *SQLiteBackend) CleanExpiredEvents(ctx context.Context) (int, error) {
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM events WHERE EXISTS (
+			SELECT 1 FROM json_each(events.tags) AS t
+			WHERE json_extract(t.value, '$[0]') = 'expiration'
+			AND CAST(json_extract(t.value, '$[1]') AS INTEGER) <= ?
+		)`, time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired events: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// PruneOldEvents removes regular events created before the given Unix time.
+func (s *SQLiteBackend) PruneOldEvents(ctx context.Context, before int64) (int, error) {
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM events WHERE created_at < ? AND NOT `+retentionKeepKinds, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune old events: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// GetPubkeyStorage returns the storage used by pubkey.
+func (s *SQLiteBackend) GetPubkeyStorage(ctx context.Context, pubkey string) (PubkeyStorage, error) {
+	usage := PubkeyStorage{Pubkey: pubkey}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT bytes, events FROM pubkey_storage WHERE pubkey = ?`, pubkey,
+	).Scan(&usage.Bytes, &usage.Events)
+	if errors.Is(err, sql.ErrNoRows) {
+		return usage, nil
+	}
+	return usage, err
+}
+
+// TopStorageConsumers returns the limit pubkeys storing the most bytes.
+func (s *SQLiteBackend) TopStorageConsumers(ctx context.Context, limit int) ([]PubkeyStorage, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT pubkey, bytes, events FROM pubkey_storage WHERE events > 0 ORDER BY bytes DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query storage consumers: %w", err)
+	}
+	defer rows.Close()
+
+	consumers := make([]PubkeyStorage, 0, limit)
+	for rows.Next() {
+		var usage PubkeyStorage
+		if err := rows.Scan(&usage.Pubkey, &usage.Bytes, &usage.Events); err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, usage)
+	}
+	return consumers, rows.Err()
+}
+
+// SaveModerationDecision records a decision, replacing an earlier one for the same target.
+func (s *SQLiteBackend) SaveModerationDecision(ctx context.Context, d ModerationDecision) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO moderation_decisions (type, target, reason, actor, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (type, target) DO UPDATE SET
+		   reason = excluded.reason, actor = excluded.actor,
+		   created_at = excluded.created_at, expires_at = excluded.expires_at`,
+		d.Type, d.Target, d.Reason, d.Actor, d.CreatedAt, d.ExpiresAt)
+	return err
+}
+
+// DeleteModerationDecision forgets the decision for a target.
+func (s *SQLiteBackend) DeleteModerationDecision(ctx context.Context, decisionType, target string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM moderation_decisions WHERE type = ? AND target = ?`, decisionType, target)
+	return err
+}
+
+// ListModerationDecisions returns every recorded decision, expired ones included.
+func (s *SQLiteBackend) ListModerationDecisions(ctx context.Context) ([]ModerationDecision, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT type, target, reason, actor, created_at, expires_at FROM moderation_decisions ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query moderation decisions: %w", err)
+	}
+	defer rows.Close()
+
+	var decisions []ModerationDecision
+	for rows.Next() {
+		var d ModerationDecision
+		if err := rows.Scan(&d.Type, &d.Target, &d.Reason, &d.Actor, &d.CreatedAt, &d.ExpiresAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, rows.Err()
+}
+
+// SaveNIP05Name records a hosted name, replacing an earlier mapping of it.
+func (s *SQLiteBackend) SaveNIP05Name(ctx context.Context, n NIP05Name) error {
+	relays := n.Relays
+	if relays == nil {
+		relays = []string{}
+	}
+	relaysJSON, err := json.Marshal(relays)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO nip05_names (name, pubkey, relays, actor, created_at)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (name) DO UPDATE SET
+		   pubkey = excluded.pubkey, relays = excluded.relays,
+		   actor = excluded.actor, created_at = excluded.created_at`,
+		n.Name, n.Pubkey, string(relaysJSON), n.Actor, n.CreatedAt)
+	return err
+}
+
+// DeleteNIP05Name forgets a hosted name.
+func (s *SQLiteBackend) DeleteNIP05Name(ctx context.Context, name string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM nip05_names WHERE name = ?`, name)
+	return err
+}
+
+// ListNIP05Names returns every hosted name, by name.
+func (s *SQLiteBackend) ListNIP05Names(ctx context.Context) ([]NIP05Name, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT name, pubkey, relays, actor, created_at FROM nip05_names ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query NIP-05 names: %w", err)
+	}
+	defer rows.Close()
+
+	var names []NIP05Name
+	for rows.Next() {
+		var n NIP05Name
+		var relays string
+		if err := rows.Scan(&n.Name, &n.Pubkey, &relays, &n.Actor, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(relays), &n.Relays); err != nil {
+			return nil, fmt.Errorf("invalid relays of NIP-05 name %s: %w", n.Name, err)
+		}
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
+
+// SaveInviteClaim inserts an invite code; a code already stored is left as is.
+func (s *SQLiteBackend) SaveInviteClaim(ctx context.Context, c InviteClaim) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO invite_claims (code, created_by, created_at, expires_at)
+		 VALUES (?, ?, ?, ?)
+		 ON CONFLICT (code) DO NOTHING`,
+		c.Code, c.CreatedBy, c.CreatedAt, c.ExpiresAt)
+	return err
+}
+
+// ConsumeInviteClaim redeems an invite code in a single conditional update.
+func (s *SQLiteBackend) ConsumeInviteClaim(ctx context.Context, code, pubkey string, usedAt int64) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE invite_claims SET used_by = ?, used_at = ?
+		 WHERE code = ? AND used_by = '' AND expires_at > ?`,
+		pubkey, usedAt, code, usedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ListInviteClaims returns the invite codes expiring after expiresAfter, oldest first.
+func (s *SQLiteBackend) ListInviteClaims(ctx context.Context, expiresAfter int64) ([]InviteClaim, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT code, created_by, created_at, expires_at, used_by, used_at
+		 FROM invite_claims WHERE expires_at > ? ORDER BY created_at`, expiresAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invite claims: %w", err)
+	}
+	defer rows.Close()
+
+	var claims []InviteClaim
+	for rows.Next() {
+		var c InviteClaim
+		if err := rows.Scan(&c.Code, &c.CreatedBy, &c.CreatedAt, &c.ExpiresAt, &c.UsedBy, &c.UsedAt); err != nil {
+			return nil, err
+		}
+		claims = append(claims, c)
+	}
+	return claims, rows.Err()
+}
+
+// PruneInviteClaims deletes invite codes expired before the given Unix time.
+func (s *SQLiteBackend) PruneInviteClaims(ctx context.Context, before int64) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM invite_claims WHERE expires_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// sqliteEventsQuery returns the statement GetEvents runs for filter: newest
+// first, or oldest first for since-only filters, with the limit applied in SQL.
+func sqliteEventsQuery(ctx context.Context, filter nostr.Filter) (string, []any) {
+	where, args := buildSQLiteWhere(ctx, filter)
+
+	_, paged := PageCursorFromContext(ctx)
+	order := pageOrder(ascendingOrder(filter), paged)
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 500
+	}
+	args = append(args, limit)
+	return `SELECT ` + sqliteEventColumns + ` FROM events` + where + order + ` LIMIT ?`, args
+}
+
+// GetEvents returns events matching a filter, newest first (oldest first for since-only filters).
+func (s *SQLiteBackend) GetEvents(ctx context.Context, filter nostr.Filter) ([]nostr.Event, error) {
+	query, args := sqliteEventsQuery(ctx, filter)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []nostr.Event
+	for rows.Next() {
+		evt, err := scanSQLiteEvent(rows)
+		if err != nil {
+			logger.Warn("Row scan failed", zap.Error(err))
+			continue
+		}
+		events = append(events, evt)
+	}
+	return events, rows.Err()
+}
+
+// GetEventByID returns a single event.
+func (s *SQLiteBackend) GetEventByID(ctx context.Context, eventID string) (nostr.Event, error) {
+	evt, err := scanSQLiteEvent(s.db.QueryRowContext(ctx,
+		`SELECT `+sqliteEventColumns+` FROM events WHERE id = ?`, eventID))
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("event not found: %w", err)
+	}
+	return evt, nil
+}
+
+// GetReplaceableEvent returns the latest event for (pubkey, kind).
+func (s *SQLiteBackend) GetReplaceableEvent(ctx context.Context, pubkey string, kind int) (nostr.Event, error) {
+	evt, err := scanSQLiteEvent(s.db.QueryRowContext(ctx,
+		`SELECT `+sqliteEventColumns+` FROM events WHERE pubkey = ? AND kind = ?
+		 ORDER BY created_at DESC LIMIT 1`, pubkey, kind))
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("replaceable event not found: %w", err)
+	}
+	return evt, nil
+}
+
+// GetAddressableEvent returns the latest event for (pubkey, kind, d).
+func (s *SQLiteBackend) GetAddressableEvent(ctx context.Context, pubkey string, kind int, dVal string) (nostr.Event, error) {
+	evt, err := scanSQLiteEvent(s.db.QueryRowContext(ctx,
+		`SELECT `+sqliteEventColumns+` FROM events WHERE pubkey = ? AND kind = ? AND `+sqliteTagMatch(1)+`
+		 ORDER BY created_at DESC LIMIT 1`, pubkey, kind, "d", dVal))
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("addressable event not found: %w", err)
+	}
+	return evt, nil
+}
+
+// GetEventCount counts events matching a filter.
+func (s *SQLiteBackend) GetEventCount(ctx context.Context, filter nostr.Filter) (int64, error) {
+	where, args := buildSQLiteWhere(ctx, filter)
+	var count int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM events`+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to execute count query: %w", err)
+	}
+	return count, nil
+}
+
+// GetEventPubkeys returns the authors of events matching a filter.
+func (s *SQLiteBackend) GetEventPubkeys(ctx context.Context, filter nostr.Filter) ([]string, error) {
+	where, args := buildSQLiteWhere(ctx, filter)
+	rows, err := s.db.QueryContext(ctx, `SELECT pubkey FROM events`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event pubkeys: %w", err)
+	}
+	defer rows.Close()
+
+	var pubkeys []string
+	for rows.Next() {
+		var pk string
+		if err := rows.Scan(&pk); err != nil {
+			return nil, fmt.Errorf("failed to scan pubkey: %w", err)
+		}
+		pubkeys = append(pubkeys, pk)
+	}
+	return pubkeys, rows.Err()
+}
+
+// EventExists reports whether an event is stored.
+func (s *SQLiteBackend) EventExists(ctx context.Context, eventID string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM events WHERE id = ?)`, eventID).Scan(&exists)
+	return exists, err
+}
+
+// IsEventDeleted reports whether an event by pubkey has a tombstone.
+func (s *SQLiteBackend) IsEventDeleted(ctx context.Context, eventID, pubkey string) (bool, error) {
+	var deleted bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM event_tombstones WHERE event_id = ? AND pubkey = ?)`,
+		eventID, pubkey).Scan(&deleted)
+	return deleted, err
+}
+
+// IsVanishedPubkey reports whether a pubkey has issued a NIP-62 request.
+func (s *SQLiteBackend) IsVanishedPubkey(ctx context.Context, pubkey string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM events WHERE kind = 62 AND pubkey = ?)`, pubkey).Scan(&exists)
+	return exists, err
+}
+
+// GetTotalEventCount returns the number of stored events.
+func (s *SQLiteBackend) GetTotalEventCount(ctx context.Context) (int64, error) {
+	var count int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM events`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to get total event count: %w", err)
+	}
+	return count, nil
+}
+
+// ForEachEventID calls fn for every stored event ID.
+func (s *SQLiteBackend) ForEachEventID(ctx context.Context, fn func(id string)) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM events`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		fn(id)
+	}
+	return rows.Err()
+}
+
+// TagEventTenant records that a tenant owns an event.
+func (s *SQLiteBackend) TagEventTenant(ctx context.Context, eventID, tenant string) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO event_tenants (event_id, tenant, tagged_at) VALUES (?, ?, ?)`,
+		eventID, tenant, time.Now().Unix())
+	return err
+}
+
+// GetEventTenants returns the tenants owning an event (empty = main relay).
+func (s *SQLiteBackend) GetEventTenants(ctx context.Context, eventID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT tenant FROM event_tenants WHERE event_id = ?`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tenants := []string{}
+	for rows.Next() {
+		var tenant string
+		if err := rows.Scan(&tenant); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// PruneEventTenants removes tenant rows older than taggedBefore whose event is not stored.
+func (s *SQLiteBackend) PruneEventTenants(ctx context.Context, taggedBefore int64) (int, error) {
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM event_tenants WHERE tagged_at < ?
+		 AND NOT EXISTS (SELECT 1 FROM events e WHERE e.id = event_tenants.event_id)`, taggedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune event tenants: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// Version returns the SQLite library version.
+func (s *SQLiteBackend) Version(ctx context.Context) (string, error) {
+	var version string
+	if err := s.db.QueryRowContext(ctx, `SELECT sqlite_version()`).Scan(&version); err != nil {
+		return "", err
+	}
+	return "SQLite " + version, nil
+}
+
+// Ping checks the database file is reachable.
+func (s *SQLiteBackend) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database.
+func (s *SQLiteBackend) Close() error {
+	return s.db.Close()
+}
+
+// withTx runs fn in a transaction, committing if it succeeds.
+func (s *SQLiteBackend) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// newTestSQLite opens a SQLite backend in a temporary directory.
+func newTestSQLite(t *testing.T) *SQLiteBackend {
+	t.Helper()
+	s, err := NewSQLiteBackend(context.Background(), filepath.Join(t.TempDir(), "relay.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteBackend: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// TestSQLiteEventsQueryPlan checks with EXPLAIN QUERY PLAN that SQLite reads
+// each filter shape from an index ending in created_at instead of sorting it.
+func TestSQLiteEventsQueryPlan(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+
+	for _, tc := range queryPlanCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, args := sqliteEventsQuery(ctx, tc.filter)
+			if !strings.Contains(query, tc.order+" LIMIT ?") {
+				t.Errorf("query %q does not end with %q", query, tc.order)
+			}
+
+			rows, err := s.db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+			if err != nil {
+				t.Fatalf("EXPLAIN QUERY PLAN: %v", err)
+			}
+			defer rows.Close()
+			var plan []string
+			for rows.Next() {
+				var id, parent, notUsed int
+				var detail string
+				if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+					t.Fatalf("scan plan: %v", err)
+				}
+				plan = append(plan, detail)
+			}
+			text := strings.Join(plan, "\n")
+			// Without statistics SQLite may pick another index ending in
+			// created_at, which serves the order just as well
+			if !strings.Contains(text, "_created_at") {
+				t.Errorf("plan does not use a created_at index:\n%s", text)
+			}
+			if strings.Contains(text, "TEMP B-TREE FOR ORDER BY") {
+				t.Errorf("plan sorts instead of reading in index order:\n%s", text)
+			}
+		})
+	}
+}
+
+// TestSQLiteGetEventsOrder checks the order GetEvents applies the limit in:
+// oldest first for since-only filters, newest first otherwise.
+func TestSQLiteGetEventsOrder(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if err := s.InsertEvent(ctx, testEvent(i+1, 1, testSince+nostr.Timestamp(i))); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+	}
+	until := testSince + 3
+
+	tests := []struct {
+		name   string
+		filter nostr.Filter
+		want   []nostr.Timestamp
+	}{
+		{"no bounds", nostr.Filter{Kinds: []int{1}, Limit: 2}, []nostr.Timestamp{testSince + 4, testSince + 3}},
+		{"since only", nostr.Filter{Kinds: []int{1}, Since: &testSince, Limit: 2}, []nostr.Timestamp{testSince, testSince + 1}},
+		{"until", nostr.Filter{Kinds: []int{1}, Until: &until, Limit: 2}, []nostr.Timestamp{testSince + 3, testSince + 2}},
+		{"since and until", nostr.Filter{Kinds: []int{1}, Since: &testSince, Until: &until, Limit: 2}, []nostr.Timestamp{testSince + 3, testSince + 2}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			events, err := s.GetEvents(ctx, tc.filter)
+			if err != nil {
+				t.Fatalf("GetEvents: %v", err)
+			}
+			var got []nostr.Timestamp
+			for _, evt := range events {
+				got = append(got, evt.CreatedAt)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("got %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+// TestSQLiteSinceUntilInclusive checks the NIP-01 bounds: since <= created_at
+// <= until, both inclusive.
+func TestSQLiteSinceUntilInclusive(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if err := s.InsertEvent(ctx, testEvent(i+1, 1, testSince+nostr.Timestamp(i))); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+	}
+	since, until := testSince+1, testSince+3
+
+	tests := []struct {
+		name   string
+		filter nostr.Filter
+		want   int
+	}{
+		{"since", nostr.Filter{Kinds: []int{1}, Since: &since}, 4},
+		{"until", nostr.Filter{Kinds: []int{1}, Until: &until}, 4},
+		{"since and until", nostr.Filter{Kinds: []int{1}, Since: &since, Until: &until}, 3},
+		{"since equals until", nostr.Filter{Kinds: []int{1}, Since: &since, Until: &since}, 1},
+		{"since after until", nostr.Filter{Kinds: []int{1}, Since: &until, Until: &since}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.filter.Limit = 10
+			events, err := s.GetEvents(ctx, tc.filter)
+			if err != nil {
+				t.Fatalf("GetEvents: %v", err)
+			}
+			if len(events) != tc.want {
+				t.Errorf("got %d events, want %d", len(events), tc.want)
+			}
+		})
+	}
+}
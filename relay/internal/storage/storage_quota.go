@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/jackc/pgx/v5"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Storage accounting: pubkey_storage holds the bytes and events every pubkey
+// has stored. A trigger on events keeps it current, so every insert and
+// delete path (replacement, deletion, expiry, retention, cascades) is
+// counted without the write paths knowing about it.
+
+// eventOverheadBytes approximates the fixed columns of a stored event (id,
+// pubkey, sig, created_at, kind); content and tags are counted as stored.
+const eventOverheadBytes = 256
+
+// PubkeyStorage is the storage used by one pubkey.
+type PubkeyStorage struct {
+	Pubkey string `json:"pubkey"`
+	Bytes  int64  `json:"bytes"`
+	Events int64  `json:"events"`
+}
+
+// pubkeyStorageDDL creates the accounting table, the size function and the
+// trigger function.
+var pubkeyStorageDDL = []string{
+	`CREATE TABLE IF NOT EXISTS pubkey_storage (
+  pubkey CHAR(64) NOT NULL,
+  bytes BIGINT NOT NULL DEFAULT 0,
+  events BIGINT NOT NULL DEFAULT 0,
+  CONSTRAINT pubkey_storage_pkey PRIMARY KEY (pubkey)
+)`,
+	`CREATE INDEX IF NOT EXISTS pubkey_storage_bytes ON pubkey_storage (bytes DESC)`,
+	`CREATE OR REPLACE FUNCTION nostr_event_bytes(content TEXT, tags JSONB)
+RETURNS BIGINT IMMUTABLE LANGUAGE sql AS $$
+  SELECT 256 + COALESCE(octet_length(content), 0) + COALESCE(octet_length(tags::text), 0)
+$$`,
+	`CREATE OR REPLACE FUNCTION track_pubkey_storage()
+RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    INSERT INTO pubkey_storage (pubkey, bytes, events)
+    VALUES (NEW.pubkey, nostr_event_bytes(NEW.content, NEW.tags), 1)
+    ON CONFLICT (pubkey) DO UPDATE SET
+      bytes = pubkey_storage.bytes + EXCLUDED.bytes,
+      events = pubkey_storage.events + 1;
+    RETURN NEW;
+  END IF;
+  UPDATE pubkey_storage SET
+    bytes = bytes - nostr_event_bytes(OLD.content, OLD.tags),
+    events = events - 1
+  WHERE pubkey = OLD.pubkey;
+  RETURN OLD;
+END
+$$`,
+}
+
+// pubkeyStorageTriggerDDL attaches the accounting trigger to events.
+const pubkeyStorageTriggerDDL = `CREATE TRIGGER events_pubkey_storage
+AFTER INSERT OR DELETE ON events
+FOR EACH ROW EXECUTE FUNCTION track_pubkey_storage()`
+
+// pubkeyStorageBackfillSQL counts the events stored before accounting existed.
+const pubkeyStorageBackfillSQL = `INSERT INTO pubkey_storage (pubkey, bytes, events)
+SELECT pubkey, SUM(nostr_event_bytes(content, tags)), COUNT(*) FROM events GROUP BY pubkey
+ON CONFLICT (pubkey) DO UPDATE SET bytes = EXCLUDED.bytes, events = EXCLUDED.events`
+
+// ensurePubkeyStorageSchema creates the accounting table and trigger. The
+// first time, stored events are counted in the transaction that creates the
+// trigger, which holds off concurrent writes until both are in place.
+func (db *DB) ensurePubkeyStorageSchema(ctx context.Context) error {
+	for _, ddl := range pubkeyStorageDDL {
+		if _, err := db.Pool.Exec(ctx, ddl); err != nil {
+			return fmt.Errorf("failed to create pubkey_storage table: %w", err)
+		}
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin storage accounting setup: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			logger.Warn("Failed to roll back storage accounting setup", zap.Error(err))
+		}
+	}()
+
+	var exists bool
+	if err := tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'events_pubkey_storage')`,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check storage accounting trigger: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	start := time.Now()
+	if _, err := tx.Exec(ctx, pubkeyStorageTriggerDDL); err != nil {
+		return fmt.Errorf("failed to create storage accounting trigger: %w", err)
+	}
+	result, err := tx.Exec(ctx, pubkeyStorageBackfillSQL)
+	if err != nil {
+		return fmt.Errorf("failed to count stored events per pubkey: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit storage accounting setup: %w", err)
+	}
+	logger.Info("Counted storage of stored events",
+		zap.Int64("pubkeys", result.RowsAffected()),
+		zap.Duration("took", time.Since(start)))
+	return nil
+}
+
+// EventStorageSize returns the bytes evt is accounted for once stored.
+func EventStorageSize(evt *nostr.Event) int64 {
+	size := int64(eventOverheadBytes + len(evt.Content))
+	if tags, err := json.Marshal(evt.Tags); err == nil {
+		size += int64(len(tags))
+	}
+	return size
+}
+
+// GetPubkeyStorage returns the storage used by pubkey.
+func (db *DB) GetPubkeyStorage(ctx context.Context, pubkey string) (PubkeyStorage, error) {
+	if !db.isConnected() {
+		return PubkeyStorage{}, fmt.Errorf("database is not connected")
+	}
+	return db.backend.GetPubkeyStorage(ctx, pubkey)
+}
+
+// GetPubkeyStorage returns the storage used by pubkey.
+func (p *postgresBackend) GetPubkeyStorage(ctx context.Context, pubkey string) (PubkeyStorage, error) {
+	usage := PubkeyStorage{Pubkey: pubkey}
+	err := p.db.Pool.QueryRow(ctx,
+		`SELECT bytes, events FROM pubkey_storage WHERE pubkey = $1`, pubkey,
+	).Scan(&usage.Bytes, &usage.Events)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return usage, nil
+	}
+	return usage, err
+}
+
+// TopStorageConsumers returns the limit pubkeys storing the most bytes.
+func (db *DB) TopStorageConsumers(ctx context.Context, limit int) ([]PubkeyStorage, error) {
+	if !db.isConnected() {
+		return nil, fmt.Errorf("database is not connected")
+	}
+	return db.backend.TopStorageConsumers(ctx, limit)
+}
+
+// TopStorageConsumers returns the limit pubkeys storing the most bytes.
+func (p *postgresBackend) TopStorageConsumers(ctx context.Context, limit int) ([]PubkeyStorage, error) {
+	rows, err := p.db.Pool.Query(ctx,
+		`SELECT pubkey, bytes, events FROM pubkey_storage WHERE events > 0 ORDER BY bytes DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query storage consumers: %w", err)
+	}
+	defer rows.Close()
+
+	consumers := make([]PubkeyStorage, 0, limit)
+	for rows.Next() {
+		var usage PubkeyStorage
+		if err := rows.Scan(&usage.Pubkey, &usage.Bytes, &usage.Events); err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, usage)
+	}
+	return consumers, rows.Err()
+}
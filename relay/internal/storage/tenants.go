@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Multi-tenant storage: events published through a virtual relay are recorded
+// in the event_tenants side table, and queries run with a tenant scope only
+// return that tenant's events. The main relay is the scope "" and sees the
+// events no tenant owns.
+
+// eventTenantsDDL creates the tenant side table, one row per event and
+// tenant. Rows are written before the event itself is stored, so there is no
+// foreign key; PruneEventTenants drops rows whose event never arrived or was
+// deleted.
+const eventTenantsDDL = `CREATE TABLE IF NOT EXISTS event_tenants (
+  event_id CHAR(64) NOT NULL,
+  tenant TEXT NOT NULL,
+  tagged_at BIGINT NOT NULL,
+  CONSTRAINT event_tenants_pkey PRIMARY KEY (event_id, tenant)
+)`
+
+// eventTenantsIndexDDL supports scoped queries, which look up events by tenant.
+const eventTenantsIndexDDL = `CREATE INDEX IF NOT EXISTS event_tenants_tenant ON event_tenants (tenant, event_id)`
+
+// tenantPruneGrace keeps fresh tenant rows whose event is still queued.
+const tenantPruneGrace = time.Hour
+
+// maxTenantCacheEntries bounds the event ownership remembered for live dispatch.
+const maxTenantCacheEntries = 100000
+
+type tenantKey struct{}
+
+// WithTenant scopes storage reads made with ctx to one tenant ("" = main relay).
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant scope of ctx, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// tenantCacheScope returns the query cache scope for ctx.
+func tenantCacheScope(ctx context.Context) string {
+	if tenant, ok := TenantFromContext(ctx); ok {
+		return "@" + tenant
+	}
+	return ""
+}
+
+// tenantCache remembers which tenants own recently seen events, so live
+// dispatch can scope events without a database round trip.
+type tenantCache struct {
+	mu     sync.Mutex
+	owners map[string][]string // event ID -> tenants (empty = main relay)
+	order  []string            // insertion order for eviction
+	next   int
+}
+
+func (tc *tenantCache) get(id string) ([]string, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	owners, ok := tc.owners[id]
+	return owners, ok
+}
+
+// set records the owners of an event, replacing what was known.
+func (tc *tenantCache) set(id string, owners []string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.owners == nil {
+		tc.owners = make(map[string][]string)
+	}
+	if _, known := tc.owners[id]; !known {
+		if len(tc.order) < maxTenantCacheEntries {
+			tc.order = append(tc.order, id)
+		} else {
+			delete(tc.owners, tc.order[tc.next])
+			tc.order[tc.next] = id
+			tc.next = (tc.next + 1) % maxTenantCacheEntries
+		}
+	}
+	tc.owners[id] = owners
+}
+
+// addOwner adds a tenant to the owners of an event.
+func (tc *tenantCache) addOwner(id, tenant string) {
+	owners, _ := tc.get(id)
+	if tenant == "" || slices.Contains(owners, tenant) {
+		if owners == nil {
+			tc.set(id, []string{})
+		}
+		return
+	}
+	tc.set(id, append(slices.Clone(owners), tenant))
+}
+
+// ensureTenantSchema creates the event_tenants table if it does not exist.
+func (db *DB) ensureTenantSchema(ctx context.Context) error {
+	for _, ddl := range []string{eventTenantsDDL, eventTenantsIndexDDL} {
+		if _, err := db.Pool.Exec(ctx, ddl); err != nil {
+			return fmt.Errorf("failed to create event_tenants table: %w", err)
+		}
+	}
+	return nil
+}
+
+// TagEventTenant records that evt was published through tenant ("" = main
+// relay). Ephemeral events are only remembered for live dispatch.
+func (db *DB) TagEventTenant(ctx context.Context, evt *nostr.Event, tenant string) error {
+	db.tenants.addOwner(evt.ID, tenant)
+	if tenant == "" || nips.IsEphemeral(evt.Kind) {
+		return nil
+	}
+
+	if err := db.backend.TagEventTenant(ctx, evt.ID, tenant); err != nil {
+		return fmt.Errorf("failed to tag event tenant: %w", err)
+	}
+
+	// An event republished through another tenant changes that tenant's results
+	db.invalidateQueryCache(evt)
+	return nil
+}
+
+// EventInTenant reports whether tenant ("" = main relay) owns an event.
+func (db *DB) EventInTenant(ctx context.Context, eventID, tenant string) bool {
+	owners, ok := db.tenants.get(eventID)
+	if !ok {
+		var err error
+		owners, err = db.backend.GetEventTenants(ctx, eventID)
+		if err != nil {
+			// Unknown ownership: only the main relay gets the event
+			return tenant == ""
+		}
+		db.tenants.set(eventID, owners)
+	}
+	if tenant == "" {
+		return len(owners) == 0
+	}
+	return slices.Contains(owners, tenant)
+}
+
+// TagEventTenant records that a tenant owns an event.
+func (p *postgresBackend) TagEventTenant(ctx context.Context, eventID, tenant string) error {
+	_, err := p.db.Pool.Exec(ctx,
+		`INSERT INTO event_tenants (event_id, tenant, tagged_at) VALUES ($1, $2, $3)
+		 ON CONFLICT DO NOTHING`,
+		eventID, tenant, time.Now().Unix())
+	return err
+}
+
+// GetEventTenants returns the tenants owning an event (empty = main relay).
+func (p *postgresBackend) GetEventTenants(ctx context.Context, eventID string) ([]string, error) {
+	rows, err := p.db.Pool.Query(ctx, `SELECT tenant FROM event_tenants WHERE event_id = $1`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := []string{}
+	for rows.Next() {
+		var tenant string
+		if err := rows.Scan(&tenant); err != nil {
+			return nil, err
+		}
+		owners = append(owners, tenant)
+	}
+	return owners, rows.Err()
+}
+
+// PruneEventTenants removes tenant rows whose event is no longer stored.
+func (db *DB) PruneEventTenants(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-tenantPruneGrace).Unix()
+	return db.backend.PruneEventTenants(ctx, cutoff)
+}
+
+// PruneEventTenants removes tenant rows older than taggedBefore whose event is not stored.
+func (p *postgresBackend) PruneEventTenants(ctx context.Context, taggedBefore int64) (int, error) {
+	result, err := p.db.Pool.Exec(ctx,
+		`DELETE FROM event_tenants t WHERE t.tagged_at < $1
+		 AND NOT EXISTS (SELECT 1 FROM events e WHERE e.id = t.event_id)`, taggedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune event tenants: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// tenantCondition returns the SQL condition scoping a query on the events
+// table to tenant, using placeholder $argIndex.
+func tenantCondition(tenant string, argIndex int) (string, []interface{}) {
+	if tenant == "" {
+		return "NOT EXISTS (SELECT 1 FROM event_tenants t WHERE t.event_id = events.id)", nil
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM event_tenants t WHERE t.event_id = events.id AND t.tenant = $%d)", argIndex),
+		[]interface{}{tenant}
+}
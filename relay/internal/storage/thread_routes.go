@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/Shugur-Network/relay/internal/metrics"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Thread routing: replies in a hot NIP-7D thread (kind 11 and its kind 1111
+// comments) or NIP-C7 chat (kind 9) would otherwise be offered to every
+// connection, each matching them against all of its subscriptions. A client
+// whose every filter names events through #e, #E or #q can only match the
+// thread events that reference one of them, so it is kept on per-root
+// subscriber lists and the dispatcher hands it only those thread events.
+// Clients with any other filter receive every event as before.
+
+// maxThreadRoots bounds the roots one client is routed by; a client
+// following more receives every thread event.
+const maxThreadRoots = 1000
+
+// threadKinds are the kinds delivered through the thread subscriber lists.
+var threadKinds = map[int]bool{9: true, 11: true, 1111: true}
+
+// threadTags are the tags pointing a thread event at its root, parent or
+// quoted message.
+var threadTags = []string{"e", "E", "q"}
+
+// threadRoutes holds the subscriber lists of the thread-scoped clients.
+type threadRoutes struct {
+	mu      sync.RWMutex
+	roots   map[string]map[string]struct{} // root event -> thread-scoped clients
+	clients map[string][]string            // thread-scoped client -> its roots
+}
+
+func newThreadRoutes() *threadRoutes {
+	return &threadRoutes{
+		roots:   make(map[string]map[string]struct{}),
+		clients: make(map[string][]string),
+	}
+}
+
+// threadRootsOf returns the events filters reference through thread tags,
+// and false unless every filter references some.
+func threadRootsOf(filters []nostr.Filter) ([]string, bool) {
+	seen := make(map[string]bool)
+	var roots []string
+	for _, f := range filters {
+		scoped := false
+		for _, name := range threadTags {
+			for _, value := range f.Tags[name] {
+				scoped = true
+				if !seen[value] {
+					seen[value] = true
+					roots = append(roots, value)
+				}
+			}
+		}
+		if !scoped || len(roots) > maxThreadRoots {
+			return nil, false
+		}
+	}
+	return roots, true
+}
+
+// SetClientFilters routes the thread events of a client by the filters of
+// all its subscriptions. Called whenever they change.
+func (ed *EventDispatcher) SetClientFilters(clientID string, filters []nostr.Filter) {
+	roots, scoped := threadRootsOf(filters)
+
+	ed.clientsMu.RLock()
+	_, registered := ed.clients[clientID]
+	ed.clientsMu.RUnlock()
+
+	t := ed.threads
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.forget(clientID)
+	if scoped && registered {
+		t.clients[clientID] = roots
+		for _, root := range roots {
+			if t.roots[root] == nil {
+				t.roots[root] = make(map[string]struct{})
+			}
+			t.roots[root][clientID] = struct{}{}
+		}
+	}
+	metrics.ThreadSubscribedRoots.Set(float64(len(t.roots)))
+}
+
+// removeClient drops a client from the subscriber lists.
+func (t *threadRoutes) removeClient(clientID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.forget(clientID)
+	metrics.ThreadSubscribedRoots.Set(float64(len(t.roots)))
+}
+
+// forget drops a client from the subscriber lists. Callers hold t.mu.
+func (t *threadRoutes) forget(clientID string) {
+	for _, root := range t.clients[clientID] {
+		delete(t.roots[root], clientID)
+		if len(t.roots[root]) == 0 {
+			delete(t.roots, root)
+		}
+	}
+	delete(t.clients, clientID)
+}
+
+// scoped reports whether a client is routed by thread. Callers hold t.mu.
+func (t *threadRoutes) scoped(clientID string) bool {
+	_, ok := t.clients[clientID]
+	return ok
+}
+
+// follows reports whether evt references a root the client is subscribed
+// to. Callers hold t.mu.
+func (t *threadRoutes) follows(clientID string, evt *nostr.Event) bool {
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || !slices.Contains(threadTags, tag[0]) {
+			continue
+		}
+		if _, ok := t.roots[tag[1]][clientID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// countThreadEvents records the thread events of a broadcast batch.
+func countThreadEvents(events []*nostr.Event) {
+	for _, evt := range events {
+		if threadKinds[evt.Kind] {
+			metrics.ThreadEventsDispatched.WithLabelValues(strconv.Itoa(evt.Kind)).Inc()
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Thread index: every stored kind 1 reply (NIP-10) and kind 1111 comment
+// (NIP-22) is recorded in the event_threads side table with the event it
+// directly answers, so a conversation is walked by parent lookups instead of
+// scanning every event that mentions one of its notes. Rows are removed with
+// their event through the foreign key.
+
+// eventThreadsDDL creates the thread index side table, one row per reply with
+// the event it answers.
+const eventThreadsDDL = `CREATE TABLE IF NOT EXISTS event_threads (
+  event_id CHAR(64) NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  parent_id CHAR(64) NOT NULL,
+  CONSTRAINT event_threads_pkey PRIMARY KEY (event_id)
+)`
+
+// eventThreadsIndexDDL serves reply lookups, which find events by parent.
+const eventThreadsIndexDDL = `CREATE INDEX IF NOT EXISTS event_threads_parent ON event_threads (parent_id, event_id)`
+
+// threadBackfillBatch is the number of index rows written per statement when
+// indexing the replies stored before the table existed.
+const threadBackfillBatch = 1000
+
+// ThreadReply is one edge of the thread index: a reply and the event it answers.
+type ThreadReply struct {
+	ID        string
+	Parent    string
+	CreatedAt int64
+}
+
+// isThreadKind reports whether events of kind are recorded in the thread index.
+func isThreadKind(kind int) bool {
+	return kind == 1 || kind == 1111
+}
+
+// ensureThreadSchema creates the event_threads table, indexing the replies
+// already stored the first time.
+func (db *DB) ensureThreadSchema(ctx context.Context) error {
+	var exists bool
+	if err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'event_threads')`,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check event_threads table: %w", err)
+	}
+
+	for _, ddl := range []string{eventThreadsDDL, eventThreadsIndexDDL} {
+		if _, err := db.Pool.Exec(ctx, ddl); err != nil {
+			return fmt.Errorf("failed to create event_threads table: %w", err)
+		}
+	}
+	if exists {
+		return nil
+	}
+
+	start := time.Now()
+	indexed, err := db.backfillThreads(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to index threads of stored events: %w", err)
+	}
+	logger.Info("Indexed threads of stored events",
+		zap.Int("replies", indexed),
+		zap.Duration("took", time.Since(start)))
+	return nil
+}
+
+// backfillThreads records the stored replies in event_threads. Parents are
+// resolved from the tags, so only events with an e tag are read.
+func (db *DB) backfillThreads(ctx context.Context) (int, error) {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT id, kind, tags FROM events
+		 WHERE kind IN (1, 1111)
+		   AND id IN (SELECT event_id FROM event_tags WHERE tag_name = 'e')`)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids, parents []string
+	for rows.Next() {
+		var evt nostr.Event
+		var rawTags []byte
+		if err := rows.Scan(&evt.ID, &evt.Kind, &rawTags); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if json.Unmarshal(rawTags, &evt.Tags) != nil {
+			continue
+		}
+		if _, parent := nips.ThreadReference(&evt); parent != "" && parent != evt.ID {
+			ids = append(ids, evt.ID)
+			parents = append(parents, parent)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for start := 0; start < len(ids); start += threadBackfillBatch {
+		end := min(start+threadBackfillBatch, len(ids))
+		if _, err := db.Pool.Exec(ctx,
+			`INSERT INTO event_threads (event_id, parent_id)
+			 SELECT * FROM unnest($1::text[], $2::text[]) ON CONFLICT DO NOTHING`,
+			ids[start:end], parents[start:end]); err != nil {
+			return start, err
+		}
+	}
+	return len(ids), nil
+}
+
+// indexThread records a newly stored reply in the thread index. Drivers
+// without the table find replies through the tag index instead.
+func (db *DB) indexThread(ctx context.Context, evt *nostr.Event) {
+	if !isThreadKind(evt.Kind) || !db.usesPool() {
+		return
+	}
+	_, parent := nips.ThreadReference(evt)
+	if parent == "" || parent == evt.ID {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	if _, err := db.Pool.Exec(ctx,
+		`INSERT INTO event_threads (event_id, parent_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		evt.ID, parent); err != nil {
+		logger.Warn("Failed to index thread reply",
+			zap.String("event_id", evt.ID),
+			zap.Error(err))
+	}
+}
+
+// ThreadReplies returns the stored replies whose direct parent is one of
+// parents, oldest first, at most limit of them. since > 0 skips replies
+// created before it. Reads are scoped to the tenant of ctx like GetEvents.
+func (db *DB) ThreadReplies(ctx context.Context, parents []string, since int64, limit int) ([]ThreadReply, error) {
+	if len(parents) == 0 || limit <= 0 {
+		return nil, nil
+	}
+	if !db.usesPool() {
+		return db.threadRepliesFromTags(ctx, parents, since, limit)
+	}
+
+	query := `SELECT t.event_id, t.parent_id, events.created_at
+	 FROM event_threads t JOIN events ON events.id = t.event_id
+	 WHERE t.parent_id = ANY($1)`
+	args := []interface{}{parents}
+	if since > 0 {
+		query += fmt.Sprintf(" AND events.created_at >= $%d", len(args)+1)
+		args = append(args, since)
+	}
+	if tenant, ok := TenantFromContext(ctx); ok {
+		cond, condArgs := tenantCondition(tenant, len(args)+1)
+		query += " AND " + cond
+		args = append(args, condArgs...)
+	}
+	query += fmt.Sprintf(" ORDER BY events.created_at ASC, t.event_id ASC LIMIT $%d", len(args)+1)
+	args = append(args, limit)
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query thread replies: %w", err)
+	}
+	defer rows.Close()
+
+	var replies []ThreadReply
+	for rows.Next() {
+		var r ThreadReply
+		if err := rows.Scan(&r.ID, &r.Parent, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan thread reply: %w", err)
+		}
+		replies = append(replies, r)
+	}
+	return replies, rows.Err()
+}
+
+// threadRepliesFromTags finds replies through #e filters and resolves their
+// parents from the tags, for drivers without the thread index.
+func (db *DB) threadRepliesFromTags(ctx context.Context, parents []string, since int64, limit int) ([]ThreadReply, error) {
+	f := nostr.Filter{
+		Kinds: []int{1, 1111},
+		Tags:  nostr.TagMap{"e": parents},
+		Limit: limit * 4, // roots and mentions also match
+	}
+	if since > 0 {
+		ts := nostr.Timestamp(since)
+		f.Since = &ts
+	}
+	events, err := db.GetEvents(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(parents))
+	for _, id := range parents {
+		wanted[id] = true
+	}
+	var replies []ThreadReply
+	for i := range events {
+		_, parent := nips.ThreadReference(&events[i])
+		if parent != events[i].ID && wanted[parent] {
+			replies = append(replies, ThreadReply{ID: events[i].ID, Parent: parent, CreatedAt: int64(events[i].CreatedAt)})
+		}
+	}
+	slices.SortFunc(replies, func(a, b ThreadReply) int {
+		return cmp.Or(cmp.Compare(a.CreatedAt, b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	if len(replies) > limit {
+		replies = replies[:limit]
+	}
+	return replies, nil
+}
+
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Tombstones: events leaving the events table are moved to event_tombstones
+// instead of being dropped. The unique replaceable/addressable indexes rule
+// out keeping superseded rows in place, but the tombstone keeps the full
+// event for audit and lets every node sharing the database refuse an event
+// that was deleted, replaced or removed when a client or a syncing relay
+// publishes it again.
+//
+// NIP-09 requests naming events this relay never stored leave a bare
+// tombstone (no event body) so the event is refused if it arrives later.
+
+// Tombstone reasons.
+const (
+	TombstoneDeleted  = "deleted"  // NIP-09 deletion request
+	TombstoneReplaced = "replaced" // superseded replaceable or addressable event
+	TombstoneVanished = "vanished" // NIP-62 request to vanish
+	TombstoneRemoved  = "removed"  // removed by the relay (moderation, DM inbox purge)
+)
+
+// eventTombstonesDDL creates the tombstone side table, keyed by event ID.
+// Event columns are NULL for bare tombstones of events never stored here.
+const eventTombstonesDDL = `CREATE TABLE IF NOT EXISTS event_tombstones (
+  event_id CHAR(64) NOT NULL,
+  pubkey CHAR(64) NOT NULL,
+  created_at BIGINT NULL,
+  kind BIGINT NULL,
+  tags JSONB NULL,
+  content TEXT NULL,
+  sig CHAR(128) NULL,
+  deleted_at BIGINT NOT NULL,
+  deleted_by CHAR(64) NULL,
+  reason TEXT NOT NULL,
+  CONSTRAINT event_tombstones_pkey PRIMARY KEY (event_id)
+)`
+
+// eventTombstonesIndexDDL supports listing the tombstones of a pubkey.
+const eventTombstonesIndexDDL = `CREATE INDEX IF NOT EXISTS event_tombstones_pubkey ON event_tombstones (pubkey, deleted_at)`
+
+// pgExecer is satisfied by both the pool and a transaction.
+type pgExecer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// ensureTombstoneSchema creates the event_tombstones table if it does not exist.
+func (db *DB) ensureTombstoneSchema(ctx context.Context) error {
+	for _, ddl := range []string{eventTombstonesDDL, eventTombstonesIndexDDL} {
+		if _, err := db.Pool.Exec(ctx, ddl); err != nil {
+			return fmt.Errorf("failed to create event_tombstones table: %w", err)
+		}
+	}
+	return nil
+}
+
+// tombstoneEvents moves the events matching where (placeholders $1..$n for
+// args) into event_tombstones and returns how many were moved. A bare
+// tombstone for the same ID is replaced by the full one.
+func tombstoneEvents(ctx context.Context, q pgExecer, where string, args []interface{}, deletedBy, reason string) (int64, error) {
+	n := len(args)
+	query := fmt.Sprintf(`WITH gone AS (
+		DELETE FROM events WHERE %s
+		RETURNING id, pubkey, created_at, kind, tags, content, sig
+	)
+	INSERT INTO event_tombstones (event_id, pubkey, created_at, kind, tags, content, sig, deleted_at, deleted_by, reason)
+	SELECT id, pubkey, created_at, kind, tags, content, sig, $%d, NULLIF($%d, ''), $%d FROM gone
+	ON CONFLICT (event_id) DO UPDATE SET
+		pubkey = EXCLUDED.pubkey, created_at = EXCLUDED.created_at, kind = EXCLUDED.kind,
+		tags = EXCLUDED.tags, content = EXCLUDED.content, sig = EXCLUDED.sig,
+		deleted_at = EXCLUDED.deleted_at, deleted_by = EXCLUDED.deleted_by, reason = EXCLUDED.reason`, where, n+1, n+2, n+3)
+
+	args = append(args, time.Now().Unix(), deletedBy, reason)
+	result, err := q.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// tombstoneMissing records bare tombstones for the event IDs of a deletion
+// request that are not stored here, so they are refused if they arrive later.
+func tombstoneMissing(ctx context.Context, q pgExecer, ids []string, del nostr.Event) error {
+	ids = validTombstoneIDs(ids)
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := q.Exec(ctx,
+		`INSERT INTO event_tombstones (event_id, pubkey, deleted_at, deleted_by, reason)
+		 SELECT t.id, $2, $3, $4, $5 FROM unnest($1::text[]) AS t(id)
+		 WHERE NOT EXISTS (SELECT 1 FROM events e WHERE e.id = t.id)
+		 ON CONFLICT (event_id) DO NOTHING`,
+		ids, del.PubKey, time.Now().Unix(), del.ID, TombstoneDeleted)
+	return err
+}
+
+// IsEventDeleted reports whether an event by pubkey has a tombstone, meaning
+// it must not be stored again.
+func (db *DB) IsEventDeleted(ctx context.Context, eventID, pubkey string) (bool, error) {
+	if !db.isConnected() {
+		return false, fmt.Errorf("database is not connected")
+	}
+	return db.backend.IsEventDeleted(ctx, eventID, pubkey)
+}
+
+// IsEventDeleted reports whether an event by pubkey has a tombstone.
+func (p *postgresBackend) IsEventDeleted(ctx context.Context, eventID, pubkey string) (bool, error) {
+	var deleted bool
+	err := p.db.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM event_tombstones WHERE event_id = $1 AND pubkey = $2)`,
+		eventID, pubkey).Scan(&deleted)
+	return deleted, err
+}
+
+// AllDeleted reports whether every event ID in ids by pubkey is already
+// tombstoned, so a NIP-09 request naming them changes nothing.
+func (db *DB) AllDeleted(ctx context.Context, ids []string, pubkey string) (bool, error) {
+	if len(ids) == 0 {
+		return false, nil
+	}
+	for _, id := range ids {
+		deleted, err := db.IsEventDeleted(ctx, id, pubkey)
+		if err != nil || !deleted {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// validTombstoneIDs keeps the well-formed event IDs of a deletion request.
+func validTombstoneIDs(ids []string) []string {
+	valid := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if nostr.IsValid32ByteHex(id) {
+			valid = append(valid, id)
+		}
+	}
+	return valid
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/Shugur-Network/relay/internal/tracing"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxTracedStatement caps the SQL text attached to query spans.
+const maxTracedStatement = 1024
+
+// queryTracer records a span for every pgx query issued under a traced
+// command. Queries without a sampled parent span (pool maintenance,
+// background jobs) are not traced.
+type queryTracer struct{}
+
+type querySpanKey struct{}
+
+// TraceQueryStart implements pgx.QueryTracer.
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	statement := data.SQL
+	if len(statement) > maxTracedStatement {
+		statement = statement[:maxTracedStatement]
+	}
+	ctx, span := tracing.StartChild(ctx, "db.query", tracing.SpanKindClient,
+		tracing.String("db.system", "postgresql"),
+		tracing.String("db.statement", statement),
+		tracing.Int("db.args", len(data.Args)))
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+// TraceQueryEnd implements pgx.QueryTracer.
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, _ := ctx.Value(querySpanKey{}).(*tracing.Span)
+	if span == nil {
+		return
+	}
+	if data.Err != nil && data.Err != pgx.ErrNoRows {
+		span.RecordError(data.Err)
+	}
+	span.SetAttributes(tracing.String("db.command", data.CommandTag.String()))
+	span.End()
+}
@@ -0,0 +1,176 @@
+// Package tlscert provides the TLS certificates of the relay when it serves
+// HTTPS itself: from certificate files reloaded when they change, or from an
+// ACME CA such as Let's Encrypt using the HTTP-01, TLS-ALPN-01 or DNS-01
+// challenge.
+package tlscert
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Defaults for settings left unset.
+const (
+	defaultReloadInterval = time.Minute
+	defaultCacheDir       = "./certs"
+	defaultPropagation    = 60 * time.Second
+)
+
+// source supplies certificates and keeps them current while running.
+type source interface {
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	run(ctx context.Context)
+}
+
+// Manager serves the certificates of one TLS listener.
+type Manager struct {
+	cfg      config.TLSConfig
+	src      source
+	autocert *autocert.Manager // HTTP-01 and TLS-ALPN-01 only
+}
+
+// New builds a Manager from cfg, loading certificate files right away.
+func New(cfg config.TLSConfig) (*Manager, error) {
+	m := &Manager{cfg: cfg}
+	ac := cfg.ACME
+	if !ac.Enabled {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("TLS needs CERT_FILE and KEY_FILE or ACME")
+		}
+		interval := cfg.ReloadInterval
+		if interval <= 0 {
+			interval = defaultReloadInterval
+		}
+		fs, err := newFileSource(cfg.CertFile, cfg.KeyFile, interval)
+		if err != nil {
+			return nil, err
+		}
+		m.src = fs
+		return m, nil
+	}
+
+	if len(ac.Domains) == 0 {
+		return nil, fmt.Errorf("ACME needs at least one domain")
+	}
+	cacheDir := ac.CacheDir
+	if cacheDir == "" {
+		cacheDir = defaultCacheDir
+	}
+	client := &acme.Client{DirectoryURL: ac.Directory}
+
+	switch ac.Challenge {
+	case "dns-01":
+		if ac.DNSHook == "" {
+			return nil, fmt.Errorf("the dns-01 challenge needs DNS_HOOK")
+		}
+		propagation := ac.DNSPropagation
+		if propagation <= 0 {
+			propagation = defaultPropagation
+		}
+		m.src = newDNSSource(client, autocert.DirCache(cacheDir), ac, propagation)
+	case "http-01":
+		if cfg.HTTPAddr == "" {
+			return nil, fmt.Errorf("the http-01 challenge needs HTTP_ADDR")
+		}
+		fallthrough
+	default:
+		for _, d := range ac.Domains {
+			if strings.HasPrefix(d, "*.") {
+				return nil, fmt.Errorf("wildcard domain %s needs the dns-01 challenge", d)
+			}
+		}
+		m.autocert = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(ac.Domains...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      ac.Email,
+			Client:     client,
+		}
+		m.src = autocertSource{m.autocert}
+	}
+	return m, nil
+}
+
+// Start keeps the certificates current until ctx is canceled.
+func (m *Manager) Start(ctx context.Context) {
+	go m.src.run(ctx)
+}
+
+// TLSConfig returns the server TLS configuration. Only HTTP/1.1 is offered,
+// as websocket upgrades need it.
+func (m *Manager) TLSConfig() *tls.Config {
+	protos := []string{"http/1.1"}
+	if m.autocert != nil {
+		protos = append(protos, acme.ALPNProto)
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     protos,
+		GetCertificate: m.src.GetCertificate,
+	}
+}
+
+// HTTPHandler returns the handler of the plain HTTP listener: it answers
+// HTTP-01 challenges and redirects everything else to HTTPS.
+func (m *Manager) HTTPHandler() http.Handler {
+	redirect := http.HandlerFunc(m.redirect)
+	if m.autocert != nil && m.cfg.ACME.Challenge == "http-01" {
+		return m.autocert.HTTPHandler(redirect)
+	}
+	return redirect
+}
+
+// redirect sends a plain HTTP request to the same URL over HTTPS.
+func (m *Manager) redirect(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(m.cfg.Addr); err == nil && port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	code := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+}
+
+// ServeHTTP serves the plain HTTP listener at addr until ctx is canceled.
+func (m *Manager) ServeHTTP(ctx context.Context, addr string) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           m.HTTPHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       30 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("HTTP redirect listener started", zap.String("address", addr))
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Error("HTTP redirect listener failed", zap.Error(err))
+	}
+}
+
+// autocertSource adapts autocert, which renews on its own.
+type autocertSource struct {
+	*autocert.Manager
+}
+
+func (autocertSource) run(context.Context) {}
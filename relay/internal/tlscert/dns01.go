@@ -0,0 +1,300 @@
+package tlscert
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// DNS-01 issuance, which autocert does not support: the TXT records are
+// created and removed by an operator-supplied hook, so any DNS provider can
+// be used, and wildcard names become possible.
+
+const (
+	renewBefore     = 30 * 24 * time.Hour // renew certificates expiring sooner
+	renewCheck      = 12 * time.Hour
+	renewRetry      = 30 * time.Minute
+	issueTimeout    = 15 * time.Minute
+	hookTimeout     = 2 * time.Minute
+	accountCacheKey = "dns01_account+key"
+)
+
+// dnsSource obtains and renews one certificate for all configured domains.
+type dnsSource struct {
+	client      *acme.Client
+	cache       autocert.Cache
+	cfg         config.ACMEConfig
+	propagation time.Duration
+	registered  bool
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newDNSSource(client *acme.Client, cache autocert.Cache, cfg config.ACMEConfig, propagation time.Duration) *dnsSource {
+	return &dnsSource{client: client, cache: cache, cfg: cfg, propagation: propagation}
+}
+
+// GetCertificate returns the current certificate.
+func (d *dnsSource) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.cert == nil {
+		return nil, errors.New("certificate not issued yet")
+	}
+	return d.cert, nil
+}
+
+// run loads the cached certificate and renews it before it expires until
+// ctx is canceled.
+func (d *dnsSource) run(ctx context.Context) {
+	log := logger.New("tls")
+	if data, err := d.cache.Get(ctx, d.certCacheKey()); err == nil {
+		if cert, err := tls.X509KeyPair(data, data); err == nil {
+			d.setCert(&cert)
+		} else {
+			log.Warn("Ignoring invalid cached certificate", zap.Error(err))
+		}
+	}
+
+	for {
+		wait := renewCheck
+		if d.needsRenewal(time.Now()) {
+			if err := d.obtain(ctx); err != nil {
+				log.Error("Failed to obtain certificate with dns-01",
+					zap.Strings("domains", d.cfg.Domains),
+					zap.Error(err))
+				wait = renewRetry
+			} else {
+				log.Info("Obtained certificate with dns-01", zap.Strings("domains", d.cfg.Domains))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (d *dnsSource) setCert(cert *tls.Certificate) {
+	d.mu.Lock()
+	d.cert = cert
+	d.mu.Unlock()
+}
+
+// needsRenewal reports whether there is no certificate or it expires soon.
+func (d *dnsSource) needsRenewal(now time.Time) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.cert == nil || d.cert.Leaf == nil || d.cert.Leaf.NotAfter.Sub(now) < renewBefore
+}
+
+// certCacheKey names the cache entry of the certificate and its key.
+func (d *dnsSource) certCacheKey() string {
+	return "dns01_" + strings.ReplaceAll(d.cfg.Domains[0], "*", "_wildcard")
+}
+
+// obtain runs an ACME order for the configured domains, answering each
+// authorization with a DNS-01 TXT record.
+func (d *dnsSource) obtain(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, issueTimeout)
+	defer cancel()
+
+	if err := d.register(ctx); err != nil {
+		return err
+	}
+	order, err := d.client.AuthorizeOrder(ctx, acme.DomainIDs(d.cfg.Domains...))
+	if err != nil {
+		return fmt.Errorf("creating order: %w", err)
+	}
+	for _, u := range order.AuthzURLs {
+		if err := d.authorize(ctx, u); err != nil {
+			return err
+		}
+	}
+	if order, err = d.client.WaitOrder(ctx, order.URI); err != nil {
+		return fmt.Errorf("waiting for order: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: d.cfg.Domains[0]},
+		DNSNames: d.cfg.Domains,
+	}, key)
+	if err != nil {
+		return err
+	}
+	chain, _, err := d.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return fmt.Errorf("finalizing order: %w", err)
+	}
+
+	data, err := encodeKeyAndChain(key, chain)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return err
+	}
+	if err := d.cache.Put(ctx, d.certCacheKey(), data); err != nil {
+		logger.New("tls").Warn("Failed to cache certificate", zap.Error(err))
+	}
+	d.setCert(&cert)
+	return nil
+}
+
+// authorize completes the authorization at url unless it is already valid.
+func (d *dnsSource) authorize(ctx context.Context, url string) error {
+	z, err := d.client.GetAuthorization(ctx, url)
+	if err != nil {
+		return fmt.Errorf("fetching authorization: %w", err)
+	}
+	if z.Status == acme.StatusValid {
+		return nil
+	}
+	var chal *acme.Challenge
+	for _, c := range z.Challenges {
+		if c.Type == "dns-01" {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("no dns-01 challenge offered for %s", z.Identifier.Value)
+	}
+	value, err := d.client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		return err
+	}
+
+	// Wildcard authorizations carry the base domain
+	domain := z.Identifier.Value
+	record := "_acme-challenge." + domain
+	if err := d.hook(ctx, "present", domain, record, value); err != nil {
+		return err
+	}
+	defer func() {
+		if err := d.hook(context.Background(), "cleanup", domain, record, value); err != nil {
+			logger.New("tls").Warn("DNS cleanup hook failed", zap.Error(err))
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d.propagation):
+	}
+	if _, err := d.client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("accepting challenge for %s: %w", domain, err)
+	}
+	if _, err := d.client.WaitAuthorization(ctx, z.URI); err != nil {
+		return fmt.Errorf("authorizing %s: %w", domain, err)
+	}
+	return nil
+}
+
+// register loads or creates the account key and registers the account once.
+func (d *dnsSource) register(ctx context.Context) error {
+	if d.registered {
+		return nil
+	}
+	key, err := d.accountKey(ctx)
+	if err != nil {
+		return err
+	}
+	d.client.Key = key
+
+	acct := &acme.Account{}
+	if d.cfg.Email != "" {
+		acct.Contact = []string{"mailto:" + d.cfg.Email}
+	}
+	if _, err := d.client.Register(ctx, acct, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return fmt.Errorf("registering ACME account: %w", err)
+	}
+	d.registered = true
+	return nil
+}
+
+// accountKey returns the cached account key, creating it on first use.
+func (d *dnsSource) accountKey(ctx context.Context) (crypto.Signer, error) {
+	if data, err := d.cache.Get(ctx, accountCacheKey); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("invalid cached account key")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	} else if err != autocert.ErrCacheMiss {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := d.cache.Put(ctx, accountCacheKey, data); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// hook runs the DNS hook for action ("present" or "cleanup").
+func (d *dnsSource) hook(ctx context.Context, action, domain, record, value string) error {
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, d.cfg.DNSHook, action, domain, record, value).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return fmt.Errorf("DNS hook %s for %s: %w", action, domain, err)
+	}
+	return nil
+}
+
+// encodeKeyAndChain encodes a private key followed by its certificate chain
+// as PEM, the layout autocert uses for its cache entries.
+func encodeKeyAndChain(key *ecdsa.PrivateKey, chain [][]byte) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}); err != nil {
+		return nil, err
+	}
+	for _, c := range chain {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: c}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/logger"
+	"go.uber.org/zap"
+)
+
+// fileSource serves a certificate from PEM files and reloads it when either
+// file changes, so renewals by an external tool need no restart.
+type fileSource struct {
+	certFile, keyFile string
+	interval          time.Duration
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newFileSource(certFile, keyFile string, interval time.Duration) (*fileSource, error) {
+	fs := &fileSource{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// GetCertificate returns the current certificate.
+func (fs *fileSource) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.cert, nil
+}
+
+// run reloads the certificate whenever its files change until ctx is canceled.
+func (fs *fileSource) run(ctx context.Context) {
+	ticker := time.NewTicker(fs.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modTime, err := fs.latestModTime()
+		if err != nil {
+			logger.Warn("Cannot check TLS certificate files", zap.Error(err))
+			continue
+		}
+		fs.mu.RLock()
+		changed := modTime.After(fs.modTime)
+		fs.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := fs.load(); err != nil {
+			// Keep serving the previous certificate until the files are fixed
+			logger.Error("Failed to reload TLS certificate", zap.Error(err))
+			continue
+		}
+		logger.Info("Reloaded TLS certificate", zap.String("cert_file", fs.certFile))
+	}
+}
+
+// load reads the certificate and key files.
+func (fs *fileSource) load() error {
+	modTime, err := fs.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(fs.certFile, fs.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	fs.mu.Lock()
+	fs.cert, fs.modTime = &cert, modTime
+	fs.mu.Unlock()
+	return nil
+}
+
+// latestModTime returns the later modification time of the two files.
+func (fs *fileSource) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{fs.certFile, fs.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
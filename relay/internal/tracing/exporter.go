@@ -0,0 +1,305 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultBatchSize      = 512
+	defaultExportInterval = 5 * time.Second
+	queueBatches          = 4 // finished spans buffered per batch before dropping
+	scopeName             = "github.com/Shugur-Network/relay"
+)
+
+// exporter batches finished spans and posts them to an OTLP/HTTP collector.
+type exporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	batchSize   int
+	interval    time.Duration
+	client      *http.Client
+
+	spans    chan *Span
+	flushReq chan chan struct{}
+	done     chan struct{} // closed by shutdown
+	stopped  chan struct{} // closed when run returns
+	stopOnce sync.Once
+}
+
+func newExporter(cfg config.TracingConfig) (*exporter, error) {
+	endpoint, err := tracesURL(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	interval := cfg.ExportInterval
+	if interval <= 0 {
+		interval = defaultExportInterval
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "shugur-relay"
+	}
+	return &exporter{
+		endpoint:    endpoint,
+		headers:     cfg.Headers,
+		serviceName: serviceName,
+		batchSize:   batchSize,
+		interval:    interval,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		spans:       make(chan *Span, batchSize*queueBatches),
+		flushReq:    make(chan chan struct{}),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}, nil
+}
+
+// tracesURL appends the OTLP traces path to a bare collector URL.
+func tracesURL(endpoint string) (string, error) {
+	if endpoint == "" {
+		endpoint = "http://localhost:4318"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid tracing endpoint %q", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return u.String(), nil
+}
+
+// enqueue hands a finished span to the exporter without blocking the caller.
+func (e *exporter) enqueue(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		// Collector is slow or down; tracing must never back-pressure the relay
+	}
+}
+
+// run exports batches until ctx is canceled or the exporter is shut down.
+func (e *exporter) run(ctx context.Context) {
+	defer close(e.stopped)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, e.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		exportCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := e.export(exportCtx, batch); err != nil {
+			logger.Warn("Failed to export spans", zap.Int("spans", len(batch)), zap.Error(err))
+		}
+		cancel()
+		batch = batch[:0]
+	}
+	drain := func() {
+		for {
+			select {
+			case s := <-e.spans:
+				batch = append(batch, s)
+				if len(batch) >= e.batchSize {
+					flush()
+				}
+			default:
+				flush()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			drain()
+			return
+		case <-e.done:
+			return
+		case reply := <-e.flushReq:
+			drain()
+			close(reply)
+		case s := <-e.spans:
+			batch = append(batch, s)
+			if len(batch) >= e.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// shutdown flushes buffered spans and stops the export loop.
+func (e *exporter) shutdown(ctx context.Context) {
+	e.stopOnce.Do(func() {
+		reply := make(chan struct{})
+		select {
+		case e.flushReq <- reply:
+			select {
+			case <-reply:
+			case <-ctx.Done():
+			}
+		case <-e.stopped:
+		case <-ctx.Done():
+		}
+		close(e.done)
+	})
+}
+
+// export posts one batch using the OTLP/HTTP JSON encoding.
+func (e *exporter) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON payload (opentelemetry-proto ExportTraceServiceRequest)
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 0 unset, 1 ok, 2 error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 is a string in OTLP JSON
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func (e *exporter) encode(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           s.sc.TraceID.String(),
+			SpanID:            s.sc.SpanID.String(),
+			Name:              s.name,
+			Kind:              int(s.kind),
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(s.attrs),
+		}
+		if s.parent != (SpanID{}) {
+			span.ParentSpanID = s.parent.String()
+		}
+		if s.failed {
+			span.Status = otlpStatus{Code: 2, Message: s.errMsg}
+		}
+		s.mu.Unlock()
+		out = append(out, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: encodeAttributes([]Attribute{
+			String("service.name", e.serviceName),
+			String("service.version", config.Version),
+		})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: scopeName, Version: config.Version},
+			Spans: out,
+		}},
+	}}}
+}
+
+func encodeAttributes(attrs []Attribute) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v otlpValue
+		switch val := a.Value.(type) {
+		case string:
+			v.StringValue = &val
+		case int64:
+			s := strconv.FormatInt(val, 10)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &val
+		case bool:
+			v.BoolValue = &val
+		default:
+			s := fmt.Sprint(val)
+			v.StringValue = &s
+		}
+		out = append(out, otlpKeyValue{Key: a.Key, Value: v})
+	}
+	return out
+}
@@ -0,0 +1,259 @@
+// Package tracing records OpenTelemetry-compatible spans for client commands
+// and exports them to an OTLP/HTTP collector. Trace and span IDs follow the
+// W3C Trace Context format, so spans line up with other instrumented services.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mrand "math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the hex encoding of the trace ID.
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// String returns the hex encoding of the span ID.
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// SpanContext is the part of a span that is carried across goroutines and queues.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether the span context refers to a real span.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// SpanKind mirrors the OTLP span kind values.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+	SpanKindConsumer SpanKind = 5
+)
+
+// Attribute is a key/value pair attached to a span.
+type Attribute struct {
+	Key   string
+	Value interface{} // string, int64, float64 or bool
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// Span is an operation being timed. A nil *Span is valid and records nothing,
+// so callers never need to check whether tracing is enabled.
+type Span struct {
+	sc       SpanContext
+	parent   SpanID
+	name     string
+	kind     SpanKind
+	start    time.Time
+	end      time.Time
+	mu       sync.Mutex
+	attrs    []Attribute
+	errMsg   string
+	failed   bool
+	ended    atomic.Bool
+	exporter *exporter
+}
+
+// SpanContext returns the span's propagation context.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetError(err.Error())
+}
+
+// SetError marks the span as failed with a message, e.g. a rejection reason.
+func (s *Span) SetError(msg string) {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+	s.mu.Lock()
+	s.failed = true
+	s.errMsg = msg
+	s.mu.Unlock()
+}
+
+// End finishes the span and hands it to the exporter.
+func (s *Span) End() {
+	if s == nil || !s.ended.CompareAndSwap(false, true) || !s.sc.Sampled {
+		return
+	}
+	s.end = time.Now()
+	s.exporter.enqueue(s)
+}
+
+type (
+	spanKey       struct{}
+	activeSpanKey struct{}
+)
+
+// ContextWithSpan returns a context carrying the span as the current parent.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(context.WithValue(ctx, spanKey{}, s.sc), activeSpanKey{}, s)
+}
+
+// SpanFromContext returns the span started in this process that ctx carries,
+// or nil (which is safe to use).
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(activeSpanKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemoteParent returns a context whose next span is a child of sc.
+// It is used to continue a trace on the far side of a queue.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+// SpanContextFromContext returns the current span context, if any.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanKey{}).(SpanContext)
+	return sc
+}
+
+// tracer holds the process-wide tracing state.
+type tracer struct {
+	exporter    *exporter
+	sampleRatio float64
+}
+
+var active atomic.Pointer[tracer]
+
+// Enabled reports whether spans are being recorded.
+func Enabled() bool {
+	return active.Load() != nil
+}
+
+// Init starts exporting spans according to cfg. It is a no-op when tracing is
+// disabled; the exporter runs until ctx is canceled or Shutdown is called.
+func Init(ctx context.Context, cfg config.TracingConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	exp, err := newExporter(cfg)
+	if err != nil {
+		return err
+	}
+	active.Store(&tracer{exporter: exp, sampleRatio: cfg.SampleRatio})
+	go exp.run(ctx)
+	return nil
+}
+
+// Shutdown stops recording spans and flushes the ones already finished.
+func Shutdown(ctx context.Context) {
+	t := active.Swap(nil)
+	if t == nil {
+		return
+	}
+	t.exporter.shutdown(ctx)
+}
+
+// Start begins a span as a child of the span in ctx, or as a new root span
+// subject to sampling. It returns a context carrying the new span.
+func Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	t := active.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() && !parent.Sampled {
+		// Keep the unsampled decision for the whole trace
+		return ctx, nil
+	}
+
+	s := &Span{
+		name:     name,
+		kind:     kind,
+		start:    time.Now(),
+		attrs:    attrs,
+		exporter: t.exporter,
+	}
+	if parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.parent = parent.SpanID
+		s.sc.Sampled = true
+	} else {
+		s.sc.TraceID = newTraceID()
+		s.sc.Sampled = t.sampleRatio >= 1 || mrand.Float64() < t.sampleRatio
+	}
+	s.sc.SpanID = newSpanID()
+
+	if !s.sc.Sampled {
+		// Remember the decision so children are skipped too
+		return context.WithValue(ctx, spanKey{}, s.sc), nil
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+// StartChild begins a span only when ctx already carries a sampled span, so
+// background work (cleanups, refreshes) never starts traces of its own.
+func StartChild(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	if !SpanContextFromContext(ctx).Sampled {
+		return ctx, nil
+	}
+	return Start(ctx, name, kind, attrs...)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	_, _ = rand.Read(id[:])
+	return id
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/anomaly"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	anomaliesDefaultLimit = 50
+	anomaliesMaxLimit     = 1000
+)
+
+// SetAnomalyDetector sets the client anomaly detector served at
+// /api/anomalies; nil when anomaly detection is disabled.
+func (h *Handler) SetAnomalyDetector(d *anomaly.Detector) {
+	h.anomalies = d
+}
+
+// HandleAnomaliesAPI serves recent client anomaly alerts and the baseline of
+// every feature:
+// GET /api/anomalies?since=<unix seconds>&limit=<n>
+func (h *Handler) HandleAnomaliesAPI(w http.ResponseWriter, r *http.Request) {
+	// Apply security headers for API endpoints
+	apiHeaders := APISecurityHeaders()
+	apiHeaders.Apply(w)
+
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// Handle preflight requests
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Only allow GET requests
+	if r.Method != "GET" {
+		methodErr := errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed.")
+		errors.HandleHTTPError(w, r, methodErr)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := anomaliesDefaultLimit
+	if l := SanitizeQueryParam(query.Get("limit")); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > anomaliesMaxLimit {
+			validationErr := errors.ValidationError("INVALID_LIMIT_PARAMETER",
+				"limit parameter must be between 1 and 1000").
+				WithUserMessage("Invalid limit parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		limit = n
+	}
+
+	var since time.Time
+	if s := SanitizeQueryParam(query.Get("since")); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			validationErr := errors.ValidationError("INVALID_SINCE_PARAMETER",
+				"since parameter must be a unix timestamp").
+				WithUserMessage("Invalid since parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		since = time.Unix(n, 0)
+	}
+
+	if h.anomalies == nil {
+		notFoundErr := errors.NotFoundError("Anomaly detection").
+			WithUserMessage("Anomaly detection is disabled on this relay.")
+		errors.HandleHTTPError(w, r, notFoundErr)
+		return
+	}
+
+	alerts := h.anomalies.Alerts(limit, since)
+	if alerts == nil {
+		alerts = []anomaly.Alert{}
+	}
+	response := struct {
+		Alerts    []anomaly.Alert             `json:"alerts"` // most recent first
+		Baselines map[string]anomaly.Baseline `json:"baselines"`
+	}{
+		Alerts:    alerts,
+		Baselines: h.anomalies.Baselines(),
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode anomalies response", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// articleDescriptionLength bounds the og:description taken from the article
+// text when it has no summary.
+const articleDescriptionLength = 200
+
+// Article is a NIP-23 long-form post as shown on its HTML page.
+type Article struct {
+	Title       string
+	Summary     string
+	Image       string // header image, kept only when http(s)
+	Content     string // markdown
+	Hashtags    []string
+	PublishedAt time.Time
+	UpdatedAt   time.Time
+
+	AuthorName   string // from the author's kind 0, may be empty
+	AuthorNpub   string
+	CanonicalURL string // http(s) URL of this page
+	Naddr        string // for opening the article in a Nostr client
+}
+
+// articlePage is the data of templates/article.html.
+type articlePage struct {
+	Article
+	SiteName    string
+	Description string
+	Body        template.HTML
+	Published   string // RFC 3339, for article:published_time
+	Modified    string
+	Date        string // human readable publication date
+}
+
+// RenderArticle writes the HTML page of a.
+func (h *Handler) RenderArticle(w http.ResponseWriter, a Article) {
+	tmpl, err := template.ParseFS(h.assets, "templates/article.html")
+	if err != nil {
+		h.logger.Error("Failed to parse article template", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if !isHTTPURL(a.Image) {
+		a.Image = ""
+	}
+	if a.Title == "" {
+		a.Title = "Untitled"
+	}
+	page := articlePage{
+		Article:     a,
+		SiteName:    h.config.Relay.Name,
+		Description: a.Summary,
+		Body:        RenderMarkdown(a.Content),
+		Published:   a.PublishedAt.UTC().Format(time.RFC3339),
+		Modified:    a.UpdatedAt.UTC().Format(time.RFC3339),
+		Date:        a.PublishedAt.UTC().Format("January 2, 2006"),
+	}
+	if page.Description == "" {
+		page.Description = MarkdownExcerpt(a.Content, articleDescriptionLength)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := tmpl.Execute(w, page); err != nil {
+		h.logger.Error("Failed to execute article template", zap.Error(err))
+	}
+}
+
+// isHTTPURL reports whether s is an absolute http or https URL.
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Host != "" && (strings.EqualFold(u.Scheme, "https") || strings.EqualFold(u.Scheme, "http"))
+}
@@ -0,0 +1,28 @@
+package web
+
+import (
+	"io/fs"
+	"os"
+
+	assets "github.com/Shugur-Network/relay/web"
+	"go.uber.org/zap"
+)
+
+// loadAssets returns the dashboard assets: the override directory when it
+// holds templates/ and static/, otherwise the copy embedded in the binary.
+func loadAssets(dir string, logger *zap.Logger) fs.FS {
+	if dir == "" {
+		return assets.FS
+	}
+	override := os.DirFS(dir)
+	for _, sub := range []string{"templates", "static"} {
+		if info, err := fs.Stat(override, sub); err != nil || !info.IsDir() {
+			logger.Warn("Dashboard assets directory is incomplete, using embedded assets",
+				zap.String("dir", dir),
+				zap.String("missing", sub))
+			return assets.FS
+		}
+	}
+	logger.Info("Serving dashboard assets from directory", zap.String("dir", dir))
+	return override
+}
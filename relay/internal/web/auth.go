@@ -0,0 +1,393 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/clientip"
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/relay/nips"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Dashboard roles, in increasing order of privilege.
+const (
+	RoleNone   = ""
+	RoleViewer = "viewer"
+	RoleAdmin  = "admin"
+)
+
+const (
+	sessionCookie = "relay_session"
+	// maxSessions bounds the login sessions kept in memory.
+	maxSessions = 10000
+	// maxAuthLimiters bounds the per-IP limiters for unauthenticated requests.
+	maxAuthLimiters = 10000
+	// maxUsedLoginEvents bounds the NIP-98 login events remembered as used.
+	maxUsedLoginEvents = 10000
+)
+
+type webSession struct {
+	pubkey  string
+	role    string
+	expires time.Time
+}
+
+// Authenticator gates the dashboard and /api/* endpoints by role.
+type Authenticator struct {
+	cfg     config.WebAuthConfig
+	admins  map[string]bool
+	viewers map[string]bool
+
+	mu         sync.Mutex
+	sessions   map[string]webSession
+	limiters   map[string]*rate.Limiter
+	usedLogins map[string]time.Time // NIP-98 login event ID -> end of its time window
+	limit      rate.Limit
+	burst      int
+}
+
+// NewAuthenticator creates an authenticator from the web and relay configuration.
+func NewAuthenticator(cfg *config.Config) *Authenticator {
+	ac := cfg.Web.Auth
+	a := &Authenticator{
+		cfg:        ac,
+		admins:     make(map[string]bool),
+		viewers:    make(map[string]bool),
+		sessions:   make(map[string]webSession),
+		limiters:   make(map[string]*rate.Limiter),
+		usedLogins: make(map[string]time.Time),
+		limit:      rate.Inf,
+		burst:      max(ac.UnauthBurst, 1),
+	}
+	if ac.UnauthRequestsPerMinute > 0 {
+		a.limit = rate.Limit(float64(ac.UnauthRequestsPerMinute) / 60)
+	}
+	if cfg.Relay.PublicKey != "" {
+		a.admins[strings.ToLower(cfg.Relay.PublicKey)] = true
+	}
+	for _, pk := range append(append([]string{}, cfg.Relay.AdminPubkeys...), ac.AdminPubkeys...) {
+		a.admins[strings.ToLower(pk)] = true
+	}
+	for _, pk := range ac.ViewerPubkeys {
+		a.viewers[strings.ToLower(pk)] = true
+	}
+	return a
+}
+
+// Enabled reports whether dashboard authentication is on.
+func (a *Authenticator) Enabled() bool {
+	return a.cfg.Enabled
+}
+
+// roleForPubkey returns the role a pubkey may log in with.
+func (a *Authenticator) roleForPubkey(pubkey string) string {
+	pubkey = strings.ToLower(pubkey)
+	switch {
+	case a.admins[pubkey]:
+		return RoleAdmin
+	case a.viewers[pubkey]:
+		return RoleViewer
+	}
+	return RoleNone
+}
+
+// RequestRole returns the role granted by a request's bearer token or session cookie.
+func (a *Authenticator) RequestRole(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token := []byte(strings.TrimPrefix(auth, "Bearer "))
+		for _, t := range a.cfg.Tokens {
+			if subtle.ConstantTimeCompare(token, []byte(t.Token)) == 1 {
+				return t.Role
+			}
+		}
+		return RoleNone
+	}
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return RoleNone
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[cookie.Value]
+	if !ok {
+		return RoleNone
+	}
+	if time.Now().After(s.expires) {
+		delete(a.sessions, cookie.Value)
+		return RoleNone
+	}
+	return s.role
+}
+
+// requiredRole returns the role needed for a request.
+func (a *Authenticator) requiredRole(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return RoleAdmin
+	}
+	if a.cfg.PublicRead {
+		return RoleNone
+	}
+	return RoleViewer
+}
+
+// allowUnauthenticated applies the per-IP limit for requests without a role.
+func (a *Authenticator) allowUnauthenticated(r *http.Request) bool {
+	if a.limit == rate.Inf {
+		return true
+	}
+	ip := requestClientIP(r)
+	a.mu.Lock()
+	limiter := a.limiters[ip]
+	if limiter == nil {
+		if len(a.limiters) >= maxAuthLimiters {
+			a.limiters = make(map[string]*rate.Limiter)
+		}
+		limiter = rate.NewLimiter(a.limit, a.burst)
+		a.limiters[ip] = limiter
+	}
+	a.mu.Unlock()
+	return limiter.Allow()
+}
+
+// Authorize checks a dashboard or API request against its required role,
+// writing the error response and returning false when it is refused.
+// Login endpoints are only rate limited.
+func (a *Authenticator) Authorize(w http.ResponseWriter, r *http.Request) bool {
+	if !a.cfg.Enabled {
+		return true
+	}
+
+	role := a.RequestRole(r)
+	if role == RoleNone && !a.allowUnauthenticated(r) {
+		w.Header().Set("Retry-After", "60")
+		errors.HandleHTTPError(w, r, errors.RateLimitError("unauthenticated dashboard access"))
+		return false
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/auth/") {
+		return true
+	}
+
+	switch required := a.requiredRole(r); {
+	case required == RoleNone, role == RoleAdmin, required == RoleViewer && role == RoleViewer:
+		return true
+	case role != RoleNone:
+		errors.HandleHTTPError(w, r, errors.AuthorizationError(r.Method+" "+r.URL.Path,
+			"requires the "+required+" role"))
+		return false
+	case r.URL.Path == "/":
+		// Browsers are sent to the login page
+		http.Redirect(w, r, "/static/login.html", http.StatusFound)
+		return false
+	default:
+		errors.HandleHTTPError(w, r, errors.AuthenticationError("log in or provide a bearer token"))
+		return false
+	}
+}
+
+// Authorize gates a dashboard or API request; see Authenticator.Authorize.
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) bool {
+	return h.auth.Authorize(w, r)
+}
+
+// HandleLogin exchanges a NIP-98 signed POST for a session cookie:
+// POST /api/auth/login with "Authorization: Nostr <base64 kind 27235 event>".
+func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	a := h.auth
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.Enabled() {
+		errors.HandleHTTPError(w, r, errors.NotFoundError("dashboard login").
+			WithUserMessage("Dashboard authentication is disabled on this relay."))
+		return
+	}
+
+	evt, err := nips.ValidateHTTPAuthEvent(r.Header.Get("Authorization"), requestURL(r), http.MethodPost, nil)
+	if err == nil && !a.claimLoginEvent(evt) {
+		err = fmt.Errorf("auth event already used")
+	}
+	if err != nil {
+		h.logger.Warn("Dashboard login failed",
+			zap.String("error", err.Error()),
+			zap.String("client_ip", requestClientIP(r)))
+		errors.HandleHTTPError(w, r, errors.AuthenticationError(err.Error()).
+			WithUserMessage("Login failed."))
+		return
+	}
+	pubkey := evt.PubKey
+	role := a.roleForPubkey(pubkey)
+	if role == RoleNone {
+		errors.HandleHTTPError(w, r, errors.AuthorizationError("dashboard login",
+			"pubkey is not allowed to use the dashboard").
+			WithUserMessage("This pubkey is not allowed to log in."))
+		return
+	}
+
+	token, expires, err := a.createSession(pubkey, role)
+	if err != nil {
+		h.logger.Error("Failed to create dashboard session", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   requestScheme(r) == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	h.logger.Info("Dashboard login",
+		zap.String("pubkey", pubkey[:16]+"..."),
+		zap.String("role", role))
+
+	writeSessionJSON(w, pubkey, role, expires)
+}
+
+// HandleLogout ends the current session: POST /api/auth/logout.
+func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		h.auth.mu.Lock()
+		delete(h.auth.sessions, cookie.Value)
+		h.auth.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSession reports the current role: GET /api/auth/session.
+func (h *Handler) HandleSession(w http.ResponseWriter, r *http.Request) {
+	a := h.auth
+	pubkey, role, expires := "", a.RequestRole(r), time.Time{}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		a.mu.Lock()
+		if s, ok := a.sessions[cookie.Value]; ok {
+			pubkey, expires = s.pubkey, s.expires
+		}
+		a.mu.Unlock()
+	}
+	if !a.Enabled() {
+		role = RoleAdmin
+	}
+	writeSessionJSON(w, pubkey, role, expires)
+}
+
+// claimLoginEvent records a login event as used and reports false when it
+// was used before, so a captured Authorization header cannot be replayed.
+// Events are remembered until they leave the NIP-98 time window, after
+// which validation refuses them anyway.
+func (a *Authenticator) claimLoginEvent(evt *nostr.Event) bool {
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, used := a.usedLogins[evt.ID]; used {
+		return false
+	}
+	if len(a.usedLogins) >= maxUsedLoginEvents {
+		for id, until := range a.usedLogins {
+			if now.After(until) {
+				delete(a.usedLogins, id)
+			}
+		}
+		if len(a.usedLogins) >= maxUsedLoginEvents {
+			return false
+		}
+	}
+	a.usedLogins[evt.ID] = evt.CreatedAt.Time().Add(nips.HTTPAuthWindow + time.Second)
+	return true
+}
+
+// createSession stores a new session and returns its token.
+func (a *Authenticator) createSession(pubkey, role string) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(b)
+
+	ttl := a.cfg.SessionTTL
+	if ttl <= 0 {
+		ttl = 12 * time.Hour
+	}
+	now := time.Now()
+	expires := now.Add(ttl)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.sessions) >= maxSessions {
+		for t, s := range a.sessions {
+			if now.After(s.expires) {
+				delete(a.sessions, t)
+			}
+		}
+		if len(a.sessions) >= maxSessions {
+			return "", time.Time{}, fmt.Errorf("too many active sessions")
+		}
+	}
+	a.sessions[token] = webSession{pubkey: strings.ToLower(pubkey), role: role, expires: expires}
+	return token, expires, nil
+}
+
+func writeSessionJSON(w http.ResponseWriter, pubkey, role string, expires time.Time) {
+	response := struct {
+		Authenticated bool       `json:"authenticated"`
+		Pubkey        string     `json:"pubkey,omitempty"`
+		Role          string     `json:"role"`
+		ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	}{
+		Authenticated: role != RoleNone,
+		Pubkey:        pubkey,
+		Role:          role,
+	}
+	if !expires.IsZero() {
+		response.ExpiresAt = &expires
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// requestScheme returns the scheme the client used, honoring X-Forwarded-Proto
+// only from trusted proxies.
+func requestScheme(r *http.Request) string {
+	return clientip.Scheme(r)
+}
+
+// requestURL returns the absolute URL of a request as the client sees it (for NIP-98 "u" tags).
+func requestURL(r *http.Request) string {
+	return requestScheme(r) + "://" + r.Host + r.URL.Path
+}
+
+// requestClientIP returns the client IP, honouring proxy headers only from
+// trusted proxies.
+func requestClientIP(r *http.Request) string {
+	return clientip.FromRequest(r)
+}
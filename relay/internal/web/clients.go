@@ -0,0 +1,91 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/clientstats"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	clientsDefaultLimit = 50
+	clientsMaxLimit     = 1000
+)
+
+// SetClientStats sets the client telemetry tracker served at /api/clients;
+// nil when client telemetry is disabled.
+func (h *Handler) SetClientStats(t *clientstats.Tracker) {
+	h.clientStats = t
+}
+
+// HandleClientsAPI serves connections, commands and published kinds per
+// client software, and the NIPs they use:
+// GET /api/clients?limit=<n>
+func (h *Handler) HandleClientsAPI(w http.ResponseWriter, r *http.Request) {
+	// Apply security headers for API endpoints
+	apiHeaders := APISecurityHeaders()
+	apiHeaders.Apply(w)
+
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// Handle preflight requests
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Only allow GET requests
+	if r.Method != "GET" {
+		methodErr := errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed.")
+		errors.HandleHTTPError(w, r, methodErr)
+		return
+	}
+
+	limit := clientsDefaultLimit
+	if l := SanitizeQueryParam(r.URL.Query().Get("limit")); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > clientsMaxLimit {
+			validationErr := errors.ValidationError("INVALID_LIMIT_PARAMETER",
+				"limit parameter must be between 1 and 1000").
+				WithUserMessage("Invalid limit parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		limit = n
+	}
+
+	if h.clientStats == nil {
+		notFoundErr := errors.NotFoundError("Client telemetry").
+			WithUserMessage("Client telemetry is disabled on this relay.")
+		errors.HandleHTTPError(w, r, notFoundErr)
+		return
+	}
+
+	summary := h.clientStats.Summary()
+	total := len(summary.Clients)
+	if len(summary.Clients) > limit {
+		summary.Clients = summary.Clients[:limit]
+	}
+	response := struct {
+		clientstats.Summary
+		Total int `json:"total"` // distinct clients, before the limit
+	}{
+		Summary: summary,
+		Total:   total,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode clients response", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
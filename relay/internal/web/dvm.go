@@ -0,0 +1,122 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	dvmDefaultLimit = 50
+	dvmMaxLimit     = 500
+)
+
+// HandleDVMJobsAPI serves tracked NIP-90 jobs with status aggregation:
+// GET /api/dvm/jobs?status=<status>&kind=<5000-5999>&customer=<hex>&limit=<n>
+func (h *Handler) HandleDVMJobsAPI(w http.ResponseWriter, r *http.Request) {
+	// Apply security headers for API endpoints
+	apiHeaders := APISecurityHeaders()
+	apiHeaders.Apply(w)
+
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// Handle preflight requests
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Only allow GET requests
+	if r.Method != "GET" {
+		methodErr := errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed.")
+		errors.HandleHTTPError(w, r, methodErr)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := storage.DVMJobFilter{
+		Status: SanitizeQueryParam(query.Get("status")),
+		Limit:  dvmDefaultLimit,
+	}
+
+	if kind := SanitizeQueryParam(query.Get("kind")); kind != "" {
+		k, err := strconv.Atoi(kind)
+		if err != nil || k < 5000 || k > 5999 {
+			validationErr := errors.ValidationError("INVALID_KIND_PARAMETER",
+				"kind parameter must be a NIP-90 job request kind (5000-5999)").
+				WithUserMessage("Invalid kind parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		filter.Kind = k
+	}
+
+	if customer := strings.ToLower(SanitizeQueryParam(query.Get("customer"))); customer != "" {
+		if !isHexPubkey(customer) {
+			validationErr := errors.ValidationError("INVALID_CUSTOMER_PARAMETER",
+				"customer parameter must be a 64-character hex public key").
+				WithUserMessage("Invalid customer parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		filter.Customer = customer
+	}
+
+	if limit := SanitizeQueryParam(query.Get("limit")); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > dvmMaxLimit {
+			validationErr := errors.ValidationError("INVALID_LIMIT_PARAMETER",
+				"limit parameter must be between 1 and 500").
+				WithUserMessage("Invalid limit parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		filter.Limit = n
+	}
+
+	var tracker *storage.DVMTracker
+	if h.db != nil {
+		tracker = h.db.DVMTracker()
+	}
+	if tracker == nil {
+		notFoundErr := errors.NotFoundError("DVM job tracking").
+			WithUserMessage("DVM job tracking is disabled on this relay.")
+		errors.HandleHTTPError(w, r, notFoundErr)
+		return
+	}
+
+	byStatus, byKind := tracker.StatusCounts()
+	response := struct {
+		Total    int                       `json:"total"`
+		ByStatus map[string]int            `json:"by_status"`
+		ByKind   map[string]map[string]int `json:"by_kind"`
+		Jobs     []storage.DVMJob          `json:"jobs"`
+	}{
+		ByStatus: byStatus,
+		ByKind:   make(map[string]map[string]int, len(byKind)),
+		Jobs:     tracker.ListJobs(filter),
+	}
+	for _, n := range byStatus {
+		response.Total += n
+	}
+	for kind, counts := range byKind {
+		response.ByKind[strconv.Itoa(kind)] = counts
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode DVM jobs response", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
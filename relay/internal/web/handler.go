@@ -5,18 +5,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
+	"github.com/Shugur-Network/relay/internal/anomaly"
+	"github.com/Shugur-Network/relay/internal/clientstats"
 	"github.com/Shugur-Network/relay/internal/config"
 	"github.com/Shugur-Network/relay/internal/constants"
 	"github.com/Shugur-Network/relay/internal/errors"
 	"github.com/Shugur-Network/relay/internal/identity"
 	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/nip05"
+	"github.com/Shugur-Network/relay/internal/opentimestamps"
+	"github.com/Shugur-Network/relay/internal/relay/nips/registry"
+	"github.com/Shugur-Network/relay/internal/spamcluster"
 	"github.com/Shugur-Network/relay/internal/storage"
 	"go.uber.org/zap"
 )
@@ -65,6 +73,10 @@ type StatsData struct {
 	ErrorRate            float64          `json:"error_rate"`
 	MemoryUsage          map[string]int64 `json:"memory_usage"`
 	LoadPercentage       float64          `json:"load_percentage"`
+
+	TopStorage    []storage.PubkeyStorage         `json:"top_storage,omitempty"`    // pubkeys storing the most bytes
+	CodeLanguages map[string]int64                `json:"code_languages,omitempty"` // accepted NIP-C0 snippets per language
+	QueryLatency  map[string]metrics.QueryLatency `json:"query_latency,omitempty"`  // REQ to EOSE percentiles per filter shape
 }
 
 // Handler provides HTTP handlers for the web dashboard
@@ -73,11 +85,22 @@ type Handler struct {
 	logger    *zap.Logger
 	startTime time.Time
 	liveSince time.Time
+	auth      *Authenticator
+	assets    fs.FS // templates/ and static/
 	db        interface {
 		GetTotalEventCount(ctx context.Context) (int64, error)
+		TopStorageConsumers(ctx context.Context, limit int) ([]storage.PubkeyStorage, error)
 		GetDatabaseInfo(ctx context.Context) (*storage.DatabaseInfo, error)
 		GetClusterHealth(ctx context.Context) (map[string]interface{}, error)
+		GetRelayHints(ctx context.Context, pubkey string) ([]storage.RelayHint, error)
+		DVMTracker() *storage.DVMTracker
+		ReportTracker() *storage.ReportTracker
 	} // Database interface
+	spamClusters   *spamcluster.Detector    // nil when spam clustering is disabled
+	clientStats    *clientstats.Tracker     // nil when client telemetry is disabled
+	anomalies      *anomaly.Detector        // nil when anomaly detection is disabled
+	nip05          *nip05.Resolver          // nil when NIP-05 verification is disabled
+	openTimestamps *opentimestamps.Verifier // nil when NIP-03 verification is disabled
 }
 
 // NewHandler creates a new web handler
@@ -87,6 +110,8 @@ func NewHandler(cfg *config.Config, logger *zap.Logger, node interface{}) *Handl
 		logger:    logger,
 		startTime: time.Now(),
 		liveSince: loadFirstBootTime(),
+		auth:      NewAuthenticator(cfg),
+		assets:    loadAssets(cfg.Web.AssetsDir, logger),
 	}
 
 	// Set database interface if node provides it
@@ -106,7 +131,6 @@ func (h *Handler) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 	dashboardHeaders.Apply(w)
 	
 	// Load template with custom functions
-	tmplPath := filepath.Join("web", "templates", "index.html")
 	funcMap := template.FuncMap{
 		"formatNIP": func(v interface{}) string {
 			switch val := v.(type) {
@@ -128,77 +152,10 @@ func (h *Handler) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 			default:
 				nip = fmt.Sprintf("%v", val)
 			}
-			descriptions := map[string]string{
-				"01": "Basic Protocol",
-				"02": "Follow List",
-				"03": "OpenTimestamps",
-				"09": "Event Deletion",
-				"11": "Relay Info",
-				"13": "Proof of Work",
-				"15": "Marketplace",
-				"17": "Private DMs",
-				"18": "Reposts",
-				"22": "Comment",
-				"23": "Long-form Content",
-				"24": "Extra Metadata",
-				"25": "Reactions",
-				"28": "Public Chat",
-				"29": "Relay Groups",
-				"30": "Custom Emoji",
-				"32": "Labeling",
-				"34": "Git Stuff",
-				"35": "Torrents",
-				"37": "Draft Wraps",
-				"38": "User Statuses",
-				"40": "Expiration",
-				"42": "Authentication",
-				"44": "Encrypted Payloads",
-				"45": "Event Counts",
-				"47": "Wallet Connect",
-				"50": "Search",
-				"51": "Lists",
-				"52": "Calendar Events",
-				"53": "Live Activities",
-				"54": "Wiki",
-				"56": "Reporting",
-				"57": "Lightning Zaps",
-				"58": "Badges",
-				"59": "Gift Wrap",
-				"60": "Cashu Wallets",
-				"61": "Nutzaps",
-				"62": "Request to Vanish",
-				"65": "Relay List Metadata",
-				"69": "P2P Orders",
-				"70": "Protected Events",
-				"71": "Video Events",
-				"72": "Communities",
-				"75": "Zap Goals",
-				"77": "Negentropy Sync",
-				"78": "App-specific Data",
-				"84": "Highlights",
-				"85": "Trusted Assertions",
-				"86": "Management API",
-				"87": "Ecash Mint Discovery",
-				"88": "Polls",
-				"89": "App Handlers",
-				"90": "Data Vending Machine",
-				"94": "File Metadata",
-				"99": "Classified Listings",
-				"7D": "Threads",
-				"A0": "Voice Messages",
-				"A4": "Public Messages",
-				"B0": "Web Bookmarking",
-				"C0": "Code Snippets",
-				"C7": "Chats",
-				"B7": "Blossom Server List",
-			}
-			if desc, ok := descriptions[nip]; ok {
-				return desc
-			}
-			return ""
+			return registry.Name(nip)
 		},
 	}
-	tmpl, err := template.New("index.html").Funcs(funcMap).ParseFiles(tmplPath)
+	tmpl, err := template.New("index.html").Funcs(funcMap).ParseFS(h.assets, "templates/index.html")
 	if err != nil {
 		h.logger.Error("Failed to parse dashboard template", zap.Error(err))
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -223,8 +180,6 @@ func (h *Handler) HandleStatic(w http.ResponseWriter, r *http.Request) {
 	staticHeaders.Apply(w)
 	
 	// Serve static files safely, preventing path traversal
-	root := filepath.Join("web", "static")
-
 	// Extract and validate the requested path
 	requestedPath := strings.TrimPrefix(r.URL.Path, "/static/")
 	
@@ -240,8 +195,8 @@ func (h *Handler) HandleStatic(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Join and ensure the resolved path remains within the static root
-	fullPath := filepath.Join(root, sanitizedPath)
-	if rel, err := filepath.Rel(root, fullPath); err != nil || strings.HasPrefix(rel, "..") {
+	fullPath := path.Join("static", filepath.ToSlash(sanitizedPath))
+	if !fs.ValidPath(fullPath) || !strings.HasPrefix(fullPath, "static/") {
 		h.logger.Warn("Path traversal attempt detected",
 			zap.String("requested_path", requestedPath),
 			zap.String("sanitized_path", sanitizedPath),
@@ -255,7 +210,7 @@ func (h *Handler) HandleStatic(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("Cache-Control", "public, max-age=3600, immutable")
 
-	http.ServeFile(w, r, fullPath)
+	http.ServeFileFS(w, r, h.assets, fullPath)
 }
 
 // HandleStatsAPI serves the stats API endpoint
@@ -409,7 +364,7 @@ func (h *Handler) getDashboardData(host string) *DashboardData {
 		Pubkey:        metadata.PubKey,
 		RelayID:       relayID,
 		SupportedNIPs: metadata.SupportedNIPs,
-		CustomNIPs:    constants.DefaultCustomNIPs,
+		CustomNIPs:    constants.DefaultCustomNIPs(),
 		Limitation: &LimitationData{
 			MaxMessageLength: metadata.Limitation.MaxMessageLength,
 			MaxSubscriptions: metadata.Limitation.MaxSubscriptions,
@@ -427,6 +382,7 @@ func (h *Handler) getDashboardData(host string) *DashboardData {
 // getStatsData retrieves current statistics
 func (h *Handler) getStatsData() *StatsData {
 	var eventsStored int64
+	var topStorage []storage.PubkeyStorage
 
 	// Get events stored from database if available
 	if h.db != nil {
@@ -443,6 +399,11 @@ func (h *Handler) getStatsData() *StatsData {
 
 		// Update the metrics gauge with current count
 		metrics.EventsStored.Set(float64(eventsStored))
+
+		topStorage, err = h.db.TopStorageConsumers(ctx, topStorageConsumers)
+		if err != nil {
+			h.logger.Warn("Failed to get top storage consumers", zap.Error(err))
+		}
 	}
 
 	// Get memory usage
@@ -474,11 +435,17 @@ func (h *Handler) getStatsData() *StatsData {
 		ErrorRate:            metrics.GetErrorRate(),
 		MemoryUsage:          memUsage,
 		LoadPercentage:       loadPercentage,
+		TopStorage:           topStorage,
+		CodeLanguages:        metrics.GetCodeSnippetLanguages(),
+		QueryLatency:         metrics.GetQueryLatency(),
 	}
 
 	return stats
 }
 
+// topStorageConsumers is how many pubkeys /api/stats lists by stored bytes.
+const topStorageConsumers = 10
+
 // getClusterData retrieves database information
 func (h *Handler) getClusterData() *storage.DatabaseInfo {
 	if h.db == nil {
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	liveInterval     = time.Second
+	liveWriteTimeout = 5 * time.Second
+	// maxLiveClients bounds concurrent /api/live streams.
+	maxLiveClients = 50
+)
+
+// liveClients counts open /api/live streams.
+var liveClients atomic.Int64
+
+var liveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 16 * 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true }, // same data as the public /api/stats
+}
+
+// HandleLiveAPI streams a metrics.LiveSnapshot over a WebSocket every second:
+// per-kind ingestion rates, rejection reasons, top talkers and queue depths.
+func (h *Handler) HandleLiveAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		methodErr := errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed.")
+		errors.HandleHTTPError(w, r, methodErr)
+		return
+	}
+
+	if liveClients.Add(1) > maxLiveClients {
+		liveClients.Add(-1)
+		http.Error(w, "Too many live dashboard clients", http.StatusServiceUnavailable)
+		return
+	}
+	defer liveClients.Add(-1)
+
+	conn, err := liveUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.logger.Debug("Live dashboard upgrade failed", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	// The stream is one-way; reading only detects the client going away.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(512)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(liveInterval)
+	defer ticker.Stop()
+	for {
+		data, err := json.Marshal(metrics.GetLiveSnapshot())
+		if err != nil {
+			h.logger.Error("Failed to encode live snapshot", zap.Error(err))
+			return
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return
+		}
+
+		select {
+		case <-done:
+			return
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
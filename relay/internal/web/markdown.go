@@ -0,0 +1,458 @@
+package web
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A small Markdown renderer for NIP-23 articles. It covers the subset
+// long-form clients write (headings, paragraphs, lists, quotes, fenced code,
+// rules, emphasis, code spans, links and images) and is safe by construction:
+// all text is HTML-escaped, raw HTML in the source shows up as text, and
+// links and images only keep URLs with an allowlisted scheme.
+
+const (
+	// maxMarkdownDepth bounds nested blockquotes and inline spans.
+	maxMarkdownDepth = 8
+
+	// maxInlineSpan bounds how far a delimiter looks for its closer, which
+	// keeps rendering linear on adversarial input.
+	maxInlineSpan = 1024
+)
+
+var (
+	mdHeading     = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+	mdRule        = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdListItem    = regexp.MustCompile(`^ {0,3}([-*+]|\d{1,9}[.)])[ \t]+(.*)$`)
+	mdFenceLang   = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,32}$`)
+	mdLinkSchemes = map[string]bool{"http": true, "https": true, "mailto": true, "nostr": true}
+	mdImgSchemes  = map[string]bool{"http": true, "https": true}
+)
+
+// RenderMarkdown renders article markdown as sanitized HTML.
+func RenderMarkdown(src string) template.HTML {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), 0)
+	return template.HTML(b.String()) // #nosec G203 -- every piece of text is escaped
+}
+
+// renderBlocks renders lines as block elements.
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>")
+			renderInline(b, strings.Join(para, "\n"), 0)
+			b.WriteString("</p>\n")
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fence := trimmed[:3]
+			lang := strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1]))
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			if mdFenceLang.MatchString(lang) {
+				b.WriteString(`<pre><code class="language-` + html.EscapeString(lang) + `">`)
+			} else {
+				b.WriteString("<pre><code>")
+			}
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			b.WriteString("</code></pre>\n")
+
+		case mdHeading.MatchString(trimmed):
+			flush()
+			m := mdHeading.FindStringSubmatch(trimmed)
+			tag := "h" + string(rune('0'+len(m[1])))
+			b.WriteString("<" + tag + ">")
+			renderInline(b, m[2], 0)
+			b.WriteString("</" + tag + ">\n")
+
+		case mdRule.MatchString(line):
+			flush()
+			b.WriteString("<hr>\n")
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quoted []string
+			for ; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if !strings.HasPrefix(t, ">") {
+					i--
+					break
+				}
+				t = strings.TrimPrefix(t, ">")
+				quoted = append(quoted, strings.TrimPrefix(t, " "))
+			}
+			b.WriteString("<blockquote>\n")
+			if depth < maxMarkdownDepth {
+				renderBlocks(b, quoted, depth+1)
+			} else {
+				b.WriteString("<p>")
+				renderInline(b, strings.Join(quoted, "\n"), 0)
+				b.WriteString("</p>\n")
+			}
+			b.WriteString("</blockquote>\n")
+
+		case mdListItem.MatchString(line):
+			flush()
+			i = renderList(b, lines, i) - 1
+
+		default:
+			para = append(para, line)
+		}
+	}
+	flush()
+}
+
+// renderList renders the list starting at lines[start] and returns the index
+// of the first line after it. Indented lines continue the previous item.
+func renderList(b *strings.Builder, lines []string, start int) int {
+	ordered := !strings.ContainsAny(mdListItem.FindStringSubmatch(lines[start])[1][:1], "-*+")
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag + ">\n")
+
+	var item []string
+	flush := func() {
+		if item != nil {
+			b.WriteString("<li>")
+			renderInline(b, strings.Join(item, "\n"), 0)
+			b.WriteString("</li>\n")
+		}
+		item = nil
+	}
+
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if m := mdListItem.FindStringSubmatch(line); m != nil {
+			if strings.ContainsAny(m[1][:1], "-*+") == ordered {
+				break // a list of the other type starts
+			}
+			flush()
+			item = []string{m[2]}
+			continue
+		}
+		if strings.TrimSpace(line) == "" || !(strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			break
+		}
+		item = append(item, strings.TrimSpace(line))
+	}
+	flush()
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// renderInline renders the inline markup of s.
+func renderInline(b *strings.Builder, s string, depth int) {
+	text := 0 // start of the pending plain text
+	emit := func(end int) {
+		if end > text {
+			b.WriteString(html.EscapeString(s[text:end]))
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			emit(i)
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			text = i
+			continue
+
+		case c == '\n' && i >= 2 && s[i-2:i] == "  ":
+			emit(i)
+			b.WriteString("<br>\n")
+			i++
+			text = i
+			continue
+
+		case c == '`':
+			n := runLength(s, i, '`')
+			if end := findRun(s, i+n, "`", n); end >= 0 {
+				emit(i)
+				b.WriteString("<code>")
+				b.WriteString(html.EscapeString(strings.TrimSpace(s[i+n : end])))
+				b.WriteString("</code>")
+				i = end + n
+				text = i
+				continue
+			}
+			i += n
+			continue
+
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if label, dest, end, ok := parseLink(s, i+1); ok {
+				emit(i)
+				if u, ok := safeURL(dest, mdImgSchemes); ok {
+					b.WriteString(`<img src="` + html.EscapeString(u) + `" alt="` +
+						html.EscapeString(label) + `" loading="lazy">`)
+				} else {
+					b.WriteString(html.EscapeString(label))
+				}
+				i = end
+				text = i
+				continue
+			}
+
+		case c == '[':
+			if label, dest, end, ok := parseLink(s, i); ok {
+				emit(i)
+				if u, ok := safeURL(dest, mdLinkSchemes); ok {
+					writeLinkOpen(b, u)
+					renderInlineNested(b, label, depth)
+					b.WriteString("</a>")
+				} else {
+					renderInlineNested(b, label, depth)
+				}
+				i = end
+				text = i
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(s[i:min(len(s), i+maxInlineSpan)], '>'); end > 0 {
+				if u, ok := safeURL(s[i+1:i+end], mdLinkSchemes); ok && !strings.ContainsAny(u, " \t\n") {
+					emit(i)
+					writeLinkOpen(b, u)
+					b.WriteString(html.EscapeString(u) + "</a>")
+					i += end + 1
+					text = i
+					continue
+				}
+			}
+
+		case c == 'h' && (strings.HasPrefix(s[i:], "http://") || strings.HasPrefix(s[i:], "https://")) && !wordBefore(s, i):
+			end, limit := i, min(len(s), i+maxInlineSpan)
+			for end < limit && !unicode.IsSpace(rune(s[end])) && s[end] != '<' {
+				end++
+			}
+			end = i + len(strings.TrimRight(s[i:end], ".,;:!?)'\""))
+			if u, ok := safeURL(s[i:end], mdLinkSchemes); ok {
+				emit(i)
+				writeLinkOpen(b, u)
+				b.WriteString(html.EscapeString(s[i:end]) + "</a>")
+				i = end
+				text = i
+				continue
+			}
+
+		case (c == '*' || c == '_' || c == '~') && depth < maxMarkdownDepth:
+			n := runLength(s, i, c)
+			if c == '~' && n != 2 || n > 3 || c == '_' && wordBefore(s, i) ||
+				i+n >= len(s) || unicode.IsSpace(rune(s[i+n])) {
+				i += n
+				continue
+			}
+			end := findRun(s, i+n, string(c), n)
+			if end < 0 || unicode.IsSpace(rune(s[end-1])) {
+				i += n
+				continue
+			}
+			emit(i)
+			open, close := "<em>", "</em>"
+			switch {
+			case c == '~':
+				open, close = "<del>", "</del>"
+			case n == 2:
+				open, close = "<strong>", "</strong>"
+			case n == 3:
+				open, close = "<strong><em>", "</em></strong>"
+			}
+			b.WriteString(open)
+			renderInline(b, s[i+n:end], depth+1)
+			b.WriteString(close)
+			i = end + n
+			text = i
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	emit(len(s))
+}
+
+// renderInlineNested renders link text one level deeper.
+func renderInlineNested(b *strings.Builder, s string, depth int) {
+	if depth >= maxMarkdownDepth {
+		b.WriteString(html.EscapeString(s))
+		return
+	}
+	renderInline(b, s, depth+1)
+}
+
+// writeLinkOpen writes the opening tag of an outbound link to u.
+func writeLinkOpen(b *strings.Builder, u string) {
+	b.WriteString(`<a href="` + html.EscapeString(u) + `" rel="nofollow noopener noreferrer">`)
+}
+
+// parseLink parses [label](dest "title") at s[start] and returns the index
+// after it.
+func parseLink(s string, start int) (label, dest string, end int, ok bool) {
+	limit := min(len(s), start+maxInlineSpan)
+	nesting := 0
+	closeBracket := -1
+	for i := start + 1; i < limit && closeBracket < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			nesting++
+		case ']':
+			if nesting == 0 {
+				closeBracket = i
+			}
+			nesting--
+		}
+	}
+	if closeBracket < 0 || closeBracket+1 >= len(s) || s[closeBracket+1] != '(' {
+		return "", "", 0, false
+	}
+	closeParen, parens := -1, 0
+	for i := closeBracket + 2; i < min(len(s), closeBracket+2+maxInlineSpan) && closeParen < 0; i++ {
+		switch s[i] {
+		case '(':
+			parens++
+		case ')':
+			if parens == 0 {
+				closeParen = i
+			}
+			parens--
+		}
+	}
+	if closeParen < 0 {
+		return "", "", 0, false
+	}
+	inner := strings.TrimSpace(s[closeBracket+2 : closeParen])
+	if sp := strings.IndexAny(inner, " \t\n"); sp >= 0 {
+		inner = inner[:sp] // drop the title
+	}
+	inner = strings.TrimSuffix(strings.TrimPrefix(inner, "<"), ">")
+	return s[start+1 : closeBracket], inner, closeParen + 1, true
+}
+
+// safeURL returns raw when it is an absolute URL with one of schemes.
+func safeURL(raw string, schemes map[string]bool) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || !schemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return "", false
+	}
+	return raw, true
+}
+
+// runLength counts the repeats of c at s[i].
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// findRun returns the index of the next run of exactly n delim characters at
+// or after from, within maxInlineSpan, or -1.
+func findRun(s string, from int, delim string, n int) int {
+	limit := min(len(s), from+maxInlineSpan)
+	for i := from; i < limit; {
+		j := strings.Index(s[i:limit], delim)
+		if j < 0 {
+			return -1
+		}
+		j += i
+		run := runLength(s, j, delim[0])
+		if run == n {
+			return j
+		}
+		i = j + run
+	}
+	return -1
+}
+
+// wordBefore reports whether s[i] directly follows a letter or digit.
+func wordBefore(s string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isASCIIPunct reports whether c may be backslash-escaped.
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// MarkdownExcerpt returns the start of the article text as plain text, cut
+// at a word boundary near max bytes, for descriptions and previews.
+func MarkdownExcerpt(src string, max int) string {
+	var words []string
+	size := 0
+	inFence := false
+	for _, line := range strings.Split(src, "\n") {
+		t := strings.TrimSpace(line)
+		if strings.HasPrefix(t, "```") || strings.HasPrefix(t, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || mdHeading.MatchString(t) || mdRule.MatchString(t) {
+			continue
+		}
+		t = mdExcerptImage.ReplaceAllString(t, "")
+		t = mdExcerptLink.ReplaceAllString(t, "$1")
+		t = strings.TrimLeft(t, ">-+* \t")
+		t = strings.NewReplacer("**", "", "__", "", "~~", "", "`", "", "*", "").Replace(t)
+		for _, w := range strings.Fields(t) {
+			if size+len(w) > max {
+				if len(words) == 0 {
+					return truncateRunes(w, max) + "…"
+				}
+				return strings.Join(words, " ") + "…"
+			}
+			words = append(words, w)
+			size += len(w) + 1
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+var (
+	mdExcerptImage = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	mdExcerptLink  = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+)
+
+// truncateRunes cuts s to at most max bytes without splitting a rune.
+func truncateRunes(s string, max int) string {
+	for len(s) > max {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
@@ -79,6 +79,19 @@ func APISecurityHeaders() *SecurityHeaders {
 	}
 }
 
+// ArticleSecurityHeaders returns security headers for rendered NIP-23 articles
+// Article pages carry author-provided content, so no scripts are allowed at all
+func ArticleSecurityHeaders() *SecurityHeaders {
+	return &SecurityHeaders{
+		CSP: "default-src 'none'; " +
+			"style-src 'self'; " +
+			"img-src 'self' https: data:; " + // Article and header images
+			"base-uri 'none'; " +
+			"form-action 'none'; " +
+			"frame-ancestors 'none'",
+	}
+}
+
 // SecurityMiddleware wraps an http.Handler with security headers
 func SecurityMiddleware(headers *SecurityHeaders) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -193,10 +206,27 @@ func APIInputValidation() *InputValidation {
 		regexp.MustCompile(`^/api/stats$`),
 		regexp.MustCompile(`^/api/metrics$`),
 		regexp.MustCompile(`^/api/cluster$`),
+		regexp.MustCompile(`^/api/relay-hints$`),
+		regexp.MustCompile(`^/api/dvm/jobs$`),
+		regexp.MustCompile(`^/api/reports$`),
+		regexp.MustCompile(`^/api/spam-clusters$`),
+		regexp.MustCompile(`^/api/clients$`),
+		regexp.MustCompile(`^/api/anomalies$`),
+		regexp.MustCompile(`^/api/nip05$`),
+		regexp.MustCompile(`^/api/opentimestamps$`),
+		regexp.MustCompile(`^/api/live$`),
+		regexp.MustCompile(`^/api/auth/(login|logout|session)$`),
 	}
 
 	allowedQueryParams := map[string]bool{
-		"type": true, // Only type parameter allowed for APIs
+		"type":     true, // /api/reports
+		"pubkey":   true, // /api/relay-hints
+		"status":   true, // /api/dvm/jobs, /api/reports, /api/spam-clusters, /api/opentimestamps
+		"kind":     true, // /api/dvm/jobs
+		"customer": true, // /api/dvm/jobs
+		"event":    true, // /api/opentimestamps
+		"since":    true, // /api/anomalies
+		"limit":    true, // /api/dvm/jobs, /api/reports, /api/spam-clusters, /api/opentimestamps, /api/clients, /api/anomalies, /api/nip05
 	}
 
 	return &InputValidation{
@@ -208,6 +238,72 @@ func APIInputValidation() *InputValidation {
 	}
 }
 
+// EventsAPIInputValidation returns the validation rules for the HTTP query
+// API and the event stream. Filters may list many ids or authors, so the query string is longer;
+// parameters are checked by the filter parser.
+func EventsAPIInputValidation() *InputValidation {
+	return &InputValidation{
+		MaxPathLength:   160, // group exports name a group ID of up to 128 characters
+		MaxQueryLength:  16384,
+		MaxHeaderLength: 4096,
+		PathPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^/api/events$`),
+			regexp.MustCompile(`^/api/event/[0-9a-fA-F]{64}$`),
+			regexp.MustCompile(`^/api/stream$`),
+			regexp.MustCompile(`^/api/thread/[0-9a-fA-F]{64}$`),
+			regexp.MustCompile(`^/api/graph/(followers|following)$`),
+			regexp.MustCompile(`^/api/marketplace/(products|stalls)$`),
+			regexp.MustCompile(`^/api/classifieds$`),
+			regexp.MustCompile(`^/api/live$`),
+			regexp.MustCompile(`^/api/handlers$`),
+			regexp.MustCompile(`^/api/communities/(queue|approved)$`),
+			regexp.MustCompile(`^/api/labels$`),
+			regexp.MustCompile(`^/api/wiki$`),
+			regexp.MustCompile(`^/api/polls/[0-9a-fA-F]{64}/results$`),
+			regexp.MustCompile(`^/api/profile/([0-9a-fA-F]{64}|npub1[02-9ac-hj-np-z]{58}|nprofile1[02-9ac-hj-np-z]+)$`),
+			regexp.MustCompile(`^/api/groups/[a-z0-9_-]{1,128}/export$`),
+			regexp.MustCompile(`^/api/keypackages/([0-9a-fA-F]{64}|npub1[02-9ac-hj-np-z]{58}|nprofile1[02-9ac-hj-np-z]+)$`),
+			regexp.MustCompile(`^/api/capsules/([0-9a-fA-F]{64}|npub1[02-9ac-hj-np-z]{58}|nprofile1[02-9ac-hj-np-z]+)/unlocks$`),
+		},
+	}
+}
+
+// ArticleInputValidation returns the validation rules for the NIP-23 article
+// pages. The d identifier of /article/<kind>:<pubkey>:<d> is free text, so
+// only its length is bounded here.
+func ArticleInputValidation() *InputValidation {
+	return &InputValidation{
+		MaxPathLength:   1024,
+		MaxQueryLength:  256,
+		MaxHeaderLength: 4096,
+		PathPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^/a/naddr1[02-9ac-hj-np-z]{6,}$`),
+			regexp.MustCompile(`^/article/[0-9]{1,5}:[0-9a-fA-F]{64}:.*$`),
+		},
+	}
+}
+
+// WellKnownInputValidation returns the validation rules for the NIP-05
+// document of the hosted names and the LNURL-pay endpoints of the relay's
+// lightning addresses. A callback carries a whole zap request, so the query
+// string is longer.
+func WellKnownInputValidation() *InputValidation {
+	return &InputValidation{
+		MaxPathLength:   128,
+		MaxQueryLength:  16384,
+		MaxHeaderLength: 4096,
+		AllowedQueryParams: map[string]bool{
+			"name":   true, // nostr.json
+			"amount": true, // LNURL-pay callback
+			"nostr":  true, // LNURL-pay callback, NIP-57 zap request
+		},
+		PathPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^/\.well-known/nostr\.json$`),
+			regexp.MustCompile(`^/\.well-known/lnurlp/[a-zA-Z0-9._-]{1,64}(/callback)?$`),
+		},
+	}
+}
+
 // ValidateRequest validates an HTTP request against the input validation rules
 func (iv *InputValidation) ValidateRequest(r *http.Request) error {
 	// Validate path length
@@ -463,6 +559,24 @@ func SecureValidatedHandlerFunc(handlerFunc http.HandlerFunc) http.HandlerFunc {
 		ValidatedHandlerFunc(DefaultInputValidation(), handlerFunc))
 }
 
+// SecureValidatedEventsAPIHandlerFunc combines security headers with input validation for the HTTP query API
+func SecureValidatedEventsAPIHandlerFunc(handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return SecurityHandlerFunc(APISecurityHeaders(),
+		ValidatedHandlerFunc(EventsAPIInputValidation(), handlerFunc))
+}
+
+// SecureValidatedArticleHandlerFunc combines security headers with input validation for article pages
+func SecureValidatedArticleHandlerFunc(handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return SecurityHandlerFunc(ArticleSecurityHeaders(),
+		ValidatedHandlerFunc(ArticleInputValidation(), handlerFunc))
+}
+
+// SecureValidatedWellKnownHandlerFunc combines security headers with input validation for /.well-known documents
+func SecureValidatedWellKnownHandlerFunc(handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return SecurityHandlerFunc(APISecurityHeaders(),
+		ValidatedHandlerFunc(WellKnownInputValidation(), handlerFunc))
+}
+
 // SecureValidatedAPIHandlerFunc combines security headers with input validation for API handlers
 func SecureValidatedAPIHandlerFunc(handlerFunc http.HandlerFunc) http.HandlerFunc {
 	return SecurityHandlerFunc(APISecurityHeaders(), 
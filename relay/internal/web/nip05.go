@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/nip05"
+	"go.uber.org/zap"
+)
+
+const (
+	nip05DefaultLimit = 50
+	nip05MaxLimit     = 1000
+)
+
+// SetNIP05Resolver sets the NIP-05 resolver served at /api/nip05; nil when
+// NIP-05 verification is disabled.
+func (h *Handler) SetNIP05Resolver(r *nip05.Resolver) {
+	h.nip05 = r
+}
+
+// HandleNIP05API serves the NIP-05 verification state: whether publishing
+// requires a verified identifier, counts of cached lookups and the most
+// recent ones:
+// GET /api/nip05?limit=<n>
+func (h *Handler) HandleNIP05API(w http.ResponseWriter, r *http.Request) {
+	// Apply security headers for API endpoints
+	apiHeaders := APISecurityHeaders()
+	apiHeaders.Apply(w)
+
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// Handle preflight requests
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Only allow GET requests
+	if r.Method != "GET" {
+		methodErr := errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed.")
+		errors.HandleHTTPError(w, r, methodErr)
+		return
+	}
+
+	limit := nip05DefaultLimit
+	if l := SanitizeQueryParam(r.URL.Query().Get("limit")); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > nip05MaxLimit {
+			validationErr := errors.ValidationError("INVALID_LIMIT_PARAMETER",
+				"limit parameter must be between 1 and 1000").
+				WithUserMessage("Invalid limit parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		limit = n
+	}
+
+	if h.nip05 == nil {
+		notFoundErr := errors.NotFoundError("NIP-05 verification").
+			WithUserMessage("NIP-05 verification is disabled on this relay.")
+		errors.HandleHTTPError(w, r, notFoundErr)
+		return
+	}
+
+	response := struct {
+		RequireForWrite bool           `json:"require_for_write"`
+		Stats           nip05.Stats    `json:"stats"`
+		Lookups         []nip05.Lookup `json:"lookups"` // most recently checked first
+	}{
+		RequireForWrite: h.nip05.RequireForWrite(),
+		Stats:           h.nip05.Stats(),
+		Lookups:         h.nip05.Recent(limit),
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode NIP-05 response", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/opentimestamps"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+const (
+	openTimestampsDefaultLimit = 50
+	openTimestampsMaxLimit     = 500
+)
+
+// SetOpenTimestamps sets the NIP-03 verifier served at /api/opentimestamps;
+// nil when verification is disabled.
+func (h *Handler) SetOpenTimestamps(v *opentimestamps.Verifier) {
+	h.openTimestamps = v
+}
+
+// HandleOpenTimestampsAPI serves the verification state of NIP-03 attestations:
+// GET /api/opentimestamps?event=<attested event id>&status=<verified|pending|invalid>&limit=<n>
+func (h *Handler) HandleOpenTimestampsAPI(w http.ResponseWriter, r *http.Request) {
+	// Apply security headers for API endpoints
+	apiHeaders := APISecurityHeaders()
+	apiHeaders.Apply(w)
+
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// Handle preflight requests
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Only allow GET requests
+	if r.Method != "GET" {
+		methodErr := errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed.")
+		errors.HandleHTTPError(w, r, methodErr)
+		return
+	}
+
+	query := r.URL.Query()
+	var filter opentimestamps.Filter
+	if event := strings.ToLower(SanitizeQueryParam(query.Get("event"))); event != "" {
+		if !nostr.IsValid32ByteHex(event) {
+			validationErr := errors.ValidationError("INVALID_EVENT_PARAMETER",
+				"event parameter must be a 64 character hex event id").
+				WithUserMessage("Invalid event parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		filter.EventID = event
+	}
+
+	switch status := SanitizeQueryParam(query.Get("status")); status {
+	case "", opentimestamps.StatusVerified, opentimestamps.StatusPending, opentimestamps.StatusInvalid:
+		filter.Status = status
+	default:
+		validationErr := errors.ValidationError("INVALID_STATUS_PARAMETER",
+			"status parameter must be verified, pending or invalid").
+			WithUserMessage("Invalid status parameter.")
+		errors.HandleHTTPError(w, r, validationErr)
+		return
+	}
+
+	filter.Limit = openTimestampsDefaultLimit
+	if l := SanitizeQueryParam(query.Get("limit")); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > openTimestampsMaxLimit {
+			validationErr := errors.ValidationError("INVALID_LIMIT_PARAMETER",
+				"limit parameter must be between 1 and 500").
+				WithUserMessage("Invalid limit parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		filter.Limit = n
+	}
+
+	if h.openTimestamps == nil {
+		notFoundErr := errors.NotFoundError("OpenTimestamps verification").
+			WithUserMessage("OpenTimestamps verification is disabled on this relay.")
+		errors.HandleHTTPError(w, r, notFoundErr)
+		return
+	}
+
+	byStatus, attestations := h.openTimestamps.List(filter)
+	response := struct {
+		ByStatus     map[string]int               `json:"by_status"`
+		Attestations []opentimestamps.Attestation `json:"attestations"`
+	}{
+		ByStatus:     byStatus,
+		Attestations: attestations,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode OpenTimestamps response", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
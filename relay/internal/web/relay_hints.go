@@ -0,0 +1,110 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/constants"
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"go.uber.org/zap"
+)
+
+// HandleRelayHintsAPI serves the NIP-65 relays recorded for a pubkey:
+// GET /api/relay-hints?pubkey=<hex>
+func (h *Handler) HandleRelayHintsAPI(w http.ResponseWriter, r *http.Request) {
+	// Apply security headers for API endpoints
+	apiHeaders := APISecurityHeaders()
+	apiHeaders.Apply(w)
+
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// Handle preflight requests
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Only allow GET requests
+	if r.Method != "GET" {
+		methodErr := errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed.")
+		errors.HandleHTTPError(w, r, methodErr)
+		return
+	}
+
+	pubkey := strings.ToLower(SanitizeQueryParam(r.URL.Query().Get("pubkey")))
+	if !isHexPubkey(pubkey) {
+		validationErr := errors.ValidationError("INVALID_PUBKEY_PARAMETER",
+			"pubkey parameter must be a 64-character hex public key").
+			WithUserMessage("Invalid pubkey parameter.")
+		errors.HandleHTTPError(w, r, validationErr)
+		return
+	}
+
+	if h.db == nil {
+		dbErr := errors.InternalError("Database not available", nil).
+			WithSeverity(errors.SeverityCritical).
+			WithUserMessage("Database service is temporarily unavailable.")
+		errors.HandleHTTPError(w, r, dbErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), constants.HealthCheckTimeout*time.Second)
+	defer cancel()
+
+	hints, err := h.db.GetRelayHints(ctx, pubkey)
+	if err != nil {
+		dbErr := errors.HandleDatabaseError("relay hints retrieval", err)
+		errors.HandleHTTPError(w, r, dbErr)
+		return
+	}
+
+	response := struct {
+		Pubkey string              `json:"pubkey"`
+		Relays []storage.RelayHint `json:"relays"`
+		Read   []string            `json:"read"`
+		Write  []string            `json:"write"`
+	}{
+		Pubkey: pubkey,
+		Relays: make([]storage.RelayHint, 0, len(hints)),
+		Read:   []string{},
+		Write:  []string{},
+	}
+	for _, hint := range hints {
+		response.Relays = append(response.Relays, hint)
+		if hint.Read {
+			response.Read = append(response.Read, hint.URL)
+		}
+		if hint.Write {
+			response.Write = append(response.Write, hint.URL)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode relay hints response", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// isHexPubkey reports whether s is a 64-character lowercase hex string.
+func isHexPubkey(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
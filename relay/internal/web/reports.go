@@ -0,0 +1,107 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	reportsDefaultLimit = 50
+	reportsMaxLimit     = 500
+)
+
+// HandleReportsAPI serves the NIP-56 report queue with status counts:
+// GET /api/reports?status=<open|hidden|flagged|dismissed>&type=<event|pubkey>&limit=<n>
+func (h *Handler) HandleReportsAPI(w http.ResponseWriter, r *http.Request) {
+	// Apply security headers for API endpoints
+	apiHeaders := APISecurityHeaders()
+	apiHeaders.Apply(w)
+
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// Handle preflight requests
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Only allow GET requests
+	if r.Method != "GET" {
+		methodErr := errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed.")
+		errors.HandleHTTPError(w, r, methodErr)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := storage.ReportFilter{Limit: reportsDefaultLimit}
+
+	switch status := SanitizeQueryParam(query.Get("status")); status {
+	case "", storage.ReportStatusOpen, storage.ReportStatusHidden, storage.ReportStatusFlagged, storage.ReportStatusDismissed:
+		filter.Status = status
+	default:
+		validationErr := errors.ValidationError("INVALID_STATUS_PARAMETER",
+			"status parameter must be open, hidden, flagged or dismissed").
+			WithUserMessage("Invalid status parameter.")
+		errors.HandleHTTPError(w, r, validationErr)
+		return
+	}
+
+	switch typ := SanitizeQueryParam(query.Get("type")); typ {
+	case "", storage.ReportTargetEvent, storage.ReportTargetPubkey:
+		filter.Type = typ
+	default:
+		validationErr := errors.ValidationError("INVALID_TYPE_PARAMETER",
+			"type parameter must be event or pubkey").
+			WithUserMessage("Invalid type parameter.")
+		errors.HandleHTTPError(w, r, validationErr)
+		return
+	}
+
+	if limit := SanitizeQueryParam(query.Get("limit")); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > reportsMaxLimit {
+			validationErr := errors.ValidationError("INVALID_LIMIT_PARAMETER",
+				"limit parameter must be between 1 and 500").
+				WithUserMessage("Invalid limit parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		filter.Limit = n
+	}
+
+	var tracker *storage.ReportTracker
+	if h.db != nil {
+		tracker = h.db.ReportTracker()
+	}
+	if tracker == nil {
+		notFoundErr := errors.NotFoundError("NIP-56 report queue").
+			WithUserMessage("The report pipeline is disabled on this relay.")
+		errors.HandleHTTPError(w, r, notFoundErr)
+		return
+	}
+
+	response := struct {
+		ByStatus map[string]int         `json:"by_status"`
+		Targets  []storage.ReportTarget `json:"targets"`
+	}{
+		ByStatus: tracker.StatusCounts(),
+		Targets:  tracker.ListTargets(filter),
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode reports response", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Shugur-Network/relay/internal/errors"
+	"github.com/Shugur-Network/relay/internal/spamcluster"
+	"go.uber.org/zap"
+)
+
+const (
+	spamClustersDefaultLimit = 50
+	spamClustersMaxLimit     = 500
+)
+
+// SetSpamClusters sets the near-duplicate detector served at
+// /api/spam-clusters; nil when spam clustering is disabled.
+func (h *Handler) SetSpamClusters(d *spamcluster.Detector) {
+	h.spamClusters = d
+}
+
+// HandleSpamClustersAPI serves near-duplicate spam clusters:
+// GET /api/spam-clusters?status=<flagged|all>&limit=<n>
+func (h *Handler) HandleSpamClustersAPI(w http.ResponseWriter, r *http.Request) {
+	// Apply security headers for API endpoints
+	apiHeaders := APISecurityHeaders()
+	apiHeaders.Apply(w)
+
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// Handle preflight requests
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Only allow GET requests
+	if r.Method != "GET" {
+		methodErr := errors.ValidationError("METHOD_NOT_ALLOWED",
+			"Only GET requests are allowed for this endpoint").
+			WithUserMessage("Method not allowed.")
+		errors.HandleHTTPError(w, r, methodErr)
+		return
+	}
+
+	query := r.URL.Query()
+	flaggedOnly := false
+	switch status := SanitizeQueryParam(query.Get("status")); status {
+	case "", "all":
+	case "flagged":
+		flaggedOnly = true
+	default:
+		validationErr := errors.ValidationError("INVALID_STATUS_PARAMETER",
+			"status parameter must be flagged or all").
+			WithUserMessage("Invalid status parameter.")
+		errors.HandleHTTPError(w, r, validationErr)
+		return
+	}
+
+	limit := spamClustersDefaultLimit
+	if l := SanitizeQueryParam(query.Get("limit")); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > spamClustersMaxLimit {
+			validationErr := errors.ValidationError("INVALID_LIMIT_PARAMETER",
+				"limit parameter must be between 1 and 500").
+				WithUserMessage("Invalid limit parameter.")
+			errors.HandleHTTPError(w, r, validationErr)
+			return
+		}
+		limit = n
+	}
+
+	if h.spamClusters == nil {
+		notFoundErr := errors.NotFoundError("Spam clustering").
+			WithUserMessage("Spam clustering is disabled on this relay.")
+		errors.HandleHTTPError(w, r, notFoundErr)
+		return
+	}
+
+	total, flagged, clusters := h.spamClusters.Snapshot(limit, flaggedOnly)
+	response := struct {
+		Total    int                   `json:"total"`
+		Flagged  int                   `json:"flagged"`
+		Clusters []spamcluster.Cluster `json:"clusters"`
+	}{
+		Total:    total,
+		Flagged:  flagged,
+		Clusters: clusters,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode spam clusters response", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
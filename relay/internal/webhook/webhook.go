@@ -0,0 +1,249 @@
+// Package webhook POSTs accepted events to operator-configured HTTP endpoints,
+// for moderation bots and integrations that cannot hold a websocket open.
+//
+// Each endpoint has a NIP-01 filter and an optional secret. The request body
+// is the event as JSON; with a secret the request carries
+//
+//	X-Webhook-Timestamp: <unix seconds>
+//	X-Webhook-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// so receivers can check its origin and reject replays. Failed deliveries are
+// retried with exponential backoff; those that still fail, or that the
+// endpoint rejects with a 4xx status, are dead-lettered: logged and counted.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shugur-Network/relay/internal/config"
+	"github.com/Shugur-Network/relay/internal/logger"
+	"github.com/Shugur-Network/relay/internal/metrics"
+	"github.com/Shugur-Network/relay/internal/storage"
+	nostr "github.com/nbd-wtf/go-nostr"
+	"go.uber.org/zap"
+)
+
+// Defaults for settings left unset.
+const (
+	defaultWorkers    = 4
+	defaultQueueSize  = 10000
+	defaultRetryDelay = 5 * time.Second
+	defaultTimeout    = 10 * time.Second
+)
+
+// endpoint is a configured webhook.
+type endpoint struct {
+	url    string
+	secret []byte
+	filter *nostr.Filter // nil = every event
+}
+
+// delivery is one event on its way to one endpoint.
+type delivery struct {
+	ep      *endpoint
+	eventID string
+	body    []byte
+	attempt int
+}
+
+// permanentError is a delivery the endpoint refused; it is not retried.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+// Notifier matches accepted events against the endpoints and delivers them.
+type Notifier struct {
+	cfg       config.WebhooksConfig
+	endpoints []*endpoint
+	queue     chan delivery
+	client    *http.Client
+	log       *zap.Logger
+}
+
+// New creates a notifier for the configured endpoints.
+func New(cfg config.WebhooksConfig) (*Notifier, error) {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = defaultRetryDelay
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	n := &Notifier{
+		cfg:    cfg,
+		queue:  make(chan delivery, cfg.QueueSize),
+		client: &http.Client{Timeout: cfg.Timeout},
+		log:    logger.New("webhook"),
+	}
+	for i, ec := range cfg.Endpoints {
+		ep := &endpoint{url: ec.URL, secret: []byte(ec.Secret)}
+		if ec.Filter != "" {
+			var f nostr.Filter
+			if err := json.Unmarshal([]byte(ec.Filter), &f); err != nil {
+				return nil, fmt.Errorf("webhook %d (%s): invalid filter: %w", i, ec.URL, err)
+			}
+			ep.filter = &f
+		}
+		n.endpoints = append(n.endpoints, ep)
+	}
+	return n, nil
+}
+
+// Start launches the delivery workers. They exit when ctx is canceled.
+func (n *Notifier) Start(ctx context.Context) {
+	for i := 0; i < n.cfg.Workers; i++ {
+		go n.worker(ctx)
+	}
+	n.log.Info("Webhooks started",
+		zap.Int("endpoints", len(n.endpoints)),
+		zap.Int("workers", n.cfg.Workers))
+}
+
+// Submit queues an accepted event for every endpoint it matches without blocking.
+func (n *Notifier) Submit(evt *nostr.Event) {
+	var body []byte
+	for _, ep := range n.endpoints {
+		if ep.filter != nil && !storage.MatchesFilter(*ep.filter, evt) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(evt); err != nil {
+				return
+			}
+		}
+		n.enqueue(delivery{ep: ep, eventID: evt.ID, body: body})
+	}
+}
+
+// enqueue adds a delivery to the queue without blocking.
+func (n *Notifier) enqueue(d delivery) bool {
+	select {
+	case n.queue <- d:
+		return true
+	default:
+		metrics.WebhookDeliveries.WithLabelValues("dropped").Inc()
+		return false
+	}
+}
+
+// worker sends queued deliveries until ctx is canceled.
+func (n *Notifier) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-n.queue:
+			n.deliver(ctx, d)
+		}
+	}
+}
+
+// deliver sends one delivery and schedules its retry when it fails.
+func (n *Notifier) deliver(ctx context.Context, d delivery) {
+	err := n.send(ctx, d)
+	if err == nil {
+		metrics.WebhookDeliveries.WithLabelValues("delivered").Inc()
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	_, permanent := err.(*permanentError)
+	if permanent || d.attempt >= n.cfg.MaxRetries {
+		n.deadLetter(d, err)
+		return
+	}
+	metrics.WebhookDeliveries.WithLabelValues("retried").Inc()
+	delay := n.cfg.RetryDelay << d.attempt
+	d.attempt++
+	n.log.Debug("Webhook delivery failed, retrying",
+		zap.String("url", d.ep.url),
+		zap.String("event_id", d.eventID),
+		zap.Duration("delay", delay),
+		zap.Error(err))
+
+	// Wait outside the workers so one slow endpoint does not hold them up
+	time.AfterFunc(delay, func() {
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case n.queue <- d:
+		default:
+			n.deadLetter(d, fmt.Errorf("queue full on retry"))
+		}
+	})
+}
+
+// deadLetter gives up on a delivery.
+func (n *Notifier) deadLetter(d delivery, err error) {
+	metrics.WebhookDeliveries.WithLabelValues("dead_letter").Inc()
+	n.log.Warn("Webhook delivery dead-lettered",
+		zap.String("url", d.ep.url),
+		zap.String("event_id", d.eventID),
+		zap.Int("attempts", d.attempt+1),
+		zap.Error(err))
+}
+
+// send POSTs a delivery. 2xx answers succeed; 4xx answers other than 408 and
+// 429 are permanent failures.
+func (n *Notifier) send(ctx context.Context, d delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.ep.url, bytes.NewReader(d.body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "shugur-relay-webhook")
+	req.Header.Set("X-Webhook-Event", d.eventID)
+	if len(d.ep.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Webhook-Timestamp", timestamp)
+		req.Header.Set("X-Webhook-Signature", "sha256="+Sign(d.ep.secret, timestamp, d.body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("endpoint answered with status %d", resp.StatusCode)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &permanentError{fmt.Errorf("endpoint rejected the delivery with status %d", resp.StatusCode)}
+	default:
+		return fmt.Errorf("endpoint answered with status %d", resp.StatusCode)
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" under secret, the
+// value of the X-Webhook-Signature header after "sha256=".
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,81 @@
+package workers
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+)
+
+// sigJob is a single pending signature verification.
+type sigJob struct {
+	evt    *nostr.Event
+	result chan bool
+}
+
+// SignatureVerifier verifies event signatures on a fixed pool of workers so a
+// burst of events spread across connections uses every core instead of
+// verifying inline on each connection's read goroutine. secp256k1 Schnorr in
+// btcec has no batch verification API, so each worker checks one signature
+// at a time and the pool verifies as many in parallel as it has workers.
+type SignatureVerifier struct {
+	jobs     chan sigJob
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSignatureVerifier starts workerCount verification workers (NumCPU when <= 0).
+func NewSignatureVerifier(workerCount, queueSize int) *SignatureVerifier {
+	if workerCount <= 0 {
+		workerCount = runtime.NumCPU()
+	}
+	if queueSize <= 0 {
+		queueSize = 4096
+	}
+	sv := &SignatureVerifier{
+		jobs: make(chan sigJob, queueSize),
+		done: make(chan struct{}),
+	}
+	for i := 0; i < workerCount; i++ {
+		go sv.worker()
+	}
+	return sv
+}
+
+// worker verifies queued signatures one at a time until the verifier stops.
+func (sv *SignatureVerifier) worker() {
+	for {
+		select {
+		case <-sv.done:
+			return
+		case job := <-sv.jobs:
+			ok, err := job.evt.CheckSignature()
+			job.result <- ok && err == nil
+		}
+	}
+}
+
+// Verify checks an event's signature on the pool. If the queue is full the
+// signature is verified inline rather than rejecting the event.
+func (sv *SignatureVerifier) Verify(ctx context.Context, evt *nostr.Event) (bool, error) {
+	job := sigJob{evt: evt, result: make(chan bool, 1)}
+	select {
+	case sv.jobs <- job:
+	default:
+		ok, err := evt.CheckSignature()
+		return ok && err == nil, nil
+	}
+
+	select {
+	case ok := <-job.result:
+		return ok, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// Stop shuts down the workers. Pending Verify calls return via their context.
+func (sv *SignatureVerifier) Stop() {
+	sv.stopOnce.Do(func() { close(sv.done) })
+}
@@ -0,0 +1,10 @@
+// Package web embeds the dashboard templates and static assets so the relay
+// binary serves its dashboard regardless of the working directory.
+package web
+
+import "embed"
+
+// FS holds templates/ and static/.
+//
+//go:embed templates static
+var FS embed.FS
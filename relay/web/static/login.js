@@ -0,0 +1,55 @@
+// Dashboard login: signs a NIP-98 (kind 27235) request with a NIP-07 extension
+// and exchanges it for a session cookie at /api/auth/login.
+
+async function login() {
+  const errorEl = document.getElementById('login-error');
+  errorEl.hidden = true;
+
+  if (!window.nostr) {
+    showLoginError('No Nostr extension found. Install a NIP-07 signer such as Alby or nos2x.');
+    return;
+  }
+
+  const url = `${window.location.origin}/api/auth/login`;
+  try {
+    const event = await window.nostr.signEvent({
+      kind: 27235,
+      created_at: Math.floor(Date.now() / 1000),
+      tags: [
+        ['u', url],
+        ['method', 'POST'],
+      ],
+      content: '',
+    });
+
+    const response = await fetch(url, {
+      method: 'POST',
+      credentials: 'same-origin',
+      headers: { Authorization: `Nostr ${btoa(JSON.stringify(event))}` },
+    });
+    if (!response.ok) {
+      let message = `Login failed (HTTP ${response.status})`;
+      try {
+        const data = await response.json();
+        if (data.error && data.error.message) message = data.error.message;
+      } catch (_) {
+        // keep the status message
+      }
+      showLoginError(message);
+      return;
+    }
+    window.location.href = '/';
+  } catch (error) {
+    showLoginError(`Login failed: ${error.message || error}`);
+  }
+}
+
+function showLoginError(message) {
+  const errorEl = document.getElementById('login-error');
+  errorEl.textContent = message;
+  errorEl.hidden = false;
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+  document.getElementById('login-btn').addEventListener('click', login);
+});
@@ -9,6 +9,29 @@ class RelayDashboard {
   init() {
     this.setupEventListeners();
     this.startStatsUpdates();
+    this.updateSession();
+  }
+
+  // Show the logged-in role and a logout button when dashboard auth is on
+  async updateSession() {
+    const info = document.getElementById('session-info');
+    if (!info) return;
+    try {
+      const response = await fetch('/api/auth/session', { credentials: 'same-origin' });
+      if (!response.ok) return;
+      const session = await response.json();
+      if (!session.pubkey) return;
+
+      document.getElementById('session-role').textContent =
+        `${session.role} ${session.pubkey.slice(0, 8)}…`;
+      document.getElementById('logout-btn').addEventListener('click', async () => {
+        await fetch('/api/auth/logout', { method: 'POST', credentials: 'same-origin' });
+        window.location.reload();
+      });
+      info.hidden = false;
+    } catch (error) {
+      console.warn('Failed to load session:', error);
+    }
   }
 
   // Setup event listeners
@@ -90,6 +113,291 @@ class RelayDashboard {
     }
   }
 
+  // Update the NIP-90 DVM job panel (stays hidden when tracking is disabled)
+  async updateDVMJobs() {
+    const panel = document.getElementById('dvm-panel');
+    if (!panel) return;
+    try {
+      const response = await fetch('/api/dvm/jobs?limit=1');
+      if (!response.ok) {
+        panel.hidden = true;
+        return;
+      }
+
+      const data = await response.json();
+      document.getElementById('dvm-total').textContent = `(${data.total.toLocaleString()})`;
+
+      const rows = Object.entries(data.by_status || {})
+        .sort((a, b) => b[1] - a[1])
+        .map(([status, count]) => {
+          const row = document.createElement('div');
+          row.className = 'cfg';
+          const key = document.createElement('span');
+          key.className = 'cfg-k';
+          key.textContent = status;
+          const val = document.createElement('span');
+          val.className = 'cfg-v';
+          val.textContent = count.toLocaleString();
+          row.append(key, val);
+          return row;
+        });
+      document.getElementById('dvm-status').replaceChildren(...rows);
+      panel.hidden = rows.length === 0;
+    } catch (error) {
+      console.warn('Failed to update DVM jobs:', error);
+    }
+  }
+
+  // Update the NIP-56 report queue panel (hidden when the pipeline is off)
+  // Show REQ to EOSE latency and result size percentiles per filter shape
+  updateQueryLatency(byShape) {
+    const panel = document.getElementById('query-latency-panel');
+    if (!panel) return;
+
+    const rows = Object.entries(byShape)
+      .sort((a, b) => b[1].requests - a[1].requests)
+      .map(([shape, q]) => {
+        const row = document.createElement('div');
+        row.className = 'cfg';
+        const key = document.createElement('span');
+        key.className = 'cfg-k';
+        key.textContent = `${shape} (${q.requests.toLocaleString()})`;
+        const val = document.createElement('span');
+        val.className = 'cfg-v';
+        val.textContent = `${q.p50_ms} / ${q.p95_ms} / ${q.p99_ms} ms, ${q.p50_events} / ${q.p95_events} / ${q.p99_events} events`;
+        row.append(key, val);
+        return row;
+      });
+    document.getElementById('query-latency-list').replaceChildren(...rows);
+    panel.hidden = rows.length === 0;
+  }
+
+  async updateReports() {
+    const panel = document.getElementById('reports-panel');
+    if (!panel) return;
+    try {
+      const response = await fetch('/api/reports?limit=1');
+      if (!response.ok) {
+        panel.hidden = true;
+        return;
+      }
+
+      const data = await response.json();
+      const byStatus = data.by_status || {};
+      document.getElementById('reports-open').textContent = `(${(byStatus.open || 0).toLocaleString()} open)`;
+
+      const rows = Object.entries(byStatus)
+        .sort((a, b) => b[1] - a[1])
+        .map(([status, count]) => {
+          const row = document.createElement('div');
+          row.className = 'cfg';
+          const key = document.createElement('span');
+          key.className = 'cfg-k';
+          key.textContent = status;
+          const val = document.createElement('span');
+          val.className = 'cfg-v';
+          val.textContent = count.toLocaleString();
+          row.append(key, val);
+          return row;
+        });
+      document.getElementById('reports-status').replaceChildren(...rows);
+      panel.hidden = rows.length === 0;
+    } catch (error) {
+      console.warn('Failed to update reports:', error);
+    }
+  }
+
+  // Update the spam cluster panel with the flagged clusters (hidden when
+  // clustering is off or nothing is flagged)
+  async updateSpamClusters() {
+    const panel = document.getElementById('spam-clusters-panel');
+    if (!panel) return;
+    try {
+      const response = await fetch('/api/spam-clusters?status=flagged&limit=10');
+      if (!response.ok) {
+        panel.hidden = true;
+        return;
+      }
+
+      const data = await response.json();
+      document.getElementById('spam-clusters-flagged').textContent = `(${data.flagged.toLocaleString()} flagged)`;
+
+      const rows = (data.clusters || []).map((cluster) => {
+        const row = document.createElement('div');
+        row.className = 'cfg';
+        const key = document.createElement('span');
+        key.className = 'cfg-k';
+        key.textContent = cluster.sample;
+        key.title = cluster.id;
+        const val = document.createElement('span');
+        val.className = 'cfg-v';
+        val.textContent = `${cluster.pubkeys.toLocaleString()} pubkeys, ${cluster.dropped.toLocaleString()} dropped`;
+        row.append(key, val);
+        return row;
+      });
+      document.getElementById('spam-clusters-list').replaceChildren(...rows);
+      panel.hidden = rows.length === 0;
+    } catch (error) {
+      console.warn('Failed to update spam clusters:', error);
+    }
+  }
+
+  // Update the clients panel with the busiest client software and the NIPs
+  // in use (hidden when client telemetry is off)
+  async updateClients() {
+    const panel = document.getElementById('clients-panel');
+    if (!panel) return;
+    try {
+      const response = await fetch('/api/clients?limit=10');
+      if (!response.ok) {
+        panel.hidden = true;
+        return;
+      }
+
+      const data = await response.json();
+      document.getElementById('clients-total').textContent = `(${data.total.toLocaleString()} clients)`;
+
+      const rows = (data.clients || []).map((client) => {
+        const commands = Object.values(client.commands || {}).reduce((sum, n) => sum + n, 0);
+        const events = Object.values(client.kinds || {}).reduce((sum, n) => sum + n, 0);
+        const row = document.createElement('div');
+        row.className = 'cfg';
+        const key = document.createElement('span');
+        key.className = 'cfg-k';
+        key.textContent = client.name;
+        const val = document.createElement('span');
+        val.className = 'cfg-v';
+        val.textContent = `${client.connections.toLocaleString()} connections, ${commands.toLocaleString()} commands, ${events.toLocaleString()} events`;
+        row.append(key, val);
+        return row;
+      });
+      document.getElementById('clients-list').replaceChildren(...rows);
+
+      const nips = Object.entries(data.nips || {}).sort((a, b) => b[1] - a[1]);
+      document.getElementById('clients-nips').textContent = nips
+        .map(([nip, n]) => `NIP-${nip}: ${n.toLocaleString()}`)
+        .join(' · ');
+      panel.hidden = rows.length === 0;
+    } catch (error) {
+      console.warn('Failed to update clients:', error);
+    }
+  }
+
+  // Update the NIP-05 panel with the cached lookups and the most recent ones
+  // (hidden when verification is off or nothing was looked up yet)
+  async updateNIP05() {
+    const panel = document.getElementById('nip05-panel');
+    if (!panel) return;
+    try {
+      const response = await fetch('/api/nip05?limit=10');
+      if (!response.ok) {
+        panel.hidden = true;
+        return;
+      }
+
+      const data = await response.json();
+      document.getElementById('nip05-mode').textContent =
+        data.require_for_write ? '(required to publish)' : '(optional)';
+
+      const row = (label, value, title) => {
+        const el = document.createElement('div');
+        el.className = 'cfg';
+        const key = document.createElement('span');
+        key.className = 'cfg-k';
+        key.textContent = label;
+        const val = document.createElement('span');
+        val.className = 'cfg-v';
+        val.textContent = value;
+        if (title) val.title = title;
+        el.append(key, val);
+        return el;
+      };
+      const stats = data.stats || {};
+      document.getElementById('nip05-stats').replaceChildren(
+        row('cached', (stats.cached || 0).toLocaleString()),
+        row('resolved', (stats.resolved || 0).toLocaleString()),
+        row('failed', (stats.failed || 0).toLocaleString()),
+      );
+      const lookups = (data.lookups || []).map((lookup) =>
+        row(lookup.identifier,
+          lookup.error ? 'failed' : `${lookup.pubkey.slice(0, 12)}…`,
+          lookup.error || lookup.pubkey));
+      document.getElementById('nip05-lookups').replaceChildren(...lookups);
+      panel.hidden = lookups.length === 0 && !data.require_for_write;
+    } catch (error) {
+      console.warn('Failed to update NIP-05 verification:', error);
+    }
+  }
+
+  // Show a banner with the client anomaly alerts of the last hour (hidden
+  // when detection is off or nothing was flagged)
+  async updateAnomalies() {
+    const banner = document.getElementById('anomaly-banner');
+    if (!banner) return;
+    try {
+      const since = Math.floor(Date.now() / 1000) - 3600;
+      const response = await fetch(`/api/anomalies?since=${since}&limit=100`);
+      if (!response.ok) {
+        banner.hidden = true;
+        return;
+      }
+
+      const data = await response.json();
+      const alerts = data.alerts || [];
+      if (alerts.length === 0) {
+        banner.hidden = true;
+        return;
+      }
+      const latest = alerts[0];
+      document.getElementById('anomaly-text').textContent =
+        `${alerts.length.toLocaleString()} anomaly alert${alerts.length === 1 ? '' : 's'} in the last hour; ` +
+        `latest: ${latest.subject} ${latest.feature} ${latest.value.toLocaleString()} ` +
+        `(baseline ${latest.baseline.toFixed(2)}, ${latest.deviation.toFixed(1)}σ)`;
+      banner.hidden = false;
+    } catch (error) {
+      console.warn('Failed to update anomalies:', error);
+    }
+  }
+
+  // Update the recent events panel with the delay between each event's
+  // created_at and the time the relay received it (hidden unless the events
+  // API exposes received_at)
+  async updateReceivedAt() {
+    const panel = document.getElementById('received-panel');
+    if (!panel) return;
+    try {
+      const response = await fetch('/api/events?limit=10');
+      if (!response.ok) {
+        panel.hidden = true;
+        return;
+      }
+
+      const data = await response.json();
+      const events = (data.events || []).filter((evt) => evt.received_at);
+      const delays = events.map((evt) => evt.received_at - evt.created_at).sort((a, b) => a - b);
+      const median = delays.length ? delays[Math.floor(delays.length / 2)] : 0;
+      document.getElementById('received-delay').textContent = `(median delay ${median.toLocaleString()}s)`;
+
+      const rows = events.map((evt) => {
+        const row = document.createElement('div');
+        row.className = 'cfg';
+        const key = document.createElement('span');
+        key.className = 'cfg-k';
+        key.textContent = `kind ${evt.kind} · ${evt.id.slice(0, 12)}`;
+        key.title = evt.id;
+        const val = document.createElement('span');
+        val.className = 'cfg-v';
+        val.textContent = `received ${new Date(evt.received_at * 1000).toLocaleTimeString()} (+${(evt.received_at - evt.created_at).toLocaleString()}s)`;
+        row.append(key, val);
+        return row;
+      });
+      document.getElementById('received-list').replaceChildren(...rows);
+      panel.hidden = rows.length === 0;
+    } catch (error) {
+      console.warn('Failed to update recent events:', error);
+    }
+  }
+
   // Update statistics by fetching from API
   async updateStats() {
     try {
@@ -104,6 +412,7 @@ class RelayDashboard {
       if (data.stats) {
         this.updateStatElement('active-connections', data.stats.active_connections);
         this.updateStatElement('events-stored', data.stats.events_stored);
+        this.updateQueryLatency(data.stats.query_latency || {});
       }
 
       // Update live since
@@ -111,6 +420,15 @@ class RelayDashboard {
         this.updateStatElement('live-since', data.live_since);
       }
       
+      // Update DVM job counts
+      this.updateDVMJobs();
+      this.updateReports();
+      this.updateSpamClusters();
+      this.updateClients();
+      this.updateAnomalies();
+      this.updateNIP05();
+      this.updateReceivedAt();
+
       // Update online indicator
       this.updateOnlineIndicator(true);
       this.addLastUpdatedIndicator();
@@ -347,6 +665,7 @@ function formatUptime(seconds) {
 document.addEventListener("DOMContentLoaded", () => {
   new RelayDashboard();
   new DatabaseClusterInfo();
+  new LiveTraffic();
 
   // Set WebSocket URL dynamically
   const websocketUrlElement = document.getElementById("websocket-url");
@@ -356,6 +675,143 @@ document.addEventListener("DOMContentLoaded", () => {
   }
 });
 
+// Live traffic panel fed by the /api/live WebSocket
+class LiveTraffic {
+  constructor() {
+    this.panel = document.getElementById('live-panel');
+    this.canvas = document.getElementById('live-chart');
+    this.history = []; // events per second, oldest first
+    this.maxPoints = 60;
+    this.retryDelay = 1000;
+    this.socket = null;
+    if (!this.panel || !this.canvas) return;
+
+    this.connect();
+    document.addEventListener('visibilitychange', () => {
+      if (document.hidden) {
+        this.disconnect();
+      } else {
+        this.connect();
+      }
+    });
+  }
+
+  connect() {
+    if (this.socket) return;
+    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+    const socket = new WebSocket(`${protocol}//${window.location.host}/api/live`);
+    this.socket = socket;
+
+    socket.onopen = () => {
+      this.retryDelay = 1000;
+      this.panel.hidden = false;
+    };
+    socket.onmessage = (msg) => {
+      try {
+        this.render(JSON.parse(msg.data));
+      } catch (error) {
+        console.warn('Invalid live frame:', error);
+      }
+    };
+    socket.onclose = () => {
+      if (this.socket !== socket) return;
+      this.socket = null;
+      if (document.hidden) return;
+      // Back off up to a minute while the endpoint is unavailable
+      setTimeout(() => this.connect(), this.retryDelay);
+      this.retryDelay = Math.min(this.retryDelay * 2, 60000);
+    };
+  }
+
+  disconnect() {
+    const socket = this.socket;
+    this.socket = null;
+    if (socket) socket.close();
+  }
+
+  render(frame) {
+    const rate = Object.values(frame.kind_rates || {}).reduce((sum, n) => sum + n, 0);
+    this.history.push(rate);
+    if (this.history.length > this.maxPoints) this.history.shift();
+    document.getElementById('live-rate').textContent = `(${rate.toLocaleString()} ev/s)`;
+    this.drawChart();
+
+    this.fillRows('live-kinds', Object.entries(frame.kind_totals || {}), (kind) => `kind ${kind}`);
+    this.fillRows('live-rejections', Object.entries(frame.rejections || {}), (reason) => reason);
+    this.fillRows('live-talkers',
+      (frame.top_talkers || []).map((t) => [t.pubkey, t.events]),
+      (pubkey) => `${pubkey.slice(0, 8)}…${pubkey.slice(-4)}`);
+    this.fillRows('live-queues', Object.entries(frame.queue_depths || {}), (cls) => cls);
+  }
+
+  fillRows(elementId, entries, label) {
+    const rows = entries
+      .sort((a, b) => b[1] - a[1])
+      .slice(0, 10)
+      .map(([key, count]) => {
+        const row = document.createElement('div');
+        row.className = 'cfg';
+        const k = document.createElement('span');
+        k.className = 'cfg-k';
+        k.textContent = label(key);
+        k.title = key;
+        const v = document.createElement('span');
+        v.className = 'cfg-v';
+        v.textContent = count.toLocaleString();
+        row.append(k, v);
+        return row;
+      });
+    if (rows.length === 0) {
+      const row = document.createElement('div');
+      row.className = 'cfg';
+      const k = document.createElement('span');
+      k.className = 'cfg-k';
+      k.textContent = 'none';
+      row.append(k);
+      rows.push(row);
+    }
+    document.getElementById(elementId).replaceChildren(...rows);
+  }
+
+  drawChart() {
+    const canvas = this.canvas;
+    const ratio = window.devicePixelRatio || 1;
+    const width = canvas.clientWidth * ratio;
+    const height = canvas.clientHeight * ratio;
+    if (canvas.width !== width) canvas.width = width;
+    if (canvas.height !== height) canvas.height = height;
+
+    const ctx = canvas.getContext('2d');
+    ctx.clearRect(0, 0, width, height);
+    if (this.history.length < 2) return;
+
+    const peak = Math.max(1, ...this.history);
+    const step = width / (this.maxPoints - 1);
+    const offset = (this.maxPoints - this.history.length) * step;
+    const y = (n) => height - (n / peak) * (height - 8 * ratio) - 2 * ratio;
+
+    ctx.beginPath();
+    this.history.forEach((n, i) => {
+      const x = offset + i * step;
+      if (i === 0) ctx.moveTo(x, y(n));
+      else ctx.lineTo(x, y(n));
+    });
+    ctx.strokeStyle = '#00e599';
+    ctx.lineWidth = 2 * ratio;
+    ctx.stroke();
+
+    ctx.lineTo(offset + (this.history.length - 1) * step, height);
+    ctx.lineTo(offset, height);
+    ctx.closePath();
+    ctx.fillStyle = 'rgba(0, 229, 153, 0.15)';
+    ctx.fill();
+
+    ctx.fillStyle = '#555555';
+    ctx.font = `${10 * ratio}px monospace`;
+    ctx.fillText(`peak ${peak.toLocaleString()} ev/s`, 6 * ratio, 14 * ratio);
+  }
+}
+
 // Database Cluster Information Handler
 class DatabaseClusterInfo {
   constructor() {